@@ -252,4 +252,6 @@ const (
 	TopUpStatusSuccess = "success"
 	TopUpStatusFailed  = "failed"
 	TopUpStatusExpired = "expired"
+	// TopUpStatusRefunded 支付渠道已全额退款
+	TopUpStatusRefunded = "refunded"
 )
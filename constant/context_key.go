@@ -19,6 +19,7 @@ const (
 	ContextKeyTokenModelLimitEnabled ContextKey = "token_model_limit_enabled"
 	ContextKeyTokenModelLimit        ContextKey = "token_model_limit"
 	ContextKeyTokenCrossGroupRetry   ContextKey = "token_cross_group_retry"
+	ContextKeyTokenOrganizationId    ContextKey = "token_organization_id"
 
 	/* channel related keys */
 	ContextKeyChannelId                   ContextKey = "channel_id"
@@ -47,6 +48,10 @@ const (
 	// only to normal (healthy) channels, provided normal channels are available.
 	ContextKeyObservedChannelTriedAndFailed ContextKey = "observed_channel_tried_and_failed"
 
+	// ContextKeyConcurrencySaturatedChannels lists channel IDs ([]int) whose
+	// concurrency cap was full when selected during this request.
+	ContextKeyConcurrencySaturatedChannels ContextKey = "concurrency_saturated_channels"
+
 	ContextKeyAutoGroup           ContextKey = "auto_group"
 	ContextKeyAutoGroupIndex      ContextKey = "auto_group_index"
 	ContextKeyAutoGroupRetryIndex ContextKey = "auto_group_retry_index"
@@ -63,6 +68,10 @@ const (
 
 	ContextKeyLocalCountTokens ContextKey = "local_count_tokens"
 
+	// ContextKeySettledUsage stores the usage the request was billed with, so
+	// post-response consumers (e.g. shadow traffic comparison) can read it.
+	ContextKeySettledUsage ContextKey = "settled_usage"
+
 	ContextKeySystemPromptOverride ContextKey = "system_prompt_override"
 
 	// ContextKeyFileSourcesToCleanup stores file sources that need cleanup when request ends
@@ -81,4 +90,13 @@ const (
 	// fallback in authHelper (finishAdminAudit) skips its record to avoid
 	// duplicate entries.
 	ContextKeyAuditLogged ContextKey = "audit_logged"
+
+	// ContextKeyResponsesInputItems stores the expanded /v1/responses input items
+	// (history from previous_response_id plus the current input), so converted
+	// handlers can persist the turn for stateful Responses emulation.
+	ContextKeyResponsesInputItems ContextKey = "responses_input_items"
+
+	// ContextKeyCohereEmbedFloats marks a Cohere /v1/embed request without
+	// embedding_types, whose response must use the legacy embeddings_floats shape.
+	ContextKeyCohereEmbedFloats ContextKey = "cohere_embed_floats"
 )
@@ -30,22 +30,35 @@ var auditContentTemplates = map[string]string{
 	"user.reset_passkey":    "Reset the user passkey",
 	"option.update":         "Updated system setting ${key}",
 
-	"channel.create":             "Created channel ${name} (type ${type}, count ${count})",
-	"channel.update":             "Updated channel ${name} (ID: ${id})",
-	"channel.delete":             "Deleted channel ${name} (ID: ${id})",
-	"channel.delete_batch":       "Batch deleted ${count} channels",
-	"channel.delete_disabled":    "Deleted all disabled channels (${count})",
-	"channel.key_view":           "Viewed channel key ${name} (ID: ${id})",
-	"channel.tag_disable":        "Disabled channels with tag ${tag}",
-	"channel.tag_enable":         "Enabled channels with tag ${tag}",
-	"channel.tag_edit":           "Edited channels with tag ${tag}",
-	"channel.tag_batch_set":      "Batch set tag for ${count} channels",
-	"channel.copy":               "Copied channel (source ID: ${sourceId}) to ${name} (new ID: ${id})",
-	"channel.multi_key_manage":   "Multi-key management ${action} on channel (ID: ${id})",
-	"channel.upstream_apply":     "Applied upstream model changes to channel (ID: ${id})",
-	"channel.upstream_apply_all": "Applied upstream model changes to ${count} channels",
-
-	"redemption.create": "Created ${count} redemption codes named ${name} (${quota} each)",
+	"channel.create":              "Created channel ${name} (type ${type}, count ${count})",
+	"channel.update":              "Updated channel ${name} (ID: ${id})",
+	"channel.delete":              "Deleted channel ${name} (ID: ${id})",
+	"channel.delete_batch":        "Batch deleted ${count} channels",
+	"channel.delete_disabled":     "Deleted all disabled channels (${count})",
+	"channel.key_view":            "Viewed channel key ${name} (ID: ${id})",
+	"channel.tag_disable":         "Disabled channels with tag ${tag}",
+	"channel.tag_enable":          "Enabled channels with tag ${tag}",
+	"channel.tag_edit":            "Edited channels with tag ${tag}",
+	"channel.tag_batch_set":       "Batch set tag for ${count} channels",
+	"channel.copy":                "Copied channel (source ID: ${sourceId}) to ${name} (new ID: ${id})",
+	"channel.multi_key_manage":    "Multi-key management ${action} on channel (ID: ${id})",
+	"channel.upstream_apply":      "Applied upstream model changes to channel (ID: ${id})",
+	"channel.upstream_apply_all":  "Applied upstream model changes to ${count} channels",
+	"channel.key_rotate":          "Rotated key ${key_index} of channel (ID: ${id}) with a ${grace_seconds}s grace period",
+	"channel.key_metadata_update": "Updated metadata of key ${key_index} on channel (ID: ${id})",
+
+	"redemption.create":       "Created ${count} redemption codes named ${name} (${quota} each)",
+	"coupon.create":           "Created coupon ${code} (${type} ${percent}%)",
+	"coupon.update":           "Updated coupon ${code} (ID: ${id})",
+	"coupon.delete":           "Deleted coupon (ID: ${id})",
+	"postpaid.account_update": "Set credit limit of ${owner_type} ${owner_id} to ${credit_limit}",
+	"postpaid.payment_record": "Recorded payment of ${amount} for postpaid statement (ID: ${statement_id})",
+
+	"organization.quota_adjust":  "Adjusted quota of organization (ID: ${id}) by ${delta}",
+	"organization.status_update": "Set status of organization (ID: ${id}) to ${status}",
+
+	"quota_ledger.reconcile": "Ran quota ledger reconciliation (${checked} users checked, ${drifted} drifted)",
+	"invoice.issue":          "Issued invoice ${invoice_no} for ${source}",
 
 	"subscription.plan_reset":      "Reset active subscriptions for plan ${plan_id}",
 	"subscription.user_plan_reset": "Reset active plan ${plan_id} subscriptions for user ${target_user_id}",
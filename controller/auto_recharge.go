@@ -0,0 +1,239 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/service"
+	"github.com/QuantumNous/new-api/setting"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stripe/stripe-go/v81"
+	"github.com/stripe/stripe-go/v81/checkout/session"
+	"github.com/stripe/stripe-go/v81/customer"
+	"github.com/stripe/stripe-go/v81/setupintent"
+)
+
+// autoRechargeMaxAmount 单次自动充值数量上限，防止误填导致大额扣款
+const autoRechargeMaxAmount int64 = 10000
+
+type AutoRechargeUpdateRequest struct {
+	Enabled   bool  `json:"enabled"`
+	Threshold int   `json:"threshold"`
+	Amount    int64 `json:"amount"`
+	DailyCap  int64 `json:"daily_cap"`
+}
+
+func isAutoRechargeAvailable() bool {
+	return operation_setting.GetAutoRechargeSetting().Enabled && isStripeTopUpEnabled()
+}
+
+func GetAutoRecharge(c *gin.Context) {
+	config, err := model.GetAutoRechargeConfig(c.GetInt("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	autoSetting := operation_setting.GetAutoRechargeSetting()
+	common.ApiSuccess(c, gin.H{
+		"available":           isAutoRechargeAvailable(),
+		"config":              config,
+		"has_payment_method":  config.HasPaymentMethod(),
+		"min_amount":          getStripeMinTopup(),
+		"max_amount":          autoRechargeMaxAmount,
+		"max_charges_per_day": autoSetting.MaxChargesPerDay,
+		"currency":            autoSetting.Currency,
+	})
+}
+
+func UpdateAutoRecharge(c *gin.Context) {
+	var req AutoRechargeUpdateRequest
+	if err := common.DecodeJson(c.Request.Body, &req); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if req.Enabled && !isAutoRechargeAvailable() {
+		common.ApiErrorMsg(c, "管理员未开启自动充值")
+		return
+	}
+	if req.Threshold < 0 || req.DailyCap < 0 {
+		common.ApiErrorMsg(c, "参数错误")
+		return
+	}
+	if req.Enabled {
+		if req.Amount < getStripeMinTopup() {
+			common.ApiErrorMsg(c, fmt.Sprintf("充值数量不能小于 %d", getStripeMinTopup()))
+			return
+		}
+		if req.Amount > autoRechargeMaxAmount {
+			common.ApiErrorMsg(c, fmt.Sprintf("充值数量不能大于 %d", autoRechargeMaxAmount))
+			return
+		}
+		if req.DailyCap > 0 && req.DailyCap < req.Amount {
+			common.ApiErrorMsg(c, "每日上限不能小于单次充值数量")
+			return
+		}
+	}
+	userId := c.GetInt("id")
+	config, err := model.UpdateAutoRechargeRules(userId, req.Enabled, req.Threshold, req.Amount, req.DailyCap)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	service.InvalidateAutoRechargeGate(userId)
+	common.ApiSuccess(c, config)
+}
+
+// SetupAutoRechargePaymentMethod 创建 setup 模式的 Stripe Checkout，用户授权后由回调保存支付方式
+func SetupAutoRechargePaymentMethod(c *gin.Context) {
+	if !isAutoRechargeAvailable() {
+		common.ApiErrorMsg(c, "管理员未开启自动充值")
+		return
+	}
+	if !strings.HasPrefix(setting.StripeApiSecret, "sk_") && !strings.HasPrefix(setting.StripeApiSecret, "rk_") {
+		common.ApiErrorMsg(c, "无效的Stripe API密钥")
+		return
+	}
+	userId := c.GetInt("id")
+	user, err := model.GetUserById(userId, false)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	config, err := model.GetAutoRechargeConfig(userId)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+
+	stripe.Key = setting.StripeApiSecret
+	customerId := config.StripeCustomerId
+	if customerId == "" {
+		customerId = user.StripeCustomer
+	}
+	if customerId == "" {
+		customerParams := &stripe.CustomerParams{}
+		if user.Email != "" {
+			customerParams.Email = stripe.String(user.Email)
+		}
+		customerParams.AddMetadata("user_id", strconv.Itoa(userId))
+		created, err := customer.New(customerParams)
+		if err != nil {
+			logger.LogError(c.Request.Context(), fmt.Sprintf("Stripe 创建客户失败 user_id=%d error=%q", userId, err.Error()))
+			common.ApiErrorMsg(c, "绑定支付方式失败")
+			return
+		}
+		customerId = created.ID
+	}
+
+	params := &stripe.CheckoutSessionParams{
+		Mode:       stripe.String(string(stripe.CheckoutSessionModeSetup)),
+		Customer:   stripe.String(customerId),
+		Currency:   stripe.String(operation_setting.GetAutoRechargeSetting().Currency),
+		SuccessURL: stripe.String(paymentReturnPath("/wallet")),
+		CancelURL:  stripe.String(paymentReturnPath("/wallet")),
+		SetupIntentData: &stripe.CheckoutSessionSetupIntentDataParams{
+			Metadata: map[string]string{"user_id": strconv.Itoa(userId)},
+		},
+	}
+	params.AddMetadata("user_id", strconv.Itoa(userId))
+	params.AddMetadata("purpose", "auto_recharge")
+	result, err := session.New(params)
+	if err != nil {
+		logger.LogError(c.Request.Context(), fmt.Sprintf("Stripe 创建绑卡会话失败 user_id=%d error=%q", userId, err.Error()))
+		common.ApiErrorMsg(c, "绑定支付方式失败")
+		return
+	}
+	common.ApiSuccess(c, gin.H{
+		"pay_link": result.URL,
+	})
+}
+
+func DeleteAutoRechargePaymentMethod(c *gin.Context) {
+	userId := c.GetInt("id")
+	if err := model.ClearAutoRechargePaymentMethod(userId); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	service.InvalidateAutoRechargeGate(userId)
+	common.ApiSuccess(c, nil)
+}
+
+func GetAutoRechargeAttempts(c *gin.Context) {
+	pageInfo := common.GetPageQuery(c)
+	attempts, total, err := model.GetAutoRechargeAttempts(c.GetInt("id"), pageInfo.GetStartIdx(), pageInfo.GetPageSize())
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	pageInfo.SetTotal(int(total))
+	pageInfo.SetItems(attempts)
+	common.ApiSuccess(c, pageInfo)
+}
+
+// setupSessionCompleted 保存 setup 模式 Checkout 授权的支付方式
+func setupSessionCompleted(ctx context.Context, event stripe.Event, callerIp string) {
+	userId, _ := strconv.Atoi(event.GetObjectValue("metadata", "user_id"))
+	customerId := event.GetObjectValue("customer")
+	setupIntentId := event.GetObjectValue("setup_intent")
+	if userId <= 0 || customerId == "" || setupIntentId == "" {
+		logger.LogWarn(ctx, fmt.Sprintf("Stripe 绑卡回调缺少必要字段 user_id=%d customer=%s setup_intent=%s client_ip=%s", userId, customerId, setupIntentId, callerIp))
+		return
+	}
+
+	stripe.Key = setting.StripeApiSecret
+	params := &stripe.SetupIntentParams{}
+	params.AddExpand("payment_method")
+	intent, err := setupintent.Get(setupIntentId, params)
+	if err != nil {
+		logger.LogError(ctx, fmt.Sprintf("Stripe 查询 SetupIntent 失败 setup_intent=%s user_id=%d error=%q", setupIntentId, userId, err.Error()))
+		return
+	}
+	if intent.PaymentMethod == nil || intent.PaymentMethod.ID == "" {
+		logger.LogWarn(ctx, fmt.Sprintf("Stripe SetupIntent 未返回支付方式 setup_intent=%s user_id=%d", setupIntentId, userId))
+		return
+	}
+	brand, last4 := "", ""
+	if card := intent.PaymentMethod.Card; card != nil {
+		brand = string(card.Brand)
+		last4 = card.Last4
+	}
+	if err := model.SetAutoRechargePaymentMethod(userId, customerId, intent.PaymentMethod.ID, brand, last4); err != nil {
+		logger.LogError(ctx, fmt.Sprintf("保存自动充值支付方式失败 user_id=%d error=%q", userId, err.Error()))
+		return
+	}
+	service.InvalidateAutoRechargeGate(userId)
+	logger.LogInfo(ctx, fmt.Sprintf("Stripe 自动充值支付方式已绑定 user_id=%d card=%s ****%s client_ip=%s", userId, brand, last4, callerIp))
+}
+
+// autoRechargePaymentIntentEvent 处理离线扣款 PaymentIntent 的异步结果，非自动充值订单直接忽略
+func autoRechargePaymentIntentEvent(ctx context.Context, event stripe.Event, callerIp string) {
+	tradeNo := event.GetObjectValue("metadata", "trade_no")
+	if !strings.HasPrefix(tradeNo, service.AutoRechargeTradeNoPrefix) {
+		return
+	}
+	LockOrder(tradeNo)
+	defer UnlockOrder(tradeNo)
+
+	var err error
+	if event.Type == stripe.EventTypePaymentIntentSucceeded {
+		err = service.CompleteAutoRecharge(tradeNo, event.GetObjectValue("customer"))
+	} else {
+		reason := event.GetObjectValue("last_payment_error", "message")
+		if reason == "" {
+			reason = "扣款失败"
+		}
+		err = service.FailAutoRecharge(tradeNo, reason)
+	}
+	if err != nil {
+		logger.LogError(ctx, fmt.Sprintf("Stripe 自动充值回调处理失败 trade_no=%s event_type=%s client_ip=%s error=%q", tradeNo, string(event.Type), callerIp, err.Error()))
+		return
+	}
+	logger.LogInfo(ctx, fmt.Sprintf("Stripe 自动充值回调处理完成 trade_no=%s event_type=%s client_ip=%s", tradeNo, string(event.Type), callerIp))
+}
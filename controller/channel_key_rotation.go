@@ -0,0 +1,140 @@
+package controller
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type ChannelKeyRotateRequest struct {
+	KeyIndex      int     `json:"key_index"`
+	Key           string  `json:"key"`
+	GraceSeconds  *int64  `json:"grace_seconds,omitempty"`
+	ExpiresAt     *int64  `json:"expires_at,omitempty"`
+	RotationOwner *string `json:"rotation_owner,omitempty"`
+	Reason        string  `json:"reason"`
+}
+
+type ChannelKeyMetadataRequest struct {
+	KeyIndex      int     `json:"key_index"`
+	ExpiresAt     *int64  `json:"expires_at,omitempty"`
+	RotationOwner *string `json:"rotation_owner,omitempty"`
+}
+
+// RotateChannelKey atomically replaces a channel key (or one multi-key index).
+// The old key stays tolerated for the grace period so in-flight retries and
+// nodes that have not synced yet don't trip auto-disable.
+func RotateChannelKey(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	var req ChannelKeyRotateRequest
+	if err := common.DecodeJson(c.Request.Body, &req); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+
+	setting := operation_setting.GetChannelKeyRotationSetting()
+	graceSeconds := setting.DefaultGraceSeconds
+	if req.GraceSeconds != nil {
+		graceSeconds = *req.GraceSeconds
+	}
+	if graceSeconds < 0 || (setting.MaxGraceSeconds > 0 && graceSeconds > setting.MaxGraceSeconds) {
+		common.ApiErrorMsg(c, "宽限期超出允许范围")
+		return
+	}
+	if len(req.Reason) > 255 {
+		common.ApiErrorMsg(c, "轮换原因过长")
+		return
+	}
+
+	rotation, err := model.RotateChannelKey(model.ChannelKeyRotationParams{
+		ChannelId:     id,
+		KeyIndex:      req.KeyIndex,
+		NewKey:        req.Key,
+		GraceSeconds:  graceSeconds,
+		ExpiresAt:     req.ExpiresAt,
+		RotationOwner: req.RotationOwner,
+		Reason:        req.Reason,
+		OperatorId:    c.GetInt("id"),
+	})
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			common.ApiErrorMsg(c, "渠道不存在")
+			return
+		}
+		common.ApiError(c, err)
+		return
+	}
+	model.InitChannelCache()
+
+	recordManageAudit(c, "channel.key_rotate", map[string]interface{}{
+		"id":            id,
+		"key_index":     rotation.KeyIndex,
+		"rotation_id":   rotation.Id,
+		"grace_seconds": graceSeconds,
+		"owner":         rotation.Owner,
+	})
+	common.ApiSuccess(c, rotation)
+}
+
+// UpdateChannelKeyMetadata sets the expiry and rotation owner of a key.
+func UpdateChannelKeyMetadata(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	var req ChannelKeyMetadataRequest
+	if err := common.DecodeJson(c.Request.Body, &req); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if err := model.UpdateChannelKeyMetadata(id, req.KeyIndex, req.ExpiresAt, req.RotationOwner); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			common.ApiErrorMsg(c, "渠道不存在")
+			return
+		}
+		common.ApiError(c, err)
+		return
+	}
+	model.InitChannelCache()
+
+	params := map[string]interface{}{
+		"id":        id,
+		"key_index": req.KeyIndex,
+	}
+	if req.ExpiresAt != nil {
+		params["expires_at"] = *req.ExpiresAt
+	}
+	if req.RotationOwner != nil {
+		params["owner"] = *req.RotationOwner
+	}
+	recordManageAudit(c, "channel.key_metadata_update", params)
+	common.ApiSuccess(c, nil)
+}
+
+func GetChannelKeyRotations(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	pageInfo := common.GetPageQuery(c)
+	items, total, err := model.GetChannelKeyRotationsByChannelID(id, pageInfo.GetStartIdx(), pageInfo.GetPageSize())
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	pageInfo.SetTotal(int(total))
+	pageInfo.SetItems(items)
+	common.ApiSuccess(c, pageInfo)
+}
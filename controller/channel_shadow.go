@@ -0,0 +1,390 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/middleware"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/relay"
+	relaychannel "github.com/QuantumNous/new-api/relay/channel"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	relayconstant "github.com/QuantumNous/new-api/relay/constant"
+	"github.com/QuantumNous/new-api/relay/helper"
+	"github.com/QuantumNous/new-api/service"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/bytedance/gopkg/util/gopool"
+	"github.com/gin-gonic/gin"
+	"github.com/tidwall/gjson"
+)
+
+// shadowContextSkipKeys are request-scoped keys that must not leak from the
+// primary request into the mirrored one.
+var shadowContextSkipKeys = []string{
+	common.KeyBodyStorage,
+	common.KeyRequestBody,
+	"monitor_id",
+	"monitor_response_recorded",
+	"use_channel",
+	string(constant.ContextKeySettledUsage),
+}
+
+type shadowRequestSnapshot struct {
+	rule             operation_setting.ShadowTrafficRule
+	requestId        string
+	method           string
+	url              string
+	header           http.Header
+	params           gin.Params
+	keys             map[string]any
+	body             []byte
+	relayFormat      types.RelayFormat
+	userId           int
+	group            string
+	modelName        string
+	isStream         bool
+	primaryChannelId int
+	primaryLatencyMs int64
+	primaryUsage     *dto.Usage
+	primaryText      string
+}
+
+func isShadowTrafficSupported(relayFormat types.RelayFormat, relayMode int) bool {
+	switch relayFormat {
+	case types.RelayFormatClaude:
+		return true
+	case types.RelayFormatGemini:
+		return relayMode == relayconstant.RelayModeGemini
+	case types.RelayFormatOpenAI, types.RelayFormatOpenAIResponses, types.RelayFormatEmbedding:
+		switch relayMode {
+		case relayconstant.RelayModeChatCompletions,
+			relayconstant.RelayModeCompletions,
+			relayconstant.RelayModeResponses,
+			relayconstant.RelayModeEmbeddings:
+			return true
+		}
+	}
+	return false
+}
+
+// maybeMirrorShadowTraffic schedules a background copy of a successfully
+// relayed request against the shadow channel of a matching rule. It never
+// touches the primary response and never bills the user.
+func maybeMirrorShadowTraffic(c *gin.Context, relayInfo *relaycommon.RelayInfo, relayFormat types.RelayFormat, primaryChannel *model.Channel) {
+	if relayInfo == nil || primaryChannel == nil || relayInfo.IsChannelTest {
+		return
+	}
+	if !isShadowTrafficSupported(relayFormat, relayInfo.RelayMode) {
+		return
+	}
+	rule := service.MatchShadowTrafficRule(relayInfo.UsingGroup, relayInfo.OriginModelName, primaryChannel.Id)
+	if rule == nil {
+		return
+	}
+	storage, err := common.GetBodyStorage(c)
+	if err != nil {
+		return
+	}
+	body, err := storage.Bytes()
+	if err != nil || len(body) == 0 {
+		return
+	}
+	if !service.TryAcquireShadowTrafficSlot() {
+		logger.LogInfo(c, fmt.Sprintf("shadow traffic skipped: in-flight limit reached, rule=%s", rule.Name))
+		return
+	}
+
+	snapshot := &shadowRequestSnapshot{
+		rule:             *rule,
+		requestId:        c.GetString(common.RequestIdKey),
+		method:           c.Request.Method,
+		url:              c.Request.URL.String(),
+		header:           c.Request.Header.Clone(),
+		params:           append(gin.Params(nil), c.Params...),
+		keys:             c.Copy().Keys,
+		body:             append([]byte(nil), body...),
+		relayFormat:      relayFormat,
+		userId:           relayInfo.UserId,
+		group:            relayInfo.UsingGroup,
+		modelName:        relayInfo.OriginModelName,
+		isStream:         relayInfo.IsStream,
+		primaryChannelId: primaryChannel.Id,
+		primaryLatencyMs: time.Since(relayInfo.StartTime).Milliseconds(),
+	}
+	if usage, ok := common.GetContextKeyType[*dto.Usage](c, constant.ContextKeySettledUsage); ok && usage != nil {
+		snapshot.primaryUsage = usage
+	}
+	if rule.CompareText && relayInfo.MonitorResponseBody != nil {
+		snapshot.primaryText = extractShadowResponseText(relayInfo.MonitorResponseBody.String())
+	}
+	for _, key := range shadowContextSkipKeys {
+		delete(snapshot.keys, key)
+	}
+
+	gopool.Go(func() {
+		defer service.ReleaseShadowTrafficSlot()
+		runShadowRequest(snapshot)
+	})
+}
+
+func runShadowRequest(snapshot *shadowRequestSnapshot) {
+	ctx, cancel := context.WithTimeout(context.Background(), service.ShadowTrafficTimeout())
+	defer cancel()
+
+	comparison := &model.ShadowComparison{
+		ShadowChannelId:         snapshot.rule.ShadowChannelId,
+		PrimaryChannelId:        snapshot.primaryChannelId,
+		RuleName:                snapshot.rule.Name,
+		RequestId:               snapshot.requestId,
+		UserId:                  snapshot.userId,
+		UsingGroup:              snapshot.group,
+		ModelName:               snapshot.modelName,
+		IsStream:                snapshot.isStream,
+		PrimaryLatencyMs:        snapshot.primaryLatencyMs,
+		PrimaryPromptTokens:     0,
+		PrimaryCompletionTokens: 0,
+	}
+	if snapshot.primaryUsage != nil {
+		comparison.PrimaryPromptTokens = snapshot.primaryUsage.PromptTokens
+		comparison.PrimaryCompletionTokens = snapshot.primaryUsage.CompletionTokens
+	}
+
+	tik := time.Now()
+	result := executeShadowRequest(ctx, snapshot)
+	comparison.ShadowLatencyMs = time.Since(tik).Milliseconds()
+	comparison.ShadowStatusCode = result.statusCode
+	if result.err != nil {
+		comparison.ShadowError = common.LocalLogPreview(result.err.Error())
+	} else {
+		comparison.ShadowSuccess = true
+		comparison.ShadowQuota = result.quota
+		if result.usage != nil {
+			comparison.ShadowPromptTokens = result.usage.PromptTokens
+			comparison.ShadowCompletionTokens = result.usage.CompletionTokens
+		}
+		if snapshot.rule.CompareText && snapshot.primaryText != "" {
+			comparison.TextCompared = true
+			comparison.TextSimilarity = service.ShadowTextSimilarity(snapshot.primaryText, result.text)
+		}
+		if result.quota > 0 {
+			// Cost is tracked against the shadow channel only; the user is never charged.
+			model.UpdateChannelUsedQuota(snapshot.rule.ShadowChannelId, result.quota)
+		}
+	}
+	service.RecordShadowComparison(ctx, comparison)
+}
+
+type shadowResult struct {
+	statusCode int
+	usage      *dto.Usage
+	quota      int
+	text       string
+	err        error
+}
+
+func executeShadowRequest(ctx context.Context, snapshot *shadowRequestSnapshot) shadowResult {
+	channel, err := model.CacheGetChannel(snapshot.rule.ShadowChannelId)
+	if err != nil {
+		return shadowResult{err: err}
+	}
+	if channel.Status != common.ChannelStatusEnabled {
+		return shadowResult{err: fmt.Errorf("shadow channel #%d is not enabled", channel.Id)}
+	}
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequestWithContext(ctx, snapshot.method, snapshot.url, bytes.NewReader(snapshot.body))
+	c.Request.Header = snapshot.header
+	c.Params = snapshot.params
+	c.Keys = snapshot.keys
+	c.Set(common.RequestIdKey, snapshot.requestId+"-shadow")
+	common.SetContextKey(c, constant.ContextKeyRequestStartTime, time.Now())
+
+	if apiErr := middleware.SetupContextForSelectedChannel(c, channel, snapshot.modelName); apiErr != nil {
+		return shadowResult{err: apiErr}
+	}
+
+	request, err := helper.GetAndValidateRequest(c, snapshot.relayFormat)
+	if err != nil {
+		return shadowResult{err: err}
+	}
+	info, err := relaycommon.GenRelayInfo(c, snapshot.relayFormat, request, nil)
+	if err != nil {
+		return shadowResult{err: err}
+	}
+	info.InitChannelMeta(c)
+	if err := attachTestBillingRequestInput(info, request); err != nil {
+		return shadowResult{err: err}
+	}
+	if err := helper.ModelMappedHelper(c, info, request); err != nil {
+		return shadowResult{err: err}
+	}
+	request.SetModelName(info.UpstreamModelName)
+
+	apiType, _ := common.ChannelType2APIType(channel.Type)
+	adaptor := relay.GetAdaptor(apiType)
+	if adaptor == nil {
+		return shadowResult{err: fmt.Errorf("invalid api type: %d, adaptor is nil", apiType)}
+	}
+	meta := request.GetTokenCountMeta()
+	priceData, err := helper.ModelPriceHelper(c, info, info.GetEstimatePromptTokens(), meta)
+	if err != nil {
+		return shadowResult{err: err}
+	}
+	adaptor.Init(info)
+
+	convertedRequest, err := convertShadowRequestForAdaptor(c, info, adaptor, request)
+	if err != nil {
+		return shadowResult{err: err}
+	}
+	jsonData, err := common.Marshal(convertedRequest)
+	if err != nil {
+		return shadowResult{err: err}
+	}
+	if len(info.ParamOverride) > 0 {
+		jsonData, err = relaycommon.ApplyParamOverrideWithRelayInfo(jsonData, info)
+		if err != nil {
+			return shadowResult{err: err}
+		}
+	}
+
+	c.Request.Body = io.NopCloser(bytes.NewReader(jsonData))
+	resp, err := adaptor.DoRequest(c, info, bytes.NewReader(jsonData))
+	if err != nil {
+		return shadowResult{err: err}
+	}
+	var httpResp *http.Response
+	if resp != nil {
+		httpResp = resp.(*http.Response)
+		if httpResp.StatusCode != http.StatusOK {
+			relayErr := service.RelayErrorHandler(c.Request.Context(), httpResp, true)
+			return shadowResult{statusCode: httpResp.StatusCode, err: relayErr}
+		}
+	}
+	usageAny, respErr := adaptor.DoResponse(c, httpResp, info)
+	if respErr != nil {
+		return shadowResult{statusCode: respErr.StatusCode, err: respErr}
+	}
+	usage, err := coerceTestUsage(usageAny, info.IsStream, info.GetEstimatePromptTokens())
+	if err != nil {
+		return shadowResult{statusCode: http.StatusOK, err: err}
+	}
+	quota, _ := settleTestQuota(info, priceData, usage)
+
+	result := shadowResult{
+		statusCode: http.StatusOK,
+		usage:      usage,
+		quota:      quota,
+	}
+	if snapshot.rule.CompareText && info.MonitorResponseBody != nil {
+		result.text = extractShadowResponseText(info.MonitorResponseBody.String())
+	}
+	return result
+}
+
+func convertShadowRequestForAdaptor(c *gin.Context, info *relaycommon.RelayInfo, adaptor relaychannel.Adaptor, request dto.Request) (any, error) {
+	switch req := request.(type) {
+	case *dto.ClaudeRequest:
+		return adaptor.ConvertClaudeRequest(c, info, req)
+	case *dto.GeminiChatRequest:
+		return adaptor.ConvertGeminiRequest(c, info, req)
+	}
+	converted, err := convertRequestForAdaptor(c, info, adaptor, request)
+	if err != nil {
+		return nil, err
+	}
+	if converted == nil {
+		return nil, errors.New("shadow request conversion returned nil")
+	}
+	return converted, nil
+}
+
+// extractShadowResponseText pulls the generated text out of a captured
+// response. Streaming handlers already capture plain text; non-streaming
+// handlers capture the raw JSON body.
+func extractShadowResponseText(captured string) string {
+	captured = strings.TrimSpace(captured)
+	if captured == "" || !gjson.Valid(captured) {
+		return captured
+	}
+	paths := []string{
+		"choices.#.message.content",
+		"choices.#.text",
+		"content.#.text",
+		"candidates.#.content.parts.#.text",
+		"output.#.content.#.text",
+	}
+	var sb strings.Builder
+	for _, path := range paths {
+		gjson.Get(captured, path).ForEach(func(_, value gjson.Result) bool {
+			appendShadowText(&sb, value)
+			return true
+		})
+		if sb.Len() > 0 {
+			return sb.String()
+		}
+	}
+	return captured
+}
+
+func appendShadowText(sb *strings.Builder, value gjson.Result) {
+	if value.IsArray() {
+		value.ForEach(func(_, item gjson.Result) bool {
+			appendShadowText(sb, item)
+			return true
+		})
+		return
+	}
+	if text := value.String(); text != "" {
+		if sb.Len() > 0 {
+			sb.WriteByte('\n')
+		}
+		sb.WriteString(text)
+	}
+}
+
+func GetChannelShadowComparisons(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	pageInfo := common.GetPageQuery(c)
+	items, total, err := model.GetShadowComparisonPageByChannelID(id, pageInfo.GetPage(), pageInfo.GetPageSize())
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	pageInfo.SetTotal(int(total))
+	pageInfo.SetItems(items)
+	common.ApiSuccess(c, pageInfo)
+}
+
+func GetChannelShadowSummary(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	since, _ := strconv.ParseInt(c.Query("since"), 10, 64)
+	summary, err := model.GetShadowComparisonSummary(id, since)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, summary)
+}
@@ -0,0 +1,178 @@
+package controller
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/model"
+
+	"github.com/gin-gonic/gin"
+)
+
+type CouponCheckRequest struct {
+	Code          string `json:"code"`
+	OrderType     string `json:"order_type"`
+	PlanId        int    `json:"plan_id"`
+	PaymentMethod string `json:"payment_method"`
+}
+
+// resolveCheckoutCoupon 校验下单时提交的优惠码，code 为空时返回 nil。
+// allowDiscount 为 false 表示该支付渠道价格固定（如 Creem 产品），只能使用赠送类优惠码。
+func resolveCheckoutCoupon(code string, usage model.CouponUsage, allowDiscount bool) (*model.Coupon, error) {
+	if model.NormalizeCouponCode(code) == "" {
+		return nil, nil
+	}
+	usage.Code = code
+	coupon, err := model.CheckCoupon(usage)
+	if err != nil {
+		return nil, err
+	}
+	if coupon.Type == model.CouponTypePercentOff && !allowDiscount {
+		return nil, model.ErrCouponDiscountNotAllowed
+	}
+	return coupon, nil
+}
+
+// CheckCoupon 供用户在下单前预览优惠码是否可用
+func CheckCoupon(c *gin.Context) {
+	var req CouponCheckRequest
+	if err := common.DecodeJson(c.Request.Body, &req); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if req.OrderType == "" {
+		req.OrderType = model.CouponScopeTopUp
+	}
+	coupon, err := resolveCheckoutCoupon(req.Code, model.CouponUsage{
+		UserId:        c.GetInt("id"),
+		OrderType:     req.OrderType,
+		PlanId:        req.PlanId,
+		PaymentMethod: req.PaymentMethod,
+	}, req.PaymentMethod != model.PaymentMethodCreem)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if coupon == nil {
+		common.ApiErrorMsg(c, "请输入优惠码")
+		return
+	}
+	common.ApiSuccess(c, gin.H{
+		"code":     coupon.Code,
+		"name":     coupon.Name,
+		"type":     coupon.Type,
+		"percent":  coupon.Percent,
+		"end_time": coupon.EndTime,
+	})
+}
+
+func GetAllCoupons(c *gin.Context) {
+	pageInfo := common.GetPageQuery(c)
+	coupons, total, err := model.GetAllCoupons(c.Query("keyword"), pageInfo.GetStartIdx(), pageInfo.GetPageSize())
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	pageInfo.SetTotal(int(total))
+	pageInfo.SetItems(coupons)
+	common.ApiSuccess(c, pageInfo)
+}
+
+func GetCoupon(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	coupon, err := model.GetCouponById(id)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	stats, err := model.GetCouponStats(id)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, gin.H{
+		"coupon": coupon,
+		"stats":  stats,
+	})
+}
+
+func AddCoupon(c *gin.Context) {
+	var coupon model.Coupon
+	if err := common.DecodeJson(c.Request.Body, &coupon); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if err := coupon.Insert(); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	recordManageAudit(c, "coupon.create", map[string]interface{}{
+		"code":    coupon.Code,
+		"type":    coupon.Type,
+		"percent": coupon.Percent,
+	})
+	common.ApiSuccess(c, &coupon)
+}
+
+func UpdateCoupon(c *gin.Context) {
+	var coupon model.Coupon
+	if err := common.DecodeJson(c.Request.Body, &coupon); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if _, err := model.GetCouponById(coupon.Id); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if err := coupon.Update(); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	recordManageAudit(c, "coupon.update", map[string]interface{}{
+		"id":   coupon.Id,
+		"code": coupon.Code,
+	})
+	common.ApiSuccess(c, &coupon)
+}
+
+func DeleteCoupon(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if err := model.DeleteCouponById(id); err != nil {
+		if errors.Is(err, model.ErrCouponNotFound) {
+			common.ApiErrorMsg(c, "优惠码不存在")
+			return
+		}
+		common.ApiError(c, err)
+		return
+	}
+	recordManageAudit(c, "coupon.delete", map[string]interface{}{
+		"id": id,
+	})
+	common.ApiSuccess(c, nil)
+}
+
+func GetCouponRedemptions(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	pageInfo := common.GetPageQuery(c)
+	redemptions, total, err := model.GetCouponRedemptions(id, pageInfo.GetStartIdx(), pageInfo.GetPageSize())
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	pageInfo.SetTotal(int(total))
+	pageInfo.SetItems(redemptions)
+	common.ApiSuccess(c, pageInfo)
+}
@@ -0,0 +1,18 @@
+package controller
+
+import (
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/model"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetSelfQuotaLots 返回当前用户余额按到期时间的拆分
+func GetSelfQuotaLots(c *gin.Context) {
+	breakdown, err := model.GetUserQuotaBalanceBreakdown(c.GetInt("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, breakdown)
+}
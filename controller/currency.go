@@ -0,0 +1,21 @@
+package controller
+
+import (
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RefreshCurrencyRates 立即从汇率接口刷新非手动设置的汇率
+func RefreshCurrencyRates(c *gin.Context) {
+	result, err := service.RefreshCurrencyRates(c.Request.Context())
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	recordManageAudit(c, "currency.refresh_rates", map[string]interface{}{
+		"updated": result.Updated,
+	})
+	common.ApiSuccess(c, result)
+}
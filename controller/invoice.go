@@ -0,0 +1,218 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/service"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type BillingProfileRequest struct {
+	LegalName string `json:"legal_name"`
+	Address   string `json:"address"`
+	TaxId     string `json:"tax_id"`
+	Email     string `json:"email"`
+}
+
+type InvoiceIssueRequest struct {
+	SourceType string `json:"source_type"`
+	TradeNo    string `json:"trade_no"`
+}
+
+func updateBillingProfile(c *gin.Context, ownerType string, ownerId int) {
+	var req BillingProfileRequest
+	if err := common.DecodeJson(c.Request.Body, &req); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	profile := &model.BillingProfile{
+		OwnerType: ownerType,
+		OwnerId:   ownerId,
+		LegalName: req.LegalName,
+		Address:   req.Address,
+		TaxId:     req.TaxId,
+		Email:     req.Email,
+	}
+	if err := model.UpsertBillingProfile(profile); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, profile)
+}
+
+func GetSelfBillingProfile(c *gin.Context) {
+	profile, err := model.GetBillingProfile(model.BillingProfileOwnerUser, c.GetInt("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, profile)
+}
+
+func UpdateSelfBillingProfile(c *gin.Context) {
+	updateBillingProfile(c, model.BillingProfileOwnerUser, c.GetInt("id"))
+}
+
+func GetOrganizationBillingProfile(c *gin.Context) {
+	orgId, _, ok := requireOrganizationMember(c, true)
+	if !ok {
+		return
+	}
+	profile, err := model.GetBillingProfile(model.BillingProfileOwnerOrganization, orgId)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, profile)
+}
+
+func UpdateOrganizationBillingProfile(c *gin.Context) {
+	orgId, _, ok := requireOrganizationMember(c, true)
+	if !ok {
+		return
+	}
+	updateBillingProfile(c, model.BillingProfileOwnerOrganization, orgId)
+}
+
+func GetSelfInvoices(c *gin.Context) {
+	listInvoices(c, c.GetInt("id"), 0)
+}
+
+func GetOrganizationInvoices(c *gin.Context) {
+	orgId, _, ok := requireOrganizationMember(c, true)
+	if !ok {
+		return
+	}
+	listInvoices(c, 0, orgId)
+}
+
+func AdminListInvoices(c *gin.Context) {
+	userId, _ := strconv.Atoi(c.Query("user_id"))
+	organizationId, _ := strconv.Atoi(c.Query("organization_id"))
+	listInvoices(c, userId, organizationId)
+}
+
+func listInvoices(c *gin.Context, userId int, organizationId int) {
+	pageInfo := common.GetPageQuery(c)
+	invoices, total, err := model.GetInvoices(userId, organizationId, pageInfo.GetStartIdx(), pageInfo.GetPageSize())
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	pageInfo.SetTotal(int(total))
+	pageInfo.SetItems(invoices)
+	common.ApiSuccess(c, pageInfo)
+}
+
+// DownloadSelfInvoice 下载自己的发票；组织发票需要组织所有者或管理员权限。
+func DownloadSelfInvoice(c *gin.Context) {
+	invoice, ok := loadInvoice(c)
+	if !ok {
+		return
+	}
+	userId := c.GetInt("id")
+	allowed := invoice.OrganizationId == 0 && invoice.UserId == userId
+	if invoice.OrganizationId > 0 {
+		member, err := model.GetOrganizationMember(invoice.OrganizationId, userId)
+		allowed = err == nil && member.CanManage()
+	}
+	if !allowed {
+		common.ApiErrorMsg(c, "发票不存在")
+		return
+	}
+	writeInvoice(c, invoice)
+}
+
+func AdminDownloadInvoice(c *gin.Context) {
+	invoice, ok := loadInvoice(c)
+	if !ok {
+		return
+	}
+	writeInvoice(c, invoice)
+}
+
+// AdminIssueInvoice 为已完成的充值或订阅订单补开发票，已开过的直接返回原发票。
+func AdminIssueInvoice(c *gin.Context) {
+	var req InvoiceIssueRequest
+	if err := common.DecodeJson(c.Request.Body, &req); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	var invoice *model.Invoice
+	var err error
+	switch req.SourceType {
+	case model.InvoiceSourceTopUp:
+		invoice, err = service.IssueTopUpInvoice(req.TradeNo)
+	case model.InvoiceSourceSubscriptionOrder:
+		invoice, err = service.IssueSubscriptionOrderInvoice(req.TradeNo)
+	default:
+		common.ApiErrorMsg(c, "不支持的发票来源")
+		return
+	}
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if invoice == nil {
+		common.ApiErrorMsg(c, "发票功能未启用或该订单无需开票")
+		return
+	}
+	recordManageAuditFor(c, invoice.UserId, "invoice.issue", map[string]interface{}{
+		"invoice_no": invoice.InvoiceNo,
+		"source":     req.TradeNo,
+	})
+	common.ApiSuccess(c, invoice)
+}
+
+func AdminResendInvoice(c *gin.Context) {
+	invoice, ok := loadInvoice(c)
+	if !ok {
+		return
+	}
+	if err := service.SendInvoiceEmail(invoice); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, nil)
+}
+
+func loadInvoice(c *gin.Context) (*model.Invoice, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return nil, false
+	}
+	invoice, err := model.GetInvoiceById(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			common.ApiErrorMsg(c, "发票不存在")
+			return nil, false
+		}
+		common.ApiError(c, err)
+		return nil, false
+	}
+	return invoice, true
+}
+
+// writeInvoice 按 format 参数返回 PDF（默认）或 HTML 版本的发票。
+func writeInvoice(c *gin.Context, invoice *model.Invoice) {
+	if c.Query("format") == "html" {
+		content, err := service.RenderInvoiceHTML(invoice)
+		if err != nil {
+			common.ApiError(c, err)
+			return
+		}
+		c.Header("Content-Disposition", fmt.Sprintf("inline; filename=%q", invoice.InvoiceNo+".html"))
+		c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(content))
+		return
+	}
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", invoice.InvoiceNo+".pdf"))
+	c.Data(http.StatusOK, "application/pdf", service.RenderInvoicePDF(invoice))
+}
@@ -3,6 +3,7 @@ package controller
 import (
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/QuantumNous/new-api/common"
 	"github.com/QuantumNous/new-api/model"
@@ -122,6 +123,32 @@ func GetLogsStat(c *gin.Context) {
 	return
 }
 
+// GetLogProfitReport 返回按渠道/模型/分组与时间桶汇总的收入、上游成本与利润。
+func GetLogProfitReport(c *gin.Context) {
+	startTimestamp, _ := strconv.ParseInt(c.Query("start_timestamp"), 10, 64)
+	endTimestamp, _ := strconv.ParseInt(c.Query("end_timestamp"), 10, 64)
+	bucketSeconds, _ := strconv.ParseInt(c.Query("bucket_seconds"), 10, 64)
+	channel, _ := strconv.Atoi(c.Query("channel"))
+	var dimensions []string
+	if raw := c.Query("dimensions"); raw != "" {
+		dimensions = strings.Split(raw, ",")
+	}
+	rows, err := model.GetProfitReport(model.ProfitReportParams{
+		StartTimestamp: startTimestamp,
+		EndTimestamp:   endTimestamp,
+		Dimensions:     dimensions,
+		BucketSeconds:  bucketSeconds,
+		ChannelId:      channel,
+		ModelName:      c.Query("model_name"),
+		Group:          c.Query("group"),
+	})
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, rows)
+}
+
 func GetLogsSelfStat(c *gin.Context) {
 	username := c.GetString("username")
 	logType, _ := strconv.Atoi(c.Query("type"))
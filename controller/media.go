@@ -0,0 +1,37 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/pkg/blobstore"
+	"github.com/QuantumNous/new-api/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetMediaObject 通过网关签名地址下载归档的生成结果，无需登录
+func GetMediaObject(c *gin.Context) {
+	id, _ := strconv.ParseInt(c.Param("id"), 10, 64)
+	expires, _ := strconv.ParseInt(c.Query("expires"), 10, 64)
+	if id <= 0 || !service.VerifyMediaSignature(id, expires, c.Query("signature")) {
+		c.JSON(http.StatusForbidden, gin.H{"success": false, "message": "签名无效或已过期"})
+		return
+	}
+	obj, err := model.GetMediaObjectById(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "message": "媒体不存在或已过期"})
+		return
+	}
+	if err := service.ServeMediaObject(c, obj); err != nil {
+		common.SysLog("failed to open media object " + strconv.FormatInt(id, 10) + ": " + err.Error())
+		status := http.StatusInternalServerError
+		if errors.Is(err, blobstore.ErrNotFound) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{"success": false, "message": "读取媒体失败"})
+	}
+}
@@ -212,8 +212,11 @@ func runMidjourneyTaskUpdateOnce(ctx context.Context, report func(processed, tot
 			won, err := task.UpdateWithStatus(preStatus)
 			if err != nil {
 				logger.LogError(ctx, "UpdateMidjourneyTask task error: "+err.Error())
+			} else if won && task.Status == "SUCCESS" && preStatus != "SUCCESS" {
+				service.ArchiveMidjourneyMediaAsync(task.MjId, task.UserId, task.ChannelId, task.ImageUrl)
 			} else if won && shouldReturnQuota {
-				err = model.IncreaseUserQuota(task.UserId, task.Quota, false)
+				// 与异步任务退款一致：退还余额的同时放回额度批次并记账
+				err = model.SettleUserWalletQuota(task.UserId, -task.Quota, -task.Quota, model.QuotaLedgerRefTaskId, task.MjId)
 				if err != nil {
 					logger.LogError(ctx, "fail to increase user quota: "+err.Error())
 				}
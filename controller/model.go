@@ -294,6 +294,22 @@ func ListModels(c *gin.Context, modelType int) {
 			"models":        userGeminiModels,
 			"nextPageToken": nil,
 		})
+	case constant.ChannelTypeOllama:
+		// 网关模型没有本地文件信息，size、digest 与 details 仅填充占位值供客户端解析
+		ollamaModels := make([]dto.OllamaModel, len(userOpenAiModels))
+		for i, model := range userOpenAiModels {
+			ollamaModels[i] = dto.OllamaModel{
+				Name:       model.Id,
+				Model:      model.Id,
+				ModifiedAt: time.Unix(int64(model.Created), 0).UTC().Format(time.RFC3339),
+				Details: dto.OllamaModelDetails{
+					Format:   "api",
+					Family:   model.OwnedBy,
+					Families: []string{},
+				},
+			}
+		}
+		c.JSON(200, dto.OllamaTagsResponse{Models: ollamaModels})
 	default:
 		c.JSON(200, gin.H{
 			"success": true,
@@ -303,6 +319,13 @@ func ListModels(c *gin.Context, modelType int) {
 	}
 }
 
+// RelayOllamaVersion 响应 Ollama 客户端的连通性检查
+func RelayOllamaVersion(c *gin.Context) {
+	c.JSON(200, gin.H{
+		"version": common.Version,
+	})
+}
+
 func ChannelListModels(c *gin.Context) {
 	c.JSON(200, gin.H{
 		"success": true,
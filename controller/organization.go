@@ -0,0 +1,399 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/model"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type OrganizationCreateRequest struct {
+	Name string `json:"name"`
+}
+
+type OrganizationMemberRequest struct {
+	UserId     int    `json:"user_id"`
+	Role       string `json:"role"`
+	SpendLimit *int   `json:"spend_limit"` // 更新成员时为空表示不修改
+	ResetSpend bool   `json:"reset_spend"`
+}
+
+type OrganizationFundRequest struct {
+	Quota int `json:"quota"`
+}
+
+type OrganizationQuotaAdjustRequest struct {
+	Delta int `json:"delta"`
+}
+
+type OrganizationStatusRequest struct {
+	Status int `json:"status"`
+}
+
+type organizationWithRole struct {
+	*model.Organization
+	Role       string `json:"role"`
+	SpendLimit int    `json:"spend_limit"`
+	SpendUsed  int    `json:"spend_used"`
+}
+
+// requireOrganizationMember 解析路径中的组织 id 并校验当前用户的成员资格；
+// manage 为 true 时要求 owner/admin 角色。
+func requireOrganizationMember(c *gin.Context, manage bool) (int, *model.OrganizationMember, bool) {
+	orgId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return 0, nil, false
+	}
+	member, err := model.GetOrganizationMember(orgId, c.GetInt("id"))
+	if err != nil {
+		if errors.Is(err, model.ErrOrganizationNotMember) {
+			common.ApiErrorMsg(c, "无权访问该组织")
+			return 0, nil, false
+		}
+		common.ApiError(c, err)
+		return 0, nil, false
+	}
+	if manage && !member.CanManage() {
+		common.ApiErrorMsg(c, "需要组织所有者或管理员权限")
+		return 0, nil, false
+	}
+	return orgId, member, true
+}
+
+func GetSelfOrganizations(c *gin.Context) {
+	orgs, members, err := model.GetUserOrganizations(c.GetInt("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	memberByOrg := make(map[int]*model.OrganizationMember, len(members))
+	for _, m := range members {
+		memberByOrg[m.OrganizationId] = m
+	}
+	items := make([]organizationWithRole, 0, len(orgs))
+	for _, org := range orgs {
+		item := organizationWithRole{Organization: org}
+		if m := memberByOrg[org.Id]; m != nil {
+			item.Role = m.Role
+			item.SpendLimit = m.SpendLimit
+			item.SpendUsed = m.SpendUsed
+		}
+		items = append(items, item)
+	}
+	common.ApiSuccess(c, items)
+}
+
+func CreateOrganization(c *gin.Context) {
+	var req OrganizationCreateRequest
+	if err := common.DecodeJson(c.Request.Body, &req); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	org, err := model.CreateOrganization(req.Name, c.GetInt("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, org)
+}
+
+func GetOrganization(c *gin.Context) {
+	orgId, member, ok := requireOrganizationMember(c, false)
+	if !ok {
+		return
+	}
+	org, err := model.GetOrganizationById(orgId)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, organizationWithRole{
+		Organization: org,
+		Role:         member.Role,
+		SpendLimit:   member.SpendLimit,
+		SpendUsed:    member.SpendUsed,
+	})
+}
+
+func GetOrganizationMembers(c *gin.Context) {
+	orgId, _, ok := requireOrganizationMember(c, true)
+	if !ok {
+		return
+	}
+	members, err := model.GetOrganizationMembers(orgId)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, members)
+}
+
+func AddOrganizationMember(c *gin.Context) {
+	orgId, operator, ok := requireOrganizationMember(c, true)
+	if !ok {
+		return
+	}
+	var req OrganizationMemberRequest
+	if err := common.DecodeJson(c.Request.Body, &req); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if req.Role == "" {
+		req.Role = model.OrganizationRoleMember
+	}
+	if req.Role == model.OrganizationRoleOwner && operator.Role != model.OrganizationRoleOwner {
+		common.ApiErrorMsg(c, "只有组织所有者可以添加所有者")
+		return
+	}
+	spendLimit := 0
+	if req.SpendLimit != nil {
+		spendLimit = *req.SpendLimit
+	}
+	member, err := model.AddOrganizationMember(orgId, req.UserId, req.Role, spendLimit)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			common.ApiErrorMsg(c, "用户不存在")
+			return
+		}
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, member)
+}
+
+func UpdateOrganizationMember(c *gin.Context) {
+	orgId, operator, ok := requireOrganizationMember(c, true)
+	if !ok {
+		return
+	}
+	userId, err := strconv.Atoi(c.Param("user_id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	var req OrganizationMemberRequest
+	if err := common.DecodeJson(c.Request.Body, &req); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if operator.Role != model.OrganizationRoleOwner {
+		// 管理员不能调整所有者，也不能授予所有者角色
+		target, err := model.GetOrganizationMember(orgId, userId)
+		if err != nil {
+			common.ApiError(c, err)
+			return
+		}
+		if target.Role == model.OrganizationRoleOwner || req.Role == model.OrganizationRoleOwner {
+			common.ApiErrorMsg(c, "只有组织所有者可以调整所有者")
+			return
+		}
+	}
+	if err := model.UpdateOrganizationMember(orgId, userId, req.Role, req.SpendLimit, req.ResetSpend); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, nil)
+}
+
+func RemoveOrganizationMember(c *gin.Context) {
+	orgId, operator, ok := requireOrganizationMember(c, false)
+	if !ok {
+		return
+	}
+	userId, err := strconv.Atoi(c.Param("user_id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	// 成员可以退出组织；移除他人需要管理权限，移除所有者需要所有者权限
+	if userId != operator.UserId {
+		if !operator.CanManage() {
+			common.ApiErrorMsg(c, "需要组织所有者或管理员权限")
+			return
+		}
+		if operator.Role != model.OrganizationRoleOwner {
+			target, err := model.GetOrganizationMember(orgId, userId)
+			if err != nil {
+				common.ApiError(c, err)
+				return
+			}
+			if target.Role == model.OrganizationRoleOwner {
+				common.ApiErrorMsg(c, "只有组织所有者可以移除所有者")
+				return
+			}
+		}
+	}
+	if err := model.RemoveOrganizationMember(orgId, userId); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, nil)
+}
+
+// FundOrganization 把当前用户个人钱包的额度转入组织钱包。
+func FundOrganization(c *gin.Context) {
+	orgId, _, ok := requireOrganizationMember(c, false)
+	if !ok {
+		return
+	}
+	var req OrganizationFundRequest
+	if err := common.DecodeJson(c.Request.Body, &req); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if err := model.FundOrganizationFromUser(orgId, c.GetInt("id"), req.Quota); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	model.RecordLog(c.GetInt("id"), model.LogTypeManage, fmt.Sprintf("向组织 #%d 钱包转入 %s", orgId, logger.LogQuota(req.Quota)))
+	common.ApiSuccess(c, nil)
+}
+
+func AdminListOrganizations(c *gin.Context) {
+	pageInfo := common.GetPageQuery(c)
+	orgs, total, err := model.GetAllOrganizations(pageInfo.GetStartIdx(), pageInfo.GetPageSize())
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	pageInfo.SetTotal(int(total))
+	pageInfo.SetItems(orgs)
+	common.ApiSuccess(c, pageInfo)
+}
+
+func AdminGetOrganizationMembers(c *gin.Context) {
+	orgId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	members, err := model.GetOrganizationMembers(orgId)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, members)
+}
+
+func AdminAdjustOrganizationQuota(c *gin.Context) {
+	orgId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	var req OrganizationQuotaAdjustRequest
+	if err := common.DecodeJson(c.Request.Body, &req); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if req.Delta == 0 {
+		common.ApiErrorMsg(c, "调整额度不能为 0")
+		return
+	}
+	if err := model.AdjustOrganizationQuota(orgId, req.Delta, c.GetInt("id")); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			common.ApiErrorMsg(c, "组织不存在")
+			return
+		}
+		common.ApiError(c, err)
+		return
+	}
+	recordManageAudit(c, "organization.quota_adjust", map[string]interface{}{
+		"id":    orgId,
+		"delta": req.Delta,
+	})
+	common.ApiSuccess(c, nil)
+}
+
+func AdminUpdateOrganizationStatus(c *gin.Context) {
+	orgId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	var req OrganizationStatusRequest
+	if err := common.DecodeJson(c.Request.Body, &req); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if err := model.UpdateOrganizationStatus(orgId, req.Status); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			common.ApiErrorMsg(c, "组织不存在")
+			return
+		}
+		common.ApiError(c, err)
+		return
+	}
+	recordManageAudit(c, "organization.status_update", map[string]interface{}{
+		"id":     orgId,
+		"status": req.Status,
+	})
+	common.ApiSuccess(c, nil)
+}
+
+// GetOrganizationSubscriptions 返回组织的订阅列表，成员均可查看。
+func GetOrganizationSubscriptions(c *gin.Context) {
+	orgId, _, ok := requireOrganizationMember(c, false)
+	if !ok {
+		return
+	}
+	subs, err := model.GetOrganizationSubscriptions(orgId)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, subs)
+}
+
+// PurchaseOrganizationSubscription 使用组织钱包为组织购买订阅，需要管理权限。
+func PurchaseOrganizationSubscription(c *gin.Context) {
+	if !requirePaymentCompliance(c) {
+		return
+	}
+	orgId, _, ok := requireOrganizationMember(c, true)
+	if !ok {
+		return
+	}
+	var req SubscriptionBalancePayRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.PlanId <= 0 {
+		common.ApiErrorMsg(c, "参数错误")
+		return
+	}
+	if err := model.PurchaseOrganizationSubscriptionWithBalance(orgId, c.GetInt("id"), req.PlanId); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, nil)
+}
+
+func AdminBindOrganizationSubscription(c *gin.Context) {
+	if !requirePaymentCompliance(c) {
+		return
+	}
+	orgId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	var req SubscriptionBalancePayRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.PlanId <= 0 {
+		common.ApiErrorMsg(c, "参数错误")
+		return
+	}
+	if err := model.AdminBindOrganizationSubscription(orgId, req.PlanId); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	recordManageAudit(c, "organization.subscription_bind", map[string]interface{}{
+		"id":      orgId,
+		"plan_id": req.PlanId,
+	})
+	common.ApiSuccess(c, nil)
+}
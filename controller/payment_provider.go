@@ -0,0 +1,40 @@
+package controller
+
+import (
+	"github.com/gin-gonic/gin"
+)
+
+// PaymentProvider 是支付渠道适配器的通用接口。新渠道实现该接口并通过 registerPaymentProvider 注册后，
+// 充值页的支付方式列表和启用状态由 GetTopUpInfo 统一输出；Stripe、Creem、Waffo 等现有渠道仍是独立实现，可逐步迁移
+type PaymentProvider interface {
+	// Name 为渠道标识，与 TopUp.PaymentProvider / PaymentMethod 保持一致
+	Name() string
+	// TopUpEnabled 表示渠道配置完整、可以下单
+	TopUpEnabled() bool
+	// WebhookConfigured 表示验签所需的配置已填写
+	WebhookConfigured() bool
+	// WebhookEnabled 表示 webhook 可以接收并处理事件
+	WebhookEnabled() bool
+	// TopUpMethod 返回展示在充值页的支付方式（name/type/color/min_topup）
+	TopUpMethod() map[string]string
+
+	RequestAmount(c *gin.Context)
+	RequestPay(c *gin.Context)
+	RequestSubscriptionPay(c *gin.Context)
+	Webhook(c *gin.Context)
+}
+
+var paymentProviders []PaymentProvider
+
+func registerPaymentProvider(provider PaymentProvider) {
+	paymentProviders = append(paymentProviders, provider)
+}
+
+func getPaymentProvider(name string) PaymentProvider {
+	for _, provider := range paymentProviders {
+		if provider.Name() == name {
+			return provider
+		}
+	}
+	return nil
+}
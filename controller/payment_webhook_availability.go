@@ -108,3 +108,21 @@ func isEpayWebhookConfigured() bool {
 func isEpayWebhookEnabled() bool {
 	return isEpayTopUpEnabled()
 }
+
+func isPayPalTopUpEnabled() bool {
+	if !isPaymentComplianceConfirmed() {
+		return false
+	}
+	// 支付完成和退款同步都依赖 webhook，未配置 Webhook ID 时不开放下单
+	return isPayPalWebhookConfigured()
+}
+
+func isPayPalWebhookConfigured() bool {
+	return strings.TrimSpace(setting.PayPalClientId) != "" &&
+		strings.TrimSpace(setting.PayPalClientSecret) != "" &&
+		strings.TrimSpace(setting.PayPalWebhookId) != ""
+}
+
+func isPayPalWebhookEnabled() bool {
+	return isPayPalTopUpEnabled()
+}
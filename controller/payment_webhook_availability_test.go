@@ -3,6 +3,7 @@ package controller
 import (
 	"testing"
 
+	"github.com/QuantumNous/new-api/model"
 	"github.com/QuantumNous/new-api/setting"
 	"github.com/QuantumNous/new-api/setting/operation_setting"
 	"github.com/stretchr/testify/require"
@@ -167,3 +168,27 @@ func TestEpayWebhookEnabledRequiresTopUpAndWebhookConfig(t *testing.T) {
 	operation_setting.PayMethods = nil
 	require.False(t, isEpayWebhookEnabled())
 }
+
+func TestPayPalWebhookEnabledRequiresTopUpAndWebhookConfig(t *testing.T) {
+	confirmPaymentComplianceForTest(t)
+	originalClientID := setting.PayPalClientId
+	originalClientSecret := setting.PayPalClientSecret
+	originalWebhookID := setting.PayPalWebhookId
+	t.Cleanup(func() {
+		setting.PayPalClientId = originalClientID
+		setting.PayPalClientSecret = originalClientSecret
+		setting.PayPalWebhookId = originalWebhookID
+	})
+
+	setting.PayPalClientId = "client_id"
+	setting.PayPalClientSecret = "client_secret"
+	setting.PayPalWebhookId = ""
+	require.False(t, isPayPalWebhookEnabled())
+
+	setting.PayPalWebhookId = "webhook_id"
+	require.True(t, isPayPalWebhookEnabled())
+	require.NotNil(t, getPaymentProvider(model.PaymentProviderPayPal))
+
+	setting.PayPalClientSecret = ""
+	require.False(t, isPayPalWebhookEnabled())
+}
@@ -0,0 +1,201 @@
+package controller
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type PostpaidPaymentRequest struct {
+	Amount    float64 `json:"amount"`
+	Method    string  `json:"method"`
+	Reference string  `json:"reference"`
+	Remark    string  `json:"remark"`
+}
+
+type PostpaidRunRequest struct {
+	PeriodStart int64 `json:"period_start"`
+}
+
+func GetPostpaidAccounts(c *gin.Context) {
+	pageInfo := common.GetPageQuery(c)
+	accounts, total, err := model.GetPostpaidAccounts(c.Query("status"), pageInfo.GetStartIdx(), pageInfo.GetPageSize())
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	pageInfo.SetTotal(int(total))
+	pageInfo.SetItems(accounts)
+	common.ApiSuccess(c, pageInfo)
+}
+
+// UpsertPostpaidAccount 为用户或组织开通或调整信用额度
+func UpsertPostpaidAccount(c *gin.Context) {
+	var account model.PostpaidAccount
+	if err := common.DecodeJson(c.Request.Body, &account); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if account.OwnerType == model.BillingProfileOwnerOrganization {
+		if _, err := model.GetOrganizationById(account.OwnerId); err != nil {
+			common.ApiErrorMsg(c, "组织不存在")
+			return
+		}
+	} else if _, err := model.GetUserById(account.OwnerId, false); err != nil {
+		common.ApiErrorMsg(c, "用户不存在")
+		return
+	}
+	if err := model.UpsertPostpaidAccount(&account); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	recordManageAudit(c, "postpaid.account_update", map[string]interface{}{
+		"owner_type":   account.OwnerType,
+		"owner_id":     account.OwnerId,
+		"credit_limit": account.CreditLimit,
+	})
+	common.ApiSuccess(c, &account)
+}
+
+func GetPostpaidStatements(c *gin.Context) {
+	pageInfo := common.GetPageQuery(c)
+	accountId, _ := strconv.Atoi(c.Query("account_id"))
+	statements, total, err := model.GetPostpaidStatements(accountId, "", 0, c.Query("status"), pageInfo.GetStartIdx(), pageInfo.GetPageSize())
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	pageInfo.SetTotal(int(total))
+	pageInfo.SetItems(statements)
+	common.ApiSuccess(c, pageInfo)
+}
+
+func GetPostpaidStatement(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	statement, err := model.GetPostpaidStatementById(id)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	respondPostpaidStatement(c, statement)
+}
+
+func respondPostpaidStatement(c *gin.Context, statement *model.PostpaidStatement) {
+	items, err := model.GetPostpaidStatementItems(statement.Id)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	payments, err := model.GetPostpaidPayments(statement.Id)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, gin.H{
+		"statement": statement,
+		"items":     items,
+		"payments":  payments,
+	})
+}
+
+// RecordPostpaidPayment 登记账单的线下付款
+func RecordPostpaidPayment(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	var req PostpaidPaymentRequest
+	if err := common.DecodeJson(c.Request.Body, &req); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if req.Method == "" {
+		req.Method = "offline"
+	}
+	payment := &model.PostpaidPayment{
+		Amount:     req.Amount,
+		Method:     req.Method,
+		Reference:  req.Reference,
+		Remark:     req.Remark,
+		OperatorId: c.GetInt("id"),
+	}
+	statement, err := model.RecordPostpaidPayment(id, payment)
+	if err != nil {
+		if errors.Is(err, model.ErrPostpaidStatementPaid) || errors.Is(err, model.ErrPostpaidStatementNotFound) {
+			common.ApiErrorMsg(c, err.Error())
+			return
+		}
+		common.ApiError(c, err)
+		return
+	}
+	recordManageAudit(c, "postpaid.payment_record", map[string]interface{}{
+		"statement_id": statement.Id,
+		"amount":       payment.Amount,
+		"reference":    payment.Reference,
+	})
+	common.ApiSuccess(c, gin.H{
+		"statement": statement,
+		"payment":   payment,
+	})
+}
+
+// RunPostpaidStatements 立即执行一次出账与逾期检查，period_start 为空时为上个自然月出账
+func RunPostpaidStatements(c *gin.Context) {
+	var req PostpaidRunRequest
+	if err := common.DecodeJson(c.Request.Body, &req); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	task, _, err := service.EnqueueSystemTask(model.SystemTaskTypePostpaidStatement, service.PostpaidStatementPayload{PeriodStart: req.PeriodStart})
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, task)
+}
+
+// GetSelfPostpaid 返回当前用户的信用额度与账单
+func GetSelfPostpaid(c *gin.Context) {
+	userId := c.GetInt("id")
+	account, err := model.GetPostpaidAccountByOwner(model.BillingProfileOwnerUser, userId)
+	if err != nil && !errors.Is(err, model.ErrPostpaidAccountNotFound) {
+		common.ApiError(c, err)
+		return
+	}
+	pageInfo := common.GetPageQuery(c)
+	statements, total, err := model.GetPostpaidStatements(0, model.BillingProfileOwnerUser, userId, "", pageInfo.GetStartIdx(), pageInfo.GetPageSize())
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	pageInfo.SetTotal(int(total))
+	pageInfo.SetItems(statements)
+	common.ApiSuccess(c, gin.H{
+		"account":    account,
+		"statements": pageInfo,
+	})
+}
+
+func GetSelfPostpaidStatement(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	statement, err := model.GetPostpaidStatementById(id)
+	if err != nil || statement.OwnerType != model.BillingProfileOwnerUser || statement.OwnerId != c.GetInt("id") {
+		common.ApiErrorMsg(c, model.ErrPostpaidStatementNotFound.Error())
+		return
+	}
+	respondPostpaidStatement(c, statement)
+}
@@ -4,6 +4,7 @@ import (
 	"github.com/QuantumNous/new-api/common"
 	"github.com/QuantumNous/new-api/model"
 	"github.com/QuantumNous/new-api/service"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
 	"github.com/QuantumNous/new-api/setting/ratio_setting"
 
 	"github.com/gin-gonic/gin"
@@ -64,6 +65,12 @@ func GetPricing(c *gin.Context) {
 		}
 	}
 
+	// 价格以 USD 计，currency 为展示币种及其汇率，未知币种回退到 USD
+	currency, ok := operation_setting.GetCurrencyRate(c.Query("currency"))
+	if !ok {
+		currency, _ = operation_setting.GetCurrencyRate(operation_setting.CurrencyUSD)
+	}
+
 	c.JSON(200, gin.H{
 		"success":            true,
 		"data":               pricing,
@@ -72,6 +79,8 @@ func GetPricing(c *gin.Context) {
 		"usable_group":       usableGroup,
 		"supported_endpoint": model.GetSupportedEndpointMap(),
 		"auto_groups":        service.GetUserAutoGroup(group),
+		"currency":           currency,
+		"currencies":         operation_setting.GetEnabledCurrencies(),
 		"pricing_version":    "a42d372ccf0b5dd13ecf71203521f9d2",
 	})
 }
@@ -0,0 +1,65 @@
+package controller
+
+import (
+	"strconv"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+func GetQuotaLedgerEntries(c *gin.Context) {
+	pageInfo := common.GetPageQuery(c)
+	userId, _ := strconv.Atoi(c.Query("user_id"))
+	startTimestamp, _ := strconv.ParseInt(c.Query("start_timestamp"), 10, 64)
+	endTimestamp, _ := strconv.ParseInt(c.Query("end_timestamp"), 10, 64)
+	entries, total, err := model.GetQuotaLedgerEntries(model.QuotaLedgerQuery{
+		UserId:         userId,
+		Account:        c.Query("account"),
+		Reason:         c.Query("reason"),
+		RefId:          c.Query("ref_id"),
+		StartTimestamp: startTimestamp,
+		EndTimestamp:   endTimestamp,
+	}, pageInfo.GetStartIdx(), pageInfo.GetPageSize())
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	pageInfo.SetTotal(int(total))
+	pageInfo.SetItems(entries)
+	common.ApiSuccess(c, pageInfo)
+}
+
+func GetQuotaLedgerDrifts(c *gin.Context) {
+	pageInfo := common.GetPageQuery(c)
+	resolved, _ := strconv.ParseBool(c.Query("resolved"))
+	drifts, total, err := model.GetQuotaLedgerDrifts(resolved, pageInfo.GetStartIdx(), pageInfo.GetPageSize())
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	pageInfo.SetTotal(int(total))
+	pageInfo.SetItems(drifts)
+	common.ApiSuccess(c, pageInfo)
+}
+
+// ReconcileQuotaLedger 立即执行一次对账，不等待定时任务。
+func ReconcileQuotaLedger(c *gin.Context) {
+	result, err := service.RunQuotaLedgerReconcile()
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	recordManageAudit(c, "quota_ledger.reconcile", map[string]interface{}{
+		"checked": result.Checked,
+		"drifted": result.Drifted,
+	})
+	common.ApiSuccess(c, gin.H{
+		"checked":  result.Checked,
+		"opened":   result.Opened,
+		"drifted":  result.Drifted,
+		"resolved": result.Resolved,
+	})
+}
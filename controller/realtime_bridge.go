@@ -0,0 +1,130 @@
+package controller
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/middleware"
+	"github.com/QuantumNous/new-api/relay"
+	"github.com/QuantumNous/new-api/relay/helper"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tidwall/gjson"
+)
+
+// realtimeBridgeHopSkipKeys 是不能从实时会话带入各环节请求的上下文键，渠道相关的键由 Distribute 重新设置
+var realtimeBridgeHopSkipKeys = []string{
+	common.KeyBodyStorage,
+	common.KeyRequestBody,
+	"relay_mode",
+	"monitor_id",
+	"monitor_response_recorded",
+	"use_channel",
+	string(constant.ContextKeySettledUsage),
+}
+
+// relayRealtimeBridge 处理命中桥接规则的 /v1/realtime 会话，由转写、对话、语音合成三个普通请求组成
+func relayRealtimeBridge(c *gin.Context, rule *operation_setting.RealtimeBridgeRule) {
+	ws, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		helper.WssError(c, ws, types.NewError(err, types.ErrorCodeGetChannelFailed, types.ErrOptionWithSkipRetry()).ToOpenAIError())
+		return
+	}
+	defer ws.Close()
+
+	logger.LogInfo(c, fmt.Sprintf("realtime bridge session started: model=%s, chat=%s, transcription=%s, speech=%s",
+		rule.Model, rule.ChatModel, rule.TranscriptionModel, rule.SpeechModel))
+	if err := relay.RealtimeBridgeHelper(c, ws, *rule, realtimeBridgePipeline{}); err != nil && !common.IsDownstreamContextDone(c.Request.Context()) {
+		logger.LogError(c, "realtime bridge error: "+err.Error())
+	}
+}
+
+// realtimeBridgePipeline 把每个环节作为内部请求交给 Relay，渠道选择、重试、预扣费与日志都与直接调用对应接口一致
+type realtimeBridgePipeline struct{}
+
+func (realtimeBridgePipeline) Transcribe(c *gin.Context, model string, audio []byte, filename string) (string, error) {
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	_ = writer.WriteField("model", model)
+	_ = writer.WriteField("response_format", "json")
+	part, err := writer.CreateFormFile("file", filename)
+	if err != nil {
+		return "", err
+	}
+	if _, err := part.Write(audio); err != nil {
+		return "", err
+	}
+	if err := writer.Close(); err != nil {
+		return "", err
+	}
+
+	data, err := runRealtimeBridgeHop(c, "/v1/audio/transcriptions", writer.FormDataContentType(), body.Bytes(), types.RelayFormatOpenAIAudio)
+	if err != nil {
+		return "", err
+	}
+	var response dto.AudioResponse
+	if err := common.Unmarshal(data, &response); err != nil {
+		return "", fmt.Errorf("invalid transcription response: %w", err)
+	}
+	return strings.TrimSpace(response.Text), nil
+}
+
+func (realtimeBridgePipeline) Chat(c *gin.Context, request *dto.GeneralOpenAIRequest) (*dto.OpenAITextResponse, error) {
+	body, err := common.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+	data, err := runRealtimeBridgeHop(c, "/v1/chat/completions", "application/json", body, types.RelayFormatOpenAI)
+	if err != nil {
+		return nil, err
+	}
+	var response dto.OpenAITextResponse
+	if err := common.Unmarshal(data, &response); err != nil {
+		return nil, fmt.Errorf("invalid chat completion response: %w", err)
+	}
+	return &response, nil
+}
+
+func (realtimeBridgePipeline) Speak(c *gin.Context, request *dto.AudioRequest) ([]byte, error) {
+	body, err := common.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+	return runRealtimeBridgeHop(c, "/v1/audio/speech", "application/json", body, types.RelayFormatOpenAIAudio)
+}
+
+// runRealtimeBridgeHop 以实时会话的令牌与用户上下文构造内部请求，依次经过 Distribute 与 Relay，返回成功响应体
+func runRealtimeBridgeHop(c *gin.Context, path string, contentType string, body []byte, relayFormat types.RelayFormat) ([]byte, error) {
+	w := httptest.NewRecorder()
+	hop, _ := gin.CreateTestContext(w)
+	hop.Request = httptest.NewRequestWithContext(c.Request.Context(), http.MethodPost, path, bytes.NewReader(body))
+	hop.Request.Header.Set("Content-Type", contentType)
+	hop.Keys = c.Copy().Keys
+	for _, key := range realtimeBridgeHopSkipKeys {
+		delete(hop.Keys, key)
+	}
+
+	middleware.Distribute()(hop)
+	if !hop.IsAborted() {
+		Relay(hop, relayFormat)
+	}
+	if w.Code >= http.StatusBadRequest {
+		message := gjson.GetBytes(w.Body.Bytes(), "error.message").String()
+		if message == "" {
+			message = common.GetStringIfEmpty(gjson.GetBytes(w.Body.Bytes(), "message").String(), http.StatusText(w.Code))
+		}
+		return nil, errors.New(message)
+	}
+	return w.Body.Bytes(), nil
+}
@@ -83,6 +83,24 @@ func shouldMonitorRequest(relayInfo *relaycommon.RelayInfo, relayFormat types.Re
 	return relayFormat == types.RelayFormatClaude
 }
 
+// bedrockErrorType 按状态码返回 Bedrock Runtime 对应的异常名称
+func bedrockErrorType(statusCode int) string {
+	switch statusCode {
+	case http.StatusBadRequest, http.StatusRequestEntityTooLarge:
+		return "ValidationException"
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return "AccessDeniedException"
+	case http.StatusNotFound:
+		return "ResourceNotFoundException"
+	case http.StatusTooManyRequests:
+		return "ThrottlingException"
+	case http.StatusServiceUnavailable:
+		return "ServiceUnavailableException"
+	default:
+		return "InternalServerException"
+	}
+}
+
 func Relay(c *gin.Context, relayFormat types.RelayFormat) {
 
 	requestId := c.GetString(common.RequestIdKey)
@@ -95,6 +113,10 @@ func Relay(c *gin.Context, relayFormat types.RelayFormat) {
 	)
 
 	if relayFormat == types.RelayFormatOpenAIRealtime {
+		if rule := service.MatchRealtimeBridgeRule(c.Query("model")); rule != nil {
+			relayRealtimeBridge(c, rule)
+			return
+		}
 		var err error
 		ws, err = upgrader.Upgrade(c.Writer, c.Request, nil)
 		if err != nil {
@@ -124,6 +146,22 @@ func Relay(c *gin.Context, relayFormat types.RelayFormat) {
 					"type":  "error",
 					"error": newAPIError.ToClaudeError(),
 				})
+			case types.RelayFormatOllama:
+				// Ollama 客户端只读取字符串形式的 error 字段
+				c.JSON(newAPIError.StatusCode, gin.H{
+					"error": newAPIError.Error(),
+				})
+			case types.RelayFormatBedrockConverse:
+				// AWS SDK 通过 x-amzn-ErrorType 头识别异常类型，消息取自 message 字段
+				c.Header("x-amzn-ErrorType", bedrockErrorType(newAPIError.StatusCode))
+				c.JSON(newAPIError.StatusCode, gin.H{
+					"message": newAPIError.Error(),
+				})
+			case types.RelayFormatCohereEmbedding:
+				// Cohere 错误响应只有 message 字段
+				c.JSON(newAPIError.StatusCode, gin.H{
+					"message": newAPIError.Error(),
+				})
 			default:
 				c.JSON(newAPIError.StatusCode, gin.H{
 					"error": newAPIError.ToOpenAIError(),
@@ -149,6 +187,23 @@ func Relay(c *gin.Context, relayFormat types.RelayFormat) {
 		return
 	}
 
+	if relayFormat == types.RelayFormatOllama {
+		// 在错误输出之前执行：先写出已转换的内容并恢复原 Writer，错误由上方 defer 按 Ollama 格式输出
+		ollamaWriter := helper.NewOllamaResponseWriter(c, relayInfo)
+		c.Writer = ollamaWriter
+		defer ollamaWriter.Finish()
+	}
+	if relayFormat == types.RelayFormatBedrockConverse {
+		converseWriter := helper.NewBedrockConverseResponseWriter(c, relayInfo)
+		c.Writer = converseWriter
+		defer converseWriter.Finish()
+	}
+	if relayFormat == types.RelayFormatCohereEmbedding {
+		embeddingWriter := helper.NewEmbeddingResponseWriter(c, relayInfo, types.RelayFormatCohereEmbedding)
+		c.Writer = embeddingWriter
+		defer embeddingWriter.Finish()
+	}
+
 	needSensitiveCheck := setting.ShouldCheckPromptSensitive()
 	needCountToken := constant.CountToken
 	// Avoid building huge CombineText (strings.Join) when token counting and sensitive check are both disabled.
@@ -257,6 +312,10 @@ func Relay(c *gin.Context, relayFormat types.RelayFormat) {
 	relayInfo.LastError = nil
 
 	attemptCounter := 0
+	var channelLease *service.ChannelConcurrencyLease
+	defer func() {
+		channelLease.Release()
+	}()
 
 	for ; retryParam.GetRetry() <= common.RetryTimes; retryParam.IncreaseRetry() {
 		// If the downstream request is already gone, abort immediately instead of
@@ -281,6 +340,14 @@ func Relay(c *gin.Context, relayFormat types.RelayFormat) {
 			break
 		}
 
+		channelLease.Release()
+		channel, channelLease, channelErr = acquireChannelConcurrencySlot(c, relayInfo, retryParam, channel)
+		if channelErr != nil {
+			logger.LogError(c, channelErr.Error())
+			newAPIError = channelErr
+			break
+		}
+
 		addUsedChannel(c, channel.Id)
 		logger.LogInfo(c, fmt.Sprintf("Currently selected channel: #%d [%s]", channel.Id, channel.Name))
 
@@ -331,7 +398,7 @@ func Relay(c *gin.Context, relayFormat types.RelayFormat) {
 			switch relayFormat {
 			case types.RelayFormatOpenAIRealtime:
 				newAPIError = relay.WssHelper(c, relayInfo)
-			case types.RelayFormatClaude:
+			case types.RelayFormatClaude, types.RelayFormatBedrockConverse:
 				newAPIError = relay.ClaudeHelper(c, relayInfo)
 			case types.RelayFormatGemini:
 				newAPIError = geminiRelayHandler(c, relayInfo)
@@ -379,6 +446,7 @@ func Relay(c *gin.Context, relayFormat types.RelayFormat) {
 				if monitorID != "" {
 					monitor.FinishChannelAttemptAndMarkPhase(monitorID, monitor.AttemptStatusSucceeded, monitor.PhaseCompleted, "", "", c.Writer.Status())
 				}
+				maybeMirrorShadowTraffic(c, relayInfo, relayFormat, channel)
 				return
 			}
 
@@ -565,6 +633,12 @@ func getChannel(c *gin.Context, info *relaycommon.RelayInfo, retryParam *service
 		}
 		return channel, nil
 	}
+	return selectRetryChannel(c, info, retryParam)
+}
+
+// selectRetryChannel picks a channel from the group/model pool (honoring the
+// per-request exclusions) and prepares the context for it.
+func selectRetryChannel(c *gin.Context, info *relaycommon.RelayInfo, retryParam *service.RetryParam) (*model.Channel, *types.NewAPIError) {
 	channel, selectGroup, err := service.CacheGetRandomSatisfiedChannel(retryParam)
 
 	info.PriceData.GroupRatioInfo = helper.HandleGroupRatio(c, info)
@@ -583,6 +657,66 @@ func getChannel(c *gin.Context, info *relaycommon.RelayInfo, retryParam *service
 	return channel, nil
 }
 
+// acquireChannelConcurrencySlot reserves a concurrency slot on the selected
+// channel. When its cap is full the request moves on to the next candidate;
+// when every candidate is full it waits in the first channel's FIFO queue,
+// on the same key that was found saturated.
+func acquireChannelConcurrencySlot(c *gin.Context, info *relaycommon.RelayInfo, retryParam *service.RetryParam, channel *model.Channel) (*model.Channel, *service.ChannelConcurrencyLease, *types.NewAPIError) {
+	// The saturated list only steers candidate selection within this loop;
+	// later retries of the request must be able to pick those channels again.
+	defer common.SetContextKey(c, constant.ContextKeyConcurrencySaturatedChannels, []int(nil))
+
+	first := channel
+	firstKeyIndex := common.GetContextKeyInt(c, constant.ContextKeyChannelMultiKeyIndex)
+	firstKey := common.GetContextKeyString(c, constant.ContextKeyChannelKey)
+	canReroute := common.GetContextKeyInt(c, constant.ContextKeyTokenSpecificChannelId) <= 0
+	for channel != nil {
+		lease, err := service.TryAcquireChannelConcurrency(channel, common.GetContextKeyInt(c, constant.ContextKeyChannelMultiKeyIndex))
+		if err == nil {
+			return channel, lease, nil
+		}
+		if !errors.Is(err, service.ErrChannelConcurrencySaturated) {
+			// Fail open: a counter backend hiccup must not take traffic down.
+			logger.LogWarn(c, fmt.Sprintf("channel concurrency check failed, continuing without cap: channel_id=%d, err=%v", channel.Id, err))
+			return channel, nil, nil
+		}
+		logger.LogInfo(c, fmt.Sprintf("channel #%d concurrency limit reached, trying next candidate", channel.Id))
+		saturated, _ := common.GetContextKeyType[[]int](c, constant.ContextKeyConcurrencySaturatedChannels)
+		common.SetContextKey(c, constant.ContextKeyConcurrencySaturatedChannels, append(saturated, channel.Id))
+		if !canReroute {
+			break
+		}
+		next, nextErr := selectRetryChannel(c, info, retryParam)
+		if nextErr != nil {
+			break
+		}
+		channel = next
+	}
+
+	if channel != first {
+		if setupErr := middleware.SetupContextForSelectedChannel(c, first, info.OriginModelName); setupErr != nil {
+			return nil, nil, setupErr
+		}
+		// Setup picks the next key again; stay on the key we found saturated.
+		if common.GetContextKeyBool(c, constant.ContextKeyChannelIsMultiKey) {
+			common.SetContextKey(c, constant.ContextKeyChannelMultiKeyIndex, firstKeyIndex)
+			common.SetContextKey(c, constant.ContextKeyChannelKey, firstKey)
+		}
+	}
+	lease, err := service.WaitChannelConcurrency(c.Request.Context(), first, firstKeyIndex)
+	if err != nil {
+		if common.IsDownstreamContextDone(c.Request.Context()) {
+			return nil, nil, types.NewErrorWithStatusCode(err, types.ErrorCodeDownstreamCanceled, constant.StatusClientClosedRequest, types.ErrOptionWithSkipRetry(), types.ErrOptionWithNoRecordErrorLog())
+		}
+		if !errors.Is(err, service.ErrChannelConcurrencySaturated) && !errors.Is(err, service.ErrChannelQueueFull) && !errors.Is(err, service.ErrChannelQueueTimeout) {
+			logger.LogWarn(c, fmt.Sprintf("channel concurrency wait failed, continuing without cap: channel_id=%d, err=%v", first.Id, err))
+			return first, nil, nil
+		}
+		return nil, nil, types.NewErrorWithStatusCode(fmt.Errorf("channel #%d: %w", first.Id, err), types.ErrorCodeConcurrencyLimited, http.StatusTooManyRequests, types.ErrOptionWithSkipRetry())
+	}
+	return first, lease, nil
+}
+
 func shouldRetry(c *gin.Context, openaiErr *types.NewAPIError, retryTimes int) bool {
 	if openaiErr == nil {
 		return false
@@ -620,6 +754,10 @@ func processChannelError(c *gin.Context, channelError types.ChannelError, err *t
 	// 不要使用context获取渠道信息，异步处理时可能会出现渠道信息不一致的情况
 	// do not use context to get channel info, there may be inconsistent channel info when processing asynchronously
 	skipAutoDisable := len(suppressAutoDisable) > 0 && suppressAutoDisable[0]
+	// 密钥轮换宽限期内新密钥被上游拒绝时同步切回旧密钥，本次重试即可使用旧密钥
+	if service.ShouldDisableChannel(err) && model.FallbackToPreviousChannelKey(channelError.ChannelId, channelError.UsingKey) {
+		logger.LogWarn(c, fmt.Sprintf("channel #%d rejected the rotated key, falling back to the previous key during the grace period", channelError.ChannelId))
+	}
 	if !skipAutoDisable && service.ShouldDisableChannel(err) && channelError.AutoBan {
 		gopool.Go(func() {
 			service.DisableChannel(channelError, err.ErrorWithStatusCode())
@@ -745,6 +883,34 @@ func RelayTaskFetch(c *gin.Context) {
 	}
 }
 
+// RelayTaskCancel 取消当前用户的进行中任务，成功后返回任务最新状态；平台不支持取消时返回 cancel_not_supported
+func RelayTaskCancel(c *gin.Context) {
+	taskID := c.Param("task_id")
+	if taskID == "" {
+		taskID = c.Param("video_id")
+	}
+	if taskID == "" {
+		taskID = c.Param("id")
+	}
+	task, exist, err := model.GetByTaskId(c.GetInt("id"), taskID)
+	if err != nil {
+		respondTaskError(c, service.TaskErrorWrapper(err, "get_task_failed", http.StatusInternalServerError))
+		return
+	}
+	if !exist {
+		respondTaskError(c, service.TaskErrorWrapperLocal(errors.New("task_not_exist"), "task_not_exist", http.StatusNotFound))
+		return
+	}
+	if taskErr := service.CancelTask(c.Request.Context(), task); taskErr != nil {
+		respondTaskError(c, taskErr)
+		return
+	}
+	c.JSON(http.StatusOK, dto.TaskResponse[any]{
+		Code: "success",
+		Data: relay.TaskModel2Dto(task),
+	})
+}
+
 func RelayTask(c *gin.Context) {
 	relayInfo, err := relaycommon.GenRelayInfo(c, types.RelayFormatTask, nil, nil)
 	if err != nil {
@@ -761,6 +927,12 @@ func RelayTask(c *gin.Context) {
 		return
 	}
 
+	callbackURL, callbackErr := service.ResolveTaskCallbackURL(c, relayInfo.TokenId)
+	if callbackErr != nil {
+		respondTaskError(c, service.TaskErrorWrapperLocal(callbackErr, "invalid_callback_url", http.StatusBadRequest))
+		return
+	}
+
 	var result *relay.TaskSubmitResult
 	var taskErr *dto.TaskError
 	defer func() {
@@ -870,14 +1042,20 @@ func RelayTask(c *gin.Context) {
 		if settleErr := service.SettleBilling(c, relayInfo, result.Quota); settleErr != nil {
 			common.SysError("settle task billing error: " + settleErr.Error())
 		}
-		service.LogTaskConsumption(c, relayInfo)
+		upstreamCost := service.LogTaskConsumption(c, relayInfo)
 
 		task := model.InitTask(result.Platform, relayInfo)
 		task.PrivateData.UpstreamTaskID = result.UpstreamTaskID
 		task.PrivateData.BillingSource = relayInfo.BillingSource
 		task.PrivateData.SubscriptionId = relayInfo.SubscriptionId
+		task.PrivateData.SubscriptionBucketId = relayInfo.SubscriptionBucketId
+		task.PrivateData.SubscriptionBucketUnit = relayInfo.SubscriptionBucketUnit
+		task.PrivateData.SubscriptionBucketConsumed = relayInfo.SubscriptionBucketPreConsumed
+		// 组织令牌的任务无论走组织订阅还是组织钱包，调整时都要同步成员已用额度
+		task.PrivateData.OrganizationId = relayInfo.OrganizationId
 		task.PrivateData.TokenId = relayInfo.TokenId
 		task.PrivateData.NodeName = common.NodeName
+		task.PrivateData.CallbackURL = callbackURL
 		task.PrivateData.BillingContext = &model.TaskBillingContext{
 			ModelPrice:      relayInfo.PriceData.ModelPrice,
 			GroupRatio:      relayInfo.PriceData.GroupRatioInfo.GroupRatio,
@@ -885,6 +1063,7 @@ func RelayTask(c *gin.Context) {
 			OtherRatios:     relayInfo.PriceData.OtherRatios(),
 			OriginModelName: relayInfo.OriginModelName,
 			PerCallBilling:  common.StringsContains(constant.TaskPricePatches, relayInfo.OriginModelName) || relayInfo.PriceData.UsePrice,
+			UpstreamCost:    upstreamCost,
 		}
 		task.Quota = result.Quota
 		task.Data = result.TaskData
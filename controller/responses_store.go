@@ -0,0 +1,67 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+func storedResponseNotFound(c *gin.Context, responseId string) {
+	c.JSON(http.StatusNotFound, gin.H{
+		"error": types.OpenAIError{
+			Message: "No response found with id '" + responseId + "'.",
+			Type:    "invalid_request_error",
+			Param:   "response_id",
+			Code:    "response_not_found",
+		},
+	})
+}
+
+// GetStoredResponse 返回网关代存的 Responses API 响应
+func GetStoredResponse(c *gin.Context) {
+	responseId := c.Param("id")
+	stored, err := model.GetStoredResponse(c.GetInt("id"), responseId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": types.OpenAIError{
+				Message: err.Error(),
+				Type:    "new_api_error",
+				Code:    string(types.ErrorCodeQueryDataError),
+			},
+		})
+		return
+	}
+	if stored == nil {
+		storedResponseNotFound(c, responseId)
+		return
+	}
+	c.Data(http.StatusOK, "application/json", []byte(stored.Response))
+}
+
+// DeleteStoredResponse 删除网关代存的 Responses API 响应
+func DeleteStoredResponse(c *gin.Context) {
+	responseId := c.Param("id")
+	deleted, err := model.DeleteStoredResponse(c.GetInt("id"), responseId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": types.OpenAIError{
+				Message: err.Error(),
+				Type:    "new_api_error",
+				Code:    string(types.ErrorCodeUpdateDataError),
+			},
+		})
+		return
+	}
+	if !deleted {
+		storedResponseNotFound(c, responseId)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"id":      responseId,
+		"object":  "response",
+		"deleted": true,
+	})
+}
@@ -204,7 +204,17 @@ func AdminCreateSubscriptionPlan(c *gin.Context) {
 		common.ApiErrorMsg(c, "自定义重置周期需大于0秒")
 		return
 	}
-	err := model.DB.Create(&req.Plan).Error
+	buckets, err := model.NormalizeSubscriptionQuotaBuckets(req.Plan.QuotaBuckets)
+	if err != nil {
+		common.ApiErrorMsg(c, err.Error())
+		return
+	}
+	req.Plan.QuotaBuckets = buckets
+	if req.Plan.BucketsOnly && len(buckets) == 0 {
+		common.ApiErrorMsg(c, "仅限额度桶模型时需要至少配置一个额度桶")
+		return
+	}
+	err = model.DB.Create(&req.Plan).Error
 	if err != nil {
 		common.ApiError(c, err)
 		return
@@ -278,8 +288,18 @@ func AdminUpdateSubscriptionPlan(c *gin.Context) {
 		common.ApiErrorMsg(c, "自定义重置周期需大于0秒")
 		return
 	}
+	buckets, err := model.NormalizeSubscriptionQuotaBuckets(req.Plan.QuotaBuckets)
+	if err != nil {
+		common.ApiErrorMsg(c, err.Error())
+		return
+	}
+	req.Plan.QuotaBuckets = buckets
+	if req.Plan.BucketsOnly && len(buckets) == 0 {
+		common.ApiErrorMsg(c, "仅限额度桶模型时需要至少配置一个额度桶")
+		return
+	}
 
-	err := model.DB.Transaction(func(tx *gorm.DB) error {
+	err = model.DB.Transaction(func(tx *gorm.DB) error {
 		// update plan (allow zero values updates with map)
 		updateMap := map[string]interface{}{
 			"title":                      req.Plan.Title,
@@ -300,6 +320,8 @@ func AdminUpdateSubscriptionPlan(c *gin.Context) {
 			"downgrade_group":            req.Plan.DowngradeGroup,
 			"quota_reset_period":         req.Plan.QuotaResetPeriod,
 			"quota_reset_custom_seconds": req.Plan.QuotaResetCustomSeconds,
+			"quota_buckets":              req.Plan.QuotaBuckets,
+			"buckets_only":               req.Plan.BucketsOnly,
 			"updated_at":                 common.GetTimestamp(),
 		}
 		if req.Plan.AllowBalancePay != nil {
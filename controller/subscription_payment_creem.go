@@ -17,7 +17,8 @@ import (
 )
 
 type SubscriptionCreemPayRequest struct {
-	PlanId int `json:"plan_id"`
+	PlanId     int    `json:"plan_id"`
+	CouponCode string `json:"coupon_code"` // Creem 产品价格固定，仅支持赠送类优惠码
 }
 
 func SubscriptionRequestCreemPay(c *gin.Context) {
@@ -82,9 +83,23 @@ func SubscriptionRequestCreemPay(c *gin.Context) {
 		}
 	}
 
+	couponUsage := model.CouponUsage{UserId: userId, OrderType: model.CouponScopeSubscription, PlanId: plan.Id, PaymentMethod: model.PaymentMethodCreem}
+	coupon, err := resolveCheckoutCoupon(req.CouponCode, couponUsage, false)
+	if err != nil {
+		common.ApiErrorMsg(c, err.Error())
+		return
+	}
+
 	reference := "sub-creem-ref-" + randstr.String(6)
 	referenceId := "sub_ref_" + common.Sha1([]byte(reference+time.Now().String()+user.Username))
 
+	if coupon != nil {
+		if err := model.ReserveCoupon(coupon, couponUsage, referenceId, plan.PriceAmount, 0); err != nil {
+			common.ApiErrorMsg(c, err.Error())
+			return
+		}
+	}
+
 	// create pending order first
 	order := &model.SubscriptionOrder{
 		UserId:          userId,
@@ -96,7 +111,11 @@ func SubscriptionRequestCreemPay(c *gin.Context) {
 		CreateTime:      time.Now().Unix(),
 		Status:          common.TopUpStatusPending,
 	}
+	if coupon != nil {
+		order.CouponCode = coupon.Code
+	}
 	if err := order.Insert(); err != nil {
+		_ = model.ReleaseCouponRedemption(referenceId)
 		c.JSON(http.StatusOK, gin.H{"message": "error", "data": "创建订单失败"})
 		return
 	}
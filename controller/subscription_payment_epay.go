@@ -19,6 +19,7 @@ import (
 type SubscriptionEpayPayRequest struct {
 	PlanId        int    `json:"plan_id"`
 	PaymentMethod string `json:"payment_method"`
+	CouponCode    string `json:"coupon_code"`
 }
 
 func SubscriptionRequestEpay(c *gin.Context) {
@@ -63,6 +64,18 @@ func SubscriptionRequestEpay(c *gin.Context) {
 		}
 	}
 
+	couponUsage := model.CouponUsage{UserId: userId, OrderType: model.CouponScopeSubscription, PlanId: plan.Id, PaymentMethod: req.PaymentMethod}
+	coupon, err := resolveCheckoutCoupon(req.CouponCode, couponUsage, true)
+	if err != nil {
+		common.ApiErrorMsg(c, err.Error())
+		return
+	}
+	payMoney, discountMoney := coupon.ApplyToMoney(plan.PriceAmount)
+	if payMoney < 0.01 {
+		common.ApiErrorMsg(c, "套餐金额过低")
+		return
+	}
+
 	callBackAddress := service.GetCallbackAddress()
 	returnUrl, err := url.Parse(callBackAddress + "/api/subscription/epay/return")
 	if err != nil {
@@ -84,17 +97,28 @@ func SubscriptionRequestEpay(c *gin.Context) {
 		return
 	}
 
+	if coupon != nil {
+		if err := model.ReserveCoupon(coupon, couponUsage, tradeNo, plan.PriceAmount, discountMoney); err != nil {
+			common.ApiErrorMsg(c, err.Error())
+			return
+		}
+	}
 	order := &model.SubscriptionOrder{
 		UserId:          userId,
 		PlanId:          plan.Id,
-		Money:           plan.PriceAmount,
+		Money:           payMoney,
 		TradeNo:         tradeNo,
 		PaymentMethod:   req.PaymentMethod,
 		PaymentProvider: model.PaymentProviderEpay,
 		CreateTime:      time.Now().Unix(),
 		Status:          common.TopUpStatusPending,
+		DiscountMoney:   discountMoney,
+	}
+	if coupon != nil {
+		order.CouponCode = coupon.Code
 	}
 	if err := order.Insert(); err != nil {
+		_ = model.ReleaseCouponRedemption(tradeNo)
 		common.ApiErrorMsg(c, "创建订单失败")
 		return
 	}
@@ -102,7 +126,7 @@ func SubscriptionRequestEpay(c *gin.Context) {
 		Type:           req.PaymentMethod,
 		ServiceTradeNo: tradeNo,
 		Name:           fmt.Sprintf("SUB:%s", plan.Title),
-		Money:          strconv.FormatFloat(plan.PriceAmount, 'f', 2, 64),
+		Money:          strconv.FormatFloat(payMoney, 'f', 2, 64),
 		Device:         epay.PC,
 		NotifyUrl:      notifyUrl,
 		ReturnUrl:      returnUrl,
@@ -164,6 +188,7 @@ func SubscriptionEpayNotify(c *gin.Context) {
 		_, _ = c.Writer.Write([]byte("fail"))
 		return
 	}
+	service.IssueSubscriptionOrderInvoiceAsync(verifyInfo.ServiceTradeNo)
 
 	_, _ = c.Writer.Write([]byte("success"))
 }
@@ -213,6 +238,7 @@ func SubscriptionEpayReturn(c *gin.Context) {
 			c.Redirect(http.StatusFound, paymentReturnPath("/wallet?pay=fail"))
 			return
 		}
+		service.IssueSubscriptionOrderInvoiceAsync(verifyInfo.ServiceTradeNo)
 		c.Redirect(http.StatusFound, paymentReturnPath("/wallet?pay=success"))
 		return
 	}
@@ -0,0 +1,145 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/service"
+	"github.com/gin-gonic/gin"
+	"github.com/thanhpk/randstr"
+)
+
+type SubscriptionPayPalPayRequest struct {
+	PlanId     int    `json:"plan_id"`
+	CouponCode string `json:"coupon_code"`
+}
+
+func SubscriptionRequestPayPalPay(c *gin.Context) {
+	if !requirePaymentCompliance(c) {
+		return
+	}
+	if !isPayPalTopUpEnabled() {
+		common.ApiErrorMsg(c, "PayPal 未配置或 Webhook 未配置")
+		return
+	}
+
+	var req SubscriptionPayPalPayRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.PlanId <= 0 {
+		common.ApiErrorMsg(c, "参数错误")
+		return
+	}
+
+	plan, err := model.GetSubscriptionPlanById(req.PlanId)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if !plan.Enabled {
+		common.ApiErrorMsg(c, "套餐未启用")
+		return
+	}
+	if plan.PriceAmount < 0.01 {
+		common.ApiErrorMsg(c, "套餐金额过低")
+		return
+	}
+
+	userId := c.GetInt("id")
+	user, err := model.GetUserById(userId, false)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if user == nil {
+		common.ApiErrorMsg(c, "用户不存在")
+		return
+	}
+
+	if plan.MaxPurchasePerUser > 0 {
+		count, err := model.CountUserSubscriptionsByPlan(userId, plan.Id)
+		if err != nil {
+			common.ApiError(c, err)
+			return
+		}
+		if count >= int64(plan.MaxPurchasePerUser) {
+			common.ApiErrorMsg(c, "已达到该套餐购买上限")
+			return
+		}
+	}
+
+	couponUsage := model.CouponUsage{UserId: userId, OrderType: model.CouponScopeSubscription, PlanId: plan.Id, PaymentMethod: model.PaymentMethodPayPal}
+	coupon, err := resolveCheckoutCoupon(req.CouponCode, couponUsage, true)
+	if err != nil {
+		common.ApiErrorMsg(c, err.Error())
+		return
+	}
+	payMoney, discountMoney := coupon.ApplyToMoney(plan.PriceAmount)
+	if payMoney < 0.01 {
+		common.ApiErrorMsg(c, "支付金额过低")
+		return
+	}
+
+	reference := fmt.Sprintf("sub-paypal-ref-%d-%d-%s", user.Id, time.Now().UnixMilli(), randstr.String(4))
+	referenceId := "sub_ref_" + common.Sha1([]byte(reference))
+
+	if coupon != nil {
+		if err := model.ReserveCoupon(coupon, couponUsage, referenceId, plan.PriceAmount, discountMoney); err != nil {
+			common.ApiErrorMsg(c, err.Error())
+			return
+		}
+	}
+
+	order := &model.SubscriptionOrder{
+		UserId:          userId,
+		PlanId:          plan.Id,
+		Money:           payMoney,
+		TradeNo:         referenceId,
+		PaymentMethod:   model.PaymentMethodPayPal,
+		PaymentProvider: model.PaymentProviderPayPal,
+		CreateTime:      time.Now().Unix(),
+		Status:          common.TopUpStatusPending,
+		DiscountMoney:   discountMoney,
+	}
+	if coupon != nil {
+		order.CouponCode = coupon.Code
+	}
+	if err := order.Insert(); err != nil {
+		_ = model.ReleaseCouponRedemption(referenceId)
+		c.JSON(http.StatusOK, gin.H{"message": "error", "data": "创建订单失败"})
+		return
+	}
+
+	currency := strings.ToUpper(strings.TrimSpace(plan.Currency))
+	if currency == "" {
+		currency = getPayPalCurrency()
+	}
+	paypalOrder, err := service.CreatePayPalOrder(c.Request.Context(), service.PayPalCreateOrderParams{
+		TradeNo:     referenceId,
+		Description: plan.Title,
+		Amount:      formatCheckoutMoney(payMoney, currency),
+		Currency:    currency,
+		ReturnURL:   payPalReturnURL(),
+		CancelURL:   paymentReturnPath("/wallet"),
+	})
+	if err != nil || paypalOrder.ApproveURL() == "" {
+		if err == nil {
+			err = fmt.Errorf("PayPal 未返回支付链接")
+		}
+		logger.LogError(c.Request.Context(), fmt.Sprintf("PayPal 订阅支付链接创建失败 trade_no=%s plan_id=%d error=%q", referenceId, plan.Id, err.Error()))
+		_ = model.ExpireSubscriptionOrder(referenceId, model.PaymentProviderPayPal)
+		c.JSON(http.StatusOK, gin.H{"message": "error", "data": "拉起支付失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "success",
+		"data": gin.H{
+			"pay_link": paypalOrder.ApproveURL(),
+			"order_id": referenceId,
+		},
+	})
+}
@@ -17,7 +17,8 @@ import (
 )
 
 type SubscriptionStripePayRequest struct {
-	PlanId int `json:"plan_id"`
+	PlanId     int    `json:"plan_id"`
+	CouponCode string `json:"coupon_code"`
 }
 
 func SubscriptionRequestStripePay(c *gin.Context) {
@@ -76,12 +77,28 @@ func SubscriptionRequestStripePay(c *gin.Context) {
 		}
 	}
 
+	couponUsage := model.CouponUsage{UserId: userId, OrderType: model.CouponScopeSubscription, PlanId: plan.Id, PaymentMethod: model.PaymentMethodStripe}
+	coupon, err := resolveCheckoutCoupon(req.CouponCode, couponUsage, true)
+	if err != nil {
+		common.ApiErrorMsg(c, err.Error())
+		return
+	}
+	payMoney, discountMoney := coupon.ApplyToMoney(plan.PriceAmount)
+
 	reference := fmt.Sprintf("sub-stripe-ref-%d-%d-%s", user.Id, time.Now().UnixMilli(), randstr.String(4))
 	referenceId := "sub_ref_" + common.Sha1([]byte(reference))
 
-	payLink, err := genStripeSubscriptionLink(referenceId, user.StripeCustomer, user.Email, plan.StripePriceId)
+	if coupon != nil {
+		if err := model.ReserveCoupon(coupon, couponUsage, referenceId, plan.PriceAmount, discountMoney); err != nil {
+			common.ApiErrorMsg(c, err.Error())
+			return
+		}
+	}
+
+	payLink, err := genStripeSubscriptionLink(referenceId, user.StripeCustomer, user.Email, plan.StripePriceId, stripeDiscountPercent(coupon))
 	if err != nil {
 		logger.LogError(c.Request.Context(), fmt.Sprintf("Stripe 订阅支付链接创建失败 trade_no=%s plan_id=%d error=%q", referenceId, plan.Id, err.Error()))
+		_ = model.ReleaseCouponRedemption(referenceId)
 		c.JSON(http.StatusOK, gin.H{"message": "error", "data": "拉起支付失败"})
 		return
 	}
@@ -89,14 +106,19 @@ func SubscriptionRequestStripePay(c *gin.Context) {
 	order := &model.SubscriptionOrder{
 		UserId:          userId,
 		PlanId:          plan.Id,
-		Money:           plan.PriceAmount,
+		Money:           payMoney,
 		TradeNo:         referenceId,
 		PaymentMethod:   model.PaymentMethodStripe,
 		PaymentProvider: model.PaymentProviderStripe,
 		CreateTime:      time.Now().Unix(),
 		Status:          common.TopUpStatusPending,
+		DiscountMoney:   discountMoney,
+	}
+	if coupon != nil {
+		order.CouponCode = coupon.Code
 	}
 	if err := order.Insert(); err != nil {
+		_ = model.ReleaseCouponRedemption(referenceId)
 		c.JSON(http.StatusOK, gin.H{"message": "error", "data": "创建订单失败"})
 		return
 	}
@@ -109,7 +131,7 @@ func SubscriptionRequestStripePay(c *gin.Context) {
 	})
 }
 
-func genStripeSubscriptionLink(referenceId string, customerId string, email string, priceId string) (string, error) {
+func genStripeSubscriptionLink(referenceId string, customerId string, email string, priceId string, discountPercent float64) (string, error) {
 	stripe.Key = setting.StripeApiSecret
 
 	params := &stripe.CheckoutSessionParams{
@@ -124,6 +146,9 @@ func genStripeSubscriptionLink(referenceId string, customerId string, email stri
 		},
 		Mode: stripe.String(string(stripe.CheckoutSessionModeSubscription)),
 	}
+	if err := applyStripeCouponDiscount(params, discountPercent); err != nil {
+		return "", err
+	}
 
 	if "" == customerId {
 		if "" != email {
@@ -0,0 +1,64 @@
+package controller
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/service"
+
+	"github.com/bytedance/gopkg/util/gopool"
+	"github.com/gin-gonic/gin"
+)
+
+func GetAllTaskCallbacks(c *gin.Context) {
+	userId, _ := strconv.Atoi(c.Query("user_id"))
+	listTaskCallbacks(c, userId)
+}
+
+func GetUserTaskCallbacks(c *gin.Context) {
+	listTaskCallbacks(c, c.GetInt("id"))
+}
+
+func listTaskCallbacks(c *gin.Context, userId int) {
+	pageInfo := common.GetPageQuery(c)
+	items, total, err := model.GetTaskCallbackDeliveries(userId, c.Query("task_id"), c.Query("status"), pageInfo.GetStartIdx(), pageInfo.GetPageSize())
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	pageInfo.SetTotal(int(total))
+	pageInfo.SetItems(items)
+	common.ApiSuccess(c, pageInfo)
+}
+
+func RetryUserTaskCallback(c *gin.Context) {
+	retryTaskCallback(c, c.GetInt("id"))
+}
+
+func RetryTaskCallback(c *gin.Context) {
+	retryTaskCallback(c, 0)
+}
+
+// retryTaskCallback 把已放弃的投递重新入队并立即触发一轮重试
+func retryTaskCallback(c *gin.Context, userId int) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil || id <= 0 {
+		common.ApiErrorMsg(c, "参数错误")
+		return
+	}
+	ok, err := model.RetryTaskCallbackDelivery(id, userId)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if !ok {
+		common.ApiErrorMsg(c, "投递记录不存在或不处于失败状态")
+		return
+	}
+	gopool.Go(func() {
+		service.RunTaskCallbackRetryOnce(context.Background())
+	})
+	common.ApiSuccess(c, nil)
+}
@@ -9,6 +9,7 @@ import (
 	"github.com/QuantumNous/new-api/common"
 	"github.com/QuantumNous/new-api/i18n"
 	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/service"
 	"github.com/QuantumNous/new-api/setting/operation_setting"
 
 	"github.com/gin-gonic/gin"
@@ -23,6 +24,26 @@ func buildMaskedTokenResponse(token *model.Token) *model.Token {
 	return &maskedToken
 }
 
+// validateTokenCallback 校验令牌默认的任务回调地址与签名密钥
+func validateTokenCallback(c *gin.Context, token *model.Token) bool {
+	token.CallbackUrl = strings.TrimSpace(token.CallbackUrl)
+	if token.CallbackUrl != "" {
+		if err := service.ValidateTaskCallbackURL(token.CallbackUrl); err != nil {
+			common.ApiErrorMsg(c, err.Error())
+			return false
+		}
+	}
+	if len(token.CallbackSecret) > 128 {
+		common.ApiErrorMsg(c, "回调签名密钥长度不能超过 128")
+		return false
+	}
+	// 配置了回调地址但未填写签名密钥时自动生成，回调始终带签名
+	if token.CallbackUrl != "" && token.CallbackSecret == "" {
+		token.CallbackSecret = common.GetRandomString(32)
+	}
+	return true
+}
+
 func buildMaskedTokenResponses(tokens []*model.Token) []*model.Token {
 	maskedTokens := make([]*model.Token, 0, len(tokens))
 	for _, token := range tokens {
@@ -175,6 +196,9 @@ func AddToken(c *gin.Context) {
 		common.ApiErrorI18n(c, i18n.MsgTokenNameTooLong)
 		return
 	}
+	if !validateTokenCallback(c, &token) {
+		return
+	}
 	// 非无限额度时，检查额度值是否超出有效范围
 	if !token.UnlimitedQuota {
 		if token.RemainQuota < 0 {
@@ -187,6 +211,13 @@ func AddToken(c *gin.Context) {
 			return
 		}
 	}
+	// 组织令牌只能由组织成员创建，消费从组织钱包扣除
+	if token.OrganizationId != 0 {
+		if _, err := model.GetOrganizationMember(token.OrganizationId, c.GetInt("id")); err != nil {
+			common.ApiError(c, err)
+			return
+		}
+	}
 	// 检查用户令牌数量是否已达上限
 	maxTokens := operation_setting.GetMaxUserTokens()
 	count, err := model.CountUserTokens(c.GetInt("id"))
@@ -221,6 +252,9 @@ func AddToken(c *gin.Context) {
 		AllowIps:           token.AllowIps,
 		Group:              token.Group,
 		CrossGroupRetry:    token.CrossGroupRetry,
+		OrganizationId:     token.OrganizationId,
+		CallbackUrl:        token.CallbackUrl,
+		CallbackSecret:     token.CallbackSecret,
 	}
 	err = cleanToken.Insert()
 	if err != nil {
@@ -260,6 +294,9 @@ func UpdateToken(c *gin.Context) {
 		common.ApiErrorI18n(c, i18n.MsgTokenNameTooLong)
 		return
 	}
+	if !validateTokenCallback(c, &token) {
+		return
+	}
 	if !token.UnlimitedQuota {
 		if token.RemainQuota < 0 {
 			common.ApiErrorI18n(c, i18n.MsgTokenQuotaNegative)
@@ -299,6 +336,8 @@ func UpdateToken(c *gin.Context) {
 		cleanToken.AllowIps = token.AllowIps
 		cleanToken.Group = token.Group
 		cleanToken.CrossGroupRetry = token.CrossGroupRetry
+		cleanToken.CallbackUrl = token.CallbackUrl
+		cleanToken.CallbackSecret = token.CallbackSecret
 	}
 	err = cleanToken.Update()
 	if err != nil {
@@ -1,10 +1,12 @@
 package controller
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"net/url"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -95,6 +97,19 @@ func GetTopUpInfo(c *gin.Context) {
 		}
 	}
 
+	// 通过 PaymentProvider 接入的渠道
+	enabledProviders := make(map[string]bool, len(paymentProviders))
+	for _, provider := range paymentProviders {
+		enabledProviders[provider.Name()] = provider.TopUpEnabled()
+		if !enabledProviders[provider.Name()] {
+			continue
+		}
+		method := provider.TopUpMethod()
+		if !lo.ContainsBy(payMethods, func(m map[string]string) bool { return m["type"] == method["type"] }) {
+			payMethods = append(payMethods, method)
+		}
+	}
+
 	data := gin.H{
 		"enable_online_topup":              isEpayTopUpEnabled(),
 		"enable_stripe_topup":              isStripeTopUpEnabled(),
@@ -120,16 +135,22 @@ func GetTopUpInfo(c *gin.Context) {
 		"discount":                operation_setting.GetPaymentSetting().AmountDiscount,
 		"topup_link":              common.TopUpLink,
 	}
+	for name, enabled := range enabledProviders {
+		data["enable_"+name+"_topup"] = enabled
+	}
 	common.ApiSuccess(c, data)
 }
 
 type EpayRequest struct {
 	Amount        int64  `json:"amount"`
 	PaymentMethod string `json:"payment_method"`
+	CouponCode    string `json:"coupon_code"`
+	Currency      string `json:"currency"` // 只能为空或易支付结算币种
 }
 
 type AmountRequest struct {
-	Amount int64 `json:"amount"`
+	Amount   int64  `json:"amount"`
+	Currency string `json:"currency"`
 }
 
 func GetEpayClient() *epay.Client {
@@ -176,6 +197,48 @@ func getPayMoney(amount int64, group string) float64 {
 	return payMoney.InexactFloat64()
 }
 
+// convertCheckoutMoney 把支付渠道默认币种的金额换算为用户选择的币种，返回换算后的金额和币种代码。
+// requested 为空或与默认币种相同时不换算
+func convertCheckoutMoney(money float64, providerCurrency string, requested string) (float64, string, error) {
+	providerCurrency = strings.ToUpper(strings.TrimSpace(providerCurrency))
+	requested = strings.ToUpper(strings.TrimSpace(requested))
+	if requested == "" || requested == providerCurrency {
+		return money, providerCurrency, nil
+	}
+	converted, err := operation_setting.ConvertCurrency(money, providerCurrency, requested)
+	if err != nil {
+		return 0, "", err
+	}
+	return converted, requested, nil
+}
+
+// requireProviderCurrency 校验只能按固定币种收款的支付渠道（易支付、Creem 产品），
+// requested 为空或与渠道币种相同时通过
+func requireProviderCurrency(providerCurrency string, requested string) error {
+	requested = strings.TrimSpace(requested)
+	if requested == "" || strings.EqualFold(requested, providerCurrency) {
+		return nil
+	}
+	return fmt.Errorf("该支付方式仅支持 %s 结算", strings.ToUpper(providerCurrency))
+}
+
+// getEpayCurrency 返回易支付的结算币种
+func getEpayCurrency() string {
+	if currency := operation_setting.GetCurrencySetting().EpayCurrency; currency != "" {
+		return strings.ToUpper(currency)
+	}
+	return "CNY"
+}
+
+// formatCheckoutMoney 按币种小数位格式化金额，未配置的币种保留两位小数
+func formatCheckoutMoney(money float64, currency string) string {
+	decimals := 2
+	if rate, ok := operation_setting.GetCurrencyRate(currency); ok {
+		decimals = rate.Decimals
+	}
+	return strconv.FormatFloat(money, 'f', decimals, 64)
+}
+
 func getMinTopup() int64 {
 	minTopup := operation_setting.MinTopUp
 	if operation_setting.GetQuotaDisplayType() == operation_setting.QuotaDisplayTypeTokens {
@@ -197,6 +260,10 @@ func RequestEpay(c *gin.Context) {
 		c.JSON(http.StatusOK, gin.H{"message": "error", "data": fmt.Sprintf("充值数量不能小于 %d", getMinTopup())})
 		return
 	}
+	if err := requireProviderCurrency(getEpayCurrency(), req.Currency); err != nil {
+		c.JSON(http.StatusOK, gin.H{"message": "error", "data": err.Error()})
+		return
+	}
 
 	id := c.GetInt("id")
 	group, err := model.GetUserGroup(id, true)
@@ -215,6 +282,19 @@ func RequestEpay(c *gin.Context) {
 		return
 	}
 
+	couponUsage := model.CouponUsage{UserId: id, OrderType: model.CouponScopeTopUp, PaymentMethod: req.PaymentMethod}
+	coupon, err := resolveCheckoutCoupon(req.CouponCode, couponUsage, true)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{"message": "error", "data": err.Error()})
+		return
+	}
+	originalMoney := payMoney
+	payMoney, discountMoney := coupon.ApplyToMoney(payMoney)
+	if payMoney < 0.01 {
+		c.JSON(http.StatusOK, gin.H{"message": "error", "data": "充值金额过低"})
+		return
+	}
+
 	callBackAddress := service.GetCallbackAddress()
 	returnUrl, _ := url.Parse(paymentReturnPath("/usage-logs"))
 	notifyUrl, _ := url.Parse(callBackAddress + "/api/user/epay/notify")
@@ -245,6 +325,12 @@ func RequestEpay(c *gin.Context) {
 		dQuotaPerUnit := decimal.NewFromFloat(common.QuotaPerUnit)
 		amount = dAmount.Div(dQuotaPerUnit).IntPart()
 	}
+	if coupon != nil {
+		if err := model.ReserveCoupon(coupon, couponUsage, tradeNo, originalMoney, discountMoney); err != nil {
+			c.JSON(http.StatusOK, gin.H{"message": "error", "data": err.Error()})
+			return
+		}
+	}
 	topUp := &model.TopUp{
 		UserId:          id,
 		Amount:          amount,
@@ -254,10 +340,16 @@ func RequestEpay(c *gin.Context) {
 		PaymentProvider: model.PaymentProviderEpay,
 		CreateTime:      time.Now().Unix(),
 		Status:          common.TopUpStatusPending,
+		DiscountMoney:   discountMoney,
+		Currency:        getEpayCurrency(),
+	}
+	if coupon != nil {
+		topUp.CouponCode = coupon.Code
 	}
 	err = topUp.Insert()
 	if err != nil {
 		logger.LogError(c.Request.Context(), fmt.Sprintf("易支付 创建充值订单失败 user_id=%d trade_no=%s payment_method=%s amount=%d error=%q", id, tradeNo, req.PaymentMethod, req.Amount, err.Error()))
+		_ = model.ReleaseCouponRedemption(tradeNo)
 		c.JSON(http.StatusOK, gin.H{"message": "error", "data": "创建订单失败"})
 		return
 	}
@@ -373,38 +465,20 @@ func EpayNotify(c *gin.Context) {
 	if verifyInfo.TradeStatus == epay.StatusTradeSuccess {
 		LockOrder(verifyInfo.ServiceTradeNo)
 		defer UnlockOrder(verifyInfo.ServiceTradeNo)
-		topUp := model.GetTopUpByTradeNo(verifyInfo.ServiceTradeNo)
-		if topUp == nil {
-			logger.LogWarn(c.Request.Context(), fmt.Sprintf("易支付 回调订单不存在 trade_no=%s callback_type=%s client_ip=%s verify_info=%q", verifyInfo.ServiceTradeNo, verifyInfo.Type, c.ClientIP(), common.GetJsonString(verifyInfo)))
-			return
-		}
-		if topUp.PaymentProvider != model.PaymentProviderEpay {
-			logger.LogWarn(c.Request.Context(), fmt.Sprintf("易支付 订单支付网关不匹配 trade_no=%s order_provider=%s callback_type=%s client_ip=%s", verifyInfo.ServiceTradeNo, topUp.PaymentProvider, verifyInfo.Type, c.ClientIP()))
-			return
-		}
-		if topUp.Status == common.TopUpStatusPending {
-			if topUp.PaymentMethod != verifyInfo.Type {
-				logger.LogInfo(c.Request.Context(), fmt.Sprintf("易支付 实际支付方式与订单不同 trade_no=%s order_payment_method=%s actual_type=%s client_ip=%s", verifyInfo.ServiceTradeNo, topUp.PaymentMethod, verifyInfo.Type, c.ClientIP()))
-				topUp.PaymentMethod = verifyInfo.Type
-			}
-			topUp.Status = common.TopUpStatusSuccess
-			err := topUp.Update()
-			if err != nil {
-				logger.LogError(c.Request.Context(), fmt.Sprintf("易支付 更新充值订单失败 trade_no=%s user_id=%d client_ip=%s error=%q topup=%q", topUp.TradeNo, topUp.UserId, c.ClientIP(), err.Error(), common.GetJsonString(topUp)))
-				return
-			}
-			//user, _ := model.GetUserById(topUp.UserId, false)
-			//user.Quota += topUp.Amount * 500000
-			dAmount := decimal.NewFromInt(int64(topUp.Amount))
-			dQuotaPerUnit := decimal.NewFromFloat(common.QuotaPerUnit)
-			quotaToAdd := int(dAmount.Mul(dQuotaPerUnit).IntPart())
-			err = model.IncreaseUserQuota(topUp.UserId, quotaToAdd, true)
-			if err != nil {
-				logger.LogError(c.Request.Context(), fmt.Sprintf("易支付 更新用户额度失败 trade_no=%s user_id=%d client_ip=%s quota_to_add=%d error=%q topup=%q", topUp.TradeNo, topUp.UserId, c.ClientIP(), quotaToAdd, err.Error(), common.GetJsonString(topUp)))
+		// 订单状态、用户额度与优惠码赠送在同一事务中提交
+		topUp, quotaToAdd, completed, err := model.RechargeEpay(verifyInfo.ServiceTradeNo, verifyInfo.Type)
+		if err != nil {
+			if errors.Is(err, model.ErrPaymentMethodMismatch) {
+				logger.LogWarn(c.Request.Context(), fmt.Sprintf("易支付 订单支付网关不匹配 trade_no=%s callback_type=%s client_ip=%s", verifyInfo.ServiceTradeNo, verifyInfo.Type, c.ClientIP()))
 				return
 			}
+			logger.LogError(c.Request.Context(), fmt.Sprintf("易支付 充值入账失败 trade_no=%s callback_type=%s client_ip=%s error=%q verify_info=%q", verifyInfo.ServiceTradeNo, verifyInfo.Type, c.ClientIP(), err.Error(), common.GetJsonString(verifyInfo)))
+			return
+		}
+		if completed {
 			logger.LogInfo(c.Request.Context(), fmt.Sprintf("易支付 充值成功 trade_no=%s user_id=%d client_ip=%s quota_to_add=%d money=%.2f topup=%q", topUp.TradeNo, topUp.UserId, c.ClientIP(), quotaToAdd, topUp.Money, common.GetJsonString(topUp)))
 			model.RecordTopupLog(topUp.UserId, fmt.Sprintf("使用在线充值成功，充值金额: %v，支付金额：%f", logger.LogQuota(quotaToAdd), topUp.Money), c.ClientIP(), topUp.PaymentMethod, "epay")
+			service.IssueTopUpInvoiceAsync(topUp.TradeNo)
 		}
 	} else {
 		logger.LogInfo(c.Request.Context(), fmt.Sprintf("易支付 webhook 忽略事件 trade_no=%s callback_type=%s trade_status=%s client_ip=%s verify_info=%q", verifyInfo.ServiceTradeNo, verifyInfo.Type, verifyInfo.TradeStatus, c.ClientIP(), common.GetJsonString(verifyInfo)))
@@ -423,6 +497,10 @@ func RequestAmount(c *gin.Context) {
 		c.JSON(http.StatusOK, gin.H{"message": "error", "data": fmt.Sprintf("充值数量不能小于 %d", getMinTopup())})
 		return
 	}
+	if err := requireProviderCurrency(getEpayCurrency(), req.Currency); err != nil {
+		c.JSON(http.StatusOK, gin.H{"message": "error", "data": err.Error()})
+		return
+	}
 	id := c.GetInt("id")
 	group, err := model.GetUserGroup(id, true)
 	if err != nil {
@@ -507,5 +585,6 @@ func AdminCompleteTopUp(c *gin.Context) {
 		common.ApiError(c, err)
 		return
 	}
+	service.IssueTopUpInvoiceAsync(req.TradeNo)
 	common.ApiSuccess(c, nil)
 }
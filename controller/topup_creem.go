@@ -12,9 +12,12 @@ import (
 	"github.com/QuantumNous/new-api/common"
 	"github.com/QuantumNous/new-api/logger"
 	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/service"
 	"github.com/QuantumNous/new-api/setting"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
 	"io"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -50,6 +53,8 @@ func verifyCreemSignature(payload string, signature string, secret string) bool
 type CreemPayRequest struct {
 	ProductId     string `json:"product_id"`
 	PaymentMethod string `json:"payment_method"`
+	CouponCode    string `json:"coupon_code"` // Creem 产品价格固定，仅支持赠送类优惠码
+	Currency      string `json:"currency"`    // Creem 按产品配置的币种收款，只能为空或与产品币种一致
 }
 
 type CreemProduct struct {
@@ -63,6 +68,14 @@ type CreemProduct struct {
 type CreemAdaptor struct {
 }
 
+// creemProductCurrency 返回产品的收款币种，未配置时为 USD
+func creemProductCurrency(product *CreemProduct) string {
+	if currency := strings.TrimSpace(product.Currency); currency != "" {
+		return strings.ToUpper(currency)
+	}
+	return operation_setting.CurrencyUSD
+}
+
 func (*CreemAdaptor) RequestPay(c *gin.Context, req *CreemPayRequest) {
 	if req.PaymentMethod != model.PaymentMethodCreem {
 		c.JSON(http.StatusOK, gin.H{"message": "error", "data": "不支持的支付渠道"})
@@ -96,28 +109,51 @@ func (*CreemAdaptor) RequestPay(c *gin.Context, req *CreemPayRequest) {
 		c.JSON(http.StatusOK, gin.H{"message": "error", "data": "产品不存在"})
 		return
 	}
+	if err := requireProviderCurrency(creemProductCurrency(selectedProduct), req.Currency); err != nil {
+		c.JSON(http.StatusOK, gin.H{"message": "error", "data": err.Error()})
+		return
+	}
 
 	id := c.GetInt("id")
 	user, _ := model.GetUserById(id, false)
 
+	couponUsage := model.CouponUsage{UserId: id, OrderType: model.CouponScopeTopUp, PaymentMethod: model.PaymentMethodCreem}
+	coupon, err := resolveCheckoutCoupon(req.CouponCode, couponUsage, false)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{"message": "error", "data": err.Error()})
+		return
+	}
+
 	// 生成唯一的订单引用ID
 	reference := fmt.Sprintf("creem-api-ref-%d-%d-%s", user.Id, time.Now().UnixMilli(), randstr.String(4))
 	referenceId := "ref_" + common.Sha1([]byte(reference))
 
+	if coupon != nil {
+		if err := model.ReserveCoupon(coupon, couponUsage, referenceId, selectedProduct.Price, 0); err != nil {
+			c.JSON(http.StatusOK, gin.H{"message": "error", "data": err.Error()})
+			return
+		}
+	}
+
 	// 先创建订单记录，使用产品配置的金额和充值额度
 	topUp := &model.TopUp{
 		UserId:          id,
 		Amount:          selectedProduct.Quota, // 充值额度
 		Money:           selectedProduct.Price, // 支付金额
+		Currency:        creemProductCurrency(selectedProduct),
 		TradeNo:         referenceId,
 		PaymentMethod:   model.PaymentMethodCreem,
 		PaymentProvider: model.PaymentProviderCreem,
 		CreateTime:      time.Now().Unix(),
 		Status:          common.TopUpStatusPending,
 	}
+	if coupon != nil {
+		topUp.CouponCode = coupon.Code
+	}
 	err = topUp.Insert()
 	if err != nil {
 		logger.LogError(c.Request.Context(), fmt.Sprintf("Creem 创建充值订单失败 user_id=%d trade_no=%s product_id=%s error=%q", id, referenceId, selectedProduct.ProductId, err.Error()))
+		_ = model.ReleaseCouponRedemption(referenceId)
 		c.JSON(http.StatusOK, gin.H{"message": "error", "data": "创建订单失败"})
 		return
 	}
@@ -304,6 +340,7 @@ func handleCheckoutCompleted(c *gin.Context, event *CreemWebhookEvent) {
 	defer UnlockOrder(referenceId)
 	if err := model.CompleteSubscriptionOrder(referenceId, common.GetJsonString(event), model.PaymentProviderCreem, ""); err == nil {
 		logger.LogInfo(c.Request.Context(), fmt.Sprintf("Creem 订阅订单处理成功 trade_no=%s creem_order_id=%s", referenceId, event.Object.Order.Id))
+		service.IssueSubscriptionOrderInvoiceAsync(referenceId)
 		c.Status(http.StatusOK)
 		return
 	} else if err != nil && !errors.Is(err, model.ErrSubscriptionOrderNotFound) {
@@ -354,6 +391,7 @@ func handleCheckoutCompleted(c *gin.Context, event *CreemWebhookEvent) {
 		return
 	}
 
+	service.IssueTopUpInvoiceAsync(referenceId)
 	logger.LogInfo(c.Request.Context(), fmt.Sprintf("Creem 充值成功 trade_no=%s creem_order_id=%s quota=%d money=%.2f client_ip=%s", referenceId, event.Object.Order.Id, topUp.Amount, topUp.Money, c.ClientIP()))
 	c.Status(http.StatusOK)
 }
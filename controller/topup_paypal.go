@@ -0,0 +1,511 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/service"
+	"github.com/QuantumNous/new-api/setting"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+
+	"github.com/gin-gonic/gin"
+	"github.com/thanhpk/randstr"
+)
+
+var payPalAdaptor = &PayPalAdaptor{}
+
+func init() {
+	registerPaymentProvider(payPalAdaptor)
+}
+
+// PayPalPayRequest 为 PayPal 充值下单请求
+type PayPalPayRequest struct {
+	Amount        int64  `json:"amount"`
+	PaymentMethod string `json:"payment_method"`
+	CouponCode    string `json:"coupon_code"`
+	Currency      string `json:"currency"` // 支付币种，为空时使用 PayPalCurrency
+}
+
+// PayPalAdaptor 实现 PaymentProvider，使用 PayPal Checkout（Orders v2）完成一次性扣款
+type PayPalAdaptor struct {
+}
+
+func (*PayPalAdaptor) Name() string {
+	return model.PaymentProviderPayPal
+}
+
+func (*PayPalAdaptor) TopUpEnabled() bool {
+	return isPayPalTopUpEnabled()
+}
+
+func (*PayPalAdaptor) WebhookConfigured() bool {
+	return isPayPalWebhookConfigured()
+}
+
+func (*PayPalAdaptor) WebhookEnabled() bool {
+	return isPayPalWebhookEnabled()
+}
+
+func (*PayPalAdaptor) TopUpMethod() map[string]string {
+	return map[string]string{
+		"name":      "PayPal",
+		"type":      model.PaymentMethodPayPal,
+		"color":     "#003087",
+		"min_topup": strconv.Itoa(setting.PayPalMinTopUp),
+	}
+}
+
+func (*PayPalAdaptor) RequestAmount(c *gin.Context) {
+	var req PayPalPayRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusOK, gin.H{"message": "error", "data": "参数错误"})
+		return
+	}
+	if req.Amount < getPayPalMinTopup() {
+		c.JSON(http.StatusOK, gin.H{"message": "error", "data": fmt.Sprintf("充值数量不能小于 %d", getPayPalMinTopup())})
+		return
+	}
+	group, err := model.GetUserGroup(c.GetInt("id"), true)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{"message": "error", "data": "获取用户分组失败"})
+		return
+	}
+	payMoney, currency, err := convertCheckoutMoney(getPayPalPayMoney(float64(req.Amount), group), getPayPalCurrency(), req.Currency)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{"message": "error", "data": err.Error()})
+		return
+	}
+	if payMoney <= 0.01 {
+		c.JSON(http.StatusOK, gin.H{"message": "error", "data": "充值金额过低"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "success", "data": formatCheckoutMoney(payMoney, currency)})
+}
+
+func (*PayPalAdaptor) RequestPay(c *gin.Context) {
+	if !isPayPalTopUpEnabled() {
+		c.JSON(http.StatusOK, gin.H{"message": "error", "data": "PayPal 支付未启用"})
+		return
+	}
+	var req PayPalPayRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusOK, gin.H{"message": "error", "data": "参数错误"})
+		return
+	}
+	if req.PaymentMethod != "" && req.PaymentMethod != model.PaymentMethodPayPal {
+		c.JSON(http.StatusOK, gin.H{"message": "error", "data": "不支持的支付渠道"})
+		return
+	}
+	if req.Amount < getPayPalMinTopup() {
+		c.JSON(http.StatusOK, gin.H{"message": "error", "data": fmt.Sprintf("充值数量不能小于 %d", getPayPalMinTopup())})
+		return
+	}
+
+	id := c.GetInt("id")
+	group, err := model.GetUserGroup(id, true)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{"message": "error", "data": "获取用户分组失败"})
+		return
+	}
+	payMoney, currency, err := convertCheckoutMoney(getPayPalPayMoney(float64(req.Amount), group), getPayPalCurrency(), req.Currency)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{"message": "error", "data": err.Error()})
+		return
+	}
+	couponUsage := model.CouponUsage{UserId: id, OrderType: model.CouponScopeTopUp, PaymentMethod: model.PaymentMethodPayPal}
+	coupon, err := resolveCheckoutCoupon(req.CouponCode, couponUsage, true)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{"message": "error", "data": err.Error()})
+		return
+	}
+	originalMoney := payMoney
+	payMoney, discountMoney := coupon.ApplyToMoney(payMoney)
+	if payMoney < 0.01 {
+		c.JSON(http.StatusOK, gin.H{"message": "error", "data": "充值金额过低"})
+		return
+	}
+
+	tradeNo := fmt.Sprintf("PAYPAL-%d-%d-%s", id, time.Now().UnixMilli(), randstr.String(6))
+
+	// Token 模式下归一化 Amount，CompleteProviderTopUp 按 Amount × QuotaPerUnit 入账
+	amount := req.Amount
+	if operation_setting.GetQuotaDisplayType() == operation_setting.QuotaDisplayTypeTokens {
+		amount = int64(float64(req.Amount) / common.QuotaPerUnit)
+		if amount < 1 {
+			amount = 1
+		}
+	}
+
+	if coupon != nil {
+		if err := model.ReserveCoupon(coupon, couponUsage, tradeNo, originalMoney, discountMoney); err != nil {
+			c.JSON(http.StatusOK, gin.H{"message": "error", "data": err.Error()})
+			return
+		}
+	}
+
+	topUp := &model.TopUp{
+		UserId:          id,
+		Amount:          amount,
+		Money:           payMoney,
+		TradeNo:         tradeNo,
+		PaymentMethod:   model.PaymentMethodPayPal,
+		PaymentProvider: model.PaymentProviderPayPal,
+		CreateTime:      time.Now().Unix(),
+		Status:          common.TopUpStatusPending,
+		DiscountMoney:   discountMoney,
+		Currency:        currency,
+	}
+	if coupon != nil {
+		topUp.CouponCode = coupon.Code
+	}
+	if err := topUp.Insert(); err != nil {
+		logger.LogError(c.Request.Context(), fmt.Sprintf("PayPal 创建充值订单失败 user_id=%d trade_no=%s amount=%d error=%q", id, tradeNo, req.Amount, err.Error()))
+		_ = model.ReleaseCouponRedemption(tradeNo)
+		c.JSON(http.StatusOK, gin.H{"message": "error", "data": "创建订单失败"})
+		return
+	}
+
+	order, err := service.CreatePayPalOrder(c.Request.Context(), service.PayPalCreateOrderParams{
+		TradeNo:     tradeNo,
+		Description: fmt.Sprintf("Recharge %d credits", req.Amount),
+		Amount:      formatCheckoutMoney(payMoney, currency),
+		Currency:    currency,
+		ReturnURL:   payPalReturnURL(),
+		CancelURL:   paymentReturnPath("/wallet"),
+	})
+	if err != nil || order.ApproveURL() == "" {
+		if err == nil {
+			err = errors.New("PayPal 未返回支付链接")
+		}
+		logger.LogError(c.Request.Context(), fmt.Sprintf("PayPal 创建订单失败 user_id=%d trade_no=%s error=%q", id, tradeNo, err.Error()))
+		_ = model.UpdatePendingTopUpStatus(tradeNo, model.PaymentProviderPayPal, common.TopUpStatusFailed)
+		c.JSON(http.StatusOK, gin.H{"message": "error", "data": "拉起支付失败"})
+		return
+	}
+
+	logger.LogInfo(c.Request.Context(), fmt.Sprintf("PayPal 充值订单创建成功 user_id=%d trade_no=%s paypal_order_id=%s amount=%d money=%.2f currency=%s", id, tradeNo, order.Id, req.Amount, payMoney, currency))
+	c.JSON(http.StatusOK, gin.H{
+		"message": "success",
+		"data": gin.H{
+			"pay_link": order.ApproveURL(),
+			"order_id": tradeNo,
+		},
+	})
+}
+
+func (*PayPalAdaptor) RequestSubscriptionPay(c *gin.Context) {
+	SubscriptionRequestPayPalPay(c)
+}
+
+func (*PayPalAdaptor) Webhook(c *gin.Context) {
+	PayPalWebhook(c)
+}
+
+func RequestPayPalAmount(c *gin.Context) {
+	payPalAdaptor.RequestAmount(c)
+}
+
+func RequestPayPalPay(c *gin.Context) {
+	payPalAdaptor.RequestPay(c)
+}
+
+// PayPalReturn 处理买家确认支付后的跳转：立即扣款并入账，webhook 作为兜底
+func PayPalReturn(c *gin.Context) {
+	redirectURL := paymentReturnPath("/wallet?show_history=true")
+	orderId := c.Query("token")
+	if !isPayPalWebhookEnabled() || orderId == "" {
+		c.Redirect(http.StatusFound, redirectURL)
+		return
+	}
+	if !service.IsValidPayPalResourceId(orderId) {
+		logger.LogWarn(c.Request.Context(), fmt.Sprintf("PayPal 跳转订单号无效 paypal_order_id=%q client_ip=%s", orderId, c.ClientIP()))
+		c.Redirect(http.StatusFound, redirectURL)
+		return
+	}
+
+	order, err := service.CapturePayPalOrder(c.Request.Context(), orderId)
+	if err != nil {
+		logger.LogError(c.Request.Context(), fmt.Sprintf("PayPal 扣款失败 paypal_order_id=%s client_ip=%s error=%q", orderId, c.ClientIP(), err.Error()))
+		c.Redirect(http.StatusFound, redirectURL)
+		return
+	}
+	if capture := order.CompletedCapture(); capture != nil {
+		if capture.CustomId == "" {
+			capture.CustomId = order.CustomId()
+		}
+		if err := completePayPalCapture(c.Request.Context(), capture, c.ClientIP()); err != nil {
+			logger.LogError(c.Request.Context(), fmt.Sprintf("PayPal 订单入账失败 paypal_order_id=%s trade_no=%s error=%q", orderId, capture.CustomId, err.Error()))
+		}
+	} else {
+		logger.LogInfo(c.Request.Context(), fmt.Sprintf("PayPal 扣款未完成，等待 webhook paypal_order_id=%s status=%s", orderId, order.Status))
+	}
+	c.Redirect(http.StatusFound, redirectURL)
+}
+
+func PayPalWebhook(c *gin.Context) {
+	ctx := c.Request.Context()
+	if !isPayPalWebhookEnabled() {
+		logger.LogWarn(ctx, fmt.Sprintf("PayPal webhook 被拒绝 reason=webhook_disabled path=%q client_ip=%s", c.Request.RequestURI, c.ClientIP()))
+		c.AbortWithStatus(http.StatusForbidden)
+		return
+	}
+
+	bodyBytes, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		logger.LogError(ctx, fmt.Sprintf("PayPal webhook 读取请求体失败 path=%q client_ip=%s error=%q", c.Request.RequestURI, c.ClientIP(), err.Error()))
+		c.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+
+	headers := service.PayPalWebhookHeaders{
+		AuthAlgo:         c.GetHeader("PAYPAL-AUTH-ALGO"),
+		CertURL:          c.GetHeader("PAYPAL-CERT-URL"),
+		TransmissionId:   c.GetHeader("PAYPAL-TRANSMISSION-ID"),
+		TransmissionSig:  c.GetHeader("PAYPAL-TRANSMISSION-SIG"),
+		TransmissionTime: c.GetHeader("PAYPAL-TRANSMISSION-TIME"),
+	}
+	logger.LogInfo(ctx, fmt.Sprintf("PayPal webhook 收到请求 path=%q client_ip=%s transmission_id=%s body=%q", c.Request.RequestURI, c.ClientIP(), headers.TransmissionId, string(bodyBytes)))
+
+	verified, err := service.VerifyPayPalWebhookSignature(ctx, headers, bodyBytes)
+	if err != nil {
+		// 验签接口不可用时返回 5xx，让 PayPal 重试
+		logger.LogError(ctx, fmt.Sprintf("PayPal webhook 验签请求失败 path=%q client_ip=%s error=%q", c.Request.RequestURI, c.ClientIP(), err.Error()))
+		c.AbortWithStatus(http.StatusServiceUnavailable)
+		return
+	}
+	if !verified {
+		logger.LogWarn(ctx, fmt.Sprintf("PayPal webhook 验签失败 path=%q client_ip=%s transmission_id=%s", c.Request.RequestURI, c.ClientIP(), headers.TransmissionId))
+		c.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
+
+	var event service.PayPalWebhookEvent
+	if err := common.Unmarshal(bodyBytes, &event); err != nil {
+		logger.LogError(ctx, fmt.Sprintf("PayPal webhook 解析失败 path=%q client_ip=%s error=%q", c.Request.RequestURI, c.ClientIP(), err.Error()))
+		c.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+
+	callerIp := c.ClientIP()
+	logger.LogInfo(ctx, fmt.Sprintf("PayPal webhook 验签成功 event_type=%s event_id=%s client_ip=%s", event.EventType, event.Id, callerIp))
+	switch event.EventType {
+	case "CHECKOUT.ORDER.APPROVED":
+		err = handlePayPalOrderApproved(ctx, &event, callerIp)
+	case "PAYMENT.CAPTURE.COMPLETED":
+		var capture service.PayPalCapture
+		if err = common.Unmarshal(event.Resource, &capture); err == nil {
+			err = completePayPalCapture(ctx, &capture, callerIp)
+		}
+	case "PAYMENT.CAPTURE.DENIED", "PAYMENT.CAPTURE.DECLINED":
+		var capture service.PayPalCapture
+		if err = common.Unmarshal(event.Resource, &capture); err == nil {
+			err = failPayPalOrder(ctx, capture.CustomId)
+		}
+	case "CHECKOUT.ORDER.VOIDED":
+		var order service.PayPalOrder
+		if err = common.Unmarshal(event.Resource, &order); err == nil {
+			err = failPayPalOrder(ctx, order.CustomId())
+		}
+	case "PAYMENT.CAPTURE.REFUNDED", "PAYMENT.CAPTURE.REVERSED":
+		err = handlePayPalRefund(ctx, &event)
+	default:
+		logger.LogInfo(ctx, fmt.Sprintf("PayPal webhook 忽略事件 event_type=%s event_id=%s", event.EventType, event.Id))
+	}
+	if err != nil {
+		logger.LogError(ctx, fmt.Sprintf("PayPal webhook 处理失败 event_type=%s event_id=%s client_ip=%s error=%q", event.EventType, event.Id, callerIp, err.Error()))
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	c.Status(http.StatusOK)
+}
+
+// handlePayPalOrderApproved 在买家确认但未回到站点时由 webhook 完成扣款
+func handlePayPalOrderApproved(ctx context.Context, event *service.PayPalWebhookEvent, callerIp string) error {
+	var approved service.PayPalOrder
+	if err := common.Unmarshal(event.Resource, &approved); err != nil {
+		return err
+	}
+	order, err := service.CapturePayPalOrder(ctx, approved.Id)
+	if err != nil {
+		return err
+	}
+	capture := order.CompletedCapture()
+	if capture == nil {
+		logger.LogInfo(ctx, fmt.Sprintf("PayPal 扣款未完成，等待后续事件 paypal_order_id=%s status=%s", order.Id, order.Status))
+		return nil
+	}
+	if capture.CustomId == "" {
+		capture.CustomId = order.CustomId()
+	}
+	return completePayPalCapture(ctx, capture, callerIp)
+}
+
+// completePayPalCapture 校验扣款金额后完成订阅订单或充值订单，重复调用是幂等的
+func completePayPalCapture(ctx context.Context, capture *service.PayPalCapture, callerIp string) error {
+	tradeNo := capture.CustomId
+	if capture.Status != "COMPLETED" || tradeNo == "" {
+		logger.LogInfo(ctx, fmt.Sprintf("PayPal 扣款未完成或缺少订单号，忽略处理 capture_id=%s status=%s trade_no=%s", capture.Id, capture.Status, tradeNo))
+		return nil
+	}
+	paid, err := strconv.ParseFloat(capture.Amount.Value, 64)
+	if err != nil {
+		return fmt.Errorf("无效的扣款金额 %q", capture.Amount.Value)
+	}
+
+	LockOrder(tradeNo)
+	defer UnlockOrder(tradeNo)
+
+	if order := model.GetSubscriptionOrderByTradeNo(tradeNo); order != nil {
+		if order.Status == common.TopUpStatusPending && math.Abs(order.Money-paid) > 0.01 {
+			return fmt.Errorf("扣款金额 %s %s 与订单金额 %.2f 不一致", capture.Amount.Value, capture.Amount.CurrencyCode, order.Money)
+		}
+		payload := map[string]any{
+			"capture_id": capture.Id,
+			"amount":     capture.Amount.Value,
+			"currency":   capture.Amount.CurrencyCode,
+		}
+		if err := model.CompleteSubscriptionOrder(tradeNo, common.GetJsonString(payload), model.PaymentProviderPayPal, ""); err != nil {
+			return err
+		}
+		logger.LogInfo(ctx, fmt.Sprintf("PayPal 订阅订单处理成功 trade_no=%s capture_id=%s client_ip=%s", tradeNo, capture.Id, callerIp))
+		service.IssueSubscriptionOrderInvoiceAsync(tradeNo)
+		return nil
+	}
+
+	topUp := model.GetTopUpByTradeNo(tradeNo)
+	if topUp == nil {
+		return model.ErrTopUpNotFound
+	}
+	if topUp.Status != common.TopUpStatusPending {
+		logger.LogInfo(ctx, fmt.Sprintf("PayPal 充值订单状态非 pending，忽略处理 trade_no=%s status=%s capture_id=%s", tradeNo, topUp.Status, capture.Id))
+		return nil
+	}
+	currency := topUp.Currency
+	if currency == "" {
+		currency = getPayPalCurrency()
+	}
+	if !strings.EqualFold(capture.Amount.CurrencyCode, currency) {
+		return fmt.Errorf("扣款币种 %s 与订单币种 %s 不一致", capture.Amount.CurrencyCode, currency)
+	}
+	if math.Abs(topUp.Money-paid) > 0.01 {
+		return fmt.Errorf("扣款金额 %s %s 与订单金额 %.2f 不一致", capture.Amount.Value, capture.Amount.CurrencyCode, topUp.Money)
+	}
+	if err := model.CompleteProviderTopUp(tradeNo, model.PaymentProviderPayPal, callerIp); err != nil {
+		return err
+	}
+	service.IssueTopUpInvoiceAsync(tradeNo)
+	logger.LogInfo(ctx, fmt.Sprintf("PayPal 充值成功 trade_no=%s capture_id=%s amount=%s currency=%s client_ip=%s", tradeNo, capture.Id, capture.Amount.Value, capture.Amount.CurrencyCode, callerIp))
+	return nil
+}
+
+func failPayPalOrder(ctx context.Context, tradeNo string) error {
+	if tradeNo == "" {
+		return nil
+	}
+	LockOrder(tradeNo)
+	defer UnlockOrder(tradeNo)
+
+	if err := model.ExpireSubscriptionOrder(tradeNo, model.PaymentProviderPayPal); err == nil {
+		logger.LogInfo(ctx, fmt.Sprintf("PayPal 订阅订单已关闭 trade_no=%s", tradeNo))
+		return nil
+	} else if !errors.Is(err, model.ErrSubscriptionOrderNotFound) {
+		return err
+	}
+
+	err := model.UpdatePendingTopUpStatus(tradeNo, model.PaymentProviderPayPal, common.TopUpStatusFailed)
+	if errors.Is(err, model.ErrTopUpStatusInvalid) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	logger.LogInfo(ctx, fmt.Sprintf("PayPal 充值订单已标记为失败 trade_no=%s", tradeNo))
+	return nil
+}
+
+func handlePayPalRefund(ctx context.Context, event *service.PayPalWebhookEvent) error {
+	var refund service.PayPalRefund
+	if err := common.Unmarshal(event.Resource, &refund); err != nil {
+		return err
+	}
+	tradeNo, err := service.PayPalRefundTradeNo(ctx, &refund)
+	if err != nil {
+		return err
+	}
+	money, err := strconv.ParseFloat(refund.Amount.Value, 64)
+	if err != nil {
+		return fmt.Errorf("无效的退款金额 %q", refund.Amount.Value)
+	}
+	// 撤销（chargeback）事件的资源 id 可能与原扣款相同，拼上事件类型避免与普通退款冲突
+	refundId := refund.Id
+	if event.EventType == "PAYMENT.CAPTURE.REVERSED" {
+		refundId = "reversed:" + refundId
+	}
+
+	LockOrder(tradeNo)
+	defer UnlockOrder(tradeNo)
+	record, err := model.RecordPaymentRefund(model.PaymentRefundParams{
+		Provider: model.PaymentProviderPayPal,
+		RefundId: refundId,
+		TradeNo:  tradeNo,
+		Money:    money,
+		Currency: strings.ToUpper(refund.Amount.CurrencyCode),
+	})
+	if err != nil {
+		return err
+	}
+	if record == nil {
+		logger.LogInfo(ctx, fmt.Sprintf("PayPal 退款已处理过 refund_id=%s trade_no=%s", refundId, tradeNo))
+		return nil
+	}
+	logger.LogInfo(ctx, fmt.Sprintf("PayPal 退款同步成功 refund_id=%s trade_no=%s money=%s currency=%s quota=%d", refundId, tradeNo, refund.Amount.Value, refund.Amount.CurrencyCode, record.Quota))
+	return nil
+}
+
+func payPalReturnURL() string {
+	return strings.TrimRight(service.GetCallbackAddress(), "/") + "/api/paypal/return"
+}
+
+// getPayPalCurrency 返回 PayPalUnitPrice 的计价币种
+func getPayPalCurrency() string {
+	if currency := strings.TrimSpace(setting.PayPalCurrency); currency != "" {
+		return strings.ToUpper(currency)
+	}
+	return operation_setting.CurrencyUSD
+}
+
+func getPayPalPayMoney(amount float64, group string) float64 {
+	originalAmount := amount
+	if operation_setting.GetQuotaDisplayType() == operation_setting.QuotaDisplayTypeTokens {
+		amount = amount / common.QuotaPerUnit
+	}
+	topupGroupRatio := common.GetTopupGroupRatio(group)
+	if topupGroupRatio == 0 {
+		topupGroupRatio = 1
+	}
+	discount := 1.0
+	if ds, ok := operation_setting.GetPaymentSetting().AmountDiscount[int(originalAmount)]; ok {
+		if ds > 0 {
+			discount = ds
+		}
+	}
+	return amount * setting.PayPalUnitPrice * topupGroupRatio * discount
+}
+
+func getPayPalMinTopup() int64 {
+	minTopup := setting.PayPalMinTopUp
+	if operation_setting.GetQuotaDisplayType() == operation_setting.QuotaDisplayTypeTokens {
+		minTopup = minTopup * int(common.QuotaPerUnit)
+	}
+	return int64(minTopup)
+}
@@ -0,0 +1,45 @@
+package controller
+
+import (
+	"context"
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/service"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsValidPayPalResourceId(t *testing.T) {
+	require.True(t, service.IsValidPayPalResourceId("5O190127TN364715T"))
+	for _, id := range []string{"", "5o190127tn364715t", "../../v1/oauth2/token", "ABC/capture", "ABC?x=1", "ABC%2F"} {
+		require.False(t, service.IsValidPayPalResourceId(id), id)
+	}
+}
+
+func TestCompletePayPalCaptureRejectsCurrencyMismatch(t *testing.T) {
+	db := setupModelListControllerTestDB(t)
+	require.NoError(t, db.AutoMigrate(&model.TopUp{}, &model.SubscriptionOrder{}))
+	user := &model.User{Username: "paypal-user", Status: common.UserStatusEnabled}
+	require.NoError(t, db.Create(user).Error)
+	topUp := &model.TopUp{
+		UserId:          user.Id,
+		Amount:          10,
+		Money:           20,
+		Currency:        "EUR",
+		TradeNo:         "PAYPAL-currency",
+		PaymentMethod:   model.PaymentMethodPayPal,
+		PaymentProvider: model.PaymentProviderPayPal,
+		Status:          common.TopUpStatusPending,
+	}
+	require.NoError(t, topUp.Insert())
+
+	capture := &service.PayPalCapture{Id: "CAPTURE1", Status: "COMPLETED", CustomId: topUp.TradeNo}
+	capture.Amount.Value = "20.00"
+	capture.Amount.CurrencyCode = "USD"
+	require.Error(t, completePayPalCapture(context.Background(), capture, "127.0.0.1"))
+
+	stored := model.GetTopUpByTradeNo(topUp.TradeNo)
+	require.NotNil(t, stored)
+	require.Equal(t, common.TopUpStatusPending, stored.Status)
+}
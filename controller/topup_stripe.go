@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"math"
 	"net/http"
 	"strconv"
 	"strings"
@@ -13,12 +14,14 @@ import (
 	"github.com/QuantumNous/new-api/common"
 	"github.com/QuantumNous/new-api/logger"
 	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/service"
 	"github.com/QuantumNous/new-api/setting"
 	"github.com/QuantumNous/new-api/setting/operation_setting"
 
 	"github.com/gin-gonic/gin"
 	"github.com/stripe/stripe-go/v81"
 	"github.com/stripe/stripe-go/v81/checkout/session"
+	stripecoupon "github.com/stripe/stripe-go/v81/coupon"
 	"github.com/stripe/stripe-go/v81/webhook"
 	"github.com/thanhpk/randstr"
 )
@@ -37,6 +40,11 @@ type StripePayRequest struct {
 	// CancelURL is the optional custom URL to redirect when payment is canceled.
 	// If empty, defaults to the server's console topup page.
 	CancelURL string `json:"cancel_url,omitempty"`
+	// CouponCode is the optional promotion code applied to this order.
+	CouponCode string `json:"coupon_code,omitempty"`
+	// Currency is the optional checkout currency. If empty, the configured
+	// Stripe price currency is used.
+	Currency string `json:"currency,omitempty"`
 }
 
 type StripeAdaptor struct {
@@ -53,12 +61,16 @@ func (*StripeAdaptor) RequestAmount(c *gin.Context, req *StripePayRequest) {
 		c.JSON(http.StatusOK, gin.H{"message": "error", "data": "获取用户分组失败"})
 		return
 	}
-	payMoney := getStripePayMoney(float64(req.Amount), group)
+	payMoney, currency, err := convertCheckoutMoney(getStripePayMoney(float64(req.Amount), group), getStripeCurrency(), req.Currency)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{"message": "error", "data": err.Error()})
+		return
+	}
 	if payMoney <= 0.01 {
 		c.JSON(http.StatusOK, gin.H{"message": "error", "data": "充值金额过低"})
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"message": "success", "data": strconv.FormatFloat(payMoney, 'f', 2, 64)})
+	c.JSON(http.StatusOK, gin.H{"message": "success", "data": formatCheckoutMoney(payMoney, currency)})
 }
 
 func (*StripeAdaptor) RequestPay(c *gin.Context, req *StripePayRequest) {
@@ -88,13 +100,54 @@ func (*StripeAdaptor) RequestPay(c *gin.Context, req *StripePayRequest) {
 	id := c.GetInt("id")
 	user, _ := model.GetUserById(id, false)
 	chargedMoney := GetChargedAmount(float64(req.Amount), *user)
+	lineItem := &stripe.CheckoutSessionLineItemParams{
+		Price:    stripe.String(setting.StripePriceId),
+		Quantity: stripe.Int64(req.Amount),
+	}
+	var payMoney float64
+	payCurrency := ""
+	if req.Currency != "" && !strings.EqualFold(req.Currency, getStripeCurrency()) {
+		// 非默认币种无法使用预设的 Stripe Price，按换算后的总金额动态定价；
+		// Money 仍记录默认币种下的数量，实收金额单独记录，避免入账额度按外币金额放大
+		var err error
+		payMoney, payCurrency, err = convertCheckoutMoney(getStripePayMoney(float64(req.Amount), user.Group), getStripeCurrency(), req.Currency)
+		if err != nil {
+			c.JSON(http.StatusOK, gin.H{"message": "error", "data": err.Error()})
+			return
+		}
+		lineItem, err = stripeTopUpPriceLineItem(req.Amount, payMoney, payCurrency)
+		if err != nil {
+			c.JSON(http.StatusOK, gin.H{"message": "error", "data": err.Error()})
+			return
+		}
+	}
+
+	couponUsage := model.CouponUsage{UserId: id, OrderType: model.CouponScopeTopUp, PaymentMethod: model.PaymentMethodStripe}
+	coupon, err := resolveCheckoutCoupon(req.CouponCode, couponUsage, true)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{"message": "error", "data": err.Error()})
+		return
+	}
+	originalMoney := chargedMoney
+	chargedMoney, discountMoney := coupon.ApplyToMoney(chargedMoney)
+	if payCurrency != "" && originalMoney > 0 {
+		payMoney = stripeDiscountedPayMoney(payMoney, payCurrency, chargedMoney/originalMoney)
+	}
 
 	reference := fmt.Sprintf("new-api-ref-%d-%d-%s", user.Id, time.Now().UnixMilli(), randstr.String(4))
 	referenceId := "ref_" + common.Sha1([]byte(reference))
 
-	payLink, err := genStripeLink(referenceId, user.StripeCustomer, user.Email, req.Amount, req.SuccessURL, req.CancelURL)
+	if coupon != nil {
+		if err := model.ReserveCoupon(coupon, couponUsage, referenceId, originalMoney, discountMoney); err != nil {
+			c.JSON(http.StatusOK, gin.H{"message": "error", "data": err.Error()})
+			return
+		}
+	}
+
+	payLink, err := genStripeLink(referenceId, user.StripeCustomer, user.Email, lineItem, req.SuccessURL, req.CancelURL, stripeDiscountPercent(coupon))
 	if err != nil {
 		logger.LogError(c.Request.Context(), fmt.Sprintf("Stripe 创建 Checkout Session 失败 user_id=%d trade_no=%s amount=%d error=%q", id, referenceId, req.Amount, err.Error()))
+		_ = model.ReleaseCouponRedemption(referenceId)
 		c.JSON(http.StatusOK, gin.H{"message": "error", "data": "拉起支付失败"})
 		return
 	}
@@ -108,10 +161,17 @@ func (*StripeAdaptor) RequestPay(c *gin.Context, req *StripePayRequest) {
 		PaymentProvider: model.PaymentProviderStripe,
 		CreateTime:      time.Now().Unix(),
 		Status:          common.TopUpStatusPending,
+		DiscountMoney:   discountMoney,
+		PayMoney:        payMoney,
+		PayCurrency:     payCurrency,
+	}
+	if coupon != nil {
+		topUp.CouponCode = coupon.Code
 	}
 	err = topUp.Insert()
 	if err != nil {
 		logger.LogError(c.Request.Context(), fmt.Sprintf("Stripe 创建充值订单失败 user_id=%d trade_no=%s amount=%d error=%q", id, referenceId, req.Amount, err.Error()))
+		_ = model.ReleaseCouponRedemption(referenceId)
 		c.JSON(http.StatusOK, gin.H{"message": "error", "data": "创建订单失败"})
 		return
 	}
@@ -182,6 +242,8 @@ func StripeWebhook(c *gin.Context) {
 		sessionAsyncPaymentSucceeded(ctx, event, callerIp)
 	case stripe.EventTypeCheckoutSessionAsyncPaymentFailed:
 		sessionAsyncPaymentFailed(ctx, event, callerIp)
+	case stripe.EventTypePaymentIntentSucceeded, stripe.EventTypePaymentIntentPaymentFailed:
+		autoRechargePaymentIntentEvent(ctx, event, callerIp)
 	default:
 		logger.LogInfo(ctx, fmt.Sprintf("Stripe webhook 忽略事件 event_type=%s client_ip=%s", string(event.Type), callerIp))
 	}
@@ -190,6 +252,10 @@ func StripeWebhook(c *gin.Context) {
 }
 
 func sessionCompleted(ctx context.Context, event stripe.Event, callerIp string) {
+	if event.GetObjectValue("mode") == string(stripe.CheckoutSessionModeSetup) {
+		setupSessionCompleted(ctx, event, callerIp)
+		return
+	}
 	customerId := event.GetObjectValue("customer")
 	referenceId := event.GetObjectValue("client_reference_id")
 	status := event.GetObjectValue("status")
@@ -272,6 +338,7 @@ func fulfillOrder(ctx context.Context, event stripe.Event, referenceId string, c
 	}
 	if err := model.CompleteSubscriptionOrder(referenceId, common.GetJsonString(payload), model.PaymentProviderStripe, ""); err == nil {
 		logger.LogInfo(ctx, fmt.Sprintf("Stripe 订阅订单处理成功 trade_no=%s event_type=%s client_ip=%s", referenceId, string(event.Type), callerIp))
+		service.IssueSubscriptionOrderInvoiceAsync(referenceId)
 		return
 	} else if err != nil && !errors.Is(err, model.ErrSubscriptionOrderNotFound) {
 		logger.LogError(ctx, fmt.Sprintf("Stripe 订阅订单处理失败 trade_no=%s event_type=%s client_ip=%s error=%q", referenceId, string(event.Type), callerIp, err.Error()))
@@ -284,6 +351,8 @@ func fulfillOrder(ctx context.Context, event stripe.Event, referenceId string, c
 		return
 	}
 
+	service.IssueTopUpInvoiceAsync(referenceId)
+
 	total, _ := strconv.ParseFloat(event.GetObjectValue("amount_total"), 64)
 	currency := strings.ToUpper(event.GetObjectValue("currency"))
 	logger.LogInfo(ctx, fmt.Sprintf("Stripe 充值成功 trade_no=%s amount_total=%.2f currency=%s event_type=%s client_ip=%s", referenceId, total/100, currency, string(event.Type), callerIp))
@@ -333,12 +402,13 @@ func sessionExpired(ctx context.Context, event stripe.Event) {
 //   - referenceId: unique reference identifier for the transaction
 //   - customerId: existing Stripe customer ID (empty string if new customer)
 //   - email: customer email address for new customer creation
-//   - amount: quantity of units to purchase
+//   - lineItem: the checkout line item (preset price × quantity, or a converted price)
 //   - successURL: custom URL to redirect after successful payment (empty for default)
 //   - cancelURL: custom URL to redirect when payment is canceled (empty for default)
+//   - discountPercent: percentage off from a local coupon (0 for none)
 //
 // Returns the checkout session URL or an error if the session creation fails.
-func genStripeLink(referenceId string, customerId string, email string, amount int64, successURL string, cancelURL string) (string, error) {
+func genStripeLink(referenceId string, customerId string, email string, lineItem *stripe.CheckoutSessionLineItemParams, successURL string, cancelURL string, discountPercent float64) (string, error) {
 	if !strings.HasPrefix(setting.StripeApiSecret, "sk_") && !strings.HasPrefix(setting.StripeApiSecret, "rk_") {
 		return "", fmt.Errorf("无效的Stripe API密钥")
 	}
@@ -354,18 +424,16 @@ func genStripeLink(referenceId string, customerId string, email string, amount i
 	}
 
 	params := &stripe.CheckoutSessionParams{
-		ClientReferenceID: stripe.String(referenceId),
-		SuccessURL:        stripe.String(successURL),
-		CancelURL:         stripe.String(cancelURL),
-		LineItems: []*stripe.CheckoutSessionLineItemParams{
-			{
-				Price:    stripe.String(setting.StripePriceId),
-				Quantity: stripe.Int64(amount),
-			},
-		},
+		ClientReferenceID:   stripe.String(referenceId),
+		SuccessURL:          stripe.String(successURL),
+		CancelURL:           stripe.String(cancelURL),
+		LineItems:           []*stripe.CheckoutSessionLineItemParams{lineItem},
 		Mode:                stripe.String(string(stripe.CheckoutSessionModePayment)),
 		AllowPromotionCodes: stripe.Bool(setting.StripePromotionCodesEnabled),
 	}
+	if err := applyStripeCouponDiscount(params, discountPercent); err != nil {
+		return "", err
+	}
 
 	if "" == customerId {
 		if "" != email {
@@ -385,6 +453,34 @@ func genStripeLink(referenceId string, customerId string, email string, amount i
 	return result.URL, nil
 }
 
+func stripeDiscountPercent(coupon *model.Coupon) float64 {
+	if coupon == nil || coupon.Type != model.CouponTypePercentOff {
+		return 0
+	}
+	return coupon.Percent
+}
+
+// applyStripeCouponDiscount 为本地折扣优惠码创建一次性的 Stripe Coupon 并附加到 Checkout Session。
+// Stripe 不允许同时设置 discounts 与 allow_promotion_codes，使用本地优惠码时关闭后者。
+func applyStripeCouponDiscount(params *stripe.CheckoutSessionParams, percent float64) error {
+	if percent <= 0 {
+		return nil
+	}
+	created, err := stripecoupon.New(&stripe.CouponParams{
+		PercentOff:     stripe.Float64(percent),
+		Duration:       stripe.String(string(stripe.CouponDurationOnce)),
+		MaxRedemptions: stripe.Int64(1),
+	})
+	if err != nil {
+		return err
+	}
+	params.AllowPromotionCodes = nil
+	params.Discounts = []*stripe.CheckoutSessionDiscountParams{
+		{Coupon: stripe.String(created.ID)},
+	}
+	return nil
+}
+
 func GetChargedAmount(count float64, user model.User) float64 {
 	topUpGroupRatio := common.GetTopupGroupRatio(user.Group)
 	if topUpGroupRatio == 0 {
@@ -415,6 +511,45 @@ func getStripePayMoney(amount float64, group string) float64 {
 	return payMoney
 }
 
+// getStripeCurrency 返回 StripePriceId / StripeUnitPrice 的计价币种
+func getStripeCurrency() string {
+	if currency := operation_setting.GetCurrencySetting().StripeCurrency; currency != "" {
+		return strings.ToUpper(currency)
+	}
+	return operation_setting.CurrencyUSD
+}
+
+// stripeTopUpPriceLineItem 以换算后的总金额构造一次性价格的 Checkout 行项目
+func stripeTopUpPriceLineItem(amount int64, payMoney float64, currency string) (*stripe.CheckoutSessionLineItemParams, error) {
+	rate, ok := operation_setting.GetCurrencyRate(currency)
+	if !ok {
+		return nil, operation_setting.ErrCurrencyNotSupported
+	}
+	unitAmount := int64(math.Round(payMoney * math.Pow10(rate.Decimals)))
+	if unitAmount <= 0 {
+		return nil, errors.New("充值金额过低")
+	}
+	return &stripe.CheckoutSessionLineItemParams{
+		PriceData: &stripe.CheckoutSessionLineItemPriceDataParams{
+			Currency:   stripe.String(strings.ToLower(rate.Code)),
+			UnitAmount: stripe.Int64(unitAmount),
+			ProductData: &stripe.CheckoutSessionLineItemPriceDataProductDataParams{
+				Name: stripe.String(fmt.Sprintf("Recharge %d credits", amount)),
+			},
+		},
+		Quantity: stripe.Int64(1),
+	}, nil
+}
+
+// stripeDiscountedPayMoney 按优惠码折扣后的比例计算换算币种的实收金额
+func stripeDiscountedPayMoney(payMoney float64, currency string, ratio float64) float64 {
+	decimals := 2
+	if rate, ok := operation_setting.GetCurrencyRate(currency); ok {
+		decimals = rate.Decimals
+	}
+	return operation_setting.RoundCurrencyAmount(payMoney*ratio, decimals)
+}
+
 func getStripeMinTopup() int64 {
 	minTopup := setting.StripeMinTopUp
 	if operation_setting.GetQuotaDisplayType() == operation_setting.QuotaDisplayTypeTokens {
@@ -109,6 +109,8 @@ type WaffoPayRequest struct {
 	PayMethodIndex *int   `json:"pay_method_index"` // 服务端支付方式列表的索引，nil 表示由 Waffo 自动选择
 	PayMethodType  string `json:"pay_method_type"`  // Deprecated: 兼容旧前端，优先使用 pay_method_index
 	PayMethodName  string `json:"pay_method_name"`  // Deprecated: 兼容旧前端，优先使用 pay_method_index
+	CouponCode     string `json:"coupon_code"`
+	Currency       string `json:"currency"` // 支付币种，为空时使用 WaffoCurrency
 }
 
 func RequestWaffoAmount(c *gin.Context) {
@@ -131,13 +133,17 @@ func RequestWaffoAmount(c *gin.Context) {
 		return
 	}
 
-	payMoney := getWaffoPayMoney(float64(req.Amount), group)
+	payMoney, currency, err := convertCheckoutMoney(getWaffoPayMoney(float64(req.Amount), group), getWaffoCurrency(), req.Currency)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{"message": "error", "data": err.Error()})
+		return
+	}
 	if payMoney <= 0.01 {
 		c.JSON(http.StatusOK, gin.H{"message": "error", "data": "充值金额过低"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "success", "data": strconv.FormatFloat(payMoney, 'f', 2, 64)})
+	c.JSON(http.StatusOK, gin.H{"message": "success", "data": formatWaffoAmount(payMoney, currency)})
 }
 
 // RequestWaffoPay 创建 Waffo 支付订单
@@ -198,7 +204,19 @@ func RequestWaffoPay(c *gin.Context) {
 	// resolvedPayMethodType/Name 为空时，Waffo 自动选择支付方式
 
 	group, _ := model.GetUserGroup(id, true)
-	payMoney := getWaffoPayMoney(float64(req.Amount), group)
+	payMoney, currency, err := convertCheckoutMoney(getWaffoPayMoney(float64(req.Amount), group), getWaffoCurrency(), req.Currency)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{"message": "error", "data": err.Error()})
+		return
+	}
+	couponUsage := model.CouponUsage{UserId: id, OrderType: model.CouponScopeTopUp, PaymentMethod: model.PaymentMethodWaffo}
+	coupon, err := resolveCheckoutCoupon(req.CouponCode, couponUsage, true)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{"message": "error", "data": err.Error()})
+		return
+	}
+	originalMoney := payMoney
+	payMoney, discountMoney := coupon.ApplyToMoney(payMoney)
 	if payMoney < 0.01 {
 		c.JSON(http.StatusOK, gin.H{"message": "error", "data": "充值金额过低"})
 		return
@@ -217,6 +235,13 @@ func RequestWaffoPay(c *gin.Context) {
 		}
 	}
 
+	if coupon != nil {
+		if err := model.ReserveCoupon(coupon, couponUsage, merchantOrderId, originalMoney, discountMoney); err != nil {
+			c.JSON(http.StatusOK, gin.H{"message": "error", "data": err.Error()})
+			return
+		}
+	}
+
 	// 创建本地订单
 	topUp := &model.TopUp{
 		UserId:          id,
@@ -227,9 +252,15 @@ func RequestWaffoPay(c *gin.Context) {
 		PaymentProvider: model.PaymentProviderWaffo,
 		CreateTime:      time.Now().Unix(),
 		Status:          common.TopUpStatusPending,
+		DiscountMoney:   discountMoney,
+		Currency:        currency,
+	}
+	if coupon != nil {
+		topUp.CouponCode = coupon.Code
 	}
 	if err := topUp.Insert(); err != nil {
 		logger.LogError(c.Request.Context(), fmt.Sprintf("Waffo 创建充值订单失败 user_id=%d trade_no=%s amount=%d error=%q", id, merchantOrderId, req.Amount, err.Error()))
+		_ = model.ReleaseCouponRedemption(merchantOrderId)
 		c.JSON(http.StatusOK, gin.H{"message": "error", "data": "创建订单失败"})
 		return
 	}
@@ -239,6 +270,7 @@ func RequestWaffoPay(c *gin.Context) {
 		logger.LogError(c.Request.Context(), fmt.Sprintf("Waffo SDK 初始化失败 user_id=%d trade_no=%s error=%q", id, merchantOrderId, err.Error()))
 		topUp.Status = common.TopUpStatusFailed
 		_ = topUp.Update()
+		_ = model.ReleaseCouponRedemption(merchantOrderId)
 		c.JSON(http.StatusOK, gin.H{"message": "error", "data": "支付配置错误"})
 		return
 	}
@@ -253,7 +285,6 @@ func RequestWaffoPay(c *gin.Context) {
 		returnUrl = setting.WaffoReturnUrl
 	}
 
-	currency := getWaffoCurrency()
 	goodsInfo := buildWaffoTopUpGoodsInfo(req.Amount)
 	createParams := &order.CreateOrderParams{
 		PaymentRequestID: paymentRequestId,
@@ -285,6 +316,7 @@ func RequestWaffoPay(c *gin.Context) {
 		logger.LogError(c.Request.Context(), fmt.Sprintf("Waffo 创建订单失败 user_id=%d trade_no=%s error=%q", id, merchantOrderId, err.Error()))
 		topUp.Status = common.TopUpStatusFailed
 		_ = topUp.Update()
+		_ = model.ReleaseCouponRedemption(merchantOrderId)
 		c.JSON(http.StatusOK, gin.H{"message": "error", "data": "拉起支付失败"})
 		return
 	}
@@ -292,6 +324,7 @@ func RequestWaffoPay(c *gin.Context) {
 		logger.LogWarn(c.Request.Context(), fmt.Sprintf("Waffo 创建订单业务失败 user_id=%d trade_no=%s code=%s message=%q response=%q", id, merchantOrderId, resp.Code, resp.Message, common.GetJsonString(resp)))
 		topUp.Status = common.TopUpStatusFailed
 		_ = topUp.Update()
+		_ = model.ReleaseCouponRedemption(merchantOrderId)
 		c.JSON(http.StatusOK, gin.H{"message": "error", "data": "拉起支付失败"})
 		return
 	}
@@ -415,6 +448,7 @@ func handleWaffoPayment(c *gin.Context, wh *core.WebhookHandler, result *core.Pa
 	}
 
 	logger.LogInfo(c.Request.Context(), fmt.Sprintf("Waffo 充值成功 trade_no=%s client_ip=%s", merchantOrderId, c.ClientIP()))
+	service.IssueTopUpInvoiceAsync(merchantOrderId)
 	sendWaffoWebhookResponse(c, wh, true, "")
 }
 
@@ -497,6 +497,7 @@ func WaffoPancakeWebhook(c *gin.Context) {
 			return
 		}
 		logger.LogInfo(c.Request.Context(), fmt.Sprintf("Waffo Pancake 订阅完成 trade_no=%s event_id=%s order_id=%s client_ip=%s", tradeNo, event.ID, event.Data.OrderID, c.ClientIP()))
+		service.IssueSubscriptionOrderInvoiceAsync(tradeNo)
 		c.String(http.StatusOK, "OK")
 		return
 	}
@@ -524,5 +525,6 @@ func WaffoPancakeWebhook(c *gin.Context) {
 	}
 
 	logger.LogInfo(c.Request.Context(), fmt.Sprintf("Waffo Pancake 充值成功 trade_no=%s event_id=%s order_id=%s client_ip=%s", tradeNo, event.ID, event.Data.OrderID, c.ClientIP()))
+	service.IssueTopUpInvoiceAsync(tradeNo)
 	c.String(http.StatusOK, "OK")
 }
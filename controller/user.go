@@ -1088,6 +1088,13 @@ type ManageRequest struct {
 	Mode   string `json:"mode"`
 }
 
+// recordAdminQuotaLedger 把管理员对用户额度的调整记入额度账本。
+func recordAdminQuotaLedger(c *gin.Context, userId int, delta int) {
+	posting := model.UserQuotaLedgerPosting(userId, delta, model.QuotaLedgerReasonAdminAdjust, "", "")
+	posting.OperatorId = c.GetInt("id")
+	model.RecordQuotaLedger(posting)
+}
+
 // ManageUser Only admin user can do this
 func ManageUser(c *gin.Context) {
 	var req ManageRequest
@@ -1178,6 +1185,7 @@ func ManageUser(c *gin.Context) {
 				common.ApiError(c, err)
 				return
 			}
+			recordAdminQuotaLedger(c, user.Id, req.Value)
 			recordManageAuditFor(c, user.Id, "user.quota_add", map[string]interface{}{
 				"quota": logger.LogQuota(req.Value),
 			})
@@ -1190,6 +1198,7 @@ func ManageUser(c *gin.Context) {
 				common.ApiError(c, err)
 				return
 			}
+			recordAdminQuotaLedger(c, user.Id, -req.Value)
 			recordManageAuditFor(c, user.Id, "user.quota_subtract", map[string]interface{}{
 				"quota": logger.LogQuota(req.Value),
 			})
@@ -1199,6 +1208,7 @@ func ManageUser(c *gin.Context) {
 				common.ApiError(c, err)
 				return
 			}
+			recordAdminQuotaLedger(c, user.Id, req.Value-oldQuota)
 			recordManageAuditFor(c, user.Id, "user.quota_override", map[string]interface{}{
 				"from": logger.LogQuota(oldQuota),
 				"to":   logger.LogQuota(req.Value),
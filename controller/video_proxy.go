@@ -55,6 +55,14 @@ func VideoProxy(c *gin.Context) {
 		return
 	}
 
+	if obj := service.GetArchivedMedia(model.MediaSourceTask, task.TaskID); obj != nil {
+		err := service.ServeMediaObject(c, obj)
+		if err == nil {
+			return
+		}
+		logger.LogWarn(c.Request.Context(), fmt.Sprintf("Archived media unavailable for task %s, falling back to upstream: %s", taskID, err.Error()))
+	}
+
 	channel, err := model.CacheGetChannel(task.ChannelId)
 	if err != nil {
 		logger.LogError(c.Request.Context(), fmt.Sprintf("Failed to get channel for task %s: %s", taskID, err.Error()))
@@ -0,0 +1,188 @@
+package dto
+
+import (
+	"encoding/json"
+)
+
+// AWS Bedrock Converse / ConverseStream 入站协议（/model/{modelId}/converse、/model/{modelId}/converse-stream）
+
+type BedrockConverseRequest struct {
+	// ModelId 来自请求路径，不在请求体中
+	ModelId                      string                          `json:"-"`
+	Stream                       bool                            `json:"-"`
+	Messages                     []BedrockConverseMessage        `json:"messages"`
+	System                       []BedrockConverseSystemBlock    `json:"system,omitempty"`
+	InferenceConfig              *BedrockConverseInferenceConfig `json:"inferenceConfig,omitempty"`
+	ToolConfig                   *BedrockConverseToolConfig      `json:"toolConfig,omitempty"`
+	AdditionalModelRequestFields json.RawMessage                 `json:"additionalModelRequestFields,omitempty"`
+}
+
+type BedrockConverseMessage struct {
+	Role    string                        `json:"role"`
+	Content []BedrockConverseContentBlock `json:"content"`
+}
+
+type BedrockConverseSystemBlock struct {
+	Text *string `json:"text,omitempty"`
+}
+
+type BedrockConverseContentBlock struct {
+	Text             *string                          `json:"text,omitempty"`
+	Image            *BedrockConverseImageBlock       `json:"image,omitempty"`
+	Document         *BedrockConverseDocumentBlock    `json:"document,omitempty"`
+	ToolUse          *BedrockConverseToolUseBlock     `json:"toolUse,omitempty"`
+	ToolResult       *BedrockConverseToolResultBlock  `json:"toolResult,omitempty"`
+	ReasoningContent *BedrockConverseReasoningContent `json:"reasoningContent,omitempty"`
+}
+
+type BedrockConverseImageBlock struct {
+	// Format png | jpeg | gif | webp
+	Format string                     `json:"format"`
+	Source BedrockConverseMediaSource `json:"source"`
+}
+
+type BedrockConverseDocumentBlock struct {
+	Format string                     `json:"format"`
+	Name   string                     `json:"name"`
+	Source BedrockConverseMediaSource `json:"source"`
+}
+
+type BedrockConverseMediaSource struct {
+	// Bytes 为 base64 编码的原始字节
+	Bytes string `json:"bytes,omitempty"`
+}
+
+type BedrockConverseToolUseBlock struct {
+	ToolUseId string          `json:"toolUseId"`
+	Name      string          `json:"name"`
+	Input     json.RawMessage `json:"input"`
+}
+
+type BedrockConverseToolResultBlock struct {
+	ToolUseId string                             `json:"toolUseId"`
+	Content   []BedrockConverseToolResultContent `json:"content"`
+	// Status success | error
+	Status string `json:"status,omitempty"`
+}
+
+type BedrockConverseToolResultContent struct {
+	Text  *string                    `json:"text,omitempty"`
+	Json  json.RawMessage            `json:"json,omitempty"`
+	Image *BedrockConverseImageBlock `json:"image,omitempty"`
+}
+
+type BedrockConverseReasoningContent struct {
+	ReasoningText   *BedrockConverseReasoningText `json:"reasoningText,omitempty"`
+	RedactedContent string                        `json:"redactedContent,omitempty"`
+}
+
+type BedrockConverseReasoningText struct {
+	Text      string `json:"text"`
+	Signature string `json:"signature,omitempty"`
+}
+
+type BedrockConverseInferenceConfig struct {
+	MaxTokens     *int     `json:"maxTokens,omitempty"`
+	Temperature   *float64 `json:"temperature,omitempty"`
+	TopP          *float64 `json:"topP,omitempty"`
+	StopSequences []string `json:"stopSequences,omitempty"`
+}
+
+type BedrockConverseToolConfig struct {
+	Tools      []BedrockConverseTool      `json:"tools"`
+	ToolChoice *BedrockConverseToolChoice `json:"toolChoice,omitempty"`
+}
+
+type BedrockConverseTool struct {
+	ToolSpec *BedrockConverseToolSpec `json:"toolSpec,omitempty"`
+}
+
+type BedrockConverseToolSpec struct {
+	Name        string                         `json:"name"`
+	Description string                         `json:"description,omitempty"`
+	InputSchema BedrockConverseToolInputSchema `json:"inputSchema"`
+}
+
+type BedrockConverseToolInputSchema struct {
+	Json json.RawMessage `json:"json"`
+}
+
+// BedrockConverseToolChoice auto、any、tool 三选一，以空对象表示
+type BedrockConverseToolChoice struct {
+	Auto *struct{}                      `json:"auto,omitempty"`
+	Any  *struct{}                      `json:"any,omitempty"`
+	Tool *BedrockConverseToolChoiceName `json:"tool,omitempty"`
+}
+
+type BedrockConverseToolChoiceName struct {
+	Name string `json:"name"`
+}
+
+type BedrockConverseResponse struct {
+	Output                        BedrockConverseOutput  `json:"output"`
+	StopReason                    string                 `json:"stopReason"`
+	Usage                         BedrockConverseUsage   `json:"usage"`
+	Metrics                       BedrockConverseMetrics `json:"metrics"`
+	AdditionalModelResponseFields json.RawMessage        `json:"additionalModelResponseFields,omitempty"`
+}
+
+type BedrockConverseOutput struct {
+	Message *BedrockConverseMessage `json:"message,omitempty"`
+}
+
+type BedrockConverseUsage struct {
+	InputTokens           int `json:"inputTokens"`
+	OutputTokens          int `json:"outputTokens"`
+	TotalTokens           int `json:"totalTokens"`
+	CacheReadInputTokens  int `json:"cacheReadInputTokens,omitempty"`
+	CacheWriteInputTokens int `json:"cacheWriteInputTokens,omitempty"`
+}
+
+type BedrockConverseMetrics struct {
+	LatencyMs int64 `json:"latencyMs"`
+}
+
+// BedrockConverseStreamEvent ConverseStream 的单个事件，EventType 写入 :event-type 头，其余字段序列化为事件负载
+type BedrockConverseStreamEvent struct {
+	EventType         string                            `json:"-"`
+	Role              string                            `json:"role,omitempty"`
+	ContentBlockIndex *int                              `json:"contentBlockIndex,omitempty"`
+	Start             *BedrockConverseContentBlockStart `json:"start,omitempty"`
+	Delta             *BedrockConverseContentBlockDelta `json:"delta,omitempty"`
+	StopReason        string                            `json:"stopReason,omitempty"`
+	Usage             *BedrockConverseUsage             `json:"usage,omitempty"`
+	Metrics           *BedrockConverseMetrics           `json:"metrics,omitempty"`
+}
+
+const (
+	BedrockConverseEventMessageStart      = "messageStart"
+	BedrockConverseEventContentBlockStart = "contentBlockStart"
+	BedrockConverseEventContentBlockDelta = "contentBlockDelta"
+	BedrockConverseEventContentBlockStop  = "contentBlockStop"
+	BedrockConverseEventMessageStop       = "messageStop"
+	BedrockConverseEventMetadata          = "metadata"
+)
+
+type BedrockConverseContentBlockStart struct {
+	ToolUse *BedrockConverseToolUseStart `json:"toolUse,omitempty"`
+}
+
+type BedrockConverseToolUseStart struct {
+	ToolUseId string `json:"toolUseId"`
+	Name      string `json:"name"`
+}
+
+type BedrockConverseContentBlockDelta struct {
+	Text             *string                               `json:"text,omitempty"`
+	ToolUse          *BedrockConverseToolUseDelta          `json:"toolUse,omitempty"`
+	ReasoningContent *BedrockConverseReasoningContentDelta `json:"reasoningContent,omitempty"`
+}
+
+type BedrockConverseToolUseDelta struct {
+	Input string `json:"input"`
+}
+
+type BedrockConverseReasoningContentDelta struct {
+	Text      *string `json:"text,omitempty"`
+	Signature *string `json:"signature,omitempty"`
+}
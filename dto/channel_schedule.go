@@ -0,0 +1,208 @@
+package dto
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type ChannelScheduleAction string
+
+const (
+	// ChannelScheduleActionEligible 渠道只在这些窗口内可被选中（存在任一 eligible 窗口时生效）
+	ChannelScheduleActionEligible ChannelScheduleAction = "eligible"
+	// ChannelScheduleActionBoost 窗口内按 WeightMultiplier 调整权重
+	ChannelScheduleActionBoost ChannelScheduleAction = "boost"
+	// ChannelScheduleActionExclude 窗口内不参与选择
+	ChannelScheduleActionExclude ChannelScheduleAction = "exclude"
+)
+
+// ChannelScheduleWindow is a recurring time-of-day window, optionally limited
+// to weekdays or calendar dates. A window whose End is not after Start wraps
+// past midnight and belongs to the day it starts on; equal Start and End (or
+// both empty) cover the whole day.
+type ChannelScheduleWindow struct {
+	Name             string                `json:"name,omitempty"`
+	Action           ChannelScheduleAction `json:"action"`
+	Timezone         string                `json:"timezone,omitempty"`          // IANA 时区，如 Asia/Shanghai，默认 UTC
+	Weekdays         []int                 `json:"weekdays,omitempty"`          // 0=周日 ... 6=周六，空表示每天
+	Dates            []string              `json:"dates,omitempty"`             // YYYY-MM-DD，指定后仅在这些日期生效
+	Start            string                `json:"start,omitempty"`             // HH:MM
+	End              string                `json:"end,omitempty"`               // HH:MM，不大于 Start 时跨越午夜
+	WeightMultiplier float64               `json:"weight_multiplier,omitempty"` // 仅 boost 使用
+}
+
+// ChannelScheduleState is the result of evaluating all windows of a channel.
+type ChannelScheduleState struct {
+	Eligible         bool
+	WeightMultiplier float64
+	ActiveWindows    []string
+}
+
+var scheduleLocationCache sync.Map // map[string]*time.Location
+
+func loadScheduleLocation(name string) (*time.Location, error) {
+	if name == "" {
+		return time.UTC, nil
+	}
+	if loc, ok := scheduleLocationCache.Load(name); ok {
+		return loc.(*time.Location), nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, err
+	}
+	scheduleLocationCache.Store(name, loc)
+	return loc, nil
+}
+
+func parseScheduleClock(value string) (int, error) {
+	if value == "" {
+		return 0, nil
+	}
+	parts := strings.Split(value, ":")
+	if len(parts) != 2 {
+		return 0, fmt.Errorf("invalid time %q, expected HH:MM", value)
+	}
+	hour, err := strconv.Atoi(parts[0])
+	if err != nil || hour < 0 || hour > 24 {
+		return 0, fmt.Errorf("invalid time %q, expected HH:MM", value)
+	}
+	minute, err := strconv.Atoi(parts[1])
+	if err != nil || minute < 0 || minute > 59 || (hour == 24 && minute != 0) {
+		return 0, fmt.Errorf("invalid time %q, expected HH:MM", value)
+	}
+	return hour*60 + minute, nil
+}
+
+func (w ChannelScheduleWindow) DisplayName(index int) string {
+	if w.Name != "" {
+		return w.Name
+	}
+	return fmt.Sprintf("#%d", index+1)
+}
+
+func (w ChannelScheduleWindow) Validate() error {
+	switch w.Action {
+	case ChannelScheduleActionEligible, ChannelScheduleActionExclude:
+	case ChannelScheduleActionBoost:
+		if w.WeightMultiplier < 0 {
+			return fmt.Errorf("weight_multiplier must be >= 0")
+		}
+	default:
+		return fmt.Errorf("invalid action %q", w.Action)
+	}
+	if _, err := loadScheduleLocation(w.Timezone); err != nil {
+		return fmt.Errorf("invalid timezone %q", w.Timezone)
+	}
+	for _, day := range w.Weekdays {
+		if day < 0 || day > 6 {
+			return fmt.Errorf("invalid weekday %d, expected 0-6", day)
+		}
+	}
+	for _, date := range w.Dates {
+		if _, err := time.Parse(time.DateOnly, date); err != nil {
+			return fmt.Errorf("invalid date %q, expected YYYY-MM-DD", date)
+		}
+	}
+	if _, err := parseScheduleClock(w.Start); err != nil {
+		return err
+	}
+	if _, err := parseScheduleClock(w.End); err != nil {
+		return err
+	}
+	return nil
+}
+
+// dayMatches reports whether a window starting on day t is allowed to start.
+func (w ChannelScheduleWindow) dayMatches(t time.Time) bool {
+	if len(w.Weekdays) > 0 {
+		matched := false
+		for _, day := range w.Weekdays {
+			if int(t.Weekday()) == day {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	if len(w.Dates) > 0 {
+		date := t.Format(time.DateOnly)
+		for _, d := range w.Dates {
+			if d == date {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// Contains reports whether now falls inside the window. Invalid windows never
+// match, so a bad config can't silently widen a channel's availability.
+func (w ChannelScheduleWindow) Contains(now time.Time) bool {
+	loc, err := loadScheduleLocation(w.Timezone)
+	if err != nil {
+		return false
+	}
+	start, err := parseScheduleClock(w.Start)
+	if err != nil {
+		return false
+	}
+	end, err := parseScheduleClock(w.End)
+	if err != nil {
+		return false
+	}
+	local := now.In(loc)
+	minute := local.Hour()*60 + local.Minute()
+	if start == end {
+		return w.dayMatches(local)
+	}
+	if start < end {
+		return minute >= start && minute < end && w.dayMatches(local)
+	}
+	// 跨午夜：当天 start 之后属于当天窗口，次日 end 之前属于前一天的窗口
+	if minute >= start {
+		return w.dayMatches(local)
+	}
+	if minute < end {
+		return w.dayMatches(local.AddDate(0, 0, -1))
+	}
+	return false
+}
+
+// EvaluateChannelSchedules combines all windows at the given instant. Exclude
+// wins over everything; when eligible windows exist the channel is only
+// selectable inside one of them; active boost multipliers are multiplied.
+func EvaluateChannelSchedules(windows []ChannelScheduleWindow, now time.Time) ChannelScheduleState {
+	state := ChannelScheduleState{Eligible: true, WeightMultiplier: 1}
+	if len(windows) == 0 {
+		return state
+	}
+	hasEligibleWindow := false
+	inEligibleWindow := false
+	excluded := false
+	for i, w := range windows {
+		if w.Action == ChannelScheduleActionEligible {
+			hasEligibleWindow = true
+		}
+		if !w.Contains(now) {
+			continue
+		}
+		state.ActiveWindows = append(state.ActiveWindows, w.DisplayName(i))
+		switch w.Action {
+		case ChannelScheduleActionEligible:
+			inEligibleWindow = true
+		case ChannelScheduleActionExclude:
+			excluded = true
+		case ChannelScheduleActionBoost:
+			state.WeightMultiplier *= w.WeightMultiplier
+		}
+	}
+	state.Eligible = !excluded && (!hasEligibleWindow || inEligibleWindow)
+	return state
+}
@@ -0,0 +1,88 @@
+package dto
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func scheduleTestTime(t *testing.T, value string) time.Time {
+	t.Helper()
+	parsed, err := time.Parse(time.RFC3339, value)
+	require.NoError(t, err)
+	return parsed
+}
+
+func TestChannelScheduleWindowContainsTimezone(t *testing.T) {
+	window := ChannelScheduleWindow{
+		Action:   ChannelScheduleActionEligible,
+		Timezone: "Asia/Shanghai",
+		Weekdays: []int{1, 2, 3, 4, 5},
+		Start:    "09:00",
+		End:      "18:00",
+	}
+	require.NoError(t, window.Validate())
+
+	// 2026-10-19 is a Monday; 01:30 UTC is 09:30 in Shanghai.
+	require.True(t, window.Contains(scheduleTestTime(t, "2026-10-19T01:30:00Z")))
+	require.False(t, window.Contains(scheduleTestTime(t, "2026-10-19T10:00:00Z")))
+	// Saturday in Shanghai.
+	require.False(t, window.Contains(scheduleTestTime(t, "2026-10-24T02:00:00Z")))
+}
+
+func TestChannelScheduleWindowContainsWrapsMidnight(t *testing.T) {
+	window := ChannelScheduleWindow{
+		Action:   ChannelScheduleActionBoost,
+		Weekdays: []int{5}, // Friday night
+		Start:    "22:00",
+		End:      "06:00",
+	}
+	require.True(t, window.Contains(scheduleTestTime(t, "2026-10-23T23:00:00Z")))
+	// Saturday early morning still belongs to Friday's window.
+	require.True(t, window.Contains(scheduleTestTime(t, "2026-10-24T05:59:00Z")))
+	require.False(t, window.Contains(scheduleTestTime(t, "2026-10-24T06:00:00Z")))
+	// Friday early morning belongs to Thursday, which is not scheduled.
+	require.False(t, window.Contains(scheduleTestTime(t, "2026-10-23T03:00:00Z")))
+}
+
+func TestChannelScheduleWindowContainsDates(t *testing.T) {
+	window := ChannelScheduleWindow{
+		Action: ChannelScheduleActionExclude,
+		Dates:  []string{"2026-12-25"},
+	}
+	require.True(t, window.Contains(scheduleTestTime(t, "2026-12-25T12:00:00Z")))
+	require.False(t, window.Contains(scheduleTestTime(t, "2026-12-26T12:00:00Z")))
+}
+
+func TestEvaluateChannelSchedules(t *testing.T) {
+	windows := []ChannelScheduleWindow{
+		{Name: "business", Action: ChannelScheduleActionEligible, Start: "09:00", End: "18:00"},
+		{Name: "off-peak", Action: ChannelScheduleActionBoost, Start: "12:00", End: "14:00", WeightMultiplier: 3},
+		{Name: "maintenance", Action: ChannelScheduleActionExclude, Start: "16:00", End: "17:00"},
+	}
+
+	state := EvaluateChannelSchedules(windows, scheduleTestTime(t, "2026-10-19T08:00:00Z"))
+	require.False(t, state.Eligible)
+
+	state = EvaluateChannelSchedules(windows, scheduleTestTime(t, "2026-10-19T13:00:00Z"))
+	require.True(t, state.Eligible)
+	require.Equal(t, 3.0, state.WeightMultiplier)
+	require.Equal(t, []string{"business", "off-peak"}, state.ActiveWindows)
+
+	state = EvaluateChannelSchedules(windows, scheduleTestTime(t, "2026-10-19T16:30:00Z"))
+	require.False(t, state.Eligible)
+
+	state = EvaluateChannelSchedules(nil, time.Now())
+	require.True(t, state.Eligible)
+	require.Equal(t, 1.0, state.WeightMultiplier)
+}
+
+func TestChannelScheduleWindowValidate(t *testing.T) {
+	require.Error(t, ChannelScheduleWindow{Action: "later"}.Validate())
+	require.Error(t, ChannelScheduleWindow{Action: ChannelScheduleActionEligible, Timezone: "Mars/Base"}.Validate())
+	require.Error(t, ChannelScheduleWindow{Action: ChannelScheduleActionEligible, Start: "25:00"}.Validate())
+	require.Error(t, ChannelScheduleWindow{Action: ChannelScheduleActionEligible, Weekdays: []int{7}}.Validate())
+	require.Error(t, ChannelScheduleWindow{Action: ChannelScheduleActionEligible, Dates: []string{"2026/12/25"}}.Validate())
+	require.NoError(t, ChannelScheduleWindow{Action: ChannelScheduleActionEligible, Start: "00:00", End: "24:00"}.Validate())
+}
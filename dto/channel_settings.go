@@ -11,16 +11,34 @@ import (
 )
 
 type ChannelSettings struct {
-	ForceFormat              bool     `json:"force_format,omitempty"`
-	ThinkingToContent        bool     `json:"thinking_to_content,omitempty"`
-	Proxy                    string   `json:"proxy"`
-	PassThroughBodyEnabled   bool     `json:"pass_through_body_enabled,omitempty"`
-	SystemPrompt             string   `json:"system_prompt,omitempty"`
-	SystemPromptOverride     bool     `json:"system_prompt_override,omitempty"`
-	MaxRetryAttempts         int      `json:"max_retry_attempts,omitempty"`
-	TreatEmptyReplyAsFailure bool     `json:"treat_empty_reply_as_failure,omitempty"`
-	DynamicCircuitBreaker    bool     `json:"dynamic_circuit_breaker,omitempty"`
-	ToleranceCoefficient     *float64 `json:"tolerance_coefficient,omitempty"` // HP bar max HP multiplier, nil = 1.0, range [0.1, 10.0]
+	ForceFormat               bool                    `json:"force_format,omitempty"`
+	ThinkingToContent         bool                    `json:"thinking_to_content,omitempty"`
+	Proxy                     string                  `json:"proxy"`
+	PassThroughBodyEnabled    bool                    `json:"pass_through_body_enabled,omitempty"`
+	SystemPrompt              string                  `json:"system_prompt,omitempty"`
+	SystemPromptOverride      bool                    `json:"system_prompt_override,omitempty"`
+	MaxRetryAttempts          int                     `json:"max_retry_attempts,omitempty"`
+	TreatEmptyReplyAsFailure  bool                    `json:"treat_empty_reply_as_failure,omitempty"`
+	DynamicCircuitBreaker     bool                    `json:"dynamic_circuit_breaker,omitempty"`
+	ToleranceCoefficient      *float64                `json:"tolerance_coefficient,omitempty"`        // HP bar max HP multiplier, nil = 1.0, range [0.1, 10.0]
+	MaxConcurrency            int                     `json:"max_concurrency,omitempty"`              // 渠道最大并发请求数，0 表示不限制
+	MaxConcurrencyPerKey      int                     `json:"max_concurrency_per_key,omitempty"`      // 多密钥渠道单个密钥的最大并发数，0 表示不限制
+	ConcurrencyQueueSize      int                     `json:"concurrency_queue_size,omitempty"`       // 所有候选渠道均满载时本节点的排队长度，0 表示不排队
+	ConcurrencyQueueTimeoutMs int                     `json:"concurrency_queue_timeout_ms,omitempty"` // 排队最长等待时间（毫秒）
+	Schedules                 []ChannelScheduleWindow `json:"schedules,omitempty"`                    // 时段/日历调度窗口
+	UpstreamCostExprs         map[string]string       `json:"upstream_cost_exprs,omitempty"`          // 上游成本价表达式（billingexpr），模型名 -> 表达式，"*" 为默认
+}
+
+// UpstreamCostExpr returns the upstream cost expression configured for the
+// model, falling back to the "*" entry. Empty means cost is not tracked.
+func (s ChannelSettings) UpstreamCostExpr(modelName string) string {
+	if len(s.UpstreamCostExprs) == 0 {
+		return ""
+	}
+	if expr, ok := s.UpstreamCostExprs[modelName]; ok {
+		return expr
+	}
+	return s.UpstreamCostExprs["*"]
 }
 
 type VertexKeyType string
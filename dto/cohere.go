@@ -0,0 +1,101 @@
+package dto
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/QuantumNous/new-api/common"
+)
+
+// Cohere 原生 Embed 协议（/v1/embed、/v2/embed）
+
+const (
+	CohereEmbedResponseTypeFloats = "embeddings_floats"
+	CohereEmbedResponseTypeByType = "embeddings_by_type"
+)
+
+type CohereEmbedRequest struct {
+	Model           string   `json:"model"`
+	Texts           []string `json:"texts,omitempty"`
+	Images          []string `json:"images,omitempty"`
+	InputType       string   `json:"input_type,omitempty"`
+	EmbeddingTypes  []string `json:"embedding_types,omitempty"`
+	OutputDimension *int     `json:"output_dimension,omitempty"`
+	Truncate        string   `json:"truncate,omitempty"`
+}
+
+type CohereEmbedResponse struct {
+	Id           string           `json:"id"`
+	ResponseType string           `json:"response_type,omitempty"`
+	Embeddings   json.RawMessage  `json:"embeddings"`
+	Texts        []string         `json:"texts,omitempty"`
+	Meta         *CohereEmbedMeta `json:"meta,omitempty"`
+}
+
+type CohereEmbedMeta struct {
+	ApiVersion  *CohereApiVersion       `json:"api_version,omitempty"`
+	BilledUnits *CohereEmbedBilledUnits `json:"billed_units,omitempty"`
+}
+
+type CohereApiVersion struct {
+	Version string `json:"version"`
+}
+
+type CohereEmbedBilledUnits struct {
+	InputTokens int `json:"input_tokens,omitempty"`
+	Images      int `json:"images,omitempty"`
+}
+
+type cohereEmbeddingsByType struct {
+	Float [][]float64 `json:"float,omitempty"`
+}
+
+// FloatEmbeddings 按 response_type 读取 float 向量：embeddings_floats 为二维数组，embeddings_by_type 取 float 字段
+func (r *CohereEmbedResponse) FloatEmbeddings() ([][]float64, error) {
+	if len(r.Embeddings) == 0 {
+		return nil, nil
+	}
+	if r.ResponseType == CohereEmbedResponseTypeFloats {
+		var vectors [][]float64
+		if err := common.Unmarshal(r.Embeddings, &vectors); err != nil {
+			return nil, err
+		}
+		return vectors, nil
+	}
+	var byType cohereEmbeddingsByType
+	if err := common.Unmarshal(r.Embeddings, &byType); err != nil {
+		return nil, err
+	}
+	if byType.Float == nil {
+		return nil, fmt.Errorf("cohere embed response has no float embeddings")
+	}
+	return byType.Float, nil
+}
+
+// SetFloatEmbeddings 写入 float 向量，byType 为 false 时按 v1 默认的 embeddings_floats 格式输出
+func (r *CohereEmbedResponse) SetFloatEmbeddings(vectors [][]float64, byType bool) error {
+	var (
+		data []byte
+		err  error
+	)
+	if byType {
+		r.ResponseType = CohereEmbedResponseTypeByType
+		data, err = common.Marshal(cohereEmbeddingsByType{Float: vectors})
+	} else {
+		r.ResponseType = CohereEmbedResponseTypeFloats
+		data, err = common.Marshal(vectors)
+	}
+	if err != nil {
+		return err
+	}
+	r.Embeddings = data
+	return nil
+}
+
+// InputTokens 返回 Cohere 计费的输入 token 数，未返回 billed_units 时为 0
+func (r *CohereEmbedResponse) InputTokens() int {
+	if r.Meta == nil || r.Meta.BilledUnits == nil {
+		return 0
+	}
+	return r.Meta.BilledUnits.InputTokens
+}
@@ -30,6 +30,8 @@ type EmbeddingRequest struct {
 	TopP             *float64 `json:"top_p,omitempty"`
 	FrequencyPenalty *float64 `json:"frequency_penalty,omitempty"`
 	PresencePenalty  *float64 `json:"presence_penalty,omitempty"`
+	// TaskType 由 Gemini/Cohere 入站请求转换而来（取 Gemini taskType 取值），仅在转换到 Gemini/Cohere 上游时使用，不会发往 OpenAI 兼容上游
+	TaskType string `json:"-"`
 }
 
 func (r *EmbeddingRequest) GetTokenCountMeta() *types.TokenCountMeta {
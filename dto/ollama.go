@@ -0,0 +1,158 @@
+package dto
+
+import (
+	"encoding/json"
+)
+
+// Ollama 原生入站协议（/api/chat、/api/generate、/api/embed、/api/tags）
+
+type OllamaMessage struct {
+	Role      string           `json:"role"`
+	Content   string           `json:"content"`
+	Thinking  string           `json:"thinking,omitempty"`
+	Images    []string         `json:"images,omitempty"`
+	ToolCalls []OllamaToolCall `json:"tool_calls,omitempty"`
+	ToolName  string           `json:"tool_name,omitempty"`
+}
+
+type OllamaToolCall struct {
+	Function OllamaToolCallFunction `json:"function"`
+}
+
+type OllamaToolCallFunction struct {
+	Index     *int            `json:"index,omitempty"`
+	Name      string          `json:"name"`
+	Arguments json.RawMessage `json:"arguments"`
+}
+
+type OllamaOptions struct {
+	Temperature      *float64 `json:"temperature,omitempty"`
+	TopP             *float64 `json:"top_p,omitempty"`
+	TopK             *int     `json:"top_k,omitempty"`
+	NumPredict       *int     `json:"num_predict,omitempty"`
+	Seed             *float64 `json:"seed,omitempty"`
+	Stop             any      `json:"stop,omitempty"`
+	FrequencyPenalty *float64 `json:"frequency_penalty,omitempty"`
+	PresencePenalty  *float64 `json:"presence_penalty,omitempty"`
+}
+
+type OllamaChatRequest struct {
+	Model     string            `json:"model"`
+	Messages  []OllamaMessage   `json:"messages"`
+	Tools     []ToolCallRequest `json:"tools,omitempty"`
+	Format    json.RawMessage   `json:"format,omitempty"`
+	Options   *OllamaOptions    `json:"options,omitempty"`
+	Stream    *bool             `json:"stream,omitempty"`
+	KeepAlive json.RawMessage   `json:"keep_alive,omitempty"`
+	Think     json.RawMessage   `json:"think,omitempty"`
+}
+
+// IsStream Ollama 未显式指定 stream 时默认流式返回
+func (r *OllamaChatRequest) IsStream() bool {
+	return r.Stream == nil || *r.Stream
+}
+
+type OllamaGenerateRequest struct {
+	Model     string          `json:"model"`
+	Prompt    string          `json:"prompt"`
+	Suffix    string          `json:"suffix,omitempty"`
+	System    string          `json:"system,omitempty"`
+	Images    []string        `json:"images,omitempty"`
+	Format    json.RawMessage `json:"format,omitempty"`
+	Options   *OllamaOptions  `json:"options,omitempty"`
+	Stream    *bool           `json:"stream,omitempty"`
+	Raw       bool            `json:"raw,omitempty"`
+	KeepAlive json.RawMessage `json:"keep_alive,omitempty"`
+	Think     json.RawMessage `json:"think,omitempty"`
+}
+
+func (r *OllamaGenerateRequest) IsStream() bool {
+	return r.Stream == nil || *r.Stream
+}
+
+type OllamaEmbedRequest struct {
+	Model      string          `json:"model"`
+	Input      any             `json:"input"`
+	Truncate   *bool           `json:"truncate,omitempty"`
+	Dimensions *int            `json:"dimensions,omitempty"`
+	Options    *OllamaOptions  `json:"options,omitempty"`
+	KeepAlive  json.RawMessage `json:"keep_alive,omitempty"`
+}
+
+// OllamaChatResponse 同时用于非流式响应与流式 NDJSON 分片，最后一个分片 done=true 并携带计数
+type OllamaChatResponse struct {
+	Model              string        `json:"model"`
+	CreatedAt          string        `json:"created_at"`
+	Message            OllamaMessage `json:"message"`
+	Done               bool          `json:"done"`
+	DoneReason         string        `json:"done_reason,omitempty"`
+	TotalDuration      int64         `json:"total_duration,omitempty"`
+	LoadDuration       int64         `json:"load_duration,omitempty"`
+	PromptEvalCount    int           `json:"prompt_eval_count,omitempty"`
+	PromptEvalDuration int64         `json:"prompt_eval_duration,omitempty"`
+	EvalCount          int           `json:"eval_count,omitempty"`
+	EvalDuration       int64         `json:"eval_duration,omitempty"`
+}
+
+type OllamaGenerateResponse struct {
+	Model              string `json:"model"`
+	CreatedAt          string `json:"created_at"`
+	Response           string `json:"response"`
+	Thinking           string `json:"thinking,omitempty"`
+	Done               bool   `json:"done"`
+	DoneReason         string `json:"done_reason,omitempty"`
+	TotalDuration      int64  `json:"total_duration,omitempty"`
+	LoadDuration       int64  `json:"load_duration,omitempty"`
+	PromptEvalCount    int    `json:"prompt_eval_count,omitempty"`
+	PromptEvalDuration int64  `json:"prompt_eval_duration,omitempty"`
+	EvalCount          int    `json:"eval_count,omitempty"`
+	EvalDuration       int64  `json:"eval_duration,omitempty"`
+}
+
+// ToGenerate 把对话分片投影为 /api/generate 的响应格式
+func (r *OllamaChatResponse) ToGenerate() *OllamaGenerateResponse {
+	return &OllamaGenerateResponse{
+		Model:              r.Model,
+		CreatedAt:          r.CreatedAt,
+		Response:           r.Message.Content,
+		Thinking:           r.Message.Thinking,
+		Done:               r.Done,
+		DoneReason:         r.DoneReason,
+		TotalDuration:      r.TotalDuration,
+		LoadDuration:       r.LoadDuration,
+		PromptEvalCount:    r.PromptEvalCount,
+		PromptEvalDuration: r.PromptEvalDuration,
+		EvalCount:          r.EvalCount,
+		EvalDuration:       r.EvalDuration,
+	}
+}
+
+type OllamaEmbedResponse struct {
+	Model           string      `json:"model"`
+	Embeddings      [][]float64 `json:"embeddings"`
+	TotalDuration   int64       `json:"total_duration,omitempty"`
+	LoadDuration    int64       `json:"load_duration,omitempty"`
+	PromptEvalCount int         `json:"prompt_eval_count,omitempty"`
+}
+
+type OllamaTagsResponse struct {
+	Models []OllamaModel `json:"models"`
+}
+
+type OllamaModel struct {
+	Name       string             `json:"name"`
+	Model      string             `json:"model"`
+	ModifiedAt string             `json:"modified_at"`
+	Size       int64              `json:"size"`
+	Digest     string             `json:"digest"`
+	Details    OllamaModelDetails `json:"details"`
+}
+
+type OllamaModelDetails struct {
+	ParentModel       string   `json:"parent_model"`
+	Format            string   `json:"format"`
+	Family            string   `json:"family"`
+	Families          []string `json:"families"`
+	ParameterSize     string   `json:"parameter_size"`
+	QuantizationLevel string   `json:"quantization_level"`
+}
@@ -0,0 +1,75 @@
+package dto
+
+import (
+	"encoding/json"
+)
+
+// 旧版 OpenAI /v1/completions 协议，仅在需要转换为 Chat Completions 时使用，原生转发仍走 GeneralOpenAIRequest
+
+type CompletionsRequest struct {
+	Model            string          `json:"model"`
+	Prompt           any             `json:"prompt,omitempty"`
+	Suffix           string          `json:"suffix,omitempty"`
+	Echo             bool            `json:"echo,omitempty"`
+	LogProbs         *int            `json:"logprobs,omitempty"`
+	BestOf           *int            `json:"best_of,omitempty"`
+	MaxTokens        *uint           `json:"max_tokens,omitempty"`
+	Temperature      *float64        `json:"temperature,omitempty"`
+	TopP             *float64        `json:"top_p,omitempty"`
+	N                *int            `json:"n,omitempty"`
+	Stream           *bool           `json:"stream,omitempty"`
+	StreamOptions    *StreamOptions  `json:"stream_options,omitempty"`
+	Stop             any             `json:"stop,omitempty"`
+	PresencePenalty  *float64        `json:"presence_penalty,omitempty"`
+	FrequencyPenalty *float64        `json:"frequency_penalty,omitempty"`
+	Seed             *float64        `json:"seed,omitempty"`
+	LogitBias        json.RawMessage `json:"logit_bias,omitempty"`
+	User             json.RawMessage `json:"user,omitempty"`
+}
+
+// PromptText 返回单条文本 prompt；数组只含一个字符串时同样视为单条，多条或 token 数组返回 false
+func (r *CompletionsRequest) PromptText() (string, bool) {
+	switch prompt := r.Prompt.(type) {
+	case string:
+		return prompt, true
+	case []any:
+		if len(prompt) != 1 {
+			return "", false
+		}
+		text, ok := prompt[0].(string)
+		return text, ok
+	case []string:
+		if len(prompt) != 1 {
+			return "", false
+		}
+		return prompt[0], true
+	default:
+		return "", false
+	}
+}
+
+type CompletionsChoice struct {
+	Text         string  `json:"text"`
+	Index        int     `json:"index"`
+	Logprobs     any     `json:"logprobs"`
+	FinishReason *string `json:"finish_reason"`
+}
+
+// CompletionsLogprobs 为 text_completion choice 中的 logprobs，TextOffset 为各 token 在文本中的字符位置
+type CompletionsLogprobs struct {
+	Tokens        []string             `json:"tokens"`
+	TokenLogprobs []float64            `json:"token_logprobs"`
+	TopLogprobs   []map[string]float64 `json:"top_logprobs"`
+	TextOffset    []int                `json:"text_offset"`
+}
+
+// CompletionsResponse 同时用于非流式响应与流式分片，object 均为 text_completion
+type CompletionsResponse struct {
+	Id                string              `json:"id"`
+	Object            string              `json:"object"`
+	Created           int64               `json:"created"`
+	Model             string              `json:"model"`
+	SystemFingerprint *string             `json:"system_fingerprint,omitempty"`
+	Choices           []CompletionsChoice `json:"choices"`
+	Usage             *Usage              `json:"usage,omitempty"`
+}
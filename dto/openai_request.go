@@ -60,7 +60,7 @@ type GeneralOpenAIRequest struct {
 	// ServiceTier specifies upstream service level and may affect billing.
 	// This field is filtered by default and can be enabled via channel setting allow_service_tier.
 	ServiceTier json.RawMessage `json:"service_tier,omitempty"`
-	LogProbs    *bool           `json:"logprobs,omitempty"`
+	LogProbs    json.RawMessage `json:"logprobs,omitempty"` // chat 为布尔值，旧版 completions 为整数
 	TopLogProbs *int            `json:"top_logprobs,omitempty"`
 	Dimensions  *int            `json:"dimensions,omitempty"`
 	Modalities  json.RawMessage `json:"modalities,omitempty"`
@@ -10,6 +10,8 @@ import (
 
 const (
 	ResponsesOutputTypeImageGenerationCall = "image_generation_call"
+	ResponsesOutputTypeMessage             = "message"
+	ResponsesOutputTypeFunctionCall        = "function_call"
 )
 
 type SimpleResponse struct {
@@ -34,9 +36,22 @@ type TextResponse struct {
 type OpenAITextResponseChoice struct {
 	Index        int `json:"index"`
 	Message      `json:"message"`
+	Logprobs     any    `json:"logprobs,omitempty"`
 	FinishReason string `json:"finish_reason"`
 }
 
+// ChatLogprobs 为 Chat Completions choice 中的 logprobs
+type ChatLogprobs struct {
+	Content []ChatTokenLogprob `json:"content"`
+}
+
+type ChatTokenLogprob struct {
+	Token       string             `json:"token"`
+	Logprob     float64            `json:"logprob"`
+	Bytes       []int              `json:"bytes,omitempty"`
+	TopLogprobs []ChatTokenLogprob `json:"top_logprobs,omitempty"`
+}
+
 type OpenAITextResponse struct {
 	Id      string                     `json:"id"`
 	Model   string                     `json:"model"`
@@ -409,6 +424,7 @@ const (
 const (
 	ResponsesOutputTypeItemAdded = "response.output_item.added"
 	ResponsesOutputTypeItemDone  = "response.output_item.done"
+	ResponsesOutputTypeCompleted = "response.completed"
 )
 
 // ResponsesStreamResponse 用于处理 /v1/responses 流式响应
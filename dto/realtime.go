@@ -8,6 +8,8 @@ const (
 	RealtimeEventTypeConversationCreate = "conversation.item.create"
 	RealtimeEventTypeResponseCreate     = "response.create"
 	RealtimeEventInputAudioBufferAppend = "input_audio_buffer.append"
+	RealtimeEventInputAudioBufferCommit = "input_audio_buffer.commit"
+	RealtimeEventInputAudioBufferClear  = "input_audio_buffer.clear"
 )
 
 const (
@@ -19,22 +21,48 @@ const (
 	RealtimeEventResponseFunctionCallArgumentsDelta = "response.function_call_arguments.delta"
 	RealtimeEventResponseFunctionCallArgumentsDone  = "response.function_call_arguments.done"
 	RealtimeEventConversationItemCreated            = "conversation.item.created"
+	RealtimeEventInputAudioBufferCommitted          = "input_audio_buffer.committed"
+	RealtimeEventInputAudioBufferCleared            = "input_audio_buffer.cleared"
+	RealtimeEventInputAudioTranscriptionCompleted   = "conversation.item.input_audio_transcription.completed"
+	RealtimeEventResponseCreated                    = "response.created"
+	RealtimeEventResponseOutputItemAdded            = "response.output_item.added"
+	RealtimeEventResponseOutputItemDone             = "response.output_item.done"
+	RealtimeEventResponseContentPartAdded           = "response.content_part.added"
+	RealtimeEventResponseContentPartDone            = "response.content_part.done"
+	RealtimeEventResponseTextDelta                  = "response.text.delta"
+	RealtimeEventResponseTextDone                   = "response.text.done"
+	RealtimeEventResponseAudioDone                  = "response.audio.done"
+	RealtimeEventResponseAudioTranscriptionDone     = "response.audio_transcript.done"
 )
 
 type RealtimeEvent struct {
-	EventId string `json:"event_id"`
-	Type    string `json:"type"`
-	//PreviousItemId string `json:"previous_item_id"`
+	EventId  string             `json:"event_id"`
+	Type     string             `json:"type"`
 	Session  *RealtimeSession   `json:"session,omitempty"`
 	Item     *RealtimeItem      `json:"item,omitempty"`
 	Error    *types.OpenAIError `json:"error,omitempty"`
 	Response *RealtimeResponse  `json:"response,omitempty"`
 	Delta    string             `json:"delta,omitempty"`
 	Audio    string             `json:"audio,omitempty"`
+
+	ItemId         string           `json:"item_id,omitempty"`
+	PreviousItemId string           `json:"previous_item_id,omitempty"`
+	ResponseId     string           `json:"response_id,omitempty"`
+	OutputIndex    *int             `json:"output_index,omitempty"`
+	ContentIndex   *int             `json:"content_index,omitempty"`
+	Part           *RealtimeContent `json:"part,omitempty"`
+	Text           string           `json:"text,omitempty"`
+	Transcript     string           `json:"transcript,omitempty"`
 }
 
 type RealtimeResponse struct {
-	Usage *RealtimeUsage `json:"usage"`
+	Id           string         `json:"id,omitempty"`
+	Object       string         `json:"object,omitempty"`
+	Status       string         `json:"status,omitempty"`
+	Modalities   []string       `json:"modalities,omitempty"`
+	Instructions string         `json:"instructions,omitempty"`
+	Output       []RealtimeItem `json:"output,omitempty"`
+	Usage        *RealtimeUsage `json:"usage"`
 }
 
 type RealtimeUsage struct {
@@ -46,6 +74,9 @@ type RealtimeUsage struct {
 }
 
 type RealtimeSession struct {
+	Id                      string                  `json:"id,omitempty"`
+	Object                  string                  `json:"object,omitempty"`
+	Model                   string                  `json:"model,omitempty"`
 	Modalities              []string                `json:"modalities"`
 	Instructions            string                  `json:"instructions"`
 	Voice                   string                  `json:"voice"`
@@ -127,6 +127,21 @@ func main() {
 	// Dynamic breaker penalty trace cleanup task (90-day retention)
 	service.StartBreakerPenaltyTraceCleanupTask()
 
+	// Stored responses cleanup task (TTL from responses_store_setting)
+	service.StartResponsesStoreCleanupTask()
+
+	// Shadow traffic comparison cleanup task (retention from shadow_traffic_setting)
+	service.StartShadowComparisonCleanupTask()
+
+	// Channel schedule watcher (logs schedule windows opening and closing)
+	service.StartChannelScheduleWatcherTask()
+
+	// Channel key expiry warnings and rotation grace periods
+	service.StartChannelKeyLifecycleTask()
+
+	// Quota ledger reconciliation against users.quota
+	service.StartQuotaLedgerReconcileTask()
+
 	// Report this process as a system instance so the System Info page can show
 	// all currently alive nodes in multi-instance deployments.
 	service.StartSystemInstanceReporter()
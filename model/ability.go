@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/QuantumNous/new-api/common"
 	"github.com/QuantumNous/new-api/constant"
@@ -118,6 +119,10 @@ func GetChannel(group string, model string, retry int, requestPath string) (*Cha
 		return nil, err
 	}
 	abilities = filterAbilitiesByRequestPathAndModel(abilities, requestPath, model)
+	abilities, err = filterAbilitiesBySchedule(abilities, time.Now())
+	if err != nil {
+		return nil, err
+	}
 	channel := Channel{}
 	if len(abilities) > 0 {
 		// Randomly choose one
@@ -200,6 +205,7 @@ func GetChannelExclude(group string, model string, exclude map[int]bool, request
 		}
 
 		// Filter out excluded channels at this priority
+		now := time.Now()
 		candidates := make([]candidate, 0, len(abilities))
 		sumWeight := 0
 		for _, a := range abilities {
@@ -208,7 +214,11 @@ func GetChannelExclude(group string, model string, exclude map[int]bool, request
 			}
 			w := int(a.Weight)
 			if ch, ok := channelByID[a.ChannelId]; ok {
-				w = ch.GetEffectiveRoutingWeight(w)
+				scheduleState := ch.GetScheduleState(now)
+				if !scheduleState.Eligible {
+					continue
+				}
+				w = applyScheduleWeight(ch.GetEffectiveRoutingWeight(w), scheduleState)
 			}
 			candidates = append(candidates, candidate{channelId: a.ChannelId, weight: w})
 			sumWeight += w
@@ -0,0 +1,285 @@
+package model
+
+import (
+	"errors"
+
+	"github.com/QuantumNous/new-api/common"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	AutoRechargeStatusPending   = "pending"
+	AutoRechargeStatusSucceeded = "succeeded"
+	AutoRechargeStatusFailed    = "failed"
+)
+
+// AutoRechargeConfig 用户的自动充值设置。Threshold 为触发阈值（额度单位），
+// Amount 为每次充值数量（与 Stripe 充值的 amount 含义一致），DailyCap 为每天最多充值的数量，0 表示只受全局次数限制。
+type AutoRechargeConfig struct {
+	Id                    int    `json:"id"`
+	UserId                int    `json:"user_id" gorm:"uniqueIndex"`
+	Enabled               bool   `json:"enabled"`
+	Threshold             int    `json:"threshold"`
+	Amount                int64  `json:"amount"`
+	DailyCap              int64  `json:"daily_cap"`
+	StripeCustomerId      string `json:"-" gorm:"type:varchar(255);default:''"`
+	StripePaymentMethodId string `json:"-" gorm:"type:varchar(255);default:''"`
+	CardBrand             string `json:"card_brand" gorm:"type:varchar(32);default:''"`
+	CardLast4             string `json:"card_last4" gorm:"type:varchar(8);default:''"`
+	ConsecutiveFailures   int    `json:"consecutive_failures"`
+	LastError             string `json:"last_error" gorm:"type:text"`
+	CreatedAt             int64  `json:"created_at" gorm:"bigint"`
+	UpdatedAt             int64  `json:"updated_at" gorm:"bigint"`
+}
+
+// AutoRechargeAttempt 记录一次自动充值扣款。IdempotencyKey 由触发请求生成，
+// 同一请求的结算只会触发一次扣款，同时作为 Stripe 的幂等键。
+type AutoRechargeAttempt struct {
+	Id              int     `json:"id"`
+	UserId          int     `json:"user_id" gorm:"index"`
+	IdempotencyKey  string  `json:"idempotency_key" gorm:"type:varchar(191);uniqueIndex"`
+	RequestId       string  `json:"request_id" gorm:"type:varchar(64);default:''"`
+	TradeNo         string  `json:"trade_no" gorm:"type:varchar(255);index"`
+	Amount          int64   `json:"amount"`
+	Money           float64 `json:"money"`
+	QuotaBefore     int     `json:"quota_before"`
+	PaymentIntentId string  `json:"payment_intent_id" gorm:"type:varchar(255);default:''"`
+	Status          string  `json:"status" gorm:"type:varchar(20);index"`
+	Error           string  `json:"error" gorm:"type:text"`
+	CreatedAt       int64   `json:"created_at" gorm:"bigint;index"`
+	CompletedAt     int64   `json:"completed_at" gorm:"bigint"`
+}
+
+func (config *AutoRechargeConfig) HasPaymentMethod() bool {
+	return config != nil && config.StripeCustomerId != "" && config.StripePaymentMethodId != ""
+}
+
+// GetAutoRechargeConfig 返回用户的自动充值设置，不存在时返回未启用的空配置
+func GetAutoRechargeConfig(userId int) (*AutoRechargeConfig, error) {
+	var config AutoRechargeConfig
+	err := DB.Where("user_id = ?", userId).First(&config).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return &AutoRechargeConfig{UserId: userId}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &config, nil
+}
+
+// UpdateAutoRechargeRules 更新用户可编辑的阈值与金额设置
+func UpdateAutoRechargeRules(userId int, enabled bool, threshold int, amount int64, dailyCap int64) (*AutoRechargeConfig, error) {
+	var result AutoRechargeConfig
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		config, err := lockAutoRechargeConfigTx(tx, userId)
+		if err != nil {
+			return err
+		}
+		if enabled && !config.HasPaymentMethod() {
+			return errors.New("请先绑定支付方式")
+		}
+		config.Enabled = enabled
+		config.Threshold = threshold
+		config.Amount = amount
+		config.DailyCap = dailyCap
+		if enabled {
+			config.ConsecutiveFailures = 0
+		}
+		config.UpdatedAt = common.GetTimestamp()
+		if err := tx.Save(config).Error; err != nil {
+			return err
+		}
+		result = *config
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// SetAutoRechargePaymentMethod 保存 Stripe 离线扣款使用的客户与支付方式
+func SetAutoRechargePaymentMethod(userId int, customerId string, paymentMethodId string, brand string, last4 string) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		config, err := lockAutoRechargeConfigTx(tx, userId)
+		if err != nil {
+			return err
+		}
+		config.StripeCustomerId = customerId
+		config.StripePaymentMethodId = paymentMethodId
+		config.CardBrand = brand
+		config.CardLast4 = last4
+		config.ConsecutiveFailures = 0
+		config.LastError = ""
+		config.UpdatedAt = common.GetTimestamp()
+		return tx.Save(config).Error
+	})
+}
+
+// ClearAutoRechargePaymentMethod 解绑支付方式并关闭自动充值
+func ClearAutoRechargePaymentMethod(userId int) error {
+	return DB.Model(&AutoRechargeConfig{}).Where("user_id = ?", userId).Updates(map[string]interface{}{
+		"enabled":                  false,
+		"stripe_payment_method_id": "",
+		"card_brand":               "",
+		"card_last4":               "",
+		"updated_at":               common.GetTimestamp(),
+	}).Error
+}
+
+func lockAutoRechargeConfigTx(tx *gorm.DB, userId int) (*AutoRechargeConfig, error) {
+	var config AutoRechargeConfig
+	err := lockForUpdate(tx).Where("user_id = ?", userId).First(&config).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		now := common.GetTimestamp()
+		config = AutoRechargeConfig{UserId: userId, CreatedAt: now, UpdatedAt: now}
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&config).Error; err != nil {
+			return nil, err
+		}
+		err = lockForUpdate(tx).Where("user_id = ?", userId).First(&config).Error
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &config, nil
+}
+
+const (
+	AutoRechargeSkipDuplicate  = "duplicate"
+	AutoRechargeSkipInFlight   = "in_flight"
+	AutoRechargeSkipCooldown   = "cooldown"
+	AutoRechargeSkipDailyCount = "daily_count"
+	AutoRechargeSkipDailyCap   = "daily_cap"
+)
+
+// AutoRechargePendingTimeout pending 超过该时长的扣款不再阻塞新的自动充值
+const AutoRechargePendingTimeout int64 = 60 * 60
+
+type AutoRechargeLimits struct {
+	CooldownSeconds  int64
+	MaxChargesPerDay int
+	DailyCap         int64
+}
+
+// ReserveAutoRechargeAttempt 在锁定用户配置的事务中检查冷却时间、进行中的扣款与每日上限，
+// 通过后以幂等键登记扣款。返回非空的跳过原因时表示本次不扣款。
+func ReserveAutoRechargeAttempt(attempt *AutoRechargeAttempt, limits AutoRechargeLimits) (skip string, err error) {
+	now := common.GetTimestamp()
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		if _, err := lockAutoRechargeConfigTx(tx, attempt.UserId); err != nil {
+			return err
+		}
+		var existing int64
+		if err := tx.Model(&AutoRechargeAttempt{}).Where("idempotency_key = ?", attempt.IdempotencyKey).Count(&existing).Error; err != nil {
+			return err
+		}
+		if existing > 0 {
+			skip = AutoRechargeSkipDuplicate
+			return nil
+		}
+		var last AutoRechargeAttempt
+		lastErr := tx.Where("user_id = ?", attempt.UserId).Order("id desc").First(&last).Error
+		if lastErr != nil && !errors.Is(lastErr, gorm.ErrRecordNotFound) {
+			return lastErr
+		}
+		if lastErr == nil {
+			if last.Status == AutoRechargeStatusPending && now-last.CreatedAt < AutoRechargePendingTimeout {
+				skip = AutoRechargeSkipInFlight
+				return nil
+			}
+			if limits.CooldownSeconds > 0 && now-last.CreatedAt < limits.CooldownSeconds {
+				skip = AutoRechargeSkipCooldown
+				return nil
+			}
+		}
+		var usage struct {
+			Count  int64
+			Amount int64
+		}
+		if err := tx.Model(&AutoRechargeAttempt{}).
+			Select("count(*) AS count, COALESCE(sum(amount), 0) AS amount").
+			Where("user_id = ? AND created_at >= ? AND status <> ?", attempt.UserId, now-now%86400, AutoRechargeStatusFailed).
+			Scan(&usage).Error; err != nil {
+			return err
+		}
+		if limits.MaxChargesPerDay > 0 && usage.Count >= int64(limits.MaxChargesPerDay) {
+			skip = AutoRechargeSkipDailyCount
+			return nil
+		}
+		if limits.DailyCap > 0 && usage.Amount+attempt.Amount > limits.DailyCap {
+			skip = AutoRechargeSkipDailyCap
+			return nil
+		}
+		attempt.Status = AutoRechargeStatusPending
+		attempt.CreatedAt = now
+		return tx.Create(attempt).Error
+	})
+	return skip, err
+}
+
+func GetAutoRechargeAttemptByTradeNo(tradeNo string) (*AutoRechargeAttempt, error) {
+	var attempt AutoRechargeAttempt
+	if err := DB.Where("trade_no = ?", tradeNo).First(&attempt).Error; err != nil {
+		return nil, err
+	}
+	return &attempt, nil
+}
+
+func (attempt *AutoRechargeAttempt) Update() error {
+	return DB.Save(attempt).Error
+}
+
+// FinishAutoRechargeAttempt 把仍处于 pending 的扣款标记为最终状态，并更新配置上的连续失败计数。
+// 返回 false 表示该扣款已被其他路径（同步结果或 Stripe 回调）处理过；
+// 连续失败次数达到 maxFailures 时自动关闭自动充值，disabled 返回 true。
+func FinishAutoRechargeAttempt(attemptId int, status string, errMsg string, maxFailures int) (finished bool, disabled bool, err error) {
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&AutoRechargeAttempt{}).
+			Where("id = ? AND status = ?", attemptId, AutoRechargeStatusPending).
+			Updates(map[string]interface{}{
+				"status":       status,
+				"error":        errMsg,
+				"completed_at": common.GetTimestamp(),
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return nil
+		}
+		finished = true
+		var attempt AutoRechargeAttempt
+		if err := tx.Select("user_id").Where("id = ?", attemptId).First(&attempt).Error; err != nil {
+			return err
+		}
+		config, err := lockAutoRechargeConfigTx(tx, attempt.UserId)
+		if err != nil {
+			return err
+		}
+		if status == AutoRechargeStatusSucceeded {
+			config.ConsecutiveFailures = 0
+			config.LastError = ""
+		} else {
+			config.ConsecutiveFailures++
+			config.LastError = errMsg
+			if maxFailures > 0 && config.ConsecutiveFailures >= maxFailures && config.Enabled {
+				config.Enabled = false
+				disabled = true
+			}
+		}
+		config.UpdatedAt = common.GetTimestamp()
+		return tx.Save(config).Error
+	})
+	return finished, disabled, err
+}
+
+func GetAutoRechargeAttempts(userId int, startIdx int, num int) (attempts []*AutoRechargeAttempt, total int64, err error) {
+	query := DB.Model(&AutoRechargeAttempt{}).Where("user_id = ?", userId)
+	if err = query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err = query.Order("id desc").Limit(num).Offset(startIdx).Find(&attempts).Error
+	return attempts, total, err
+}
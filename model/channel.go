@@ -13,6 +13,7 @@ import (
 	"github.com/QuantumNous/new-api/constant"
 	"github.com/QuantumNous/new-api/dto"
 	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/pkg/billingexpr"
 	"github.com/QuantumNous/new-api/types"
 
 	"github.com/samber/lo"
@@ -85,6 +86,9 @@ type ChannelInfo struct {
 	MultiKeyDisabledTime   map[int]int64         `json:"multi_key_disabled_time,omitempty"`   // key禁用时间列表，key index -> time
 	MultiKeyPollingIndex   int                   `json:"multi_key_polling_index"`             // 多Key模式下轮询的key索引
 	MultiKeyMode           constant.MultiKeyMode `json:"multi_key_mode"`
+	KeyExpiresAt           map[int]int64         `json:"key_expires_at,omitempty"`       // 密钥到期时间，key index -> unix 秒；单密钥渠道使用索引 0
+	KeyRotationOwner       map[int]string        `json:"key_rotation_owner,omitempty"`   // 密钥轮换负责人，key index -> owner
+	KeyExpiryWarnedAt      map[int]int64         `json:"key_expiry_warned_at,omitempty"` // 已发送提醒的到期时间，避免重复提醒
 }
 
 type ChannelSortOptions struct {
@@ -254,6 +258,18 @@ func (channel *Channel) GetKeys() []string {
 }
 
 func (channel *Channel) GetNextEnabledKey() (string, int, *types.NewAPIError) {
+	key, index, err := channel.nextEnabledKey()
+	if err != nil {
+		return key, index, err
+	}
+	// 密钥轮换宽限期内新密钥已被上游拒绝时，改用旧密钥
+	if previousKey, ok := previousChannelKey(channel.Id, index, key); ok {
+		return previousKey, index, nil
+	}
+	return key, index, nil
+}
+
+func (channel *Channel) nextEnabledKey() (string, int, *types.NewAPIError) {
 	// If not in multi-key mode, return the original key string directly.
 	if !channel.ChannelInfo.IsMultiKey {
 		return channel.Key, 0, nil
@@ -1245,6 +1261,16 @@ func (channel *Channel) ValidateSettings() error {
 			return err
 		}
 	}
+	for i, window := range channelParams.Schedules {
+		if err := window.Validate(); err != nil {
+			return fmt.Errorf("schedules[%d]: %w", i, err)
+		}
+	}
+	for modelName, expr := range channelParams.UpstreamCostExprs {
+		if _, err := billingexpr.CompileFromCache(expr); err != nil {
+			return fmt.Errorf("upstream_cost_exprs[%s]: %w", modelName, err)
+		}
+	}
 	channelOtherSettings := &dto.ChannelOtherSettings{}
 	if channel.OtherSettings != "" {
 		err := common.UnmarshalJsonStr(channel.OtherSettings, channelOtherSettings)
@@ -30,6 +30,7 @@ func InitChannelCache() {
 	}
 	newChannelId2channel := make(map[int]*Channel)
 	newChannel2advancedCustomConfig := make(map[int]*dto.AdvancedCustomConfig)
+	newChannel2schedules := make(map[int][]dto.ChannelScheduleWindow)
 	var channels []*Channel
 	DB.Find(&channels)
 	if err := LoadChannelExternalFields(channels...); err != nil {
@@ -42,6 +43,13 @@ func InitChannelCache() {
 				newChannel2advancedCustomConfig[channel.Id] = config
 			}
 		}
+		if schedules := channel.GetSetting().Schedules; len(schedules) > 0 {
+			newChannel2schedules[channel.Id] = schedules
+		}
+	}
+	newChannel2keyGraces, err := loadChannelKeyGraces(common.GetTimestamp())
+	if err != nil {
+		common.SysError(fmt.Sprintf("failed to load channel key rotation graces while syncing cache: %v", err))
 	}
 	var abilities []*Ability
 	DB.Find(&abilities)
@@ -97,6 +105,8 @@ func InitChannelCache() {
 	}
 	channelsIDM = newChannelId2channel
 	channel2advancedCustomConfig = newChannel2advancedCustomConfig
+	channel2schedules = newChannel2schedules
+	channel2keyGraces = newChannel2keyGraces
 	channelSyncLock.Unlock()
 	// Lock ordering: InvalidatePricingCache acquires updatePricingLock, and
 	// GetPricing (holding updatePricingLock) nests channelSyncLock.RLock via
@@ -139,6 +149,8 @@ func GetRandomSatisfiedChannel(group string, model string, retry int, requestPat
 		channels = filterChannelsByRequestPathAndModel(group2model2channels[group][normalizedModel], rp, model)
 	}
 
+	now := time.Now()
+	channels = filterChannelsBySchedule(channels, now)
 	if len(channels) == 0 {
 		return nil, nil
 	}
@@ -172,11 +184,14 @@ func GetRandomSatisfiedChannel(group string, model string, retry int, requestPat
 	// get the priority for the given retry number
 	var sumWeight = 0
 	var targetChannels []*Channel
+	var targetWeights []int
 	for _, channelId := range channels {
 		if channel, ok := channelsIDM[channelId]; ok {
 			if channel.GetPriority() == targetPriority {
-				sumWeight += channel.GetWeight()
+				w := applyScheduleWeight(channel.GetWeight(), getChannelScheduleState(channelId, now))
+				sumWeight += w
 				targetChannels = append(targetChannels, channel)
+				targetWeights = append(targetWeights, w)
 			}
 		} else {
 			return nil, fmt.Errorf("数据库一致性错误，渠道# %d 不存在，请联系管理员修复", channelId)
@@ -208,8 +223,8 @@ func GetRandomSatisfiedChannel(group string, model string, retry int, requestPat
 	randomWeight := rand.Intn(totalWeight)
 
 	// Find a channel based on its weight
-	for _, channel := range targetChannels {
-		randomWeight -= channel.GetWeight()*smoothingFactor + smoothingAdjustment
+	for i, channel := range targetChannels {
+		randomWeight -= targetWeights[i]*smoothingFactor + smoothingAdjustment
 		if randomWeight < 0 {
 			return channel, nil
 		}
@@ -244,6 +259,8 @@ func GetRandomSatisfiedChannelExclude(group string, model string, exclude map[in
 		channels = filterChannelsByRequestPathAndModel(group2model2channels[group][normalizedModel], rp, model)
 	}
 
+	now := time.Now()
+	channels = filterChannelsBySchedule(channels, now)
 	if len(channels) == 0 {
 		return nil, nil
 	}
@@ -282,7 +299,7 @@ func GetRandomSatisfiedChannelExclude(group string, model string, exclude map[in
 				if exclude != nil && exclude[ch.Id] {
 					continue
 				}
-				w := ch.GetEffectiveRoutingWeight(ch.GetWeight())
+				w := applyScheduleWeight(ch.GetEffectiveRoutingWeight(ch.GetWeight()), getChannelScheduleState(ch.Id, now))
 				candidates = append(candidates, candidate{ch: ch, weight: w})
 				sumWeight += w
 			}
@@ -526,6 +543,13 @@ func CacheUpdateChannel(channel *Channel) {
 			channel2advancedCustomConfig[channel.Id] = config
 		}
 	}
+	if channel2schedules == nil {
+		channel2schedules = make(map[int][]dto.ChannelScheduleWindow)
+	}
+	delete(channel2schedules, channel.Id)
+	if schedules := channel.GetSetting().Schedules; len(schedules) > 0 {
+		channel2schedules[channel.Id] = schedules
+	}
 	logger.LogDebug(nil, "CacheUpdateChannel after: id=%d, name=%s, status=%d, polling_index=%d", channel.Id, channel.Name, channel.Status, channel.ChannelInfo.MultiKeyPollingIndex)
 	// Lock ordering: do NOT hold channelSyncLock while calling
 	// InvalidatePricingCache. GetPricing acquires updatePricingLock first and then
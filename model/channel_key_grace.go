@@ -0,0 +1,151 @@
+package model
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/QuantumNous/new-api/common"
+)
+
+// channelKeyGrace is an unfinished key rotation as held by the channel cache.
+type channelKeyGrace struct {
+	keyIndex   int
+	oldKey     string
+	oldKeyHash string
+	newKeyHash string
+	graceUntil int64
+}
+
+// channel2keyGraces caches unfinished key rotations per channel so the
+// auto-disable path and key selection never hit the database. Refreshed on
+// full sync, which also runs right after a rotation.
+var channel2keyGraces map[int][]*channelKeyGrace
+
+// channelKeyGraceFallbacks holds, per channel key index, the rotation whose
+// new key was rejected by the upstream on this node; until its grace ends,
+// requests on that key index use the old key. Each node flips over on its
+// own first rejection.
+var (
+	channelKeyGraceFallbacks     = make(map[channelKeySlot]*channelKeyGrace)
+	channelKeyGraceFallbacksLock sync.RWMutex
+)
+
+type channelKeySlot struct {
+	channelId int
+	keyIndex  int
+}
+
+func newChannelKeyGrace(rotation *ChannelKeyRotation) *channelKeyGrace {
+	return &channelKeyGrace{
+		keyIndex:   rotation.KeyIndex,
+		oldKey:     rotation.OldKey,
+		oldKeyHash: rotation.OldKeyHash,
+		newKeyHash: rotation.NewKeyHash,
+		graceUntil: rotation.GraceUntil,
+	}
+}
+
+// loadChannelKeyGraces reads the rotations still inside their grace period,
+// grouped by channel.
+func loadChannelKeyGraces(now int64) (map[int][]*channelKeyGrace, error) {
+	var rotations []*ChannelKeyRotation
+	if err := DB.Where("grace_ended = ? AND grace_until > ?", false, now).Find(&rotations).Error; err != nil {
+		return nil, err
+	}
+	graces := make(map[int][]*channelKeyGrace)
+	for _, rotation := range rotations {
+		graces[rotation.ChannelId] = append(graces[rotation.ChannelId], newChannelKeyGrace(rotation))
+	}
+	return graces, nil
+}
+
+// getChannelKeyGraces returns the active graces of a channel, from the
+// channel cache when it is enabled.
+func getChannelKeyGraces(channelId int, now int64) []*channelKeyGrace {
+	var graces []*channelKeyGrace
+	if common.MemoryCacheEnabled {
+		channelSyncLock.RLock()
+		graces = channel2keyGraces[channelId]
+		channelSyncLock.RUnlock()
+	} else {
+		var rotations []*ChannelKeyRotation
+		err := DB.Where("channel_id = ? AND grace_ended = ? AND grace_until > ?", channelId, false, now).Find(&rotations).Error
+		if err != nil {
+			common.SysError(fmt.Sprintf("failed to load channel key rotation graces: channel_id=%d, error=%v", channelId, err))
+			return nil
+		}
+		for _, rotation := range rotations {
+			graces = append(graces, newChannelKeyGrace(rotation))
+		}
+	}
+	active := make([]*channelKeyGrace, 0, len(graces))
+	for _, grace := range graces {
+		if grace.graceUntil > now {
+			active = append(active, grace)
+		}
+	}
+	return active
+}
+
+// IsChannelKeyInRotationGrace reports whether usingKey is the old or new key
+// of a rotation of this channel whose grace period has not passed yet.
+func IsChannelKeyInRotationGrace(channelId int, usingKey string) bool {
+	if channelId <= 0 || strings.TrimSpace(usingKey) == "" {
+		return false
+	}
+	graces := getChannelKeyGraces(channelId, common.GetTimestamp())
+	if len(graces) == 0 {
+		return false
+	}
+	hash := channelKeyFingerprint(usingKey)
+	for _, grace := range graces {
+		if grace.oldKeyHash == hash || grace.newKeyHash == hash {
+			return true
+		}
+	}
+	return false
+}
+
+// FallbackToPreviousChannelKey switches the key index of a rotation back to
+// its old key for the rest of the grace period after the upstream rejected
+// the new key. It returns false when usingKey is not the new key of an
+// active rotation.
+func FallbackToPreviousChannelKey(channelId int, usingKey string) bool {
+	if channelId <= 0 || strings.TrimSpace(usingKey) == "" {
+		return false
+	}
+	hash := channelKeyFingerprint(usingKey)
+	for _, grace := range getChannelKeyGraces(channelId, common.GetTimestamp()) {
+		if grace.newKeyHash != hash || grace.oldKey == "" {
+			continue
+		}
+		channelKeyGraceFallbacksLock.Lock()
+		channelKeyGraceFallbacks[channelKeySlot{channelId: channelId, keyIndex: grace.keyIndex}] = grace
+		channelKeyGraceFallbacksLock.Unlock()
+		return true
+	}
+	return false
+}
+
+// previousChannelKey returns the old key to use instead of currentKey at
+// keyIndex when the channel fell back during an active grace period. A later
+// rotation of the same index replaces currentKey and ends the fallback.
+func previousChannelKey(channelId int, keyIndex int, currentKey string) (string, bool) {
+	slot := channelKeySlot{channelId: channelId, keyIndex: keyIndex}
+	channelKeyGraceFallbacksLock.RLock()
+	grace, ok := channelKeyGraceFallbacks[slot]
+	channelKeyGraceFallbacksLock.RUnlock()
+	if !ok {
+		return "", false
+	}
+	if grace.graceUntil <= common.GetTimestamp() || grace.newKeyHash != channelKeyFingerprint(currentKey) {
+		channelKeyGraceFallbacksLock.Lock()
+		if channelKeyGraceFallbacks[slot] == grace {
+			delete(channelKeyGraceFallbacks, slot)
+		}
+		channelKeyGraceFallbacksLock.Unlock()
+		return "", false
+	}
+	return grace.oldKey, true
+}
@@ -0,0 +1,339 @@
+package model
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+
+	"gorm.io/gorm"
+)
+
+// ChannelKeyRotation records one key swap. The history only exposes a keyed
+// fingerprint and a short preview of each key, so it is safe to list.
+// Until GraceUntil both the old and the new key are treated as valid: auth
+// failures on either of them do not auto-disable the channel, and once the
+// upstream rejects the new key requests fall back to the old one. OldKey is
+// kept for that fallback only and cleared when the grace period ends.
+type ChannelKeyRotation struct {
+	Id            int    `json:"id"`
+	ChannelId     int    `json:"channel_id" gorm:"index"`
+	KeyIndex      int    `json:"key_index"`
+	OldKey        string `json:"-" gorm:"type:text"`
+	OldKeyHash    string `json:"-" gorm:"type:varchar(64);index"`
+	NewKeyHash    string `json:"-" gorm:"type:varchar(64);index"`
+	OldKeyPreview string `json:"old_key_preview" gorm:"type:varchar(32)"`
+	NewKeyPreview string `json:"new_key_preview" gorm:"type:varchar(32)"`
+	Owner         string `json:"owner" gorm:"type:varchar(128)"`
+	Reason        string `json:"reason" gorm:"type:varchar(255)"`
+	OperatorId    int    `json:"operator_id"`
+	GraceUntil    int64  `json:"grace_until" gorm:"bigint;index"`
+	GraceEnded    bool   `json:"grace_ended" gorm:"default:false;index"`
+	CreatedAt     int64  `json:"created_at" gorm:"bigint;index"`
+}
+
+// ChannelKeyRotationParams describes a rotation request. KeyIndex is ignored
+// for single-key channels.
+type ChannelKeyRotationParams struct {
+	ChannelId     int
+	KeyIndex      int
+	NewKey        string
+	GraceSeconds  int64
+	ExpiresAt     *int64
+	RotationOwner *string
+	Reason        string
+	OperatorId    int
+}
+
+var (
+	ErrChannelKeyIndexOutOfRange = errors.New("key index out of range")
+	ErrChannelKeyUnchanged       = errors.New("new key is identical to the current key")
+)
+
+func channelKeyFingerprint(key string) string {
+	return common.GenerateHMAC(strings.TrimSpace(key))
+}
+
+func channelKeyPreview(key string) string {
+	key = strings.TrimSpace(key)
+	if len(key) <= 8 {
+		return strings.Repeat("*", len(key))
+	}
+	return key[:4] + "..." + key[len(key)-4:]
+}
+
+func isJSONArrayChannelKey(key string) bool {
+	return strings.HasPrefix(strings.TrimSpace(key), "[")
+}
+
+// replaceMultiKey swaps one entry of a multi-key list, keeping the format it
+// was stored in: a JSON array stays a JSON array (the new key must be a JSON
+// value), everything else stays newline separated. It also returns the entry
+// as stored, which is what requests will later report as their using key.
+func replaceMultiKey(original string, keys []string, keyIndex int, newKey string) (string, string, error) {
+	updated := make([]string, len(keys))
+	copy(updated, keys)
+	if isJSONArrayChannelKey(original) {
+		var compacted bytes.Buffer
+		if err := json.Compact(&compacted, []byte(newKey)); err != nil {
+			return "", "", errors.New("new key must be valid JSON for JSON array keys")
+		}
+		updated[keyIndex] = compacted.String()
+		return "[" + strings.Join(updated, ",") + "]", updated[keyIndex], nil
+	}
+	if strings.Contains(newKey, "\n") {
+		return "", "", errors.New("new key must be a single key")
+	}
+	updated[keyIndex] = newKey
+	return strings.Join(updated, "\n"), newKey, nil
+}
+
+func ensureChannelKeyMetadata(info *ChannelInfo) {
+	if info.KeyExpiresAt == nil {
+		info.KeyExpiresAt = make(map[int]int64)
+	}
+	if info.KeyRotationOwner == nil {
+		info.KeyRotationOwner = make(map[int]string)
+	}
+	if info.KeyExpiryWarnedAt == nil {
+		info.KeyExpiryWarnedAt = make(map[int]int64)
+	}
+}
+
+// ApplyKeyMetadata sets expiry and owner of one key index. A zero expiry
+// clears it; an empty owner clears the owner.
+func (info *ChannelInfo) ApplyKeyMetadata(keyIndex int, expiresAt *int64, owner *string) {
+	ensureChannelKeyMetadata(info)
+	if expiresAt != nil {
+		if *expiresAt > 0 {
+			info.KeyExpiresAt[keyIndex] = *expiresAt
+		} else {
+			delete(info.KeyExpiresAt, keyIndex)
+		}
+		delete(info.KeyExpiryWarnedAt, keyIndex)
+	}
+	if owner != nil {
+		if trimmed := strings.TrimSpace(*owner); trimmed != "" {
+			info.KeyRotationOwner[keyIndex] = trimmed
+		} else {
+			delete(info.KeyRotationOwner, keyIndex)
+		}
+	}
+}
+
+// RemapKeyMetadata moves per-key metadata after keys were removed. indexMap
+// maps old indexes to new ones; indexes missing from it are dropped.
+func (info *ChannelInfo) RemapKeyMetadata(indexMap map[int]int) {
+	remapInt64 := func(src map[int]int64) map[int]int64 {
+		if src == nil {
+			return nil
+		}
+		dst := make(map[int]int64, len(src))
+		for oldIndex, v := range src {
+			if newIndex, ok := indexMap[oldIndex]; ok {
+				dst[newIndex] = v
+			}
+		}
+		return dst
+	}
+	info.KeyExpiresAt = remapInt64(info.KeyExpiresAt)
+	info.KeyExpiryWarnedAt = remapInt64(info.KeyExpiryWarnedAt)
+	if info.KeyRotationOwner != nil {
+		owners := make(map[int]string, len(info.KeyRotationOwner))
+		for oldIndex, v := range info.KeyRotationOwner {
+			if newIndex, ok := indexMap[oldIndex]; ok {
+				owners[newIndex] = v
+			}
+		}
+		info.KeyRotationOwner = owners
+	}
+}
+
+// RotateChannelKey swaps a channel key (or one multi-key index) and records
+// the rotation in the same transaction, so concurrent rotations of the same
+// channel serialize on the row lock and never lose an update.
+func RotateChannelKey(params ChannelKeyRotationParams) (*ChannelKeyRotation, error) {
+	newKey := strings.TrimSpace(params.NewKey)
+	if newKey == "" {
+		return nil, errors.New("new key is empty")
+	}
+
+	var rotation *ChannelKeyRotation
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		channel := &Channel{}
+		if err := lockForUpdate(tx).Where("id = ?", params.ChannelId).First(channel).Error; err != nil {
+			return err
+		}
+
+		keyIndex := 0
+		var oldKey string
+		storedNewKey := newKey
+		if channel.ChannelInfo.IsMultiKey {
+			keys := channel.GetKeys()
+			if params.KeyIndex < 0 || params.KeyIndex >= len(keys) {
+				return ErrChannelKeyIndexOutOfRange
+			}
+			keyIndex = params.KeyIndex
+			oldKey = keys[keyIndex]
+			joined, stored, err := replaceMultiKey(channel.Key, keys, keyIndex, newKey)
+			if err != nil {
+				return err
+			}
+			channel.Key = joined
+			storedNewKey = stored
+		} else {
+			oldKey = channel.Key
+			channel.Key = newKey
+		}
+		if strings.TrimSpace(oldKey) == storedNewKey {
+			return ErrChannelKeyUnchanged
+		}
+
+		info := channel.ChannelInfo
+		// 新密钥的到期时间与旧密钥无关，未显式指定时清空
+		expiresAt := params.ExpiresAt
+		if expiresAt == nil {
+			zero := int64(0)
+			expiresAt = &zero
+		}
+		info.ApplyKeyMetadata(keyIndex, expiresAt, params.RotationOwner)
+		// 被替换的是已禁用的密钥时，新密钥不沿用旧密钥的禁用状态
+		if channel.ChannelInfo.IsMultiKey {
+			delete(info.MultiKeyStatusList, keyIndex)
+			delete(info.MultiKeyDisabledReason, keyIndex)
+			delete(info.MultiKeyDisabledTime, keyIndex)
+		}
+
+		if err := tx.Model(&Channel{}).Where("id = ?", channel.Id).Updates(map[string]interface{}{
+			"key":          channel.Key,
+			"channel_info": info,
+		}).Error; err != nil {
+			return err
+		}
+
+		now := common.GetTimestamp()
+		rotation = &ChannelKeyRotation{
+			ChannelId:     channel.Id,
+			KeyIndex:      keyIndex,
+			OldKey:        oldKey,
+			OldKeyHash:    channelKeyFingerprint(oldKey),
+			NewKeyHash:    channelKeyFingerprint(storedNewKey),
+			OldKeyPreview: channelKeyPreview(oldKey),
+			NewKeyPreview: channelKeyPreview(storedNewKey),
+			Owner:         info.KeyRotationOwner[keyIndex],
+			Reason:        params.Reason,
+			OperatorId:    params.OperatorId,
+			GraceUntil:    now + params.GraceSeconds,
+			GraceEnded:    params.GraceSeconds <= 0,
+			CreatedAt:     now,
+		}
+		if rotation.GraceEnded {
+			rotation.OldKey = ""
+		}
+		return tx.Create(rotation).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return rotation, nil
+}
+
+// UpdateChannelKeyMetadata sets expiry/owner of a key without rotating it.
+func UpdateChannelKeyMetadata(channelId int, keyIndex int, expiresAt *int64, owner *string) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		channel := &Channel{}
+		if err := lockForUpdate(tx).Where("id = ?", channelId).First(channel).Error; err != nil {
+			return err
+		}
+		if channel.ChannelInfo.IsMultiKey {
+			if keyIndex < 0 || keyIndex >= len(channel.GetKeys()) {
+				return ErrChannelKeyIndexOutOfRange
+			}
+		} else {
+			keyIndex = 0
+		}
+		info := channel.ChannelInfo
+		info.ApplyKeyMetadata(keyIndex, expiresAt, owner)
+		return tx.Model(&Channel{}).Where("id = ?", channel.Id).Update("channel_info", info).Error
+	})
+}
+
+func GetChannelKeyRotationsByChannelID(channelId int, startIdx int, num int) ([]*ChannelKeyRotation, int64, error) {
+	var rotations []*ChannelKeyRotation
+	var total int64
+	query := DB.Model(&ChannelKeyRotation{}).Where("channel_id = ?", channelId)
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err := query.Order("id desc").Limit(num).Offset(startIdx).Find(&rotations).Error
+	return rotations, total, err
+}
+
+// ClaimEndedChannelKeyRotationGraces marks rotations whose grace period has
+// passed, drops their retained old key and returns them; each row is claimed
+// once even with several nodes.
+func ClaimEndedChannelKeyRotationGraces(now int64, limit int) ([]*ChannelKeyRotation, error) {
+	var candidates []*ChannelKeyRotation
+	if err := DB.Where("grace_ended = ? AND grace_until <= ?", false, now).
+		Order("id asc").Limit(limit).Find(&candidates).Error; err != nil {
+		return nil, err
+	}
+	claimed := make([]*ChannelKeyRotation, 0, len(candidates))
+	for _, rotation := range candidates {
+		result := DB.Model(&ChannelKeyRotation{}).
+			Where("id = ? AND grace_ended = ?", rotation.Id, false).
+			Updates(map[string]interface{}{"grace_ended": true, "old_key": ""})
+		if result.Error != nil {
+			return claimed, result.Error
+		}
+		if result.RowsAffected == 1 {
+			rotation.GraceEnded = true
+			claimed = append(claimed, rotation)
+		}
+	}
+	return claimed, nil
+}
+
+// GetChannelsWithKeyExpiry returns channels that carry key expiry metadata.
+// channel_info is a JSON column whose operators differ per database, so the
+// filtering happens here rather than in SQL.
+func GetChannelsWithKeyExpiry() ([]*Channel, error) {
+	var channels []*Channel
+	if err := DB.Select("id", "name", "status", "channel_info").Order("id asc").Find(&channels).Error; err != nil {
+		return nil, err
+	}
+	result := make([]*Channel, 0)
+	for _, channel := range channels {
+		if len(channel.ChannelInfo.KeyExpiresAt) > 0 {
+			result = append(result, channel)
+		}
+	}
+	return result, nil
+}
+
+// MarkChannelKeyExpiryWarned remembers that the expiry expiresAt of a key was
+// announced. It returns false when the key's expiry changed meanwhile or the
+// warning was already recorded (e.g. by another node).
+func MarkChannelKeyExpiryWarned(channelId int, keyIndex int, expiresAt int64) (bool, error) {
+	marked := false
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		channel := &Channel{}
+		if err := lockForUpdate(tx).Select("id", "channel_info").Where("id = ?", channelId).First(channel).Error; err != nil {
+			return err
+		}
+		info := channel.ChannelInfo
+		if info.KeyExpiresAt[keyIndex] != expiresAt || info.KeyExpiryWarnedAt[keyIndex] == expiresAt {
+			return nil
+		}
+		ensureChannelKeyMetadata(&info)
+		info.KeyExpiryWarnedAt[keyIndex] = expiresAt
+		if err := tx.Model(&Channel{}).Where("id = ?", channelId).Update("channel_info", info).Error; err != nil {
+			return err
+		}
+		marked = true
+		return nil
+	})
+	return marked, err
+}
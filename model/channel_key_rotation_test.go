@@ -0,0 +1,140 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/stretchr/testify/require"
+)
+
+func setupKeyRotationChannel(t *testing.T, channel *Channel) {
+	t.Helper()
+	require.NoError(t, DB.AutoMigrate(&ChannelKeyRotation{}))
+	require.NoError(t, DB.Create(channel).Error)
+	t.Cleanup(func() {
+		DB.Exec("DELETE FROM channels")
+		DB.Exec("DELETE FROM channel_key_rotations")
+	})
+}
+
+func TestRotateChannelKeyMultiKeyIndexWithGrace(t *testing.T) {
+	channel := &Channel{Name: "rotate-multi", Key: "sk-old-aaaaaaaa\nsk-keep-bbbbbbbb"}
+	channel.ChannelInfo.IsMultiKey = true
+	channel.ChannelInfo.MultiKeySize = 2
+	channel.ChannelInfo.KeyExpiresAt = map[int]int64{0: 100}
+	channel.ChannelInfo.KeyExpiryWarnedAt = map[int]int64{0: 100}
+	channel.ChannelInfo.MultiKeyStatusList = map[int]int{0: common.ChannelStatusAutoDisabled, 1: common.ChannelStatusManuallyDisabled}
+	channel.ChannelInfo.MultiKeyDisabledReason = map[int]string{0: "invalid api key", 1: "manual"}
+	channel.ChannelInfo.MultiKeyDisabledTime = map[int]int64{0: 100, 1: 100}
+	setupKeyRotationChannel(t, channel)
+
+	owner := "platform-team"
+	expiresAt := common.GetTimestamp() + 86400
+	rotation, err := RotateChannelKey(ChannelKeyRotationParams{
+		ChannelId:     channel.Id,
+		KeyIndex:      0,
+		NewKey:        "sk-new-cccccccc",
+		GraceSeconds:  600,
+		ExpiresAt:     &expiresAt,
+		RotationOwner: &owner,
+	})
+	require.NoError(t, err)
+	require.Equal(t, "platform-team", rotation.Owner)
+	require.False(t, rotation.GraceEnded)
+
+	stored, err := GetChannelById(channel.Id, true)
+	require.NoError(t, err)
+	require.Equal(t, "sk-new-cccccccc\nsk-keep-bbbbbbbb", stored.Key)
+	require.Equal(t, expiresAt, stored.ChannelInfo.KeyExpiresAt[0])
+	require.Zero(t, stored.ChannelInfo.KeyExpiryWarnedAt[0])
+	// The new key starts enabled; other keys keep their status.
+	require.NotContains(t, stored.ChannelInfo.MultiKeyStatusList, 0)
+	require.NotContains(t, stored.ChannelInfo.MultiKeyDisabledReason, 0)
+	require.NotContains(t, stored.ChannelInfo.MultiKeyDisabledTime, 0)
+	require.Equal(t, common.ChannelStatusManuallyDisabled, stored.ChannelInfo.MultiKeyStatusList[1])
+
+	// Both keys are tolerated during the grace period, unrelated keys are not.
+	require.True(t, IsChannelKeyInRotationGrace(channel.Id, "sk-old-aaaaaaaa"))
+	require.True(t, IsChannelKeyInRotationGrace(channel.Id, "sk-new-cccccccc"))
+	require.False(t, IsChannelKeyInRotationGrace(channel.Id, "sk-keep-bbbbbbbb"))
+
+	_, err = RotateChannelKey(ChannelKeyRotationParams{ChannelId: channel.Id, KeyIndex: 5, NewKey: "sk-x"})
+	require.ErrorIs(t, err, ErrChannelKeyIndexOutOfRange)
+	_, err = RotateChannelKey(ChannelKeyRotationParams{ChannelId: channel.Id, KeyIndex: 1, NewKey: "sk-keep-bbbbbbbb"})
+	require.ErrorIs(t, err, ErrChannelKeyUnchanged)
+}
+
+func TestClaimEndedChannelKeyRotationGraces(t *testing.T) {
+	channel := &Channel{Name: "rotate-single", Key: "sk-single-old"}
+	setupKeyRotationChannel(t, channel)
+
+	rotation, err := RotateChannelKey(ChannelKeyRotationParams{ChannelId: channel.Id, NewKey: "sk-single-new", GraceSeconds: 60})
+	require.NoError(t, err)
+
+	claimed, err := ClaimEndedChannelKeyRotationGraces(rotation.GraceUntil-1, 10)
+	require.NoError(t, err)
+	require.Empty(t, claimed)
+
+	claimed, err = ClaimEndedChannelKeyRotationGraces(rotation.GraceUntil, 10)
+	require.NoError(t, err)
+	require.Len(t, claimed, 1)
+
+	// The retained old key is dropped together with the grace period.
+	var stored ChannelKeyRotation
+	require.NoError(t, DB.First(&stored, rotation.Id).Error)
+	require.True(t, stored.GraceEnded)
+	require.Empty(t, stored.OldKey)
+
+	claimed, err = ClaimEndedChannelKeyRotationGraces(rotation.GraceUntil, 10)
+	require.NoError(t, err)
+	require.Empty(t, claimed)
+}
+
+func TestChannelKeyGraceFallsBackToPreviousKeyFromCache(t *testing.T) {
+	oldMemoryCacheEnabled := common.MemoryCacheEnabled
+	t.Cleanup(func() {
+		common.MemoryCacheEnabled = oldMemoryCacheEnabled
+		InitChannelCache()
+	})
+	common.MemoryCacheEnabled = true
+
+	channel := &Channel{Name: "rotate-fallback", Key: "sk-fallback-old"}
+	setupKeyRotationChannel(t, channel)
+	_, err := RotateChannelKey(ChannelKeyRotationParams{ChannelId: channel.Id, NewKey: "sk-fallback-new", GraceSeconds: 600})
+	require.NoError(t, err)
+	InitChannelCache()
+
+	// Grace lookups are served from the channel cache, not the database.
+	DB.Exec("DELETE FROM channel_key_rotations")
+	require.True(t, IsChannelKeyInRotationGrace(channel.Id, "sk-fallback-old"))
+	require.True(t, IsChannelKeyInRotationGrace(channel.Id, "sk-fallback-new"))
+
+	cached, err := CacheGetChannel(channel.Id)
+	require.NoError(t, err)
+	key, _, apiErr := cached.GetNextEnabledKey()
+	require.Nil(t, apiErr)
+	require.Equal(t, "sk-fallback-new", key)
+
+	// Only a rejected new key triggers the fallback.
+	require.False(t, FallbackToPreviousChannelKey(channel.Id, "sk-fallback-old"))
+	require.True(t, FallbackToPreviousChannelKey(channel.Id, "sk-fallback-new"))
+	key, _, apiErr = cached.GetNextEnabledKey()
+	require.Nil(t, apiErr)
+	require.Equal(t, "sk-fallback-old", key)
+
+	// A later rotation replaces the rejected key and ends the fallback.
+	cached.Key = "sk-fallback-newer"
+	key, _, apiErr = cached.GetNextEnabledKey()
+	require.Nil(t, apiErr)
+	require.Equal(t, "sk-fallback-newer", key)
+}
+
+func TestChannelInfoRemapKeyMetadata(t *testing.T) {
+	info := ChannelInfo{
+		KeyExpiresAt:     map[int]int64{0: 10, 1: 11, 2: 12},
+		KeyRotationOwner: map[int]string{2: "ops"},
+	}
+	info.RemapKeyMetadata(map[int]int{0: 0, 2: 1})
+	require.Equal(t, map[int]int64{0: 10, 1: 12}, info.KeyExpiresAt)
+	require.Equal(t, map[int]string{1: "ops"}, info.KeyRotationOwner)
+}
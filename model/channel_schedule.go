@@ -0,0 +1,133 @@
+package model
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+)
+
+// channel2schedules caches parsed schedule windows so selection does not
+// re-parse channel settings per request. Refreshed on full sync and on
+// CacheUpdateChannel.
+var channel2schedules map[int][]dto.ChannelScheduleWindow
+
+// getChannelScheduleState evaluates the cached windows of a channel.
+// Callers must hold channelSyncLock.
+func getChannelScheduleState(channelId int, now time.Time) dto.ChannelScheduleState {
+	return dto.EvaluateChannelSchedules(channel2schedules[channelId], now)
+}
+
+// GetScheduleState evaluates the channel's own schedule settings. It parses
+// the settings on every call and is meant for paths without the memory cache.
+func (channel *Channel) GetScheduleState(now time.Time) dto.ChannelScheduleState {
+	return dto.EvaluateChannelSchedules(channel.GetSetting().Schedules, now)
+}
+
+// applyScheduleWeight scales a routing weight by the active boost windows.
+func applyScheduleWeight(weight int, state dto.ChannelScheduleState) int {
+	if state.WeightMultiplier == 1 {
+		return weight
+	}
+	scaled := int(math.Round(float64(weight) * state.WeightMultiplier))
+	if scaled < 0 {
+		return 0
+	}
+	return scaled
+}
+
+// filterChannelsBySchedule drops channels that are outside their eligible
+// windows or inside an exclude window. Callers must hold channelSyncLock.
+func filterChannelsBySchedule(channels []int, now time.Time) []int {
+	if len(channels) == 0 || len(channel2schedules) == 0 {
+		return channels
+	}
+	filtered := make([]int, 0, len(channels))
+	for _, channelId := range channels {
+		if getChannelScheduleState(channelId, now).Eligible {
+			filtered = append(filtered, channelId)
+		}
+	}
+	return filtered
+}
+
+// filterAbilitiesBySchedule is the database-path counterpart of
+// filterChannelsBySchedule; active boost windows are folded into the weight.
+func filterAbilitiesBySchedule(abilities []Ability, now time.Time) ([]Ability, error) {
+	if len(abilities) == 0 {
+		return abilities, nil
+	}
+	channelIDs := make([]int, 0, len(abilities))
+	for _, a := range abilities {
+		channelIDs = append(channelIDs, a.ChannelId)
+	}
+	channelByID, err := getChannelMapByIDs(channelIDs)
+	if err != nil {
+		return nil, err
+	}
+	filtered := make([]Ability, 0, len(abilities))
+	for _, a := range abilities {
+		if ch, ok := channelByID[a.ChannelId]; ok {
+			state := ch.GetScheduleState(now)
+			if !state.Eligible {
+				continue
+			}
+			a.Weight = uint(applyScheduleWeight(int(a.Weight), state))
+		}
+		filtered = append(filtered, a)
+	}
+	return filtered, nil
+}
+
+// ChannelScheduleSnapshot is the schedule state of one scheduled channel.
+type ChannelScheduleSnapshot struct {
+	ChannelId   int
+	ChannelName string
+	State       dto.ChannelScheduleState
+}
+
+// GetChannelScheduleSnapshots evaluates every cached channel that has
+// schedule windows, ordered by channel id.
+func GetChannelScheduleSnapshots(now time.Time) ([]ChannelScheduleSnapshot, error) {
+	if !common.MemoryCacheEnabled {
+		var channels []*Channel
+		if err := DB.Select("id", "name", "setting").Where("setting LIKE ?", "%\"schedules\"%").Order("id").Find(&channels).Error; err != nil {
+			return nil, err
+		}
+		snapshots := make([]ChannelScheduleSnapshot, 0, len(channels))
+		for _, channel := range channels {
+			// 只查询了部分列，不能走 GetSetting（解析失败时会回写整行）
+			var setting dto.ChannelSettings
+			if channel.Setting == nil || common.UnmarshalJsonStr(*channel.Setting, &setting) != nil || len(setting.Schedules) == 0 {
+				continue
+			}
+			snapshots = append(snapshots, ChannelScheduleSnapshot{
+				ChannelId:   channel.Id,
+				ChannelName: channel.Name,
+				State:       dto.EvaluateChannelSchedules(setting.Schedules, now),
+			})
+		}
+		return snapshots, nil
+	}
+
+	channelSyncLock.RLock()
+	defer channelSyncLock.RUnlock()
+
+	snapshots := make([]ChannelScheduleSnapshot, 0, len(channel2schedules))
+	for channelId := range channel2schedules {
+		snapshot := ChannelScheduleSnapshot{
+			ChannelId: channelId,
+			State:     getChannelScheduleState(channelId, now),
+		}
+		if channel, ok := channelsIDM[channelId]; ok {
+			snapshot.ChannelName = channel.Name
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+	sort.Slice(snapshots, func(i, j int) bool {
+		return snapshots[i].ChannelId < snapshots[j].ChannelId
+	})
+	return snapshots, nil
+}
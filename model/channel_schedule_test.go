@@ -0,0 +1,89 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetRandomSatisfiedChannelSkipsScheduledOutChannels(t *testing.T) {
+	const (
+		group = "schedule-group"
+		model = "schedule-model"
+	)
+
+	oldMemoryCacheEnabled := common.MemoryCacheEnabled
+	oldGroup2Model2Channels := group2model2channels
+	oldChannelsIDM := channelsIDM
+	oldChannel2Schedules := channel2schedules
+	t.Cleanup(func() {
+		common.MemoryCacheEnabled = oldMemoryCacheEnabled
+		group2model2channels = oldGroup2Model2Channels
+		channelsIDM = oldChannelsIDM
+		channel2schedules = oldChannel2Schedules
+	})
+
+	common.MemoryCacheEnabled = true
+	priority := int64(0)
+	weight := uint(100)
+	channelsIDM = map[int]*Channel{
+		1: {Id: 1, Priority: &priority, Weight: &weight},
+		2: {Id: 2, Priority: &priority, Weight: &weight},
+	}
+	group2model2channels = map[string]map[string][]int{
+		group: {model: {1, 2}},
+	}
+	// Channel 1 is excluded all day, every day.
+	channel2schedules = map[int][]dto.ChannelScheduleWindow{
+		1: {{Name: "always off", Action: dto.ChannelScheduleActionExclude}},
+	}
+
+	for i := 0; i < 20; i++ {
+		channel, err := GetRandomSatisfiedChannel(group, model, 0)
+		require.NoError(t, err)
+		require.Equal(t, 2, channel.Id)
+
+		channel, err = GetRandomSatisfiedChannelExclude(group, model, nil)
+		require.NoError(t, err)
+		require.Equal(t, 2, channel.Id)
+	}
+
+	channel, err := GetRandomSatisfiedChannelExclude(group, model, map[int]bool{2: true})
+	require.Error(t, err)
+	require.Nil(t, channel)
+}
+
+func TestApplyScheduleWeight(t *testing.T) {
+	require.Equal(t, 50, applyScheduleWeight(50, dto.ChannelScheduleState{WeightMultiplier: 1}))
+	require.Equal(t, 150, applyScheduleWeight(50, dto.ChannelScheduleState{WeightMultiplier: 3}))
+	require.Equal(t, 0, applyScheduleWeight(50, dto.ChannelScheduleState{WeightMultiplier: 0}))
+}
+
+func TestCacheUpdateChannelRefreshesSchedules(t *testing.T) {
+	oldMemoryCacheEnabled := common.MemoryCacheEnabled
+	oldChannelsIDM := channelsIDM
+	oldChannel2Schedules := channel2schedules
+	t.Cleanup(func() {
+		common.MemoryCacheEnabled = oldMemoryCacheEnabled
+		channelsIDM = oldChannelsIDM
+		channel2schedules = oldChannel2Schedules
+	})
+
+	common.MemoryCacheEnabled = true
+	channelsIDM = map[int]*Channel{}
+	channel2schedules = nil
+
+	channel := &Channel{Id: 501, Status: common.ChannelStatusEnabled}
+	channel.SetSetting(dto.ChannelSettings{Schedules: []dto.ChannelScheduleWindow{
+		{Name: "night", Action: dto.ChannelScheduleActionExclude, Start: "22:00", End: "06:00"},
+	}})
+	CacheUpdateChannel(channel)
+	require.Len(t, channel2schedules[501], 1)
+	require.Equal(t, "night", channel2schedules[501][0].Name)
+
+	channel.SetSetting(dto.ChannelSettings{})
+	CacheUpdateChannel(channel)
+	require.NotContains(t, channel2schedules, 501)
+}
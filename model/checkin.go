@@ -3,6 +3,7 @@ package model
 import (
 	"errors"
 	"math/rand"
+	"strconv"
 	"time"
 
 	"github.com/QuantumNous/new-api/common"
@@ -105,6 +106,12 @@ func userCheckinWithTransaction(checkin *Checkin, userId int, quotaAwarded int)
 			Update("quota", gorm.Expr("quota + ?", quotaAwarded)).Error; err != nil {
 			return errors.New("签到失败：更新额度出错")
 		}
+		if err := PostQuotaLedgerTx(tx, UserQuotaLedgerPosting(userId, quotaAwarded, QuotaLedgerReasonCheckin, QuotaLedgerRefCheckinId, strconv.Itoa(checkin.Id))); err != nil {
+			return err
+		}
+		if err := GrantQuotaLotTx(tx, userId, QuotaLotSourceCheckin, strconv.Itoa(checkin.Id), quotaAwarded, QuotaLotValidDays(QuotaLotSourceCheckin)); err != nil {
+			return err
+		}
 
 		return nil
 	})
@@ -136,6 +143,8 @@ func userCheckinWithoutTransaction(checkin *Checkin, userId int, quotaAwarded in
 		DB.Delete(checkin)
 		return nil, errors.New("签到失败：更新额度出错")
 	}
+	RecordQuotaLedger(UserQuotaLedgerPosting(userId, quotaAwarded, QuotaLedgerReasonCheckin, QuotaLedgerRefCheckinId, strconv.Itoa(checkin.Id)))
+	GrantQuotaLot(userId, QuotaLotSourceCheckin, strconv.Itoa(checkin.Id), quotaAwarded, QuotaLotValidDays(QuotaLotSourceCheckin))
 
 	return checkin, nil
 }
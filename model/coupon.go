@@ -0,0 +1,469 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+const (
+	CouponTypePercentOff   = "percent_off"   // 按百分比减免支付金额
+	CouponTypeBonusPercent = "bonus_percent" // 按百分比额外赠送额度
+)
+
+const (
+	CouponScopeAll          = "all"
+	CouponScopeTopUp        = "topup"
+	CouponScopeSubscription = "subscription"
+)
+
+const (
+	CouponStatusEnabled  = 1
+	CouponStatusDisabled = 2
+)
+
+const (
+	CouponRedemptionStatusPending   = "pending"
+	CouponRedemptionStatusCompleted = "completed"
+	CouponRedemptionStatusReleased  = "released"
+)
+
+// CouponReservationTTL 下单时预占的使用次数在该时间内计入 MaxUses / PerUserLimit，
+// 超时未支付的订单不再占用名额。
+const CouponReservationTTL int64 = 2 * 60 * 60
+
+var (
+	ErrCouponNotFound           = errors.New("优惠码不存在")
+	ErrCouponDisabled           = errors.New("优惠码已停用")
+	ErrCouponNotStarted         = errors.New("优惠码尚未生效")
+	ErrCouponExpired            = errors.New("优惠码已过期")
+	ErrCouponScopeMismatch      = errors.New("优惠码不适用于该订单类型")
+	ErrCouponPlanMismatch       = errors.New("优惠码不适用于该套餐")
+	ErrCouponMethodMismatch     = errors.New("优惠码不适用于该支付方式")
+	ErrCouponUsedUp             = errors.New("优惠码已达到使用上限")
+	ErrCouponUserLimit          = errors.New("已达到该优惠码的个人使用上限")
+	ErrCouponDiscountNotAllowed = errors.New("该支付方式不支持折扣类优惠码")
+)
+
+// Coupon 优惠码。PlanIds / PaymentMethods 为逗号分隔的白名单，为空表示不限制；
+// MaxUses / PerUserLimit 为 0 表示不限制，StartTime / EndTime 为 0 表示不限制。
+type Coupon struct {
+	Id             int            `json:"id"`
+	Code           string         `json:"code" gorm:"type:varchar(64);uniqueIndex"`
+	Name           string         `json:"name" gorm:"type:varchar(128)"`
+	Type           string         `json:"type" gorm:"type:varchar(20)"`
+	Percent        float64        `json:"percent"`
+	AppliesTo      string         `json:"applies_to" gorm:"type:varchar(20);default:'all'"`
+	PlanIds        string         `json:"plan_ids" gorm:"type:varchar(255);default:''"`
+	PaymentMethods string         `json:"payment_methods" gorm:"type:varchar(255);default:''"`
+	MaxUses        int            `json:"max_uses"`
+	PerUserLimit   int            `json:"per_user_limit"`
+	UsedCount      int            `json:"used_count"`
+	StartTime      int64          `json:"start_time" gorm:"bigint"`
+	EndTime        int64          `json:"end_time" gorm:"bigint"`
+	Status         int            `json:"status" gorm:"default:1"`
+	CreatedTime    int64          `json:"created_time" gorm:"bigint"`
+	DeletedAt      gorm.DeletedAt `json:"-" gorm:"index"`
+}
+
+// CouponRedemption 记录一次优惠码使用，与订单通过 TradeNo 关联。下单时以 pending 状态预占，
+// 订单支付成功后转为 completed 并计入 Coupon.UsedCount。
+type CouponRedemption struct {
+	Id            int     `json:"id"`
+	CouponId      int     `json:"coupon_id" gorm:"index"`
+	Code          string  `json:"code" gorm:"type:varchar(64)"`
+	UserId        int     `json:"user_id" gorm:"index"`
+	OrderType     string  `json:"order_type" gorm:"type:varchar(20)"`
+	TradeNo       string  `json:"trade_no" gorm:"type:varchar(255);uniqueIndex"`
+	PlanId        int     `json:"plan_id"`
+	PaymentMethod string  `json:"payment_method" gorm:"type:varchar(50)"`
+	OriginalMoney float64 `json:"original_money"`
+	DiscountMoney float64 `json:"discount_money"`
+	BonusPercent  float64 `json:"bonus_percent"`
+	BonusQuota    int64   `json:"bonus_quota"`
+	Status        string  `json:"status" gorm:"type:varchar(20);index"`
+	CreatedTime   int64   `json:"created_time" gorm:"bigint;index"`
+	CompletedTime int64   `json:"completed_time" gorm:"bigint"`
+}
+
+// CouponUsage 描述一次下单使用优惠码的场景
+type CouponUsage struct {
+	Code          string
+	UserId        int
+	OrderType     string // CouponScopeTopUp / CouponScopeSubscription
+	PlanId        int
+	PaymentMethod string
+}
+
+type CouponStats struct {
+	Redemptions   int64   `json:"redemptions"`
+	OriginalMoney float64 `json:"original_money"`
+	DiscountMoney float64 `json:"discount_money"`
+	BonusQuota    int64   `json:"bonus_quota"`
+}
+
+func NormalizeCouponCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+func splitCouponList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// Normalize 规范化并校验管理员提交的优惠码配置
+func (coupon *Coupon) Normalize() error {
+	coupon.Code = NormalizeCouponCode(coupon.Code)
+	if coupon.Code == "" || len(coupon.Code) > 64 {
+		return errors.New("优惠码长度必须在 1-64 之间")
+	}
+	switch coupon.Type {
+	case CouponTypePercentOff:
+		if coupon.Percent <= 0 || coupon.Percent >= 100 {
+			return errors.New("折扣比例必须大于 0 且小于 100")
+		}
+	case CouponTypeBonusPercent:
+		if coupon.Percent <= 0 || coupon.Percent > 1000 {
+			return errors.New("赠送比例必须大于 0 且不超过 1000")
+		}
+	default:
+		return fmt.Errorf("不支持的优惠码类型：%s", coupon.Type)
+	}
+	if coupon.AppliesTo == "" {
+		coupon.AppliesTo = CouponScopeAll
+	}
+	switch coupon.AppliesTo {
+	case CouponScopeAll, CouponScopeTopUp, CouponScopeSubscription:
+	default:
+		return fmt.Errorf("不支持的适用范围：%s", coupon.AppliesTo)
+	}
+	planIds := splitCouponList(coupon.PlanIds)
+	for _, id := range planIds {
+		if _, err := strconv.Atoi(id); err != nil {
+			return fmt.Errorf("套餐 ID 无效：%s", id)
+		}
+	}
+	coupon.PlanIds = strings.Join(planIds, ",")
+	coupon.PaymentMethods = strings.Join(splitCouponList(coupon.PaymentMethods), ",")
+	if coupon.MaxUses < 0 || coupon.PerUserLimit < 0 {
+		return errors.New("使用次数限制不能为负数")
+	}
+	if coupon.EndTime != 0 && coupon.EndTime <= coupon.StartTime {
+		return errors.New("结束时间必须晚于开始时间")
+	}
+	if coupon.Status == 0 {
+		coupon.Status = CouponStatusEnabled
+	}
+	return nil
+}
+
+func (coupon *Coupon) allowsPlan(planId int) bool {
+	planIds := splitCouponList(coupon.PlanIds)
+	if len(planIds) == 0 {
+		return true
+	}
+	return common.StringsContains(planIds, strconv.Itoa(planId))
+}
+
+func (coupon *Coupon) allowsPaymentMethod(method string) bool {
+	methods := splitCouponList(coupon.PaymentMethods)
+	if len(methods) == 0 {
+		return true
+	}
+	return common.StringsContains(methods, method)
+}
+
+// ApplyToMoney 返回应付金额与减免金额；赠送类优惠码不改变金额。
+func (coupon *Coupon) ApplyToMoney(money float64) (float64, float64) {
+	if coupon == nil || coupon.Type != CouponTypePercentOff {
+		return money, 0
+	}
+	dMoney := decimal.NewFromFloat(money)
+	discount := dMoney.Mul(decimal.NewFromFloat(coupon.Percent)).Div(decimal.NewFromInt(100)).Round(2)
+	return dMoney.Sub(discount).InexactFloat64(), discount.InexactFloat64()
+}
+
+// BonusPercent 返回赠送比例，折扣类优惠码为 0
+func (coupon *Coupon) BonusPercent() float64 {
+	if coupon == nil || coupon.Type != CouponTypeBonusPercent {
+		return 0
+	}
+	return coupon.Percent
+}
+
+// countActiveCouponRedemptions 统计已完成与仍在预占期内的使用次数
+func countActiveCouponRedemptions(tx *gorm.DB, couponId int, userId int, now int64) (int64, error) {
+	query := tx.Model(&CouponRedemption{}).Where("coupon_id = ?", couponId).
+		Where("status = ? OR (status = ? AND created_time > ?)",
+			CouponRedemptionStatusCompleted, CouponRedemptionStatusPending, now-CouponReservationTTL)
+	if userId > 0 {
+		query = query.Where("user_id = ?", userId)
+	}
+	var count int64
+	err := query.Count(&count).Error
+	return count, err
+}
+
+func checkCouponUsage(tx *gorm.DB, coupon *Coupon, usage CouponUsage, now int64) error {
+	if coupon.Status != CouponStatusEnabled {
+		return ErrCouponDisabled
+	}
+	if coupon.StartTime != 0 && now < coupon.StartTime {
+		return ErrCouponNotStarted
+	}
+	if coupon.EndTime != 0 && now >= coupon.EndTime {
+		return ErrCouponExpired
+	}
+	if coupon.AppliesTo != CouponScopeAll && coupon.AppliesTo != usage.OrderType {
+		return ErrCouponScopeMismatch
+	}
+	if usage.OrderType == CouponScopeSubscription && !coupon.allowsPlan(usage.PlanId) {
+		return ErrCouponPlanMismatch
+	}
+	if !coupon.allowsPaymentMethod(usage.PaymentMethod) {
+		return ErrCouponMethodMismatch
+	}
+	if coupon.MaxUses > 0 {
+		used, err := countActiveCouponRedemptions(tx, coupon.Id, 0, now)
+		if err != nil {
+			return err
+		}
+		if used >= int64(coupon.MaxUses) {
+			return ErrCouponUsedUp
+		}
+	}
+	if coupon.PerUserLimit > 0 {
+		used, err := countActiveCouponRedemptions(tx, coupon.Id, usage.UserId, now)
+		if err != nil {
+			return err
+		}
+		if used >= int64(coupon.PerUserLimit) {
+			return ErrCouponUserLimit
+		}
+	}
+	return nil
+}
+
+// CheckCoupon 校验优惠码在给定场景下是否可用，不会预占使用次数
+func CheckCoupon(usage CouponUsage) (*Coupon, error) {
+	var coupon Coupon
+	if err := DB.Where("code = ?", NormalizeCouponCode(usage.Code)).First(&coupon).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrCouponNotFound
+		}
+		return nil, err
+	}
+	if err := checkCouponUsage(DB, &coupon, usage, common.GetTimestamp()); err != nil {
+		return nil, err
+	}
+	return &coupon, nil
+}
+
+// ReserveCoupon 在创建订单前预占一次优惠码使用，锁定优惠码行以避免并发超发
+func ReserveCoupon(coupon *Coupon, usage CouponUsage, tradeNo string, originalMoney float64, discountMoney float64) error {
+	if coupon == nil || tradeNo == "" {
+		return errors.New("invalid coupon reservation")
+	}
+	return DB.Transaction(func(tx *gorm.DB) error {
+		var locked Coupon
+		if err := lockForUpdate(tx).Where("id = ?", coupon.Id).First(&locked).Error; err != nil {
+			return ErrCouponNotFound
+		}
+		now := common.GetTimestamp()
+		if err := checkCouponUsage(tx, &locked, usage, now); err != nil {
+			return err
+		}
+		return tx.Create(&CouponRedemption{
+			CouponId:      locked.Id,
+			Code:          locked.Code,
+			UserId:        usage.UserId,
+			OrderType:     usage.OrderType,
+			TradeNo:       tradeNo,
+			PlanId:        usage.PlanId,
+			PaymentMethod: usage.PaymentMethod,
+			OriginalMoney: originalMoney,
+			DiscountMoney: discountMoney,
+			BonusPercent:  locked.BonusPercent(),
+			Status:        CouponRedemptionStatusPending,
+			CreatedTime:   now,
+		}).Error
+	})
+}
+
+// ReleaseCouponRedemption 释放未支付订单预占的优惠码使用次数
+func ReleaseCouponRedemption(tradeNo string) error {
+	return releaseCouponRedemptionTx(DB, tradeNo)
+}
+
+func releaseCouponRedemptionTx(tx *gorm.DB, tradeNo string) error {
+	if tradeNo == "" {
+		return nil
+	}
+	return tx.Model(&CouponRedemption{}).
+		Where("trade_no = ? AND status = ?", tradeNo, CouponRedemptionStatusPending).
+		Update("status", CouponRedemptionStatusReleased).Error
+}
+
+// completeCouponRedemptionTx 在订单支付成功的事务中确认优惠码使用，返回按 baseQuota 计算的赠送额度。
+// 预占已过期或被释放的订单仍然按下单时的优惠结算，因为用户已按优惠后的金额付款。
+func completeCouponRedemptionTx(tx *gorm.DB, tradeNo string, baseQuota int64) (int64, error) {
+	var redemption CouponRedemption
+	err := lockForUpdate(tx).Where("trade_no = ?", tradeNo).First(&redemption).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	if redemption.Status == CouponRedemptionStatusCompleted {
+		return 0, nil
+	}
+	var bonusQuota int64
+	if redemption.BonusPercent > 0 && baseQuota > 0 {
+		bonusQuota = decimal.NewFromInt(baseQuota).Mul(decimal.NewFromFloat(redemption.BonusPercent)).Div(decimal.NewFromInt(100)).IntPart()
+	}
+	if err := tx.Model(&redemption).Updates(map[string]interface{}{
+		"status":         CouponRedemptionStatusCompleted,
+		"bonus_quota":    bonusQuota,
+		"completed_time": common.GetTimestamp(),
+	}).Error; err != nil {
+		return 0, err
+	}
+	if err := tx.Model(&Coupon{}).Unscoped().Where("id = ?", redemption.CouponId).
+		Update("used_count", gorm.Expr("used_count + ?", 1)).Error; err != nil {
+		return 0, err
+	}
+	return bonusQuota, nil
+}
+
+// grantTopUpCouponBonusTx 确认充值订单的优惠码使用，并把赠送额度记入用户钱包与订单
+func grantTopUpCouponBonusTx(tx *gorm.DB, topUp *TopUp, baseQuota int) (int, error) {
+	if topUp.CouponCode == "" {
+		return 0, nil
+	}
+	bonus, err := completeCouponRedemptionTx(tx, topUp.TradeNo, int64(baseQuota))
+	if err != nil || bonus <= 0 {
+		return 0, err
+	}
+	if err := tx.Model(&User{}).Where("id = ?", topUp.UserId).Update("quota", gorm.Expr("quota + ?", bonus)).Error; err != nil {
+		return 0, err
+	}
+	if err := tx.Model(&TopUp{}).Where("id = ?", topUp.Id).Update("bonus_quota", bonus).Error; err != nil {
+		return 0, err
+	}
+	topUp.BonusQuota = bonus
+	if err := PostQuotaLedgerTx(tx, UserQuotaLedgerPosting(topUp.UserId, int(bonus), QuotaLedgerReasonCouponBonus, QuotaLedgerRefTradeNo, topUp.TradeNo)); err != nil {
+		return 0, err
+	}
+	if err := GrantQuotaLotTx(tx, topUp.UserId, QuotaLotSourceCouponBonus, topUp.TradeNo, int(bonus), QuotaLotValidDays(QuotaLotSourceCouponBonus)); err != nil {
+		return 0, err
+	}
+	return int(bonus), nil
+}
+
+// grantSubscriptionCouponBonusTx 确认订阅订单的优惠码使用，赠送额度加到新订阅的总额度上
+func grantSubscriptionCouponBonusTx(tx *gorm.DB, order *SubscriptionOrder, sub *UserSubscription) error {
+	if order.CouponCode == "" || sub == nil {
+		return nil
+	}
+	bonus, err := completeCouponRedemptionTx(tx, order.TradeNo, sub.AmountTotal)
+	if err != nil || bonus <= 0 {
+		return err
+	}
+	if err := tx.Model(&UserSubscription{}).Where("id = ?", sub.Id).
+		Update("amount_total", gorm.Expr("amount_total + ?", bonus)).Error; err != nil {
+		return err
+	}
+	sub.AmountTotal += bonus
+	return PostQuotaLedgerTx(tx, QuotaLedgerPosting{
+		Account: QuotaLedgerSubscriptionAccount(sub.Id),
+		UserId:  sub.UserId,
+		Amount:  bonus,
+		Reason:  QuotaLedgerReasonCouponBonus,
+		RefType: QuotaLedgerRefTradeNo,
+		RefId:   order.TradeNo,
+	})
+}
+
+func GetAllCoupons(keyword string, startIdx int, num int) (coupons []*Coupon, total int64, err error) {
+	query := DB.Model(&Coupon{})
+	if keyword = strings.TrimSpace(keyword); keyword != "" {
+		query = query.Where("code LIKE ? OR name LIKE ?", NormalizeCouponCode(keyword)+"%", keyword+"%")
+	}
+	if err = query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err = query.Order("id desc").Limit(num).Offset(startIdx).Find(&coupons).Error
+	return coupons, total, err
+}
+
+func GetCouponById(id int) (*Coupon, error) {
+	var coupon Coupon
+	err := DB.Where("id = ?", id).First(&coupon).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrCouponNotFound
+	}
+	return &coupon, err
+}
+
+func (coupon *Coupon) Insert() error {
+	if err := coupon.Normalize(); err != nil {
+		return err
+	}
+	coupon.Id = 0
+	coupon.UsedCount = 0
+	coupon.CreatedTime = common.GetTimestamp()
+	return DB.Create(coupon).Error
+}
+
+// Update 更新优惠码配置；已使用次数与创建时间不可修改
+func (coupon *Coupon) Update() error {
+	if err := coupon.Normalize(); err != nil {
+		return err
+	}
+	return DB.Model(coupon).Select("code", "name", "type", "percent", "applies_to", "plan_ids",
+		"payment_methods", "max_uses", "per_user_limit", "start_time", "end_time", "status").Updates(coupon).Error
+}
+
+func DeleteCouponById(id int) error {
+	result := DB.Delete(&Coupon{}, "id = ?", id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrCouponNotFound
+	}
+	return nil
+}
+
+func GetCouponRedemptions(couponId int, startIdx int, num int) (redemptions []*CouponRedemption, total int64, err error) {
+	query := DB.Model(&CouponRedemption{}).Where("coupon_id = ?", couponId)
+	if err = query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err = query.Order("id desc").Limit(num).Offset(startIdx).Find(&redemptions).Error
+	return redemptions, total, err
+}
+
+// GetCouponStats 汇总优惠码已完成订单的原价、减免金额与赠送额度
+func GetCouponStats(couponId int) (*CouponStats, error) {
+	var stats CouponStats
+	err := DB.Model(&CouponRedemption{}).
+		Select("count(*) AS redemptions, COALESCE(sum(original_money), 0) AS original_money, "+
+			"COALESCE(sum(discount_money), 0) AS discount_money, COALESCE(sum(bonus_quota), 0) AS bonus_quota").
+		Where("coupon_id = ? AND status = ?", couponId, CouponRedemptionStatusCompleted).
+		Scan(&stats).Error
+	return &stats, err
+}
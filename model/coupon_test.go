@@ -0,0 +1,221 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func setupCoupons(t *testing.T) {
+	t.Helper()
+	require.NoError(t, DB.AutoMigrate(&Coupon{}, &CouponRedemption{}))
+	truncateTables(t)
+	t.Cleanup(func() {
+		DB.Exec("DELETE FROM coupons")
+		DB.Exec("DELETE FROM coupon_redemptions")
+		DB.Exec("DELETE FROM quota_ledger_entries")
+		DB.Exec("DELETE FROM quota_ledger_accounts")
+	})
+}
+
+func TestCouponApplyToMoney(t *testing.T) {
+	coupon := &Coupon{Type: CouponTypePercentOff, Percent: 15}
+	pay, discount := coupon.ApplyToMoney(9.99)
+	require.InDelta(t, 1.5, discount, 0.0001)
+	require.InDelta(t, 8.49, pay, 0.0001)
+
+	bonus := &Coupon{Type: CouponTypeBonusPercent, Percent: 15}
+	pay, discount = bonus.ApplyToMoney(9.99)
+	require.InDelta(t, 9.99, pay, 0.0001)
+	require.Zero(t, discount)
+
+	var none *Coupon
+	pay, discount = none.ApplyToMoney(5)
+	require.InDelta(t, 5, pay, 0.0001)
+	require.Zero(t, discount)
+}
+
+func TestCouponEnforcesRestrictionsAndUsageLimits(t *testing.T) {
+	setupCoupons(t)
+	coupon := &Coupon{
+		Code:           " spring20 ",
+		Type:           CouponTypePercentOff,
+		Percent:        20,
+		AppliesTo:      CouponScopeTopUp,
+		PaymentMethods: "stripe, waffo",
+		MaxUses:        2,
+		PerUserLimit:   1,
+	}
+	require.NoError(t, coupon.Insert())
+	require.Equal(t, "SPRING20", coupon.Code)
+
+	usage := func(userId int) CouponUsage {
+		return CouponUsage{Code: "spring20", UserId: userId, OrderType: CouponScopeTopUp, PaymentMethod: PaymentMethodStripe}
+	}
+
+	_, err := CheckCoupon(CouponUsage{Code: "spring20", UserId: 1, OrderType: CouponScopeTopUp, PaymentMethod: "alipay"})
+	require.ErrorIs(t, err, ErrCouponMethodMismatch)
+	_, err = CheckCoupon(CouponUsage{Code: "spring20", UserId: 1, OrderType: CouponScopeSubscription, PaymentMethod: PaymentMethodStripe})
+	require.ErrorIs(t, err, ErrCouponScopeMismatch)
+	_, err = CheckCoupon(CouponUsage{Code: "unknown", UserId: 1, OrderType: CouponScopeTopUp})
+	require.ErrorIs(t, err, ErrCouponNotFound)
+
+	checked, err := CheckCoupon(usage(1))
+	require.NoError(t, err)
+	require.NoError(t, ReserveCoupon(checked, usage(1), "trade-1", 10, 2))
+	require.ErrorIs(t, ReserveCoupon(checked, usage(1), "trade-1b", 10, 2), ErrCouponUserLimit)
+	require.NoError(t, ReserveCoupon(checked, usage(2), "trade-2", 10, 2))
+	require.ErrorIs(t, ReserveCoupon(checked, usage(3), "trade-3", 10, 2), ErrCouponUsedUp)
+
+	// 释放未支付订单后名额归还
+	require.NoError(t, ReleaseCouponRedemption("trade-2"))
+	require.NoError(t, ReserveCoupon(checked, usage(3), "trade-3", 10, 2))
+
+	// 预占过期后不再占用名额
+	require.NoError(t, DB.Model(&CouponRedemption{}).Where("trade_no = ?", "trade-3").
+		Update("created_time", common.GetTimestamp()-CouponReservationTTL-1).Error)
+	require.NoError(t, ReserveCoupon(checked, usage(4), "trade-4", 10, 2))
+}
+
+func TestCouponValidityWindow(t *testing.T) {
+	setupCoupons(t)
+	now := common.GetTimestamp()
+	future := &Coupon{Code: "FUTURE", Type: CouponTypeBonusPercent, Percent: 10, StartTime: now + 3600}
+	require.NoError(t, future.Insert())
+	expired := &Coupon{Code: "EXPIRED", Type: CouponTypeBonusPercent, Percent: 10, StartTime: now - 7200, EndTime: now - 3600}
+	require.NoError(t, expired.Insert())
+
+	_, err := CheckCoupon(CouponUsage{Code: "future", OrderType: CouponScopeTopUp})
+	require.ErrorIs(t, err, ErrCouponNotStarted)
+	_, err = CheckCoupon(CouponUsage{Code: "expired", OrderType: CouponScopeTopUp})
+	require.ErrorIs(t, err, ErrCouponExpired)
+
+	invalid := &Coupon{Code: "BAD", Type: CouponTypePercentOff, Percent: 100}
+	require.Error(t, invalid.Insert())
+}
+
+func TestRechargeWaffoGrantsCouponBonusOnce(t *testing.T) {
+	setupCoupons(t)
+	user := &User{Username: "coupon-user", AffCode: "coupon-user"}
+	require.NoError(t, DB.Create(user).Error)
+	coupon := &Coupon{Code: "BONUS10", Type: CouponTypeBonusPercent, Percent: 10}
+	require.NoError(t, coupon.Insert())
+
+	usage := CouponUsage{Code: "BONUS10", UserId: user.Id, OrderType: CouponScopeTopUp, PaymentMethod: PaymentMethodWaffo}
+	require.NoError(t, ReserveCoupon(coupon, usage, "waffo-coupon-1", 2, 0))
+	topUp := &TopUp{
+		UserId:          user.Id,
+		Amount:          2,
+		Money:           2,
+		TradeNo:         "waffo-coupon-1",
+		PaymentMethod:   PaymentMethodWaffo,
+		PaymentProvider: PaymentProviderWaffo,
+		Status:          common.TopUpStatusPending,
+		CouponCode:      coupon.Code,
+	}
+	require.NoError(t, topUp.Insert())
+
+	require.NoError(t, RechargeWaffo("waffo-coupon-1", "127.0.0.1"))
+	require.NoError(t, RechargeWaffo("waffo-coupon-1", "127.0.0.1"))
+
+	base := int(2 * common.QuotaPerUnit)
+	var stored User
+	require.NoError(t, DB.First(&stored, user.Id).Error)
+	require.Equal(t, base+base/10, stored.Quota)
+
+	completed := GetTopUpByTradeNo("waffo-coupon-1")
+	require.EqualValues(t, base/10, completed.BonusQuota)
+
+	reloaded, err := GetCouponById(coupon.Id)
+	require.NoError(t, err)
+	require.Equal(t, 1, reloaded.UsedCount)
+
+	stats, err := GetCouponStats(coupon.Id)
+	require.NoError(t, err)
+	require.EqualValues(t, 1, stats.Redemptions)
+	require.EqualValues(t, base/10, stats.BonusQuota)
+
+	account, err := GetQuotaLedgerAccount(QuotaLedgerUserAccount(user.Id))
+	require.NoError(t, err)
+	require.EqualValues(t, stored.Quota, account.Balance)
+}
+
+func TestRechargeEpayCreditsTopUpAndCouponBonusTogether(t *testing.T) {
+	setupCoupons(t)
+	user := &User{Username: "coupon-epay-user", AffCode: "coupon-epay-user"}
+	require.NoError(t, DB.Create(user).Error)
+	coupon := &Coupon{Code: "EPAY10", Type: CouponTypeBonusPercent, Percent: 10}
+	require.NoError(t, coupon.Insert())
+	require.NoError(t, ReserveCoupon(coupon, CouponUsage{Code: "EPAY10", UserId: user.Id, OrderType: CouponScopeTopUp, PaymentMethod: "alipay"}, "epay-coupon-1", 2, 0))
+	topUp := &TopUp{
+		UserId:          user.Id,
+		Amount:          2,
+		Money:           2,
+		TradeNo:         "epay-coupon-1",
+		PaymentMethod:   "alipay",
+		PaymentProvider: PaymentProviderEpay,
+		Status:          common.TopUpStatusPending,
+		CouponCode:      coupon.Code,
+	}
+	require.NoError(t, topUp.Insert())
+
+	_, quotaToAdd, completed, err := RechargeEpay("epay-coupon-1", "wxpay")
+	require.NoError(t, err)
+	require.True(t, completed)
+	base := int(2 * common.QuotaPerUnit)
+	require.Equal(t, base+base/10, quotaToAdd)
+
+	// 重复回调不再入账
+	_, _, completed, err = RechargeEpay("epay-coupon-1", "wxpay")
+	require.NoError(t, err)
+	require.False(t, completed)
+
+	var stored User
+	require.NoError(t, DB.First(&stored, user.Id).Error)
+	require.Equal(t, base+base/10, stored.Quota)
+	order := GetTopUpByTradeNo("epay-coupon-1")
+	require.Equal(t, common.TopUpStatusSuccess, order.Status)
+	require.Equal(t, "wxpay", order.PaymentMethod)
+	require.EqualValues(t, base/10, order.BonusQuota)
+}
+
+func TestSubscriptionCouponBonusExtendsSubscriptionQuota(t *testing.T) {
+	setupCoupons(t)
+	user := &User{Username: "coupon-sub-user", AffCode: "coupon-sub-user"}
+	require.NoError(t, DB.Create(user).Error)
+	coupon := &Coupon{Code: "PLANBONUS", Type: CouponTypeBonusPercent, Percent: 50, AppliesTo: CouponScopeSubscription, PlanIds: "9601"}
+	require.NoError(t, coupon.Insert())
+
+	_, err := CheckCoupon(CouponUsage{Code: "PLANBONUS", UserId: user.Id, OrderType: CouponScopeSubscription, PlanId: 9602})
+	require.ErrorIs(t, err, ErrCouponPlanMismatch)
+
+	usage := CouponUsage{Code: "PLANBONUS", UserId: user.Id, OrderType: CouponScopeSubscription, PlanId: 9601, PaymentMethod: PaymentMethodStripe}
+	require.NoError(t, ReserveCoupon(coupon, usage, "sub-coupon-1", 10, 0))
+	order := &SubscriptionOrder{
+		UserId:     user.Id,
+		PlanId:     9601,
+		Money:      10,
+		TradeNo:    "sub-coupon-1",
+		CouponCode: coupon.Code,
+	}
+	sub := &UserSubscription{UserId: user.Id, PlanId: 9601, AmountTotal: 1000, Status: "active"}
+	require.NoError(t, DB.Create(sub).Error)
+
+	require.NoError(t, DB.Transaction(func(tx *gorm.DB) error {
+		return grantSubscriptionCouponBonusTx(tx, order, sub)
+	}))
+	// 重复确认不会再次赠送
+	require.NoError(t, DB.Transaction(func(tx *gorm.DB) error {
+		return grantSubscriptionCouponBonusTx(tx, order, sub)
+	}))
+
+	var stored UserSubscription
+	require.NoError(t, DB.First(&stored, sub.Id).Error)
+	require.EqualValues(t, 1500, stored.AmountTotal)
+
+	account, err := GetQuotaLedgerAccount(QuotaLedgerSubscriptionAccount(sub.Id))
+	require.NoError(t, err)
+	require.EqualValues(t, 1500, account.Balance)
+}
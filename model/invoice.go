@@ -0,0 +1,272 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	BillingProfileOwnerUser         = "user"
+	BillingProfileOwnerOrganization = "organization"
+)
+
+// 发票来源
+const (
+	InvoiceSourceTopUp             = "topup"
+	InvoiceSourceSubscriptionOrder = "subscription_order"
+	InvoiceSourcePostpaidStatement = "postpaid_statement"
+)
+
+// BillingProfile 是用户或组织的开票信息，开票时会快照到发票上。
+type BillingProfile struct {
+	Id        int    `json:"id"`
+	OwnerType string `json:"owner_type" gorm:"type:varchar(16);uniqueIndex:idx_billing_profile_owner"`
+	OwnerId   int    `json:"owner_id" gorm:"uniqueIndex:idx_billing_profile_owner"`
+	LegalName string `json:"legal_name" gorm:"type:varchar(255)"`
+	Address   string `json:"address" gorm:"type:text"`
+	TaxId     string `json:"tax_id" gorm:"type:varchar(64)"`
+	Email     string `json:"email" gorm:"type:varchar(255)"` // 接收发票的邮箱，为空时使用用户邮箱
+	UpdatedAt int64  `json:"updated_at" gorm:"bigint"`
+}
+
+// Invoice 是已开具的发票，开具后不再修改（邮件发送时间除外）。
+// 同一来源（SourceType + SourceId）只会开具一张发票。
+type Invoice struct {
+	Id             int     `json:"id"`
+	InvoiceNo      string  `json:"invoice_no" gorm:"type:varchar(64);uniqueIndex"`
+	UserId         int     `json:"user_id" gorm:"index"`
+	OrganizationId int     `json:"organization_id" gorm:"index"`
+	SourceType     string  `json:"source_type" gorm:"type:varchar(32);uniqueIndex:idx_invoice_source"`
+	SourceId       string  `json:"source_id" gorm:"type:varchar(255);uniqueIndex:idx_invoice_source"`
+	Description    string  `json:"description" gorm:"type:varchar(255)"`
+	Amount         float64 `json:"amount"`
+	Currency       string  `json:"currency" gorm:"type:varchar(16)"`
+	PaymentMethod  string  `json:"payment_method" gorm:"type:varchar(50)"`
+	PeriodStart    int64   `json:"period_start" gorm:"bigint"`
+	PeriodEnd      int64   `json:"period_end" gorm:"bigint"`
+	BillingName    string  `json:"billing_name" gorm:"type:varchar(255)"`
+	BillingAddress string  `json:"billing_address" gorm:"type:text"`
+	BillingTaxId   string  `json:"billing_tax_id" gorm:"type:varchar(64)"`
+	BillingEmail   string  `json:"billing_email" gorm:"type:varchar(255)"`
+	SellerName     string  `json:"seller_name" gorm:"type:varchar(255)"`
+	SellerAddress  string  `json:"seller_address" gorm:"type:text"`
+	SellerTaxId    string  `json:"seller_tax_id" gorm:"type:varchar(64)"`
+	Footer         string  `json:"footer" gorm:"type:text"`
+	IssuedAt       int64   `json:"issued_at" gorm:"bigint;index"`
+	EmailedAt      int64   `json:"emailed_at" gorm:"bigint"`
+}
+
+// InvoiceSequence 保存每个编号前缀和年份的下一个序号，保证编号连续且不重复。
+type InvoiceSequence struct {
+	SequenceKey string `gorm:"primaryKey;type:varchar(64)"`
+	NextNumber  int64
+}
+
+// InvoiceIssueParams 描述一张待开具的发票。
+type InvoiceIssueParams struct {
+	UserId         int
+	OrganizationId int
+	SourceType     string
+	SourceId       string
+	Description    string
+	Amount         float64
+	PaymentMethod  string
+	Currency       string // 为空时使用发票设置中的币种
+	PeriodStart    int64
+	PeriodEnd      int64
+}
+
+func GetBillingProfile(ownerType string, ownerId int) (*BillingProfile, error) {
+	var profile BillingProfile
+	err := DB.Where("owner_type = ? AND owner_id = ?", ownerType, ownerId).First(&profile).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return &BillingProfile{OwnerType: ownerType, OwnerId: ownerId}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &profile, nil
+}
+
+func UpsertBillingProfile(profile *BillingProfile) error {
+	profile.LegalName = strings.TrimSpace(profile.LegalName)
+	profile.Address = strings.TrimSpace(profile.Address)
+	profile.TaxId = strings.TrimSpace(profile.TaxId)
+	profile.Email = strings.TrimSpace(profile.Email)
+	if len(profile.LegalName) > 255 || len(profile.Address) > 1000 || len(profile.TaxId) > 64 || len(profile.Email) > 255 {
+		return errors.New("开票信息过长")
+	}
+	profile.UpdatedAt = common.GetTimestamp()
+	return DB.Transaction(func(tx *gorm.DB) error {
+		var existing BillingProfile
+		err := lockForUpdate(tx).Where("owner_type = ? AND owner_id = ?", profile.OwnerType, profile.OwnerId).First(&existing).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			profile.Id = 0
+			return tx.Create(profile).Error
+		}
+		if err != nil {
+			return err
+		}
+		profile.Id = existing.Id
+		return tx.Save(profile).Error
+	})
+}
+
+// IssueInvoice 为一笔已完成的付款开具发票并分配编号。该来源已开过发票时返回已有发票，
+// created 为 false。开票方信息取自发票设置，开票信息优先使用组织，其次用户的开票资料。
+func IssueInvoice(params InvoiceIssueParams) (invoice *Invoice, created bool, err error) {
+	if params.SourceType == "" || params.SourceId == "" {
+		return nil, false, errors.New("invoice source is required")
+	}
+	setting := operation_setting.GetInvoiceSetting()
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		var existing Invoice
+		query := tx.Where("source_type = ? AND source_id = ?", params.SourceType, params.SourceId).Limit(1).Find(&existing)
+		if query.Error != nil {
+			return query.Error
+		}
+		if query.RowsAffected > 0 {
+			invoice = &existing
+			return nil
+		}
+
+		inv := &Invoice{
+			UserId:         params.UserId,
+			OrganizationId: params.OrganizationId,
+			SourceType:     params.SourceType,
+			SourceId:       params.SourceId,
+			Description:    params.Description,
+			Amount:         params.Amount,
+			Currency:       setting.Currency,
+			PaymentMethod:  params.PaymentMethod,
+			PeriodStart:    params.PeriodStart,
+			PeriodEnd:      params.PeriodEnd,
+			SellerName:     setting.SellerName,
+			SellerAddress:  setting.SellerAddress,
+			SellerTaxId:    setting.SellerTaxId,
+			Footer:         setting.Footer,
+			IssuedAt:       common.GetTimestamp(),
+		}
+		if params.Currency != "" {
+			inv.Currency = params.Currency
+		}
+		if err := fillInvoiceBillingInfo(tx, inv); err != nil {
+			return err
+		}
+		invoiceNo, err := nextInvoiceNumberTx(tx, setting.NumberPrefix, time.Unix(inv.IssuedAt, 0).Year())
+		if err != nil {
+			return err
+		}
+		inv.InvoiceNo = invoiceNo
+		if err := tx.Create(inv).Error; err != nil {
+			return err
+		}
+		invoice = inv
+		created = true
+		return nil
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	return invoice, created, nil
+}
+
+func fillInvoiceBillingInfo(tx *gorm.DB, inv *Invoice) error {
+	var user User
+	if err := tx.Unscoped().Select("id", "username", "display_name", "email").Where("id = ?", inv.UserId).First(&user).Error; err != nil {
+		return err
+	}
+	inv.BillingName = user.DisplayName
+	if inv.BillingName == "" {
+		inv.BillingName = user.Username
+	}
+	inv.BillingEmail = user.Email
+
+	ownerType, ownerId := BillingProfileOwnerUser, inv.UserId
+	if inv.OrganizationId > 0 {
+		ownerType, ownerId = BillingProfileOwnerOrganization, inv.OrganizationId
+	}
+	var profile BillingProfile
+	query := tx.Where("owner_type = ? AND owner_id = ?", ownerType, ownerId).Limit(1).Find(&profile)
+	if query.Error != nil {
+		return query.Error
+	}
+	if query.RowsAffected == 0 {
+		return nil
+	}
+	if profile.LegalName != "" {
+		inv.BillingName = profile.LegalName
+	}
+	if profile.Email != "" {
+		inv.BillingEmail = profile.Email
+	}
+	inv.BillingAddress = profile.Address
+	inv.BillingTaxId = profile.TaxId
+	return nil
+}
+
+// nextInvoiceNumberTx 在调用方事务中分配下一个发票编号，编号按前缀和年份连续递增。
+func nextInvoiceNumberTx(tx *gorm.DB, prefix string, year int) (string, error) {
+	prefix = strings.TrimSpace(prefix)
+	if prefix == "" {
+		prefix = "INV"
+	}
+	key := fmt.Sprintf("%s-%d", prefix, year)
+	// 先以 insert-ignore 建行，并发的首次开票不会因主键冲突失败，随后统一加锁读取
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&InvoiceSequence{SequenceKey: key, NextNumber: 1}).Error; err != nil {
+		return "", err
+	}
+	var seq InvoiceSequence
+	if err := lockForUpdate(tx).Where("sequence_key = ?", key).First(&seq).Error; err != nil {
+		return "", err
+	}
+	number := seq.NextNumber
+	if err := tx.Model(&InvoiceSequence{}).Where("sequence_key = ?", key).Update("next_number", number+1).Error; err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s-%06d", key, number), nil
+}
+
+func MarkInvoiceEmailed(id int) error {
+	return DB.Model(&Invoice{}).Where("id = ?", id).Update("emailed_at", common.GetTimestamp()).Error
+}
+
+func GetInvoiceById(id int) (*Invoice, error) {
+	var invoice Invoice
+	if err := DB.First(&invoice, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &invoice, nil
+}
+
+func GetInvoiceBySource(sourceType string, sourceId string) (*Invoice, error) {
+	var invoice Invoice
+	if err := DB.Where("source_type = ? AND source_id = ?", sourceType, sourceId).First(&invoice).Error; err != nil {
+		return nil, err
+	}
+	return &invoice, nil
+}
+
+// GetInvoices 按用户/组织筛选发票，userId 与 organizationId 为 0 时不筛选。
+func GetInvoices(userId int, organizationId int, startIdx int, num int) (invoices []*Invoice, total int64, err error) {
+	tx := DB.Model(&Invoice{})
+	if userId != 0 {
+		tx = tx.Where("user_id = ?", userId)
+	}
+	if organizationId != 0 {
+		tx = tx.Where("organization_id = ?", organizationId)
+	}
+	if err = tx.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err = tx.Order("id desc").Limit(num).Offset(startIdx).Find(&invoices).Error
+	return invoices, total, err
+}
@@ -0,0 +1,105 @@
+package model
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func setupInvoices(t *testing.T) *User {
+	t.Helper()
+	require.NoError(t, DB.AutoMigrate(&User{}, &BillingProfile{}, &Invoice{}, &InvoiceSequence{}))
+	t.Cleanup(func() {
+		DB.Exec("DELETE FROM users")
+		DB.Exec("DELETE FROM billing_profiles")
+		DB.Exec("DELETE FROM invoices")
+		DB.Exec("DELETE FROM invoice_sequences")
+	})
+	user := &User{Username: "invoice-user", AffCode: "invoice-user", Email: "user@example.com"}
+	require.NoError(t, DB.Create(user).Error)
+	return user
+}
+
+func TestIssueInvoiceNumbersSequentiallyAndOncePerSource(t *testing.T) {
+	user := setupInvoices(t)
+	year := time.Now().Year()
+	prefix := operation_setting.GetInvoiceSetting().NumberPrefix
+
+	for i := 1; i <= 3; i++ {
+		invoice, created, err := IssueInvoice(InvoiceIssueParams{
+			UserId:     user.Id,
+			SourceType: InvoiceSourceTopUp,
+			SourceId:   fmt.Sprintf("trade-%d", i),
+			Amount:     10,
+		})
+		require.NoError(t, err)
+		require.True(t, created)
+		require.Equal(t, fmt.Sprintf("%s-%d-%06d", prefix, year, i), invoice.InvoiceNo)
+	}
+
+	again, created, err := IssueInvoice(InvoiceIssueParams{
+		UserId:     user.Id,
+		SourceType: InvoiceSourceTopUp,
+		SourceId:   "trade-2",
+		Amount:     10,
+	})
+	require.NoError(t, err)
+	require.False(t, created)
+	require.Equal(t, fmt.Sprintf("%s-%d-%06d", prefix, year, 2), again.InvoiceNo)
+
+	_, total, err := GetInvoices(user.Id, 0, 0, 10)
+	require.NoError(t, err)
+	require.EqualValues(t, 3, total)
+}
+
+func TestNextInvoiceNumberContinuesSequenceCreatedConcurrently(t *testing.T) {
+	setupInvoices(t)
+	// 另一个事务已抢先建好序号行，本次插入应被忽略而不是主键冲突
+	require.NoError(t, DB.Create(&InvoiceSequence{SequenceKey: "RACE-2026", NextNumber: 5}).Error)
+
+	for _, want := range []string{"RACE-2026-000005", "RACE-2026-000006"} {
+		var number string
+		require.NoError(t, DB.Transaction(func(tx *gorm.DB) error {
+			var err error
+			number, err = nextInvoiceNumberTx(tx, "RACE", 2026)
+			return err
+		}))
+		require.Equal(t, want, number)
+	}
+}
+
+func TestIssueInvoiceSnapshotsBillingProfile(t *testing.T) {
+	user := setupInvoices(t)
+
+	invoice, _, err := IssueInvoice(InvoiceIssueParams{UserId: user.Id, SourceType: InvoiceSourceTopUp, SourceId: "no-profile"})
+	require.NoError(t, err)
+	require.Equal(t, "invoice-user", invoice.BillingName)
+	require.Equal(t, "user@example.com", invoice.BillingEmail)
+
+	require.NoError(t, UpsertBillingProfile(&BillingProfile{
+		OwnerType: BillingProfileOwnerUser,
+		OwnerId:   user.Id,
+		LegalName: " Acme Ltd ",
+		Address:   "1 Main St",
+		TaxId:     "VAT123",
+		Email:     "billing@acme.test",
+	}))
+	invoice, _, err = IssueInvoice(InvoiceIssueParams{UserId: user.Id, SourceType: InvoiceSourceTopUp, SourceId: "with-profile"})
+	require.NoError(t, err)
+	require.Equal(t, "Acme Ltd", invoice.BillingName)
+	require.Equal(t, "VAT123", invoice.BillingTaxId)
+	require.Equal(t, "billing@acme.test", invoice.BillingEmail)
+
+	// 修改开票资料不影响已开具的发票
+	require.NoError(t, UpsertBillingProfile(&BillingProfile{OwnerType: BillingProfileOwnerUser, OwnerId: user.Id, LegalName: "Other"}))
+	stored, err := GetInvoiceById(invoice.Id)
+	require.NoError(t, err)
+	require.Equal(t, "Acme Ltd", stored.BillingName)
+	var count int64
+	require.NoError(t, DB.Model(&BillingProfile{}).Count(&count).Error)
+	require.EqualValues(t, 1, count)
+}
@@ -0,0 +1,171 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+)
+
+const (
+	ProfitDimensionChannel = "channel"
+	ProfitDimensionModel   = "model"
+	ProfitDimensionGroup   = "group"
+)
+
+// ProfitReportParams 描述盈亏报表的筛选与分组方式。
+// BucketSeconds > 0 时按时间分桶（桶起点 = created_at - created_at % BucketSeconds）。
+type ProfitReportParams struct {
+	StartTimestamp int64
+	EndTimestamp   int64
+	Dimensions     []string
+	BucketSeconds  int64
+	ChannelId      int
+	ModelName      string
+	Group          string
+}
+
+// ProfitReportRow 为一组消费日志的汇总。只有配置了成本价的请求才会计入
+// CostedQuota，Profit 与 Margin 只基于这部分请求计算，避免未配置成本的渠道被算成纯利润。
+type ProfitReportRow struct {
+	Bucket       int64   `json:"bucket,omitempty"`
+	ChannelId    int     `json:"channel_id,omitempty"`
+	ModelName    string  `json:"model_name,omitempty"`
+	Group        string  `json:"group,omitempty"`
+	Requests     int64   `json:"requests"`
+	Quota        int64   `json:"quota"`
+	CostedQuota  int64   `json:"costed_quota"`
+	UpstreamCost int64   `json:"upstream_cost"`
+	Profit       int64   `json:"profit"`
+	Margin       float64 `json:"margin"`
+	Loss         bool    `json:"loss"`
+}
+
+type profitReportScanRow struct {
+	Bucket       int64
+	ChannelId    int
+	ModelName    string
+	UsingGroup   string
+	Requests     int64
+	Quota        int64
+	CostedQuota  int64
+	UpstreamCost int64
+}
+
+func NormalizeProfitDimensions(dimensions []string) ([]string, error) {
+	seen := make(map[string]bool, len(dimensions))
+	normalized := make([]string, 0, len(dimensions))
+	for _, dimension := range dimensions {
+		dimension = strings.TrimSpace(dimension)
+		if dimension == "" || seen[dimension] {
+			continue
+		}
+		switch dimension {
+		case ProfitDimensionChannel, ProfitDimensionModel, ProfitDimensionGroup:
+		default:
+			return nil, fmt.Errorf("不支持的分组维度：%s", dimension)
+		}
+		seen[dimension] = true
+		normalized = append(normalized, dimension)
+	}
+	return normalized, nil
+}
+
+// GetProfitReport 按渠道/模型/分组（以及可选的时间桶）汇总消费日志的收入与上游成本。
+func GetProfitReport(params ProfitReportParams) ([]ProfitReportRow, error) {
+	dimensions, err := NormalizeProfitDimensions(params.Dimensions)
+	if err != nil {
+		return nil, err
+	}
+	if params.BucketSeconds < 0 {
+		return nil, errors.New("时间粒度无效")
+	}
+
+	selects := []string{
+		"count(*) AS requests",
+		"COALESCE(sum(quota), 0) AS quota",
+		"COALESCE(sum(CASE WHEN upstream_cost > 0 THEN quota ELSE 0 END), 0) AS costed_quota",
+		"COALESCE(sum(upstream_cost), 0) AS upstream_cost",
+	}
+	var groupBy []string
+	if params.BucketSeconds > 0 {
+		bucketExpr := fmt.Sprintf("(created_at - (created_at %% %d))", params.BucketSeconds)
+		selects = append(selects, bucketExpr+" AS bucket")
+		groupBy = append(groupBy, bucketExpr)
+	}
+	for _, dimension := range dimensions {
+		switch dimension {
+		case ProfitDimensionChannel:
+			selects = append(selects, "channel_id")
+			groupBy = append(groupBy, "channel_id")
+		case ProfitDimensionModel:
+			selects = append(selects, "model_name")
+			groupBy = append(groupBy, "model_name")
+		case ProfitDimensionGroup:
+			selects = append(selects, logGroupCol+" AS using_group")
+			groupBy = append(groupBy, logGroupCol)
+		}
+	}
+
+	tx := LOG_DB.Table("logs").Select(strings.Join(selects, ", ")).Where("type = ?", LogTypeConsume)
+	if params.StartTimestamp != 0 {
+		tx = tx.Where("created_at >= ?", params.StartTimestamp)
+	}
+	if params.EndTimestamp != 0 {
+		tx = tx.Where("created_at <= ?", params.EndTimestamp)
+	}
+	if params.ChannelId != 0 {
+		tx = tx.Where("channel_id = ?", params.ChannelId)
+	}
+	if params.ModelName != "" {
+		tx = tx.Where("model_name = ?", params.ModelName)
+	}
+	if params.Group != "" {
+		tx = tx.Where(logGroupCol+" = ?", params.Group)
+	}
+	if len(groupBy) > 0 {
+		tx = tx.Group(strings.Join(groupBy, ", "))
+	}
+
+	var scanned []profitReportScanRow
+	if err := tx.Scan(&scanned).Error; err != nil {
+		common.SysError("failed to query profit report: " + err.Error())
+		return nil, errors.New("查询盈亏报表失败")
+	}
+
+	rows := make([]ProfitReportRow, 0, len(scanned))
+	for _, s := range scanned {
+		row := ProfitReportRow{
+			Bucket:       s.Bucket,
+			ChannelId:    s.ChannelId,
+			ModelName:    s.ModelName,
+			Group:        s.UsingGroup,
+			Requests:     s.Requests,
+			Quota:        s.Quota,
+			CostedQuota:  s.CostedQuota,
+			UpstreamCost: s.UpstreamCost,
+			Profit:       s.CostedQuota - s.UpstreamCost,
+		}
+		if row.CostedQuota > 0 {
+			row.Margin = float64(row.Profit) / float64(row.CostedQuota)
+		}
+		row.Loss = row.Profit < 0
+		rows = append(rows, row)
+	}
+	sort.SliceStable(rows, func(i, j int) bool {
+		a, b := rows[i], rows[j]
+		if a.Bucket != b.Bucket {
+			return a.Bucket < b.Bucket
+		}
+		if a.ChannelId != b.ChannelId {
+			return a.ChannelId < b.ChannelId
+		}
+		if a.ModelName != b.ModelName {
+			return a.ModelName < b.ModelName
+		}
+		return a.Group < b.Group
+	})
+	return rows, nil
+}
@@ -0,0 +1,50 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetProfitReportGroupsByChannelAndBucket(t *testing.T) {
+	require.NoError(t, LOG_DB.AutoMigrate(&Log{}))
+	t.Cleanup(func() {
+		LOG_DB.Exec("DELETE FROM logs")
+	})
+	logs := []*Log{
+		{Type: LogTypeConsume, CreatedAt: 3600, ChannelId: 1, ModelName: "m", Group: "default", Quota: 100, UpstreamCost: 60},
+		{Type: LogTypeConsume, CreatedAt: 3700, ChannelId: 1, ModelName: "m", Group: "default", Quota: 50},
+		{Type: LogTypeConsume, CreatedAt: 3800, ChannelId: 2, ModelName: "m", Group: "vip", Quota: 100, UpstreamCost: 150},
+		{Type: LogTypeConsume, CreatedAt: 7300, ChannelId: 1, ModelName: "m", Group: "default", Quota: 10, UpstreamCost: 5},
+		{Type: LogTypeError, CreatedAt: 3600, ChannelId: 1, ModelName: "m", Quota: 999, UpstreamCost: 999},
+	}
+	for _, log := range logs {
+		require.NoError(t, LOG_DB.Create(log).Error)
+	}
+
+	rows, err := GetProfitReport(ProfitReportParams{
+		Dimensions:    []string{ProfitDimensionChannel, ProfitDimensionGroup},
+		BucketSeconds: 3600,
+	})
+	require.NoError(t, err)
+	require.Len(t, rows, 3)
+
+	require.Equal(t, int64(3600), rows[0].Bucket)
+	require.Equal(t, 1, rows[0].ChannelId)
+	require.Equal(t, "default", rows[0].Group)
+	require.Equal(t, int64(2), rows[0].Requests)
+	require.Equal(t, int64(150), rows[0].Quota)
+	require.Equal(t, int64(100), rows[0].CostedQuota)
+	require.Equal(t, int64(40), rows[0].Profit)
+	require.InDelta(t, 0.4, rows[0].Margin, 1e-9)
+	require.False(t, rows[0].Loss)
+
+	require.Equal(t, 2, rows[1].ChannelId)
+	require.Equal(t, int64(-50), rows[1].Profit)
+	require.True(t, rows[1].Loss)
+
+	require.Equal(t, int64(7200), rows[2].Bucket)
+
+	_, err = GetProfitReport(ProfitReportParams{Dimensions: []string{"user"}})
+	require.Error(t, err)
+}
@@ -273,6 +273,24 @@ func migrateDB() error {
 		&ChannelBreakerState{},
 		&ChannelTestConfig{},
 		&BreakerPenaltyTrace{},
+		&ShadowComparison{},
+		&ChannelKeyRotation{},
+		&Organization{},
+		&OrganizationMember{},
+		&QuotaLedgerEntry{},
+		&QuotaLedgerAccount{},
+		&QuotaLedgerDrift{},
+		&BillingProfile{},
+		&Invoice{},
+		&InvoiceSequence{},
+		&Coupon{},
+		&CouponRedemption{},
+		&AutoRechargeConfig{},
+		&AutoRechargeAttempt{},
+		&PostpaidAccount{},
+		&PostpaidStatement{},
+		&PostpaidStatementItem{},
+		&PostpaidPayment{},
 		&Token{},
 		&User{},
 		&UserSession{},
@@ -297,6 +315,12 @@ func migrateDB() error {
 		&SubscriptionOrder{},
 		&UserSubscription{},
 		&SubscriptionPreConsumeRecord{},
+		&UserSubscriptionBucket{},
+		&QuotaLot{},
+		&PaymentRefund{},
+		&TaskCallbackDelivery{},
+		&MediaObject{},
+		&StoredResponse{},
 		&CustomOAuthProvider{},
 		&UserOAuthBinding{},
 		&PerfMetric{},
@@ -342,6 +366,24 @@ func migrateDBFast() error {
 		{&ChannelBreakerState{}, "ChannelBreakerState"},
 		{&ChannelTestConfig{}, "ChannelTestConfig"},
 		{&BreakerPenaltyTrace{}, "BreakerPenaltyTrace"},
+		{&ShadowComparison{}, "ShadowComparison"},
+		{&ChannelKeyRotation{}, "ChannelKeyRotation"},
+		{&Organization{}, "Organization"},
+		{&OrganizationMember{}, "OrganizationMember"},
+		{&QuotaLedgerEntry{}, "QuotaLedgerEntry"},
+		{&QuotaLedgerAccount{}, "QuotaLedgerAccount"},
+		{&QuotaLedgerDrift{}, "QuotaLedgerDrift"},
+		{&BillingProfile{}, "BillingProfile"},
+		{&Invoice{}, "Invoice"},
+		{&InvoiceSequence{}, "InvoiceSequence"},
+		{&Coupon{}, "Coupon"},
+		{&CouponRedemption{}, "CouponRedemption"},
+		{&AutoRechargeConfig{}, "AutoRechargeConfig"},
+		{&AutoRechargeAttempt{}, "AutoRechargeAttempt"},
+		{&PostpaidAccount{}, "PostpaidAccount"},
+		{&PostpaidStatement{}, "PostpaidStatement"},
+		{&PostpaidStatementItem{}, "PostpaidStatementItem"},
+		{&PostpaidPayment{}, "PostpaidPayment"},
 		{&Token{}, "Token"},
 		{&User{}, "User"},
 		{&UserSession{}, "UserSession"},
@@ -366,6 +408,12 @@ func migrateDBFast() error {
 		{&SubscriptionOrder{}, "SubscriptionOrder"},
 		{&UserSubscription{}, "UserSubscription"},
 		{&SubscriptionPreConsumeRecord{}, "SubscriptionPreConsumeRecord"},
+		{&UserSubscriptionBucket{}, "UserSubscriptionBucket"},
+		{&QuotaLot{}, "QuotaLot"},
+		{&PaymentRefund{}, "PaymentRefund"},
+		{&TaskCallbackDelivery{}, "TaskCallbackDelivery"},
+		{&MediaObject{}, "MediaObject"},
+		{&StoredResponse{}, "StoredResponse"},
 		{&CustomOAuthProvider{}, "CustomOAuthProvider"},
 		{&UserOAuthBinding{}, "UserOAuthBinding"},
 		{&PerfMetric{}, "PerfMetric"},
@@ -430,6 +478,10 @@ func migrateClickHouseLogDB() error {
 	if err := LOG_DB.Exec(clickHouseLogCreateTableSQL(ttlDays)).Error; err != nil {
 		return err
 	}
+	// Columns added after the table was first created.
+	if err := LOG_DB.Exec("ALTER TABLE logs ADD COLUMN IF NOT EXISTS upstream_cost Int32 DEFAULT 0 AFTER quota").Error; err != nil {
+		return err
+	}
 	return syncClickHouseLogTTL(ttlDays)
 }
 
@@ -468,6 +520,7 @@ CREATE TABLE IF NOT EXISTS logs (
 	token_name String DEFAULT '',
 	model_name String DEFAULT '',
 	quota Int32 DEFAULT 0,
+	upstream_cost Int32 DEFAULT 0,
 	prompt_tokens Int32 DEFAULT 0,
 	completion_tokens Int32 DEFAULT 0,
 	use_time Int32 DEFAULT 0,
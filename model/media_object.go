@@ -0,0 +1,150 @@
+package model
+
+import (
+	"errors"
+	"math"
+	"strconv"
+
+	"github.com/QuantumNous/new-api/common"
+
+	"gorm.io/gorm"
+)
+
+const (
+	MediaSourceTask       = "task"
+	MediaSourceMidjourney = "midjourney"
+
+	mediaBytesPerGB = 1 << 30
+	secondsPerDay   = 24 * 3600
+)
+
+// MediaObject 为已归档到存储后端的一份生成结果（视频/图片）。Source + SourceId 指向原任务，
+// 每个任务只归档一次。ExpiresAt 为 0 表示永久保留；BilledAt 记录存储费用已结算到的时间
+type MediaObject struct {
+	Id          int64  `json:"id"`
+	Source      string `json:"source" gorm:"type:varchar(16);uniqueIndex:idx_media_source,priority:1"`
+	SourceId    string `json:"source_id" gorm:"type:varchar(191);uniqueIndex:idx_media_source,priority:2"`
+	UserId      int    `json:"user_id" gorm:"index"`
+	Backend     string `json:"backend" gorm:"type:varchar(16)"`
+	StorageKey  string `json:"-" gorm:"type:varchar(512)"`
+	ContentType string `json:"content_type" gorm:"type:varchar(128)"`
+	Size        int64  `json:"size"`
+	CreatedAt   int64  `json:"created_at" gorm:"bigint"`
+	ExpiresAt   int64  `json:"expires_at" gorm:"bigint;index"`
+	BilledAt    int64  `json:"billed_at" gorm:"bigint;index"`
+}
+
+func (o *MediaObject) Insert() error {
+	now := common.GetTimestamp()
+	if o.CreatedAt == 0 {
+		o.CreatedAt = now
+	}
+	if o.BilledAt == 0 {
+		o.BilledAt = o.CreatedAt
+	}
+	return DB.Create(o).Error
+}
+
+func GetMediaObjectById(id int64) (*MediaObject, error) {
+	var obj MediaObject
+	if err := DB.Where("id = ?", id).First(&obj).Error; err != nil {
+		return nil, err
+	}
+	return &obj, nil
+}
+
+// GetMediaObjectBySource 返回任务对应的归档记录，未归档时返回 nil
+func GetMediaObjectBySource(source string, sourceId string) (*MediaObject, error) {
+	var obj MediaObject
+	err := DB.Where("source = ? AND source_id = ?", source, sourceId).First(&obj).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &obj, nil
+}
+
+// GetExpiredMediaObjects 返回 backend 上 id 大于 afterId 且已超过保留期限的归档记录
+func GetExpiredMediaObjects(backend string, now int64, afterId int64, limit int) ([]*MediaObject, error) {
+	var objs []*MediaObject
+	err := DB.Where("backend = ? AND expires_at > 0 AND expires_at <= ? AND id > ?", backend, now, afterId).
+		Order("id asc").
+		Limit(limit).
+		Find(&objs).Error
+	return objs, err
+}
+
+func DeleteMediaObjectById(id int64) error {
+	return DB.Where("id = ?", id).Delete(&MediaObject{}).Error
+}
+
+// GetMediaObjectsDueForBilling 返回存储费用结算时间早于 before 的归档记录
+func GetMediaObjectsDueForBilling(before int64, afterId int64, limit int) ([]*MediaObject, error) {
+	var objs []*MediaObject
+	err := DB.Where("billed_at <= ? AND id > ?", before, afterId).
+		Order("id asc").
+		Limit(limit).
+		Find(&objs).Error
+	return objs, err
+}
+
+// MediaStorageQuota 计算 size 字节存放 days 天的存储费用（额度），quotaPerGBDay 为每 GB·天 的额度
+func MediaStorageQuota(size int64, days int64, quotaPerGBDay float64) int64 {
+	if size <= 0 || days <= 0 || quotaPerGBDay <= 0 {
+		return 0
+	}
+	return int64(math.Ceil(float64(size) / mediaBytesPerGB * float64(days) * quotaPerGBDay))
+}
+
+// ChargeMediaObjectStorage 按已满的整天数结算一条归档记录的存储费用并推进 BilledAt，
+// 扣费不超过用户当前余额。返回实际扣除的额度
+func ChargeMediaObjectStorage(id int64, now int64, quotaPerGBDay float64) (int64, error) {
+	var charged int64
+	var userId int
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		charged = 0
+		var obj MediaObject
+		if err := lockForUpdate(tx).Where("id = ?", id).First(&obj).Error; err != nil {
+			return err
+		}
+		userId = obj.UserId
+		days := (now - obj.BilledAt) / secondsPerDay
+		if days <= 0 {
+			return nil
+		}
+		quota := MediaStorageQuota(obj.Size, days, quotaPerGBDay)
+		if quota > 0 {
+			var user User
+			if err := lockForUpdate(tx).Select("id", "quota").Where("id = ?", obj.UserId).First(&user).Error; err != nil {
+				if !errors.Is(err, gorm.ErrRecordNotFound) {
+					return err
+				}
+			} else {
+				charged = min(quota, int64(max(user.Quota, 0)))
+			}
+		}
+		if charged > 0 {
+			if err := tx.Model(&User{}).Where("id = ?", obj.UserId).
+				Update("quota", gorm.Expr("quota - ?", charged)).Error; err != nil {
+				return err
+			}
+			if err := consumeUserQuotaLotsTx(tx, obj.UserId, charged); err != nil {
+				return err
+			}
+		}
+		if err := tx.Model(&MediaObject{}).Where("id = ?", obj.Id).
+			Update("billed_at", obj.BilledAt+days*secondsPerDay).Error; err != nil {
+			return err
+		}
+		if charged <= 0 {
+			return nil
+		}
+		return PostQuotaLedgerTx(tx, UserQuotaLedgerPosting(obj.UserId, -int(charged), QuotaLedgerReasonMediaStorage, QuotaLedgerRefMediaObjectId, strconv.FormatInt(obj.Id, 10)))
+	})
+	if err == nil && charged > 0 {
+		_ = cacheDecrUserQuota(userId, charged)
+	}
+	return charged, err
+}
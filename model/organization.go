@@ -0,0 +1,480 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+
+	"gorm.io/gorm"
+)
+
+const (
+	OrganizationStatusEnabled  = 1
+	OrganizationStatusDisabled = 2
+)
+
+const (
+	OrganizationRoleOwner  = "owner"
+	OrganizationRoleAdmin  = "admin"
+	OrganizationRoleMember = "member"
+)
+
+var (
+	ErrOrganizationNotFound            = errors.New("组织不存在或已停用")
+	ErrOrganizationNotMember           = errors.New("用户不是该组织成员")
+	ErrOrganizationQuotaInsufficient   = errors.New("organization quota insufficient")
+	ErrOrganizationMemberLimitExceeded = errors.New("organization member spend limit exceeded")
+	ErrOrganizationLastOwner           = errors.New("组织至少需要保留一名所有者")
+	ErrOrganizationMemberExists        = errors.New("用户已是该组织成员")
+)
+
+// Organization 是共享钱包的所有者：组织令牌产生的消费从 Quota 中扣除，
+// 成员各自的消费上限记录在 OrganizationMember 上。
+type Organization struct {
+	Id        int    `json:"id"`
+	Name      string `json:"name" gorm:"type:varchar(64);index"`
+	OwnerId   int    `json:"owner_id" gorm:"index"`
+	Status    int    `json:"status" gorm:"default:1"`
+	Quota     int    `json:"quota" gorm:"default:0"`
+	UsedQuota int    `json:"used_quota" gorm:"default:0"`
+	CreatedAt int64  `json:"created_at" gorm:"bigint"`
+	UpdatedAt int64  `json:"updated_at" gorm:"bigint"`
+}
+
+// OrganizationMember 记录成员角色与消费上限。SpendLimit 为 0 表示不限制。
+type OrganizationMember struct {
+	Id             int    `json:"id"`
+	OrganizationId int    `json:"organization_id" gorm:"uniqueIndex:idx_org_member,priority:1;index"`
+	UserId         int    `json:"user_id" gorm:"uniqueIndex:idx_org_member,priority:2;index"`
+	Role           string `json:"role" gorm:"type:varchar(16);default:'member'"`
+	SpendLimit     int    `json:"spend_limit" gorm:"default:0"`
+	SpendUsed      int    `json:"spend_used" gorm:"default:0"`
+	Username       string `json:"username,omitempty" gorm:"-:all"`
+	CreatedAt      int64  `json:"created_at" gorm:"bigint"`
+	UpdatedAt      int64  `json:"updated_at" gorm:"bigint"`
+}
+
+func IsValidOrganizationRole(role string) bool {
+	switch role {
+	case OrganizationRoleOwner, OrganizationRoleAdmin, OrganizationRoleMember:
+		return true
+	}
+	return false
+}
+
+// CanManage 表示成员是否可以管理组织成员与资金。
+func (m *OrganizationMember) CanManage() bool {
+	return m != nil && (m.Role == OrganizationRoleOwner || m.Role == OrganizationRoleAdmin)
+}
+
+func CreateOrganization(name string, ownerId int) (*Organization, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || len(name) > 64 {
+		return nil, errors.New("组织名称长度需在 1-64 之间")
+	}
+	now := common.GetTimestamp()
+	org := &Organization{
+		Name:      name,
+		OwnerId:   ownerId,
+		Status:    OrganizationStatusEnabled,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(org).Error; err != nil {
+			return err
+		}
+		return tx.Create(&OrganizationMember{
+			OrganizationId: org.Id,
+			UserId:         ownerId,
+			Role:           OrganizationRoleOwner,
+			CreatedAt:      now,
+			UpdatedAt:      now,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return org, nil
+}
+
+func GetOrganizationById(id int) (*Organization, error) {
+	var org Organization
+	if err := DB.First(&org, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &org, nil
+}
+
+func GetAllOrganizations(startIdx int, num int) (orgs []*Organization, total int64, err error) {
+	tx := DB.Model(&Organization{})
+	if err = tx.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err = tx.Order("id desc").Limit(num).Offset(startIdx).Find(&orgs).Error
+	return orgs, total, err
+}
+
+// GetUserOrganizations 返回用户所属的组织及其在各组织中的成员记录。
+func GetUserOrganizations(userId int) ([]*Organization, []*OrganizationMember, error) {
+	var members []*OrganizationMember
+	if err := DB.Where("user_id = ?", userId).Order("organization_id asc").Find(&members).Error; err != nil {
+		return nil, nil, err
+	}
+	if len(members) == 0 {
+		return []*Organization{}, members, nil
+	}
+	ids := make([]int, 0, len(members))
+	for _, m := range members {
+		ids = append(ids, m.OrganizationId)
+	}
+	var orgs []*Organization
+	if err := DB.Where("id IN ?", ids).Order("id asc").Find(&orgs).Error; err != nil {
+		return nil, nil, err
+	}
+	return orgs, members, nil
+}
+
+func GetOrganizationMember(orgId int, userId int) (*OrganizationMember, error) {
+	return getOrganizationMemberTx(DB, orgId, userId)
+}
+
+func GetOrganizationMembers(orgId int) ([]*OrganizationMember, error) {
+	var members []*OrganizationMember
+	if err := DB.Where("organization_id = ?", orgId).Order("id asc").Find(&members).Error; err != nil {
+		return nil, err
+	}
+	if len(members) == 0 {
+		return members, nil
+	}
+	userIds := make([]int, 0, len(members))
+	for _, m := range members {
+		userIds = append(userIds, m.UserId)
+	}
+	var users []User
+	if err := DB.Select("id", "username").Where("id IN ?", userIds).Find(&users).Error; err != nil {
+		return nil, err
+	}
+	names := make(map[int]string, len(users))
+	for _, u := range users {
+		names[u.Id] = u.Username
+	}
+	for _, m := range members {
+		m.Username = names[m.UserId]
+	}
+	return members, nil
+}
+
+func AddOrganizationMember(orgId int, userId int, role string, spendLimit int) (*OrganizationMember, error) {
+	if !IsValidOrganizationRole(role) {
+		return nil, fmt.Errorf("无效的组织角色：%s", role)
+	}
+	if spendLimit < 0 {
+		return nil, errors.New("消费上限不能为负数")
+	}
+	if _, err := GetOrganizationMember(orgId, userId); err == nil {
+		return nil, ErrOrganizationMemberExists
+	} else if !errors.Is(err, ErrOrganizationNotMember) {
+		return nil, err
+	}
+	if err := DB.Select("id").First(&User{}, "id = ?", userId).Error; err != nil {
+		return nil, err
+	}
+	now := common.GetTimestamp()
+	member := &OrganizationMember{
+		OrganizationId: orgId,
+		UserId:         userId,
+		Role:           role,
+		SpendLimit:     spendLimit,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}
+	if err := DB.Create(member).Error; err != nil {
+		return nil, err
+	}
+	return member, nil
+}
+
+// UpdateOrganizationMember 修改成员角色与消费上限；role 为空或 spendLimit 为 nil 时不修改对应字段，
+// resetSpend 为 true 时清零已用额度。
+func UpdateOrganizationMember(orgId int, userId int, role string, spendLimit *int, resetSpend bool) error {
+	if role != "" && !IsValidOrganizationRole(role) {
+		return fmt.Errorf("无效的组织角色：%s", role)
+	}
+	if spendLimit != nil && *spendLimit < 0 {
+		return errors.New("消费上限不能为负数")
+	}
+	return DB.Transaction(func(tx *gorm.DB) error {
+		var member OrganizationMember
+		err := lockForUpdate(tx).Where("organization_id = ? AND user_id = ?", orgId, userId).First(&member).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrOrganizationNotMember
+		}
+		if err != nil {
+			return err
+		}
+		if role != "" && role != member.Role && member.Role == OrganizationRoleOwner {
+			if err := ensureAnotherOrganizationOwner(tx, orgId, userId); err != nil {
+				return err
+			}
+		}
+		updates := map[string]interface{}{
+			"updated_at": common.GetTimestamp(),
+		}
+		if spendLimit != nil {
+			updates["spend_limit"] = *spendLimit
+		}
+		if role != "" {
+			updates["role"] = role
+		}
+		if resetSpend {
+			updates["spend_used"] = 0
+		}
+		return tx.Model(&OrganizationMember{}).Where("id = ?", member.Id).Updates(updates).Error
+	})
+}
+
+func RemoveOrganizationMember(orgId int, userId int) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		var member OrganizationMember
+		err := lockForUpdate(tx).Where("organization_id = ? AND user_id = ?", orgId, userId).First(&member).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrOrganizationNotMember
+		}
+		if err != nil {
+			return err
+		}
+		if member.Role == OrganizationRoleOwner {
+			if err := ensureAnotherOrganizationOwner(tx, orgId, userId); err != nil {
+				return err
+			}
+		}
+		return tx.Delete(&OrganizationMember{}, member.Id).Error
+	})
+}
+
+func ensureAnotherOrganizationOwner(tx *gorm.DB, orgId int, userId int) error {
+	var owners int64
+	if err := tx.Model(&OrganizationMember{}).
+		Where("organization_id = ? AND role = ? AND user_id <> ?", orgId, OrganizationRoleOwner, userId).
+		Count(&owners).Error; err != nil {
+		return err
+	}
+	if owners == 0 {
+		return ErrOrganizationLastOwner
+	}
+	return nil
+}
+
+func UpdateOrganizationStatus(orgId int, status int) error {
+	if status != OrganizationStatusEnabled && status != OrganizationStatusDisabled {
+		return errors.New("无效的组织状态")
+	}
+	result := DB.Model(&Organization{}).Where("id = ?", orgId).Updates(map[string]interface{}{
+		"status":     status,
+		"updated_at": common.GetTimestamp(),
+	})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// AdjustOrganizationQuota 由管理员直接增减组织钱包额度（delta 可为负）。
+func AdjustOrganizationQuota(orgId int, delta int, operatorId int) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&Organization{}).Where("id = ?", orgId).Updates(map[string]interface{}{
+			"quota":      gorm.Expr("quota + ?", delta),
+			"updated_at": common.GetTimestamp(),
+		})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return PostQuotaLedgerTx(tx, QuotaLedgerPosting{
+			Account:    QuotaLedgerOrganizationAccount(orgId),
+			Amount:     int64(delta),
+			Reason:     QuotaLedgerReasonOrganizationAdjust,
+			OperatorId: operatorId,
+		})
+	})
+}
+
+// FundOrganizationFromUser 把用户个人钱包的额度转入组织钱包。
+func FundOrganizationFromUser(orgId int, userId int, quota int) error {
+	if quota <= 0 {
+		return errors.New("转入额度必须大于 0")
+	}
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		var user User
+		if err := lockForUpdate(tx).Select("id", "quota").First(&user, "id = ?", userId).Error; err != nil {
+			return err
+		}
+		if user.Quota < quota {
+			return errors.New("个人额度不足")
+		}
+		if err := tx.Model(&User{}).Where("id = ?", userId).Update("quota", gorm.Expr("quota - ?", quota)).Error; err != nil {
+			return err
+		}
+		// 转入组织钱包的额度不会过期，先消耗个人的到期批次
+		if err := consumeUserQuotaLotsTx(tx, userId, int64(quota)); err != nil {
+			return err
+		}
+		result := tx.Model(&Organization{}).Where("id = ? AND status = ?", orgId, OrganizationStatusEnabled).Updates(map[string]interface{}{
+			"quota":      gorm.Expr("quota + ?", quota),
+			"updated_at": common.GetTimestamp(),
+		})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrOrganizationNotFound
+		}
+		posting := UserQuotaLedgerPosting(userId, -quota, QuotaLedgerReasonOrganizationFund, QuotaLedgerRefOrganizationId, strconv.Itoa(orgId))
+		posting.CounterAccount = QuotaLedgerOrganizationAccount(orgId)
+		return PostQuotaLedgerTx(tx, posting)
+	})
+	if err != nil {
+		return err
+	}
+	if err := cacheDecrUserQuota(userId, int64(quota)); err != nil {
+		common.SysLog("failed to decrease user quota cache: " + err.Error())
+	}
+	return nil
+}
+
+// PreConsumeOrganizationQuota 在同一事务中从组织钱包扣除 amount，并计入成员的已用额度。
+// 组织余额不足或成员超过消费上限时整体失败，不会产生部分扣减。
+// amount 为 0 时只校验组织状态与成员资格。
+func PreConsumeOrganizationQuota(orgId int, userId int, amount int, refType string, refId string) error {
+	if amount <= 0 {
+		var org Organization
+		if err := DB.Select("id", "status").First(&org, "id = ?", orgId).Error; err != nil || org.Status != OrganizationStatusEnabled {
+			return ErrOrganizationNotFound
+		}
+		_, err := GetOrganizationMember(orgId, userId)
+		return err
+	}
+	return DB.Transaction(func(tx *gorm.DB) error {
+		if err := chargeOrganizationMemberSpendTx(tx, orgId, userId, amount); err != nil {
+			return err
+		}
+		// 开通信用额度的组织可以透支到 -CreditLimit
+		creditLimit := getPostpaidCreditLimitTx(tx, BillingProfileOwnerOrganization, orgId)
+		result := tx.Model(&Organization{}).
+			Where("id = ? AND status = ? AND quota >= ?", orgId, OrganizationStatusEnabled, amount-creditLimit).
+			Updates(map[string]interface{}{
+				"quota":      gorm.Expr("quota - ?", amount),
+				"used_quota": gorm.Expr("used_quota + ?", amount),
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			var org Organization
+			if err := tx.Select("id", "status").First(&org, "id = ?", orgId).Error; err != nil || org.Status != OrganizationStatusEnabled {
+				return ErrOrganizationNotFound
+			}
+			return ErrOrganizationQuotaInsufficient
+		}
+		return PostQuotaLedgerTx(tx, organizationQuotaLedgerPosting(orgId, userId, -amount, refType, refId))
+	})
+}
+
+// SettleOrganizationQuota 按差额调整组织钱包与成员已用额度（正数补扣，负数退还）。
+// 结算发生在请求完成之后，因此不再校验余额和消费上限。
+func SettleOrganizationQuota(orgId int, userId int, delta int, refType string, refId string) error {
+	if delta == 0 {
+		return nil
+	}
+	return DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&Organization{}).Where("id = ?", orgId).Updates(map[string]interface{}{
+			"quota":      gorm.Expr("quota - ?", delta),
+			"used_quota": gorm.Expr("used_quota + ?", delta),
+		}).Error; err != nil {
+			return err
+		}
+		if err := settleOrganizationMemberSpendTx(tx, orgId, userId, delta); err != nil {
+			return err
+		}
+		return PostQuotaLedgerTx(tx, organizationQuotaLedgerPosting(orgId, userId, -delta, refType, refId))
+	})
+}
+
+// ChargeOrganizationMemberSpend 只计入成员已用额度并校验消费上限，用于从组织订阅扣费的请求。
+func ChargeOrganizationMemberSpend(orgId int, userId int, amount int) error {
+	if amount <= 0 {
+		return nil
+	}
+	return DB.Transaction(func(tx *gorm.DB) error {
+		return chargeOrganizationMemberSpendTx(tx, orgId, userId, amount)
+	})
+}
+
+// SettleOrganizationMemberSpend 按差额调整成员已用额度，不校验消费上限。
+func SettleOrganizationMemberSpend(orgId int, userId int, delta int) error {
+	if delta == 0 {
+		return nil
+	}
+	return settleOrganizationMemberSpendTx(DB, orgId, userId, delta)
+}
+
+// chargeOrganizationMemberSpendTx 计入成员已用额度，超过消费上限时返回 ErrOrganizationMemberLimitExceeded。
+func chargeOrganizationMemberSpendTx(tx *gorm.DB, orgId int, userId int, amount int) error {
+	result := tx.Model(&OrganizationMember{}).
+		Where("organization_id = ? AND user_id = ?", orgId, userId).
+		Where("spend_limit = 0 OR spend_used + ? <= spend_limit", amount).
+		Update("spend_used", gorm.Expr("spend_used + ?", amount))
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		if _, err := getOrganizationMemberTx(tx, orgId, userId); err != nil {
+			return err
+		}
+		return ErrOrganizationMemberLimitExceeded
+	}
+	return nil
+}
+
+func settleOrganizationMemberSpendTx(tx *gorm.DB, orgId int, userId int, delta int) error {
+	return tx.Model(&OrganizationMember{}).
+		Where("organization_id = ? AND user_id = ?", orgId, userId).
+		Update("spend_used", gorm.Expr("spend_used + ?", delta)).Error
+}
+
+// organizationQuotaLedgerPosting 构造记入组织钱包的消费记账，amount 为负数时是扣费，正数是退还。
+func organizationQuotaLedgerPosting(orgId int, userId int, amount int, refType string, refId string) QuotaLedgerPosting {
+	reason := QuotaLedgerReasonOrganizationConsume
+	if amount > 0 {
+		reason = QuotaLedgerReasonOrganizationRefund
+	}
+	return QuotaLedgerPosting{
+		Account: QuotaLedgerOrganizationAccount(orgId),
+		UserId:  userId,
+		Amount:  int64(amount),
+		Reason:  reason,
+		RefType: refType,
+		RefId:   refId,
+	}
+}
+
+func getOrganizationMemberTx(tx *gorm.DB, orgId int, userId int) (*OrganizationMember, error) {
+	var member OrganizationMember
+	err := tx.Where("organization_id = ? AND user_id = ?", orgId, userId).First(&member).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrOrganizationNotMember
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &member, nil
+}
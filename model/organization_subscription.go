@@ -0,0 +1,176 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+
+	"gorm.io/gorm"
+)
+
+// 组织订阅：UserSubscription.OrganizationId > 0 的订阅归组织所有，
+// 组织令牌产生的请求先从组织订阅扣费，订阅额度不足时再回退到组织钱包。
+
+func getEnabledOrganizationTx(tx *gorm.DB, orgId int) (*Organization, error) {
+	var org Organization
+	if err := tx.First(&org, "id = ?", orgId).Error; err != nil || org.Status != OrganizationStatusEnabled {
+		return nil, ErrOrganizationNotFound
+	}
+	return &org, nil
+}
+
+// AdminBindOrganizationSubscription 由管理员直接为组织开通套餐（无需支付）。
+func AdminBindOrganizationSubscription(orgId int, planId int) error {
+	if orgId <= 0 || planId <= 0 {
+		return errors.New("invalid orgId or planId")
+	}
+	plan, err := GetSubscriptionPlanById(planId)
+	if err != nil {
+		return err
+	}
+	return DB.Transaction(func(tx *gorm.DB) error {
+		org, err := getEnabledOrganizationTx(tx, orgId)
+		if err != nil {
+			return err
+		}
+		_, err = createSubscriptionFromPlanTx(tx, org.OwnerId, orgId, plan, "admin")
+		return err
+	})
+}
+
+// PurchaseOrganizationSubscriptionWithBalance 从组织钱包扣除套餐价格为组织购买订阅，userId 为操作的成员。
+func PurchaseOrganizationSubscriptionWithBalance(orgId int, userId int, planId int) error {
+	if orgId <= 0 || userId <= 0 || planId <= 0 {
+		return errors.New("invalid orgId, userId or planId")
+	}
+	var logPlanTitle string
+	var chargedQuota int
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		plan, err := getSubscriptionPlanByIdTx(tx, planId)
+		if err != nil {
+			return err
+		}
+		if !plan.Enabled {
+			return errors.New("套餐未启用")
+		}
+		if plan.PriceAmount < 0 {
+			return errors.New("套餐价格不能为负数")
+		}
+		if plan.AllowBalancePay != nil && !*plan.AllowBalancePay {
+			return errors.New("该套餐不允许使用余额兑换")
+		}
+		requiredQuota, err := calcSubscriptionBalanceQuota(plan.PriceAmount)
+		if err != nil {
+			return err
+		}
+		if _, err := getOrganizationMemberTx(tx, orgId, userId); err != nil {
+			return err
+		}
+		if requiredQuota > 0 {
+			result := tx.Model(&Organization{}).
+				Where("id = ? AND status = ? AND quota >= ?", orgId, OrganizationStatusEnabled, requiredQuota).
+				Updates(map[string]interface{}{
+					"quota":      gorm.Expr("quota - ?", requiredQuota),
+					"updated_at": common.GetTimestamp(),
+				})
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				if _, err := getEnabledOrganizationTx(tx, orgId); err != nil {
+					return err
+				}
+				return errors.New("组织余额不足")
+			}
+		} else if _, err := getEnabledOrganizationTx(tx, orgId); err != nil {
+			return err
+		}
+
+		if _, err := createSubscriptionFromPlanTx(tx, userId, orgId, plan, PaymentMethodBalance); err != nil {
+			return err
+		}
+
+		now := common.GetTimestamp()
+		tradeNo := fmt.Sprintf("SUBBALORG%dNO%s%d", orgId, common.GetRandomString(6), time.Now().UnixNano())
+		order := &SubscriptionOrder{
+			UserId:          userId,
+			PlanId:          plan.Id,
+			Money:           plan.PriceAmount,
+			TradeNo:         tradeNo,
+			PaymentMethod:   PaymentMethodBalance,
+			PaymentProvider: PaymentProviderBalance,
+			Status:          common.TopUpStatusSuccess,
+			CreateTime:      now,
+			CompleteTime:    now,
+			ProviderPayload: fmt.Sprintf("organization_id=%d,charged_quota=%d", orgId, requiredQuota),
+		}
+		if err := tx.Create(order).Error; err != nil {
+			return err
+		}
+		if err := PostQuotaLedgerTx(tx, QuotaLedgerPosting{
+			Account: QuotaLedgerOrganizationAccount(orgId),
+			UserId:  userId,
+			Amount:  -int64(requiredQuota),
+			Reason:  QuotaLedgerReasonSubscriptionPurchase,
+			RefType: QuotaLedgerRefTradeNo,
+			RefId:   tradeNo,
+		}); err != nil {
+			return err
+		}
+		logPlanTitle = plan.Title
+		chargedQuota = requiredQuota
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	RecordLog(userId, LogTypeTopup, fmt.Sprintf("使用组织 #%d 余额购买订阅成功，套餐: %s，扣除额度: %d", orgId, logPlanTitle, chargedQuota))
+	return nil
+}
+
+// GetOrganizationSubscriptions 返回组织的全部订阅（含已过期）。
+func GetOrganizationSubscriptions(orgId int) ([]SubscriptionSummary, error) {
+	if orgId <= 0 {
+		return nil, errors.New("invalid orgId")
+	}
+	var subs []UserSubscription
+	err := DB.Where("organization_id = ?", orgId).
+		Order("end_time desc, id desc").
+		Find(&subs).Error
+	if err != nil {
+		return nil, err
+	}
+	return buildSubscriptionSummaries(subs), nil
+}
+
+// HasActiveOrganizationSubscription 轻量检查组织是否有生效中的订阅，避免无订阅时进入预扣事务。
+func HasActiveOrganizationSubscription(orgId int) (bool, error) {
+	if orgId <= 0 {
+		return false, errors.New("invalid orgId")
+	}
+	var count int64
+	if err := DB.Model(&UserSubscription{}).
+		Where("organization_id = ? AND status = ? AND end_time > ?", orgId, "active", common.GetTimestamp()).
+		Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// OrganizationActiveSubscriptionsAllowWalletOverflow 返回组织订阅额度用尽后是否允许回退到组织钱包，
+// 任一生效中的订阅禁止回退即不允许。
+func OrganizationActiveSubscriptionsAllowWalletOverflow(orgId int) (bool, error) {
+	if orgId <= 0 {
+		return false, errors.New("invalid orgId")
+	}
+	var strictCount int64
+	if err := DB.Model(&UserSubscription{}).
+		Where("organization_id = ? AND status = ? AND end_time > ? AND allow_wallet_overflow = ?",
+			orgId, "active", common.GetTimestamp(), false).
+		Count(&strictCount).Error; err != nil {
+		return false, err
+	}
+	return strictCount == 0, nil
+}
@@ -0,0 +1,173 @@
+package model
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func setupOrganization(t *testing.T, quota int) (*Organization, *User) {
+	t.Helper()
+	require.NoError(t, DB.AutoMigrate(&User{}, &Organization{}, &OrganizationMember{}))
+	t.Cleanup(func() {
+		DB.Exec("DELETE FROM users")
+		DB.Exec("DELETE FROM organizations")
+		DB.Exec("DELETE FROM organization_members")
+		DB.Exec("DELETE FROM quota_ledger_entries")
+		DB.Exec("DELETE FROM quota_ledger_accounts")
+	})
+	owner := &User{Username: "org-owner", AffCode: "org-owner", Quota: 1000}
+	require.NoError(t, DB.Create(owner).Error)
+	org, err := CreateOrganization("acme", owner.Id)
+	require.NoError(t, err)
+	if quota != 0 {
+		require.NoError(t, AdjustOrganizationQuota(org.Id, quota, 0))
+	}
+	return org, owner
+}
+
+func getOrganizationForTest(t *testing.T, id int) *Organization {
+	t.Helper()
+	org, err := GetOrganizationById(id)
+	require.NoError(t, err)
+	return org
+}
+
+func TestPreConsumeOrganizationQuotaEnforcesWalletAndMemberLimit(t *testing.T) {
+	org, _ := setupOrganization(t, 500)
+	member := &User{Username: "org-member", AffCode: "org-member"}
+	require.NoError(t, DB.Create(member).Error)
+	_, err := AddOrganizationMember(org.Id, member.Id, OrganizationRoleMember, 300)
+	require.NoError(t, err)
+
+	require.NoError(t, PreConsumeOrganizationQuota(org.Id, member.Id, 200, "", ""))
+	require.ErrorIs(t, PreConsumeOrganizationQuota(org.Id, member.Id, 150, "", ""), ErrOrganizationMemberLimitExceeded)
+
+	// 结算补扣不受上限约束，退款会同步回退成员已用额度
+	require.NoError(t, SettleOrganizationQuota(org.Id, member.Id, 150, "", ""))
+	require.NoError(t, SettleOrganizationQuota(org.Id, member.Id, -50, "", ""))
+	stored := getOrganizationForTest(t, org.Id)
+	require.Equal(t, 200, stored.Quota)
+	require.Equal(t, 300, stored.UsedQuota)
+	m, err := GetOrganizationMember(org.Id, member.Id)
+	require.NoError(t, err)
+	require.Equal(t, 300, m.SpendUsed)
+
+	// 只修改角色时保留原有的消费上限
+	require.NoError(t, UpdateOrganizationMember(org.Id, member.Id, OrganizationRoleAdmin, nil, false))
+	m, err = GetOrganizationMember(org.Id, member.Id)
+	require.NoError(t, err)
+	require.Equal(t, OrganizationRoleAdmin, m.Role)
+	require.Equal(t, 300, m.SpendLimit)
+
+	require.NoError(t, UpdateOrganizationMember(org.Id, member.Id, "", nil, true))
+	require.ErrorIs(t, PreConsumeOrganizationQuota(org.Id, member.Id, 201, "", ""), ErrOrganizationQuotaInsufficient)
+	// 失败的预扣不能留下成员已用额度
+	m, err = GetOrganizationMember(org.Id, member.Id)
+	require.NoError(t, err)
+	require.Zero(t, m.SpendUsed)
+
+	outsider := &User{Username: "outsider", AffCode: "outsider"}
+	require.NoError(t, DB.Create(outsider).Error)
+	require.ErrorIs(t, PreConsumeOrganizationQuota(org.Id, outsider.Id, 1, "", ""), ErrOrganizationNotMember)
+	require.ErrorIs(t, PreConsumeOrganizationQuota(org.Id, outsider.Id, 0, "", ""), ErrOrganizationNotMember)
+
+	require.NoError(t, UpdateOrganizationStatus(org.Id, OrganizationStatusDisabled))
+	require.ErrorIs(t, PreConsumeOrganizationQuota(org.Id, member.Id, 1, "", ""), ErrOrganizationNotFound)
+}
+
+func TestOrganizationKeepsLastOwner(t *testing.T) {
+	org, owner := setupOrganization(t, 0)
+	require.ErrorIs(t, RemoveOrganizationMember(org.Id, owner.Id), ErrOrganizationLastOwner)
+	require.ErrorIs(t, UpdateOrganizationMember(org.Id, owner.Id, OrganizationRoleMember, nil, false), ErrOrganizationLastOwner)
+
+	second := &User{Username: "second-owner", AffCode: "second-owner"}
+	require.NoError(t, DB.Create(second).Error)
+	_, err := AddOrganizationMember(org.Id, second.Id, OrganizationRoleOwner, 0)
+	require.NoError(t, err)
+	_, err = AddOrganizationMember(org.Id, second.Id, OrganizationRoleMember, 0)
+	require.ErrorIs(t, err, ErrOrganizationMemberExists)
+	require.NoError(t, RemoveOrganizationMember(org.Id, owner.Id))
+}
+
+func TestFundOrganizationFromUser(t *testing.T) {
+	org, owner := setupOrganization(t, 0)
+	require.Error(t, FundOrganizationFromUser(org.Id, owner.Id, 2000))
+	require.NoError(t, FundOrganizationFromUser(org.Id, owner.Id, 600))
+
+	var user User
+	require.NoError(t, DB.Select("quota").First(&user, owner.Id).Error)
+	require.Equal(t, 400, user.Quota)
+	require.Equal(t, 600, getOrganizationForTest(t, org.Id).Quota)
+}
+
+// useSharedMemoryDB 切换到允许多连接的内存库：创建订阅时 GetDBTimestamp 会在事务外另取连接。
+func useSharedMemoryDB(t *testing.T) {
+	t.Helper()
+	previousDB, previousLogDB := DB, LOG_DB
+	dsn := fmt.Sprintf("file:%s?mode=memory&cache=shared", strings.ReplaceAll(t.Name(), "/", "_"))
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{})
+	require.NoError(t, err)
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	sqlDB.SetMaxOpenConns(4)
+	DB, LOG_DB = db, db
+	t.Cleanup(func() {
+		DB, LOG_DB = previousDB, previousLogDB
+		_ = sqlDB.Close()
+	})
+	require.NoError(t, db.AutoMigrate(&SubscriptionPlan{}, &UserSubscription{}, &UserSubscriptionBucket{},
+		&SubscriptionPreConsumeRecord{}, &QuotaLedgerEntry{}, &QuotaLedgerAccount{}))
+}
+
+func TestOrganizationSubscriptionIsSeparateFromPersonalAndChargesMemberSpend(t *testing.T) {
+	useSharedMemoryDB(t)
+	org, owner := setupOrganization(t, 1000)
+	member := &User{Username: "org-sub-member", AffCode: "org-sub-member"}
+	require.NoError(t, DB.Create(member).Error)
+	_, err := AddOrganizationMember(org.Id, member.Id, OrganizationRoleMember, 250)
+	require.NoError(t, err)
+
+	plan := &SubscriptionPlan{Id: 9401, Title: "Team", Enabled: true, PriceAmount: 0, DurationUnit: SubscriptionDurationMonth, DurationValue: 1, TotalAmount: 500}
+	require.NoError(t, DB.Create(plan).Error)
+	InvalidateSubscriptionPlanCache(plan.Id)
+	t.Cleanup(func() { InvalidateSubscriptionPlanCache(plan.Id) })
+
+	require.NoError(t, AdminBindOrganizationSubscription(org.Id, plan.Id))
+	subs, err := GetOrganizationSubscriptions(org.Id)
+	require.NoError(t, err)
+	require.Len(t, subs, 1)
+	require.Equal(t, org.Id, subs[0].Subscription.OrganizationId)
+
+	// 组织订阅不会出现在所有者的个人订阅中，也不会被个人请求消耗
+	hasPersonal, err := HasActiveUserSubscription(owner.Id)
+	require.NoError(t, err)
+	require.False(t, hasPersonal)
+	_, err = PreConsumeUserSubscription(SubscriptionPreConsumeParams{RequestId: "org-sub-personal", UserId: owner.Id, Amount: 10})
+	require.Error(t, err)
+
+	res, err := PreConsumeUserSubscription(SubscriptionPreConsumeParams{RequestId: "org-sub-1", UserId: member.Id, OrganizationId: org.Id, Amount: 200})
+	require.NoError(t, err)
+	require.Equal(t, subs[0].Subscription.Id, res.UserSubscriptionId)
+	m, err := GetOrganizationMember(org.Id, member.Id)
+	require.NoError(t, err)
+	require.Equal(t, 200, m.SpendUsed)
+
+	// 成员消费上限同样约束组织订阅，失败时不扣订阅额度
+	_, err = PreConsumeUserSubscription(SubscriptionPreConsumeParams{RequestId: "org-sub-2", UserId: member.Id, OrganizationId: org.Id, Amount: 100})
+	require.ErrorIs(t, err, ErrOrganizationMemberLimitExceeded)
+
+	require.NoError(t, RefundSubscriptionPreConsume("org-sub-1"))
+	m, err = GetOrganizationMember(org.Id, member.Id)
+	require.NoError(t, err)
+	require.Zero(t, m.SpendUsed)
+	var sub UserSubscription
+	require.NoError(t, DB.First(&sub, subs[0].Subscription.Id).Error)
+	require.Zero(t, sub.AmountUsed)
+	// 组织钱包不受订阅扣费影响
+	require.Equal(t, 1000, getOrganizationForTest(t, org.Id).Quota)
+}
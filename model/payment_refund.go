@@ -0,0 +1,220 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/logger"
+
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+// PaymentRefund 记录支付渠道同步过来的退款，(provider, refund_id) 唯一，重复的 webhook 不会重复扣减额度
+type PaymentRefund struct {
+	Id        int     `json:"id"`
+	Provider  string  `json:"provider" gorm:"type:varchar(50);uniqueIndex:idx_payment_refund_provider_ref"`
+	RefundId  string  `json:"refund_id" gorm:"type:varchar(128);uniqueIndex:idx_payment_refund_provider_ref"`
+	TradeNo   string  `json:"trade_no" gorm:"type:varchar(255);index"`
+	UserId    int     `json:"user_id" gorm:"index"`
+	Money     float64 `json:"money"`
+	Currency  string  `json:"currency" gorm:"type:varchar(8);default:''"`
+	Quota     int64   `json:"quota"` // 扣回的额度，订阅订单为 0
+	CreatedAt int64   `json:"created_at" gorm:"bigint;index"`
+}
+
+var ErrPaymentRefundOrderInvalid = errors.New("退款对应的订单不存在或未支付")
+
+// CompleteProviderTopUp 按支付渠道完成充值订单：校验渠道和状态后入账额度、记录账本、额度批次和优惠码赠送，
+// 已成功的订单直接返回，供没有专用 RechargeXxx 的渠道复用
+func CompleteProviderTopUp(tradeNo string, provider string, callerIp string) error {
+	if tradeNo == "" {
+		return errors.New("未提供支付单号")
+	}
+
+	var quotaToAdd int
+	topUp := &TopUp{}
+
+	refCol := "`trade_no`"
+	if common.UsingMainDatabase(common.DatabaseTypePostgreSQL) {
+		refCol = `"trade_no"`
+	}
+
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		quotaToAdd = 0
+		if err := lockForUpdate(tx).Where(refCol+" = ?", tradeNo).First(topUp).Error; err != nil {
+			return ErrTopUpNotFound
+		}
+		if topUp.PaymentProvider != provider {
+			return ErrPaymentMethodMismatch
+		}
+		if topUp.Status == common.TopUpStatusSuccess {
+			return nil
+		}
+		if topUp.Status != common.TopUpStatusPending {
+			return ErrTopUpStatusInvalid
+		}
+
+		quotaToAdd = int(decimal.NewFromInt(topUp.Amount).Mul(decimal.NewFromFloat(common.QuotaPerUnit)).IntPart())
+		if quotaToAdd <= 0 {
+			return errors.New("无效的充值额度")
+		}
+
+		topUp.CompleteTime = common.GetTimestamp()
+		topUp.Status = common.TopUpStatusSuccess
+		if err := tx.Save(topUp).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&User{}).Where("id = ?", topUp.UserId).Update("quota", gorm.Expr("quota + ?", quotaToAdd)).Error; err != nil {
+			return err
+		}
+		if err := PostQuotaLedgerTx(tx, UserQuotaLedgerPosting(topUp.UserId, quotaToAdd, QuotaLedgerReasonTopup, QuotaLedgerRefTradeNo, topUp.TradeNo)); err != nil {
+			return err
+		}
+		if err := GrantQuotaLotTx(tx, topUp.UserId, QuotaLotSourceTopup, topUp.TradeNo, quotaToAdd, QuotaLotValidDays(QuotaLotSourceTopup)); err != nil {
+			return err
+		}
+		bonus, err := grantTopUpCouponBonusTx(tx, topUp, quotaToAdd)
+		quotaToAdd += bonus
+		return err
+	})
+	if err != nil {
+		common.SysError(fmt.Sprintf("%s topup failed: %s", provider, err.Error()))
+		return err
+	}
+
+	if quotaToAdd > 0 {
+		RecordTopupLog(topUp.UserId, fmt.Sprintf("%s充值成功，充值额度: %v，支付金额: %.2f %s", provider, logger.FormatQuota(quotaToAdd), topUp.Money, topUp.Currency), callerIp, topUp.PaymentMethod, provider)
+	}
+	return nil
+}
+
+// PaymentRefundParams 为支付渠道通知的一笔退款，Money 与订单同币种
+type PaymentRefundParams struct {
+	Provider string
+	RefundId string
+	TradeNo  string
+	Money    float64
+	Currency string
+}
+
+// RecordPaymentRefund 同步一笔渠道退款。充值订单按退款金额占实付金额的比例扣回额度（含优惠码赠送），
+// 允许余额扣成负数以免退款后继续使用已退还的额度，该订单入账的有效期批次同步扣减；累计退款达到实付金额时订单标记为 refunded。
+// 订阅订单只标记 refunded，已生效的订阅由管理员决定是否作废。返回 nil 表示该退款已处理过
+func RecordPaymentRefund(params PaymentRefundParams) (*PaymentRefund, error) {
+	if params.Provider == "" || params.RefundId == "" || params.TradeNo == "" {
+		return nil, errors.New("退款参数不完整")
+	}
+	if params.Money <= 0 {
+		return nil, errors.New("退款金额无效")
+	}
+
+	refCol := "`trade_no`"
+	if common.UsingMainDatabase(common.DatabaseTypePostgreSQL) {
+		refCol = `"trade_no"`
+	}
+
+	var refund *PaymentRefund
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		refund = nil
+		var existing int64
+		if err := tx.Model(&PaymentRefund{}).Where("provider = ? AND refund_id = ?", params.Provider, params.RefundId).Count(&existing).Error; err != nil {
+			return err
+		}
+		if existing > 0 {
+			return nil
+		}
+
+		record := &PaymentRefund{
+			Provider:  params.Provider,
+			RefundId:  params.RefundId,
+			TradeNo:   params.TradeNo,
+			Money:     params.Money,
+			Currency:  params.Currency,
+			CreatedAt: common.GetTimestamp(),
+		}
+
+		var order SubscriptionOrder
+		err := lockForUpdate(tx).Where(refCol+" = ?", params.TradeNo).First(&order).Error
+		if err == nil {
+			if order.PaymentProvider != params.Provider {
+				return ErrPaymentMethodMismatch
+			}
+			if order.Status != common.TopUpStatusSuccess && order.Status != common.TopUpStatusRefunded {
+				return ErrPaymentRefundOrderInvalid
+			}
+			record.UserId = order.UserId
+			if err := tx.Model(&SubscriptionOrder{}).Where("id = ?", order.Id).Update("status", common.TopUpStatusRefunded).Error; err != nil {
+				return err
+			}
+			if err := tx.Model(&TopUp{}).Where(refCol+" = ?", order.TradeNo).Updates(map[string]interface{}{
+				"status":         common.TopUpStatusRefunded,
+				"refunded_money": gorm.Expr("refunded_money + ?", params.Money),
+			}).Error; err != nil {
+				return err
+			}
+			refund = record
+			return tx.Create(record).Error
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		topUp := &TopUp{}
+		if err := lockForUpdate(tx).Where(refCol+" = ?", params.TradeNo).First(topUp).Error; err != nil {
+			return ErrPaymentRefundOrderInvalid
+		}
+		if topUp.PaymentProvider != params.Provider {
+			return ErrPaymentMethodMismatch
+		}
+		if topUp.Status != common.TopUpStatusSuccess && topUp.Status != common.TopUpStatusRefunded {
+			return ErrPaymentRefundOrderInvalid
+		}
+		record.UserId = topUp.UserId
+
+		refundable := decimal.NewFromFloat(topUp.Money).Sub(decimal.NewFromFloat(topUp.RefundedMoney))
+		refundMoney := decimal.NewFromFloat(params.Money)
+		if refundMoney.GreaterThan(refundable) {
+			refundMoney = refundable
+		}
+		if topUp.Money > 0 && refundMoney.IsPositive() {
+			granted := decimal.NewFromInt(topUp.Amount).Mul(decimal.NewFromFloat(common.QuotaPerUnit)).Add(decimal.NewFromInt(topUp.BonusQuota))
+			record.Quota = granted.Mul(refundMoney).Div(decimal.NewFromFloat(topUp.Money)).IntPart()
+		}
+
+		topUp.RefundedMoney, _ = decimal.NewFromFloat(topUp.RefundedMoney).Add(refundMoney).Float64()
+		if !refundable.Sub(refundMoney).GreaterThan(decimal.NewFromFloat(0.005)) {
+			topUp.Status = common.TopUpStatusRefunded
+		}
+		if err := tx.Save(topUp).Error; err != nil {
+			return err
+		}
+		if record.Quota > 0 {
+			if err := tx.Model(&User{}).Where("id = ?", topUp.UserId).Update("quota", gorm.Expr("quota - ?", record.Quota)).Error; err != nil {
+				return err
+			}
+			if err := revokeTradeQuotaLotsTx(tx, topUp.UserId, topUp.TradeNo, record.Quota); err != nil {
+				return err
+			}
+		}
+		if err := tx.Create(record).Error; err != nil {
+			return err
+		}
+		refund = record
+		return PostQuotaLedgerTx(tx, UserQuotaLedgerPosting(topUp.UserId, -int(record.Quota), QuotaLedgerReasonTopupRefund, QuotaLedgerRefPaymentRefund, strconv.Itoa(record.Id)))
+	})
+	if err != nil {
+		return nil, err
+	}
+	if refund == nil {
+		return nil, nil
+	}
+
+	if refund.Quota > 0 {
+		_ = cacheDecrUserQuota(refund.UserId, refund.Quota)
+	}
+	RecordLog(refund.UserId, LogTypeTopup, fmt.Sprintf("%s 订单 %s 退款 %.2f %s，扣回额度 %s", refund.Provider, refund.TradeNo, refund.Money, refund.Currency, logger.LogQuota(int(refund.Quota))))
+	return refund, nil
+}
@@ -0,0 +1,59 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecordPaymentRefundClawsBackQuotaProportionally(t *testing.T) {
+	truncateTables(t)
+	user := &User{Username: "refund-user", Status: common.UserStatusEnabled}
+	require.NoError(t, DB.Create(user).Error)
+	topUp := &TopUp{
+		UserId:          user.Id,
+		Amount:          10,
+		Money:           20,
+		TradeNo:         "PAYPAL-refund-1",
+		PaymentMethod:   PaymentMethodPayPal,
+		PaymentProvider: PaymentProviderPayPal,
+		Status:          common.TopUpStatusPending,
+	}
+	require.NoError(t, topUp.Insert())
+	require.NoError(t, CompleteProviderTopUp(topUp.TradeNo, PaymentProviderPayPal, "127.0.0.1"))
+
+	granted := int(10 * common.QuotaPerUnit)
+	quota, err := GetUserQuota(user.Id, true)
+	require.NoError(t, err)
+	require.Equal(t, granted, quota)
+
+	params := PaymentRefundParams{Provider: PaymentProviderPayPal, RefundId: "R1", TradeNo: topUp.TradeNo, Money: 5, Currency: "USD"}
+	refund, err := RecordPaymentRefund(params)
+	require.NoError(t, err)
+	require.NotNil(t, refund)
+	assert.EqualValues(t, granted/4, refund.Quota)
+	assert.Equal(t, common.TopUpStatusSuccess, GetTopUpByTradeNo(topUp.TradeNo).Status)
+
+	// 重复的 webhook 不会重复扣减
+	refund, err = RecordPaymentRefund(params)
+	require.NoError(t, err)
+	assert.Nil(t, refund)
+
+	// 超出剩余可退金额的部分按剩余金额计算
+	refund, err = RecordPaymentRefund(PaymentRefundParams{Provider: PaymentProviderPayPal, RefundId: "R2", TradeNo: topUp.TradeNo, Money: 30, Currency: "USD"})
+	require.NoError(t, err)
+	require.NotNil(t, refund)
+	assert.EqualValues(t, granted*3/4, refund.Quota)
+
+	stored := GetTopUpByTradeNo(topUp.TradeNo)
+	assert.Equal(t, common.TopUpStatusRefunded, stored.Status)
+	assert.InDelta(t, 20, stored.RefundedMoney, 0.001)
+	quota, err = GetUserQuota(user.Id, true)
+	require.NoError(t, err)
+	assert.Equal(t, 0, quota)
+
+	_, err = RecordPaymentRefund(PaymentRefundParams{Provider: PaymentProviderStripe, RefundId: "R3", TradeNo: topUp.TradeNo, Money: 1})
+	assert.ErrorIs(t, err, ErrPaymentMethodMismatch)
+}
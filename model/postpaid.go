@@ -0,0 +1,646 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	PostpaidAccountStatusActive    = "active"
+	PostpaidAccountStatusSuspended = "suspended"
+)
+
+const (
+	PostpaidStatementStatusOpen    = "open"
+	PostpaidStatementStatusOverdue = "overdue"
+	PostpaidStatementStatusPaid    = "paid"
+)
+
+var (
+	ErrPostpaidAccountNotFound   = errors.New("信用额度账户不存在")
+	ErrPostpaidStatementNotFound = errors.New("账单不存在")
+	ErrPostpaidStatementPaid     = errors.New("账单已结清")
+)
+
+// PostpaidAccount 为用户或组织开通的信用额度。开通后钱包余额可以透支到 -CreditLimit，
+// 按月出账、线下付款。OwnerType 取值与 BillingProfile 相同。
+// GraceDays 为 0 时使用全局设置；逾期暂停时被停用的令牌记录在 SuspendedTokenIds，结清后恢复。
+type PostpaidAccount struct {
+	Id                int    `json:"id"`
+	OwnerType         string `json:"owner_type" gorm:"type:varchar(16);uniqueIndex:idx_postpaid_owner"`
+	OwnerId           int    `json:"owner_id" gorm:"uniqueIndex:idx_postpaid_owner"`
+	CreditLimit       int    `json:"credit_limit"`
+	GraceDays         int    `json:"grace_days"`
+	Status            string `json:"status" gorm:"type:varchar(16);default:'active';index"`
+	SuspendedTokenIds string `json:"-" gorm:"type:text"`
+	SuspendedAt       int64  `json:"suspended_at" gorm:"bigint"`
+	Remark            string `json:"remark" gorm:"type:varchar(255)"`
+	CreatedAt         int64  `json:"created_at" gorm:"bigint"`
+	UpdatedAt         int64  `json:"updated_at" gorm:"bigint"`
+}
+
+// PostpaidStatement 是信用额度账户某个账期的账单，同一账户同一账期只生成一张。
+// UsageQuota 为账期内钱包支付的用量（不含订阅支付），BilledQuota 为其中实际透支信用额度的部分，
+// 预付余额覆盖的用量不计费；关闭消费日志时 UsageQuota 为 0，BilledQuota 按透支的额度计算。
+// Amount 按 BilledQuota / QuotaPerUnit 折算，PaidAmount 为已登记的线下付款合计。
+type PostpaidStatement struct {
+	Id          int     `json:"id"`
+	AccountId   int     `json:"account_id" gorm:"uniqueIndex:idx_postpaid_statement_period,priority:1"`
+	OwnerType   string  `json:"owner_type" gorm:"type:varchar(16);index:idx_postpaid_statement_owner,priority:1"`
+	OwnerId     int     `json:"owner_id" gorm:"index:idx_postpaid_statement_owner,priority:2"`
+	PeriodStart int64   `json:"period_start" gorm:"bigint;uniqueIndex:idx_postpaid_statement_period,priority:2"`
+	PeriodEnd   int64   `json:"period_end" gorm:"bigint"`
+	Requests    int64   `json:"requests"`
+	UsageQuota  int64   `json:"usage_quota"`
+	BilledQuota int64   `json:"billed_quota"`
+	Amount      float64 `json:"amount"`
+	PaidAmount  float64 `json:"paid_amount"`
+	Status      string  `json:"status" gorm:"type:varchar(16);index"`
+	DueAt       int64   `json:"due_at" gorm:"bigint;index"`
+	InvoiceId   int     `json:"invoice_id"`
+	CreatedAt   int64   `json:"created_at" gorm:"bigint"`
+	PaidAt      int64   `json:"paid_at" gorm:"bigint"`
+}
+
+// PostpaidStatementItem 是账单按模型和令牌汇总的用量明细。
+type PostpaidStatementItem struct {
+	Id          int    `json:"id"`
+	StatementId int    `json:"statement_id" gorm:"index"`
+	ModelName   string `json:"model_name" gorm:"type:varchar(255)"`
+	TokenId     int    `json:"token_id"`
+	TokenName   string `json:"token_name" gorm:"type:varchar(255)"`
+	Requests    int64  `json:"requests"`
+	Quota       int64  `json:"quota"`
+}
+
+// PostpaidPayment 是管理员登记的线下付款，登记后按金额为账户钱包入账。
+type PostpaidPayment struct {
+	Id          int     `json:"id"`
+	StatementId int     `json:"statement_id" gorm:"index"`
+	Amount      float64 `json:"amount"`
+	Quota       int     `json:"quota"`
+	Method      string  `json:"method" gorm:"type:varchar(32)"`
+	Reference   string  `json:"reference" gorm:"type:varchar(128)"`
+	Remark      string  `json:"remark" gorm:"type:varchar(255)"`
+	OperatorId  int     `json:"operator_id"`
+	CreatedAt   int64   `json:"created_at" gorm:"bigint"`
+}
+
+func isValidPostpaidOwnerType(ownerType string) bool {
+	return ownerType == BillingProfileOwnerUser || ownerType == BillingProfileOwnerOrganization
+}
+
+// GetPostpaidCreditLimit 返回账户当前可透支的额度；未开通、已暂停或后付费关闭时返回 0。
+func GetPostpaidCreditLimit(ownerType string, ownerId int) int {
+	return getPostpaidCreditLimitTx(DB, ownerType, ownerId)
+}
+
+// GetUserPostpaidCreditLimit 返回用户个人钱包可透支的额度
+func GetUserPostpaidCreditLimit(userId int) int {
+	return GetPostpaidCreditLimit(BillingProfileOwnerUser, userId)
+}
+
+func getPostpaidCreditLimitTx(tx *gorm.DB, ownerType string, ownerId int) int {
+	if !operation_setting.GetPostpaidSetting().Enabled {
+		return 0
+	}
+	var account PostpaidAccount
+	err := tx.Select("credit_limit", "status").
+		Where("owner_type = ? AND owner_id = ?", ownerType, ownerId).
+		Limit(1).Find(&account).Error
+	if err != nil {
+		common.SysError(fmt.Sprintf("failed to load postpaid account %s:%d: %s", ownerType, ownerId, err.Error()))
+		return 0
+	}
+	if account.Status != PostpaidAccountStatusActive || account.CreditLimit <= 0 {
+		return 0
+	}
+	return account.CreditLimit
+}
+
+// UpsertPostpaidAccount 按 OwnerType + OwnerId 创建或更新信用额度设置，不会改变暂停状态。
+func UpsertPostpaidAccount(account *PostpaidAccount) error {
+	if !isValidPostpaidOwnerType(account.OwnerType) || account.OwnerId <= 0 {
+		return errors.New("无效的账户归属")
+	}
+	if account.CreditLimit < 0 || account.GraceDays < 0 {
+		return errors.New("信用额度和付款期限不能为负数")
+	}
+	now := common.GetTimestamp()
+	account.UpdatedAt = now
+	return DB.Transaction(func(tx *gorm.DB) error {
+		var existing PostpaidAccount
+		query := lockForUpdate(tx).Where("owner_type = ? AND owner_id = ?", account.OwnerType, account.OwnerId).Limit(1).Find(&existing)
+		if query.Error != nil {
+			return query.Error
+		}
+		if query.RowsAffected == 0 {
+			account.Id = 0
+			account.Status = PostpaidAccountStatusActive
+			account.SuspendedTokenIds = ""
+			account.SuspendedAt = 0
+			account.CreatedAt = now
+			return tx.Create(account).Error
+		}
+		if err := tx.Model(&existing).Updates(map[string]interface{}{
+			"credit_limit": account.CreditLimit,
+			"grace_days":   account.GraceDays,
+			"remark":       account.Remark,
+			"updated_at":   now,
+		}).Error; err != nil {
+			return err
+		}
+		return tx.First(account, existing.Id).Error
+	})
+}
+
+func GetPostpaidAccountById(id int) (*PostpaidAccount, error) {
+	var account PostpaidAccount
+	if err := DB.First(&account, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrPostpaidAccountNotFound
+		}
+		return nil, err
+	}
+	return &account, nil
+}
+
+func GetPostpaidAccountByOwner(ownerType string, ownerId int) (*PostpaidAccount, error) {
+	var account PostpaidAccount
+	err := DB.Where("owner_type = ? AND owner_id = ?", ownerType, ownerId).First(&account).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrPostpaidAccountNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &account, nil
+}
+
+func GetPostpaidAccounts(status string, startIdx int, num int) (accounts []*PostpaidAccount, total int64, err error) {
+	query := DB.Model(&PostpaidAccount{})
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if err = query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err = query.Order("id desc").Limit(num).Offset(startIdx).Find(&accounts).Error
+	return accounts, total, err
+}
+
+// GetAllPostpaidAccounts 返回全部信用额度账户，供出账任务遍历。
+func GetAllPostpaidAccounts() ([]*PostpaidAccount, error) {
+	var accounts []*PostpaidAccount
+	err := DB.Order("id asc").Find(&accounts).Error
+	return accounts, err
+}
+
+// postpaidOwnerTokenIds 返回账户归属的令牌（含已删除的令牌），组织账户为组织令牌，
+// 用户账户为该用户的个人令牌。
+func postpaidOwnerTokenIds(tx *gorm.DB, ownerType string, ownerId int, onlyEnabled bool) ([]int, error) {
+	query := tx.Model(&Token{})
+	if !onlyEnabled {
+		query = query.Unscoped()
+	}
+	if ownerType == BillingProfileOwnerOrganization {
+		query = query.Where("organization_id = ?", ownerId)
+	} else {
+		query = query.Where("user_id = ? AND organization_id = 0", ownerId)
+	}
+	if onlyEnabled {
+		query = query.Where("status = ?", common.TokenStatusEnabled)
+	}
+	var ids []int
+	err := query.Pluck("id", &ids).Error
+	return ids, err
+}
+
+// GeneratePostpaidStatement 汇总账户在 [periodStart, periodEnd) 内的消费日志生成账单，
+// 关闭消费日志时只按钱包透支的额度出账。
+// 账单已存在时直接返回，created 为 false。
+func GeneratePostpaidStatement(account *PostpaidAccount, periodStart int64, periodEnd int64, graceDays int) (statement *PostpaidStatement, created bool, err error) {
+	var existing PostpaidStatement
+	query := DB.Where("account_id = ? AND period_start = ?", account.Id, periodStart).Limit(1).Find(&existing)
+	if query.Error != nil {
+		return nil, false, query.Error
+	}
+	if query.RowsAffected > 0 {
+		return &existing, false, nil
+	}
+
+	items, err := aggregatePostpaidUsage(account, periodStart, periodEnd)
+	if err != nil {
+		return nil, false, err
+	}
+	if account.GraceDays > 0 {
+		graceDays = account.GraceDays
+	}
+	statement = &PostpaidStatement{
+		AccountId:   account.Id,
+		OwnerType:   account.OwnerType,
+		OwnerId:     account.OwnerId,
+		PeriodStart: periodStart,
+		PeriodEnd:   periodEnd,
+		Status:      PostpaidStatementStatusOpen,
+		DueAt:       periodEnd + int64(graceDays)*86400,
+		CreatedAt:   common.GetTimestamp(),
+	}
+	for _, item := range items {
+		statement.Requests += item.Requests
+		statement.UsageQuota += item.Quota
+	}
+	if statement.UsageQuota > 0 || !common.LogConsumeEnabled {
+		drawn, err := postpaidCreditDrawnQuota(account, periodStart, periodEnd)
+		if err != nil {
+			return nil, false, err
+		}
+		if common.LogConsumeEnabled {
+			statement.BilledQuota = min(statement.UsageQuota, drawn)
+		} else {
+			// 未记录消费日志时无法汇总用量，直接按透支的额度出账
+			statement.BilledQuota = drawn
+		}
+	}
+	statement.Amount = postpaidQuotaToMoney(statement.BilledQuota)
+	if statement.Amount <= 0 {
+		statement.Status = PostpaidStatementStatusPaid
+		statement.PaidAt = statement.CreatedAt
+	}
+
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(statement)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			// 并发生成时以先写入的账单为准
+			return tx.Where("account_id = ? AND period_start = ?", account.Id, periodStart).First(statement).Error
+		}
+		created = true
+		if len(items) == 0 {
+			return nil
+		}
+		for _, item := range items {
+			item.StatementId = statement.Id
+		}
+		return tx.CreateInBatches(items, 200).Error
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	return statement, created, nil
+}
+
+// postpaidSubscriptionLogPattern 匹配订阅支付的消费日志（Other.billing_source），这部分用量不占用信用额度。
+const postpaidSubscriptionLogPattern = `%"billing_source":"subscription"%`
+
+// postpaidUsageQuery 返回账户钱包支付的消费日志查询；账户没有任何令牌时返回 nil。
+func postpaidUsageQuery(account *PostpaidAccount) (*gorm.DB, error) {
+	query := LOG_DB.Model(&Log{}).
+		Where("type = ? AND COALESCE(other, '') NOT LIKE ?", LogTypeConsume, postpaidSubscriptionLogPattern)
+	if account.OwnerType == BillingProfileOwnerOrganization {
+		tokenIds, err := postpaidOwnerTokenIds(DB, account.OwnerType, account.OwnerId, false)
+		if err != nil {
+			return nil, err
+		}
+		if len(tokenIds) == 0 {
+			return nil, nil
+		}
+		return query.Where("token_id IN ?", tokenIds), nil
+	}
+	query = query.Where("user_id = ?", account.OwnerId)
+	var orgTokenIds []int
+	if err := DB.Model(&Token{}).Unscoped().Where("user_id = ? AND organization_id > 0", account.OwnerId).Pluck("id", &orgTokenIds).Error; err != nil {
+		return nil, err
+	}
+	if len(orgTokenIds) > 0 {
+		query = query.Where("token_id NOT IN ?", orgTokenIds)
+	}
+	return query, nil
+}
+
+func aggregatePostpaidUsage(account *PostpaidAccount, periodStart int64, periodEnd int64) ([]*PostpaidStatementItem, error) {
+	query, err := postpaidUsageQuery(account)
+	if err != nil || query == nil {
+		return nil, err
+	}
+	var items []*PostpaidStatementItem
+	err = query.Select("model_name, token_id, token_name, count(*) AS requests, COALESCE(sum(quota), 0) AS quota").
+		Where("created_at >= ? AND created_at < ?", periodStart, periodEnd).
+		Group("model_name, token_id, token_name").Order("quota desc").Scan(&items).Error
+	return items, err
+}
+
+// postpaidCreditDrawnQuota 估算账期结束时账户透支且尚未出账的额度：
+// 当前钱包欠款加回账期结束后的钱包用量，再扣除此前账单尚未付清的部分。
+// 账期结束后的充值视为偿还欠款，因此不加回。
+func postpaidCreditDrawnQuota(account *PostpaidAccount, periodStart int64, periodEnd int64) (int64, error) {
+	var balance int64
+	if account.OwnerType == BillingProfileOwnerOrganization {
+		if err := DB.Model(&Organization{}).Where("id = ?", account.OwnerId).Select("quota").Scan(&balance).Error; err != nil {
+			return 0, err
+		}
+	} else if err := DB.Model(&User{}).Where("id = ?", account.OwnerId).Select("quota").Scan(&balance).Error; err != nil {
+		return 0, err
+	}
+
+	query, err := postpaidUsageQuery(account)
+	if err != nil {
+		return 0, err
+	}
+	var laterUsage int64
+	if query != nil {
+		if err := query.Select("COALESCE(sum(quota), 0)").Where("created_at >= ?", periodEnd).Scan(&laterUsage).Error; err != nil {
+			return 0, err
+		}
+	}
+
+	var unpaid []*PostpaidStatement
+	if err := DB.Where("account_id = ? AND status <> ? AND period_start < ?", account.Id, PostpaidStatementStatusPaid, periodStart).
+		Find(&unpaid).Error; err != nil {
+		return 0, err
+	}
+	outstanding := decimal.Zero
+	for _, statement := range unpaid {
+		outstanding = outstanding.Add(decimal.NewFromFloat(statement.Amount).Sub(decimal.NewFromFloat(statement.PaidAmount)))
+	}
+	outstandingQuota := outstanding.Mul(decimal.NewFromFloat(common.QuotaPerUnit)).IntPart()
+
+	drawn := -(balance + laterUsage) - outstandingQuota
+	if drawn < 0 {
+		return 0, nil
+	}
+	return drawn, nil
+}
+
+func postpaidQuotaToMoney(quota int64) float64 {
+	if common.QuotaPerUnit <= 0 {
+		return 0
+	}
+	return decimal.NewFromInt(quota).Div(decimal.NewFromFloat(common.QuotaPerUnit)).Round(2).InexactFloat64()
+}
+
+func SetPostpaidStatementInvoice(statementId int, invoiceId int) error {
+	return DB.Model(&PostpaidStatement{}).Where("id = ?", statementId).Update("invoice_id", invoiceId).Error
+}
+
+func GetPostpaidStatementById(id int) (*PostpaidStatement, error) {
+	var statement PostpaidStatement
+	if err := DB.First(&statement, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrPostpaidStatementNotFound
+		}
+		return nil, err
+	}
+	return &statement, nil
+}
+
+func GetPostpaidStatementItems(statementId int) ([]*PostpaidStatementItem, error) {
+	var items []*PostpaidStatementItem
+	err := DB.Where("statement_id = ?", statementId).Order("quota desc").Find(&items).Error
+	return items, err
+}
+
+func GetPostpaidPayments(statementId int) ([]*PostpaidPayment, error) {
+	var payments []*PostpaidPayment
+	err := DB.Where("statement_id = ?", statementId).Order("id asc").Find(&payments).Error
+	return payments, err
+}
+
+// GetPostpaidStatements 按账户或归属查询账单；accountId 为 0 且 ownerType 为空时查询全部。
+func GetPostpaidStatements(accountId int, ownerType string, ownerId int, status string, startIdx int, num int) (statements []*PostpaidStatement, total int64, err error) {
+	query := DB.Model(&PostpaidStatement{})
+	if accountId > 0 {
+		query = query.Where("account_id = ?", accountId)
+	}
+	if ownerType != "" {
+		query = query.Where("owner_type = ? AND owner_id = ?", ownerType, ownerId)
+	}
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if err = query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err = query.Order("period_start desc, id desc").Limit(num).Offset(startIdx).Find(&statements).Error
+	return statements, total, err
+}
+
+// MarkOverduePostpaidStatements 把超过付款期限仍未结清的账单标记为逾期，返回涉及的账户 ID。
+func MarkOverduePostpaidStatements(now int64) ([]int, error) {
+	if err := DB.Model(&PostpaidStatement{}).
+		Where("status = ? AND due_at < ?", PostpaidStatementStatusOpen, now).
+		Update("status", PostpaidStatementStatusOverdue).Error; err != nil {
+		return nil, err
+	}
+	var accountIds []int
+	err := DB.Model(&PostpaidStatement{}).
+		Where("status = ?", PostpaidStatementStatusOverdue).
+		Distinct("account_id").Pluck("account_id", &accountIds).Error
+	return accountIds, err
+}
+
+// SuspendPostpaidAccount 暂停逾期账户：停用其所有启用中的令牌并记录下来，结清后恢复。
+// 账户已处于暂停状态时返回 false。
+func SuspendPostpaidAccount(accountId int) (bool, error) {
+	var tokens []Token
+	suspended := false
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		var account PostpaidAccount
+		if err := lockForUpdate(tx).First(&account, accountId).Error; err != nil {
+			return err
+		}
+		if account.Status == PostpaidAccountStatusSuspended {
+			return nil
+		}
+		tokenIds, err := postpaidOwnerTokenIds(tx, account.OwnerType, account.OwnerId, true)
+		if err != nil {
+			return err
+		}
+		if len(tokenIds) > 0 {
+			if err := tx.Model(&Token{}).Where("id IN ?", tokenIds).Update("status", common.TokenStatusDisabled).Error; err != nil {
+				return err
+			}
+			if err := tx.Where("id IN ?", tokenIds).Find(&tokens).Error; err != nil {
+				return err
+			}
+		}
+		suspended = true
+		return tx.Model(&account).Updates(map[string]interface{}{
+			"status":              PostpaidAccountStatusSuspended,
+			"suspended_token_ids": joinPostpaidTokenIds(tokenIds),
+			"suspended_at":        common.GetTimestamp(),
+			"updated_at":          common.GetTimestamp(),
+		}).Error
+	})
+	if err != nil {
+		return false, err
+	}
+	if err := invalidateTokensCache(tokens); err != nil {
+		common.SysLog("failed to invalidate suspended token cache: " + err.Error())
+	}
+	return suspended, nil
+}
+
+// resumePostpaidAccountTx 在账户没有逾期账单时恢复暂停期间停用的令牌。
+// 暂停后被用户手动修改过状态的令牌保持不变。
+func resumePostpaidAccountTx(tx *gorm.DB, accountId int) ([]Token, error) {
+	var account PostpaidAccount
+	if err := lockForUpdate(tx).First(&account, accountId).Error; err != nil {
+		return nil, err
+	}
+	if account.Status != PostpaidAccountStatusSuspended {
+		return nil, nil
+	}
+	var overdue int64
+	if err := tx.Model(&PostpaidStatement{}).Where("account_id = ? AND status = ?", accountId, PostpaidStatementStatusOverdue).Count(&overdue).Error; err != nil {
+		return nil, err
+	}
+	if overdue > 0 {
+		return nil, nil
+	}
+	var tokens []Token
+	if tokenIds := splitPostpaidTokenIds(account.SuspendedTokenIds); len(tokenIds) > 0 {
+		if err := tx.Model(&Token{}).Where("id IN ? AND status = ?", tokenIds, common.TokenStatusDisabled).
+			Update("status", common.TokenStatusEnabled).Error; err != nil {
+			return nil, err
+		}
+		if err := tx.Where("id IN ?", tokenIds).Find(&tokens).Error; err != nil {
+			return nil, err
+		}
+	}
+	err := tx.Model(&account).Updates(map[string]interface{}{
+		"status":              PostpaidAccountStatusActive,
+		"suspended_token_ids": "",
+		"suspended_at":        0,
+		"updated_at":          common.GetTimestamp(),
+	}).Error
+	return tokens, err
+}
+
+// RecordPostpaidPayment 为账单登记一笔线下付款：按金额折算额度为账户钱包入账，
+// 付清后账单标记为已结清，账户没有其他逾期账单时恢复被暂停的令牌。
+func RecordPostpaidPayment(statementId int, payment *PostpaidPayment) (*PostpaidStatement, error) {
+	if payment.Amount <= 0 {
+		return nil, errors.New("付款金额必须大于 0")
+	}
+	var statement PostpaidStatement
+	var resumed []Token
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := lockForUpdate(tx).First(&statement, statementId).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrPostpaidStatementNotFound
+			}
+			return err
+		}
+		if statement.Status == PostpaidStatementStatusPaid {
+			return ErrPostpaidStatementPaid
+		}
+		now := common.GetTimestamp()
+		payment.Id = 0
+		payment.StatementId = statement.Id
+		payment.Quota = int(decimal.NewFromFloat(payment.Amount).Mul(decimal.NewFromFloat(common.QuotaPerUnit)).IntPart())
+		payment.CreatedAt = now
+		if err := tx.Create(payment).Error; err != nil {
+			return err
+		}
+		if err := creditPostpaidOwnerTx(tx, &statement, payment); err != nil {
+			return err
+		}
+
+		statement.PaidAmount = decimal.NewFromFloat(statement.PaidAmount).Add(decimal.NewFromFloat(payment.Amount)).Round(2).InexactFloat64()
+		updates := map[string]interface{}{"paid_amount": statement.PaidAmount}
+		if statement.PaidAmount >= statement.Amount {
+			statement.Status = PostpaidStatementStatusPaid
+			statement.PaidAt = now
+			updates["status"] = statement.Status
+			updates["paid_at"] = now
+		}
+		if err := tx.Model(&PostpaidStatement{}).Where("id = ?", statement.Id).Updates(updates).Error; err != nil {
+			return err
+		}
+		if statement.Status != PostpaidStatementStatusPaid {
+			return nil
+		}
+		var err error
+		resumed, err = resumePostpaidAccountTx(tx, statement.AccountId)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if statement.OwnerType == BillingProfileOwnerUser {
+		if err := cacheIncrUserQuota(statement.OwnerId, int64(payment.Quota)); err != nil {
+			common.SysLog("failed to increase user quota cache: " + err.Error())
+		}
+	}
+	if err := invalidateTokensCache(resumed); err != nil {
+		common.SysLog("failed to invalidate resumed token cache: " + err.Error())
+	}
+	return &statement, nil
+}
+
+func creditPostpaidOwnerTx(tx *gorm.DB, statement *PostpaidStatement, payment *PostpaidPayment) error {
+	if payment.Quota <= 0 {
+		return nil
+	}
+	refId := strconv.Itoa(statement.Id)
+	if statement.OwnerType == BillingProfileOwnerOrganization {
+		result := tx.Model(&Organization{}).Where("id = ?", statement.OwnerId).Updates(map[string]interface{}{
+			"quota":      gorm.Expr("quota + ?", payment.Quota),
+			"updated_at": common.GetTimestamp(),
+		})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrOrganizationNotFound
+		}
+		return PostQuotaLedgerTx(tx, QuotaLedgerPosting{
+			Account:    QuotaLedgerOrganizationAccount(statement.OwnerId),
+			Amount:     int64(payment.Quota),
+			Reason:     QuotaLedgerReasonPostpaidPayment,
+			RefType:    QuotaLedgerRefPostpaidStatement,
+			RefId:      refId,
+			OperatorId: payment.OperatorId,
+		})
+	}
+	result := tx.Model(&User{}).Where("id = ?", statement.OwnerId).Update("quota", gorm.Expr("quota + ?", payment.Quota))
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("用户不存在")
+	}
+	posting := UserQuotaLedgerPosting(statement.OwnerId, payment.Quota, QuotaLedgerReasonPostpaidPayment, QuotaLedgerRefPostpaidStatement, refId)
+	posting.OperatorId = payment.OperatorId
+	return PostQuotaLedgerTx(tx, posting)
+}
+
+func joinPostpaidTokenIds(ids []int) string {
+	parts := make([]string, 0, len(ids))
+	for _, id := range ids {
+		parts = append(parts, strconv.Itoa(id))
+	}
+	return strings.Join(parts, ",")
+}
+
+func splitPostpaidTokenIds(raw string) []int {
+	var ids []int
+	for _, part := range strings.Split(raw, ",") {
+		if id, err := strconv.Atoi(strings.TrimSpace(part)); err == nil && id > 0 {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
@@ -0,0 +1,365 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 账本记录原因
+const (
+	QuotaLedgerReasonOpeningBalance       = "opening_balance"
+	QuotaLedgerReasonTopup                = "topup"
+	QuotaLedgerReasonRedemption           = "redemption"
+	QuotaLedgerReasonCheckin              = "checkin"
+	QuotaLedgerReasonAffTransfer          = "aff_transfer"
+	QuotaLedgerReasonSignupBonus          = "signup_bonus"
+	QuotaLedgerReasonInviteBonus          = "invite_bonus"
+	QuotaLedgerReasonAdminAdjust          = "admin_adjust"
+	QuotaLedgerReasonConsume              = "consume"
+	QuotaLedgerReasonRefund               = "refund"
+	QuotaLedgerReasonSubscriptionPurchase = "subscription_purchase"
+	QuotaLedgerReasonSubscriptionConsume  = "subscription_consume"
+	QuotaLedgerReasonSubscriptionRefund   = "subscription_refund"
+	QuotaLedgerReasonSubscriptionReset    = "subscription_reset"
+	QuotaLedgerReasonOrganizationFund     = "organization_fund"
+	QuotaLedgerReasonOrganizationAdjust   = "organization_adjust"
+	QuotaLedgerReasonOrganizationConsume  = "organization_consume"
+	QuotaLedgerReasonOrganizationRefund   = "organization_refund"
+	QuotaLedgerReasonCouponBonus          = "coupon_bonus"
+	QuotaLedgerReasonPostpaidPayment      = "postpaid_payment"
+	QuotaLedgerReasonCreditExpiry         = "credit_expiry"
+	QuotaLedgerReasonTopupRefund          = "topup_refund"
+	QuotaLedgerReasonMediaStorage         = "media_storage"
+)
+
+// 账本来源引用类型
+const (
+	QuotaLedgerRefTradeNo           = "trade_no"
+	QuotaLedgerRefRequestId         = "request_id"
+	QuotaLedgerRefTaskId            = "task_id"
+	QuotaLedgerRefRedemptionId      = "redemption_id"
+	QuotaLedgerRefCheckinId         = "checkin_id"
+	QuotaLedgerRefUserSubscription  = "user_subscription_id"
+	QuotaLedgerRefOrganizationId    = "organization_id"
+	QuotaLedgerRefPostpaidStatement = "postpaid_statement_id"
+	QuotaLedgerRefQuotaLotId        = "quota_lot_id"
+	QuotaLedgerRefPaymentRefund     = "payment_refund_id"
+	QuotaLedgerRefMediaObjectId     = "media_object_id"
+)
+
+const quotaLedgerSystemAccountPrefix = "system:"
+
+// QuotaLedgerEntry 是不可变的账本分录。每笔记账写入两条分录：Account 记 Amount，
+// 对方账户记 -Amount，同一 TxnId 下的分录之和恒为 0。
+// BalanceAfter 为记账后该账户的余额；system:* 对方账户不维护余额，恒为 0。
+type QuotaLedgerEntry struct {
+	Id           int64  `json:"id"`
+	TxnId        string `json:"txn_id" gorm:"type:varchar(64);index"`
+	Account      string `json:"account" gorm:"type:varchar(64);index"`
+	UserId       int    `json:"user_id" gorm:"index"`
+	Amount       int64  `json:"amount"`
+	BalanceAfter int64  `json:"balance_after"`
+	Reason       string `json:"reason" gorm:"type:varchar(32);index"`
+	RefType      string `json:"ref_type" gorm:"type:varchar(32)"`
+	RefId        string `json:"ref_id" gorm:"type:varchar(128);index"`
+	OperatorId   int    `json:"operator_id"`
+	CreatedAt    int64  `json:"created_at" gorm:"bigint;index"`
+}
+
+// QuotaLedgerAccount 保存账户的当前账本余额，用于生成 BalanceAfter 和对账。
+type QuotaLedgerAccount struct {
+	Account   string `json:"account" gorm:"primaryKey;type:varchar(64)"`
+	UserId    int    `json:"user_id" gorm:"index"`
+	Balance   int64  `json:"balance"`
+	CreatedAt int64  `json:"created_at" gorm:"bigint"`
+	UpdatedAt int64  `json:"updated_at" gorm:"bigint"`
+}
+
+// QuotaLedgerPosting 描述一笔记账。Amount 为记入 Account 的金额（正为入账，负为出账），
+// CounterAccount 为空时使用 system:<reason>。
+type QuotaLedgerPosting struct {
+	Account        string
+	UserId         int
+	CounterAccount string
+	Amount         int64
+	Reason         string
+	RefType        string
+	RefId          string
+	OperatorId     int
+}
+
+func QuotaLedgerUserAccount(userId int) string {
+	return fmt.Sprintf("user:%d", userId)
+}
+
+func QuotaLedgerSubscriptionAccount(userSubscriptionId int) string {
+	return fmt.Sprintf("subscription:%d", userSubscriptionId)
+}
+
+func QuotaLedgerOrganizationAccount(orgId int) string {
+	return fmt.Sprintf("organization:%d", orgId)
+}
+
+// UserQuotaLedgerPosting 构造记入用户钱包的记账。
+func UserQuotaLedgerPosting(userId int, amount int, reason string, refType string, refId string) QuotaLedgerPosting {
+	return QuotaLedgerPosting{
+		Account: QuotaLedgerUserAccount(userId),
+		UserId:  userId,
+		Amount:  int64(amount),
+		Reason:  reason,
+		RefType: refType,
+		RefId:   refId,
+	}
+}
+
+// UserConsumeQuotaLedgerPosting 构造请求或任务计费产生的钱包记账，amount 为负数时是扣费，正数是退还。
+func UserConsumeQuotaLedgerPosting(userId int, amount int, refType string, refId string) QuotaLedgerPosting {
+	reason := QuotaLedgerReasonConsume
+	if amount > 0 {
+		reason = QuotaLedgerReasonRefund
+	}
+	return UserQuotaLedgerPosting(userId, amount, reason, refType, refId)
+}
+
+func isQuotaLedgerSystemAccount(account string) bool {
+	return strings.HasPrefix(account, quotaLedgerSystemAccountPrefix)
+}
+
+// PostQuotaLedgerTx 在调用方事务中记账。必须在业务表余额（users.quota、订阅已用额度、
+// 组织钱包）变更之后调用，账户首次记账时据此推算期初余额。
+// 记账在保存点中执行：失败时只回滚账本写入并记录日志，不会让充值、兑换等业务事务失败，
+// 漏记会在对账任务中以差额的形式暴露出来。
+func PostQuotaLedgerTx(tx *gorm.DB, posting QuotaLedgerPosting) error {
+	if !operation_setting.GetQuotaLedgerSetting().Enabled || posting.Amount == 0 {
+		return nil
+	}
+	err := tx.Transaction(func(ledgerTx *gorm.DB) error {
+		return postQuotaLedgerTx(ledgerTx, posting)
+	})
+	if err != nil {
+		logQuotaLedgerFailure(posting, err)
+	}
+	return nil
+}
+
+func postQuotaLedgerTx(tx *gorm.DB, posting QuotaLedgerPosting) error {
+	if posting.Account == "" || posting.Reason == "" {
+		return errors.New("quota ledger posting requires account and reason")
+	}
+	if posting.CounterAccount == "" {
+		posting.CounterAccount = quotaLedgerSystemAccountPrefix + posting.Reason
+	}
+	now := common.GetTimestamp()
+	balance, err := applyQuotaLedgerAccountDelta(tx, posting.Account, posting.UserId, posting.Amount, now)
+	if err != nil {
+		return err
+	}
+	counterBalance, err := applyQuotaLedgerAccountDelta(tx, posting.CounterAccount, 0, -posting.Amount, now)
+	if err != nil {
+		return err
+	}
+	txnId := common.GetUUID()
+	entries := []QuotaLedgerEntry{
+		{
+			TxnId:        txnId,
+			Account:      posting.Account,
+			UserId:       posting.UserId,
+			Amount:       posting.Amount,
+			BalanceAfter: balance,
+			Reason:       posting.Reason,
+			RefType:      posting.RefType,
+			RefId:        posting.RefId,
+			OperatorId:   posting.OperatorId,
+			CreatedAt:    now,
+		},
+		{
+			TxnId:        txnId,
+			Account:      posting.CounterAccount,
+			Amount:       -posting.Amount,
+			BalanceAfter: counterBalance,
+			Reason:       posting.Reason,
+			RefType:      posting.RefType,
+			RefId:        posting.RefId,
+			OperatorId:   posting.OperatorId,
+			CreatedAt:    now,
+		},
+	}
+	return tx.Create(&entries).Error
+}
+
+// RecordQuotaLedger 在独立事务中记账，失败只记录日志，不影响调用方的业务流程；
+// 漏记会在对账任务中以差额的形式暴露出来。
+func RecordQuotaLedger(posting QuotaLedgerPosting) {
+	if !operation_setting.GetQuotaLedgerSetting().Enabled || posting.Amount == 0 {
+		return
+	}
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		return postQuotaLedgerTx(tx, posting)
+	})
+	if err != nil {
+		logQuotaLedgerFailure(posting, err)
+	}
+}
+
+func logQuotaLedgerFailure(posting QuotaLedgerPosting, err error) {
+	common.SysError(fmt.Sprintf("failed to record quota ledger (account=%s, amount=%d, reason=%s, ref=%s:%s): %s",
+		posting.Account, posting.Amount, posting.Reason, posting.RefType, posting.RefId, err.Error()))
+}
+
+// applyQuotaLedgerAccountDelta 原子地调整账户余额并返回调整后的余额。
+// system:* 账户是所有请求共用的对方账户，为避免热点行不维护余额。
+func applyQuotaLedgerAccountDelta(tx *gorm.DB, account string, userId int, delta int64, now int64) (int64, error) {
+	if isQuotaLedgerSystemAccount(account) {
+		return 0, nil
+	}
+	for attempt := 0; attempt < 2; attempt++ {
+		result := tx.Model(&QuotaLedgerAccount{}).Where("account = ?", account).Updates(map[string]interface{}{
+			"balance":    gorm.Expr("balance + ?", delta),
+			"updated_at": now,
+		})
+		if result.Error != nil {
+			return 0, result.Error
+		}
+		if result.RowsAffected > 0 {
+			var stored QuotaLedgerAccount
+			if err := tx.Select("balance").Where("account = ?", account).First(&stored).Error; err != nil {
+				return 0, err
+			}
+			return stored.Balance, nil
+		}
+		if attempt > 0 {
+			break
+		}
+		// 账户首次出现：按对应业务表的当前余额（已包含本次变更）倒推期初余额
+		opening, err := quotaLedgerSourceBalance(tx, account)
+		if err != nil {
+			return 0, err
+		}
+		opening -= delta
+		created, err := openQuotaLedgerAccount(tx, account, userId, opening, now)
+		if err != nil {
+			return 0, err
+		}
+		if created {
+			continue
+		}
+	}
+	return 0, fmt.Errorf("quota ledger account %s could not be updated", account)
+}
+
+// openQuotaLedgerAccount 创建账户并写入期初余额分录。账户已被并发请求创建时不写期初分录，
+// 两种情况都返回 true，调用方重新执行余额调整即可。
+func openQuotaLedgerAccount(tx *gorm.DB, account string, userId int, opening int64, now int64) (bool, error) {
+	result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&QuotaLedgerAccount{
+		Account:   account,
+		UserId:    userId,
+		Balance:   opening,
+		CreatedAt: now,
+		UpdatedAt: now,
+	})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	if result.RowsAffected == 0 || opening == 0 {
+		return true, nil
+	}
+	txnId := common.GetUUID()
+	entries := []QuotaLedgerEntry{
+		{TxnId: txnId, Account: account, UserId: userId, Amount: opening, BalanceAfter: opening, Reason: QuotaLedgerReasonOpeningBalance, CreatedAt: now},
+		{TxnId: txnId, Account: quotaLedgerSystemAccountPrefix + QuotaLedgerReasonOpeningBalance, Amount: -opening, Reason: QuotaLedgerReasonOpeningBalance, CreatedAt: now},
+	}
+	return true, tx.Create(&entries).Error
+}
+
+// quotaLedgerSourceBalance 返回账户对应业务表中的实际余额：用户钱包为 users.quota 加上本节点
+// 尚未落库的批量更新（其他节点缓冲的差额不可见，多节点开启批量更新时期初余额可能有偏差），
+// 订阅为剩余额度（不限量时为负的已用额度），组织为组织钱包余额，其余账户为 0。
+func quotaLedgerSourceBalance(tx *gorm.DB, account string) (int64, error) {
+	kind, idStr, ok := strings.Cut(account, ":")
+	if !ok {
+		return 0, nil
+	}
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		return 0, nil
+	}
+	switch kind {
+	case "user":
+		var user User
+		if err := tx.Unscoped().Select("id", "quota").Where("id = ?", id).First(&user).Error; err != nil {
+			return 0, err
+		}
+		return int64(user.Quota) + int64(pendingBatchUserQuota(id)), nil
+	case "subscription":
+		var sub UserSubscription
+		if err := tx.Select("id", "amount_total", "amount_used").Where("id = ?", id).First(&sub).Error; err != nil {
+			return 0, err
+		}
+		return sub.AmountTotal - sub.AmountUsed, nil
+	case "organization":
+		var org Organization
+		if err := tx.Select("id", "quota").Where("id = ?", id).First(&org).Error; err != nil {
+			return 0, err
+		}
+		return int64(org.Quota), nil
+	}
+	return 0, nil
+}
+
+func pendingBatchUserQuota(userId int) int {
+	batchUpdateLocks[BatchUpdateTypeUserQuota].Lock()
+	defer batchUpdateLocks[BatchUpdateTypeUserQuota].Unlock()
+	return batchUpdateStores[BatchUpdateTypeUserQuota][userId]
+}
+
+type QuotaLedgerQuery struct {
+	UserId         int
+	Account        string
+	Reason         string
+	RefId          string
+	StartTimestamp int64
+	EndTimestamp   int64
+}
+
+func GetQuotaLedgerEntries(query QuotaLedgerQuery, startIdx int, num int) (entries []*QuotaLedgerEntry, total int64, err error) {
+	tx := DB.Model(&QuotaLedgerEntry{})
+	if query.UserId != 0 {
+		tx = tx.Where("user_id = ?", query.UserId)
+	}
+	if query.Account != "" {
+		tx = tx.Where("account = ?", query.Account)
+	}
+	if query.Reason != "" {
+		tx = tx.Where("reason = ?", query.Reason)
+	}
+	if query.RefId != "" {
+		tx = tx.Where("ref_id = ?", query.RefId)
+	}
+	if query.StartTimestamp != 0 {
+		tx = tx.Where("created_at >= ?", query.StartTimestamp)
+	}
+	if query.EndTimestamp != 0 {
+		tx = tx.Where("created_at <= ?", query.EndTimestamp)
+	}
+	if err = tx.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err = tx.Order("id desc").Limit(num).Offset(startIdx).Find(&entries).Error
+	return entries, total, err
+}
+
+func GetQuotaLedgerAccount(account string) (*QuotaLedgerAccount, error) {
+	var stored QuotaLedgerAccount
+	if err := DB.Where("account = ?", account).First(&stored).Error; err != nil {
+		return nil, err
+	}
+	return &stored, nil
+}
@@ -0,0 +1,147 @@
+package model
+
+import (
+	"errors"
+
+	"github.com/QuantumNous/new-api/common"
+
+	"gorm.io/gorm"
+)
+
+// QuotaLedgerDrift 记录 users.quota 与账本余额不一致的情况。同一用户同时最多有一条未解决记录，
+// 连续多次对账仍存在差额时才会通知，以排除对账瞬间正在进行的扣费造成的误报。
+type QuotaLedgerDrift struct {
+	Id            int   `json:"id"`
+	UserId        int   `json:"user_id" gorm:"index"`
+	UserQuota     int64 `json:"user_quota"`
+	LedgerBalance int64 `json:"ledger_balance"`
+	Drift         int64 `json:"drift"` // users.quota - 账本余额
+	Consecutive   int   `json:"consecutive"`
+	Notified      bool  `json:"notified"`
+	Resolved      bool  `json:"resolved" gorm:"index"`
+	FirstSeenAt   int64 `json:"first_seen_at" gorm:"bigint"`
+	LastSeenAt    int64 `json:"last_seen_at" gorm:"bigint"`
+	ResolvedAt    int64 `json:"resolved_at" gorm:"bigint"`
+}
+
+const QuotaLedgerDriftNotifyThreshold = 2
+
+type QuotaLedgerReconcileResult struct {
+	Checked  int
+	Opened   int
+	Drifted  int
+	Resolved int
+	// ToNotify 为本次首次达到通知阈值的差额记录
+	ToNotify []*QuotaLedgerDrift
+}
+
+// ReconcileQuotaLedgerBatch 对 id > afterId 的最多 limit 个用户对账，返回本批最后一个用户 id；
+// 返回 0 表示已经遍历完。没有账本账户的用户会以当前余额开立期初余额。
+// 只比较已落库的 users.quota，调用方需确保未开启批量更新。
+func ReconcileQuotaLedgerBatch(afterId int, limit int, tolerance int64, result *QuotaLedgerReconcileResult) (int, error) {
+	var users []User
+	if err := DB.Unscoped().Select("id", "quota").Where("id > ?", afterId).Order("id asc").Limit(limit).Find(&users).Error; err != nil {
+		return 0, err
+	}
+	if len(users) == 0 {
+		return 0, nil
+	}
+	accountNames := make([]string, 0, len(users))
+	for _, user := range users {
+		accountNames = append(accountNames, QuotaLedgerUserAccount(user.Id))
+	}
+	var accounts []QuotaLedgerAccount
+	if err := DB.Where("account IN ?", accountNames).Find(&accounts).Error; err != nil {
+		return 0, err
+	}
+	balances := make(map[string]int64, len(accounts))
+	for _, account := range accounts {
+		balances[account.Account] = account.Balance
+	}
+
+	now := common.GetTimestamp()
+	for _, user := range users {
+		result.Checked++
+		userQuota := int64(user.Quota)
+		balance, ok := balances[QuotaLedgerUserAccount(user.Id)]
+		if !ok {
+			err := DB.Transaction(func(tx *gorm.DB) error {
+				_, err := openQuotaLedgerAccount(tx, QuotaLedgerUserAccount(user.Id), user.Id, userQuota, now)
+				return err
+			})
+			if err != nil {
+				return 0, err
+			}
+			result.Opened++
+			continue
+		}
+		drift := userQuota - balance
+		if drift <= tolerance && drift >= -tolerance {
+			resolved, err := resolveQuotaLedgerDrift(user.Id, now)
+			if err != nil {
+				return 0, err
+			}
+			result.Resolved += resolved
+			continue
+		}
+		result.Drifted++
+		record, err := upsertQuotaLedgerDrift(user.Id, userQuota, balance, drift, now)
+		if err != nil {
+			return 0, err
+		}
+		if record != nil {
+			result.ToNotify = append(result.ToNotify, record)
+		}
+	}
+	return users[len(users)-1].Id, nil
+}
+
+// upsertQuotaLedgerDrift 更新用户的未解决差额记录；首次达到通知阈值时返回该记录。
+func upsertQuotaLedgerDrift(userId int, userQuota int64, balance int64, drift int64, now int64) (*QuotaLedgerDrift, error) {
+	var record QuotaLedgerDrift
+	err := DB.Where("user_id = ? AND resolved = ?", userId, false).Order("id desc").First(&record).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		record = QuotaLedgerDrift{
+			UserId:      userId,
+			FirstSeenAt: now,
+		}
+	}
+	record.UserQuota = userQuota
+	record.LedgerBalance = balance
+	record.Drift = drift
+	record.Consecutive++
+	record.LastSeenAt = now
+	notify := !record.Notified && record.Consecutive >= QuotaLedgerDriftNotifyThreshold
+	if notify {
+		record.Notified = true
+	}
+	if err := DB.Save(&record).Error; err != nil {
+		return nil, err
+	}
+	if notify {
+		return &record, nil
+	}
+	return nil, nil
+}
+
+func resolveQuotaLedgerDrift(userId int, now int64) (int, error) {
+	result := DB.Model(&QuotaLedgerDrift{}).
+		Where("user_id = ? AND resolved = ?", userId, false).
+		Updates(map[string]interface{}{
+			"resolved":    true,
+			"resolved_at": now,
+		})
+	return int(result.RowsAffected), result.Error
+}
+
+func GetQuotaLedgerDrifts(resolved bool, startIdx int, num int) (drifts []*QuotaLedgerDrift, total int64, err error) {
+	tx := DB.Model(&QuotaLedgerDrift{}).Where("resolved = ?", resolved)
+	if err = tx.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err = tx.Order("id desc").Limit(num).Offset(startIdx).Find(&drifts).Error
+	return drifts, total, err
+}
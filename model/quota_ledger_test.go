@@ -0,0 +1,176 @@
+package model
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func setupQuotaLedger(t *testing.T) {
+	t.Helper()
+	require.NoError(t, DB.AutoMigrate(&User{}, &QuotaLedgerEntry{}, &QuotaLedgerAccount{}, &QuotaLedgerDrift{}))
+	t.Cleanup(func() {
+		DB.Exec("DELETE FROM users")
+		DB.Exec("DELETE FROM quota_ledger_entries")
+		DB.Exec("DELETE FROM quota_ledger_accounts")
+		DB.Exec("DELETE FROM quota_ledger_drifts")
+	})
+}
+
+func getLedgerBalanceForTest(t *testing.T, account string) int64 {
+	t.Helper()
+	stored, err := GetQuotaLedgerAccount(account)
+	require.NoError(t, err)
+	return stored.Balance
+}
+
+func TestPostQuotaLedgerInfersOpeningBalanceAndBalancesEntries(t *testing.T) {
+	setupQuotaLedger(t)
+	user := &User{Username: "ledger-user", AffCode: "ledger-user", Quota: 1000}
+	require.NoError(t, DB.Create(user).Error)
+
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&User{}).Where("id = ?", user.Id).Update("quota", gorm.Expr("quota + ?", 200)).Error; err != nil {
+			return err
+		}
+		return PostQuotaLedgerTx(tx, UserQuotaLedgerPosting(user.Id, 200, QuotaLedgerReasonTopup, QuotaLedgerRefTradeNo, "trade-1"))
+	})
+	require.NoError(t, err)
+
+	entries, total, err := GetQuotaLedgerEntries(QuotaLedgerQuery{Account: QuotaLedgerUserAccount(user.Id)}, 0, 10)
+	require.NoError(t, err)
+	require.EqualValues(t, 2, total)
+	// 按 id 倒序：先是本次充值，再是推算出的期初余额
+	require.Equal(t, QuotaLedgerReasonTopup, entries[0].Reason)
+	require.EqualValues(t, 200, entries[0].Amount)
+	require.EqualValues(t, 1200, entries[0].BalanceAfter)
+	require.Equal(t, "trade-1", entries[0].RefId)
+	require.Equal(t, QuotaLedgerReasonOpeningBalance, entries[1].Reason)
+	require.EqualValues(t, 1000, entries[1].BalanceAfter)
+	require.EqualValues(t, 1200, getLedgerBalanceForTest(t, QuotaLedgerUserAccount(user.Id)))
+
+	var sums []struct {
+		TxnId string
+		Total int64
+	}
+	require.NoError(t, DB.Model(&QuotaLedgerEntry{}).Select("txn_id, sum(amount) AS total").Group("txn_id").Scan(&sums).Error)
+	require.Len(t, sums, 2)
+	for _, sum := range sums {
+		require.Zero(t, sum.Total, "txn %s is unbalanced", sum.TxnId)
+	}
+}
+
+func TestPostQuotaLedgerFailureDoesNotRollBackBusinessTransaction(t *testing.T) {
+	setupQuotaLedger(t)
+	user := &User{Username: "ledger-isolated", AffCode: "ledger-isolated", Quota: 100}
+	require.NoError(t, DB.Create(user).Error)
+
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&User{}).Where("id = ?", user.Id).Update("quota", gorm.Expr("quota + ?", 50)).Error; err != nil {
+			return err
+		}
+		// 缺少 reason 的记账会失败，但充值本身必须提交
+		return PostQuotaLedgerTx(tx, QuotaLedgerPosting{Account: QuotaLedgerUserAccount(user.Id), UserId: user.Id, Amount: 50})
+	})
+	require.NoError(t, err)
+	var stored User
+	require.NoError(t, DB.First(&stored, user.Id).Error)
+	require.Equal(t, 150, stored.Quota)
+	_, total, err := GetQuotaLedgerEntries(QuotaLedgerQuery{UserId: user.Id}, 0, 10)
+	require.NoError(t, err)
+	require.Zero(t, total)
+}
+
+func TestOpenQuotaLedgerAccountIgnoresExistingAccount(t *testing.T) {
+	setupQuotaLedger(t)
+	account := QuotaLedgerUserAccount(42)
+	created, err := openQuotaLedgerAccount(DB, account, 42, 300, 1)
+	require.NoError(t, err)
+	require.True(t, created)
+	// 并发请求已开户时不能报主键冲突，也不能重复写期初余额
+	created, err = openQuotaLedgerAccount(DB, account, 42, 999, 2)
+	require.NoError(t, err)
+	require.True(t, created)
+	require.EqualValues(t, 300, getLedgerBalanceForTest(t, account))
+	_, total, err := GetQuotaLedgerEntries(QuotaLedgerQuery{Account: account}, 0, 10)
+	require.NoError(t, err)
+	require.EqualValues(t, 1, total)
+}
+
+func TestReconcileQuotaLedgerFlagsPersistentDrift(t *testing.T) {
+	setupQuotaLedger(t)
+	user := &User{Username: "ledger-drift", AffCode: "ledger-drift", Quota: 500}
+	require.NoError(t, DB.Create(user).Error)
+
+	reconcile := func() *QuotaLedgerReconcileResult {
+		result := &QuotaLedgerReconcileResult{}
+		afterId := 0
+		for {
+			nextId, err := ReconcileQuotaLedgerBatch(afterId, 100, 0, result)
+			require.NoError(t, err)
+			if nextId == 0 {
+				return result
+			}
+			afterId = nextId
+		}
+	}
+
+	result := reconcile()
+	require.Equal(t, 1, result.Opened)
+	require.EqualValues(t, 500, getLedgerBalanceForTest(t, QuotaLedgerUserAccount(user.Id)))
+
+	// 绕过账本直接修改余额
+	require.NoError(t, DB.Model(&User{}).Where("id = ?", user.Id).Update("quota", 550).Error)
+	result = reconcile()
+	require.Equal(t, 1, result.Drifted)
+	require.Empty(t, result.ToNotify)
+
+	result = reconcile()
+	require.Len(t, result.ToNotify, 1)
+	require.EqualValues(t, 50, result.ToNotify[0].Drift)
+	result = reconcile()
+	require.Empty(t, result.ToNotify, "drift is only notified once")
+
+	RecordQuotaLedger(UserQuotaLedgerPosting(user.Id, 50, QuotaLedgerReasonAdminAdjust, "", ""))
+	result = reconcile()
+	require.Equal(t, 1, result.Resolved)
+	drifts, total, err := GetQuotaLedgerDrifts(false, 0, 10)
+	require.NoError(t, err)
+	require.Zero(t, total)
+	require.Empty(t, drifts)
+}
+
+func TestSettleUserWalletQuotaRevertsBatchedDebitWhenCommitFails(t *testing.T) {
+	setupQuotaLedger(t)
+	oldBatchUpdateEnabled := common.BatchUpdateEnabled
+	common.BatchUpdateEnabled = true
+	t.Cleanup(func() { common.BatchUpdateEnabled = oldBatchUpdateEnabled })
+	user := &User{Username: "ledger-batched", AffCode: "ledger-batched", Quota: 1000}
+	require.NoError(t, DB.Create(user).Error)
+	t.Cleanup(func() {
+		batchUpdateLocks[BatchUpdateTypeUserQuota].Lock()
+		delete(batchUpdateStores[BatchUpdateTypeUserQuota], user.Id)
+		batchUpdateLocks[BatchUpdateTypeUserQuota].Unlock()
+	})
+
+	// 在记账时结束底层事务，使记账保存点之后的提交失败
+	const callbackName = "test:quota-ledger-commit-failure"
+	require.NoError(t, DB.Callback().Create().Before("gorm:create").Register(callbackName, func(tx *gorm.DB) {
+		if tx.Statement.Table != "quota_ledger_entries" {
+			return
+		}
+		if _, inTransaction := tx.Statement.ConnPool.(gorm.TxCommitter); inTransaction {
+			_, _ = tx.Statement.ConnPool.ExecContext(tx.Statement.Context, "ROLLBACK")
+			tx.AddError(errors.New("forced quota ledger failure"))
+		}
+	}))
+	t.Cleanup(func() { _ = DB.Callback().Create().Remove(callbackName) })
+
+	err := SettleUserWalletQuota(user.Id, 300, 0, QuotaLedgerRefRequestId, "req-commit-failure")
+	require.Error(t, err)
+	// 结算失败时批量队列中不能留下扣费，否则调用方不退款也会扣掉用户余额
+	require.Zero(t, pendingBatchUserQuota(user.Id))
+}
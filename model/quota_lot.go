@@ -0,0 +1,367 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+
+	"github.com/bytedance/gopkg/util/gopool"
+	"gorm.io/gorm"
+)
+
+const (
+	QuotaLotSourceCheckin     = "checkin"
+	QuotaLotSourceRedemption  = "redemption"
+	QuotaLotSourceTopup       = "topup"
+	QuotaLotSourceCouponBonus = "coupon_bonus"
+
+	QuotaLotStatusActive  = "active"
+	QuotaLotStatusExpired = "expired"
+)
+
+// QuotaLot 是一笔带有效期的入账额度。额度本身仍记在 users.quota 中，批次只记录
+// 其中有多少会在何时过期：结算时按到期时间从早到晚扣减 Remaining，到期后由定时任务
+// 从用户余额中扣除剩余部分。没有批次覆盖的余额永不过期。
+type QuotaLot struct {
+	Id            int    `json:"id"`
+	UserId        int    `json:"user_id" gorm:"index:idx_quota_lot_user_status,priority:1"`
+	Source        string `json:"source" gorm:"type:varchar(32)"`
+	SourceRef     string `json:"source_ref" gorm:"type:varchar(128)"`
+	Amount        int64  `json:"amount"`
+	Remaining     int64  `json:"remaining"`
+	ExpiredAmount int64  `json:"expired_amount"`
+	Status        string `json:"status" gorm:"type:varchar(16);index:idx_quota_lot_user_status,priority:2"`
+	ExpiresAt     int64  `json:"expires_at" gorm:"bigint;index"`
+	CreatedAt     int64  `json:"created_at" gorm:"bigint"`
+}
+
+// QuotaLotValidDays 返回某来源入账额度的有效期（天），0 表示不生成批次
+func QuotaLotValidDays(source string) int {
+	setting := operation_setting.GetCreditExpirySetting()
+	if !setting.Enabled {
+		return 0
+	}
+	switch source {
+	case QuotaLotSourceCheckin:
+		return setting.CheckinValidDays
+	case QuotaLotSourceTopup:
+		return setting.TopupValidDays
+	case QuotaLotSourceCouponBonus:
+		return setting.CouponBonusValidDays
+	}
+	return 0
+}
+
+// GrantQuotaLotTx 在入账额度的同一事务中登记有效期批次。validDays <= 0 或功能未开启时不做任何事
+func GrantQuotaLotTx(tx *gorm.DB, userId int, source string, sourceRef string, amount int, validDays int) error {
+	if amount <= 0 || validDays <= 0 || !operation_setting.GetCreditExpirySetting().Enabled {
+		return nil
+	}
+	now := common.GetTimestamp()
+	lot := &QuotaLot{
+		UserId:    userId,
+		Source:    source,
+		SourceRef: sourceRef,
+		Amount:    int64(amount),
+		Remaining: int64(amount),
+		Status:    QuotaLotStatusActive,
+		ExpiresAt: now + int64(validDays)*86400,
+		CreatedAt: now,
+	}
+	return tx.Create(lot).Error
+}
+
+// GrantQuotaLot 供不在事务中入账的调用方使用，失败只记录日志
+func GrantQuotaLot(userId int, source string, sourceRef string, amount int, validDays int) {
+	if err := GrantQuotaLotTx(DB, userId, source, sourceRef, amount, validDays); err != nil {
+		common.SysLog(fmt.Sprintf("failed to grant quota lot (userId=%d, source=%s, ref=%s): %s", userId, source, sourceRef, err.Error()))
+	}
+}
+
+// ConsumeQuotaLots 按到期时间从早到晚扣减用户未过期批次的剩余额度，返回实际扣减的总量
+func ConsumeQuotaLots(userId int, amount int64) (int64, error) {
+	if userId <= 0 || amount <= 0 {
+		return 0, nil
+	}
+	var consumed int64
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		consumed, err = consumeQuotaLotsTx(tx, userId, amount)
+		return err
+	})
+	return consumed, err
+}
+
+func consumeQuotaLotsTx(tx *gorm.DB, userId int, amount int64) (int64, error) {
+	var lots []QuotaLot
+	if err := lockForUpdate(tx).
+		Where("user_id = ? AND status = ? AND remaining > 0 AND expires_at > ?", userId, QuotaLotStatusActive, common.GetTimestamp()).
+		Order("expires_at asc, id asc").
+		Find(&lots).Error; err != nil {
+		return 0, err
+	}
+	var consumed int64
+	for _, lot := range lots {
+		if consumed >= amount {
+			break
+		}
+		take := min(lot.Remaining, amount-consumed)
+		if err := tx.Model(&QuotaLot{}).Where("id = ?", lot.Id).
+			Update("remaining", gorm.Expr("remaining - ?", take)).Error; err != nil {
+			return 0, err
+		}
+		consumed += take
+	}
+	return consumed, nil
+}
+
+// consumeUserQuotaLotsTx 在扣减用户余额的同一事务中按到期顺序消耗批次，避免之后批次到期时扣走永久余额。
+// 功能未开启时不做任何事
+func consumeUserQuotaLotsTx(tx *gorm.DB, userId int, amount int64) error {
+	if amount <= 0 || !operation_setting.GetCreditExpirySetting().Enabled {
+		return nil
+	}
+	_, err := consumeQuotaLotsTx(tx, userId, amount)
+	return err
+}
+
+// RestoreQuotaLots 把退还的额度放回已被消耗、仍未过期的批次，优先放回最晚到期的批次，
+// 避免退款把即将过期的额度变成永久额度。放不下的部分留作永不过期的余额
+func RestoreQuotaLots(userId int, amount int64) (int64, error) {
+	if userId <= 0 || amount <= 0 {
+		return 0, nil
+	}
+	var restored int64
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		restored, err = restoreQuotaLotsTx(tx, userId, amount)
+		return err
+	})
+	return restored, err
+}
+
+func restoreQuotaLotsTx(tx *gorm.DB, userId int, amount int64) (int64, error) {
+	var lots []QuotaLot
+	if err := lockForUpdate(tx).
+		Where("user_id = ? AND status = ? AND remaining < amount AND expires_at > ?", userId, QuotaLotStatusActive, common.GetTimestamp()).
+		Order("expires_at desc, id desc").
+		Find(&lots).Error; err != nil {
+		return 0, err
+	}
+	var restored int64
+	for _, lot := range lots {
+		if restored >= amount {
+			break
+		}
+		put := min(lot.Amount-lot.Remaining, amount-restored)
+		if err := tx.Model(&QuotaLot{}).Where("id = ?", lot.Id).
+			Update("remaining", gorm.Expr("remaining + ?", put)).Error; err != nil {
+			return 0, err
+		}
+		restored += put
+	}
+	return restored, nil
+}
+
+// revokeTradeQuotaLotsTx 在扣回订单额度的同一事务中，按到期顺序扣减该订单入账的充值和优惠码赠送批次，
+// 避免批次到期时再次扣除已退款的额度。Amount 同步减少，之后的退还不会把额度放回已退款的部分
+func revokeTradeQuotaLotsTx(tx *gorm.DB, userId int, tradeNo string, amount int64) error {
+	if amount <= 0 || tradeNo == "" {
+		return nil
+	}
+	var lots []QuotaLot
+	if err := lockForUpdate(tx).
+		Where("user_id = ? AND source IN ? AND source_ref = ? AND status = ? AND remaining > 0", userId, []string{QuotaLotSourceTopup, QuotaLotSourceCouponBonus}, tradeNo, QuotaLotStatusActive).
+		Order("expires_at asc, id asc").
+		Find(&lots).Error; err != nil {
+		return err
+	}
+	var revoked int64
+	for _, lot := range lots {
+		if revoked >= amount {
+			break
+		}
+		take := min(lot.Remaining, amount-revoked)
+		if err := tx.Model(&QuotaLot{}).Where("id = ?", lot.Id).Updates(map[string]interface{}{
+			"remaining": gorm.Expr("remaining - ?", take),
+			"amount":    gorm.Expr("amount - ?", take),
+		}).Error; err != nil {
+			return err
+		}
+		revoked += take
+	}
+	return nil
+}
+
+// SettleUserWalletQuota 结算用户钱包：quotaDelta > 0 扣减余额，< 0 退还余额；lotDelta 为本次结算
+// 同步到额度批次的量（> 0 消耗，< 0 放回）。余额变更、批次调整和以 refType/refId 标识的钱包账本分录
+// 在同一事务内完成，结算成功即批次已扣减、账本已记账。启用批量更新时余额变更仍进入批量队列，
+// 在事务提交前加入，保证账本推算期初余额时已包含本次变更；事务失败时再加入一笔相反的变更冲回。
+func SettleUserWalletQuota(userId int, quotaDelta int, lotDelta int, refType string, refId string) error {
+	if userId <= 0 {
+		return errors.New("invalid userId")
+	}
+	adjustLots := lotDelta != 0 && operation_setting.GetCreditExpirySetting().Enabled
+	postLedger := quotaDelta != 0 && operation_setting.GetQuotaLedgerSetting().Enabled
+	batched := common.BatchUpdateEnabled
+	if quotaDelta == 0 && !adjustLots {
+		return nil
+	}
+	if adjustLots || postLedger || !batched {
+		enqueued := false
+		err := DB.Transaction(func(tx *gorm.DB) error {
+			if quotaDelta != 0 && !batched {
+				if err := tx.Model(&User{}).Where("id = ?", userId).
+					Update("quota", gorm.Expr("quota - ?", quotaDelta)).Error; err != nil {
+					return err
+				}
+			}
+			if adjustLots {
+				var err error
+				if lotDelta > 0 {
+					_, err = consumeQuotaLotsTx(tx, userId, int64(lotDelta))
+				} else {
+					_, err = restoreQuotaLotsTx(tx, userId, int64(-lotDelta))
+				}
+				if err != nil {
+					return err
+				}
+			}
+			if quotaDelta == 0 {
+				return nil
+			}
+			if batched {
+				addNewRecord(BatchUpdateTypeUserQuota, userId, -quotaDelta)
+				enqueued = true
+			}
+			return PostQuotaLedgerTx(tx, UserConsumeQuotaLedgerPosting(userId, -quotaDelta, refType, refId))
+		})
+		if err != nil {
+			if enqueued {
+				// 事务未提交，冲回已加入批量队列的余额变更，调用方按结算失败处理时不会多扣
+				addNewRecord(BatchUpdateTypeUserQuota, userId, quotaDelta)
+			}
+			return err
+		}
+	} else {
+		addNewRecord(BatchUpdateTypeUserQuota, userId, -quotaDelta)
+	}
+	if quotaDelta == 0 {
+		return nil
+	}
+	gopool.Go(func() {
+		var err error
+		if quotaDelta > 0 {
+			err = cacheDecrUserQuota(userId, int64(quotaDelta))
+		} else {
+			err = cacheIncrUserQuota(userId, int64(-quotaDelta))
+		}
+		if err != nil {
+			common.SysLog("failed to update user quota cache: " + err.Error())
+		}
+	})
+	return nil
+}
+
+// ExpireDueQuotaLots 处理已到期的批次：从用户余额中扣除未用完的部分（不会扣成负数）并记账、写日志。
+// 返回本次处理的批次数
+func ExpireDueQuotaLots(limit int) (int, error) {
+	if limit <= 0 {
+		limit = 300
+	}
+	var lots []QuotaLot
+	if err := DB.Where("status = ? AND expires_at <= ?", QuotaLotStatusActive, common.GetTimestamp()).
+		Order("expires_at asc, id asc").
+		Limit(limit).
+		Find(&lots).Error; err != nil {
+		return 0, err
+	}
+	processed := 0
+	for _, lot := range lots {
+		deducted, err := expireQuotaLot(lot.Id)
+		if err != nil {
+			common.SysLog(fmt.Sprintf("failed to expire quota lot %d: %s", lot.Id, err.Error()))
+			continue
+		}
+		processed++
+		if deducted <= 0 {
+			continue
+		}
+		_ = cacheDecrUserQuota(lot.UserId, deducted)
+		RecordLog(lot.UserId, LogTypeSystem, fmt.Sprintf("额度批次 #%d（%s）已过期，扣除剩余额度 %s", lot.Id, lot.Source, logger.LogQuota(int(deducted))))
+	}
+	return processed, nil
+}
+
+func expireQuotaLot(lotId int) (int64, error) {
+	var deducted int64
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		deducted = 0
+		var lot QuotaLot
+		if err := lockForUpdate(tx).Where("id = ?", lotId).First(&lot).Error; err != nil {
+			return err
+		}
+		if lot.Status != QuotaLotStatusActive {
+			return nil
+		}
+		if lot.Remaining > 0 {
+			var user User
+			if err := lockForUpdate(tx).Select("id", "quota").Where("id = ?", lot.UserId).First(&user).Error; err != nil {
+				if !errors.Is(err, gorm.ErrRecordNotFound) {
+					return err
+				}
+			} else {
+				deducted = min(lot.Remaining, int64(max(user.Quota, 0)))
+			}
+		}
+		if deducted > 0 {
+			if err := tx.Model(&User{}).Where("id = ?", lot.UserId).
+				Update("quota", gorm.Expr("quota - ?", deducted)).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Model(&QuotaLot{}).Where("id = ?", lot.Id).Updates(map[string]interface{}{
+			"status":         QuotaLotStatusExpired,
+			"remaining":      0,
+			"expired_amount": deducted,
+		}).Error; err != nil {
+			return err
+		}
+		if deducted <= 0 {
+			return nil
+		}
+		return PostQuotaLedgerTx(tx, UserQuotaLedgerPosting(lot.UserId, -int(deducted), QuotaLedgerReasonCreditExpiry, QuotaLedgerRefQuotaLotId, strconv.Itoa(lot.Id)))
+	})
+	return deducted, err
+}
+
+// QuotaBalanceBreakdown 按到期时间拆分用户余额
+type QuotaBalanceBreakdown struct {
+	Quota       int        `json:"quota"`
+	Expiring    int64      `json:"expiring"`
+	NonExpiring int64      `json:"non_expiring"`
+	Lots        []QuotaLot `json:"lots"`
+}
+
+// GetUserQuotaBalanceBreakdown 返回用户当前余额中各批次的剩余额度（按到期时间升序）和永不过期的部分
+func GetUserQuotaBalanceBreakdown(userId int) (*QuotaBalanceBreakdown, error) {
+	quota, err := GetUserQuota(userId, true)
+	if err != nil {
+		return nil, err
+	}
+	breakdown := &QuotaBalanceBreakdown{Quota: quota, Lots: []QuotaLot{}}
+	if err := DB.Where("user_id = ? AND status = ? AND remaining > 0", userId, QuotaLotStatusActive).
+		Order("expires_at asc, id asc").
+		Find(&breakdown.Lots).Error; err != nil {
+		return nil, err
+	}
+	for _, lot := range breakdown.Lots {
+		breakdown.Expiring += lot.Remaining
+	}
+	breakdown.NonExpiring = max(int64(quota)-breakdown.Expiring, 0)
+	return breakdown, nil
+}
@@ -0,0 +1,180 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func enableCreditExpiry(t *testing.T) {
+	t.Helper()
+	setting := operation_setting.GetCreditExpirySetting()
+	saved := *setting
+	setting.Enabled = true
+	t.Cleanup(func() { *setting = saved })
+}
+
+func getQuotaLot(t *testing.T, source string, ref string) QuotaLot {
+	t.Helper()
+	var lot QuotaLot
+	require.NoError(t, DB.Where("source = ? AND source_ref = ?", source, ref).First(&lot).Error)
+	return lot
+}
+
+func TestQuotaLotsConsumeOldestExpiringFirstAndRestoreLatestFirst(t *testing.T) {
+	truncateTables(t)
+	enableCreditExpiry(t)
+	user := &User{Username: "lot-user", Quota: 1000, Status: common.UserStatusEnabled}
+	require.NoError(t, DB.Create(user).Error)
+
+	require.NoError(t, GrantQuotaLotTx(DB, user.Id, QuotaLotSourceRedemption, "late", 300, 30))
+	require.NoError(t, GrantQuotaLotTx(DB, user.Id, QuotaLotSourceCheckin, "early", 300, 10))
+	// 有效期为 0 不生成批次
+	require.NoError(t, GrantQuotaLotTx(DB, user.Id, QuotaLotSourceTopup, "forever", 300, 0))
+	var count int64
+	require.NoError(t, DB.Model(&QuotaLot{}).Where("user_id = ?", user.Id).Count(&count).Error)
+	require.EqualValues(t, 2, count)
+
+	consumed, err := ConsumeQuotaLots(user.Id, 400)
+	require.NoError(t, err)
+	assert.EqualValues(t, 400, consumed)
+	assert.EqualValues(t, 0, getQuotaLot(t, QuotaLotSourceCheckin, "early").Remaining)
+	assert.EqualValues(t, 200, getQuotaLot(t, QuotaLotSourceRedemption, "late").Remaining)
+
+	// 超出批次剩余的消耗由永久余额承担
+	consumed, err = ConsumeQuotaLots(user.Id, 500)
+	require.NoError(t, err)
+	assert.EqualValues(t, 200, consumed)
+
+	restored, err := RestoreQuotaLots(user.Id, 450)
+	require.NoError(t, err)
+	assert.EqualValues(t, 450, restored)
+	assert.EqualValues(t, 300, getQuotaLot(t, QuotaLotSourceRedemption, "late").Remaining)
+	assert.EqualValues(t, 150, getQuotaLot(t, QuotaLotSourceCheckin, "early").Remaining)
+
+	breakdown, err := GetUserQuotaBalanceBreakdown(user.Id)
+	require.NoError(t, err)
+	assert.Equal(t, 1000, breakdown.Quota)
+	assert.EqualValues(t, 450, breakdown.Expiring)
+	assert.EqualValues(t, 550, breakdown.NonExpiring)
+	require.Len(t, breakdown.Lots, 2)
+	assert.Equal(t, "early", breakdown.Lots[0].SourceRef)
+}
+
+func TestExpireDueQuotaLotsDeductsRemainingBalance(t *testing.T) {
+	truncateTables(t)
+	enableCreditExpiry(t)
+	user := &User{Username: "expiry-user", Quota: 100, Status: common.UserStatusEnabled}
+	require.NoError(t, DB.Create(user).Error)
+
+	require.NoError(t, GrantQuotaLotTx(DB, user.Id, QuotaLotSourceCheckin, "c1", 80, 30))
+	require.NoError(t, GrantQuotaLotTx(DB, user.Id, QuotaLotSourceCheckin, "c2", 60, 30))
+	require.NoError(t, GrantQuotaLotTx(DB, user.Id, QuotaLotSourceCheckin, "c3", 50, 30))
+	past := common.GetTimestamp() - 1
+	require.NoError(t, DB.Model(&QuotaLot{}).Where("source_ref IN ?", []string{"c1", "c2"}).Update("expires_at", past).Error)
+
+	n, err := ExpireDueQuotaLots(10)
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+
+	// 余额不足以覆盖全部过期额度时不会扣成负数
+	quota, err := GetUserQuota(user.Id, true)
+	require.NoError(t, err)
+	assert.Equal(t, 0, quota)
+	c1 := getQuotaLot(t, QuotaLotSourceCheckin, "c1")
+	c2 := getQuotaLot(t, QuotaLotSourceCheckin, "c2")
+	assert.Equal(t, QuotaLotStatusExpired, c1.Status)
+	assert.EqualValues(t, 80, c1.ExpiredAmount)
+	assert.EqualValues(t, 20, c2.ExpiredAmount)
+	assert.Equal(t, QuotaLotStatusActive, getQuotaLot(t, QuotaLotSourceCheckin, "c3").Status)
+
+	var logs []Log
+	require.NoError(t, LOG_DB.Where("user_id = ? AND type = ?", user.Id, LogTypeSystem).Find(&logs).Error)
+	assert.Len(t, logs, 2)
+	var entries int64
+	require.NoError(t, DB.Model(&QuotaLedgerEntry{}).Where("reason = ?", QuotaLedgerReasonCreditExpiry).Count(&entries).Error)
+	assert.EqualValues(t, 4, entries)
+
+	n, err = ExpireDueQuotaLots(10)
+	require.NoError(t, err)
+	assert.Zero(t, n)
+}
+
+func TestFundOrganizationFromUserConsumesQuotaLots(t *testing.T) {
+	truncateTables(t)
+	enableCreditExpiry(t)
+	org, owner := setupOrganization(t, 0)
+	require.NoError(t, GrantQuotaLotTx(DB, owner.Id, QuotaLotSourceCheckin, "promo", 300, 10))
+
+	// 转入组织钱包的额度不再过期，个人到期批次需同步扣减
+	require.NoError(t, FundOrganizationFromUser(org.Id, owner.Id, 200))
+	assert.EqualValues(t, 100, getQuotaLot(t, QuotaLotSourceCheckin, "promo").Remaining)
+}
+
+func TestRecordPaymentRefundKeepsQuotaLots(t *testing.T) {
+	truncateTables(t)
+	enableCreditExpiry(t)
+	user := &User{Username: "refund-lot-user", Status: common.UserStatusEnabled}
+	require.NoError(t, DB.Create(user).Error)
+	topUp := &TopUp{
+		UserId:          user.Id,
+		Amount:          10,
+		Money:           20,
+		TradeNo:         "PAYPAL-refund-lot",
+		PaymentMethod:   PaymentMethodPayPal,
+		PaymentProvider: PaymentProviderPayPal,
+		Status:          common.TopUpStatusPending,
+	}
+	require.NoError(t, topUp.Insert())
+	require.NoError(t, CompleteProviderTopUp(topUp.TradeNo, PaymentProviderPayPal, "127.0.0.1"))
+	require.NoError(t, DB.Model(&User{}).Where("id = ?", user.Id).Update("quota", gorm.Expr("quota + ?", 300)).Error)
+	require.NoError(t, GrantQuotaLotTx(DB, user.Id, QuotaLotSourceCheckin, "refund-promo", 300, 10))
+
+	// 退款扣回的是充值的永久余额，赠送批次保持不变
+	_, err := RecordPaymentRefund(PaymentRefundParams{Provider: PaymentProviderPayPal, RefundId: "R-lot", TradeNo: topUp.TradeNo, Money: 20, Currency: "USD"})
+	require.NoError(t, err)
+	assert.EqualValues(t, 300, getQuotaLot(t, QuotaLotSourceCheckin, "refund-promo").Remaining)
+	quota, err := GetUserQuota(user.Id, true)
+	require.NoError(t, err)
+	assert.Equal(t, 300, quota)
+}
+
+func TestRecordPaymentRefundRevokesTopUpQuotaLot(t *testing.T) {
+	truncateTables(t)
+	enableCreditExpiry(t)
+	operation_setting.GetCreditExpirySetting().TopupValidDays = 30
+	user := &User{Username: "refund-topup-lot-user", Quota: 300, Status: common.UserStatusEnabled}
+	require.NoError(t, DB.Create(user).Error)
+	topUp := &TopUp{
+		UserId:          user.Id,
+		Amount:          10,
+		Money:           20,
+		TradeNo:         "PAYPAL-refund-topup-lot",
+		PaymentMethod:   PaymentMethodPayPal,
+		PaymentProvider: PaymentProviderPayPal,
+		Status:          common.TopUpStatusPending,
+	}
+	require.NoError(t, topUp.Insert())
+	require.NoError(t, CompleteProviderTopUp(topUp.TradeNo, PaymentProviderPayPal, "127.0.0.1"))
+	granted := int64(10 * common.QuotaPerUnit)
+	require.EqualValues(t, granted, getQuotaLot(t, QuotaLotSourceTopup, topUp.TradeNo).Remaining)
+
+	_, err := RecordPaymentRefund(PaymentRefundParams{Provider: PaymentProviderPayPal, RefundId: "R-topup-lot", TradeNo: topUp.TradeNo, Money: 20, Currency: "USD"})
+	require.NoError(t, err)
+	lot := getQuotaLot(t, QuotaLotSourceTopup, topUp.TradeNo)
+	assert.Zero(t, lot.Remaining)
+	assert.Zero(t, lot.Amount)
+
+	// 已退款的批次到期时不再扣除永久余额
+	require.NoError(t, DB.Model(&QuotaLot{}).Where("id = ?", lot.Id).Update("expires_at", common.GetTimestamp()-1).Error)
+	_, err = ExpireDueQuotaLots(10)
+	require.NoError(t, err)
+	assert.Zero(t, getQuotaLot(t, QuotaLotSourceTopup, topUp.TradeNo).ExpiredAmount)
+	quota, err := GetUserQuota(user.Id, true)
+	require.NoError(t, err)
+	assert.Equal(t, 300, quota)
+}
@@ -0,0 +1,181 @@
+package model
+
+import (
+	"github.com/QuantumNous/new-api/common"
+
+	"gorm.io/gorm"
+)
+
+// ShadowComparison records one mirrored request: the primary channel answered
+// the client, the shadow channel ran the same request in the background.
+type ShadowComparison struct {
+	Id                      int     `json:"id" gorm:"primaryKey;autoIncrement"`
+	ShadowChannelId         int     `json:"shadow_channel_id" gorm:"not null;index:idx_shadow_comparison_channel_created,priority:1"`
+	CreatedAt               int64   `json:"created_at" gorm:"bigint;not null;index:idx_shadow_comparison_channel_created,priority:2;index:idx_shadow_comparison_created"`
+	PrimaryChannelId        int     `json:"primary_channel_id" gorm:"index"`
+	RuleName                string  `json:"rule_name" gorm:"type:varchar(64)"`
+	RequestId               string  `json:"request_id" gorm:"type:varchar(64);index"`
+	UserId                  int     `json:"user_id" gorm:"index"`
+	UsingGroup              string  `json:"using_group" gorm:"type:varchar(64)"`
+	ModelName               string  `json:"model_name" gorm:"type:varchar(255);index"`
+	IsStream                bool    `json:"is_stream"`
+	PrimaryLatencyMs        int64   `json:"primary_latency_ms" gorm:"bigint;default:0"`
+	ShadowLatencyMs         int64   `json:"shadow_latency_ms" gorm:"bigint;default:0"`
+	PrimaryPromptTokens     int     `json:"primary_prompt_tokens"`
+	PrimaryCompletionTokens int     `json:"primary_completion_tokens"`
+	ShadowPromptTokens      int     `json:"shadow_prompt_tokens"`
+	ShadowCompletionTokens  int     `json:"shadow_completion_tokens"`
+	ShadowSuccess           bool    `json:"shadow_success" gorm:"index"`
+	ShadowStatusCode        int     `json:"shadow_status_code"`
+	ShadowError             string  `json:"shadow_error" gorm:"type:text"`
+	ShadowQuota             int     `json:"shadow_quota"`
+	TextCompared            bool    `json:"text_compared"`
+	TextSimilarity          float64 `json:"text_similarity"`
+}
+
+// ShadowComparisonSummary aggregates comparison rows of one shadow channel.
+type ShadowComparisonSummary struct {
+	Total                int64   `json:"total"`
+	Succeeded            int64   `json:"succeeded"`
+	AvgPrimaryLatencyMs  float64 `json:"avg_primary_latency_ms"`
+	AvgShadowLatencyMs   float64 `json:"avg_shadow_latency_ms"`
+	AvgPrimaryCompletion float64 `json:"avg_primary_completion_tokens"`
+	AvgShadowCompletion  float64 `json:"avg_shadow_completion_tokens"`
+	TextCompared         int64   `json:"text_compared"`
+	AvgTextSimilarity    float64 `json:"avg_text_similarity"`
+	TotalShadowQuota     int64   `json:"total_shadow_quota"`
+}
+
+func (s *ShadowComparison) Insert() error {
+	if s.CreatedAt == 0 {
+		s.CreatedAt = common.GetTimestamp()
+	}
+	return DB.Create(s).Error
+}
+
+func GetShadowComparisonPageByChannelID(channelID int, page int, pageSize int) ([]*ShadowComparison, int64, error) {
+	if channelID <= 0 {
+		return []*ShadowComparison{}, 0, nil
+	}
+	if page < 1 {
+		page = 1
+	}
+	if pageSize <= 0 {
+		pageSize = common.ItemsPerPage
+	}
+	if pageSize > 100 {
+		pageSize = 100
+	}
+
+	query := DB.Model(&ShadowComparison{}).Where("shadow_channel_id = ?", channelID)
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	items := make([]*ShadowComparison, 0, pageSize)
+	if err := query.Order("created_at DESC").Order("id DESC").
+		Offset((page - 1) * pageSize).
+		Limit(pageSize).
+		Find(&items).Error; err != nil {
+		return nil, 0, err
+	}
+	return items, total, nil
+}
+
+// GetShadowComparisonSummary aggregates the comparisons of a shadow channel
+// created at or after since (0 means all time).
+func GetShadowComparisonSummary(channelID int, since int64) (*ShadowComparisonSummary, error) {
+	summary := &ShadowComparisonSummary{}
+	if channelID <= 0 {
+		return summary, nil
+	}
+	base := func() *gorm.DB {
+		q := DB.Model(&ShadowComparison{}).Where("shadow_channel_id = ?", channelID)
+		if since > 0 {
+			q = q.Where("created_at >= ?", since)
+		}
+		return q
+	}
+
+	var all struct {
+		Total             int64
+		AvgPrimaryLatency float64
+		AvgPrimaryComp    float64
+		TotalShadowQuota  int64
+	}
+	if err := base().Select("COUNT(*) AS total, COALESCE(AVG(primary_latency_ms), 0) AS avg_primary_latency, " +
+		"COALESCE(AVG(primary_completion_tokens), 0) AS avg_primary_comp, COALESCE(SUM(shadow_quota), 0) AS total_shadow_quota").
+		Scan(&all).Error; err != nil {
+		return nil, err
+	}
+
+	var succeeded struct {
+		Succeeded        int64
+		AvgShadowLatency float64
+		AvgShadowComp    float64
+	}
+	if err := base().Where("shadow_success = ?", true).
+		Select("COUNT(*) AS succeeded, COALESCE(AVG(shadow_latency_ms), 0) AS avg_shadow_latency, " +
+			"COALESCE(AVG(shadow_completion_tokens), 0) AS avg_shadow_comp").
+		Scan(&succeeded).Error; err != nil {
+		return nil, err
+	}
+
+	var compared struct {
+		TextCompared      int64
+		AvgTextSimilarity float64
+	}
+	if err := base().Where("text_compared = ?", true).
+		Select("COUNT(*) AS text_compared, COALESCE(AVG(text_similarity), 0) AS avg_text_similarity").
+		Scan(&compared).Error; err != nil {
+		return nil, err
+	}
+
+	summary.Total = all.Total
+	summary.AvgPrimaryLatencyMs = all.AvgPrimaryLatency
+	summary.AvgPrimaryCompletion = all.AvgPrimaryComp
+	summary.TotalShadowQuota = all.TotalShadowQuota
+	summary.Succeeded = succeeded.Succeeded
+	summary.AvgShadowLatencyMs = succeeded.AvgShadowLatency
+	summary.AvgShadowCompletion = succeeded.AvgShadowComp
+	summary.TextCompared = compared.TextCompared
+	summary.AvgTextSimilarity = compared.AvgTextSimilarity
+	return summary, nil
+}
+
+func CleanupShadowComparisonRecords(olderThanSeconds int64, limit int) (int64, error) {
+	if olderThanSeconds <= 0 {
+		olderThanSeconds = 30 * 24 * 3600
+	}
+	if limit <= 0 {
+		limit = 1000
+	}
+	cutoff := common.GetTimestamp() - olderThanSeconds
+	var total int64
+
+	for {
+		var ids []int
+		if err := DB.Model(&ShadowComparison{}).
+			Where("created_at < ?", cutoff).
+			Order("id ASC").
+			Limit(limit).
+			Pluck("id", &ids).Error; err != nil {
+			return total, err
+		}
+		if len(ids) == 0 {
+			break
+		}
+
+		result := DB.Where("id IN ?", ids).Delete(&ShadowComparison{})
+		if result.Error != nil {
+			return total, result.Error
+		}
+		total += result.RowsAffected
+		if len(ids) < limit {
+			break
+		}
+	}
+
+	return total, nil
+}
@@ -0,0 +1,90 @@
+package model
+
+import (
+	"errors"
+
+	"github.com/QuantumNous/new-api/common"
+
+	"gorm.io/gorm"
+)
+
+// StoredResponse 为网关代存的一条 Responses API 响应，用于在无状态上游上模拟 previous_response_id。
+// InputItems 为该轮请求展开后的完整输入条目（含历史），Response 为返回给客户端的完整响应 JSON
+type StoredResponse struct {
+	Id                 int64  `json:"id"`
+	ResponseId         string `json:"response_id" gorm:"type:varchar(191);uniqueIndex"`
+	UserId             int    `json:"user_id" gorm:"index"`
+	Model              string `json:"model" gorm:"type:varchar(255)"`
+	PreviousResponseId string `json:"previous_response_id" gorm:"type:varchar(191)"`
+	InputItems         string `json:"-"`
+	Response           string `json:"-"`
+	Size               int    `json:"size"`
+	CreatedAt          int64  `json:"created_at" gorm:"bigint"`
+	ExpiresAt          int64  `json:"expires_at" gorm:"bigint;index"`
+}
+
+func (r *StoredResponse) Insert() error {
+	if r.CreatedAt == 0 {
+		r.CreatedAt = common.GetTimestamp()
+	}
+	return DB.Create(r).Error
+}
+
+// GetStoredResponse 返回用户未过期的已存响应，不存在时返回 nil
+func GetStoredResponse(userId int, responseId string) (*StoredResponse, error) {
+	if responseId == "" {
+		return nil, nil
+	}
+	var r StoredResponse
+	err := DB.Where("user_id = ? AND response_id = ? AND expires_at > ?", userId, responseId, common.GetTimestamp()).
+		First(&r).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// DeleteStoredResponse 删除用户的已存响应，返回是否确实删除了记录
+func DeleteStoredResponse(userId int, responseId string) (bool, error) {
+	result := DB.Where("user_id = ? AND response_id = ?", userId, responseId).Delete(&StoredResponse{})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// CleanupExpiredStoredResponses 分批删除已过期的已存响应，返回删除条数
+func CleanupExpiredStoredResponses(limit int) (int64, error) {
+	if limit <= 0 {
+		limit = 1000
+	}
+	now := common.GetTimestamp()
+	var total int64
+
+	for {
+		var ids []int64
+		if err := DB.Model(&StoredResponse{}).
+			Where("expires_at <= ?", now).
+			Order("id ASC").
+			Limit(limit).
+			Pluck("id", &ids).Error; err != nil {
+			return total, err
+		}
+		if len(ids) == 0 {
+			break
+		}
+
+		result := DB.Where("id IN ?", ids).Delete(&StoredResponse{})
+		if result.Error != nil {
+			return total, result.Error
+		}
+		total += result.RowsAffected
+		if len(ids) < limit {
+			break
+		}
+	}
+	return total, nil
+}
@@ -185,6 +185,11 @@ type SubscriptionPlan struct {
 	QuotaResetPeriod        string `json:"quota_reset_period" gorm:"type:varchar(16);default:'never'"`
 	QuotaResetCustomSeconds int64  `json:"quota_reset_custom_seconds" gorm:"type:bigint;default:0"`
 
+	// Per-model quota buckets; matched requests consume the bucket instead of TotalAmount
+	QuotaBuckets SubscriptionQuotaBuckets `json:"quota_buckets" gorm:"type:text"`
+	// Only models covered by a quota bucket may use this plan
+	BucketsOnly bool `json:"buckets_only" gorm:"default:false"`
+
 	CreatedAt int64 `json:"created_at" gorm:"bigint"`
 	UpdatedAt int64 `json:"updated_at" gorm:"bigint"`
 }
@@ -225,6 +230,10 @@ type SubscriptionOrder struct {
 	CompleteTime    int64  `json:"complete_time"`
 
 	ProviderPayload string `json:"provider_payload" gorm:"type:text"`
+
+	// 优惠码归因：Money 为实付金额，DiscountMoney 为减免金额
+	CouponCode    string  `json:"coupon_code" gorm:"type:varchar(64);default:'';index"`
+	DiscountMoney float64 `json:"discount_money"`
 }
 
 func (o *SubscriptionOrder) Insert() error {
@@ -254,6 +263,8 @@ type UserSubscription struct {
 	Id     int `json:"id"`
 	UserId int `json:"user_id" gorm:"index;index:idx_user_sub_active,priority:1"`
 	PlanId int `json:"plan_id" gorm:"index"`
+	// 组织订阅的所属组织，为 0 时是个人订阅；组织订阅的 UserId 记录购买或绑定时的操作用户
+	OrganizationId int `json:"organization_id" gorm:"index;default:0"`
 
 	AmountTotal int64 `json:"amount_total" gorm:"type:bigint;not null;default:0"`
 	AmountUsed  int64 `json:"amount_used" gorm:"type:bigint;not null;default:0"`
@@ -293,7 +304,8 @@ func (s *UserSubscription) BeforeUpdate(tx *gorm.DB) error {
 }
 
 type SubscriptionSummary struct {
-	Subscription *UserSubscription `json:"subscription"`
+	Subscription *UserSubscription        `json:"subscription"`
+	Buckets      []UserSubscriptionBucket `json:"buckets,omitempty"`
 }
 
 type SubscriptionResetResult struct {
@@ -345,7 +357,11 @@ func calcNextResetTime(base time.Time, plan *SubscriptionPlan, endUnix int64) in
 	if plan == nil {
 		return 0
 	}
-	period := NormalizeResetPeriod(plan.QuotaResetPeriod)
+	return calcNextResetTimeByPeriod(base, plan.QuotaResetPeriod, plan.QuotaResetCustomSeconds, endUnix)
+}
+
+func calcNextResetTimeByPeriod(base time.Time, period string, customSeconds int64, endUnix int64) int64 {
+	period = NormalizeResetPeriod(period)
 	if period == SubscriptionResetNever {
 		return 0
 	}
@@ -369,10 +385,10 @@ func calcNextResetTime(base time.Time, plan *SubscriptionPlan, endUnix int64) in
 		next = time.Date(base.Year(), base.Month(), 1, 0, 0, 0, 0, base.Location()).
 			AddDate(0, 1, 0)
 	case SubscriptionResetCustom:
-		if plan.QuotaResetCustomSeconds <= 0 {
+		if customSeconds <= 0 {
 			return 0
 		}
-		next = base.Add(time.Duration(plan.QuotaResetCustomSeconds) * time.Second)
+		next = base.Add(time.Duration(customSeconds) * time.Second)
 	default:
 		return 0
 	}
@@ -416,7 +432,7 @@ func CountUserSubscriptionsByPlan(userId int, planId int) (int64, error) {
 	}
 	var count int64
 	if err := DB.Model(&UserSubscription{}).
-		Where("user_id = ? AND plan_id = ?", userId, planId).
+		Where("user_id = ? AND plan_id = ? AND organization_id = 0", userId, planId).
 		Count(&count).Error; err != nil {
 		return 0, err
 	}
@@ -482,6 +498,12 @@ func downgradeUserGroupForSubscriptionTx(tx *gorm.DB, sub *UserSubscription, now
 }
 
 func CreateUserSubscriptionFromPlanTx(tx *gorm.DB, userId int, plan *SubscriptionPlan, source string) (*UserSubscription, error) {
+	return createSubscriptionFromPlanTx(tx, userId, 0, plan, source)
+}
+
+// createSubscriptionFromPlanTx 按套餐快照创建订阅。orgId > 0 时创建组织订阅：
+// 购买上限按组织计算，也不会升级操作用户的分组。
+func createSubscriptionFromPlanTx(tx *gorm.DB, userId int, orgId int, plan *SubscriptionPlan, source string) (*UserSubscription, error) {
 	if tx == nil {
 		return nil, errors.New("tx is nil")
 	}
@@ -492,10 +514,14 @@ func CreateUserSubscriptionFromPlanTx(tx *gorm.DB, userId int, plan *Subscriptio
 		return nil, errors.New("invalid user id")
 	}
 	if plan.MaxPurchasePerUser > 0 {
+		countQuery := tx.Model(&UserSubscription{}).Where("plan_id = ?", plan.Id)
+		if orgId > 0 {
+			countQuery = countQuery.Where("organization_id = ?", orgId)
+		} else {
+			countQuery = countQuery.Where("user_id = ? AND organization_id = 0", userId)
+		}
 		var count int64
-		if err := tx.Model(&UserSubscription{}).
-			Where("user_id = ? AND plan_id = ?", userId, plan.Id).
-			Count(&count).Error; err != nil {
+		if err := countQuery.Count(&count).Error; err != nil {
 			return nil, err
 		}
 		if count >= int64(plan.MaxPurchasePerUser) {
@@ -515,6 +541,11 @@ func CreateUserSubscriptionFromPlanTx(tx *gorm.DB, userId int, plan *Subscriptio
 		lastReset = now.Unix()
 	}
 	upgradeGroup := strings.TrimSpace(plan.UpgradeGroup)
+	downgradeGroup := strings.TrimSpace(plan.DowngradeGroup)
+	if orgId > 0 {
+		upgradeGroup = ""
+		downgradeGroup = ""
+	}
 	prevGroup := ""
 	if upgradeGroup != "" {
 		currentGroup, err := getUserGroupByIdTx(tx, userId)
@@ -536,6 +567,7 @@ func CreateUserSubscriptionFromPlanTx(tx *gorm.DB, userId int, plan *Subscriptio
 	sub := &UserSubscription{
 		UserId:              userId,
 		PlanId:              plan.Id,
+		OrganizationId:      orgId,
 		AmountTotal:         plan.TotalAmount,
 		AmountUsed:          0,
 		StartTime:           now.Unix(),
@@ -546,7 +578,7 @@ func CreateUserSubscriptionFromPlanTx(tx *gorm.DB, userId int, plan *Subscriptio
 		NextResetTime:       nextReset,
 		UpgradeGroup:        upgradeGroup,
 		PrevUserGroup:       prevGroup,
-		DowngradeGroup:      strings.TrimSpace(plan.DowngradeGroup),
+		DowngradeGroup:      downgradeGroup,
 		AllowWalletOverflow: allowWalletOverflow,
 		CreatedAt:           common.GetTimestamp(),
 		UpdatedAt:           common.GetTimestamp(),
@@ -554,6 +586,9 @@ func CreateUserSubscriptionFromPlanTx(tx *gorm.DB, userId int, plan *Subscriptio
 	if err := tx.Create(sub).Error; err != nil {
 		return nil, err
 	}
+	if err := createUserSubscriptionBucketsTx(tx, sub, plan); err != nil {
+		return nil, err
+	}
 	return sub, nil
 }
 
@@ -607,6 +642,9 @@ func CompleteSubscriptionOrder(tradeNo string, providerPayload string, expectedP
 		if subscription.PrevUserGroup != "" {
 			upgradeGroup = strings.TrimSpace(subscription.UpgradeGroup)
 		}
+		if err := grantSubscriptionCouponBonusTx(tx, &order, subscription); err != nil {
+			return err
+		}
 		if err := upsertSubscriptionTopUpTx(tx, &order); err != nil {
 			return err
 		}
@@ -657,12 +695,16 @@ func upsertSubscriptionTopUpTx(tx *gorm.DB, order *SubscriptionOrder) error {
 				CreateTime:    order.CreateTime,
 				CompleteTime:  now,
 				Status:        common.TopUpStatusSuccess,
+				CouponCode:    order.CouponCode,
+				DiscountMoney: order.DiscountMoney,
 			}
 			return tx.Create(&topup).Error
 		}
 		return err
 	}
 	topup.Money = order.Money
+	topup.CouponCode = order.CouponCode
+	topup.DiscountMoney = order.DiscountMoney
 	if topup.PaymentMethod == "" {
 		topup.PaymentMethod = order.PaymentMethod
 	} else if topup.PaymentMethod != order.PaymentMethod {
@@ -697,7 +739,10 @@ func ExpireSubscriptionOrder(tradeNo string, expectedPaymentProvider string) err
 		}
 		order.Status = common.TopUpStatusExpired
 		order.CompleteTime = common.GetTimestamp()
-		return tx.Save(&order).Error
+		if err := tx.Save(&order).Error; err != nil {
+			return err
+		}
+		return releaseCouponRedemptionTx(tx, order.TradeNo)
 	})
 }
 
@@ -784,6 +829,9 @@ func PurchaseSubscriptionWithBalance(userId int, planId int) error {
 				Update("quota", gorm.Expr("quota - ?", requiredQuota)).Error; err != nil {
 				return err
 			}
+			if err := consumeUserQuotaLotsTx(tx, userId, int64(requiredQuota)); err != nil {
+				return err
+			}
 		}
 
 		subscription, err := CreateUserSubscriptionFromPlanTx(tx, userId, plan, PaymentMethodBalance)
@@ -808,6 +856,9 @@ func PurchaseSubscriptionWithBalance(userId int, planId int) error {
 		if err := tx.Create(order).Error; err != nil {
 			return err
 		}
+		if err := PostQuotaLedgerTx(tx, UserQuotaLedgerPosting(userId, -requiredQuota, QuotaLedgerReasonSubscriptionPurchase, QuotaLedgerRefTradeNo, tradeNo)); err != nil {
+			return err
+		}
 
 		logPlanTitle = plan.Title
 		logMoney = plan.PriceAmount
@@ -841,7 +892,7 @@ func GetAllActiveUserSubscriptions(userId int) ([]SubscriptionSummary, error) {
 	}
 	now := common.GetTimestamp()
 	var subs []UserSubscription
-	err := DB.Where("user_id = ? AND status = ? AND end_time > ?", userId, "active", now).
+	err := DB.Where("user_id = ? AND organization_id = 0 AND status = ? AND end_time > ?", userId, "active", now).
 		Order("end_time desc, id desc").
 		Find(&subs).Error
 	if err != nil {
@@ -859,7 +910,7 @@ func HasActiveUserSubscription(userId int) (bool, error) {
 	now := common.GetTimestamp()
 	var count int64
 	if err := DB.Model(&UserSubscription{}).
-		Where("user_id = ? AND status = ? AND end_time > ?", userId, "active", now).
+		Where("user_id = ? AND organization_id = 0 AND status = ? AND end_time > ?", userId, "active", now).
 		Count(&count).Error; err != nil {
 		return false, err
 	}
@@ -876,7 +927,7 @@ func UserActiveSubscriptionsAllowWalletOverflow(userId int) (bool, error) {
 	now := common.GetTimestamp()
 	var strictCount int64
 	if err := DB.Model(&UserSubscription{}).
-		Where("user_id = ? AND status = ? AND end_time > ? AND allow_wallet_overflow = ?",
+		Where("user_id = ? AND organization_id = 0 AND status = ? AND end_time > ? AND allow_wallet_overflow = ?",
 			userId, "active", now, false).
 		Count(&strictCount).Error; err != nil {
 		return false, err
@@ -890,7 +941,7 @@ func GetAllUserSubscriptions(userId int) ([]SubscriptionSummary, error) {
 		return nil, errors.New("invalid userId")
 	}
 	var subs []UserSubscription
-	err := DB.Where("user_id = ?", userId).
+	err := DB.Where("user_id = ? AND organization_id = 0", userId).
 		Order("end_time desc, id desc").
 		Find(&subs).Error
 	if err != nil {
@@ -903,11 +954,20 @@ func buildSubscriptionSummaries(subs []UserSubscription) []SubscriptionSummary {
 	if len(subs) == 0 {
 		return []SubscriptionSummary{}
 	}
+	subIds := make([]int, 0, len(subs))
+	for _, sub := range subs {
+		subIds = append(subIds, sub.Id)
+	}
+	buckets, err := GetUserSubscriptionBucketsBySubscriptionIds(subIds)
+	if err != nil {
+		common.SysError("failed to load subscription buckets: " + err.Error())
+	}
 	result := make([]SubscriptionSummary, 0, len(subs))
 	for _, sub := range subs {
 		subCopy := sub
 		result = append(result, SubscriptionSummary{
 			Subscription: &subCopy,
+			Buckets:      buckets[sub.Id],
 		})
 	}
 	return result
@@ -982,6 +1042,9 @@ func AdminDeleteUserSubscription(userSubscriptionId int) (string, error) {
 			cacheGroup = target
 			downgradeGroup = target
 		}
+		if err := tx.Where("user_subscription_id = ?", userSubscriptionId).Delete(&UserSubscriptionBucket{}).Error; err != nil {
+			return err
+		}
 		if err := tx.Where("id = ?", userSubscriptionId).Delete(&UserSubscription{}).Error; err != nil {
 			return err
 		}
@@ -1003,6 +1066,7 @@ func resetUserSubscriptionTx(tx *gorm.DB, sub *UserSubscription, plan *Subscript
 	if tx == nil || sub == nil || plan == nil {
 		return errors.New("invalid reset args")
 	}
+	usedBefore := sub.AmountUsed
 	sub.AmountUsed = 0
 	if advanceResetTime {
 		nextReset := calcNextResetTime(time.Unix(now, 0), plan, sub.EndTime)
@@ -1013,7 +1077,13 @@ func resetUserSubscriptionTx(tx *gorm.DB, sub *UserSubscription, plan *Subscript
 			sub.LastResetTime = 0
 		}
 	}
-	return tx.Save(sub).Error
+	if err := tx.Save(sub).Error; err != nil {
+		return err
+	}
+	if err := resetUserSubscriptionBucketsTx(tx, sub, now, advanceResetTime); err != nil {
+		return err
+	}
+	return postSubscriptionResetLedgerTx(tx, sub, usedBefore)
 }
 
 func buildSubscriptionResetResult(plan *SubscriptionPlan, subs []UserSubscription, advanceResetTime bool) *SubscriptionResetResult {
@@ -1043,7 +1113,7 @@ func adminResetUserSubscriptionsByPlanTx(tx *gorm.DB, userId int, plan *Subscrip
 	}
 	var subs []UserSubscription
 	if err := lockForUpdate(tx).
-		Where("user_id = ? AND plan_id = ? AND status = ? AND end_time > ?", userId, plan.Id, "active", now).
+		Where("user_id = ? AND organization_id = 0 AND plan_id = ? AND status = ? AND end_time > ?", userId, plan.Id, "active", now).
 		Order("end_time asc, id asc").
 		Find(&subs).Error; err != nil {
 		return nil, err
@@ -1124,6 +1194,28 @@ type SubscriptionPreConsumeResult struct {
 	AmountTotal        int64
 	AmountUsedBefore   int64
 	AmountUsedAfter    int64
+	// 命中额度桶时填充；PreConsumed 为桶计量单位下的预扣量，
+	// 只有按额度计量的桶才会把 AmountTotal/AmountUsed* 设为桶的用量
+	BucketId   int
+	BucketKey  string
+	BucketName string
+	BucketUnit string
+}
+
+// fillBucket 用额度桶的用量填充预扣结果
+func (r *SubscriptionPreConsumeResult) fillBucket(row *UserSubscriptionBucket, usedBefore int64) {
+	r.BucketId = row.Id
+	r.BucketKey = row.BucketKey
+	r.BucketName = row.Name
+	r.BucketUnit = row.Unit
+	r.AmountTotal = 0
+	r.AmountUsedBefore = 0
+	r.AmountUsedAfter = 0
+	if row.Unit == SubscriptionBucketUnitQuota {
+		r.AmountTotal = row.AmountTotal
+		r.AmountUsedBefore = usedBefore
+		r.AmountUsedAfter = row.AmountUsed
+	}
 }
 
 // ExpireDueSubscriptions marks expired subscriptions and handles group downgrade.
@@ -1230,10 +1322,15 @@ type SubscriptionPreConsumeRecord struct {
 	RequestId          string `json:"request_id" gorm:"type:varchar(64);uniqueIndex"`
 	UserId             int    `json:"user_id" gorm:"index"`
 	UserSubscriptionId int    `json:"user_subscription_id" gorm:"index"`
-	PreConsumed        int64  `json:"pre_consumed" gorm:"type:bigint;not null;default:0"`
-	Status             string `json:"status" gorm:"type:varchar(32);index"` // consumed/refunded
-	CreatedAt          int64  `json:"created_at" gorm:"bigint"`
-	UpdatedAt          int64  `json:"updated_at" gorm:"bigint;index"`
+	// 命中额度桶时为 user_subscription_buckets.id，PreConsumed 按桶的计量单位记录
+	UserSubscriptionBucketId int   `json:"user_subscription_bucket_id" gorm:"default:0"`
+	PreConsumed              int64 `json:"pre_consumed" gorm:"type:bigint;not null;default:0"`
+	// 组织订阅预扣时计入成员已用额度的额度，退款时一并退还
+	OrganizationId    int    `json:"organization_id" gorm:"default:0"`
+	OrganizationSpend int64  `json:"organization_spend" gorm:"type:bigint;not null;default:0"`
+	Status            string `json:"status" gorm:"type:varchar(32);index"` // consumed/refunded
+	CreatedAt         int64  `json:"created_at" gorm:"bigint"`
+	UpdatedAt         int64  `json:"updated_at" gorm:"bigint;index"`
 }
 
 func (r *SubscriptionPreConsumeRecord) BeforeCreate(tx *gorm.DB) error {
@@ -1278,14 +1375,35 @@ func maybeResetUserSubscriptionWithPlanTx(tx *gorm.DB, sub *UserSubscription, pl
 		}
 		return nil
 	}
+	usedBefore := sub.AmountUsed
 	sub.AmountUsed = 0
 	sub.LastResetTime = base.Unix()
 	sub.NextResetTime = next
-	return tx.Save(sub).Error
+	if err := tx.Save(sub).Error; err != nil {
+		return err
+	}
+	return postSubscriptionResetLedgerTx(tx, sub, usedBefore)
 }
 
-// PreConsumeUserSubscription pre-consumes from any active subscription total quota.
-func PreConsumeUserSubscription(requestId string, userId int, modelName string, quotaType int, amount int64) (*SubscriptionPreConsumeResult, error) {
+// SubscriptionPreConsumeParams describes one subscription pre-consume.
+type SubscriptionPreConsumeParams struct {
+	RequestId string
+	UserId    int
+	// OrganizationId > 0 consumes the organization's subscriptions and charges the member spend limit
+	OrganizationId int
+	ModelName      string
+	// Amount is the quota to reserve from the plan total or a quota-measured bucket
+	Amount int64
+	// EstimatedTokens is reserved from token-measured buckets and settled later
+	EstimatedTokens int64
+}
+
+// PreConsumeUserSubscription pre-consumes from the first active subscription that can cover the request.
+// Requests matching a plan quota bucket consume that bucket; others consume the plan total quota.
+func PreConsumeUserSubscription(params SubscriptionPreConsumeParams) (*SubscriptionPreConsumeResult, error) {
+	requestId := params.RequestId
+	userId := params.UserId
+	amount := params.Amount
 	if userId <= 0 {
 		return nil, errors.New("invalid userId")
 	}
@@ -1299,6 +1417,30 @@ func PreConsumeUserSubscription(requestId string, userId int, modelName string,
 
 	returnValue := &SubscriptionPreConsumeResult{}
 
+	// fillExisting 处理同一 requestId 的重复预扣，返回已有记录的结果
+	fillExisting := func(tx *gorm.DB, existing *SubscriptionPreConsumeRecord) error {
+		if existing.Status == "refunded" {
+			return errors.New("subscription pre-consume already refunded")
+		}
+		var sub UserSubscription
+		if err := tx.Where("id = ?", existing.UserSubscriptionId).First(&sub).Error; err != nil {
+			return err
+		}
+		returnValue.UserSubscriptionId = sub.Id
+		returnValue.PreConsumed = existing.PreConsumed
+		returnValue.AmountTotal = sub.AmountTotal
+		returnValue.AmountUsedBefore = sub.AmountUsed
+		returnValue.AmountUsedAfter = sub.AmountUsed
+		if existing.UserSubscriptionBucketId > 0 {
+			var row UserSubscriptionBucket
+			if err := tx.Where("id = ?", existing.UserSubscriptionBucketId).First(&row).Error; err != nil {
+				return err
+			}
+			returnValue.fillBucket(&row, row.AmountUsed)
+		}
+		return nil
+	}
+
 	err := DB.Transaction(func(tx *gorm.DB) error {
 		var existing SubscriptionPreConsumeRecord
 		query := tx.Where("request_id = ?", requestId).Limit(1).Find(&existing)
@@ -1306,26 +1448,21 @@ func PreConsumeUserSubscription(requestId string, userId int, modelName string,
 			return query.Error
 		}
 		if query.RowsAffected > 0 {
-			if existing.Status == "refunded" {
-				return errors.New("subscription pre-consume already refunded")
-			}
-			var sub UserSubscription
-			if err := tx.Where("id = ?", existing.UserSubscriptionId).First(&sub).Error; err != nil {
-				return err
-			}
-			returnValue.UserSubscriptionId = sub.Id
-			returnValue.PreConsumed = existing.PreConsumed
-			returnValue.AmountTotal = sub.AmountTotal
-			returnValue.AmountUsedBefore = sub.AmountUsed
-			returnValue.AmountUsedAfter = sub.AmountUsed
-			return nil
+			return fillExisting(tx, &existing)
 		}
 
+		subQuery := lockForUpdate(tx).Where("status = ? AND end_time > ?", "active", now)
+		if params.OrganizationId > 0 {
+			var org Organization
+			if err := tx.Select("id", "status").First(&org, "id = ?", params.OrganizationId).Error; err != nil || org.Status != OrganizationStatusEnabled {
+				return ErrOrganizationNotFound
+			}
+			subQuery = subQuery.Where("organization_id = ?", params.OrganizationId)
+		} else {
+			subQuery = subQuery.Where("user_id = ? AND organization_id = 0", userId)
+		}
 		var subs []UserSubscription
-		if err := lockForUpdate(tx).
-			Where("user_id = ? AND status = ? AND end_time > ?", userId, "active", now).
-			Order("end_time asc, id asc").
-			Find(&subs).Error; err != nil {
+		if err := subQuery.Order("end_time asc, id asc").Find(&subs).Error; err != nil {
 			return errors.New("no active subscription")
 		}
 		if len(subs) == 0 {
@@ -1340,41 +1477,77 @@ func PreConsumeUserSubscription(requestId string, userId int, modelName string,
 			if err := maybeResetUserSubscriptionWithPlanTx(tx, &sub, plan, now); err != nil {
 				return err
 			}
-			usedBefore := sub.AmountUsed
-			if sub.AmountTotal > 0 {
-				remain := sub.AmountTotal - usedBefore
-				if remain < amount {
+			bucket, err := matchSubscriptionQuotaBucketTx(tx, plan, params.ModelName)
+			if err != nil {
+				return err
+			}
+			if bucket == nil && plan.BucketsOnly {
+				continue
+			}
+
+			var bucketRow *UserSubscriptionBucket
+			consume := amount
+			if bucket != nil {
+				bucketRow, err = getOrCreateUserSubscriptionBucketTx(tx, &sub, bucket, now)
+				if err != nil {
+					return err
+				}
+				if err := maybeResetUserSubscriptionBucketTx(tx, bucketRow, sub.EndTime, now); err != nil {
+					return err
+				}
+				consume = subscriptionBucketConsumeAmount(bucketRow.Unit, amount, params.EstimatedTokens)
+				if bucketRow.AmountTotal > 0 && bucketRow.AmountTotal-bucketRow.AmountUsed < consume {
 					continue
 				}
+			} else if sub.AmountTotal > 0 && sub.AmountTotal-sub.AmountUsed < amount {
+				continue
 			}
+
 			record := &SubscriptionPreConsumeRecord{
 				RequestId:          requestId,
 				UserId:             userId,
 				UserSubscriptionId: sub.Id,
-				PreConsumed:        amount,
+				PreConsumed:        consume,
 				Status:             "consumed",
 			}
+			if bucketRow != nil {
+				record.UserSubscriptionBucketId = bucketRow.Id
+			}
+			if params.OrganizationId > 0 {
+				// 组织订阅同样受成员消费上限约束，按额度计入成员已用额度
+				if err := chargeOrganizationMemberSpendTx(tx, params.OrganizationId, userId, int(amount)); err != nil {
+					return err
+				}
+				record.OrganizationId = params.OrganizationId
+				record.OrganizationSpend = amount
+			}
 			if err := tx.Create(record).Error; err != nil {
 				var dup SubscriptionPreConsumeRecord
 				if err2 := tx.Where("request_id = ?", requestId).First(&dup).Error; err2 == nil {
-					if dup.Status == "refunded" {
-						return errors.New("subscription pre-consume already refunded")
-					}
-					returnValue.UserSubscriptionId = sub.Id
-					returnValue.PreConsumed = dup.PreConsumed
-					returnValue.AmountTotal = sub.AmountTotal
-					returnValue.AmountUsedBefore = sub.AmountUsed
-					returnValue.AmountUsedAfter = sub.AmountUsed
-					return nil
+					return fillExisting(tx, &dup)
 				}
 				return err
 			}
+
+			returnValue.UserSubscriptionId = sub.Id
+			returnValue.PreConsumed = consume
+			if bucketRow != nil {
+				usedBefore := bucketRow.AmountUsed
+				bucketRow.AmountUsed += consume
+				if err := tx.Save(bucketRow).Error; err != nil {
+					return err
+				}
+				returnValue.fillBucket(bucketRow, usedBefore)
+				return nil
+			}
+			usedBefore := sub.AmountUsed
 			sub.AmountUsed += amount
 			if err := tx.Save(&sub).Error; err != nil {
 				return err
 			}
-			returnValue.UserSubscriptionId = sub.Id
-			returnValue.PreConsumed = amount
+			if err := PostQuotaLedgerTx(tx, subscriptionQuotaLedgerPosting(&sub, -amount, QuotaLedgerRefRequestId, requestId)); err != nil {
+				return err
+			}
 			returnValue.AmountTotal = sub.AmountTotal
 			returnValue.AmountUsedBefore = usedBefore
 			returnValue.AmountUsedAfter = sub.AmountUsed
@@ -1406,9 +1579,18 @@ func RefundSubscriptionPreConsume(requestId string) error {
 			record.Status = "refunded"
 			return tx.Save(&record).Error
 		}
-		if err := PostConsumeUserSubscriptionDelta(record.UserSubscriptionId, -record.PreConsumed); err != nil {
+		if record.UserSubscriptionBucketId > 0 {
+			if _, err := adjustUserSubscriptionBucketTx(tx, record.UserSubscriptionBucketId, "", -record.PreConsumed); err != nil {
+				return err
+			}
+		} else if err := PostConsumeUserSubscriptionDelta(record.UserSubscriptionId, -record.PreConsumed, QuotaLedgerRefRequestId, requestId); err != nil {
 			return err
 		}
+		if record.OrganizationId > 0 && record.OrganizationSpend > 0 {
+			if err := settleOrganizationMemberSpendTx(tx, record.OrganizationId, record.UserId, -int(record.OrganizationSpend)); err != nil {
+				return err
+			}
+		}
 		record.Status = "refunded"
 		return tx.Save(&record).Error
 	})
@@ -1497,7 +1679,8 @@ func GetSubscriptionPlanInfoByUserSubscriptionId(userSubscriptionId int) (*Subsc
 }
 
 // Update subscription used amount by delta (positive consume more, negative refund).
-func PostConsumeUserSubscriptionDelta(userSubscriptionId int, delta int64) error {
+// refType/refId identify the request or task in the quota ledger.
+func PostConsumeUserSubscriptionDelta(userSubscriptionId int, delta int64, refType string, refId string) error {
 	if userSubscriptionId <= 0 {
 		return errors.New("invalid userSubscriptionId")
 	}
@@ -1518,7 +1701,39 @@ func PostConsumeUserSubscriptionDelta(userSubscriptionId int, delta int64) error
 		if sub.AmountTotal > 0 && newUsed > sub.AmountTotal {
 			return fmt.Errorf("subscription used exceeds total, used=%d total=%d", newUsed, sub.AmountTotal)
 		}
+		usedBefore := sub.AmountUsed
 		sub.AmountUsed = newUsed
-		return tx.Save(&sub).Error
+		if err := tx.Save(&sub).Error; err != nil {
+			return err
+		}
+		return PostQuotaLedgerTx(tx, subscriptionQuotaLedgerPosting(&sub, usedBefore-newUsed, refType, refId))
 	})
 }
+
+// postSubscriptionResetLedgerTx 把周期重置恢复的额度记入订阅账户。
+func postSubscriptionResetLedgerTx(tx *gorm.DB, sub *UserSubscription, usedBefore int64) error {
+	return PostQuotaLedgerTx(tx, QuotaLedgerPosting{
+		Account: QuotaLedgerSubscriptionAccount(sub.Id),
+		UserId:  sub.UserId,
+		Amount:  usedBefore,
+		Reason:  QuotaLedgerReasonSubscriptionReset,
+		RefType: QuotaLedgerRefUserSubscription,
+		RefId:   strconv.Itoa(sub.Id),
+	})
+}
+
+// subscriptionQuotaLedgerPosting 构造记入订阅额度账户的记账，amount 为剩余额度的变化（负数为消耗）。
+func subscriptionQuotaLedgerPosting(sub *UserSubscription, amount int64, refType string, refId string) QuotaLedgerPosting {
+	reason := QuotaLedgerReasonSubscriptionConsume
+	if amount > 0 {
+		reason = QuotaLedgerReasonSubscriptionRefund
+	}
+	return QuotaLedgerPosting{
+		Account: QuotaLedgerSubscriptionAccount(sub.Id),
+		UserId:  sub.UserId,
+		Amount:  amount,
+		Reason:  reason,
+		RefType: refType,
+		RefId:   refId,
+	}
+}
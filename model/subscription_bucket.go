@@ -0,0 +1,464 @@
+package model
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Subscription quota bucket units
+const (
+	SubscriptionBucketUnitQuota    = "quota"
+	SubscriptionBucketUnitRequests = "requests"
+	SubscriptionBucketUnitTokens   = "tokens"
+)
+
+// SubscriptionQuotaBucket 套餐内按模型划分的额度桶。命中桶的请求只从桶中扣减，不占用套餐总额度；
+// 例如 "每月 500 次 Opus 请求 + 不限量 Haiku" 可以配置为两个按次计量的桶。
+type SubscriptionQuotaBucket struct {
+	Key        string   `json:"key"`
+	Name       string   `json:"name"`
+	Models     []string `json:"models,omitempty"`      // 模型名，支持 * 通配，如 claude-*-opus*
+	ModelGroup string   `json:"model_group,omitempty"` // 模型预填组名称（prefill_groups.type = model）
+	Unit       string   `json:"unit"`                  // quota / requests / tokens
+	Amount     int64    `json:"amount"`                // 每个周期的额度，0 表示不限
+	// 桶的独立重置周期，取值同套餐的 quota_reset_period
+	ResetPeriod        string `json:"reset_period"`
+	ResetCustomSeconds int64  `json:"reset_custom_seconds"`
+}
+
+type SubscriptionQuotaBuckets []SubscriptionQuotaBucket
+
+// Value implements driver.Valuer interface
+func (b SubscriptionQuotaBuckets) Value() (driver.Value, error) {
+	if len(b) == 0 {
+		return "", nil
+	}
+	data, err := common.Marshal(b)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+// Scan implements sql.Scanner interface
+func (b *SubscriptionQuotaBuckets) Scan(value interface{}) error {
+	var data []byte
+	switch v := value.(type) {
+	case nil:
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return fmt.Errorf("unsupported quota buckets type: %T", value)
+	}
+	if len(strings.TrimSpace(string(data))) == 0 {
+		*b = nil
+		return nil
+	}
+	return common.Unmarshal(data, b)
+}
+
+// NormalizeSubscriptionQuotaBuckets 校验并规范化套餐的额度桶配置
+func NormalizeSubscriptionQuotaBuckets(buckets SubscriptionQuotaBuckets) (SubscriptionQuotaBuckets, error) {
+	if len(buckets) == 0 {
+		return nil, nil
+	}
+	seen := make(map[string]struct{}, len(buckets))
+	result := make(SubscriptionQuotaBuckets, 0, len(buckets))
+	for i, bucket := range buckets {
+		bucket.Key = strings.TrimSpace(bucket.Key)
+		if bucket.Key == "" {
+			bucket.Key = fmt.Sprintf("bucket_%d", i+1)
+		}
+		if len(bucket.Key) > 64 {
+			return nil, fmt.Errorf("额度桶标识过长: %s", bucket.Key)
+		}
+		if _, ok := seen[bucket.Key]; ok {
+			return nil, fmt.Errorf("额度桶标识重复: %s", bucket.Key)
+		}
+		seen[bucket.Key] = struct{}{}
+		bucket.Name = strings.TrimSpace(bucket.Name)
+		if bucket.Name == "" {
+			bucket.Name = bucket.Key
+		}
+		models := make([]string, 0, len(bucket.Models))
+		for _, m := range bucket.Models {
+			if m = strings.TrimSpace(m); m != "" {
+				models = append(models, m)
+			}
+		}
+		bucket.Models = models
+		bucket.ModelGroup = strings.TrimSpace(bucket.ModelGroup)
+		if len(bucket.Models) == 0 && bucket.ModelGroup == "" {
+			return nil, fmt.Errorf("额度桶 %s 需要配置模型或模型组", bucket.Key)
+		}
+		switch bucket.Unit {
+		case "":
+			bucket.Unit = SubscriptionBucketUnitQuota
+		case SubscriptionBucketUnitQuota, SubscriptionBucketUnitRequests, SubscriptionBucketUnitTokens:
+		default:
+			return nil, fmt.Errorf("额度桶 %s 的计量单位无效: %s", bucket.Key, bucket.Unit)
+		}
+		if bucket.Amount < 0 {
+			return nil, fmt.Errorf("额度桶 %s 的额度不能为负数", bucket.Key)
+		}
+		bucket.ResetPeriod = NormalizeResetPeriod(bucket.ResetPeriod)
+		if bucket.ResetPeriod == SubscriptionResetCustom && bucket.ResetCustomSeconds <= 0 {
+			return nil, fmt.Errorf("额度桶 %s 的自定义重置周期需大于0秒", bucket.Key)
+		}
+		if bucket.ResetPeriod != SubscriptionResetCustom {
+			bucket.ResetCustomSeconds = 0
+		}
+		result = append(result, bucket)
+	}
+	return result, nil
+}
+
+// matchSubscriptionModelPattern 匹配模型名，pattern 中的 * 匹配任意字符
+func matchSubscriptionModelPattern(pattern string, modelName string) bool {
+	if !strings.Contains(pattern, "*") {
+		return pattern == modelName
+	}
+	parts := strings.Split(pattern, "*")
+	if !strings.HasPrefix(modelName, parts[0]) {
+		return false
+	}
+	rest := modelName[len(parts[0]):]
+	last := parts[len(parts)-1]
+	for _, part := range parts[1 : len(parts)-1] {
+		idx := strings.Index(rest, part)
+		if idx < 0 {
+			return false
+		}
+		rest = rest[idx+len(part):]
+	}
+	return len(rest) >= len(last) && strings.HasSuffix(rest, last)
+}
+
+func getModelPrefillGroupItemsTx(tx *gorm.DB, name string) ([]string, error) {
+	var group PrefillGroup
+	err := tx.Where("name = ? AND type = ?", name, "model").Limit(1).Find(&group).Error
+	if err != nil || group.Id == 0 || len(group.Items) == 0 {
+		return nil, err
+	}
+	var items []string
+	if err := common.Unmarshal(group.Items, &items); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// matchSubscriptionQuotaBucketTx 返回套餐中第一个覆盖 modelName 的额度桶，没有命中时返回 nil
+func matchSubscriptionQuotaBucketTx(tx *gorm.DB, plan *SubscriptionPlan, modelName string) (*SubscriptionQuotaBucket, error) {
+	if plan == nil || len(plan.QuotaBuckets) == 0 || modelName == "" {
+		return nil, nil
+	}
+	for i := range plan.QuotaBuckets {
+		bucket := &plan.QuotaBuckets[i]
+		for _, pattern := range bucket.Models {
+			if matchSubscriptionModelPattern(pattern, modelName) {
+				return bucket, nil
+			}
+		}
+		if bucket.ModelGroup == "" {
+			continue
+		}
+		items, err := getModelPrefillGroupItemsTx(tx, bucket.ModelGroup)
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range items {
+			if matchSubscriptionModelPattern(strings.TrimSpace(item), modelName) {
+				return bucket, nil
+			}
+		}
+	}
+	return nil, nil
+}
+
+// UserSubscriptionBucket 记录用户订阅在某个额度桶上的用量，额度与重置周期在创建时从套餐快照
+type UserSubscriptionBucket struct {
+	Id                 int    `json:"id"`
+	UserSubscriptionId int    `json:"user_subscription_id" gorm:"uniqueIndex:idx_user_sub_bucket,priority:1"`
+	UserId             int    `json:"user_id" gorm:"index"`
+	BucketKey          string `json:"bucket_key" gorm:"type:varchar(64);uniqueIndex:idx_user_sub_bucket,priority:2"`
+	Name               string `json:"name" gorm:"type:varchar(128);default:''"`
+	Unit               string `json:"unit" gorm:"type:varchar(16);not null;default:'quota'"`
+
+	AmountTotal int64 `json:"amount_total" gorm:"type:bigint;not null;default:0"`
+	AmountUsed  int64 `json:"amount_used" gorm:"type:bigint;not null;default:0"`
+
+	ResetPeriod        string `json:"reset_period" gorm:"type:varchar(16);default:'never'"`
+	ResetCustomSeconds int64  `json:"reset_custom_seconds" gorm:"type:bigint;default:0"`
+	LastResetTime      int64  `json:"last_reset_time" gorm:"type:bigint;default:0"`
+	NextResetTime      int64  `json:"next_reset_time" gorm:"type:bigint;default:0;index"`
+
+	CreatedAt int64 `json:"created_at" gorm:"bigint"`
+	UpdatedAt int64 `json:"updated_at" gorm:"bigint"`
+}
+
+func (b *UserSubscriptionBucket) BeforeCreate(tx *gorm.DB) error {
+	now := common.GetTimestamp()
+	b.CreatedAt = now
+	b.UpdatedAt = now
+	return nil
+}
+
+func (b *UserSubscriptionBucket) BeforeUpdate(tx *gorm.DB) error {
+	b.UpdatedAt = common.GetTimestamp()
+	return nil
+}
+
+func newUserSubscriptionBucket(sub *UserSubscription, bucket *SubscriptionQuotaBucket, now int64) *UserSubscriptionBucket {
+	row := &UserSubscriptionBucket{
+		UserSubscriptionId: sub.Id,
+		UserId:             sub.UserId,
+		BucketKey:          bucket.Key,
+		Name:               bucket.Name,
+		Unit:               bucket.Unit,
+		AmountTotal:        bucket.Amount,
+		ResetPeriod:        NormalizeResetPeriod(bucket.ResetPeriod),
+		ResetCustomSeconds: bucket.ResetCustomSeconds,
+	}
+	if row.Unit == "" {
+		row.Unit = SubscriptionBucketUnitQuota
+	}
+	row.NextResetTime = calcNextResetTimeByPeriod(time.Unix(now, 0), row.ResetPeriod, row.ResetCustomSeconds, sub.EndTime)
+	if row.NextResetTime > 0 {
+		row.LastResetTime = now
+	}
+	return row
+}
+
+// createUserSubscriptionBucketsTx 为新订阅创建套餐中配置的全部额度桶
+func createUserSubscriptionBucketsTx(tx *gorm.DB, sub *UserSubscription, plan *SubscriptionPlan) error {
+	if len(plan.QuotaBuckets) == 0 {
+		return nil
+	}
+	rows := make([]*UserSubscriptionBucket, 0, len(plan.QuotaBuckets))
+	for i := range plan.QuotaBuckets {
+		rows = append(rows, newUserSubscriptionBucket(sub, &plan.QuotaBuckets[i], sub.StartTime))
+	}
+	return tx.Create(&rows).Error
+}
+
+// getOrCreateUserSubscriptionBucketTx 锁定订阅在某个额度桶上的用量记录；
+// 套餐在用户购买后新增的桶在首次使用时创建。
+func getOrCreateUserSubscriptionBucketTx(tx *gorm.DB, sub *UserSubscription, bucket *SubscriptionQuotaBucket, now int64) (*UserSubscriptionBucket, error) {
+	var row UserSubscriptionBucket
+	query := lockForUpdate(tx).
+		Where("user_subscription_id = ? AND bucket_key = ?", sub.Id, bucket.Key).
+		Limit(1).Find(&row)
+	if query.Error != nil {
+		return nil, query.Error
+	}
+	if query.RowsAffected > 0 {
+		return &row, nil
+	}
+	created := newUserSubscriptionBucket(sub, bucket, now)
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(created).Error; err != nil {
+		return nil, err
+	}
+	if err := lockForUpdate(tx).
+		Where("user_subscription_id = ? AND bucket_key = ?", sub.Id, bucket.Key).
+		First(&row).Error; err != nil {
+		return nil, err
+	}
+	return &row, nil
+}
+
+// maybeResetUserSubscriptionBucketTx 按桶自己的周期重置用量，逻辑与订阅总额度的重置一致
+func maybeResetUserSubscriptionBucketTx(tx *gorm.DB, row *UserSubscriptionBucket, endUnix int64, now int64) error {
+	if row.NextResetTime > 0 && row.NextResetTime > now {
+		return nil
+	}
+	if NormalizeResetPeriod(row.ResetPeriod) == SubscriptionResetNever {
+		return nil
+	}
+	baseUnix := row.LastResetTime
+	if baseUnix <= 0 {
+		baseUnix = row.CreatedAt
+	}
+	base := time.Unix(baseUnix, 0)
+	next := calcNextResetTimeByPeriod(base, row.ResetPeriod, row.ResetCustomSeconds, endUnix)
+	advanced := false
+	for next > 0 && next <= now {
+		advanced = true
+		base = time.Unix(next, 0)
+		next = calcNextResetTimeByPeriod(base, row.ResetPeriod, row.ResetCustomSeconds, endUnix)
+	}
+	if !advanced {
+		if row.NextResetTime == 0 && next > 0 {
+			row.NextResetTime = next
+			row.LastResetTime = base.Unix()
+			return tx.Save(row).Error
+		}
+		return nil
+	}
+	row.AmountUsed = 0
+	row.LastResetTime = base.Unix()
+	row.NextResetTime = next
+	return tx.Save(row).Error
+}
+
+// subscriptionBucketConsumeAmount 换算一次请求在桶计量单位下的预扣量
+func subscriptionBucketConsumeAmount(unit string, quota int64, estimatedTokens int64) int64 {
+	switch unit {
+	case SubscriptionBucketUnitRequests:
+		return 1
+	case SubscriptionBucketUnitTokens:
+		if estimatedTokens <= 0 {
+			return 1
+		}
+		return estimatedTokens
+	default:
+		return quota
+	}
+}
+
+// adjustUserSubscriptionBucketTx 调整额度桶用量（正数为消耗，负数为退还）。
+// 按额度计量的桶超出上限时报错，与订阅总额度一致；按 token 计量时预扣只是估算，超出时按上限记为用尽。
+func adjustUserSubscriptionBucketTx(tx *gorm.DB, bucketId int, unit string, delta int64) (*UserSubscriptionBucket, error) {
+	var row UserSubscriptionBucket
+	if err := lockForUpdate(tx).Where("id = ?", bucketId).First(&row).Error; err != nil {
+		return nil, err
+	}
+	if unit != "" && row.Unit != unit {
+		return &row, nil
+	}
+	newUsed := row.AmountUsed + delta
+	if newUsed < 0 {
+		newUsed = 0
+	}
+	if row.AmountTotal > 0 && newUsed > row.AmountTotal {
+		if row.Unit != SubscriptionBucketUnitTokens {
+			return nil, fmt.Errorf("subscription bucket used exceeds total, used=%d total=%d", newUsed, row.AmountTotal)
+		}
+		newUsed = row.AmountTotal
+	}
+	if newUsed == row.AmountUsed {
+		return &row, nil
+	}
+	row.AmountUsed = newUsed
+	if err := tx.Save(&row).Error; err != nil {
+		return nil, err
+	}
+	return &row, nil
+}
+
+// PostConsumeUserSubscriptionBucketDelta 按 unit 调整额度桶用量；桶的计量单位与 unit 不同时不做调整。
+// 额度桶独立于订阅总额度，不记入额度账本。
+func PostConsumeUserSubscriptionBucketDelta(bucketId int, unit string, delta int64) error {
+	if bucketId <= 0 {
+		return errors.New("invalid bucketId")
+	}
+	if delta == 0 {
+		return nil
+	}
+	return DB.Transaction(func(tx *gorm.DB) error {
+		_, err := adjustUserSubscriptionBucketTx(tx, bucketId, unit, delta)
+		return err
+	})
+}
+
+// PostConsumeUserSubscriptionQuotaDelta 按额度差值调整订阅用量：请求命中额度桶时调整该桶
+// （仅按额度计量的桶随额度变化），否则调整订阅总额度。
+func PostConsumeUserSubscriptionQuotaDelta(userSubscriptionId int, bucketId int, delta int64, refType string, refId string) error {
+	if bucketId > 0 {
+		return PostConsumeUserSubscriptionBucketDelta(bucketId, SubscriptionBucketUnitQuota, delta)
+	}
+	return PostConsumeUserSubscriptionDelta(userSubscriptionId, delta, refType, refId)
+}
+
+// GetUserSubscriptionBucketsBySubscriptionIds 按订阅 ID 分组返回额度桶用量
+func GetUserSubscriptionBucketsBySubscriptionIds(subscriptionIds []int) (map[int][]UserSubscriptionBucket, error) {
+	result := make(map[int][]UserSubscriptionBucket)
+	if len(subscriptionIds) == 0 {
+		return result, nil
+	}
+	var rows []UserSubscriptionBucket
+	if err := DB.Where("user_subscription_id IN ?", subscriptionIds).Order("id asc").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		result[row.UserSubscriptionId] = append(result[row.UserSubscriptionId], row)
+	}
+	return result, nil
+}
+
+// resetUserSubscriptionBucketsTx 管理员重置订阅时同时清空其额度桶用量
+func resetUserSubscriptionBucketsTx(tx *gorm.DB, sub *UserSubscription, now int64, advanceResetTime bool) error {
+	var rows []UserSubscriptionBucket
+	if err := tx.Where("user_subscription_id = ?", sub.Id).Find(&rows).Error; err != nil {
+		return err
+	}
+	for i := range rows {
+		row := &rows[i]
+		row.AmountUsed = 0
+		if advanceResetTime {
+			row.NextResetTime = calcNextResetTimeByPeriod(time.Unix(now, 0), row.ResetPeriod, row.ResetCustomSeconds, sub.EndTime)
+			if row.NextResetTime > 0 {
+				row.LastResetTime = now
+			} else {
+				row.LastResetTime = 0
+			}
+		}
+		if err := tx.Save(row).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ResetDueSubscriptionBuckets resets quota buckets whose next_reset_time has passed.
+func ResetDueSubscriptionBuckets(limit int) (int, error) {
+	if limit <= 0 {
+		limit = 200
+	}
+	now := GetDBTimestamp()
+	var rows []UserSubscriptionBucket
+	if err := DB.Where("next_reset_time > 0 AND next_reset_time <= ?", now).
+		Order("next_reset_time asc").
+		Limit(limit).
+		Find(&rows).Error; err != nil {
+		return 0, err
+	}
+	resetCount := 0
+	for _, row := range rows {
+		rowId := row.Id
+		err := DB.Transaction(func(tx *gorm.DB) error {
+			var locked UserSubscriptionBucket
+			if err := lockForUpdate(tx).
+				Where("id = ? AND next_reset_time > 0 AND next_reset_time <= ?", rowId, now).
+				First(&locked).Error; err != nil {
+				return nil
+			}
+			var sub UserSubscription
+			if err := tx.Select("id", "end_time", "status").Where("id = ?", locked.UserSubscriptionId).First(&sub).Error; err != nil {
+				return nil
+			}
+			if sub.Status != "active" {
+				// 订阅已失效的桶不再重置
+				locked.NextResetTime = 0
+				return tx.Save(&locked).Error
+			}
+			if err := maybeResetUserSubscriptionBucketTx(tx, &locked, sub.EndTime, now); err != nil {
+				return err
+			}
+			resetCount++
+			return nil
+		})
+		if err != nil {
+			return resetCount, err
+		}
+	}
+	return resetCount, nil
+}
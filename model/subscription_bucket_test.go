@@ -0,0 +1,208 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func seedBucketSubscription(t *testing.T, userId int, plan *SubscriptionPlan) *UserSubscription {
+	t.Helper()
+	buckets, err := NormalizeSubscriptionQuotaBuckets(plan.QuotaBuckets)
+	require.NoError(t, err)
+	plan.QuotaBuckets = buckets
+	require.NoError(t, DB.Create(plan).Error)
+	InvalidateSubscriptionPlanCache(plan.Id)
+	t.Cleanup(func() { InvalidateSubscriptionPlanCache(plan.Id) })
+
+	sub, err := CreateUserSubscriptionFromPlanTx(DB, userId, plan, "admin")
+	require.NoError(t, err)
+	return sub
+}
+
+func getSubscriptionBucket(t *testing.T, subId int, key string) UserSubscriptionBucket {
+	t.Helper()
+	var row UserSubscriptionBucket
+	require.NoError(t, DB.Where("user_subscription_id = ? AND bucket_key = ?", subId, key).First(&row).Error)
+	return row
+}
+
+func TestPreConsumeUserSubscriptionRoutesRequestsToQuotaBuckets(t *testing.T) {
+	truncateTables(t)
+
+	require.NoError(t, (&PrefillGroup{Name: "haiku-models", Type: "model", Items: JSONValue(`["claude-3-haiku"]`)}).Insert())
+	sub := seedBucketSubscription(t, 301, &SubscriptionPlan{
+		Id:            9301,
+		Title:         "Opus bundle",
+		DurationUnit:  SubscriptionDurationMonth,
+		DurationValue: 1,
+		TotalAmount:   1000,
+		QuotaBuckets: SubscriptionQuotaBuckets{
+			{Key: "opus", Name: "Opus", Models: []string{"claude-*opus*"}, Unit: SubscriptionBucketUnitRequests, Amount: 2, ResetPeriod: SubscriptionResetMonthly},
+			{Key: "haiku", ModelGroup: "haiku-models"},
+		},
+	})
+
+	// 套餐创建时即生成全部额度桶
+	buckets, err := GetUserSubscriptionBucketsBySubscriptionIds([]int{sub.Id})
+	require.NoError(t, err)
+	require.Len(t, buckets[sub.Id], 2)
+	assert.NotZero(t, buckets[sub.Id][0].NextResetTime)
+	assert.Zero(t, buckets[sub.Id][1].NextResetTime)
+
+	res, err := PreConsumeUserSubscription(SubscriptionPreConsumeParams{RequestId: "opus-1", UserId: 301, ModelName: "claude-3-opus-20240229", Amount: 300})
+	require.NoError(t, err)
+	assert.Equal(t, sub.Id, res.UserSubscriptionId)
+	assert.Equal(t, "opus", res.BucketKey)
+	assert.Equal(t, SubscriptionBucketUnitRequests, res.BucketUnit)
+	assert.EqualValues(t, 1, res.PreConsumed)
+	assert.Zero(t, res.AmountTotal)
+
+	_, err = PreConsumeUserSubscription(SubscriptionPreConsumeParams{RequestId: "opus-2", UserId: 301, ModelName: "claude-opus-4", Amount: 300})
+	require.NoError(t, err)
+	_, err = PreConsumeUserSubscription(SubscriptionPreConsumeParams{RequestId: "opus-3", UserId: 301, ModelName: "claude-opus-4", Amount: 300})
+	require.ErrorContains(t, err, "subscription quota insufficient")
+	assert.EqualValues(t, 2, getSubscriptionBucket(t, sub.Id, "opus").AmountUsed)
+
+	// 模型组命中不限量桶，按额度计量
+	res, err = PreConsumeUserSubscription(SubscriptionPreConsumeParams{RequestId: "haiku-1", UserId: 301, ModelName: "claude-3-haiku", Amount: 5000})
+	require.NoError(t, err)
+	assert.Equal(t, "haiku", res.BucketKey)
+	assert.EqualValues(t, 5000, getSubscriptionBucket(t, sub.Id, "haiku").AmountUsed)
+	require.NoError(t, PostConsumeUserSubscriptionQuotaDelta(sub.Id, res.BucketId, -1000, QuotaLedgerRefRequestId, "haiku-1"))
+	assert.EqualValues(t, 4000, getSubscriptionBucket(t, sub.Id, "haiku").AmountUsed)
+
+	// 未命中任何桶的模型使用套餐总额度
+	res, err = PreConsumeUserSubscription(SubscriptionPreConsumeParams{RequestId: "gpt-1", UserId: 301, ModelName: "gpt-4o", Amount: 100})
+	require.NoError(t, err)
+	assert.Zero(t, res.BucketId)
+	assert.EqualValues(t, 1000, res.AmountTotal)
+	assert.EqualValues(t, 100, res.AmountUsedAfter)
+	assert.EqualValues(t, 100, getSubscriptionResetSub(t, sub.Id).AmountUsed)
+
+	// 幂等重放返回原来的桶
+	res, err = PreConsumeUserSubscription(SubscriptionPreConsumeParams{RequestId: "opus-1", UserId: 301, ModelName: "claude-3-opus-20240229", Amount: 300})
+	require.NoError(t, err)
+	assert.Equal(t, "opus", res.BucketKey)
+	assert.EqualValues(t, 2, getSubscriptionBucket(t, sub.Id, "opus").AmountUsed)
+
+	require.NoError(t, RefundSubscriptionPreConsume("opus-1"))
+	require.NoError(t, RefundSubscriptionPreConsume("opus-1"))
+	assert.EqualValues(t, 1, getSubscriptionBucket(t, sub.Id, "opus").AmountUsed)
+	assert.EqualValues(t, 100, getSubscriptionResetSub(t, sub.Id).AmountUsed)
+
+	summaries, err := GetAllActiveUserSubscriptions(301)
+	require.NoError(t, err)
+	require.Len(t, summaries, 1)
+	require.Len(t, summaries[0].Buckets, 2)
+	assert.Equal(t, "Opus", summaries[0].Buckets[0].Name)
+	assert.EqualValues(t, 1, summaries[0].Buckets[0].AmountUsed)
+}
+
+func TestPreConsumeUserSubscriptionBucketsOnlyAndTokenBuckets(t *testing.T) {
+	truncateTables(t)
+
+	sub := seedBucketSubscription(t, 302, &SubscriptionPlan{
+		Id:            9302,
+		Title:         "Tokens",
+		DurationUnit:  SubscriptionDurationMonth,
+		DurationValue: 1,
+		BucketsOnly:   true,
+		QuotaBuckets: SubscriptionQuotaBuckets{
+			{Key: "gpt", Models: []string{"gpt-4o*"}, Unit: SubscriptionBucketUnitTokens, Amount: 1000},
+		},
+	})
+
+	_, err := PreConsumeUserSubscription(SubscriptionPreConsumeParams{RequestId: "o3-1", UserId: 302, ModelName: "o3", Amount: 10})
+	require.ErrorContains(t, err, "subscription quota insufficient")
+
+	res, err := PreConsumeUserSubscription(SubscriptionPreConsumeParams{RequestId: "gpt-1", UserId: 302, ModelName: "gpt-4o-mini", Amount: 10, EstimatedTokens: 300})
+	require.NoError(t, err)
+	assert.EqualValues(t, 300, res.PreConsumed)
+
+	// 额度差值不影响按 token 计量的桶
+	require.NoError(t, PostConsumeUserSubscriptionQuotaDelta(sub.Id, res.BucketId, 50, QuotaLedgerRefRequestId, "gpt-1"))
+	assert.EqualValues(t, 300, getSubscriptionBucket(t, sub.Id, "gpt").AmountUsed)
+
+	// 实际 token 超出剩余额度时记为用尽
+	require.NoError(t, PostConsumeUserSubscriptionBucketDelta(res.BucketId, SubscriptionBucketUnitTokens, 900))
+	assert.EqualValues(t, 1000, getSubscriptionBucket(t, sub.Id, "gpt").AmountUsed)
+	_, err = PreConsumeUserSubscription(SubscriptionPreConsumeParams{RequestId: "gpt-2", UserId: 302, ModelName: "gpt-4o", Amount: 10, EstimatedTokens: 1})
+	require.ErrorContains(t, err, "subscription quota insufficient")
+	assert.Zero(t, getSubscriptionResetSub(t, sub.Id).AmountUsed)
+}
+
+func TestResetDueSubscriptionBucketsAndAdminReset(t *testing.T) {
+	truncateTables(t)
+
+	sub := seedBucketSubscription(t, 303, &SubscriptionPlan{
+		Id:            9303,
+		Title:         "Daily",
+		DurationUnit:  SubscriptionDurationMonth,
+		DurationValue: 1,
+		QuotaBuckets: SubscriptionQuotaBuckets{
+			{Key: "daily", Models: []string{"claude-*"}, Unit: SubscriptionBucketUnitRequests, Amount: 10, ResetPeriod: SubscriptionResetDaily},
+			{Key: "total", Models: []string{"gpt-*"}, Unit: SubscriptionBucketUnitRequests, Amount: 10},
+		},
+	})
+	now := GetDBTimestamp()
+	require.NoError(t, DB.Model(&UserSubscriptionBucket{}).Where("user_subscription_id = ?", sub.Id).
+		Updates(map[string]interface{}{"amount_used": 7, "last_reset_time": now - 2*86400, "next_reset_time": now - 86400}).Error)
+	require.NoError(t, DB.Model(&UserSubscriptionBucket{}).Where("bucket_key = ?", "total").
+		Updates(map[string]interface{}{"last_reset_time": 0, "next_reset_time": 0}).Error)
+
+	count, err := ResetDueSubscriptionBuckets(10)
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+	daily := getSubscriptionBucket(t, sub.Id, "daily")
+	assert.Zero(t, daily.AmountUsed)
+	assert.Greater(t, daily.NextResetTime, now)
+	assert.EqualValues(t, 7, getSubscriptionBucket(t, sub.Id, "total").AmountUsed)
+
+	_, err = AdminResetUserSubscriptionsByPlan(303, 9303, false)
+	require.NoError(t, err)
+	assert.Zero(t, getSubscriptionBucket(t, sub.Id, "total").AmountUsed)
+}
+
+func TestNormalizeSubscriptionQuotaBuckets(t *testing.T) {
+	buckets, err := NormalizeSubscriptionQuotaBuckets(SubscriptionQuotaBuckets{
+		{Models: []string{" claude-*opus* ", ""}},
+		{Key: "custom", ModelGroup: "g", Unit: SubscriptionBucketUnitTokens, ResetPeriod: "hourly"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "bucket_1", buckets[0].Key)
+	assert.Equal(t, "bucket_1", buckets[0].Name)
+	assert.Equal(t, []string{"claude-*opus*"}, buckets[0].Models)
+	assert.Equal(t, SubscriptionBucketUnitQuota, buckets[0].Unit)
+	assert.Equal(t, SubscriptionResetNever, buckets[1].ResetPeriod)
+
+	_, err = NormalizeSubscriptionQuotaBuckets(SubscriptionQuotaBuckets{{Key: "a", Models: []string{"x"}}, {Key: "a", Models: []string{"y"}}})
+	assert.Error(t, err)
+	_, err = NormalizeSubscriptionQuotaBuckets(SubscriptionQuotaBuckets{{Key: "a"}})
+	assert.Error(t, err)
+	_, err = NormalizeSubscriptionQuotaBuckets(SubscriptionQuotaBuckets{{Key: "a", Models: []string{"x"}, Unit: "minutes"}})
+	assert.Error(t, err)
+	_, err = NormalizeSubscriptionQuotaBuckets(SubscriptionQuotaBuckets{{Key: "a", Models: []string{"x"}, ResetPeriod: SubscriptionResetCustom}})
+	assert.Error(t, err)
+}
+
+func TestMatchSubscriptionModelPattern(t *testing.T) {
+	cases := []struct {
+		pattern string
+		model   string
+		want    bool
+	}{
+		{"gpt-4o", "gpt-4o", true},
+		{"gpt-4o", "gpt-4o-mini", false},
+		{"gpt-4o*", "gpt-4o-mini", true},
+		{"*opus*", "claude-3-opus-20240229", true},
+		{"claude-*-haiku", "claude-3-5-haiku", true},
+		{"claude-*-haiku", "claude-3-5-haiku-latest", false},
+		{"a*a", "a", false},
+		{"*", "anything/with/slash", true},
+	}
+	for _, tc := range cases {
+		assert.Equal(t, tc.want, matchSubscriptionModelPattern(tc.pattern, tc.model), "%s vs %s", tc.pattern, tc.model)
+	}
+}
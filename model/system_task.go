@@ -16,11 +16,16 @@ const (
 	SystemTaskStatusSucceeded SystemTaskStatus = "succeeded"
 	SystemTaskStatusFailed    SystemTaskStatus = "failed"
 
-	SystemTaskTypeLogCleanup     = "log_cleanup"
-	SystemTaskTypeChannelTest    = "channel_test"
-	SystemTaskTypeModelUpdate    = "model_update"
-	SystemTaskTypeMidjourneyPoll = "midjourney_poll"
-	SystemTaskTypeAsyncTaskPoll  = "async_task_poll"
+	SystemTaskTypeLogCleanup        = "log_cleanup"
+	SystemTaskTypeChannelTest       = "channel_test"
+	SystemTaskTypeModelUpdate       = "model_update"
+	SystemTaskTypeMidjourneyPoll    = "midjourney_poll"
+	SystemTaskTypeAsyncTaskPoll     = "async_task_poll"
+	SystemTaskTypePostpaidStatement = "postpaid_statement"
+	SystemTaskTypeCreditExpiry      = "credit_expiry"
+	SystemTaskTypeCurrencyRate      = "currency_rate"
+	SystemTaskTypeMediaRetention    = "media_retention"
+	SystemTaskTypeTaskCallback      = "task_callback_retry"
 )
 
 var ErrSystemTaskLockLost = errors.New("system task lock lost")
@@ -23,7 +23,7 @@ func (t TaskStatus) ToVideoStatus() string {
 		status = dto.VideoStatusInProgress
 	case TaskStatusSuccess:
 		status = dto.VideoStatusCompleted
-	case TaskStatusFailure:
+	case TaskStatusFailure, TaskStatusCancelled:
 		status = dto.VideoStatusFailed
 	default:
 		status = dto.VideoStatusUnknown // Default fallback
@@ -38,6 +38,7 @@ const (
 	TaskStatusInProgress            = "IN_PROGRESS"
 	TaskStatusFailure               = "FAILURE"
 	TaskStatusSuccess               = "SUCCESS"
+	TaskStatusCancelled             = "CANCELLED"
 	TaskStatusUnknown               = "UNKNOWN"
 )
 
@@ -105,11 +106,16 @@ type TaskPrivateData struct {
 	UpstreamTaskID string `json:"upstream_task_id,omitempty"` // 上游真实 task ID
 	ResultURL      string `json:"result_url,omitempty"`       // 任务成功后的结果 URL（视频地址等）
 	// 计费上下文：用于异步退款/差额结算（轮询阶段读取）
-	BillingSource  string              `json:"billing_source,omitempty"`  // "wallet" 或 "subscription"
-	SubscriptionId int                 `json:"subscription_id,omitempty"` // 订阅 ID，用于订阅退款
-	TokenId        int                 `json:"token_id,omitempty"`        // 令牌 ID，用于令牌额度退款
-	NodeName       string              `json:"node_name,omitempty"`       // 发起任务的节点名，轮询结算阶段据此归属日志而非最后查询节点
-	BillingContext *TaskBillingContext `json:"billing_context,omitempty"` // 计费参数快照（用于轮询阶段重新计算）
+	BillingSource              string              `json:"billing_source,omitempty"`               // "wallet"、"subscription" 或 "organization"
+	SubscriptionId             int                 `json:"subscription_id,omitempty"`              // 订阅 ID，用于订阅退款
+	SubscriptionBucketId       int                 `json:"subscription_bucket_id,omitempty"`       // 订阅额度桶 ID，命中套餐额度桶时用于结算与退款
+	SubscriptionBucketUnit     string              `json:"subscription_bucket_unit,omitempty"`     // 额度桶的计量单位
+	SubscriptionBucketConsumed int64               `json:"subscription_bucket_consumed,omitempty"` // 提交时按桶计量单位预扣的用量，全额退款时退还
+	OrganizationId             int                 `json:"organization_id,omitempty"`              // 组织 ID，用于组织钱包退款与组织订阅的成员额度调整
+	TokenId                    int                 `json:"token_id,omitempty"`                     // 令牌 ID，用于令牌额度退款
+	NodeName                   string              `json:"node_name,omitempty"`                    // 发起任务的节点名，轮询结算阶段据此归属日志而非最后查询节点
+	CallbackURL                string              `json:"callback_url,omitempty"`                 // 任务到达终态后推送通知的客户端地址
+	BillingContext             *TaskBillingContext `json:"billing_context,omitempty"`              // 计费参数快照（用于轮询阶段重新计算）
 }
 
 // TaskBillingContext 记录任务提交时的计费参数，以便轮询阶段可以重新计算额度。
@@ -120,6 +126,7 @@ type TaskBillingContext struct {
 	OtherRatios     map[string]float64 `json:"other_ratios,omitempty"`      // 附加倍率（时长、分辨率等）
 	OriginModelName string             `json:"origin_model_name,omitempty"` // 模型名称，必须为OriginModelName
 	PerCallBilling  bool               `json:"per_call_billing,omitempty"`  // 按次计费：跳过轮询阶段的差额结算
+	UpstreamCost    int                `json:"upstream_cost,omitempty"`     // 提交时已记录的上游成本，差额结算时扣除
 }
 
 // GetUpstreamTaskID 获取上游真实 task ID（用于与 provider 通信）
@@ -297,7 +304,7 @@ func TaskGetAllTasks(startIdx int, num int, queryParams SyncTaskQueryParams) []*
 func GetTimedOutUnfinishedTasks(cutoffUnix int64, limit int) []*Task {
 	var tasks []*Task
 	err := DB.Where("progress != ?", "100%").
-		Where("status NOT IN ?", []string{TaskStatusFailure, TaskStatusSuccess}).
+		Where("status NOT IN ?", []string{TaskStatusFailure, TaskStatusSuccess, TaskStatusCancelled}).
 		Where("submit_time < ?", cutoffUnix).
 		Order("submit_time").
 		Limit(limit).
@@ -308,7 +315,7 @@ func GetTimedOutUnfinishedTasks(cutoffUnix int64, limit int) []*Task {
 	return tasks
 }
 
-// GetUnrefundedFailedTasks returns failed tasks whose non-zero quota marks a
+// GetUnrefundedFailedTasks returns failed or cancelled tasks whose non-zero quota marks a
 // pending refund. Legacy timeout tasks are excluded before LIMIT is applied so
 // they cannot starve refundable tasks from the reconciliation sweep.
 func GetUnrefundedFailedTasks(updatedBefore int64, limit int) []*Task {
@@ -317,7 +324,7 @@ func GetUnrefundedFailedTasks(updatedBefore int64, limit int) []*Task {
 	}
 
 	var tasks []*Task
-	err := DB.Where("status = ?", TaskStatusFailure).
+	err := DB.Where("status IN ?", []string{TaskStatusFailure, TaskStatusCancelled}).
 		Where("quota != ?", 0).
 		Where("updated_at <= ?", updatedBefore).
 		Where("(submit_time <= ? OR submit_time >= ?)", 0, TaskRefundLegacyCutoff).
@@ -334,7 +341,7 @@ func GetAllUnFinishSyncTasks(limit int) []*Task {
 	var tasks []*Task
 	var err error
 	// get all tasks progress is not 100%
-	err = DB.Where("progress != ?", "100%").Where("status != ?", TaskStatusFailure).Where("status != ?", TaskStatusSuccess).Limit(limit).Order("id").Find(&tasks).Error
+	err = DB.Where("progress != ?", "100%").Where("status NOT IN ?", []string{TaskStatusFailure, TaskStatusSuccess, TaskStatusCancelled}).Limit(limit).Order("id").Find(&tasks).Error
 	if err != nil {
 		return nil
 	}
@@ -349,8 +356,7 @@ func HasUnfinishedSyncTasks() bool {
 	var id int64
 	err := DB.Model(&Task{}).
 		Where("progress != ?", "100%").
-		Where("status != ?", TaskStatusFailure).
-		Where("status != ?", TaskStatusSuccess).
+		Where("status NOT IN ?", []string{TaskStatusFailure, TaskStatusSuccess, TaskStatusCancelled}).
 		Limit(1).
 		Pluck("id", &id).Error
 	return err == nil && id != 0
@@ -366,7 +372,7 @@ func HasTaskPollingWork() bool {
 
 	var id int64
 	err := DB.Model(&Task{}).
-		Where("status = ?", TaskStatusFailure).
+		Where("status IN ?", []string{TaskStatusFailure, TaskStatusCancelled}).
 		Where("quota != ?", 0).
 		Where("(submit_time <= ? OR submit_time >= ?)", 0, TaskRefundLegacyCutoff).
 		Limit(1).
@@ -0,0 +1,144 @@
+package model
+
+import (
+	"github.com/QuantumNous/new-api/common"
+)
+
+const (
+	TaskCallbackStatusPending   = "pending"
+	TaskCallbackStatusDelivered = "delivered"
+	TaskCallbackStatusFailed    = "failed"
+)
+
+// TaskCallbackDelivery 记录异步任务到达终态后向客户端回调地址的一次推送，同时作为投递日志。
+// 投递失败时按退避时间重试，直到成功或达到最大次数
+type TaskCallbackDelivery struct {
+	Id             int64  `json:"id"`
+	TaskId         string `json:"task_id" gorm:"type:varchar(191);index"`
+	UserId         int    `json:"user_id" gorm:"index"`
+	TokenId        int    `json:"token_id"`
+	Url            string `json:"url" gorm:"type:varchar(1024)"`
+	Event          string `json:"event" gorm:"type:varchar(32)"`
+	Payload        string `json:"payload" gorm:"type:text"`
+	Status         string `json:"status" gorm:"type:varchar(16);index:idx_task_callback_due,priority:1"`
+	Attempts       int    `json:"attempts"`
+	NextAttemptAt  int64  `json:"next_attempt_at" gorm:"bigint;index:idx_task_callback_due,priority:2"`
+	LastStatusCode int    `json:"last_status_code"`
+	LastError      string `json:"last_error" gorm:"type:text"`
+	DeliveredAt    int64  `json:"delivered_at" gorm:"bigint"`
+	CreatedAt      int64  `json:"created_at" gorm:"bigint;index"`
+	UpdatedAt      int64  `json:"updated_at" gorm:"bigint"`
+}
+
+func (d *TaskCallbackDelivery) Insert() error {
+	now := common.GetTimestamp()
+	d.CreatedAt = now
+	d.UpdatedAt = now
+	if d.Status == "" {
+		d.Status = TaskCallbackStatusPending
+	}
+	if d.NextAttemptAt == 0 {
+		d.NextAttemptAt = now
+	}
+	return DB.Create(d).Error
+}
+
+// GetDueTaskCallbackDeliveries 返回已到重试时间的待投递记录
+func GetDueTaskCallbackDeliveries(now int64, limit int) ([]*TaskCallbackDelivery, error) {
+	var deliveries []*TaskCallbackDelivery
+	err := DB.Where("status = ? AND next_attempt_at <= ?", TaskCallbackStatusPending, now).
+		Order("next_attempt_at asc, id asc").
+		Limit(limit).
+		Find(&deliveries).Error
+	return deliveries, err
+}
+
+// HasDueTaskCallbackDeliveries 轻量检查是否有到期待投递的回调，供重试任务决定是否调度
+func HasDueTaskCallbackDeliveries(now int64) bool {
+	var delivery TaskCallbackDelivery
+	err := DB.Select("id").Where("status = ? AND next_attempt_at <= ?", TaskCallbackStatusPending, now).
+		Limit(1).Find(&delivery).Error
+	return err == nil && delivery.Id > 0
+}
+
+// ClaimTaskCallbackDelivery 以 CAS 方式占用一次投递机会：把 next_attempt_at 推后 lease 秒，
+// 防止多实例或即时投递与定时重试同时发送。返回 false 表示已被其他节点占用
+func ClaimTaskCallbackDelivery(d *TaskCallbackDelivery, lease int64) (bool, error) {
+	now := common.GetTimestamp()
+	result := DB.Model(&TaskCallbackDelivery{}).
+		Where("id = ? AND status = ? AND attempts = ? AND next_attempt_at <= ?", d.Id, TaskCallbackStatusPending, d.Attempts, now).
+		Updates(map[string]any{
+			"next_attempt_at": now + lease,
+			"updated_at":      now,
+		})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// FinishTaskCallbackAttempt 记录一次投递结果。nextAttemptAt 为 0 表示不再重试
+func FinishTaskCallbackAttempt(d *TaskCallbackDelivery, statusCode int, deliverErr error, nextAttemptAt int64) error {
+	now := common.GetTimestamp()
+	d.Attempts++
+	d.LastStatusCode = statusCode
+	d.UpdatedAt = now
+	switch {
+	case deliverErr == nil:
+		d.Status = TaskCallbackStatusDelivered
+		d.LastError = ""
+		d.DeliveredAt = now
+	case nextAttemptAt > 0:
+		d.LastError = deliverErr.Error()
+		d.NextAttemptAt = nextAttemptAt
+	default:
+		d.Status = TaskCallbackStatusFailed
+		d.LastError = deliverErr.Error()
+	}
+	return DB.Model(&TaskCallbackDelivery{}).Where("id = ?", d.Id).Updates(map[string]any{
+		"status":           d.Status,
+		"attempts":         d.Attempts,
+		"last_status_code": d.LastStatusCode,
+		"last_error":       d.LastError,
+		"next_attempt_at":  d.NextAttemptAt,
+		"delivered_at":     d.DeliveredAt,
+		"updated_at":       d.UpdatedAt,
+	}).Error
+}
+
+// RetryTaskCallbackDelivery 把投递失败的记录重新放回队列，供用户手动重发
+func RetryTaskCallbackDelivery(id int64, userId int) (bool, error) {
+	query := DB.Model(&TaskCallbackDelivery{}).Where("id = ? AND status = ?", id, TaskCallbackStatusFailed)
+	if userId > 0 {
+		query = query.Where("user_id = ?", userId)
+	}
+	now := common.GetTimestamp()
+	result := query.Updates(map[string]any{
+		"status":          TaskCallbackStatusPending,
+		"attempts":        0,
+		"next_attempt_at": now,
+		"updated_at":      now,
+	})
+	return result.RowsAffected > 0, result.Error
+}
+
+// GetTaskCallbackDeliveries 分页查询投递日志，userId 为 0 时查询全部用户
+func GetTaskCallbackDeliveries(userId int, taskId string, status string, startIdx int, num int) ([]*TaskCallbackDelivery, int64, error) {
+	query := DB.Model(&TaskCallbackDelivery{})
+	if userId > 0 {
+		query = query.Where("user_id = ?", userId)
+	}
+	if taskId != "" {
+		query = query.Where("task_id = ?", taskId)
+	}
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var deliveries []*TaskCallbackDelivery
+	err := query.Order("id desc").Limit(num).Offset(startIdx).Find(&deliveries).Error
+	return deliveries, total, err
+}
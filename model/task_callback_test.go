@@ -0,0 +1,61 @@
+package model
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTaskCallbackDeliveryLifecycle(t *testing.T) {
+	truncateTables(t)
+	delivery := &TaskCallbackDelivery{TaskId: "task_cb_1", UserId: 7, Url: "https://example.com/hook", Event: "task.succeeded", Payload: "{}"}
+	require.NoError(t, delivery.Insert())
+
+	due, err := GetDueTaskCallbackDeliveries(common.GetTimestamp(), 10)
+	require.NoError(t, err)
+	require.Len(t, due, 1)
+
+	// 同一次投递只能被占用一次
+	claimed, err := ClaimTaskCallbackDelivery(due[0], 60)
+	require.NoError(t, err)
+	assert.True(t, claimed)
+	claimed, err = ClaimTaskCallbackDelivery(delivery, 60)
+	require.NoError(t, err)
+	assert.False(t, claimed)
+
+	next := common.GetTimestamp() + 30
+	require.NoError(t, FinishTaskCallbackAttempt(due[0], 500, errors.New("upstream 500"), next))
+	due, err = GetDueTaskCallbackDeliveries(common.GetTimestamp(), 10)
+	require.NoError(t, err)
+	assert.Empty(t, due)
+
+	require.NoError(t, FinishTaskCallbackAttempt(delivery, 0, errors.New("timeout"), 0))
+	items, total, err := GetTaskCallbackDeliveries(7, "task_cb_1", "", 0, 10)
+	require.NoError(t, err)
+	require.EqualValues(t, 1, total)
+	assert.Equal(t, TaskCallbackStatusFailed, items[0].Status)
+
+	// 其他用户不能重发
+	ok, err := RetryTaskCallbackDelivery(delivery.Id, 8)
+	require.NoError(t, err)
+	assert.False(t, ok)
+	ok, err = RetryTaskCallbackDelivery(delivery.Id, 7)
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	due, err = GetDueTaskCallbackDeliveries(common.GetTimestamp(), 10)
+	require.NoError(t, err)
+	require.Len(t, due, 1)
+	claimed, err = ClaimTaskCallbackDelivery(due[0], 60)
+	require.NoError(t, err)
+	require.True(t, claimed)
+	require.NoError(t, FinishTaskCallbackAttempt(due[0], 200, nil, 0))
+	items, _, err = GetTaskCallbackDeliveries(0, "", TaskCallbackStatusDelivered, 0, 10)
+	require.NoError(t, err)
+	require.Len(t, items, 1)
+	assert.Equal(t, 1, items[0].Attempts)
+	assert.NotZero(t, items[0].DeliveredAt)
+}
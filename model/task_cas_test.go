@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
 	"github.com/glebarez/sqlite"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -26,6 +27,8 @@ func TestMain(m *testing.M) {
 	common.RedisEnabled = false
 	common.BatchUpdateEnabled = false
 	common.LogConsumeEnabled = true
+	// 账本默认关闭，测试中开启以覆盖记账路径
+	operation_setting.GetQuotaLedgerSetting().Enabled = true
 	initCol()
 
 	sqlDB, err := db.DB()
@@ -54,11 +57,21 @@ func TestMain(m *testing.M) {
 		&SubscriptionPlan{},
 		&SubscriptionOrder{},
 		&UserSubscription{},
+		&SubscriptionPreConsumeRecord{},
+		&UserSubscriptionBucket{},
+		&QuotaLot{},
+		&PaymentRefund{},
+		&TaskCallbackDelivery{},
+		&MediaObject{},
+		&PrefillGroup{},
 		&UserOAuthBinding{},
 		&PerfMetric{},
 		&SystemInstance{},
 		&SystemTask{},
 		&SystemTaskLock{},
+		&QuotaLedgerEntry{},
+		&QuotaLedgerAccount{},
+		&QuotaLedgerDrift{},
 	); err != nil {
 		panic("failed to migrate: " + err.Error())
 	}
@@ -89,9 +102,19 @@ func truncateTables(t *testing.T) {
 		DB.Exec("DELETE FROM subscription_orders")
 		DB.Exec("DELETE FROM subscription_plans")
 		DB.Exec("DELETE FROM user_subscriptions")
+		DB.Exec("DELETE FROM subscription_pre_consume_records")
+		DB.Exec("DELETE FROM user_subscription_buckets")
+		DB.Exec("DELETE FROM quota_lots")
+		DB.Exec("DELETE FROM payment_refunds")
+		DB.Exec("DELETE FROM task_callback_deliveries")
+		DB.Exec("DELETE FROM media_objects")
+		DB.Exec("DELETE FROM prefill_groups")
 		DB.Exec("DELETE FROM perf_metrics")
 		DB.Exec("DELETE FROM system_instances")
 		DB.Exec("DELETE FROM system_task_locks")
+		DB.Exec("DELETE FROM quota_ledger_entries")
+		DB.Exec("DELETE FROM quota_ledger_accounts")
+		DB.Exec("DELETE FROM quota_ledger_drifts")
 		DB.Exec("DELETE FROM system_tasks")
 	})
 }
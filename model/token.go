@@ -27,7 +27,10 @@ type Token struct {
 	AllowIps           *string        `json:"allow_ips" gorm:"default:''"`
 	UsedQuota          int            `json:"used_quota" gorm:"default:0"` // used quota
 	Group              string         `json:"group" gorm:"default:''"`
-	CrossGroupRetry    bool           `json:"cross_group_retry"` // 跨分组重试，仅auto分组有效
+	CrossGroupRetry    bool           `json:"cross_group_retry"`                                   // 跨分组重试，仅auto分组有效
+	OrganizationId     int            `json:"organization_id" gorm:"default:0;index"`              // 组织令牌，消费从组织钱包扣除
+	CallbackUrl        string         `json:"callback_url" gorm:"type:varchar(1024);default:''"`   // 异步任务默认回调地址，请求未指定 callback_url 时使用
+	CallbackSecret     string         `json:"callback_secret" gorm:"type:varchar(128);default:''"` // 回调签名密钥
 	DeletedAt          gorm.DeletedAt `gorm:"index"`
 }
 
@@ -302,7 +305,7 @@ func (token *Token) Update() (err error) {
 		}
 	}()
 	err = DB.Model(token).Select("name", "status", "expired_time", "remain_quota", "unlimited_quota",
-		"model_limits_enabled", "model_limits", "allow_ips", "group", "cross_group_retry").Updates(token).Error
+		"model_limits_enabled", "model_limits", "allow_ips", "group", "cross_group_retry", "callback_url", "callback_secret").Updates(token).Error
 	return err
 }
 
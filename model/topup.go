@@ -22,6 +22,18 @@ type TopUp struct {
 	CreateTime      int64   `json:"create_time"`
 	CompleteTime    int64   `json:"complete_time"`
 	Status          string  `json:"status"`
+	// 优惠码归因：DiscountMoney 为下单时减免的金额，BonusQuota 为支付成功后额外赠送的额度
+	CouponCode    string  `json:"coupon_code" gorm:"type:varchar(64);default:'';index"`
+	DiscountMoney float64 `json:"discount_money"`
+	BonusQuota    int64   `json:"bonus_quota"`
+	// Currency 为 Money 的币种，为空表示支付渠道的默认币种
+	Currency string `json:"currency" gorm:"type:varchar(8);default:''"`
+	// PayMoney / PayCurrency 为按其他币种结账时实际收取的金额和币种（Stripe 换算币种结账、自动充值）。
+	// 此时 Money 仍为默认币种下的购买数量，入账额度按 Money 计算，不受结账币种影响
+	PayMoney    float64 `json:"pay_money"`
+	PayCurrency string  `json:"pay_currency" gorm:"type:varchar(8);default:''"`
+	// RefundedMoney 为支付渠道同步过来的累计退款金额（与 Money 同币种）
+	RefundedMoney float64 `json:"refunded_money"`
 }
 
 const (
@@ -30,6 +42,7 @@ const (
 	PaymentMethodWaffo        = "waffo"
 	PaymentMethodWaffoPancake = "waffo_pancake"
 	PaymentMethodBalance      = "balance"
+	PaymentMethodPayPal       = "paypal"
 )
 
 const (
@@ -39,6 +52,7 @@ const (
 	PaymentProviderWaffo        = "waffo"
 	PaymentProviderWaffoPancake = "waffo_pancake"
 	PaymentProviderBalance      = "balance"
+	PaymentProviderPayPal       = "paypal"
 )
 
 var (
@@ -102,7 +116,10 @@ func UpdatePendingTopUpStatus(tradeNo string, expectedPaymentProvider string, ta
 		}
 
 		topUp.Status = targetStatus
-		return tx.Save(topUp).Error
+		if err := tx.Save(topUp).Error; err != nil {
+			return err
+		}
+		return releaseCouponRedemptionTx(tx, topUp.TradeNo)
 	})
 }
 
@@ -140,13 +157,22 @@ func Recharge(referenceId string, customerId string, callerIp string) (err error
 			return err
 		}
 
-		quota = topUp.Money * common.QuotaPerUnit
+		// 折扣类优惠码只减少实付金额，额度仍按原价计算
+		quota = (topUp.Money + topUp.DiscountMoney) * common.QuotaPerUnit
 		err = tx.Model(&User{}).Where("id = ?", topUp.UserId).Updates(map[string]interface{}{"stripe_customer": customerId, "quota": gorm.Expr("quota + ?", quota)}).Error
 		if err != nil {
 			return err
 		}
 
-		return nil
+		if err := PostQuotaLedgerTx(tx, UserQuotaLedgerPosting(topUp.UserId, int(quota), QuotaLedgerReasonTopup, QuotaLedgerRefTradeNo, topUp.TradeNo)); err != nil {
+			return err
+		}
+		if err := GrantQuotaLotTx(tx, topUp.UserId, QuotaLotSourceTopup, topUp.TradeNo, int(quota), QuotaLotValidDays(QuotaLotSourceTopup)); err != nil {
+			return err
+		}
+		bonus, err := grantTopUpCouponBonusTx(tx, topUp, int(quota))
+		quota += float64(bonus)
+		return err
 	})
 
 	if err != nil {
@@ -159,6 +185,66 @@ func Recharge(referenceId string, customerId string, callerIp string) (err error
 	return nil
 }
 
+// RechargeEpay 在同一事务中完成易支付订单：标记订单成功、按 Amount 入账并发放优惠码赠送额度，
+// 任一步失败整体回滚，订单保持待支付以便回调重试。actualPaymentMethod 为回调中的实际支付方式。
+// 订单已不是待支付状态时不做修改，completed 为 false。
+func RechargeEpay(tradeNo string, actualPaymentMethod string) (topUp *TopUp, quotaToAdd int, completed bool, err error) {
+	if tradeNo == "" {
+		return nil, 0, false, errors.New("未提供支付单号")
+	}
+	refCol := "`trade_no`"
+	if common.UsingMainDatabase(common.DatabaseTypePostgreSQL) {
+		refCol = `"trade_no"`
+	}
+	topUp = &TopUp{}
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		if err := lockForUpdate(tx).Where(refCol+" = ?", tradeNo).First(topUp).Error; err != nil {
+			return errors.New("充值订单不存在")
+		}
+		if topUp.PaymentProvider != PaymentProviderEpay {
+			return ErrPaymentMethodMismatch
+		}
+		if topUp.Status != common.TopUpStatusPending {
+			return nil
+		}
+		if actualPaymentMethod != "" {
+			topUp.PaymentMethod = actualPaymentMethod
+		}
+		topUp.CompleteTime = common.GetTimestamp()
+		topUp.Status = common.TopUpStatusSuccess
+		if err := tx.Save(topUp).Error; err != nil {
+			return err
+		}
+
+		quotaToAdd = int(decimal.NewFromInt(topUp.Amount).Mul(decimal.NewFromFloat(common.QuotaPerUnit)).IntPart())
+		if err := tx.Model(&User{}).Where("id = ?", topUp.UserId).Update("quota", gorm.Expr("quota + ?", quotaToAdd)).Error; err != nil {
+			return err
+		}
+		if err := PostQuotaLedgerTx(tx, UserQuotaLedgerPosting(topUp.UserId, quotaToAdd, QuotaLedgerReasonTopup, QuotaLedgerRefTradeNo, topUp.TradeNo)); err != nil {
+			return err
+		}
+		if err := GrantQuotaLotTx(tx, topUp.UserId, QuotaLotSourceTopup, topUp.TradeNo, quotaToAdd, QuotaLotValidDays(QuotaLotSourceTopup)); err != nil {
+			return err
+		}
+		bonus, err := grantTopUpCouponBonusTx(tx, topUp, quotaToAdd)
+		if err != nil {
+			return err
+		}
+		quotaToAdd += bonus
+		completed = true
+		return nil
+	})
+	if err != nil {
+		return nil, 0, false, err
+	}
+	if completed {
+		if err := cacheIncrUserQuota(topUp.UserId, int64(quotaToAdd)); err != nil {
+			common.SysLog("failed to increase user quota cache after epay topup: " + err.Error())
+		}
+	}
+	return topUp, quotaToAdd, completed, nil
+}
+
 // topUpQueryWindowSeconds 限制充值记录查询的时间窗口（秒）。
 const topUpQueryWindowSeconds int64 = 30 * 24 * 60 * 60
 
@@ -353,7 +439,7 @@ func ManualCompleteTopUp(tradeNo string, callerIp string) error {
 		// - 其他订单（如易支付）：Amount 为美元数量，* QuotaPerUnit
 		if topUp.PaymentProvider == PaymentProviderStripe {
 			dQuotaPerUnit := decimal.NewFromFloat(common.QuotaPerUnit)
-			quotaToAdd = int(decimal.NewFromFloat(topUp.Money).Mul(dQuotaPerUnit).IntPart())
+			quotaToAdd = int(decimal.NewFromFloat(topUp.Money).Add(decimal.NewFromFloat(topUp.DiscountMoney)).Mul(dQuotaPerUnit).IntPart())
 		} else {
 			dAmount := decimal.NewFromInt(topUp.Amount)
 			dQuotaPerUnit := decimal.NewFromFloat(common.QuotaPerUnit)
@@ -374,6 +460,17 @@ func ManualCompleteTopUp(tradeNo string, callerIp string) error {
 		if err := tx.Model(&User{}).Where("id = ?", topUp.UserId).Update("quota", gorm.Expr("quota + ?", quotaToAdd)).Error; err != nil {
 			return err
 		}
+		if err := PostQuotaLedgerTx(tx, UserQuotaLedgerPosting(topUp.UserId, quotaToAdd, QuotaLedgerReasonTopup, QuotaLedgerRefTradeNo, topUp.TradeNo)); err != nil {
+			return err
+		}
+		if err := GrantQuotaLotTx(tx, topUp.UserId, QuotaLotSourceTopup, topUp.TradeNo, quotaToAdd, QuotaLotValidDays(QuotaLotSourceTopup)); err != nil {
+			return err
+		}
+		bonus, err := grantTopUpCouponBonusTx(tx, topUp, quotaToAdd)
+		if err != nil {
+			return err
+		}
+		quotaToAdd += bonus
 
 		userId = topUp.UserId
 		payMoney = topUp.Money
@@ -451,7 +548,15 @@ func RechargeCreem(referenceId string, customerEmail string, customerName string
 			return err
 		}
 
-		return nil
+		if err := PostQuotaLedgerTx(tx, UserQuotaLedgerPosting(topUp.UserId, int(quota), QuotaLedgerReasonTopup, QuotaLedgerRefTradeNo, topUp.TradeNo)); err != nil {
+			return err
+		}
+		if err := GrantQuotaLotTx(tx, topUp.UserId, QuotaLotSourceTopup, topUp.TradeNo, int(quota), QuotaLotValidDays(QuotaLotSourceTopup)); err != nil {
+			return err
+		}
+		bonus, err := grantTopUpCouponBonusTx(tx, topUp, int(quota))
+		quota += int64(bonus)
+		return err
 	})
 
 	if err != nil {
@@ -512,7 +617,15 @@ func RechargeWaffo(tradeNo string, callerIp string) (err error) {
 			return err
 		}
 
-		return nil
+		if err := PostQuotaLedgerTx(tx, UserQuotaLedgerPosting(topUp.UserId, quotaToAdd, QuotaLedgerReasonTopup, QuotaLedgerRefTradeNo, topUp.TradeNo)); err != nil {
+			return err
+		}
+		if err := GrantQuotaLotTx(tx, topUp.UserId, QuotaLotSourceTopup, topUp.TradeNo, quotaToAdd, QuotaLotValidDays(QuotaLotSourceTopup)); err != nil {
+			return err
+		}
+		bonus, err := grantTopUpCouponBonusTx(tx, topUp, quotaToAdd)
+		quotaToAdd += bonus
+		return err
 	})
 
 	if err != nil {
@@ -573,7 +686,15 @@ func RechargeWaffoPancake(tradeNo string) (err error) {
 			return err
 		}
 
-		return nil
+		if err := PostQuotaLedgerTx(tx, UserQuotaLedgerPosting(topUp.UserId, quotaToAdd, QuotaLedgerReasonTopup, QuotaLedgerRefTradeNo, topUp.TradeNo)); err != nil {
+			return err
+		}
+		if err := GrantQuotaLotTx(tx, topUp.UserId, QuotaLotSourceTopup, topUp.TradeNo, quotaToAdd, QuotaLotValidDays(QuotaLotSourceTopup)); err != nil {
+			return err
+		}
+		bonus, err := grantTopUpCouponBonusTx(tx, topUp, quotaToAdd)
+		quotaToAdd += bonus
+		return err
 	})
 
 	if err != nil {
@@ -0,0 +1,38 @@
+package model
+
+import (
+	"testing"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStripeForeignCurrencyTopUpCreditsPurchasedUnits(t *testing.T) {
+	truncateTables(t)
+	insertUserForPaymentGuardTest(t, 201, 0)
+
+	// 按 JPY 结账：Money 为默认币种下的购买数量，实收的 1500 JPY 只记录在 PayMoney
+	for _, tradeNo := range []string{"stripe-jpy-webhook", "stripe-jpy-manual"} {
+		topUp := &TopUp{
+			UserId:          201,
+			Amount:          10,
+			Money:           10,
+			PayMoney:        1500,
+			PayCurrency:     "JPY",
+			TradeNo:         tradeNo,
+			PaymentMethod:   PaymentMethodStripe,
+			PaymentProvider: PaymentProviderStripe,
+			Status:          common.TopUpStatusPending,
+			CreateTime:      time.Now().Unix(),
+		}
+		require.NoError(t, topUp.Insert())
+	}
+
+	unitQuota := int(10 * common.QuotaPerUnit)
+	require.NoError(t, Recharge("stripe-jpy-webhook", "cus_test", ""))
+	assert.Equal(t, unitQuota, getUserQuotaForPaymentGuardTest(t, 201))
+	require.NoError(t, ManualCompleteTopUp("stripe-jpy-manual", ""))
+	assert.Equal(t, 2*unitQuota, getUserQuotaForPaymentGuardTest(t, 201))
+}
@@ -534,6 +534,9 @@ func (user *User) TransferAffQuotaToQuota(quota int) error {
 	if err := tx.Save(user).Error; err != nil {
 		return err
 	}
+	if err := PostQuotaLedgerTx(tx, UserQuotaLedgerPosting(user.Id, quota, QuotaLedgerReasonAffTransfer, "", "")); err != nil {
+		return err
+	}
 
 	// 提交事务
 	return tx.Commit().Error
@@ -633,11 +636,14 @@ func (user *User) finishInsert(inviterId int) {
 	}
 
 	if common.QuotaForNewUser > 0 {
+		RecordQuotaLedger(UserQuotaLedgerPosting(user.Id, common.QuotaForNewUser, QuotaLedgerReasonSignupBonus, "", ""))
 		RecordLog(user.Id, LogTypeSystem, fmt.Sprintf("新用户注册赠送 %s", logger.LogQuota(common.QuotaForNewUser)))
 	}
 	if inviterId != 0 && operation_setting.IsPaymentComplianceConfirmed() {
 		if common.QuotaForInvitee > 0 {
-			_ = IncreaseUserQuota(user.Id, common.QuotaForInvitee, true)
+			if err := IncreaseUserQuota(user.Id, common.QuotaForInvitee, true); err == nil {
+				RecordQuotaLedger(UserQuotaLedgerPosting(user.Id, common.QuotaForInvitee, QuotaLedgerReasonInviteBonus, "", ""))
+			}
 			RecordLog(user.Id, LogTypeSystem, fmt.Sprintf("使用邀请码赠送 %s", logger.LogQuota(common.QuotaForInvitee)))
 		}
 		if common.QuotaForInviter > 0 {
@@ -690,11 +696,14 @@ func (user *User) FinalizeOAuthUserCreation(inviterId int) {
 	}
 
 	if common.QuotaForNewUser > 0 {
+		RecordQuotaLedger(UserQuotaLedgerPosting(user.Id, common.QuotaForNewUser, QuotaLedgerReasonSignupBonus, "", ""))
 		RecordLog(user.Id, LogTypeSystem, fmt.Sprintf("新用户注册赠送 %s", logger.LogQuota(common.QuotaForNewUser)))
 	}
 	if inviterId != 0 && operation_setting.IsPaymentComplianceConfirmed() {
 		if common.QuotaForInvitee > 0 {
-			_ = IncreaseUserQuota(user.Id, common.QuotaForInvitee, true)
+			if err := IncreaseUserQuota(user.Id, common.QuotaForInvitee, true); err == nil {
+				RecordQuotaLedger(UserQuotaLedgerPosting(user.Id, common.QuotaForInvitee, QuotaLedgerReasonInviteBonus, "", ""))
+			}
 			RecordLog(user.Id, LogTypeSystem, fmt.Sprintf("使用邀请码赠送 %s", logger.LogQuota(common.QuotaForInvitee)))
 		}
 		if common.QuotaForInviter > 0 {
@@ -0,0 +1,135 @@
+package monitor
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// ChannelQueueStats tracks per-channel concurrency and queue pressure on this
+// node. Counters are node-local even when the cap itself is enforced across
+// nodes through Redis.
+type ChannelQueueStats struct {
+	mu       sync.Mutex
+	channels map[int]*channelQueueCounters
+}
+
+type channelQueueCounters struct {
+	limit       int
+	inFlight    int
+	queueDepth  int
+	waited      int64
+	timedOut    int64
+	totalWaitMs int64
+	maxWaitMs   int64
+}
+
+type ChannelQueueSnapshot struct {
+	ChannelId   int     `json:"channel_id"`
+	Limit       int     `json:"limit"`
+	InFlight    int     `json:"in_flight"`
+	QueueDepth  int     `json:"queue_depth"`
+	Waited      int64   `json:"waited"`
+	TimedOut    int64   `json:"timed_out"`
+	AvgWaitMs   float64 `json:"avg_wait_ms"`
+	MaxWaitMs   int64   `json:"max_wait_ms"`
+	TotalWaitMs int64   `json:"total_wait_ms"`
+}
+
+var channelQueueStats = &ChannelQueueStats{channels: make(map[int]*channelQueueCounters)}
+
+func (s *ChannelQueueStats) countersLocked(channelID int) *channelQueueCounters {
+	counters, ok := s.channels[channelID]
+	if !ok {
+		counters = &channelQueueCounters{}
+		s.channels[channelID] = counters
+	}
+	return counters
+}
+
+// ChannelConcurrencyAcquired records a granted concurrency slot.
+func ChannelConcurrencyAcquired(channelID int, limit int) {
+	if channelID <= 0 {
+		return
+	}
+	channelQueueStats.mu.Lock()
+	counters := channelQueueStats.countersLocked(channelID)
+	counters.limit = limit
+	counters.inFlight++
+	channelQueueStats.mu.Unlock()
+}
+
+// ChannelConcurrencyReleased records a returned concurrency slot.
+func ChannelConcurrencyReleased(channelID int) {
+	if channelID <= 0 {
+		return
+	}
+	channelQueueStats.mu.Lock()
+	counters := channelQueueStats.countersLocked(channelID)
+	if counters.inFlight > 0 {
+		counters.inFlight--
+	}
+	channelQueueStats.mu.Unlock()
+}
+
+// ChannelQueueEnter records a request starting to wait for a channel slot.
+func ChannelQueueEnter(channelID int) {
+	if channelID <= 0 {
+		return
+	}
+	channelQueueStats.mu.Lock()
+	channelQueueStats.countersLocked(channelID).queueDepth++
+	channelQueueStats.mu.Unlock()
+}
+
+// ChannelQueueLeave records a request leaving the queue, either with a slot
+// (acquired) or because its deadline passed or the client went away.
+func ChannelQueueLeave(channelID int, wait time.Duration, acquired bool) {
+	if channelID <= 0 {
+		return
+	}
+	waitMs := wait.Milliseconds()
+	channelQueueStats.mu.Lock()
+	counters := channelQueueStats.countersLocked(channelID)
+	if counters.queueDepth > 0 {
+		counters.queueDepth--
+	}
+	counters.waited++
+	counters.totalWaitMs += waitMs
+	if waitMs > counters.maxWaitMs {
+		counters.maxWaitMs = waitMs
+	}
+	if !acquired {
+		counters.timedOut++
+	}
+	channelQueueStats.mu.Unlock()
+}
+
+// GetChannelQueueSnapshots returns the counters of every channel that has
+// ever been capped on this node, ordered by channel id.
+func GetChannelQueueSnapshots() []ChannelQueueSnapshot {
+	channelQueueStats.mu.Lock()
+	snapshots := make([]ChannelQueueSnapshot, 0, len(channelQueueStats.channels))
+	for channelID, counters := range channelQueueStats.channels {
+		snapshot := ChannelQueueSnapshot{
+			ChannelId:   channelID,
+			Limit:       counters.limit,
+			InFlight:    counters.inFlight,
+			QueueDepth:  counters.queueDepth,
+			Waited:      counters.waited,
+			TimedOut:    counters.timedOut,
+			MaxWaitMs:   counters.maxWaitMs,
+			TotalWaitMs: counters.totalWaitMs,
+		}
+		if counters.waited > 0 {
+			snapshot.AvgWaitMs = float64(counters.totalWaitMs) / float64(counters.waited)
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+	channelQueueStats.mu.Unlock()
+
+	sort.Slice(snapshots, func(i, j int) bool {
+		return snapshots[i].ChannelId < snapshots[j].ChannelId
+	})
+	return snapshots
+}
@@ -53,10 +53,11 @@ func GetStats() gin.HandlerFunc {
 		}
 
 		c.JSON(http.StatusOK, gin.H{
-			"success":     true,
-			"data":        stats,
-			"load":        load,
-			"connections": connections,
+			"success":        true,
+			"data":           stats,
+			"load":           load,
+			"connections":    connections,
+			"channel_queues": GetChannelQueueSnapshots(),
 		})
 	}
 }
@@ -0,0 +1,37 @@
+package blobstore
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+)
+
+// ErrNotFound is returned by Get when the key does not exist in the store.
+var ErrNotFound = errors.New("blobstore: object not found")
+
+// Store is a minimal object store used to persist generated media. Keys are
+// slash separated relative paths such as "task/2026/10/task_xxx.mp4".
+type Store interface {
+	// Put writes the object, replacing any existing object with the same key.
+	// size may be -1 when unknown; backends that need a length must reject it.
+	Put(ctx context.Context, key string, body io.Reader, size int64, contentType string) error
+	// Get opens the object for reading. The caller must close the reader.
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete removes the object. Deleting a missing key is not an error.
+	Delete(ctx context.Context, key string) error
+}
+
+// ValidateKey rejects empty, absolute or path-traversing keys so a key can be
+// mapped to a file path or an object URL without escaping the store root.
+func ValidateKey(key string) error {
+	if key == "" || strings.HasPrefix(key, "/") || strings.Contains(key, "\\") {
+		return errors.New("blobstore: invalid key")
+	}
+	for _, part := range strings.Split(key, "/") {
+		if part == "" || part == "." || part == ".." {
+			return errors.New("blobstore: invalid key")
+		}
+	}
+	return nil
+}
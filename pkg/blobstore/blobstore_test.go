@@ -0,0 +1,94 @@
+package blobstore
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeS3 is an in-memory stand-in for an S3-compatible server that only checks
+// the request is SigV4 signed.
+type fakeS3 struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 ") || r.Header.Get("X-Amz-Content-Sha256") == "" {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch r.Method {
+	case http.MethodPut:
+		body, _ := io.ReadAll(r.Body)
+		f.objects[r.URL.Path] = body
+	case http.MethodGet:
+		body, ok := f.objects[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write(body)
+	case http.MethodDelete:
+		delete(f.objects, r.URL.Path)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func exerciseStore(t *testing.T, store Store) {
+	ctx := context.Background()
+	payload := "generated media"
+	require.NoError(t, store.Put(ctx, "task/2026/clip.mp4", strings.NewReader(payload), int64(len(payload)), "video/mp4"))
+
+	r, err := store.Get(ctx, "task/2026/clip.mp4")
+	require.NoError(t, err)
+	data, err := io.ReadAll(r)
+	r.Close()
+	require.NoError(t, err)
+	assert.Equal(t, payload, string(data))
+
+	require.NoError(t, store.Delete(ctx, "task/2026/clip.mp4"))
+	require.NoError(t, store.Delete(ctx, "task/2026/clip.mp4"))
+	_, err = store.Get(ctx, "task/2026/clip.mp4")
+	assert.True(t, errors.Is(err, ErrNotFound))
+
+	assert.Error(t, store.Put(ctx, "../escape", strings.NewReader("x"), 1, ""))
+}
+
+func TestLocalStore(t *testing.T) {
+	store, err := NewLocalStore(t.TempDir())
+	require.NoError(t, err)
+	exerciseStore(t, store)
+}
+
+func TestS3StorePathStyle(t *testing.T) {
+	fake := &fakeS3{objects: map[string][]byte{}}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	store, err := NewS3Store(S3Config{
+		Endpoint:        server.URL,
+		Bucket:          "media",
+		AccessKeyId:     "test",
+		SecretAccessKey: "test-secret",
+		PathStyle:       true,
+		Prefix:          "archive",
+		HTTPClient:      server.Client(),
+	})
+	require.NoError(t, err)
+	exerciseStore(t, store)
+
+	require.NoError(t, store.Put(context.Background(), "a/b.png", strings.NewReader("png"), 3, "image/png"))
+	_, ok := fake.objects["/media/archive/a/b.png"]
+	assert.True(t, ok)
+}
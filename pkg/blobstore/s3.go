@@ -0,0 +1,158 @@
+package blobstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+)
+
+const s3UnsignedPayload = "UNSIGNED-PAYLOAD"
+
+// S3Config describes an S3-compatible bucket (AWS S3, MinIO, R2, OSS, ...).
+type S3Config struct {
+	// Endpoint is the service base URL, e.g. https://s3.us-east-1.amazonaws.com
+	// or http://127.0.0.1:9000 for a local MinIO.
+	Endpoint        string
+	Region          string
+	Bucket          string
+	AccessKeyId     string
+	SecretAccessKey string
+	// PathStyle addresses objects as {endpoint}/{bucket}/{key} instead of
+	// {bucket}.{host}/{key}. Most self-hosted implementations need it.
+	PathStyle bool
+	// Prefix is prepended to every key, allowing several deployments to share
+	// one bucket.
+	Prefix     string
+	HTTPClient *http.Client
+}
+
+// S3Store talks to an S3-compatible API with SigV4 signed requests. Payloads
+// are sent unsigned so uploads can be streamed without hashing them first.
+type S3Store struct {
+	cfg      S3Config
+	endpoint *url.URL
+	signer   *v4.Signer
+	client   *http.Client
+}
+
+func NewS3Store(cfg S3Config) (*S3Store, error) {
+	if cfg.Endpoint == "" || cfg.Bucket == "" {
+		return nil, errors.New("blobstore: s3 endpoint and bucket are required")
+	}
+	if cfg.AccessKeyId == "" || cfg.SecretAccessKey == "" {
+		return nil, errors.New("blobstore: s3 credentials are required")
+	}
+	endpoint, err := url.Parse(strings.TrimRight(cfg.Endpoint, "/"))
+	if err != nil || endpoint.Host == "" || (endpoint.Scheme != "http" && endpoint.Scheme != "https") {
+		return nil, fmt.Errorf("blobstore: invalid s3 endpoint %q", cfg.Endpoint)
+	}
+	if cfg.Region == "" {
+		cfg.Region = "us-east-1"
+	}
+	client := cfg.HTTPClient
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Minute}
+	}
+	return &S3Store{cfg: cfg, endpoint: endpoint, signer: v4.NewSigner(), client: client}, nil
+}
+
+func (s *S3Store) objectURL(key string) (string, error) {
+	if err := ValidateKey(key); err != nil {
+		return "", err
+	}
+	fullKey := key
+	if prefix := strings.Trim(s.cfg.Prefix, "/"); prefix != "" {
+		fullKey = prefix + "/" + key
+	}
+	segments := strings.Split(fullKey, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	u := *s.endpoint
+	if s.cfg.PathStyle {
+		u.Path = strings.TrimRight(u.Path, "/") + "/" + url.PathEscape(s.cfg.Bucket) + "/" + strings.Join(segments, "/")
+	} else {
+		u.Host = s.cfg.Bucket + "." + u.Host
+		u.Path = strings.TrimRight(u.Path, "/") + "/" + strings.Join(segments, "/")
+	}
+	return u.String(), nil
+}
+
+func (s *S3Store) do(ctx context.Context, method string, key string, body io.Reader, size int64, contentType string) (*http.Response, error) {
+	objectURL, err := s.objectURL(key)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, method, objectURL, body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.ContentLength = size
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	req.Header.Set("X-Amz-Content-Sha256", s3UnsignedPayload)
+	credentials := aws.Credentials{AccessKeyID: s.cfg.AccessKeyId, SecretAccessKey: s.cfg.SecretAccessKey}
+	if err := s.signer.SignHTTP(ctx, credentials, req, s3UnsignedPayload, "s3", s.cfg.Region, time.Now()); err != nil {
+		return nil, err
+	}
+	return s.client.Do(req)
+}
+
+func (s *S3Store) Put(ctx context.Context, key string, body io.Reader, size int64, contentType string) error {
+	if size < 0 {
+		return errors.New("blobstore: s3 upload requires a known size")
+	}
+	resp, err := s.do(ctx, http.MethodPut, key, body, size, contentType)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return s3Error(resp)
+	}
+	return nil
+}
+
+func (s *S3Store) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	resp, err := s.do(ctx, http.MethodGet, key, nil, 0, "")
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		resp.Body.Close()
+		return nil, ErrNotFound
+	}
+	if resp.StatusCode/100 != 2 {
+		defer resp.Body.Close()
+		return nil, s3Error(resp)
+	}
+	return resp.Body, nil
+}
+
+func (s *S3Store) Delete(ctx context.Context, key string) error {
+	resp, err := s.do(ctx, http.MethodDelete, key, nil, 0, "")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 && resp.StatusCode != http.StatusNotFound {
+		return s3Error(resp)
+	}
+	return nil
+}
+
+func s3Error(resp *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	return fmt.Errorf("blobstore: s3 http status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+}
@@ -78,6 +78,14 @@ type TaskAdaptor interface {
 	ParseTaskResult(respBody []byte) (*relaycommon.TaskInfo, error)
 }
 
+// TaskCanceler is an optional interface for task adaptors whose upstream can
+// cancel an in-flight task. body carries "task_id" (the upstream task ID) and
+// "action", mirroring FetchTask. service.CancelTask asserts this method set on
+// the adaptor returned by service.GetTaskAdaptorFunc.
+type TaskCanceler interface {
+	CancelTask(baseUrl, key string, body map[string]any, proxy string) (*http.Response, error)
+}
+
 type OpenAIVideoConverter interface {
 	ConvertToOpenAIVideo(originTask *model.Task) ([]byte, error)
 }
@@ -2,6 +2,7 @@ package cohere
 
 import (
 	"encoding/json"
+	"fmt"
 	"io"
 	"net/http"
 	"strings"
@@ -11,6 +12,7 @@ import (
 	relaycommon "github.com/QuantumNous/new-api/relay/common"
 	"github.com/QuantumNous/new-api/relay/helper"
 	"github.com/QuantumNous/new-api/service"
+	"github.com/QuantumNous/new-api/service/relayconvert"
 	"github.com/QuantumNous/new-api/types"
 
 	"github.com/gin-gonic/gin"
@@ -250,3 +252,36 @@ func cohereRerankHandler(c *gin.Context, resp *http.Response, info *relaycommon.
 	_, err = c.Writer.Write(jsonResponse)
 	return &usage, nil
 }
+
+func cohereEmbeddingHandler(c *gin.Context, resp *http.Response, info *relaycommon.RelayInfo) (*dto.Usage, *types.NewAPIError) {
+	defer service.CloseResponseBodyGracefully(resp)
+
+	responseBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, types.NewError(err, types.ErrorCodeBadResponseBody)
+	}
+	var cohereResp dto.CohereEmbedResponse
+	if err = common.Unmarshal(responseBody, &cohereResp); err != nil {
+		return nil, types.NewError(err, types.ErrorCodeBadResponseBody)
+	}
+	convertResult, err := relayconvert.ConvertResponse(c, info, types.RelayFormatEmbedding, &cohereResp)
+	if err != nil {
+		return nil, types.NewError(err, types.ErrorCodeBadResponseBody)
+	}
+	embeddingResp, ok := convertResult.Value.(*dto.EmbeddingResponse)
+	if !ok {
+		return nil, types.NewError(fmt.Errorf("unexpected converted embedding response type %T", convertResult.Value), types.ErrorCodeBadResponseBody)
+	}
+	// 未返回 billed_units 时按预估输入用量计费
+	if embeddingResp.Usage.PromptTokens == 0 {
+		embeddingResp.Usage.PromptTokens = info.GetEstimatePromptTokens()
+		embeddingResp.Usage.TotalTokens = info.GetEstimatePromptTokens()
+	}
+
+	jsonResponse, err := common.Marshal(embeddingResp)
+	if err != nil {
+		return nil, types.NewError(err, types.ErrorCodeBadResponseBody)
+	}
+	service.IOCopyBytesGracefully(c, resp, jsonResponse)
+	return &embeddingResp.Usage, nil
+}
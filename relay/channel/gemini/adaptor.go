@@ -17,7 +17,6 @@ import (
 	"github.com/QuantumNous/new-api/types"
 
 	"github.com/gin-gonic/gin"
-	"github.com/samber/lo"
 )
 
 type Adaptor struct {
@@ -86,69 +85,30 @@ func (a *Adaptor) ConvertAudioRequest(c *gin.Context, info *relaycommon.RelayInf
 }
 
 func (a *Adaptor) ConvertImageRequest(c *gin.Context, info *relaycommon.RelayInfo, request dto.ImageRequest) (any, error) {
-	if !strings.HasPrefix(info.UpstreamModelName, "imagen") {
-		return nil, errors.New("not supported model for image generation, only imagen models are supported")
-	}
-
-	// convert size to aspect ratio but allow user to specify aspect ratio
-	aspectRatio := "1:1" // default aspect ratio
-	size := strings.TrimSpace(request.Size)
-	if size != "" {
-		if strings.Contains(size, ":") {
-			aspectRatio = size
-		} else {
-			switch size {
-			case "256x256", "512x512", "1024x1024":
-				aspectRatio = "1:1"
-			case "1536x1024":
-				aspectRatio = "3:2"
-			case "1024x1536":
-				aspectRatio = "2:3"
-			case "1024x1792":
-				aspectRatio = "9:16"
-			case "1792x1024":
-				aspectRatio = "16:9"
-			}
+	switch {
+	case strings.HasPrefix(info.UpstreamModelName, "imagen"):
+		result, err := relayconvert.ConvertRequest(c, info, types.RelayFormatGeminiImagen, &request)
+		if err != nil {
+			return nil, err
 		}
-	}
-
-	// build gemini imagen request
-	geminiRequest := dto.GeminiImageRequest{
-		Instances: []dto.GeminiImageInstance{
-			{
-				Prompt: request.Prompt,
-			},
-		},
-		Parameters: dto.GeminiImageParameters{
-			SampleCount:      int(lo.FromPtrOr(request.N, uint(1))),
-			AspectRatio:      aspectRatio,
-			PersonGeneration: "allow_adult", // default allow adult
-		},
-	}
-
-	// Set imageSize when quality parameter is specified
-	// Map quality parameter to imageSize (only supported by Standard and Ultra models)
-	// quality values: auto, high, medium, low (for gpt-image-1), hd, standard (for dall-e-3)
-	// imageSize values: 1K (default), 2K
-	// https://ai.google.dev/gemini-api/docs/imagen
-	// https://platform.openai.com/docs/api-reference/images/create
-	if request.Quality != "" {
-		imageSize := "1K" // default
-		switch request.Quality {
-		case "hd", "high":
-			imageSize = "2K"
-		case "2K":
-			imageSize = "2K"
-		case "standard", "medium", "low", "auto", "1K":
-			imageSize = "1K"
-		default:
-			// unknown quality value, default to 1K
-			imageSize = "1K"
+		return result.Value, nil
+	case isGeminiImageModel(info.UpstreamModelName):
+		// Gemini 图像模型走 generateContent，图片在完整响应中内联返回，不使用流式
+		info.IsStream = false
+		result, err := relayconvert.ConvertRequest(c, info, types.RelayFormatGemini, &request)
+		if err != nil {
+			return nil, err
 		}
-		geminiRequest.Parameters.ImageSize = imageSize
+		return result.Value, nil
+	default:
+		return nil, errors.New("not supported model for image generation, only imagen and gemini image models are supported")
 	}
+}
 
-	return geminiRequest, nil
+// isGeminiImageModel 判断是否为通过 generateContent 输出图片的 Gemini 图像模型，如 gemini-2.5-flash-image、nano-banana-pro-preview
+func isGeminiImageModel(modelName string) bool {
+	return (strings.HasPrefix(modelName, "gemini-") && strings.Contains(modelName, "-image")) ||
+		strings.HasPrefix(modelName, "nano-banana")
 }
 
 func (a *Adaptor) Init(info *relaycommon.RelayInfo) {
@@ -223,44 +183,29 @@ func (a *Adaptor) ConvertEmbeddingRequest(c *gin.Context, info *relaycommon.Rela
 	if request.Input == nil {
 		return nil, errors.New("input is required")
 	}
-
-	inputs := request.ParseInput()
-	if len(inputs) == 0 {
-		return nil, errors.New("input is empty")
-	}
 	// We always build a batch-style payload with `requests`, so ensure we call the
 	// batch endpoint upstream to avoid payload/endpoint mismatches.
 	info.IsGeminiBatchEmbedding = true
-	// process all inputs
-	geminiRequests := make([]map[string]interface{}, 0, len(inputs))
-	for _, input := range inputs {
-		geminiRequest := map[string]interface{}{
-			"model": fmt.Sprintf("models/%s", info.UpstreamModelName),
-			"content": dto.GeminiChatContent{
-				Parts: []dto.GeminiPart{
-					{
-						Text: input,
-					},
-				},
-			},
-		}
+	result, err := relayconvert.ConvertRequest(c, info, types.RelayFormatGeminiEmbedding, &request)
+	if err != nil {
+		return nil, err
+	}
+	batchRequest, ok := result.Value.(*dto.GeminiBatchEmbeddingRequest)
+	if !ok {
+		return nil, fmt.Errorf("expected Gemini batch embedding request, got %T", result.Value)
+	}
 
-		// set specific parameters for different models
-		// https://ai.google.dev/api/embeddings?hl=zh-cn#method:-models.embedcontent
-		switch info.UpstreamModelName {
-		case "text-embedding-004", "gemini-embedding-exp-03-07", "gemini-embedding-001":
-			// Only newer models introduced after 2024 support OutputDimensionality
-			dimensions := lo.FromPtrOr(request.Dimensions, 0)
-			if dimensions > 0 {
-				geminiRequest["outputDimensionality"] = dimensions
-			}
+	// set specific parameters for different models
+	// https://ai.google.dev/api/embeddings?hl=zh-cn#method:-models.embedcontent
+	switch info.UpstreamModelName {
+	case "text-embedding-004", "gemini-embedding-exp-03-07", "gemini-embedding-001":
+		// Only newer models introduced after 2024 support OutputDimensionality
+	default:
+		for _, geminiRequest := range batchRequest.Requests {
+			geminiRequest.OutputDimensionality = 0
 		}
-		geminiRequests = append(geminiRequests, geminiRequest)
 	}
-
-	return map[string]interface{}{
-		"requests": geminiRequests,
-	}, nil
+	return batchRequest, nil
 }
 
 func (a *Adaptor) ConvertOpenAIResponsesRequest(c *gin.Context, info *relaycommon.RelayInfo, request dto.OpenAIResponsesRequest) (any, error) {
@@ -303,6 +248,10 @@ func (a *Adaptor) DoResponse(c *gin.Context, resp *http.Response, info *relaycom
 		return GeminiImageHandler(c, info, resp)
 	}
 
+	if info.RelayMode == constant.RelayModeImagesGenerations && isGeminiImageModel(info.UpstreamModelName) {
+		return GeminiImageGenerationHandler(c, info, resp)
+	}
+
 	// check if the model is an embedding model
 	if strings.HasPrefix(info.UpstreamModelName, "text-embedding") ||
 		strings.HasPrefix(info.UpstreamModelName, "embedding") ||
@@ -392,18 +392,13 @@ func GeminiEmbeddingHandler(c *gin.Context, info *relaycommon.RelayInfo, resp *h
 	}
 
 	// convert to openai format response
-	openAIResponse := dto.OpenAIEmbeddingResponse{
-		Object: "list",
-		Data:   make([]dto.OpenAIEmbeddingResponseItem, 0, len(geminiResponse.Embeddings)),
-		Model:  info.UpstreamModelName,
+	convertResult, convertErr := relayconvert.ConvertResponse(c, info, types.RelayFormatEmbedding, &geminiResponse)
+	if convertErr != nil {
+		return nil, types.NewOpenAIError(convertErr, types.ErrorCodeBadResponseBody, http.StatusInternalServerError)
 	}
-
-	for i, embedding := range geminiResponse.Embeddings {
-		openAIResponse.Data = append(openAIResponse.Data, dto.OpenAIEmbeddingResponseItem{
-			Object:    "embedding",
-			Embedding: embedding.Values,
-			Index:     i,
-		})
+	openAIResponse, ok := convertResult.Value.(*dto.EmbeddingResponse)
+	if !ok {
+		return nil, types.NewOpenAIError(fmt.Errorf("unexpected converted embedding response type %T", convertResult.Value), types.ErrorCodeBadResponseBody, http.StatusInternalServerError)
 	}
 
 	// calculate usage
@@ -440,18 +435,13 @@ func GeminiImageHandler(c *gin.Context, info *relaycommon.RelayInfo, resp *http.
 	}
 
 	// convert to openai format response
-	openAIResponse := dto.ImageResponse{
-		Created: common.GetTimestamp(),
-		Data:    make([]dto.ImageData, 0, len(geminiResponse.Predictions)),
+	convertResult, convertErr := relayconvert.ConvertResponse(c, info, types.RelayFormatOpenAIImage, &geminiResponse)
+	if convertErr != nil {
+		return nil, types.NewOpenAIError(convertErr, types.ErrorCodeBadResponseBody, http.StatusInternalServerError)
 	}
-
-	for _, prediction := range geminiResponse.Predictions {
-		if prediction.RaiFilteredReason != "" {
-			continue // skip filtered image
-		}
-		openAIResponse.Data = append(openAIResponse.Data, dto.ImageData{
-			B64Json: prediction.BytesBase64Encoded,
-		})
+	openAIResponse, ok := convertResult.Value.(*dto.ImageResponse)
+	if !ok {
+		return nil, types.NewOpenAIError(fmt.Errorf("unexpected converted image response type %T", convertResult.Value), types.ErrorCodeBadResponseBody, http.StatusInternalServerError)
 	}
 
 	jsonResponse, jsonErr := common.Marshal(openAIResponse)
@@ -477,6 +467,49 @@ func GeminiImageHandler(c *gin.Context, info *relaycommon.RelayInfo, resp *http.
 	return usage, nil
 }
 
+// GeminiImageGenerationHandler 将 Gemini 图像模型的 generateContent 响应转换为 OpenAI Images 响应，按 usageMetadata 计费
+func GeminiImageGenerationHandler(c *gin.Context, info *relaycommon.RelayInfo, resp *http.Response) (*dto.Usage, *types.NewAPIError) {
+	responseBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, types.NewOpenAIError(err, types.ErrorCodeBadResponseBody, http.StatusInternalServerError)
+	}
+	service.CloseResponseBodyGracefully(resp)
+
+	var geminiResponse dto.GeminiChatResponse
+	if err := common.Unmarshal(responseBody, &geminiResponse); err != nil {
+		return nil, types.NewOpenAIError(err, types.ErrorCodeBadResponseBody, http.StatusInternalServerError)
+	}
+	if geminiResponse.PromptFeedback != nil && geminiResponse.PromptFeedback.BlockReason != nil {
+		common.SetContextKey(c, constant.ContextKeyAdminRejectReason, fmt.Sprintf("gemini_block_reason=%s", *geminiResponse.PromptFeedback.BlockReason))
+		return nil, types.NewOpenAIError(
+			errors.New("request blocked by Gemini API: "+*geminiResponse.PromptFeedback.BlockReason),
+			types.ErrorCodePromptBlocked,
+			http.StatusBadRequest,
+		)
+	}
+
+	convertResult, err := relayconvert.ConvertResponse(c, info, types.RelayFormatOpenAIImage, &geminiResponse)
+	if err != nil {
+		return nil, types.NewOpenAIError(err, types.ErrorCodeBadResponseBody, http.StatusInternalServerError)
+	}
+	openAIResponse, ok := convertResult.Value.(*dto.ImageResponse)
+	if !ok {
+		return nil, types.NewOpenAIError(fmt.Errorf("unexpected converted image response type %T", convertResult.Value), types.ErrorCodeBadResponseBody, http.StatusInternalServerError)
+	}
+	if len(openAIResponse.Data) == 0 {
+		return nil, types.NewOpenAIError(errors.New("no images generated"), types.ErrorCodeBadResponseBody, http.StatusInternalServerError)
+	}
+
+	jsonResponse, err := common.Marshal(openAIResponse)
+	if err != nil {
+		return nil, types.NewError(err, types.ErrorCodeBadResponseBody)
+	}
+	service.IOCopyBytesGracefully(c, resp, jsonResponse)
+
+	usage := buildUsageFromGeminiResponse(c, info, &geminiResponse)
+	return &usage, nil
+}
+
 type GeminiModelsResponse struct {
 	Models        []dto.GeminiModel `json:"models"`
 	NextPageToken string            `json:"nextPageToken"`
@@ -70,6 +70,7 @@ func GeminiResponsesHandler(c *gin.Context, info *relaycommon.RelayInfo, resp *h
 		responsesResp.Usage = relayconvert.UsageFromChatUsage(&usage)
 	}
 
+	service.StoreEmulatedResponse(c, responsesResp)
 	responseBody, err = common.Marshal(responsesResp)
 	if err != nil {
 		return nil, types.NewOpenAIError(err, types.ErrorCodeJsonMarshalFailed, http.StatusInternalServerError)
@@ -95,6 +96,9 @@ func GeminiResponsesStreamHandler(c *gin.Context, info *relaycommon.RelayInfo, r
 	var streamErr *types.NewAPIError
 
 	sendEvent := func(event relayconvert.ChatToResponsesStreamEvent) bool {
+		if event.Type == dto.ResponsesOutputTypeCompleted && event.Payload.Response != nil {
+			service.StoreEmulatedResponse(c, event.Payload.Response)
+		}
 		data, err := common.Marshal(event.Payload)
 		if err != nil {
 			streamErr = types.NewOpenAIError(err, types.ErrorCodeJsonMarshalFailed, http.StatusInternalServerError)
@@ -0,0 +1,74 @@
+package openai
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/dto"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+func newConvertedIngressContext(path string) *gin.Context {
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, path, nil)
+	return c
+}
+
+func requireUpstreamURLs(t *testing.T, info *relaycommon.RelayInfo, openAIURL string, azureURL string) {
+	t.Helper()
+	adaptor := &Adaptor{}
+
+	info.ChannelMeta = &relaycommon.ChannelMeta{
+		ChannelType:       constant.ChannelTypeOpenAI,
+		ChannelBaseUrl:    "https://api.openai.com",
+		UpstreamModelName: "gpt-4o",
+	}
+	got, err := adaptor.GetRequestURL(info)
+	require.NoError(t, err)
+	require.Equal(t, openAIURL, got)
+
+	info.ChannelMeta = &relaycommon.ChannelMeta{
+		ChannelType:       constant.ChannelTypeAzure,
+		ChannelBaseUrl:    "https://example.openai.azure.com",
+		ApiVersion:        "2024-10-21",
+		UpstreamModelName: "gpt-4o",
+		ChannelCreateTime: constant.AzureNoRemoveDotTime,
+	}
+	got, err = adaptor.GetRequestURL(info)
+	require.NoError(t, err)
+	require.Equal(t, azureURL, got)
+}
+
+func TestGetRequestURLForOllamaIngress(t *testing.T) {
+	chatInfo, err := relaycommon.GenRelayInfoOllama(newConvertedIngressContext("/api/chat"), &dto.GeneralOpenAIRequest{Model: "gpt-4o"})
+	require.NoError(t, err)
+	requireUpstreamURLs(t, chatInfo,
+		"https://api.openai.com/v1/chat/completions",
+		"https://example.openai.azure.com/openai/deployments/gpt-4o/chat/completions?api-version=2024-10-21")
+
+	embeddingInfo, err := relaycommon.GenRelayInfoOllama(newConvertedIngressContext("/api/embed"), &dto.EmbeddingRequest{Model: "gpt-4o"})
+	require.NoError(t, err)
+	requireUpstreamURLs(t, embeddingInfo,
+		"https://api.openai.com/v1/embeddings",
+		"https://example.openai.azure.com/openai/deployments/gpt-4o/embeddings?api-version=2024-10-21")
+}
+
+func TestGetRequestURLForCohereEmbeddingIngress(t *testing.T) {
+	info := relaycommon.GenRelayInfoCohereEmbedding(newConvertedIngressContext("/v2/embed"), &dto.EmbeddingRequest{Model: "gpt-4o"})
+	requireUpstreamURLs(t, info,
+		"https://api.openai.com/v1/embeddings",
+		"https://example.openai.azure.com/openai/deployments/gpt-4o/embeddings?api-version=2024-10-21")
+}
+
+func TestGetRequestURLForBedrockConverseIngress(t *testing.T) {
+	info := relaycommon.GenRelayInfoBedrockConverse(newConvertedIngressContext("/model/gpt-4o/converse"), &dto.ClaudeRequest{Model: "gpt-4o"})
+	requireUpstreamURLs(t, info,
+		"https://api.openai.com/v1/chat/completions",
+		"https://example.openai.azure.com/openai/deployments/gpt-4o/chat/completions?api-version=2024-10-21")
+}
@@ -53,6 +53,7 @@ func OaiChatToResponsesHandler(c *gin.Context, info *relaycommon.RelayInfo, resp
 		responsesResp.Usage = relayconvert.UsageFromChatUsage(usage)
 	}
 
+	service.StoreEmulatedResponse(c, responsesResp)
 	responseBody, err := common.Marshal(responsesResp)
 	if err != nil {
 		return nil, types.NewOpenAIError(err, types.ErrorCodeJsonMarshalFailed, http.StatusInternalServerError)
@@ -79,6 +80,9 @@ func OaiChatToResponsesStreamHandler(c *gin.Context, info *relaycommon.RelayInfo
 	streamErr := (*types.NewAPIError)(nil)
 
 	sendEvent := func(event relayconvert.ChatToResponsesStreamEvent) bool {
+		if event.Type == dto.ResponsesOutputTypeCompleted && event.Payload.Response != nil {
+			service.StoreEmulatedResponse(c, event.Payload.Response)
+		}
 		data, err := common.Marshal(event.Payload)
 		if err != nil {
 			streamErr = types.NewOpenAIError(err, types.ErrorCodeJsonMarshalFailed, http.StatusInternalServerError)
@@ -153,6 +153,7 @@ type RelayInfo struct {
 	UsingGroup        string // 使用的分组，当auto跨分组重试时，会变动
 	UserGroup         string // 用户所在分组
 	TokenUnlimited    bool
+	OrganizationId    int // 组织令牌所属组织，非 0 时从组织钱包计费
 	StartTime         time.Time
 	FirstResponseTime time.Time
 	isFirstResponse   bool
@@ -193,8 +194,8 @@ type RelayInfo struct {
 	// Billing 是计费会话，封装了预扣费/结算/退款的统一生命周期。
 	// 免费模型时为 nil。
 	Billing BillingSettler
-	// BillingSource indicates whether this request is billed from wallet quota or subscription.
-	// "" or "wallet" => wallet; "subscription" => subscription
+	// BillingSource indicates whether this request is billed from wallet quota, subscription or organization wallet.
+	// "" or "wallet" => wallet; "subscription" => subscription; "organization" => organization wallet
 	BillingSource string
 	// SubscriptionId is the user_subscriptions.id used when BillingSource == "subscription"
 	SubscriptionId int
@@ -205,6 +206,14 @@ type RelayInfo struct {
 	// SubscriptionPlanId / SubscriptionPlanTitle are used for logging/UI display.
 	SubscriptionPlanId    int
 	SubscriptionPlanTitle string
+	// SubscriptionBucketId is the user_subscription_buckets.id when the request matched a plan quota bucket (0 = plan total quota).
+	SubscriptionBucketId   int
+	SubscriptionBucketName string
+	SubscriptionBucketUnit string
+	// SubscriptionBucketPreConsumed is the amount pre-consumed on the bucket in the bucket's own unit.
+	SubscriptionBucketPreConsumed int64
+	// UsageTotalTokens is the total tokens of the finished request, set before settlement for token-measured buckets.
+	UsageTotalTokens int
 	// RequestId is used for idempotent pre-consume/refund
 	RequestId string
 	// SubscriptionAmountTotal / SubscriptionAmountUsedAfterPreConsume are used to compute remaining in logs.
@@ -500,6 +509,40 @@ func GenRelayInfoImage(c *gin.Context, request dto.Request) *RelayInfo {
 	return info
 }
 
+// GenRelayInfoOllama Ollama 入站请求在校验阶段已转换为 OpenAI 格式，按 OpenAI 处理并在转换链首记录 ollama
+func GenRelayInfoOllama(c *gin.Context, request dto.Request) (*RelayInfo, error) {
+	var info *RelayInfo
+	// 上游按 OpenAI 路径请求，不能沿用 /api/chat、/api/embed 等入站路径
+	switch request.(type) {
+	case *dto.GeneralOpenAIRequest:
+		info = GenRelayInfoOpenAI(c, request)
+		info.RequestURLPath = "/v1/chat/completions"
+	case *dto.EmbeddingRequest:
+		info = GenRelayInfoEmbedding(c, request)
+		info.RequestURLPath = "/v1/embeddings"
+	default:
+		return nil, fmt.Errorf("unexpected ollama request type %T", request)
+	}
+	info.RequestConversionChain = []types.RelayFormat{types.RelayFormatOllama, info.RelayFormat}
+	return info, nil
+}
+
+// GenRelayInfoBedrockConverse Bedrock Converse 入站请求在校验阶段已转换为 Claude 格式，按 Claude 处理并在转换链首记录 bedrock_converse
+func GenRelayInfoBedrockConverse(c *gin.Context, request dto.Request) *RelayInfo {
+	info := GenRelayInfoClaude(c, request)
+	info.RequestURLPath = "/v1/messages"
+	info.RequestConversionChain = []types.RelayFormat{types.RelayFormatBedrockConverse, info.RelayFormat}
+	return info
+}
+
+// GenRelayInfoCohereEmbedding Cohere /embed 入站请求在校验阶段已转换为 OpenAI Embeddings 格式，在转换链首记录 cohere_embedding
+func GenRelayInfoCohereEmbedding(c *gin.Context, request dto.Request) *RelayInfo {
+	info := GenRelayInfoEmbedding(c, request)
+	info.RequestURLPath = "/v1/embeddings"
+	info.RequestConversionChain = []types.RelayFormat{types.RelayFormatCohereEmbedding, info.RelayFormat}
+	return info
+}
+
 func GenRelayInfoOpenAI(c *gin.Context, request dto.Request) *RelayInfo {
 	info := genBaseRelayInfo(c, request)
 	info.RelayFormat = types.RelayFormatOpenAI
@@ -551,6 +594,7 @@ func genBaseRelayInfo(c *gin.Context, request dto.Request) *RelayInfo {
 		TokenId:        common.GetContextKeyInt(c, constant.ContextKeyTokenId),
 		TokenKey:       common.GetContextKeyString(c, constant.ContextKeyTokenKey),
 		TokenUnlimited: common.GetContextKeyBool(c, constant.ContextKeyTokenUnlimited),
+		OrganizationId: common.GetContextKeyInt(c, constant.ContextKeyTokenOrganizationId),
 		TokenGroup:     tokenGroup,
 
 		isFirstResponse: true,
@@ -635,6 +679,12 @@ func GenRelayInfo(c *gin.Context, relayFormat types.RelayFormat, request dto.Req
 		info = GenRelayInfoGemini(c, request)
 	case types.RelayFormatEmbedding:
 		info = GenRelayInfoEmbedding(c, request)
+	case types.RelayFormatOllama:
+		info, err = GenRelayInfoOllama(c, request)
+	case types.RelayFormatBedrockConverse:
+		info = GenRelayInfoBedrockConverse(c, request)
+	case types.RelayFormatCohereEmbedding:
+		info = GenRelayInfoCohereEmbedding(c, request)
 	case types.RelayFormatOpenAIResponses:
 		if request, ok := request.(*dto.OpenAIResponsesRequest); ok {
 			info = GenRelayInfoResponses(c, request)
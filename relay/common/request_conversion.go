@@ -9,6 +9,8 @@ func GuessRelayFormatFromRequest(req any) (types.RelayFormat, bool) {
 	switch req.(type) {
 	case *dto.GeneralOpenAIRequest, dto.GeneralOpenAIRequest:
 		return types.RelayFormatOpenAI, true
+	case *dto.CompletionsRequest, dto.CompletionsRequest:
+		return types.RelayFormatOpenAICompletions, true
 	case *dto.OpenAIResponsesRequest, dto.OpenAIResponsesRequest:
 		return types.RelayFormatOpenAIResponses, true
 	case *dto.ClaudeRequest, dto.ClaudeRequest:
@@ -23,6 +25,17 @@ func GuessRelayFormatFromRequest(req any) (types.RelayFormat, bool) {
 		return types.RelayFormatOpenAIImage, true
 	case *dto.AudioRequest, dto.AudioRequest:
 		return types.RelayFormatOpenAIAudio, true
+	case *dto.OllamaChatRequest, dto.OllamaChatRequest, *dto.OllamaGenerateRequest, dto.OllamaGenerateRequest,
+		*dto.OllamaEmbedRequest, dto.OllamaEmbedRequest:
+		return types.RelayFormatOllama, true
+	case *dto.BedrockConverseRequest, dto.BedrockConverseRequest:
+		return types.RelayFormatBedrockConverse, true
+	case *dto.GeminiEmbeddingRequest, dto.GeminiEmbeddingRequest, *dto.GeminiBatchEmbeddingRequest, dto.GeminiBatchEmbeddingRequest:
+		return types.RelayFormatGeminiEmbedding, true
+	case *dto.CohereEmbedRequest, dto.CohereEmbedRequest:
+		return types.RelayFormatCohereEmbedding, true
+	case *dto.GeminiImageRequest, dto.GeminiImageRequest:
+		return types.RelayFormatGeminiImagen, true
 	default:
 		return "", false
 	}
@@ -65,13 +65,26 @@ func TextHelper(c *gin.Context, info *relaycommon.RelayInfo) (newAPIError *types
 
 	info.ShouldIncludeUsage = includeUsage
 
+	passThroughGlobal := model_setting.GetGlobalSettings().PassThroughRequestEnabled
+	// 上游没有 completions 接口时改写为 Chat Completions，需在 adaptor.Init 之前切换 RelayMode
+	if info.RelayMode == relayconstant.RelayModeCompletions &&
+		!passThroughGlobal &&
+		!info.ChannelSetting.PassThroughBodyEnabled &&
+		service.ShouldCompletionsUseChatGlobal(info.ChannelId, info.ChannelType, info.ApiType, info.OriginModelName) {
+		chatRequest, finish, err := completionsViaChat(c, info, request)
+		if err != nil {
+			return types.NewErrorWithStatusCode(err, types.ErrorCodeConvertRequestFailed, http.StatusBadRequest, types.ErrOptionWithSkipRetry())
+		}
+		defer finish()
+		request = chatRequest
+	}
+
 	adaptor := GetAdaptor(info.ApiType)
 	if adaptor == nil {
 		return types.NewError(fmt.Errorf("invalid api type: %d", info.ApiType), types.ErrorCodeInvalidApiType, types.ErrOptionWithSkipRetry())
 	}
 	adaptor.Init(info)
 
-	passThroughGlobal := model_setting.GetGlobalSettings().PassThroughRequestEnabled
 	if info.RelayMode == relayconstant.RelayModeChatCompletions &&
 		!passThroughGlobal &&
 		!info.ChannelSetting.PassThroughBodyEnabled &&
@@ -0,0 +1,62 @@
+package relay
+
+import (
+	"fmt"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	relayconstant "github.com/QuantumNous/new-api/relay/constant"
+	"github.com/QuantumNous/new-api/relay/helper"
+	"github.com/QuantumNous/new-api/service"
+	"github.com/QuantumNous/new-api/service/relayconvert"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// completionsViaChat 把 /v1/completions 请求改写为 Chat Completions 请求发往只支持对话接口的上游，
+// 响应经 CompletionsResponseWriter 转回 text_completion。返回的 finish 需在请求结束时调用，用于输出剩余内容并恢复 RelayInfo
+func completionsViaChat(c *gin.Context, info *relaycommon.RelayInfo, request *dto.GeneralOpenAIRequest) (*dto.GeneralOpenAIRequest, func(), error) {
+	// GeneralOpenAIRequest 没有 echo 等旧版字段，按 completions 协议重新解析原始请求体
+	completionsRequest := &dto.CompletionsRequest{}
+	if err := common.UnmarshalBodyReusable(c, completionsRequest); err != nil {
+		return nil, nil, err
+	}
+	completionsRequest.Model = request.Model
+	completionsRequest.StreamOptions = request.StreamOptions
+
+	savedRelayMode := info.RelayMode
+	savedRequestURLPath := info.RequestURLPath
+	savedConversionChain := info.RequestConversionChain
+	restore := func() {
+		info.RelayMode = savedRelayMode
+		info.RequestURLPath = savedRequestURLPath
+		info.RequestConversionChain = savedConversionChain
+	}
+
+	info.RequestConversionChain = []types.RelayFormat{types.RelayFormatOpenAICompletions}
+	result, err := service.ConvertRequestByID(c, info, relayconvert.ConverterOpenAICompletionsToOpenAIChat, completionsRequest)
+	if err != nil {
+		restore()
+		return nil, nil, err
+	}
+	chatRequest, ok := result.Value.(*dto.GeneralOpenAIRequest)
+	if !ok {
+		restore()
+		return nil, nil, fmt.Errorf("expected OpenAI chat completions request, got %T", result.Value)
+	}
+
+	echo := ""
+	if completionsRequest.Echo {
+		echo, _ = completionsRequest.PromptText()
+	}
+	info.RelayMode = relayconstant.RelayModeChatCompletions
+	info.RequestURLPath = "/v1/chat/completions"
+	writer := helper.NewCompletionsResponseWriter(c, info, echo)
+	c.Writer = writer
+	return chatRequest, func() {
+		writer.Finish()
+		restore()
+	}, nil
+}
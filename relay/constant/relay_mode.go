@@ -94,6 +94,17 @@ func Path2RelayMode(path string) int {
 		relayMode = RelayModeGemini
 	} else if strings.HasPrefix(path, "/mj") {
 		relayMode = Path2RelayModeMidjourney(path)
+	} else if path == "/api/chat" || path == "/api/generate" || strings.HasPrefix(path, "/api/v0/chat/completions") {
+		// Ollama 原生与 LM Studio 风格接口
+		relayMode = RelayModeChatCompletions
+	} else if path == "/api/embed" || path == "/v1/embed" || path == "/v2/embed" {
+		// Ollama 与 Cohere 原生向量接口
+		relayMode = RelayModeEmbeddings
+	} else if strings.HasPrefix(path, "/api/v0/completions") {
+		relayMode = RelayModeCompletions
+	} else if strings.HasPrefix(path, "/model/") && (strings.HasSuffix(path, "/converse") || strings.HasSuffix(path, "/converse-stream")) {
+		// AWS Bedrock Converse / ConverseStream
+		relayMode = RelayModeChatCompletions
 	}
 	return relayMode
 }
@@ -23,7 +23,11 @@ func EmbeddingHelper(c *gin.Context, info *relaycommon.RelayInfo) (newAPIError *
 	if !ok {
 		return types.NewErrorWithStatusCode(fmt.Errorf("invalid request type, expected *dto.EmbeddingRequest, got %T", info.Request), types.ErrorCodeInvalidRequest, http.StatusBadRequest, types.ErrOptionWithSkipRetry())
 	}
+	return relayEmbeddingRequest(c, info, embeddingReq)
+}
 
+// relayEmbeddingRequest 以 OpenAI Embeddings 请求调用渠道适配器，响应由适配器统一转换为 OpenAI 格式
+func relayEmbeddingRequest(c *gin.Context, info *relaycommon.RelayInfo, embeddingReq *dto.EmbeddingRequest) (newAPIError *types.NewAPIError) {
 	request, err := common.DeepCopy(embeddingReq)
 	if err != nil {
 		return types.NewError(fmt.Errorf("failed to copy request to EmbeddingRequest: %w", err), types.ErrorCodeInvalidRequest, types.ErrOptionWithSkipRetry())
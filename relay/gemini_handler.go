@@ -12,6 +12,7 @@ import (
 	"github.com/QuantumNous/new-api/logger"
 	"github.com/QuantumNous/new-api/monitor"
 	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	relayconstant "github.com/QuantumNous/new-api/relay/constant"
 	"github.com/QuantumNous/new-api/relay/helper"
 	"github.com/QuantumNous/new-api/service"
 	"github.com/QuantumNous/new-api/service/relayconvert"
@@ -71,6 +72,10 @@ func GeminiHelper(c *gin.Context, info *relaycommon.RelayInfo) (newAPIError *typ
 		return types.NewError(err, types.ErrorCodeChannelModelMappedError, types.ErrOptionWithSkipRetry())
 	}
 
+	if info.ApiType != constant.APITypeGemini && common.IsImageGenerationModel(info.UpstreamModelName) {
+		return geminiImageViaOpenAI(c, info, request)
+	}
+
 	if model_setting.GetGeminiSettings().ThinkingAdapterEnabled {
 		if isNoThinkingRequest(request) {
 			// check is thinking
@@ -274,6 +279,10 @@ func GeminiEmbeddingHandler(c *gin.Context, info *relaycommon.RelayInfo) (newAPI
 		}
 	}
 
+	if info.ApiType != constant.APITypeGemini {
+		return geminiEmbeddingViaOpenAI(c, info, req)
+	}
+
 	err = helper.ModelMappedHelper(c, info, req)
 	if err != nil {
 		return types.NewError(err, types.ErrorCodeChannelModelMappedError, types.ErrOptionWithSkipRetry())
@@ -340,3 +349,78 @@ func GeminiEmbeddingHandler(c *gin.Context, info *relaycommon.RelayInfo) (newAPI
 	service.PostTextConsumeQuota(c, info, usage.(*dto.Usage), nil)
 	return nil
 }
+
+// geminiEmbeddingViaOpenAI 非 Gemini 渠道无法直接处理 embedContent/batchEmbedContents，
+// 先将请求转换为 OpenAI Embeddings 经渠道适配器发送，再把响应转回 Gemini 格式
+func geminiEmbeddingViaOpenAI(c *gin.Context, info *relaycommon.RelayInfo, req dto.Request) *types.NewAPIError {
+	result, err := relayconvert.ConvertRequest(c, info, types.RelayFormatEmbedding, req)
+	if err != nil {
+		return types.NewError(err, types.ErrorCodeConvertRequestFailed, types.ErrOptionWithSkipRetry())
+	}
+	embeddingReq, ok := result.Value.(*dto.EmbeddingRequest)
+	if !ok {
+		return types.NewError(fmt.Errorf("unexpected converted gemini embedding request type %T", result.Value), types.ErrorCodeConvertRequestFailed, types.ErrOptionWithSkipRetry())
+	}
+	embeddingReq.Model = info.OriginModelName
+	info.RequestConversionChain = []types.RelayFormat{types.RelayFormatGeminiEmbedding, types.RelayFormatEmbedding}
+
+	defer useOpenAIEmbeddingRelayInfo(info)()
+
+	writer := helper.NewEmbeddingResponseWriter(c, info, types.RelayFormatGeminiEmbedding)
+	c.Writer = writer
+	defer writer.Finish()
+	return relayEmbeddingRequest(c, info, embeddingReq)
+}
+
+// useOpenAIEmbeddingRelayInfo 把 RelayInfo 切换为 OpenAI Embeddings 请求，上游按 /v1/embeddings 构造地址；
+// RelayInfo 会在重试间复用，返回的函数用于恢复原值
+func useOpenAIEmbeddingRelayInfo(info *relaycommon.RelayInfo) func() {
+	savedRelayMode := info.RelayMode
+	savedRelayFormat := info.RelayFormat
+	savedRequestURLPath := info.RequestURLPath
+
+	info.RelayMode = relayconstant.RelayModeEmbeddings
+	info.RelayFormat = types.RelayFormatEmbedding
+	info.RequestURLPath = "/v1/embeddings"
+	return func() {
+		info.RelayMode = savedRelayMode
+		info.RelayFormat = savedRelayFormat
+		info.RequestURLPath = savedRequestURLPath
+	}
+}
+
+// geminiImageViaOpenAI 非 Gemini 渠道的图像生成模型（dall-e、gpt-image、flux 等）无法处理 generateContent，
+// 先将请求转换为 OpenAI Images 经渠道适配器发送，再把响应转回 Gemini 格式
+func geminiImageViaOpenAI(c *gin.Context, info *relaycommon.RelayInfo, request *dto.GeminiChatRequest) *types.NewAPIError {
+	result, err := relayconvert.ConvertRequest(c, info, types.RelayFormatOpenAIImage, request)
+	if err != nil {
+		return types.NewError(err, types.ErrorCodeConvertRequestFailed, types.ErrOptionWithSkipRetry())
+	}
+	imageReq, ok := result.Value.(*dto.ImageRequest)
+	if !ok {
+		return types.NewError(fmt.Errorf("unexpected converted gemini image request type %T", result.Value), types.ErrorCodeConvertRequestFailed, types.ErrOptionWithSkipRetry())
+	}
+	imageReq.Model = info.OriginModelName
+	info.RequestConversionChain = []types.RelayFormat{types.RelayFormatGemini, types.RelayFormatOpenAIImage}
+
+	savedRelayMode := info.RelayMode
+	savedRelayFormat := info.RelayFormat
+	savedRequestURLPath := info.RequestURLPath
+	savedIsStream := info.IsStream
+	defer func() {
+		info.RelayMode = savedRelayMode
+		info.RelayFormat = savedRelayFormat
+		info.RequestURLPath = savedRequestURLPath
+		info.IsStream = savedIsStream
+	}()
+
+	info.RelayMode = relayconstant.RelayModeImagesGenerations
+	info.RelayFormat = types.RelayFormatOpenAIImage
+	info.RequestURLPath = "/v1/images/generations"
+	info.IsStream = false
+
+	writer := helper.NewImageResponseWriter(c, info, types.RelayFormatGemini, savedIsStream)
+	c.Writer = writer
+	defer writer.Finish()
+	return relayImageRequest(c, info, imageReq)
+}
@@ -0,0 +1,49 @@
+package relay
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/relay/channel/openai"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	relayconstant "github.com/QuantumNous/new-api/relay/constant"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUseOpenAIEmbeddingRelayInfoBuildsOpenAIUpstreamURL(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1beta/models/text-embedding-3-small:batchEmbedContents", nil)
+	info := relaycommon.GenRelayInfoGemini(c, &dto.GeminiBatchEmbeddingRequest{})
+	info.ChannelMeta = &relaycommon.ChannelMeta{
+		ChannelType:       constant.ChannelTypeAzure,
+		ChannelBaseUrl:    "https://example.openai.azure.com",
+		ApiVersion:        "2024-10-21",
+		UpstreamModelName: "text-embedding-3-small",
+		ChannelCreateTime: constant.AzureNoRemoveDotTime,
+	}
+	originalPath := info.RequestURLPath
+
+	restore := useOpenAIEmbeddingRelayInfo(info)
+	url, err := (&openai.Adaptor{}).GetRequestURL(info)
+	require.NoError(t, err)
+	require.Equal(t, "https://example.openai.azure.com/openai/deployments/text-embedding-3-small/embeddings?api-version=2024-10-21", url)
+
+	info.ChannelMeta.ChannelType = constant.ChannelTypeOpenAI
+	info.ChannelMeta.ChannelBaseUrl = "https://api.openai.com"
+	url, err = (&openai.Adaptor{}).GetRequestURL(info)
+	require.NoError(t, err)
+	require.Equal(t, "https://api.openai.com/v1/embeddings", url)
+
+	// RelayInfo 在重试间复用，恢复后需回到 Gemini 入站状态
+	restore()
+	require.Equal(t, originalPath, info.RequestURLPath)
+	require.Equal(t, types.RelayFormat(types.RelayFormatGemini), info.RelayFormat)
+	require.Equal(t, relayconstant.RelayModeGemini, info.RelayMode)
+}
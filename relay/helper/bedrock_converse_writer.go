@@ -0,0 +1,208 @@
+package helper
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/logger"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/service/relayconvert"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/aws/aws-sdk-go-v2/aws/protocol/eventstream"
+	"github.com/gin-gonic/gin"
+)
+
+// BedrockConverseResponseWriter 把 Claude Messages 格式的响应转写为 Bedrock Converse 格式：SSE 流逐事件转换为
+// AWS event stream 二进制帧，非流式响应缓冲到 Finish 时整体转换。错误状态码的响应原样透传
+type BedrockConverseResponseWriter struct {
+	gin.ResponseWriter
+
+	c    *gin.Context
+	info *relaycommon.RelayInfo
+
+	mode    transcodeWriteMode
+	pending []byte
+	body    bytes.Buffer
+	state   *relayconvert.ResponseStreamState
+	encoder *eventstream.Encoder
+}
+
+// NewBedrockConverseResponseWriter 包装当前 c.Writer，调用方需在请求结束时调用 Finish 输出剩余内容并恢复原 Writer
+func NewBedrockConverseResponseWriter(c *gin.Context, info *relaycommon.RelayInfo) *BedrockConverseResponseWriter {
+	return &BedrockConverseResponseWriter{
+		ResponseWriter: c.Writer,
+		c:              c,
+		info:           info,
+		encoder:        eventstream.NewEncoder(),
+	}
+}
+
+func (w *BedrockConverseResponseWriter) Write(data []byte) (int, error) {
+	w.decideMode()
+	switch w.mode {
+	case transcodeWriteModeStream:
+		w.pending = append(w.pending, data...)
+		if err := w.drainLines(); err != nil {
+			return 0, err
+		}
+		return len(data), nil
+	case transcodeWriteModeBuffer:
+		return w.body.Write(data)
+	default:
+		return w.ResponseWriter.Write(data)
+	}
+}
+
+func (w *BedrockConverseResponseWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *BedrockConverseResponseWriter) WriteHeaderNow() {
+	w.decideMode()
+	if w.mode == transcodeWriteModeBuffer {
+		return
+	}
+	w.ResponseWriter.WriteHeaderNow()
+}
+
+func (w *BedrockConverseResponseWriter) Flush() {
+	w.decideMode()
+	if w.mode == transcodeWriteModeBuffer {
+		return
+	}
+	w.ResponseWriter.Flush()
+}
+
+// Finish 输出 messageStop/metadata 事件或缓冲的非流式响应，并把 c.Writer 恢复为被包装的 Writer
+func (w *BedrockConverseResponseWriter) Finish() {
+	defer func() {
+		w.c.Writer = w.ResponseWriter
+	}()
+
+	switch w.mode {
+	case transcodeWriteModeStream:
+		if len(w.pending) > 0 {
+			w.pending = append(w.pending, '\n')
+			_ = w.drainLines()
+		}
+		if w.state == nil {
+			return
+		}
+		results, err := relayconvert.FinalizeStreamResponse(w.c, w.info, w.state)
+		if err != nil {
+			logger.LogError(w.c, fmt.Sprintf("finalize bedrock converse stream failed: %s", err.Error()))
+			return
+		}
+		for _, result := range results {
+			_ = w.writeEvent(result.Value)
+		}
+		w.ResponseWriter.Flush()
+	case transcodeWriteModeBuffer:
+		w.ResponseWriter.Header().Del("Content-Length")
+		w.ResponseWriter.Header().Set("Content-Type", "application/json")
+		_, _ = w.ResponseWriter.Write(w.convertBody(w.body.Bytes()))
+	}
+}
+
+func (w *BedrockConverseResponseWriter) decideMode() {
+	if w.mode != transcodeWriteModeUndecided {
+		return
+	}
+	header := w.ResponseWriter.Header()
+	switch {
+	case w.ResponseWriter.Status() >= http.StatusBadRequest:
+		w.mode = transcodeWriteModePassthrough
+	case strings.Contains(header.Get("Content-Type"), "text/event-stream"):
+		w.mode = transcodeWriteModeStream
+		header.Set("Content-Type", "application/vnd.amazon.eventstream")
+		header.Del("Content-Length")
+	default:
+		w.mode = transcodeWriteModeBuffer
+	}
+}
+
+// drainLines 逐行解析已接收的 SSE 数据，只处理 data 行，event 名称以负载中的 type 为准
+func (w *BedrockConverseResponseWriter) drainLines() error {
+	for {
+		idx := bytes.IndexByte(w.pending, '\n')
+		if idx < 0 {
+			return nil
+		}
+		line := strings.TrimSpace(string(w.pending[:idx]))
+		w.pending = w.pending[idx+1:]
+
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+		payload := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		if payload == "" || payload == "[DONE]" {
+			continue
+		}
+		var chunk dto.ClaudeResponse
+		if err := common.UnmarshalJsonStr(payload, &chunk); err != nil {
+			continue
+		}
+		if w.state == nil {
+			state, err := relayconvert.NewResponseStreamState(types.RelayFormatClaude, types.RelayFormatBedrockConverse, relayconvert.ResponseStreamOptions{
+				Model: w.info.OriginModelName,
+			})
+			if err != nil {
+				return err
+			}
+			w.state = state
+		}
+		results, err := relayconvert.ConvertStreamResponseChunk(w.c, w.info, w.state, &chunk)
+		if err != nil {
+			logger.LogError(w.c, fmt.Sprintf("convert bedrock converse stream chunk failed: %s", err.Error()))
+			continue
+		}
+		for _, result := range results {
+			if err := w.writeEvent(result.Value); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// writeEvent 以 AWS event stream 帧写出单个事件，事件类型写入 :event-type 头
+func (w *BedrockConverseResponseWriter) writeEvent(value any) error {
+	event, ok := value.(*dto.BedrockConverseStreamEvent)
+	if !ok {
+		return fmt.Errorf("unexpected bedrock converse stream event type %T", value)
+	}
+	payload, err := common.Marshal(event)
+	if err != nil {
+		return err
+	}
+	return w.encoder.Encode(w.ResponseWriter, eventstream.Message{
+		Headers: eventstream.Headers{
+			{Name: ":event-type", Value: eventstream.StringValue(event.EventType)},
+			{Name: ":content-type", Value: eventstream.StringValue("application/json")},
+			{Name: ":message-type", Value: eventstream.StringValue("event")},
+		},
+		Payload: payload,
+	})
+}
+
+// convertBody 把缓冲的 Claude 响应转换为 Converse 格式，无法识别时原样返回
+func (w *BedrockConverseResponseWriter) convertBody(body []byte) []byte {
+	var response dto.ClaudeResponse
+	if err := common.Unmarshal(body, &response); err != nil || response.Type != "message" {
+		return body
+	}
+	result, err := relayconvert.ConvertResponse(w.c, w.info, types.RelayFormatBedrockConverse, &response)
+	if err != nil {
+		logger.LogError(w.c, fmt.Sprintf("convert bedrock converse response failed: %s", err.Error()))
+		return body
+	}
+	data, err := common.Marshal(result.Value)
+	if err != nil {
+		return body
+	}
+	return data
+}
@@ -0,0 +1,98 @@
+package helper
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/aws/aws-sdk-go-v2/aws/protocol/eventstream"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newBedrockConverseTestContext(path string) (*gin.Context, *httptest.ResponseRecorder) {
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	c.Request = httptest.NewRequest(http.MethodPost, path, nil)
+	return c, recorder
+}
+
+func TestBedrockConverseResponseWriterStreamsEventFrames(t *testing.T) {
+	c, recorder := newBedrockConverseTestContext("/model/claude/converse-stream")
+	writer := NewBedrockConverseResponseWriter(c, &relaycommon.RelayInfo{OriginModelName: "claude"})
+	c.Writer = writer
+
+	c.Header("Content-Type", "text/event-stream")
+	sse := "event: message_start\ndata: {\"type\":\"message_start\",\"message\":{\"usage\":{\"input_tokens\":5}}}\n\n" +
+		"event: ping\ndata: {\"type\":\"ping\"}\n\n" +
+		"event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"index\":0,\"delta\":{\"type\":\"text_delta\",\"text\":\"hi\"}}\n\n" +
+		"event: content_block_stop\ndata: {\"type\":\"content_block_stop\",\"index\":0}\n\n" +
+		"event: message_delta\ndata: {\"type\":\"message_delta\",\"delta\":{\"stop_reason\":\"end_turn\"},\"usage\":{\"output_tokens\":2}}\n\n"
+	// 分两段写入，验证跨写入的行缓冲
+	_, err := c.Writer.WriteString(sse[:40])
+	require.NoError(t, err)
+	_, err = c.Writer.WriteString(sse[40:])
+	require.NoError(t, err)
+	writer.Finish()
+
+	assert.Same(t, writer.ResponseWriter, c.Writer)
+	assert.Equal(t, "application/vnd.amazon.eventstream", recorder.Header().Get("Content-Type"))
+
+	decoder := eventstream.NewDecoder()
+	reader := bytes.NewReader(recorder.Body.Bytes())
+	var eventTypes []string
+	var payloads []string
+	for {
+		message, err := decoder.Decode(reader, nil)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		require.NoError(t, err)
+		assert.Equal(t, "event", message.Headers.Get(":message-type").String())
+		eventTypes = append(eventTypes, message.Headers.Get(":event-type").String())
+		payloads = append(payloads, string(message.Payload))
+	}
+	assert.Equal(t, []string{"messageStart", "contentBlockDelta", "contentBlockStop", "messageStop", "metadata"}, eventTypes)
+	assert.JSONEq(t, `{"contentBlockIndex":0,"delta":{"text":"hi"}}`, payloads[1])
+	assert.JSONEq(t, `{"stopReason":"end_turn"}`, payloads[3])
+	assert.Contains(t, payloads[4], `"totalTokens":7`)
+}
+
+func TestBedrockConverseResponseWriterConvertsBufferedBody(t *testing.T) {
+	c, recorder := newBedrockConverseTestContext("/model/claude/converse")
+	writer := NewBedrockConverseResponseWriter(c, &relaycommon.RelayInfo{OriginModelName: "claude"})
+	c.Writer = writer
+
+	c.JSON(http.StatusOK, gin.H{
+		"type":        "message",
+		"content":     []gin.H{{"type": "text", "text": "hello"}},
+		"stop_reason": "max_tokens",
+		"usage":       gin.H{"input_tokens": 3, "output_tokens": 4},
+	})
+	writer.Finish()
+
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.JSONEq(t, `{
+		"output":{"message":{"role":"assistant","content":[{"text":"hello"}]}},
+		"stopReason":"max_tokens",
+		"usage":{"inputTokens":3,"outputTokens":4,"totalTokens":7},
+		"metrics":{"latencyMs":0}
+	}`, recorder.Body.String())
+}
+
+func TestBedrockConverseResponseWriterPassesThroughErrors(t *testing.T) {
+	c, recorder := newBedrockConverseTestContext("/model/claude/converse")
+	writer := NewBedrockConverseResponseWriter(c, &relaycommon.RelayInfo{})
+	c.Writer = writer
+
+	c.JSON(http.StatusBadRequest, gin.H{"message": "bad"})
+	writer.Finish()
+
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+	assert.JSONEq(t, `{"message":"bad"}`, recorder.Body.String())
+}
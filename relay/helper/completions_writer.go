@@ -0,0 +1,232 @@
+package helper
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/logger"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/service/relayconvert"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CompletionsResponseWriter 把 Chat Completions 响应转写为旧版 text_completion：SSE 流逐个分片转换，
+// 非流式响应缓冲到 Finish 时整体转换。echo 为 true 时在每个 choice 的首段文本前补上 prompt。错误状态码的响应原样透传
+type CompletionsResponseWriter struct {
+	gin.ResponseWriter
+
+	c      *gin.Context
+	info   *relaycommon.RelayInfo
+	echo   string
+	echoed map[int]bool
+	// textOffsets 记录每个 choice 已输出的字符数，用于累加流式分片 logprobs 的 text_offset
+	textOffsets map[int]int
+
+	mode    transcodeWriteMode
+	pending []byte
+	body    bytes.Buffer
+}
+
+// NewCompletionsResponseWriter 包装当前 c.Writer，echo 为需要回显的 prompt（不回显时为空），
+// 调用方需在请求结束时调用 Finish 输出剩余内容并恢复原 Writer
+func NewCompletionsResponseWriter(c *gin.Context, info *relaycommon.RelayInfo, echo string) *CompletionsResponseWriter {
+	return &CompletionsResponseWriter{
+		ResponseWriter: c.Writer,
+		c:              c,
+		info:           info,
+		echo:           echo,
+		echoed:         make(map[int]bool),
+		textOffsets:    make(map[int]int),
+	}
+}
+
+func (w *CompletionsResponseWriter) Write(data []byte) (int, error) {
+	w.decideMode()
+	switch w.mode {
+	case transcodeWriteModeStream:
+		w.pending = append(w.pending, data...)
+		if err := w.drainLines(); err != nil {
+			return 0, err
+		}
+		return len(data), nil
+	case transcodeWriteModeBuffer:
+		return w.body.Write(data)
+	default:
+		return w.ResponseWriter.Write(data)
+	}
+}
+
+func (w *CompletionsResponseWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *CompletionsResponseWriter) WriteHeaderNow() {
+	w.decideMode()
+	if w.mode == transcodeWriteModeBuffer {
+		return
+	}
+	w.ResponseWriter.WriteHeaderNow()
+}
+
+func (w *CompletionsResponseWriter) Flush() {
+	w.decideMode()
+	if w.mode == transcodeWriteModeBuffer {
+		return
+	}
+	w.ResponseWriter.Flush()
+}
+
+// Finish 输出未以换行结尾的流式数据或缓冲的非流式响应，并把 c.Writer 恢复为被包装的 Writer
+func (w *CompletionsResponseWriter) Finish() {
+	defer func() {
+		w.c.Writer = w.ResponseWriter
+	}()
+
+	switch w.mode {
+	case transcodeWriteModeStream:
+		if len(w.pending) > 0 {
+			w.pending = append(w.pending, '\n')
+			_ = w.drainLines()
+		}
+		w.ResponseWriter.Flush()
+	case transcodeWriteModeBuffer:
+		w.ResponseWriter.Header().Del("Content-Length")
+		w.ResponseWriter.Header().Set("Content-Type", "application/json; charset=utf-8")
+		_, _ = w.ResponseWriter.Write(w.convertBody(w.body.Bytes()))
+	}
+}
+
+func (w *CompletionsResponseWriter) decideMode() {
+	if w.mode != transcodeWriteModeUndecided {
+		return
+	}
+	header := w.ResponseWriter.Header()
+	switch {
+	case w.ResponseWriter.Status() >= http.StatusBadRequest:
+		w.mode = transcodeWriteModePassthrough
+	case strings.Contains(header.Get("Content-Type"), "text/event-stream"):
+		w.mode = transcodeWriteModeStream
+		header.Del("Content-Length")
+	default:
+		w.mode = transcodeWriteModeBuffer
+	}
+}
+
+// drainLines 逐行解析已接收的 SSE 数据，data 行转换后按 SSE 输出，[DONE] 原样转发，其余行忽略
+func (w *CompletionsResponseWriter) drainLines() error {
+	for {
+		idx := bytes.IndexByte(w.pending, '\n')
+		if idx < 0 {
+			return nil
+		}
+		line := strings.TrimSpace(string(w.pending[:idx]))
+		w.pending = w.pending[idx+1:]
+
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+		payload := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		if payload == "" {
+			continue
+		}
+		if payload == "[DONE]" {
+			if err := w.writeEvent([]byte(payload)); err != nil {
+				return err
+			}
+			continue
+		}
+		var chunk dto.ChatCompletionsStreamResponse
+		if err := common.UnmarshalJsonStr(payload, &chunk); err != nil {
+			continue
+		}
+		result, err := relayconvert.ConvertStreamResponse(w.c, w.info, types.RelayFormatOpenAICompletions, &chunk)
+		if err != nil {
+			logger.LogError(w.c, fmt.Sprintf("convert completions stream chunk failed: %s", err.Error()))
+			continue
+		}
+		completionsChunk, ok := result.Value.(*dto.CompletionsResponse)
+		if !ok || completionsChunk == nil {
+			continue
+		}
+		w.applyTextOffsets(completionsChunk)
+		w.applyEcho(completionsChunk)
+		data, err := common.Marshal(completionsChunk)
+		if err != nil {
+			return err
+		}
+		if err := w.writeEvent(data); err != nil {
+			return err
+		}
+	}
+}
+
+func (w *CompletionsResponseWriter) writeEvent(data []byte) error {
+	event := make([]byte, 0, len(data)+8)
+	event = append(event, "data: "...)
+	event = append(event, data...)
+	event = append(event, '\n', '\n')
+	_, err := w.ResponseWriter.Write(event)
+	return err
+}
+
+// applyTextOffsets 把 logprobs 的 text_offset 平移到该 choice 已输出文本（含回显的 prompt）之后，需在 applyEcho 之前调用
+func (w *CompletionsResponseWriter) applyTextOffsets(response *dto.CompletionsResponse) {
+	for i := range response.Choices {
+		choice := &response.Choices[i]
+		if _, seen := w.textOffsets[choice.Index]; !seen && w.echo != "" {
+			w.textOffsets[choice.Index] = utf8.RuneCountInString(w.echo)
+		}
+		if logprobs, ok := choice.Logprobs.(*dto.CompletionsLogprobs); ok && logprobs != nil {
+			for j := range logprobs.TextOffset {
+				logprobs.TextOffset[j] += w.textOffsets[choice.Index]
+			}
+		}
+		w.textOffsets[choice.Index] += utf8.RuneCountInString(choice.Text)
+	}
+}
+
+// applyEcho 在每个 choice 第一次出现时把 prompt 拼到文本前面
+func (w *CompletionsResponseWriter) applyEcho(response *dto.CompletionsResponse) {
+	if w.echo == "" {
+		return
+	}
+	for i := range response.Choices {
+		index := response.Choices[i].Index
+		if w.echoed[index] {
+			continue
+		}
+		w.echoed[index] = true
+		response.Choices[i].Text = w.echo + response.Choices[i].Text
+	}
+}
+
+// convertBody 把缓冲的 Chat Completions 响应转换为 text_completion，无法识别时原样返回
+func (w *CompletionsResponseWriter) convertBody(body []byte) []byte {
+	var response dto.OpenAITextResponse
+	if err := common.Unmarshal(body, &response); err != nil {
+		return body
+	}
+	result, err := relayconvert.ConvertResponse(w.c, w.info, types.RelayFormatOpenAICompletions, &response)
+	if err != nil {
+		logger.LogError(w.c, fmt.Sprintf("convert completions response failed: %s", err.Error()))
+		return body
+	}
+	completionsResponse, ok := result.Value.(*dto.CompletionsResponse)
+	if !ok {
+		return body
+	}
+	w.applyTextOffsets(completionsResponse)
+	w.applyEcho(completionsResponse)
+	data, err := common.Marshal(completionsResponse)
+	if err != nil {
+		return body
+	}
+	return data
+}
@@ -0,0 +1,136 @@
+package helper
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newCompletionsTestContext() (*gin.Context, *httptest.ResponseRecorder) {
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/completions", nil)
+	return c, recorder
+}
+
+func TestCompletionsResponseWriterStreamsTextCompletionChunks(t *testing.T) {
+	c, recorder := newCompletionsTestContext()
+	writer := NewCompletionsResponseWriter(c, &relaycommon.RelayInfo{}, "1 + 1 =")
+	c.Writer = writer
+
+	c.Header("Content-Type", "text/event-stream")
+	sse := "data: {\"id\":\"chatcmpl-1\",\"choices\":[{\"index\":0,\"delta\":{\"role\":\"assistant\"}}]}\n\n" +
+		": keep-alive\n\n" +
+		"data: {\"id\":\"chatcmpl-1\",\"choices\":[{\"index\":0,\"delta\":{\"content\":\" 2\"}}]}\n\n" +
+		"data: {\"id\":\"chatcmpl-1\",\"choices\":[{\"index\":0,\"delta\":{\"content\":\".\"},\"finish_reason\":\"stop\"}]}\n\n" +
+		"data: [DONE]\n\n"
+	// 分两段写入，验证跨写入的行缓冲
+	_, err := c.Writer.WriteString(sse[:50])
+	require.NoError(t, err)
+	_, err = c.Writer.WriteString(sse[50:])
+	require.NoError(t, err)
+	writer.Finish()
+
+	assert.Same(t, writer.ResponseWriter, c.Writer)
+	assert.Equal(t, "text/event-stream", recorder.Header().Get("Content-Type"))
+
+	var events []string
+	for _, line := range strings.Split(recorder.Body.String(), "\n") {
+		if strings.HasPrefix(line, "data: ") {
+			events = append(events, strings.TrimPrefix(line, "data: "))
+		}
+	}
+	require.Len(t, events, 3)
+	assert.Equal(t, "[DONE]", events[2])
+
+	var first, last dto.CompletionsResponse
+	require.NoError(t, common.UnmarshalJsonStr(events[0], &first))
+	require.NoError(t, common.UnmarshalJsonStr(events[1], &last))
+	assert.Equal(t, "text_completion", first.Object)
+	assert.Equal(t, "cmpl-1", first.Id)
+	// echo 只拼在每个 choice 的首段文本前
+	assert.Equal(t, "1 + 1 = 2", first.Choices[0].Text)
+	assert.Equal(t, ".", last.Choices[0].Text)
+	assert.Equal(t, "stop", *last.Choices[0].FinishReason)
+}
+
+func TestCompletionsResponseWriterConvertsBufferedBody(t *testing.T) {
+	c, recorder := newCompletionsTestContext()
+	writer := NewCompletionsResponseWriter(c, &relaycommon.RelayInfo{}, "")
+	c.Writer = writer
+
+	c.JSON(http.StatusOK, gin.H{
+		"id":      "chatcmpl-2",
+		"object":  "chat.completion",
+		"created": 1700000000,
+		"model":   "claude-sonnet",
+		"choices": []gin.H{{"index": 0, "message": gin.H{"role": "assistant", "content": "hello"}, "finish_reason": "length"}},
+		"usage":   gin.H{"prompt_tokens": 3, "completion_tokens": 2, "total_tokens": 5},
+	})
+	writer.Finish()
+
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	var response dto.CompletionsResponse
+	require.NoError(t, common.Unmarshal(recorder.Body.Bytes(), &response))
+	assert.Equal(t, "cmpl-2", response.Id)
+	assert.Equal(t, "text_completion", response.Object)
+	assert.Equal(t, int64(1700000000), response.Created)
+	require.Len(t, response.Choices, 1)
+	assert.Equal(t, "hello", response.Choices[0].Text)
+	assert.Equal(t, "length", *response.Choices[0].FinishReason)
+	assert.Equal(t, 5, response.Usage.TotalTokens)
+}
+
+func TestCompletionsResponseWriterPassesThroughErrors(t *testing.T) {
+	c, recorder := newCompletionsTestContext()
+	writer := NewCompletionsResponseWriter(c, &relaycommon.RelayInfo{}, "")
+	c.Writer = writer
+
+	c.JSON(http.StatusBadRequest, gin.H{"error": gin.H{"message": "bad"}})
+	writer.Finish()
+
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+	assert.JSONEq(t, `{"error":{"message":"bad"}}`, recorder.Body.String())
+}
+
+func TestCompletionsResponseWriterConvertsStreamLogprobs(t *testing.T) {
+	c, recorder := newCompletionsTestContext()
+	writer := NewCompletionsResponseWriter(c, &relaycommon.RelayInfo{}, "1 + 1 =")
+	c.Writer = writer
+
+	c.Header("Content-Type", "text/event-stream")
+	sse := "data: {\"id\":\"chatcmpl-3\",\"choices\":[{\"index\":0,\"delta\":{\"content\":\" 2\"},\"logprobs\":{\"content\":[{\"token\":\" 2\",\"logprob\":-0.1,\"top_logprobs\":[{\"token\":\" 2\",\"logprob\":-0.1},{\"token\":\" 3\",\"logprob\":-2.5}]}]}}]}\n\n" +
+		"data: {\"id\":\"chatcmpl-3\",\"choices\":[{\"index\":0,\"delta\":{\"content\":\".\"},\"logprobs\":{\"content\":[{\"token\":\".\",\"logprob\":-0.2}]},\"finish_reason\":\"stop\"}]}\n\n"
+	_, err := c.Writer.WriteString(sse)
+	require.NoError(t, err)
+	writer.Finish()
+
+	var logprobs []dto.CompletionsLogprobs
+	for _, line := range strings.Split(recorder.Body.String(), "\n") {
+		if !strings.HasPrefix(line, "data: ") {
+			continue
+		}
+		var chunk struct {
+			Choices []struct {
+				Logprobs dto.CompletionsLogprobs `json:"logprobs"`
+			} `json:"choices"`
+		}
+		require.NoError(t, common.UnmarshalJsonStr(strings.TrimPrefix(line, "data: "), &chunk))
+		logprobs = append(logprobs, chunk.Choices[0].Logprobs)
+	}
+	require.Len(t, logprobs, 2)
+	assert.Equal(t, []string{" 2"}, logprobs[0].Tokens)
+	assert.Equal(t, []float64{-0.1}, logprobs[0].TokenLogprobs)
+	assert.Equal(t, map[string]float64{" 2": -0.1, " 3": -2.5}, logprobs[0].TopLogprobs[0])
+	// text_offset 按回显的 prompt 与已输出的文本累加
+	assert.Equal(t, []int{7}, logprobs[0].TextOffset)
+	assert.Equal(t, []int{9}, logprobs[1].TextOffset)
+}
@@ -0,0 +1,121 @@
+package helper
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/logger"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/service/relayconvert"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// EmbeddingResponseWriter 把 OpenAI Embeddings 响应缓冲到 Finish 时整体转换为目标向量格式（Gemini、Cohere），
+// 错误状态码的响应原样透传
+type EmbeddingResponseWriter struct {
+	gin.ResponseWriter
+
+	c      *gin.Context
+	info   *relaycommon.RelayInfo
+	target types.RelayFormat
+
+	mode transcodeWriteMode
+	body bytes.Buffer
+}
+
+// NewEmbeddingResponseWriter 包装当前 c.Writer，调用方需在请求结束时调用 Finish 输出转换结果并恢复原 Writer
+func NewEmbeddingResponseWriter(c *gin.Context, info *relaycommon.RelayInfo, target types.RelayFormat) *EmbeddingResponseWriter {
+	return &EmbeddingResponseWriter{
+		ResponseWriter: c.Writer,
+		c:              c,
+		info:           info,
+		target:         target,
+	}
+}
+
+func (w *EmbeddingResponseWriter) Write(data []byte) (int, error) {
+	w.decideMode()
+	if w.mode == transcodeWriteModeBuffer {
+		return w.body.Write(data)
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *EmbeddingResponseWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *EmbeddingResponseWriter) WriteHeaderNow() {
+	w.decideMode()
+	if w.mode == transcodeWriteModeBuffer {
+		return
+	}
+	w.ResponseWriter.WriteHeaderNow()
+}
+
+func (w *EmbeddingResponseWriter) Flush() {
+	w.decideMode()
+	if w.mode == transcodeWriteModeBuffer {
+		return
+	}
+	w.ResponseWriter.Flush()
+}
+
+// Finish 输出转换后的响应，并把 c.Writer 恢复为被包装的 Writer
+func (w *EmbeddingResponseWriter) Finish() {
+	defer func() {
+		w.c.Writer = w.ResponseWriter
+	}()
+
+	if w.mode != transcodeWriteModeBuffer {
+		return
+	}
+	w.ResponseWriter.Header().Del("Content-Length")
+	w.ResponseWriter.Header().Set("Content-Type", "application/json")
+	_, _ = w.ResponseWriter.Write(w.convertBody(w.body.Bytes()))
+}
+
+func (w *EmbeddingResponseWriter) decideMode() {
+	if w.mode != transcodeWriteModeUndecided {
+		return
+	}
+	if w.ResponseWriter.Status() >= http.StatusBadRequest {
+		w.mode = transcodeWriteModePassthrough
+		return
+	}
+	w.mode = transcodeWriteModeBuffer
+}
+
+// convertBody 把缓冲的 OpenAI Embeddings 响应转换为目标格式，无法识别时原样返回
+func (w *EmbeddingResponseWriter) convertBody(body []byte) []byte {
+	var response dto.EmbeddingResponse
+	if err := common.Unmarshal(body, &response); err != nil || response.Data == nil {
+		return body
+	}
+	result, err := relayconvert.ConvertResponse(w.c, w.info, w.target, &response)
+	if err != nil {
+		logger.LogError(w.c, fmt.Sprintf("convert %s embedding response failed: %s", w.target, err.Error()))
+		return body
+	}
+	if cohereResponse, ok := result.Value.(*dto.CohereEmbedResponse); ok && common.GetContextKeyBool(w.c, constant.ContextKeyCohereEmbedFloats) {
+		vectors, err := cohereResponse.FloatEmbeddings()
+		if err == nil {
+			err = cohereResponse.SetFloatEmbeddings(vectors, false)
+		}
+		if err != nil {
+			logger.LogError(w.c, fmt.Sprintf("convert cohere embed response failed: %s", err.Error()))
+			return body
+		}
+	}
+	data, err := common.Marshal(result.Value)
+	if err != nil {
+		return body
+	}
+	return data
+}
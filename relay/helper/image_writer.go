@@ -0,0 +1,122 @@
+package helper
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/logger"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/service/relayconvert"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ImageResponseWriter 把 OpenAI Images 响应缓冲到 Finish 时整体转换为目标格式（Gemini generateContent），
+// 错误状态码的响应原样透传
+type ImageResponseWriter struct {
+	gin.ResponseWriter
+
+	c      *gin.Context
+	info   *relaycommon.RelayInfo
+	target types.RelayFormat
+	// stream 为 true 时按客户端的流式请求以单个 SSE 事件输出
+	stream bool
+
+	mode transcodeWriteMode
+	body bytes.Buffer
+}
+
+// NewImageResponseWriter 包装当前 c.Writer，调用方需在请求结束时调用 Finish 输出转换结果并恢复原 Writer
+func NewImageResponseWriter(c *gin.Context, info *relaycommon.RelayInfo, target types.RelayFormat, stream bool) *ImageResponseWriter {
+	return &ImageResponseWriter{
+		ResponseWriter: c.Writer,
+		c:              c,
+		info:           info,
+		target:         target,
+		stream:         stream,
+	}
+}
+
+func (w *ImageResponseWriter) Write(data []byte) (int, error) {
+	w.decideMode()
+	if w.mode == transcodeWriteModeBuffer {
+		return w.body.Write(data)
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *ImageResponseWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *ImageResponseWriter) WriteHeaderNow() {
+	w.decideMode()
+	if w.mode == transcodeWriteModeBuffer {
+		return
+	}
+	w.ResponseWriter.WriteHeaderNow()
+}
+
+func (w *ImageResponseWriter) Flush() {
+	w.decideMode()
+	if w.mode == transcodeWriteModeBuffer {
+		return
+	}
+	w.ResponseWriter.Flush()
+}
+
+// Finish 输出转换后的响应，并把 c.Writer 恢复为被包装的 Writer
+func (w *ImageResponseWriter) Finish() {
+	defer func() {
+		w.c.Writer = w.ResponseWriter
+	}()
+
+	if w.mode != transcodeWriteModeBuffer {
+		return
+	}
+	data := w.convertBody(w.body.Bytes())
+	w.ResponseWriter.Header().Del("Content-Length")
+	if w.stream {
+		w.ResponseWriter.Header().Set("Content-Type", "text/event-stream")
+		_, _ = w.ResponseWriter.Write([]byte("data: "))
+		_, _ = w.ResponseWriter.Write(data)
+		_, _ = w.ResponseWriter.Write([]byte("\n\n"))
+		w.ResponseWriter.Flush()
+		return
+	}
+	w.ResponseWriter.Header().Set("Content-Type", "application/json")
+	_, _ = w.ResponseWriter.Write(data)
+}
+
+func (w *ImageResponseWriter) decideMode() {
+	if w.mode != transcodeWriteModeUndecided {
+		return
+	}
+	if w.ResponseWriter.Status() >= http.StatusBadRequest {
+		w.mode = transcodeWriteModePassthrough
+		return
+	}
+	w.mode = transcodeWriteModeBuffer
+}
+
+// convertBody 把缓冲的 OpenAI Images 响应转换为目标格式，无法识别时原样返回
+func (w *ImageResponseWriter) convertBody(body []byte) []byte {
+	var response dto.ImageResponse
+	if err := common.Unmarshal(body, &response); err != nil || response.Data == nil {
+		return body
+	}
+	result, err := relayconvert.ConvertResponse(w.c, w.info, w.target, &response)
+	if err != nil {
+		logger.LogError(w.c, fmt.Sprintf("convert %s image response failed: %s", w.target, err.Error()))
+		return body
+	}
+	data, err := common.Marshal(result.Value)
+	if err != nil {
+		return body
+	}
+	return data
+}
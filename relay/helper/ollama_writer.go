@@ -0,0 +1,226 @@
+package helper
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/logger"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	relayconstant "github.com/QuantumNous/new-api/relay/constant"
+	"github.com/QuantumNous/new-api/service/relayconvert"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// transcodeWriteMode 转写 Writer 在首次写入时根据状态码与 Content-Type 决定输出方式
+type transcodeWriteMode int
+
+const (
+	transcodeWriteModeUndecided transcodeWriteMode = iota
+	transcodeWriteModeStream
+	transcodeWriteModeBuffer
+	transcodeWriteModePassthrough
+)
+
+// OllamaResponseWriter 把 OpenAI 格式的响应转写为 Ollama 格式：SSE 流逐行转换为 NDJSON，
+// 非流式响应缓冲到 Finish 时整体转换。错误状态码的响应原样透传
+type OllamaResponseWriter struct {
+	gin.ResponseWriter
+
+	c        *gin.Context
+	info     *relaycommon.RelayInfo
+	generate bool
+
+	mode    transcodeWriteMode
+	pending []byte
+	body    bytes.Buffer
+	state   *relayconvert.ResponseStreamState
+}
+
+// NewOllamaResponseWriter 包装当前 c.Writer，调用方需在请求结束时调用 Finish 输出剩余内容并恢复原 Writer
+func NewOllamaResponseWriter(c *gin.Context, info *relaycommon.RelayInfo) *OllamaResponseWriter {
+	return &OllamaResponseWriter{
+		ResponseWriter: c.Writer,
+		c:              c,
+		info:           info,
+		generate:       strings.HasSuffix(c.Request.URL.Path, "/generate"),
+	}
+}
+
+func (w *OllamaResponseWriter) Write(data []byte) (int, error) {
+	w.decideMode()
+	switch w.mode {
+	case transcodeWriteModeStream:
+		w.pending = append(w.pending, data...)
+		if err := w.drainLines(); err != nil {
+			return 0, err
+		}
+		return len(data), nil
+	case transcodeWriteModeBuffer:
+		return w.body.Write(data)
+	default:
+		return w.ResponseWriter.Write(data)
+	}
+}
+
+func (w *OllamaResponseWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *OllamaResponseWriter) WriteHeaderNow() {
+	w.decideMode()
+	if w.mode == transcodeWriteModeBuffer {
+		return
+	}
+	w.ResponseWriter.WriteHeaderNow()
+}
+
+func (w *OllamaResponseWriter) Flush() {
+	w.decideMode()
+	if w.mode == transcodeWriteModeBuffer {
+		return
+	}
+	w.ResponseWriter.Flush()
+}
+
+// Finish 输出流式结束分片或缓冲的非流式响应，并把 c.Writer 恢复为被包装的 Writer
+func (w *OllamaResponseWriter) Finish() {
+	defer func() {
+		w.c.Writer = w.ResponseWriter
+	}()
+
+	switch w.mode {
+	case transcodeWriteModeStream:
+		if len(w.pending) > 0 {
+			w.pending = append(w.pending, '\n')
+			_ = w.drainLines()
+		}
+		if w.state == nil {
+			return
+		}
+		results, err := relayconvert.FinalizeStreamResponse(w.c, w.info, w.state)
+		if err != nil {
+			logger.LogError(w.c, fmt.Sprintf("finalize ollama stream failed: %s", err.Error()))
+			return
+		}
+		for _, result := range results {
+			_ = w.writeLine(result.Value)
+		}
+		w.ResponseWriter.Flush()
+	case transcodeWriteModeBuffer:
+		w.ResponseWriter.Header().Del("Content-Length")
+		w.ResponseWriter.Header().Set("Content-Type", "application/json; charset=utf-8")
+		_, _ = w.ResponseWriter.Write(w.convertBody(w.body.Bytes()))
+	}
+}
+
+func (w *OllamaResponseWriter) decideMode() {
+	if w.mode != transcodeWriteModeUndecided {
+		return
+	}
+	header := w.ResponseWriter.Header()
+	switch {
+	case w.ResponseWriter.Status() >= http.StatusBadRequest:
+		w.mode = transcodeWriteModePassthrough
+	case strings.Contains(header.Get("Content-Type"), "text/event-stream"):
+		w.mode = transcodeWriteModeStream
+		header.Set("Content-Type", "application/x-ndjson")
+		header.Del("Content-Length")
+	default:
+		w.mode = transcodeWriteModeBuffer
+	}
+}
+
+// drainLines 逐行解析已接收的 SSE 数据，只处理 data 行，忽略 event、注释心跳与 [DONE]
+func (w *OllamaResponseWriter) drainLines() error {
+	for {
+		idx := bytes.IndexByte(w.pending, '\n')
+		if idx < 0 {
+			return nil
+		}
+		line := strings.TrimSpace(string(w.pending[:idx]))
+		w.pending = w.pending[idx+1:]
+
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+		payload := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		if payload == "" || payload == "[DONE]" {
+			continue
+		}
+		var chunk dto.ChatCompletionsStreamResponse
+		if err := common.UnmarshalJsonStr(payload, &chunk); err != nil {
+			continue
+		}
+		if w.state == nil {
+			state, err := relayconvert.NewResponseStreamState(types.RelayFormatOpenAI, types.RelayFormatOllama, relayconvert.ResponseStreamOptions{
+				Model: w.info.OriginModelName,
+			})
+			if err != nil {
+				return err
+			}
+			w.state = state
+		}
+		results, err := relayconvert.ConvertStreamResponseChunk(w.c, w.info, w.state, &chunk)
+		if err != nil {
+			logger.LogError(w.c, fmt.Sprintf("convert ollama stream chunk failed: %s", err.Error()))
+			continue
+		}
+		for _, result := range results {
+			if err := w.writeLine(result.Value); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (w *OllamaResponseWriter) writeLine(value any) error {
+	if chunk, ok := value.(*dto.OllamaChatResponse); ok && w.generate {
+		value = chunk.ToGenerate()
+	}
+	data, err := common.Marshal(value)
+	if err != nil {
+		return err
+	}
+	_, err = w.ResponseWriter.Write(append(data, '\n'))
+	return err
+}
+
+// convertBody 把缓冲的 OpenAI 响应转换为 Ollama 格式，无法识别时原样返回
+func (w *OllamaResponseWriter) convertBody(body []byte) []byte {
+	var response any
+	if w.info.RelayMode == relayconstant.RelayModeEmbeddings {
+		response = &dto.EmbeddingResponse{}
+	} else {
+		response = &dto.OpenAITextResponse{}
+	}
+	if err := common.Unmarshal(body, response); err != nil {
+		return body
+	}
+	result, err := relayconvert.ConvertResponse(w.c, w.info, types.RelayFormatOllama, response)
+	if err != nil {
+		logger.LogError(w.c, fmt.Sprintf("convert ollama response failed: %s", err.Error()))
+		return body
+	}
+
+	value := result.Value
+	switch resp := value.(type) {
+	case *dto.OllamaChatResponse:
+		resp.Model = w.info.OriginModelName
+		if w.generate {
+			value = resp.ToGenerate()
+		}
+	case *dto.OllamaEmbedResponse:
+		resp.Model = w.info.OriginModelName
+	}
+	data, err := common.Marshal(value)
+	if err != nil {
+		return body
+	}
+	return data
+}
@@ -9,9 +9,11 @@ import (
 	"strings"
 
 	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
 	"github.com/QuantumNous/new-api/dto"
 	"github.com/QuantumNous/new-api/logger"
 	relayconstant "github.com/QuantumNous/new-api/relay/constant"
+	"github.com/QuantumNous/new-api/service"
 	"github.com/QuantumNous/new-api/types"
 	"github.com/samber/lo"
 
@@ -49,12 +51,133 @@ func GetAndValidateRequest(c *gin.Context, format types.RelayFormat) (request dt
 		request, err = GetAndValidAudioRequest(c, relayMode)
 	case types.RelayFormatOpenAIRealtime:
 		request = &dto.BaseRequest{}
+	case types.RelayFormatOllama:
+		request, err = GetAndValidateOllamaRequest(c, relayMode)
+	case types.RelayFormatBedrockConverse:
+		request, err = GetAndValidateBedrockConverseRequest(c)
+	case types.RelayFormatCohereEmbedding:
+		request, err = GetAndValidateCohereEmbedRequest(c)
 	default:
 		return nil, fmt.Errorf("unsupported relay format: %s", format)
 	}
 	return request, err
 }
 
+// GetAndValidateOllamaRequest 解析 Ollama 原生请求并立即经 relayconvert 转换为 OpenAI Chat/Embeddings 请求，
+// 后续计费、令牌估算与渠道适配均按 OpenAI 格式处理，响应由 OllamaResponseWriter 转回 NDJSON
+func GetAndValidateOllamaRequest(c *gin.Context, relayMode int) (dto.Request, error) {
+	var ollamaRequest any
+	target := types.RelayFormat(types.RelayFormatOpenAI)
+	switch {
+	case relayMode == relayconstant.RelayModeEmbeddings:
+		req := &dto.OllamaEmbedRequest{}
+		if err := common.UnmarshalBodyReusable(c, req); err != nil {
+			return nil, err
+		}
+		if req.Input == nil {
+			return nil, errors.New("input is required")
+		}
+		ollamaRequest, target = req, types.RelayFormatEmbedding
+	case strings.HasSuffix(c.Request.URL.Path, "/generate"):
+		req := &dto.OllamaGenerateRequest{}
+		if err := common.UnmarshalBodyReusable(c, req); err != nil {
+			return nil, err
+		}
+		if req.Prompt == "" && len(req.Images) == 0 {
+			return nil, errors.New("prompt is required")
+		}
+		ollamaRequest = req
+	default:
+		req := &dto.OllamaChatRequest{}
+		if err := common.UnmarshalBodyReusable(c, req); err != nil {
+			return nil, err
+		}
+		if len(req.Messages) == 0 {
+			return nil, errors.New("messages is required")
+		}
+		ollamaRequest = req
+	}
+
+	result, err := service.ConvertRequest(c, nil, target, ollamaRequest)
+	if err != nil {
+		return nil, err
+	}
+	request, ok := result.Value.(dto.Request)
+	if !ok {
+		return nil, fmt.Errorf("unexpected converted ollama request type %T", result.Value)
+	}
+	switch req := request.(type) {
+	case *dto.GeneralOpenAIRequest:
+		if req.Model == "" {
+			return nil, errors.New("model is required")
+		}
+		if exceedsMaxTokensLimit(req.MaxTokens) {
+			return nil, errors.New("options.num_predict is invalid")
+		}
+	case *dto.EmbeddingRequest:
+		if req.Model == "" {
+			return nil, errors.New("model is required")
+		}
+	}
+	return request, nil
+}
+
+// GetAndValidateBedrockConverseRequest 解析 Bedrock Converse 请求，模型取自路径，converse-stream 表示流式；
+// 请求立即转换为 Claude Messages 格式，响应由 BedrockConverseResponseWriter 转回 Converse 格式
+func GetAndValidateBedrockConverseRequest(c *gin.Context) (*dto.ClaudeRequest, error) {
+	converseRequest := &dto.BedrockConverseRequest{}
+	if err := common.UnmarshalBodyReusable(c, converseRequest); err != nil {
+		return nil, err
+	}
+	converseRequest.ModelId = c.Param("model")
+	converseRequest.Stream = strings.HasSuffix(c.Request.URL.Path, "/converse-stream")
+	if converseRequest.ModelId == "" {
+		return nil, errors.New("modelId is required")
+	}
+	if len(converseRequest.Messages) == 0 {
+		return nil, errors.New("messages is required")
+	}
+
+	result, err := service.ConvertRequest(c, nil, types.RelayFormatClaude, converseRequest)
+	if err != nil {
+		return nil, err
+	}
+	claudeRequest, ok := result.Value.(*dto.ClaudeRequest)
+	if !ok {
+		return nil, fmt.Errorf("unexpected converted bedrock converse request type %T", result.Value)
+	}
+	if exceedsMaxTokensLimit(claudeRequest.MaxTokens) {
+		return nil, errors.New("inferenceConfig.maxTokens is invalid")
+	}
+	return claudeRequest, nil
+}
+
+// GetAndValidateCohereEmbedRequest 解析 Cohere /embed 请求并立即转换为 OpenAI Embeddings 请求，
+// 响应由 EmbeddingResponseWriter 转回 Cohere 格式
+func GetAndValidateCohereEmbedRequest(c *gin.Context) (*dto.EmbeddingRequest, error) {
+	cohereRequest := &dto.CohereEmbedRequest{}
+	if err := common.UnmarshalBodyReusable(c, cohereRequest); err != nil {
+		return nil, err
+	}
+	if cohereRequest.Model == "" {
+		return nil, errors.New("model is required")
+	}
+
+	result, err := service.ConvertRequest(c, nil, types.RelayFormatEmbedding, cohereRequest)
+	if err != nil {
+		return nil, err
+	}
+	embeddingRequest, ok := result.Value.(*dto.EmbeddingRequest)
+	if !ok {
+		return nil, fmt.Errorf("unexpected converted cohere embed request type %T", result.Value)
+	}
+	// v1 未指定 embedding_types 时返回二维数组，v2 始终按类型返回
+	if strings.HasPrefix(c.Request.URL.Path, "/v1/") && len(cohereRequest.EmbeddingTypes) == 0 {
+		common.SetContextKey(c, constant.ContextKeyCohereEmbedFloats, true)
+	}
+	return embeddingRequest, nil
+}
+
 func GetAndValidAudioRequest(c *gin.Context, relayMode int) (*dto.AudioRequest, error) {
 	audioRequest := &dto.AudioRequest{}
 	err := common.UnmarshalBodyReusable(c, audioRequest)
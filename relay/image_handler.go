@@ -27,7 +27,11 @@ func ImageHelper(c *gin.Context, info *relaycommon.RelayInfo) (newAPIError *type
 	if !ok {
 		return types.NewErrorWithStatusCode(fmt.Errorf("invalid request type, expected dto.ImageRequest, got %T", info.Request), types.ErrorCodeInvalidRequest, http.StatusBadRequest, types.ErrOptionWithSkipRetry())
 	}
+	return relayImageRequest(c, info, imageReq)
+}
 
+// relayImageRequest 按 OpenAI Images 请求经渠道适配器发送并计费，其他入站格式转换为 OpenAI Images 后也从这里进入
+func relayImageRequest(c *gin.Context, info *relaycommon.RelayInfo, imageReq *dto.ImageRequest) (newAPIError *types.NewAPIError) {
 	request, err := common.DeepCopy(imageReq)
 	if err != nil {
 		return types.NewError(fmt.Errorf("failed to copy request to ImageRequest: %w", err), types.ErrorCodeInvalidRequest, types.ErrOptionWithSkipRetry())
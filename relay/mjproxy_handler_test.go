@@ -0,0 +1,29 @@
+package relay
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/QuantumNous/new-api/constant"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMidjourneyRejectsOrganizationTokens(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/mj/submit/imagine", nil)
+	info := &relaycommon.RelayInfo{UserId: 1, OrganizationId: 7}
+
+	mjErr := RelayMidjourneySubmit(c, info)
+	require.NotNil(t, mjErr)
+	require.Equal(t, constant.MjRequestError, mjErr.Code)
+	require.Equal(t, "organization_token_not_supported_for_midjourney", mjErr.Description)
+
+	mjErr = RelaySwapFace(c, info)
+	require.NotNil(t, mjErr)
+	require.Equal(t, "organization_token_not_supported_for_midjourney", mjErr.Description)
+}
@@ -0,0 +1,492 @@
+package relay
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/tidwall/gjson"
+)
+
+const (
+	realtimeBridgeDefaultVoice = "alloy"
+	// realtimeBridgeMaxAudioBytes 与 OpenAI 转写接口的文件大小上限一致
+	realtimeBridgeMaxAudioBytes = 25 << 20
+	// realtimeBridgeAudioChunkBytes 约 0.5 秒 24kHz pcm16 音频
+	realtimeBridgeAudioChunkBytes = 24000
+)
+
+// RealtimeBridgePipeline 桥接会话依赖的转写、对话、语音合成三个环节，
+// 每个环节都作为独立请求走常规的渠道选择与计费
+type RealtimeBridgePipeline interface {
+	Transcribe(c *gin.Context, model string, audio []byte, filename string) (string, error)
+	Chat(c *gin.Context, request *dto.GeneralOpenAIRequest) (*dto.OpenAITextResponse, error)
+	Speak(c *gin.Context, request *dto.AudioRequest) ([]byte, error)
+}
+
+type realtimeBridgeConn interface {
+	ReadMessage() (int, []byte, error)
+	WriteMessage(messageType int, data []byte) error
+}
+
+type realtimeBridgeSession struct {
+	c        *gin.Context
+	conn     realtimeBridgeConn
+	rule     operation_setting.RealtimeBridgeRule
+	pipeline RealtimeBridgePipeline
+
+	session dto.RealtimeSession
+	audio   bytes.Buffer
+	items   []dto.RealtimeItem
+}
+
+// RealtimeBridgeHelper 在客户端 WebSocket 上模拟 OpenAI Realtime 协议：累积 input_audio_buffer，
+// commit 时转写为用户消息，response.create 时调用对话模型并按会话模态合成语音后以 Realtime 事件返回。
+// 不支持服务端 VAD，客户端需自行 commit；开启 turn_detection 时 commit 后自动生成回复
+func RealtimeBridgeHelper(c *gin.Context, ws *websocket.Conn, rule operation_setting.RealtimeBridgeRule, pipeline RealtimeBridgePipeline) error {
+	if ws == nil {
+		return errors.New("websocket connection is nil")
+	}
+	return newRealtimeBridgeSession(c, ws, rule, pipeline).run()
+}
+
+func newRealtimeBridgeSession(c *gin.Context, conn realtimeBridgeConn, rule operation_setting.RealtimeBridgeRule, pipeline RealtimeBridgePipeline) *realtimeBridgeSession {
+	modalities := []string{"text"}
+	if rule.SpeechModel != "" {
+		modalities = append(modalities, "audio")
+	}
+	return &realtimeBridgeSession{
+		c:        c,
+		conn:     conn,
+		rule:     rule,
+		pipeline: pipeline,
+		session: dto.RealtimeSession{
+			Id:                      "sess_" + common.GetRandomString(24),
+			Object:                  "realtime.session",
+			Model:                   rule.Model,
+			Modalities:              modalities,
+			Voice:                   common.GetStringIfEmpty(rule.Voice, realtimeBridgeDefaultVoice),
+			InputAudioFormat:        "pcm16",
+			OutputAudioFormat:       "pcm16",
+			InputAudioTranscription: dto.InputAudioTranscription{Model: rule.TranscriptionModel},
+			ToolChoice:              "auto",
+		},
+	}
+}
+
+func (s *realtimeBridgeSession) run() error {
+	if err := s.send(&dto.RealtimeEvent{Type: dto.RealtimeEventTypeSessionCreated, Session: &s.session}); err != nil {
+		return err
+	}
+	for {
+		messageType, message, err := s.conn.ReadMessage()
+		if err != nil {
+			if websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				return nil
+			}
+			return err
+		}
+		if messageType != websocket.TextMessage {
+			continue
+		}
+		if err := s.handle(message); err != nil {
+			return err
+		}
+	}
+}
+
+// handle 处理一条客户端事件，只有写回客户端失败时才返回错误结束会话，其余错误以 error 事件告知客户端
+func (s *realtimeBridgeSession) handle(message []byte) error {
+	event := &dto.RealtimeEvent{}
+	if err := common.Unmarshal(message, event); err != nil {
+		return s.sendError("invalid_request_error", fmt.Sprintf("invalid event: %s", err.Error()), "")
+	}
+	switch event.Type {
+	case dto.RealtimeEventTypeSessionUpdate:
+		return s.updateSession(event, message)
+	case dto.RealtimeEventInputAudioBufferAppend:
+		return s.appendAudio(event)
+	case dto.RealtimeEventInputAudioBufferClear:
+		s.audio.Reset()
+		return s.send(&dto.RealtimeEvent{Type: dto.RealtimeEventInputAudioBufferCleared})
+	case dto.RealtimeEventInputAudioBufferCommit:
+		return s.commitAudio(event)
+	case dto.RealtimeEventTypeConversationCreate:
+		return s.createItem(event)
+	case dto.RealtimeEventTypeResponseCreate:
+		return s.createResponse(event.Response)
+	default:
+		return s.sendError("invalid_request_error", fmt.Sprintf("event type %s is not supported by bridged realtime sessions", event.Type), event.EventId)
+	}
+}
+
+func (s *realtimeBridgeSession) updateSession(event *dto.RealtimeEvent, message []byte) error {
+	if event.Session == nil {
+		return s.sendError("invalid_request_error", "session is required", event.EventId)
+	}
+	update := event.Session
+	if update.Modalities != nil {
+		s.session.Modalities = update.Modalities
+	}
+	if update.Instructions != "" {
+		s.session.Instructions = update.Instructions
+	}
+	if update.Voice != "" {
+		s.session.Voice = update.Voice
+	}
+	if update.Temperature > 0 {
+		s.session.Temperature = update.Temperature
+	}
+	if update.InputAudioFormat != "" {
+		if _, _, _, ok := realtimeBridgeWavFormat(update.InputAudioFormat); !ok {
+			return s.sendError("invalid_request_error", fmt.Sprintf("input_audio_format %s is not supported", update.InputAudioFormat), event.EventId)
+		}
+		s.session.InputAudioFormat = update.InputAudioFormat
+	}
+	// 语音合成固定请求 pcm 输出，与 Realtime 的 pcm16 一致
+	if update.OutputAudioFormat != "" && update.OutputAudioFormat != "pcm16" {
+		return s.sendError("invalid_request_error", fmt.Sprintf("output_audio_format %s is not supported by bridged realtime sessions", update.OutputAudioFormat), event.EventId)
+	}
+	if gjson.GetBytes(message, "session.turn_detection").Exists() {
+		s.session.TurnDetection = update.TurnDetection
+	}
+	return s.send(&dto.RealtimeEvent{Type: dto.RealtimeEventTypeSessionUpdated, Session: &s.session})
+}
+
+func (s *realtimeBridgeSession) appendAudio(event *dto.RealtimeEvent) error {
+	data, err := base64.StdEncoding.DecodeString(event.Audio)
+	if err != nil {
+		return s.sendError("invalid_request_error", "audio must be base64 encoded", event.EventId)
+	}
+	if s.audio.Len()+len(data) > realtimeBridgeMaxAudioBytes {
+		return s.sendError("invalid_request_error", "input audio buffer is too large, commit or clear it first", event.EventId)
+	}
+	s.audio.Write(data)
+	return nil
+}
+
+func (s *realtimeBridgeSession) commitAudio(event *dto.RealtimeEvent) error {
+	if s.audio.Len() == 0 {
+		return s.sendError("invalid_request_error", "input audio buffer is empty", event.EventId)
+	}
+	if s.rule.TranscriptionModel == "" {
+		s.audio.Reset()
+		return s.sendError("invalid_request_error", "audio input is not supported: no transcription model is configured", event.EventId)
+	}
+	wav := realtimeAudioToWav(s.session.InputAudioFormat, s.audio.Bytes())
+	s.audio.Reset()
+
+	item := dto.RealtimeItem{
+		Id:     newRealtimeBridgeID("item"),
+		Type:   "message",
+		Status: "completed",
+		Role:   "user",
+	}
+	previousItemId := s.lastItemId()
+	if err := s.send(&dto.RealtimeEvent{Type: dto.RealtimeEventInputAudioBufferCommitted, ItemId: item.Id, PreviousItemId: previousItemId}); err != nil {
+		return err
+	}
+
+	transcript, err := s.pipeline.Transcribe(s.c, s.rule.TranscriptionModel, wav, "audio.wav")
+	if err != nil {
+		logger.LogError(s.c, fmt.Sprintf("realtime bridge transcription failed: %s", err.Error()))
+		return s.sendError("server_error", fmt.Sprintf("transcription failed: %s", err.Error()), event.EventId)
+	}
+	item.Content = []dto.RealtimeContent{{Type: "input_audio", Transcript: transcript}}
+	s.items = append(s.items, item)
+	if err := s.send(&dto.RealtimeEvent{Type: dto.RealtimeEventConversationItemCreated, PreviousItemId: previousItemId, Item: &item}); err != nil {
+		return err
+	}
+	if err := s.send(&dto.RealtimeEvent{
+		Type:         dto.RealtimeEventInputAudioTranscriptionCompleted,
+		ItemId:       item.Id,
+		ContentIndex: common.GetPointer(0),
+		Transcript:   transcript,
+	}); err != nil {
+		return err
+	}
+	if s.session.TurnDetection != nil {
+		return s.createResponse(nil)
+	}
+	return nil
+}
+
+func (s *realtimeBridgeSession) createItem(event *dto.RealtimeEvent) error {
+	if event.Item == nil {
+		return s.sendError("invalid_request_error", "item is required", event.EventId)
+	}
+	item := *event.Item
+	if item.Id == "" {
+		item.Id = newRealtimeBridgeID("item")
+	}
+	item.Status = "completed"
+	previousItemId := s.lastItemId()
+	s.items = append(s.items, item)
+	return s.send(&dto.RealtimeEvent{Type: dto.RealtimeEventConversationItemCreated, PreviousItemId: previousItemId, Item: &item})
+}
+
+// createResponse 以会话历史调用对话模型，需要音频模态且配置了语音模型时再合成语音
+func (s *realtimeBridgeSession) createResponse(override *dto.RealtimeResponse) error {
+	modalities := s.session.Modalities
+	instructions := s.session.Instructions
+	if override != nil {
+		if override.Modalities != nil {
+			modalities = override.Modalities
+		}
+		instructions = common.GetStringIfEmpty(override.Instructions, instructions)
+	}
+	withAudio := slices.Contains(modalities, "audio") && s.rule.SpeechModel != ""
+
+	response := &dto.RealtimeResponse{
+		Id:     newRealtimeBridgeID("resp"),
+		Object: "realtime.response",
+		Status: "in_progress",
+		Output: []dto.RealtimeItem{},
+	}
+	if err := s.send(&dto.RealtimeEvent{Type: dto.RealtimeEventResponseCreated, Response: response}); err != nil {
+		return err
+	}
+
+	chatResponse, err := s.pipeline.Chat(s.c, s.buildChatRequest(instructions))
+	if err != nil {
+		logger.LogError(s.c, fmt.Sprintf("realtime bridge chat failed: %s", err.Error()))
+		return s.failResponse(response, fmt.Sprintf("chat completion failed: %s", err.Error()))
+	}
+	if len(chatResponse.Choices) == 0 {
+		return s.failResponse(response, "chat completion returned no choices")
+	}
+	text := chatResponse.Choices[0].Message.StringContent()
+
+	var audio []byte
+	if withAudio {
+		audio, err = s.pipeline.Speak(s.c, &dto.AudioRequest{
+			Model:          s.rule.SpeechModel,
+			Input:          text,
+			Voice:          s.session.Voice,
+			ResponseFormat: "pcm",
+		})
+		if err != nil {
+			logger.LogError(s.c, fmt.Sprintf("realtime bridge speech failed: %s", err.Error()))
+			return s.failResponse(response, fmt.Sprintf("speech synthesis failed: %s", err.Error()))
+		}
+	}
+
+	item := dto.RealtimeItem{
+		Id:      newRealtimeBridgeID("item"),
+		Type:    "message",
+		Status:  "in_progress",
+		Role:    "assistant",
+		Content: []dto.RealtimeContent{},
+	}
+	previousItemId := s.lastItemId()
+	if err := s.send(&dto.RealtimeEvent{Type: dto.RealtimeEventResponseOutputItemAdded, ResponseId: response.Id, OutputIndex: common.GetPointer(0), Item: &item}); err != nil {
+		return err
+	}
+	if err := s.send(&dto.RealtimeEvent{Type: dto.RealtimeEventConversationItemCreated, PreviousItemId: previousItemId, Item: &item}); err != nil {
+		return err
+	}
+	if withAudio {
+		err = s.sendAudioContent(response.Id, item.Id, text, audio)
+	} else {
+		err = s.sendTextContent(response.Id, item.Id, text)
+	}
+	if err != nil {
+		return err
+	}
+
+	item.Status = "completed"
+	if withAudio {
+		item.Content = []dto.RealtimeContent{{Type: "audio", Transcript: text}}
+	} else {
+		item.Content = []dto.RealtimeContent{{Type: "text", Text: text}}
+	}
+	s.items = append(s.items, item)
+	if err := s.send(&dto.RealtimeEvent{Type: dto.RealtimeEventResponseOutputItemDone, ResponseId: response.Id, OutputIndex: common.GetPointer(0), Item: &item}); err != nil {
+		return err
+	}
+
+	response.Status = "completed"
+	response.Output = []dto.RealtimeItem{item}
+	response.Usage = &dto.RealtimeUsage{
+		TotalTokens:  chatResponse.Usage.TotalTokens,
+		InputTokens:  chatResponse.Usage.PromptTokens,
+		OutputTokens: chatResponse.Usage.CompletionTokens,
+	}
+	response.Usage.InputTokenDetails.TextTokens = chatResponse.Usage.PromptTokens
+	response.Usage.OutputTokenDetails.TextTokens = chatResponse.Usage.CompletionTokens
+	return s.send(&dto.RealtimeEvent{Type: dto.RealtimeEventTypeResponseDone, Response: response})
+}
+
+func (s *realtimeBridgeSession) sendTextContent(responseId string, itemId string, text string) error {
+	events := []*dto.RealtimeEvent{
+		{Type: dto.RealtimeEventResponseContentPartAdded, Part: &dto.RealtimeContent{Type: "text"}},
+		{Type: dto.RealtimeEventResponseTextDelta, Delta: text},
+		{Type: dto.RealtimeEventResponseTextDone, Text: text},
+		{Type: dto.RealtimeEventResponseContentPartDone, Part: &dto.RealtimeContent{Type: "text", Text: text}},
+	}
+	for _, event := range events {
+		if err := s.sendContentEvent(event, responseId, itemId); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *realtimeBridgeSession) sendAudioContent(responseId string, itemId string, transcript string, audio []byte) error {
+	if err := s.sendContentEvent(&dto.RealtimeEvent{Type: dto.RealtimeEventResponseContentPartAdded, Part: &dto.RealtimeContent{Type: "audio"}}, responseId, itemId); err != nil {
+		return err
+	}
+	if err := s.sendContentEvent(&dto.RealtimeEvent{Type: dto.RealtimeEventResponseAudioTranscriptionDelta, Delta: transcript}, responseId, itemId); err != nil {
+		return err
+	}
+	for start := 0; start < len(audio); start += realtimeBridgeAudioChunkBytes {
+		end := min(start+realtimeBridgeAudioChunkBytes, len(audio))
+		delta := base64.StdEncoding.EncodeToString(audio[start:end])
+		if err := s.sendContentEvent(&dto.RealtimeEvent{Type: dto.RealtimeEventResponseAudioDelta, Delta: delta}, responseId, itemId); err != nil {
+			return err
+		}
+	}
+	events := []*dto.RealtimeEvent{
+		{Type: dto.RealtimeEventResponseAudioDone},
+		{Type: dto.RealtimeEventResponseAudioTranscriptionDone, Transcript: transcript},
+		{Type: dto.RealtimeEventResponseContentPartDone, Part: &dto.RealtimeContent{Type: "audio", Transcript: transcript}},
+	}
+	for _, event := range events {
+		if err := s.sendContentEvent(event, responseId, itemId); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *realtimeBridgeSession) sendContentEvent(event *dto.RealtimeEvent, responseId string, itemId string) error {
+	event.ResponseId = responseId
+	event.ItemId = itemId
+	event.OutputIndex = common.GetPointer(0)
+	event.ContentIndex = common.GetPointer(0)
+	return s.send(event)
+}
+
+// failResponse 先发送 error 事件，再以 failed 状态结束本次回复
+func (s *realtimeBridgeSession) failResponse(response *dto.RealtimeResponse, message string) error {
+	if err := s.sendError("server_error", message, ""); err != nil {
+		return err
+	}
+	response.Status = "failed"
+	return s.send(&dto.RealtimeEvent{Type: dto.RealtimeEventTypeResponseDone, Response: response})
+}
+
+// buildChatRequest 把会话历史中的消息转为 Chat Completions 请求，音频内容使用转写文本，非消息类条目忽略
+func (s *realtimeBridgeSession) buildChatRequest(instructions string) *dto.GeneralOpenAIRequest {
+	request := &dto.GeneralOpenAIRequest{
+		Model:  s.rule.ChatModel,
+		Stream: common.GetPointer(false),
+	}
+	if s.session.Temperature > 0 {
+		request.Temperature = common.GetPointer(s.session.Temperature)
+	}
+	if instructions != "" {
+		request.Messages = append(request.Messages, dto.Message{Role: "system", Content: instructions})
+	}
+	for _, item := range s.items {
+		if item.Type != "message" {
+			continue
+		}
+		texts := make([]string, 0, len(item.Content))
+		for _, content := range item.Content {
+			switch content.Type {
+			case "input_text", "text":
+				texts = append(texts, content.Text)
+			case "input_audio", "audio":
+				texts = append(texts, content.Transcript)
+			}
+		}
+		text := strings.TrimSpace(strings.Join(texts, "\n"))
+		if text == "" {
+			continue
+		}
+		request.Messages = append(request.Messages, dto.Message{Role: item.Role, Content: text})
+	}
+	return request
+}
+
+func (s *realtimeBridgeSession) lastItemId() string {
+	if len(s.items) == 0 {
+		return ""
+	}
+	return s.items[len(s.items)-1].Id
+}
+
+func (s *realtimeBridgeSession) sendError(errorType string, message string, eventId string) error {
+	return s.send(&dto.RealtimeEvent{
+		Type: dto.RealtimeEventTypeError,
+		Error: &types.OpenAIError{
+			Message: message,
+			Type:    errorType,
+			Param:   eventId,
+		},
+	})
+}
+
+func (s *realtimeBridgeSession) send(event *dto.RealtimeEvent) error {
+	event.EventId = newRealtimeBridgeID("event")
+	data, err := common.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("error marshalling realtime event: %w", err)
+	}
+	return s.conn.WriteMessage(websocket.TextMessage, data)
+}
+
+func newRealtimeBridgeID(prefix string) string {
+	return prefix + "_" + common.GetRandomString(20)
+}
+
+// realtimeBridgeWavFormat 返回 Realtime 输入音频格式对应的 WAV 编码、采样率与位深
+func realtimeBridgeWavFormat(format string) (uint16, uint32, uint16, bool) {
+	switch format {
+	case "", "pcm16":
+		return 1, 24000, 16, true
+	case "g711_alaw":
+		return 6, 8000, 8, true
+	case "g711_ulaw":
+		return 7, 8000, 8, true
+	default:
+		return 0, 0, 0, false
+	}
+}
+
+// realtimeAudioToWav 为 input_audio_buffer 中的单声道裸音频加上 WAV 头，供转写接口识别
+func realtimeAudioToWav(format string, data []byte) []byte {
+	audioFormat, sampleRate, bitsPerSample, ok := realtimeBridgeWavFormat(format)
+	if !ok {
+		audioFormat, sampleRate, bitsPerSample, _ = realtimeBridgeWavFormat("pcm16")
+	}
+	blockAlign := bitsPerSample / 8
+	buf := bytes.NewBuffer(make([]byte, 0, 44+len(data)))
+	buf.WriteString("RIFF")
+	_ = binary.Write(buf, binary.LittleEndian, uint32(36+len(data)))
+	buf.WriteString("WAVEfmt ")
+	_ = binary.Write(buf, binary.LittleEndian, uint32(16))
+	_ = binary.Write(buf, binary.LittleEndian, audioFormat)
+	_ = binary.Write(buf, binary.LittleEndian, uint16(1))
+	_ = binary.Write(buf, binary.LittleEndian, sampleRate)
+	_ = binary.Write(buf, binary.LittleEndian, sampleRate*uint32(blockAlign))
+	_ = binary.Write(buf, binary.LittleEndian, blockAlign)
+	_ = binary.Write(buf, binary.LittleEndian, bitsPerSample)
+	buf.WriteString("data")
+	_ = binary.Write(buf, binary.LittleEndian, uint32(len(data)))
+	buf.Write(data)
+	return buf.Bytes()
+}
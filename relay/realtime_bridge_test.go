@@ -0,0 +1,197 @@
+package relay
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeRealtimeBridgeConn struct {
+	incoming []string
+	outgoing []dto.RealtimeEvent
+}
+
+func (f *fakeRealtimeBridgeConn) ReadMessage() (int, []byte, error) {
+	if len(f.incoming) == 0 {
+		return 0, nil, &websocket.CloseError{Code: websocket.CloseNormalClosure}
+	}
+	message := f.incoming[0]
+	f.incoming = f.incoming[1:]
+	return websocket.TextMessage, []byte(message), nil
+}
+
+func (f *fakeRealtimeBridgeConn) WriteMessage(_ int, data []byte) error {
+	var event dto.RealtimeEvent
+	if err := common.Unmarshal(data, &event); err != nil {
+		return err
+	}
+	f.outgoing = append(f.outgoing, event)
+	return nil
+}
+
+func (f *fakeRealtimeBridgeConn) types() []string {
+	result := make([]string, 0, len(f.outgoing))
+	for _, event := range f.outgoing {
+		result = append(result, event.Type)
+	}
+	return result
+}
+
+type fakeRealtimeBridgePipeline struct {
+	transcribedAudio []byte
+	chatRequest      *dto.GeneralOpenAIRequest
+	speechRequest    *dto.AudioRequest
+	chatErr          error
+}
+
+func (f *fakeRealtimeBridgePipeline) Transcribe(_ *gin.Context, model string, audio []byte, _ string) (string, error) {
+	f.transcribedAudio = audio
+	return "what time is it", nil
+}
+
+func (f *fakeRealtimeBridgePipeline) Chat(_ *gin.Context, request *dto.GeneralOpenAIRequest) (*dto.OpenAITextResponse, error) {
+	f.chatRequest = request
+	if f.chatErr != nil {
+		return nil, f.chatErr
+	}
+	return &dto.OpenAITextResponse{
+		Choices: []dto.OpenAITextResponseChoice{{Message: dto.Message{Role: "assistant", Content: "It is noon."}}},
+		Usage:   dto.Usage{PromptTokens: 12, CompletionTokens: 4, TotalTokens: 16},
+	}, nil
+}
+
+func (f *fakeRealtimeBridgePipeline) Speak(_ *gin.Context, request *dto.AudioRequest) ([]byte, error) {
+	f.speechRequest = request
+	return make([]byte, realtimeBridgeAudioChunkBytes+10), nil
+}
+
+func runFakeRealtimeBridge(t *testing.T, rule operation_setting.RealtimeBridgeRule, pipeline RealtimeBridgePipeline, incoming ...string) *fakeRealtimeBridgeConn {
+	t.Helper()
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	conn := &fakeRealtimeBridgeConn{incoming: incoming}
+	require.NoError(t, newRealtimeBridgeSession(c, conn, rule, pipeline).run())
+	return conn
+}
+
+var testRealtimeBridgeRule = operation_setting.RealtimeBridgeRule{
+	Model:              "gpt-4o-mini-realtime",
+	ChatModel:          "gpt-4o-mini",
+	TranscriptionModel: "whisper-1",
+	SpeechModel:        "tts-1",
+}
+
+func TestRealtimeBridgeAudioTurnWithTurnDetection(t *testing.T) {
+	audio := base64.StdEncoding.EncodeToString([]byte{1, 2, 3, 4})
+	pipeline := &fakeRealtimeBridgePipeline{}
+	conn := runFakeRealtimeBridge(t, testRealtimeBridgeRule, pipeline,
+		`{"type":"session.update","session":{"instructions":"be brief","voice":"verse","turn_detection":{"type":"server_vad"}}}`,
+		`{"type":"input_audio_buffer.append","audio":"`+audio+`"}`,
+		`{"type":"input_audio_buffer.append","audio":"`+audio+`"}`,
+		`{"type":"input_audio_buffer.commit"}`,
+	)
+
+	assert.Equal(t, []string{
+		dto.RealtimeEventTypeSessionCreated,
+		dto.RealtimeEventTypeSessionUpdated,
+		dto.RealtimeEventInputAudioBufferCommitted,
+		dto.RealtimeEventConversationItemCreated,
+		dto.RealtimeEventInputAudioTranscriptionCompleted,
+		dto.RealtimeEventResponseCreated,
+		dto.RealtimeEventResponseOutputItemAdded,
+		dto.RealtimeEventConversationItemCreated,
+		dto.RealtimeEventResponseContentPartAdded,
+		dto.RealtimeEventResponseAudioTranscriptionDelta,
+		dto.RealtimeEventResponseAudioDelta,
+		dto.RealtimeEventResponseAudioDelta,
+		dto.RealtimeEventResponseAudioDone,
+		dto.RealtimeEventResponseAudioTranscriptionDone,
+		dto.RealtimeEventResponseContentPartDone,
+		dto.RealtimeEventResponseOutputItemDone,
+		dto.RealtimeEventTypeResponseDone,
+	}, conn.types())
+
+	// pcm16 输入加上 44 字节 WAV 头后送去转写
+	require.Len(t, pipeline.transcribedAudio, 44+8)
+	assert.Equal(t, "RIFF", string(pipeline.transcribedAudio[:4]))
+	assert.Equal(t, uint32(24000), binary.LittleEndian.Uint32(pipeline.transcribedAudio[24:28]))
+
+	require.NotNil(t, pipeline.chatRequest)
+	assert.Equal(t, "gpt-4o-mini", pipeline.chatRequest.Model)
+	require.Len(t, pipeline.chatRequest.Messages, 2)
+	assert.Equal(t, "be brief", pipeline.chatRequest.Messages[0].StringContent())
+	assert.Equal(t, "what time is it", pipeline.chatRequest.Messages[1].StringContent())
+
+	require.NotNil(t, pipeline.speechRequest)
+	assert.Equal(t, "tts-1", pipeline.speechRequest.Model)
+	assert.Equal(t, "verse", pipeline.speechRequest.Voice)
+	assert.Equal(t, "pcm", pipeline.speechRequest.ResponseFormat)
+	assert.Equal(t, "It is noon.", pipeline.speechRequest.Input)
+
+	done := conn.outgoing[len(conn.outgoing)-1].Response
+	require.NotNil(t, done)
+	assert.Equal(t, "completed", done.Status)
+	require.Len(t, done.Output, 1)
+	assert.Equal(t, "It is noon.", done.Output[0].Content[0].Transcript)
+	assert.Equal(t, 16, done.Usage.TotalTokens)
+}
+
+func TestRealtimeBridgeTextResponseKeepsHistory(t *testing.T) {
+	pipeline := &fakeRealtimeBridgePipeline{}
+	conn := runFakeRealtimeBridge(t, testRealtimeBridgeRule, pipeline,
+		`{"type":"conversation.item.create","item":{"type":"message","role":"user","content":[{"type":"input_text","text":"hi"}]}}`,
+		`{"type":"response.create","response":{"modalities":["text"]}}`,
+		`{"type":"conversation.item.create","item":{"type":"message","role":"user","content":[{"type":"input_text","text":"and now?"}]}}`,
+		`{"type":"response.create","response":{"modalities":["text"]}}`,
+	)
+
+	assert.Contains(t, conn.types(), dto.RealtimeEventResponseTextDelta)
+	assert.NotContains(t, conn.types(), dto.RealtimeEventResponseAudioDelta)
+	assert.Nil(t, pipeline.speechRequest)
+
+	require.Len(t, pipeline.chatRequest.Messages, 3)
+	assert.Equal(t, "user", pipeline.chatRequest.Messages[0].Role)
+	assert.Equal(t, "assistant", pipeline.chatRequest.Messages[1].Role)
+	assert.Equal(t, "It is noon.", pipeline.chatRequest.Messages[1].StringContent())
+	assert.Equal(t, "and now?", pipeline.chatRequest.Messages[2].StringContent())
+}
+
+func TestRealtimeBridgeReportsErrorsWithoutClosing(t *testing.T) {
+	pipeline := &fakeRealtimeBridgePipeline{chatErr: errors.New("upstream unavailable")}
+	conn := runFakeRealtimeBridge(t, testRealtimeBridgeRule, pipeline,
+		`{"type":"session.update","session":{"output_audio_format":"g711_ulaw"}}`,
+		`{"type":"input_audio_buffer.commit"}`,
+		`{"type":"conversation.item.create","item":{"type":"message","role":"user","content":[{"type":"input_text","text":"hi"}]}}`,
+		`{"type":"response.create"}`,
+	)
+
+	assert.Equal(t, []string{
+		dto.RealtimeEventTypeSessionCreated,
+		dto.RealtimeEventTypeError,
+		dto.RealtimeEventTypeError,
+		dto.RealtimeEventConversationItemCreated,
+		dto.RealtimeEventResponseCreated,
+		dto.RealtimeEventTypeError,
+		dto.RealtimeEventTypeResponseDone,
+	}, conn.types())
+	assert.Contains(t, conn.outgoing[5].Error.Message, "upstream unavailable")
+	assert.Equal(t, "failed", conn.outgoing[6].Response.Status)
+}
+
+func TestRealtimeAudioToWavUsesG711Header(t *testing.T) {
+	wav := realtimeAudioToWav("g711_ulaw", []byte{0xff, 0x7f})
+	require.Len(t, wav, 46)
+	assert.Equal(t, uint16(7), binary.LittleEndian.Uint16(wav[20:22]))
+	assert.Equal(t, uint32(8000), binary.LittleEndian.Uint32(wav[24:28]))
+	assert.Equal(t, uint16(8), binary.LittleEndian.Uint16(wav[34:36]))
+	assert.Equal(t, uint32(2), binary.LittleEndian.Uint32(wav[40:44]))
+}
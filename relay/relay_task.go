@@ -499,7 +499,7 @@ func tryRealtimeFetch(task *model.Task, isOpenAIVideoAPI bool) []byte {
 		"metadata": nil,
 		"status":   mapTaskStatusToSimple(task.Status),
 		"task_id":  task.TaskID,
-		"url":      task.GetResultURL(),
+		"url":      service.TaskResultURL(task),
 	}
 	respBody, _ := common.Marshal(dto.TaskResponse[any]{
 		Code: "success",
@@ -540,6 +540,8 @@ func mapTaskStatusToSimple(status model.TaskStatus) string {
 		return "succeeded"
 	case model.TaskStatusFailure:
 		return "failed"
+	case model.TaskStatusCancelled:
+		return "cancelled"
 	case model.TaskStatusQueued, model.TaskStatusSubmitted:
 		return "queued"
 	default:
@@ -561,7 +563,7 @@ func TaskModel2Dto(task *model.Task) *dto.TaskDto {
 		Action:     task.Action,
 		Status:     string(task.Status),
 		FailReason: task.FailReason,
-		ResultURL:  task.GetResultURL(),
+		ResultURL:  service.TaskResultURL(task),
 		SubmitTime: task.SubmitTime,
 		StartTime:  task.StartTime,
 		FinishTime: task.FinishTime,
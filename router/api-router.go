@@ -29,6 +29,7 @@ func SetApiRouter(router *gin.Engine) {
 		apiRouter.GET("/user-agreement", controller.GetUserAgreement)
 		apiRouter.GET("/privacy-policy", controller.GetPrivacyPolicy)
 		apiRouter.GET("/about", controller.GetAbout)
+		apiRouter.GET("/media/:id", controller.GetMediaObject)
 		//apiRouter.GET("/midjourney", controller.GetMidjourney)
 		apiRouter.GET("/home_page_content", controller.GetHomePageContent)
 		apiRouter.GET("/pricing", middleware.HeaderNavModuleAuth("pricing"), controller.GetPricing)
@@ -57,6 +58,8 @@ func SetApiRouter(router *gin.Engine) {
 
 		apiRouter.POST("/stripe/webhook", anonymousRequestBodyLimit, controller.StripeWebhook)
 		apiRouter.POST("/creem/webhook", anonymousRequestBodyLimit, controller.CreemWebhook)
+		apiRouter.POST("/paypal/webhook", anonymousRequestBodyLimit, controller.PayPalWebhook)
+		apiRouter.GET("/paypal/return", middleware.CriticalRateLimit(), controller.PayPalReturn)
 		apiRouter.POST("/waffo/webhook", anonymousRequestBodyLimit, controller.WaffoWebhook)
 		// :env separates test vs prod URLs so the operator can register each
 		// in Pancake's matching webhook slot; handler enforces env match.
@@ -106,10 +109,21 @@ func SetApiRouter(router *gin.Engine) {
 				selfRoute.POST("/stripe/pay", middleware.CriticalRateLimit(), controller.RequestStripePay)
 				selfRoute.POST("/stripe/amount", controller.RequestStripeAmount)
 				selfRoute.POST("/creem/pay", middleware.CriticalRateLimit(), controller.RequestCreemPay)
+				selfRoute.POST("/paypal/amount", controller.RequestPayPalAmount)
+				selfRoute.POST("/paypal/pay", middleware.CriticalRateLimit(), controller.RequestPayPalPay)
 				selfRoute.POST("/waffo/amount", controller.RequestWaffoAmount)
 				selfRoute.POST("/waffo/pay", middleware.CriticalRateLimit(), controller.RequestWaffoPay)
 				selfRoute.POST("/waffo-pancake/amount", controller.RequestWaffoPancakeAmount)
 				selfRoute.POST("/waffo-pancake/pay", middleware.CriticalRateLimit(), controller.RequestWaffoPancakePay)
+				selfRoute.POST("/coupon/check", middleware.CriticalRateLimit(), controller.CheckCoupon)
+				selfRoute.GET("/auto_recharge", controller.GetAutoRecharge)
+				selfRoute.PUT("/auto_recharge", controller.UpdateAutoRecharge)
+				selfRoute.POST("/auto_recharge/setup", middleware.CriticalRateLimit(), controller.SetupAutoRechargePaymentMethod)
+				selfRoute.DELETE("/auto_recharge/payment_method", controller.DeleteAutoRechargePaymentMethod)
+				selfRoute.GET("/auto_recharge/attempts", controller.GetAutoRechargeAttempts)
+				selfRoute.GET("/quota_lots", controller.GetSelfQuotaLots)
+				selfRoute.GET("/postpaid", controller.GetSelfPostpaid)
+				selfRoute.GET("/postpaid/statements/:id", controller.GetSelfPostpaidStatement)
 				selfRoute.POST("/aff_transfer", controller.TransferAffQuota)
 				selfRoute.PUT("/setting", controller.UpdateUserSetting)
 
@@ -152,6 +166,47 @@ func SetApiRouter(router *gin.Engine) {
 			}
 		}
 
+		// Organizations (shared wallet, members and spend limits)
+		organizationRoute := apiRouter.Group("/organization")
+		organizationRoute.Use(middleware.UserAuth())
+		{
+			organizationRoute.GET("/self", controller.GetSelfOrganizations)
+			organizationRoute.POST("/", controller.CreateOrganization)
+			organizationRoute.GET("/:id", controller.GetOrganization)
+			organizationRoute.GET("/:id/members", controller.GetOrganizationMembers)
+			organizationRoute.POST("/:id/members", controller.AddOrganizationMember)
+			organizationRoute.PUT("/:id/members/:user_id", controller.UpdateOrganizationMember)
+			organizationRoute.DELETE("/:id/members/:user_id", controller.RemoveOrganizationMember)
+			organizationRoute.POST("/:id/fund", middleware.CriticalRateLimit(), controller.FundOrganization)
+			organizationRoute.GET("/:id/billing_profile", controller.GetOrganizationBillingProfile)
+			organizationRoute.PUT("/:id/billing_profile", controller.UpdateOrganizationBillingProfile)
+			organizationRoute.GET("/:id/invoices", controller.GetOrganizationInvoices)
+			organizationRoute.GET("/:id/subscriptions", controller.GetOrganizationSubscriptions)
+			organizationRoute.POST("/:id/subscription/purchase", middleware.CriticalRateLimit(), controller.PurchaseOrganizationSubscription)
+		}
+		organizationAdminRoute := apiRouter.Group("/organization/admin")
+		organizationAdminRoute.Use(middleware.AdminAuth())
+		{
+			organizationAdminRoute.GET("/", controller.AdminListOrganizations)
+			organizationAdminRoute.GET("/:id/members", controller.AdminGetOrganizationMembers)
+			organizationAdminRoute.POST("/:id/quota", controller.AdminAdjustOrganizationQuota)
+			organizationAdminRoute.PUT("/:id/status", controller.AdminUpdateOrganizationStatus)
+			organizationAdminRoute.POST("/:id/subscription", controller.AdminBindOrganizationSubscription)
+		}
+
+		// Billing profiles and invoices
+		billingRoute := apiRouter.Group("/billing")
+		{
+			billingRoute.GET("/profile", middleware.UserAuth(), controller.GetSelfBillingProfile)
+			billingRoute.PUT("/profile", middleware.UserAuth(), controller.UpdateSelfBillingProfile)
+			billingRoute.GET("/invoices", middleware.UserAuth(), controller.GetSelfInvoices)
+			billingRoute.GET("/invoices/:id/download", middleware.UserAuth(), controller.DownloadSelfInvoice)
+			billingRoute.GET("/admin/invoices", middleware.AdminAuth(), controller.AdminListInvoices)
+			billingRoute.GET("/admin/invoices/:id/download", middleware.AdminAuth(), controller.AdminDownloadInvoice)
+			billingRoute.POST("/admin/invoices/issue", middleware.AdminAuth(), controller.AdminIssueInvoice)
+			billingRoute.POST("/admin/invoices/:id/resend", middleware.AdminAuth(), controller.AdminResendInvoice)
+		}
+
 		// Subscription billing (plans, purchase, admin management)
 		subscriptionRoute := apiRouter.Group("/subscription")
 		subscriptionRoute.Use(middleware.UserAuth())
@@ -163,6 +218,7 @@ func SetApiRouter(router *gin.Engine) {
 			subscriptionRoute.POST("/epay/pay", middleware.CriticalRateLimit(), controller.SubscriptionRequestEpay)
 			subscriptionRoute.POST("/stripe/pay", middleware.CriticalRateLimit(), controller.SubscriptionRequestStripePay)
 			subscriptionRoute.POST("/creem/pay", middleware.CriticalRateLimit(), controller.SubscriptionRequestCreemPay)
+			subscriptionRoute.POST("/paypal/pay", middleware.CriticalRateLimit(), controller.SubscriptionRequestPayPalPay)
 			subscriptionRoute.POST("/waffo-pancake/pay", middleware.CriticalRateLimit(), controller.SubscriptionRequestWaffoPancakePay)
 		}
 		subscriptionAdminRoute := apiRouter.Group("/subscription/admin")
@@ -197,6 +253,7 @@ func SetApiRouter(router *gin.Engine) {
 			optionRoute.GET("/channel_affinity_cache", controller.GetChannelAffinityCacheStats)
 			optionRoute.DELETE("/channel_affinity_cache", controller.ClearChannelAffinityCache)
 			optionRoute.POST("/rest_model_ratio", controller.ResetModelRatio)
+			optionRoute.POST("/currency/refresh", controller.RefreshCurrencyRates)
 			optionRoute.GET("/waffo-pancake/catalog", controller.ListWaffoPancakeCatalog)
 			optionRoute.POST("/waffo-pancake/pair", controller.CreateWaffoPancakePair)
 			optionRoute.POST("/waffo-pancake/save", controller.SaveWaffoPancake)
@@ -268,9 +325,38 @@ func SetApiRouter(router *gin.Engine) {
 			redemptionRoute.DELETE("/invalid", controller.DeleteInvalidRedemption)
 			redemptionRoute.DELETE("/:id", controller.DeleteRedemption)
 		}
+		couponRoute := apiRouter.Group("/coupon")
+		couponRoute.Use(middleware.AdminAuth())
+		{
+			couponRoute.GET("/", controller.GetAllCoupons)
+			couponRoute.GET("/:id", controller.GetCoupon)
+			couponRoute.GET("/:id/redemptions", controller.GetCouponRedemptions)
+			couponRoute.POST("/", controller.AddCoupon)
+			couponRoute.PUT("/", controller.UpdateCoupon)
+			couponRoute.DELETE("/:id", controller.DeleteCoupon)
+		}
+		postpaidRoute := apiRouter.Group("/postpaid")
+		postpaidRoute.Use(middleware.AdminAuth())
+		{
+			postpaidRoute.GET("/accounts", controller.GetPostpaidAccounts)
+			postpaidRoute.POST("/accounts", controller.UpsertPostpaidAccount)
+			postpaidRoute.GET("/statements", controller.GetPostpaidStatements)
+			postpaidRoute.GET("/statements/:id", controller.GetPostpaidStatement)
+			postpaidRoute.POST("/statements/:id/payments", controller.RecordPostpaidPayment)
+			postpaidRoute.POST("/run", controller.RunPostpaidStatements)
+		}
+		ledgerRoute := apiRouter.Group("/ledger")
+		ledgerRoute.Use(middleware.AdminAuth())
+		{
+			ledgerRoute.GET("/", controller.GetQuotaLedgerEntries)
+			ledgerRoute.GET("/drift", controller.GetQuotaLedgerDrifts)
+			ledgerRoute.POST("/reconcile", middleware.RootAuth(), controller.ReconcileQuotaLedger)
+		}
+
 		logRoute := apiRouter.Group("/log")
 		logRoute.GET("/", middleware.AdminAuth(), controller.GetAllLogs)
 		logRoute.GET("/stat", middleware.AdminAuth(), controller.GetLogsStat)
+		logRoute.GET("/profit", middleware.AdminAuth(), controller.GetLogProfitReport)
 		logRoute.GET("/self/stat", middleware.UserAuth(), controller.GetLogsSelfStat)
 		logRoute.GET("/channel_affinity_usage_cache", middleware.AdminAuth(), controller.GetChannelAffinityUsageCacheStats)
 		logRoute.GET("/search", middleware.AdminAuth(), controller.SearchAllLogs)
@@ -327,6 +413,10 @@ func SetApiRouter(router *gin.Engine) {
 		{
 			taskRoute.GET("/self", middleware.UserAuth(), controller.GetUserTask)
 			taskRoute.GET("/", middleware.AdminAuth(), controller.GetAllTask)
+			taskRoute.GET("/callbacks/self", middleware.UserAuth(), controller.GetUserTaskCallbacks)
+			taskRoute.GET("/callbacks", middleware.AdminAuth(), controller.GetAllTaskCallbacks)
+			taskRoute.POST("/callbacks/self/:id/retry", middleware.UserAuth(), controller.RetryUserTaskCallback)
+			taskRoute.POST("/callbacks/:id/retry", middleware.AdminAuth(), controller.RetryTaskCallback)
 		}
 
 		vendorRoute := apiRouter.Group("/vendors")
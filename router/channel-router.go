@@ -81,6 +81,11 @@ var channelPermissionRoutes = []permissionRoute{
 	{method: http.MethodPost, path: "/breaker/reset", permission: authz.ChannelOperate, handler: controller.ResetDynamicChannelBreakers},
 	{method: http.MethodPost, path: "/:id/breaker/reset", permission: authz.ChannelOperate, handler: controller.ResetDynamicChannelBreaker},
 	{method: http.MethodGet, path: "/:id/breaker/detail", permission: authz.ChannelRead, handler: controller.GetChannelBreakerDetail},
+	{method: http.MethodGet, path: "/:id/shadow/comparisons", permission: authz.ChannelRead, handler: controller.GetChannelShadowComparisons},
+	{method: http.MethodGet, path: "/:id/shadow/summary", permission: authz.ChannelRead, handler: controller.GetChannelShadowSummary},
+	{method: http.MethodPost, path: "/:id/key/rotate", permission: authz.ChannelSensitiveWrite, handler: controller.RotateChannelKey},
+	{method: http.MethodPut, path: "/:id/key/metadata", permission: authz.ChannelWrite, handler: controller.UpdateChannelKeyMetadata},
+	{method: http.MethodGet, path: "/:id/key/rotations", permission: authz.ChannelRead, handler: controller.GetChannelKeyRotations},
 	{method: http.MethodGet, path: "/fetch_models/:id", permission: authz.ChannelOperate, handler: controller.FetchUpstreamModels},
 	{method: http.MethodPost, path: "/:id/codex/refresh", permission: authz.ChannelSensitiveWrite, handler: controller.RefreshCodexChannelCredential},
 	{method: http.MethodGet, path: "/:id/codex/usage", permission: authz.ChannelRead, handler: controller.GetCodexChannelUsage},
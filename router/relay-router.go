@@ -79,6 +79,11 @@ func SetRelayRouter(router *gin.Engine) {
 			controller.Relay(c, types.RelayFormatOpenAIRealtime)
 		})
 	}
+	{
+		// 网关代存的 Responses API 响应，不经过渠道分发
+		relayV1Router.GET("/responses/:id", controller.GetStoredResponse)
+		relayV1Router.DELETE("/responses/:id", controller.DeleteStoredResponse)
+	}
 	{
 		//http router
 		httpRouter := relayV1Router.Group("")
@@ -120,6 +125,10 @@ func SetRelayRouter(router *gin.Engine) {
 		httpRouter.POST("/embeddings", func(c *gin.Context) {
 			controller.Relay(c, types.RelayFormatEmbedding)
 		})
+		// Cohere 原生向量接口
+		httpRouter.POST("/embed", func(c *gin.Context) {
+			controller.Relay(c, types.RelayFormatCohereEmbedding)
+		})
 
 		// audio related routes
 		httpRouter.POST("/audio/transcriptions", func(c *gin.Context) {
@@ -186,6 +195,14 @@ func SetRelayRouter(router *gin.Engine) {
 		relaySunoRouter.GET("/fetch/:id", controller.RelayTaskFetch)
 	}
 
+	// 取消任务只作用于已有任务所在渠道，无需重新分发；上游不支持取消时返回 cancel_not_supported
+	relaySunoCancelRouter := router.Group("/suno")
+	relaySunoCancelRouter.Use(middleware.RouteTag("relay"))
+	relaySunoCancelRouter.Use(middleware.TokenAuth())
+	{
+		relaySunoCancelRouter.POST("/cancel/:id", controller.RelayTaskCancel)
+	}
+
 	relayGeminiRouter := router.Group("/v1beta")
 	relayGeminiRouter.Use(middleware.RouteTag("relay"))
 	relayGeminiRouter.Use(middleware.SystemPerformanceCheck())
@@ -198,6 +215,77 @@ func SetRelayRouter(router *gin.Engine) {
 			controller.Relay(c, types.RelayFormatGemini)
 		})
 	}
+
+	// Ollama 原生接口与 LM Studio 风格的 /api/v0 接口，供只支持本地模型协议的客户端接入
+	ollamaModelsRouter := router.Group("/api")
+	ollamaModelsRouter.Use(middleware.RouteTag("relay"))
+	ollamaModelsRouter.Use(middleware.TokenAuth())
+	{
+		ollamaModelsRouter.GET("/version", controller.RelayOllamaVersion)
+		ollamaModelsRouter.GET("/tags", func(c *gin.Context) {
+			controller.ListModels(c, constant.ChannelTypeOllama)
+		})
+		ollamaModelsRouter.GET("/v0/models", func(c *gin.Context) {
+			controller.ListModels(c, constant.ChannelTypeOpenAI)
+		})
+	}
+
+	relayOllamaRouter := router.Group("/api")
+	relayOllamaRouter.Use(middleware.RouteTag("relay"))
+	relayOllamaRouter.Use(middleware.SystemPerformanceCheck())
+	relayOllamaRouter.Use(middleware.TokenAuth())
+	relayOllamaRouter.Use(middleware.ModelRequestRateLimit())
+	relayOllamaRouter.Use(middleware.Distribute())
+	{
+		relayOllamaRouter.POST("/chat", func(c *gin.Context) {
+			controller.Relay(c, types.RelayFormatOllama)
+		})
+		relayOllamaRouter.POST("/generate", func(c *gin.Context) {
+			controller.Relay(c, types.RelayFormatOllama)
+		})
+		relayOllamaRouter.POST("/embed", func(c *gin.Context) {
+			controller.Relay(c, types.RelayFormatOllama)
+		})
+
+		relayOllamaRouter.POST("/v0/chat/completions", func(c *gin.Context) {
+			controller.Relay(c, types.RelayFormatOpenAI)
+		})
+		relayOllamaRouter.POST("/v0/completions", func(c *gin.Context) {
+			controller.Relay(c, types.RelayFormatOpenAI)
+		})
+		relayOllamaRouter.POST("/v0/embeddings", func(c *gin.Context) {
+			controller.Relay(c, types.RelayFormatEmbedding)
+		})
+	}
+
+	// Cohere v2 原生向量接口
+	relayV2Router := router.Group("/v2")
+	relayV2Router.Use(middleware.RouteTag("relay"))
+	relayV2Router.Use(middleware.SystemPerformanceCheck())
+	relayV2Router.Use(middleware.TokenAuth())
+	relayV2Router.Use(middleware.ModelRequestRateLimit())
+	relayV2Router.Use(middleware.Distribute())
+	{
+		relayV2Router.POST("/embed", func(c *gin.Context) {
+			controller.Relay(c, types.RelayFormatCohereEmbedding)
+		})
+	}
+
+	// AWS Bedrock Converse 兼容接口，使用网关令牌（Bearer）鉴权
+	relayBedrockRouter := router.Group("/model")
+	relayBedrockRouter.Use(middleware.RouteTag("relay"))
+	relayBedrockRouter.Use(middleware.SystemPerformanceCheck())
+	relayBedrockRouter.Use(middleware.TokenAuth())
+	relayBedrockRouter.Use(middleware.ModelRequestRateLimit())
+	relayBedrockRouter.Use(middleware.Distribute())
+	{
+		relayBedrockRouter.POST("/:model/converse", func(c *gin.Context) {
+			controller.Relay(c, types.RelayFormatBedrockConverse)
+		})
+		relayBedrockRouter.POST("/:model/converse-stream", func(c *gin.Context) {
+			controller.Relay(c, types.RelayFormatBedrockConverse)
+		})
+	}
 }
 
 func registerMjRouterGroup(relayMjRouter *gin.RouterGroup) {
@@ -16,6 +16,15 @@ func SetVideoRouter(router *gin.Engine) {
 		videoProxyRouter.GET("/videos/:task_id/content", controller.VideoProxy)
 	}
 
+	// 取消任务只作用于已有任务所在渠道，无需重新分发
+	taskCancelRouter := router.Group("/v1")
+	taskCancelRouter.Use(middleware.RouteTag("relay"))
+	taskCancelRouter.Use(middleware.TokenAuth())
+	{
+		taskCancelRouter.POST("/video/generations/:task_id/cancel", controller.RelayTaskCancel)
+		taskCancelRouter.POST("/videos/:video_id/cancel", controller.RelayTaskCancel)
+	}
+
 	videoV1Router := router.Group("/v1")
 	videoV1Router.Use(middleware.RouteTag("relay"))
 	videoV1Router.Use(middleware.TokenAuth(), middleware.Distribute())
@@ -0,0 +1,283 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/setting"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+
+	"github.com/bytedance/gopkg/util/gopool"
+	"github.com/shopspring/decimal"
+	"github.com/stripe/stripe-go/v81"
+	"github.com/stripe/stripe-go/v81/paymentintent"
+)
+
+// AutoRechargeTradeNoPrefix 自动充值订单号前缀，Stripe 回调据此识别离线扣款
+const AutoRechargeTradeNoPrefix = "auto_ref_"
+
+// autoRechargeGateTTL 触发判断所用的配置缓存时长，避免每次结算都查询数据库
+const autoRechargeGateTTL int64 = 300
+
+type autoRechargeGate struct {
+	enabled   bool
+	threshold int
+	expiresAt int64
+}
+
+var autoRechargeGates sync.Map // userId -> autoRechargeGate
+
+// InvalidateAutoRechargeGate 在用户修改自动充值设置后清除本节点的触发判断缓存
+func InvalidateAutoRechargeGate(userId int) {
+	autoRechargeGates.Delete(userId)
+}
+
+func loadAutoRechargeGate(userId int) autoRechargeGate {
+	now := common.GetTimestamp()
+	if v, ok := autoRechargeGates.Load(userId); ok {
+		gate := v.(autoRechargeGate)
+		if gate.expiresAt > now {
+			return gate
+		}
+	}
+	gate := autoRechargeGate{expiresAt: now + autoRechargeGateTTL}
+	config, err := model.GetAutoRechargeConfig(userId)
+	if err != nil {
+		common.SysError(fmt.Sprintf("failed to load auto recharge config for user %d: %s", userId, err.Error()))
+	} else {
+		gate.enabled = config.Enabled && config.HasPaymentMethod() && config.Amount > 0
+		gate.threshold = config.Threshold
+	}
+	autoRechargeGates.Store(userId, gate)
+	return gate
+}
+
+// TriggerAutoRecharge 在钱包结算后调用，estimatedQuota 为结算后的预估余额。
+// 预估余额低于用户阈值时异步发起扣款，实际是否扣款以数据库余额为准。
+func TriggerAutoRecharge(userId int, estimatedQuota int, requestId string) {
+	if !operation_setting.GetAutoRechargeSetting().Enabled || userId <= 0 {
+		return
+	}
+	gate := loadAutoRechargeGate(userId)
+	if !gate.enabled || estimatedQuota >= gate.threshold {
+		return
+	}
+	gopool.Go(func() {
+		if _, err := RunAutoRecharge(userId, requestId); err != nil {
+			common.SysError(fmt.Sprintf("auto recharge failed for user %d (request %s): %s", userId, requestId, err.Error()))
+		}
+	})
+}
+
+func autoRechargeIdempotencyKey(userId int, requestId string) string {
+	if requestId == "" {
+		// 没有请求 ID 时按分钟去重
+		requestId = fmt.Sprintf("t%d", common.GetTimestamp()/60)
+	}
+	return fmt.Sprintf("auto_recharge:%d:%s", userId, requestId)
+}
+
+// RunAutoRecharge 检查用户余额并在低于阈值时通过 Stripe 离线扣款充值。
+// 没有满足扣款条件时返回 nil, nil。
+func RunAutoRecharge(userId int, requestId string) (*model.AutoRechargeAttempt, error) {
+	autoSetting := operation_setting.GetAutoRechargeSetting()
+	if !autoSetting.Enabled {
+		return nil, nil
+	}
+	config, err := model.GetAutoRechargeConfig(userId)
+	if err != nil {
+		return nil, err
+	}
+	if !config.Enabled || !config.HasPaymentMethod() || config.Amount <= 0 {
+		return nil, nil
+	}
+	quota, err := model.GetUserQuota(userId, true)
+	if err != nil {
+		return nil, err
+	}
+	if quota >= config.Threshold {
+		return nil, nil
+	}
+	user, err := model.GetUserById(userId, false)
+	if err != nil {
+		return nil, err
+	}
+
+	topupGroupRatio := common.GetTopupGroupRatio(user.Group)
+	if topupGroupRatio == 0 {
+		topupGroupRatio = 1
+	}
+	dAmount := decimal.NewFromInt(config.Amount).Mul(decimal.NewFromFloat(topupGroupRatio))
+	// 与 Stripe Checkout 充值一致：Money 为经分组倍率换算后的数量，实付金额再乘以单价
+	chargedMoney := dAmount.InexactFloat64()
+	payMoney := dAmount.Mul(decimal.NewFromFloat(setting.StripeUnitPrice)).Round(2)
+
+	key := autoRechargeIdempotencyKey(userId, requestId)
+	attempt := &model.AutoRechargeAttempt{
+		UserId:         userId,
+		IdempotencyKey: key,
+		RequestId:      requestId,
+		TradeNo:        AutoRechargeTradeNoPrefix + common.Sha1([]byte(key)),
+		Amount:         config.Amount,
+		Money:          payMoney.InexactFloat64(),
+		QuotaBefore:    quota,
+	}
+	skip, err := model.ReserveAutoRechargeAttempt(attempt, model.AutoRechargeLimits{
+		CooldownSeconds:  autoSetting.CooldownSeconds,
+		MaxChargesPerDay: autoSetting.MaxChargesPerDay,
+		DailyCap:         config.DailyCap,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if skip != "" {
+		common.SysLog(fmt.Sprintf("auto recharge skipped for user %d: %s", userId, skip))
+		return nil, nil
+	}
+
+	topUp := &model.TopUp{
+		UserId:          userId,
+		Amount:          config.Amount,
+		Money:           chargedMoney,
+		TradeNo:         attempt.TradeNo,
+		PaymentMethod:   model.PaymentMethodStripe,
+		PaymentProvider: model.PaymentProviderStripe,
+		CreateTime:      common.GetTimestamp(),
+		Status:          common.TopUpStatusPending,
+		PayMoney:        payMoney.InexactFloat64(),
+		PayCurrency:     strings.ToUpper(autoSetting.Currency),
+	}
+	if err := topUp.Insert(); err != nil {
+		failAutoRecharge(attempt, user, "创建充值订单失败")
+		return attempt, err
+	}
+
+	intent, err := createAutoRechargePaymentIntent(config, attempt, payMoney.Mul(decimal.NewFromInt(100)).IntPart(), autoSetting.Currency)
+	if err != nil {
+		failAutoRecharge(attempt, user, stripeErrorMessage(err))
+		return attempt, err
+	}
+	attempt.PaymentIntentId = intent.ID
+	if err := attempt.Update(); err != nil {
+		common.SysError(fmt.Sprintf("failed to save payment intent for auto recharge %s: %s", attempt.TradeNo, err.Error()))
+	}
+
+	switch intent.Status {
+	case stripe.PaymentIntentStatusSucceeded:
+		return attempt, CompleteAutoRecharge(attempt.TradeNo, config.StripeCustomerId)
+	case stripe.PaymentIntentStatusProcessing:
+		// 结果由 payment_intent.* 回调确认
+		return attempt, nil
+	default:
+		msg := fmt.Sprintf("扣款未完成，状态：%s", intent.Status)
+		failAutoRecharge(attempt, user, msg)
+		return attempt, errors.New(msg)
+	}
+}
+
+func createAutoRechargePaymentIntent(config *model.AutoRechargeConfig, attempt *model.AutoRechargeAttempt, amountCents int64, currency string) (*stripe.PaymentIntent, error) {
+	if amountCents <= 0 {
+		return nil, errors.New("充值金额过低")
+	}
+	stripe.Key = setting.StripeApiSecret
+	params := &stripe.PaymentIntentParams{
+		Amount:        stripe.Int64(amountCents),
+		Currency:      stripe.String(currency),
+		Customer:      stripe.String(config.StripeCustomerId),
+		PaymentMethod: stripe.String(config.StripePaymentMethodId),
+		OffSession:    stripe.Bool(true),
+		Confirm:       stripe.Bool(true),
+		Description:   stripe.String(fmt.Sprintf("Auto recharge %d units", attempt.Amount)),
+	}
+	params.AddMetadata("trade_no", attempt.TradeNo)
+	params.AddMetadata("user_id", fmt.Sprintf("%d", attempt.UserId))
+	params.SetIdempotencyKey(attempt.IdempotencyKey)
+	return paymentintent.New(params)
+}
+
+func stripeErrorMessage(err error) string {
+	var stripeErr *stripe.Error
+	if errors.As(err, &stripeErr) && stripeErr.Msg != "" {
+		return stripeErr.Msg
+	}
+	return err.Error()
+}
+
+// CompleteAutoRecharge 在扣款成功后为用户入账，同步结果与 Stripe 回调都会调用，重复调用是安全的
+func CompleteAutoRecharge(tradeNo string, customerId string) error {
+	attempt, err := model.GetAutoRechargeAttemptByTradeNo(tradeNo)
+	if err != nil {
+		return err
+	}
+	if attempt.Status != model.AutoRechargeStatusPending {
+		return nil
+	}
+	if err := model.Recharge(tradeNo, customerId, ""); err != nil {
+		if topUp := model.GetTopUpByTradeNo(tradeNo); topUp == nil || topUp.Status != common.TopUpStatusSuccess {
+			return err
+		}
+	}
+	finished, _, err := model.FinishAutoRechargeAttempt(attempt.Id, model.AutoRechargeStatusSucceeded, "", operation_setting.GetAutoRechargeSetting().MaxConsecutiveFailures)
+	if err != nil {
+		return err
+	}
+	if finished {
+		IssueTopUpInvoiceAsync(tradeNo)
+	}
+	return nil
+}
+
+// FailAutoRecharge 供 Stripe 回调在异步扣款失败时调用
+func FailAutoRecharge(tradeNo string, reason string) error {
+	attempt, err := model.GetAutoRechargeAttemptByTradeNo(tradeNo)
+	if err != nil {
+		return err
+	}
+	user, err := model.GetUserById(attempt.UserId, false)
+	if err != nil {
+		return err
+	}
+	failAutoRecharge(attempt, user, reason)
+	return nil
+}
+
+func failAutoRecharge(attempt *model.AutoRechargeAttempt, user *model.User, reason string) {
+	if err := model.UpdatePendingTopUpStatus(attempt.TradeNo, model.PaymentProviderStripe, common.TopUpStatusFailed); err != nil &&
+		!errors.Is(err, model.ErrTopUpNotFound) && !errors.Is(err, model.ErrTopUpStatusInvalid) {
+		common.SysError(fmt.Sprintf("failed to mark auto recharge topup %s failed: %s", attempt.TradeNo, err.Error()))
+	}
+	finished, disabled, err := model.FinishAutoRechargeAttempt(attempt.Id, model.AutoRechargeStatusFailed, reason, operation_setting.GetAutoRechargeSetting().MaxConsecutiveFailures)
+	if err != nil {
+		common.SysError(fmt.Sprintf("failed to finish auto recharge %s: %s", attempt.TradeNo, err.Error()))
+		return
+	}
+	if !finished {
+		return
+	}
+	attempt.Status = model.AutoRechargeStatusFailed
+	attempt.Error = reason
+	if disabled {
+		InvalidateAutoRechargeGate(user.Id)
+	}
+	notifyAutoRechargeFailure(user, attempt, disabled)
+}
+
+func notifyAutoRechargeFailure(user *model.User, attempt *model.AutoRechargeAttempt, disabled bool) {
+	prompt := "自动充值失败"
+	content := "{{value}}：本次自动充值 {{value}}（{{value}}）未能完成，原因：{{value}}。"
+	values := []interface{}{prompt, attempt.Amount, fmt.Sprintf("%.2f", attempt.Money), attempt.Error}
+	if disabled {
+		content += "连续失败次数过多，自动充值已关闭，请更新支付方式后重新开启。"
+	} else {
+		content += "请检查支付方式，避免余额不足影响使用。"
+	}
+	err := NotifyUser(user.Id, user.Email, user.GetSetting(), dto.NewNotify(dto.NotifyTypeAutoRecharge, prompt, content, values))
+	if err != nil {
+		common.SysError(fmt.Sprintf("failed to send auto recharge failure notify to user %d: %s", user.Id, err.Error()))
+	}
+}
@@ -0,0 +1,206 @@
+package service
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/setting"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+	"github.com/QuantumNous/new-api/setting/system_setting"
+	"github.com/stretchr/testify/require"
+	"github.com/stripe/stripe-go/v81"
+)
+
+// fakeStripe 模拟 Stripe PaymentIntent 接口，记录收到的请求
+type fakeStripe struct {
+	mu        sync.Mutex
+	declined  bool
+	forms     []url.Values
+	idemKeys  []string
+	callCount int
+}
+
+func (f *fakeStripe) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	form, _ := url.ParseQuery(string(body))
+	f.mu.Lock()
+	f.callCount++
+	f.forms = append(f.forms, form)
+	f.idemKeys = append(f.idemKeys, r.Header.Get("Idempotency-Key"))
+	declined := f.declined
+	f.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	if r.URL.Path != "/v1/payment_intents" {
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = w.Write([]byte(`{"error":{"type":"invalid_request_error","message":"not found"}}`))
+		return
+	}
+	if declined {
+		w.WriteHeader(http.StatusPaymentRequired)
+		_, _ = w.Write([]byte(`{"error":{"type":"card_error","code":"card_declined","message":"Your card was declined."}}`))
+		return
+	}
+	_, _ = w.Write([]byte(`{"id":"pi_auto_1","object":"payment_intent","status":"succeeded","amount":` + form.Get("amount") + `}`))
+}
+
+func (f *fakeStripe) calls() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.callCount
+}
+
+func setupAutoRecharge(t *testing.T, fake *fakeStripe) {
+	t.Helper()
+	truncate(t)
+
+	srv := httptest.NewServer(fake)
+	original := stripe.GetBackend(stripe.APIBackend)
+	stripe.SetBackend(stripe.APIBackend, stripe.GetBackendWithConfig(stripe.APIBackend, &stripe.BackendConfig{
+		URL:               stripe.String(srv.URL),
+		MaxNetworkRetries: stripe.Int64(0),
+	}))
+
+	autoSetting := operation_setting.GetAutoRechargeSetting()
+	savedSetting := *autoSetting
+	autoSetting.Enabled = true
+	autoSetting.CooldownSeconds = 0
+	autoSetting.MaxChargesPerDay = 5
+	autoSetting.MaxConsecutiveFailures = 2
+	savedSecret, savedPrice := setting.StripeApiSecret, setting.StripeUnitPrice
+	setting.StripeApiSecret = "sk_test_auto"
+	setting.StripeUnitPrice = 8
+
+	t.Cleanup(func() {
+		srv.Close()
+		stripe.SetBackend(stripe.APIBackend, original)
+		*autoSetting = savedSetting
+		setting.StripeApiSecret, setting.StripeUnitPrice = savedSecret, savedPrice
+		autoRechargeGates = sync.Map{}
+	})
+}
+
+func seedAutoRechargeUser(t *testing.T, id int, quota int, dailyCap int64) {
+	t.Helper()
+	seedUser(t, id, quota)
+	require.NoError(t, model.SetAutoRechargePaymentMethod(id, "cus_auto", "pm_auto", "visa", "4242"))
+	_, err := model.UpdateAutoRechargeRules(id, true, 1000, 10, dailyCap)
+	require.NoError(t, err)
+}
+
+func TestRunAutoRechargeChargesSavedCardOncePerRequest(t *testing.T) {
+	fake := &fakeStripe{}
+	setupAutoRecharge(t, fake)
+	seedAutoRechargeUser(t, 1, 100, 15)
+
+	attempt, err := RunAutoRecharge(1, "req-1")
+	require.NoError(t, err)
+	require.NotNil(t, attempt)
+	require.Equal(t, 1, fake.calls())
+	require.Equal(t, "auto_recharge:1:req-1", fake.idemKeys[0])
+	require.Equal(t, "8000", fake.forms[0].Get("amount"))
+	require.Equal(t, "true", fake.forms[0].Get("off_session"))
+	require.Equal(t, "pm_auto", fake.forms[0].Get("payment_method"))
+	require.Equal(t, attempt.TradeNo, fake.forms[0].Get("metadata[trade_no]"))
+
+	require.Equal(t, 100+int(10*common.QuotaPerUnit), getUserQuota(t, 1))
+	topUp := model.GetTopUpByTradeNo(attempt.TradeNo)
+	require.NotNil(t, topUp)
+	require.Equal(t, common.TopUpStatusSuccess, topUp.Status)
+	stored, err := model.GetAutoRechargeAttemptByTradeNo(attempt.TradeNo)
+	require.NoError(t, err)
+	require.Equal(t, model.AutoRechargeStatusSucceeded, stored.Status)
+	require.Equal(t, "pi_auto_1", stored.PaymentIntentId)
+
+	// 同一请求重复结算不会再次扣款
+	require.NoError(t, model.DB.Model(&model.User{}).Where("id = ?", 1).Update("quota", 100).Error)
+	attempt, err = RunAutoRecharge(1, "req-1")
+	require.NoError(t, err)
+	require.Nil(t, attempt)
+
+	// 超过每日上限时跳过
+	attempt, err = RunAutoRecharge(1, "req-2")
+	require.NoError(t, err)
+	require.Nil(t, attempt)
+	require.Equal(t, 1, fake.calls())
+	require.Equal(t, 100, getUserQuota(t, 1))
+}
+
+func TestRunAutoRechargeSkipsAboveThreshold(t *testing.T) {
+	fake := &fakeStripe{}
+	setupAutoRecharge(t, fake)
+	seedAutoRechargeUser(t, 1, 5000, 0)
+
+	attempt, err := RunAutoRecharge(1, "req-1")
+	require.NoError(t, err)
+	require.Nil(t, attempt)
+	require.Zero(t, fake.calls())
+}
+
+func TestRunAutoRechargeFailureNotifiesAndDisables(t *testing.T) {
+	fake := &fakeStripe{declined: true}
+	setupAutoRecharge(t, fake)
+	seedAutoRechargeUser(t, 1, 100, 0)
+
+	var notifyMu sync.Mutex
+	var notifications []string
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		notifyMu.Lock()
+		notifications = append(notifications, string(body))
+		notifyMu.Unlock()
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer hook.Close()
+	fetchSetting := system_setting.GetFetchSetting()
+	savedSSRF := fetchSetting.EnableSSRFProtection
+	fetchSetting.EnableSSRFProtection = false
+	savedLimit := constant.NotifyLimitCount
+	constant.NotifyLimitCount = 10
+	savedClient := httpClient
+	httpClient = hook.Client()
+	t.Cleanup(func() {
+		fetchSetting.EnableSSRFProtection = savedSSRF
+		constant.NotifyLimitCount = savedLimit
+		httpClient = savedClient
+	})
+
+	user, err := model.GetUserById(1, false)
+	require.NoError(t, err)
+	user.SetSetting(dto.UserSetting{NotifyType: dto.NotifyTypeWebhook, WebhookUrl: hook.URL})
+	require.NoError(t, model.DB.Model(user).Update("setting", user.Setting).Error)
+
+	attempt, err := RunAutoRecharge(1, "req-1")
+	require.Error(t, err)
+	require.NotNil(t, attempt)
+	stored, err := model.GetAutoRechargeAttemptByTradeNo(attempt.TradeNo)
+	require.NoError(t, err)
+	require.Equal(t, model.AutoRechargeStatusFailed, stored.Status)
+	require.Equal(t, "Your card was declined.", stored.Error)
+	require.Equal(t, common.TopUpStatusFailed, model.GetTopUpByTradeNo(attempt.TradeNo).Status)
+	config, err := model.GetAutoRechargeConfig(1)
+	require.NoError(t, err)
+	require.True(t, config.Enabled)
+	require.Equal(t, 1, config.ConsecutiveFailures)
+
+	_, err = RunAutoRecharge(1, "req-2")
+	require.Error(t, err)
+	config, err = model.GetAutoRechargeConfig(1)
+	require.NoError(t, err)
+	require.False(t, config.Enabled)
+	require.Equal(t, 100, getUserQuota(t, 1))
+
+	notifyMu.Lock()
+	defer notifyMu.Unlock()
+	require.Len(t, notifications, 2)
+	require.Contains(t, notifications[0], dto.NotifyTypeAutoRecharge)
+	require.Contains(t, notifications[1], "自动充值已关闭")
+}
@@ -13,6 +13,7 @@ import (
 const (
 	BillingSourceWallet       = "wallet"
 	BillingSourceSubscription = "subscription"
+	BillingSourceOrganization = "organization"
 )
 
 // PreConsumeBilling 根据用户计费偏好创建 BillingSession 并执行预扣费。
@@ -79,16 +80,17 @@ func SettleBilling(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, actualQuo
 		if actualQuota != 0 {
 			if relayInfo.BillingSource == BillingSourceSubscription {
 				checkAndSendSubscriptionQuotaNotify(relayInfo)
-			} else {
+			} else if relayInfo.BillingSource != BillingSourceOrganization {
 				checkAndSendQuotaNotify(relayInfo, actualQuota-preConsumed, preConsumed)
+				TriggerAutoRecharge(relayInfo.UserId, relayInfo.UserQuota-actualQuota, relayInfo.RequestId)
 			}
 		}
 		return nil
 	}
 
-	// 回退：无 BillingSession 时使用旧路径
+	// 回退：无 BillingSession 时使用旧路径；差额为 0 时仍需按全额同步额度批次
 	quotaDelta := actualQuota - relayInfo.FinalPreConsumedQuota
-	if quotaDelta != 0 {
+	if quotaDelta != 0 || actualQuota != 0 {
 		return PostConsumeQuota(relayInfo, quotaDelta, relayInfo.FinalPreConsumedQuota, true)
 	}
 	return nil
@@ -1,6 +1,7 @@
 package service
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"strings"
@@ -44,18 +45,24 @@ func (s *BillingSession) Settle(actualQuota int) error {
 	if s.settled {
 		return nil
 	}
-	delta := actualQuota - s.preConsumedQuota
-	if delta == 0 {
-		s.settled = true
-		return nil
+	// 按 token 计量的订阅额度桶与额度差值无关，单独结算
+	if sub, ok := s.funding.(*SubscriptionFunding); ok {
+		if err := sub.SettleTokens(s.relayInfo.UsageTotalTokens); err != nil {
+			common.SysLog(fmt.Sprintf("error settling subscription bucket tokens (userId=%d, bucketId=%d): %s", s.relayInfo.UserId, sub.bucketId, err.Error()))
+		}
 	}
-	// 1) 调整资金来源（仅在尚未提交时执行，防止重复调用）
+	delta := actualQuota - s.preConsumedQuota
+	// 1) 调整资金来源（仅在尚未提交时执行，防止重复调用）；差额为 0 时钱包仍需按全额消耗额度批次
 	if !s.fundingSettled {
 		if err := s.funding.Settle(delta); err != nil {
 			return err
 		}
 		s.fundingSettled = true
 	}
+	if delta == 0 {
+		s.settled = true
+		return nil
+	}
 	// 2) 调整令牌额度
 	var tokenErr error
 	if !s.relayInfo.IsPlayground {
@@ -101,6 +108,8 @@ func (s *BillingSession) Refund(c *gin.Context) {
 	tokenConsumed := s.tokenConsumed
 	extraReserved := s.extraReserved
 	subscriptionId := s.relayInfo.SubscriptionId
+	subscriptionBucketId := s.relayInfo.SubscriptionBucketId
+	requestId := s.relayInfo.RequestId
 	funding := s.funding
 
 	gopool.Go(func() {
@@ -108,9 +117,11 @@ func (s *BillingSession) Refund(c *gin.Context) {
 		if err := funding.Refund(); err != nil {
 			common.SysLog("error refunding billing source: " + err.Error())
 		}
-		if extraReserved > 0 && funding.Source() == BillingSourceSubscription && subscriptionId > 0 {
-			if err := model.PostConsumeUserSubscriptionDelta(subscriptionId, -int64(extraReserved)); err != nil {
+		if sub, ok := funding.(*SubscriptionFunding); ok && extraReserved > 0 && subscriptionId > 0 {
+			if err := model.PostConsumeUserSubscriptionQuotaDelta(subscriptionId, subscriptionBucketId, -int64(extraReserved), model.QuotaLedgerRefRequestId, requestId); err != nil {
 				common.SysLog("error refunding subscription extra reserved quota: " + err.Error())
+			} else if err := sub.settleMemberSpend(-extraReserved); err != nil {
+				common.SysLog("error refunding organization member spend: " + err.Error())
 			}
 		}
 		// 2) 退还令牌额度
@@ -141,6 +152,9 @@ func (s *BillingSession) needsRefundLocked() bool {
 	if sub, ok := s.funding.(*SubscriptionFunding); ok && sub.preConsumed > 0 {
 		return true
 	}
+	if org, ok := s.funding.(*OrganizationFunding); ok && org.consumed > 0 {
+		return true
+	}
 	return false
 }
 
@@ -213,6 +227,15 @@ func (s *BillingSession) preConsume(c *gin.Context, quota int) *types.NewAPIErro
 			}
 			s.tokenConsumed = 0
 		}
+		if errors.Is(err, model.ErrOrganizationQuotaInsufficient) {
+			return types.NewErrorWithStatusCode(errors.New("组织额度不足"), types.ErrorCodeInsufficientUserQuota, http.StatusForbidden, types.ErrOptionWithSkipRetry(), types.ErrOptionWithNoRecordErrorLog())
+		}
+		if errors.Is(err, model.ErrOrganizationMemberLimitExceeded) {
+			return types.NewErrorWithStatusCode(errors.New("已超出组织为您设置的消费上限"), types.ErrorCodeInsufficientUserQuota, http.StatusForbidden, types.ErrOptionWithSkipRetry(), types.ErrOptionWithNoRecordErrorLog())
+		}
+		if errors.Is(err, model.ErrOrganizationNotFound) || errors.Is(err, model.ErrOrganizationNotMember) {
+			return types.NewErrorWithStatusCode(err, types.ErrorCodeAccessDenied, http.StatusForbidden, types.ErrOptionWithSkipRetry(), types.ErrOptionWithNoRecordErrorLog())
+		}
 		// TODO: model 层应定义哨兵错误（如 ErrNoActiveSubscription），用 errors.Is 替代字符串匹配
 		errMsg := err.Error()
 		if strings.Contains(errMsg, "no active subscription") || strings.Contains(errMsg, "subscription quota insufficient") {
@@ -232,13 +255,21 @@ func (s *BillingSession) preConsume(c *gin.Context, quota int) *types.NewAPIErro
 func (s *BillingSession) reserveFunding(delta int) error {
 	switch funding := s.funding.(type) {
 	case *WalletFunding:
-		if err := model.DecreaseUserQuota(funding.userId, delta, false); err != nil {
+		if err := model.SettleUserWalletQuota(funding.userId, delta, 0, model.QuotaLedgerRefRequestId, funding.requestId); err != nil {
 			return types.NewError(err, types.ErrorCodeUpdateDataError, types.ErrOptionWithSkipRetry())
 		}
 		funding.consumed += delta
 		return nil
 	case *SubscriptionFunding:
-		if err := model.PostConsumeUserSubscriptionDelta(funding.subscriptionId, int64(delta)); err != nil {
+		if funding.organizationId > 0 {
+			if err := model.ChargeOrganizationMemberSpend(funding.organizationId, funding.userId, delta); err != nil {
+				return types.NewErrorWithStatusCode(err, types.ErrorCodeInsufficientUserQuota, http.StatusForbidden, types.ErrOptionWithSkipRetry(), types.ErrOptionWithNoRecordErrorLog())
+			}
+		}
+		if err := model.PostConsumeUserSubscriptionQuotaDelta(funding.subscriptionId, funding.bucketId, int64(delta), model.QuotaLedgerRefRequestId, funding.requestId); err != nil {
+			if rollbackErr := funding.settleMemberSpend(-delta); rollbackErr != nil {
+				common.SysLog("error rolling back organization member spend: " + rollbackErr.Error())
+			}
 			return types.NewErrorWithStatusCode(
 				fmt.Errorf("订阅额度不足或未配置订阅: %s", err.Error()),
 				types.ErrorCodeInsufficientUserQuota,
@@ -248,6 +279,12 @@ func (s *BillingSession) reserveFunding(delta int) error {
 			)
 		}
 		return nil
+	case *OrganizationFunding:
+		if err := model.PreConsumeOrganizationQuota(funding.organizationId, funding.userId, delta, model.QuotaLedgerRefRequestId, funding.requestId); err != nil {
+			return types.NewErrorWithStatusCode(err, types.ErrorCodeInsufficientUserQuota, http.StatusForbidden, types.ErrOptionWithSkipRetry(), types.ErrOptionWithNoRecordErrorLog())
+		}
+		funding.consumed += delta
+		return nil
 	default:
 		return types.NewError(fmt.Errorf("unsupported funding source: %s", s.funding.Source()), types.ErrorCodeUpdateDataError, types.ErrOptionWithSkipRetry())
 	}
@@ -256,14 +293,22 @@ func (s *BillingSession) reserveFunding(delta int) error {
 func (s *BillingSession) rollbackFundingReserve(delta int) {
 	switch funding := s.funding.(type) {
 	case *WalletFunding:
-		if err := model.IncreaseUserQuota(funding.userId, delta, false); err != nil {
+		if err := model.SettleUserWalletQuota(funding.userId, -delta, 0, model.QuotaLedgerRefRequestId, funding.requestId); err != nil {
 			common.SysLog("error rolling back wallet funding reserve: " + err.Error())
 		} else {
 			funding.consumed -= delta
 		}
 	case *SubscriptionFunding:
-		if err := model.PostConsumeUserSubscriptionDelta(funding.subscriptionId, -int64(delta)); err != nil {
+		if err := model.PostConsumeUserSubscriptionQuotaDelta(funding.subscriptionId, funding.bucketId, -int64(delta), model.QuotaLedgerRefRequestId, funding.requestId); err != nil {
 			common.SysLog("error rolling back subscription funding reserve: " + err.Error())
+		} else if err := funding.settleMemberSpend(-delta); err != nil {
+			common.SysLog("error rolling back organization member spend: " + err.Error())
+		}
+	case *OrganizationFunding:
+		if err := model.SettleOrganizationQuota(funding.organizationId, funding.userId, -delta, model.QuotaLedgerRefRequestId, funding.requestId); err != nil {
+			common.SysLog("error rolling back organization funding reserve: " + err.Error())
+		} else {
+			funding.consumed -= delta
 		}
 	}
 }
@@ -309,6 +354,9 @@ func (s *BillingSession) shouldTrust(c *gin.Context) bool {
 		// 2. SubscriptionFunding.PreConsume 忽略参数，始终用 s.amount 预扣
 		// 3. 若信任旁路将 effectiveQuota 设为 0，会导致 preConsumedQuota 与实际订阅预扣不一致
 		return false
+	case BillingSourceOrganization:
+		// 组织钱包需要逐次预扣以执行成员消费上限
+		return false
 	default:
 		return false
 	}
@@ -328,9 +376,18 @@ func (s *BillingSession) syncRelayInfo() {
 		info.SubscriptionAmountUsedAfterPreConsume = sub.AmountUsedAfter + int64(s.extraReserved)
 		info.SubscriptionPlanId = sub.PlanId
 		info.SubscriptionPlanTitle = sub.PlanTitle
+		info.SubscriptionBucketId = sub.bucketId
+		info.SubscriptionBucketName = sub.BucketName
+		info.SubscriptionBucketUnit = sub.bucketUnit
+		info.SubscriptionBucketPreConsumed = 0
+		if sub.bucketId > 0 {
+			info.SubscriptionBucketPreConsumed = sub.preConsumed
+		}
 	} else {
 		info.SubscriptionId = 0
 		info.SubscriptionPreConsumed = 0
+		info.SubscriptionBucketId = 0
+		info.SubscriptionBucketPreConsumed = 0
 	}
 }
 
@@ -344,6 +401,11 @@ func NewBillingSession(c *gin.Context, relayInfo *relaycommon.RelayInfo, preCons
 		return nil, types.NewError(fmt.Errorf("relayInfo is nil"), types.ErrorCodeInvalidRequest, types.ErrOptionWithSkipRetry())
 	}
 
+	// 组织令牌只从组织订阅和组织钱包计费，不参与个人钱包/订阅的偏好与回退
+	if relayInfo.OrganizationId > 0 {
+		return newOrganizationBillingSession(c, relayInfo, preConsumedQuota)
+	}
+
 	pref := common.NormalizeBillingPreference(relayInfo.UserSetting.BillingPreference)
 
 	// 钱包路径需要先检查用户额度
@@ -352,13 +414,18 @@ func NewBillingSession(c *gin.Context, relayInfo *relaycommon.RelayInfo, preCons
 		if err != nil {
 			return nil, types.NewError(err, types.ErrorCodeQueryDataError, types.ErrOptionWithSkipRetry())
 		}
-		if userQuota <= 0 {
+		// 余额不足时才查询信用额度，开通后付费的用户可以透支到 -CreditLimit
+		availableQuota := userQuota
+		if userQuota <= 0 || userQuota-preConsumedQuota < 0 {
+			availableQuota += model.GetUserPostpaidCreditLimit(relayInfo.UserId)
+		}
+		if availableQuota <= 0 {
 			return nil, types.NewErrorWithStatusCode(
 				fmt.Errorf("用户额度不足, 剩余额度: %s", logger.FormatQuota(userQuota)),
 				types.ErrorCodeInsufficientUserQuota, http.StatusForbidden,
 				types.ErrOptionWithSkipRetry(), types.ErrOptionWithNoRecordErrorLog())
 		}
-		if userQuota-preConsumedQuota < 0 {
+		if availableQuota-preConsumedQuota < 0 {
 			return nil, types.NewErrorWithStatusCode(
 				fmt.Errorf("预扣费额度失败, 用户剩余额度: %s, 需要预扣费额度: %s", logger.FormatQuota(userQuota), logger.FormatQuota(preConsumedQuota)),
 				types.ErrorCodeInsufficientUserQuota, http.StatusForbidden,
@@ -368,7 +435,7 @@ func NewBillingSession(c *gin.Context, relayInfo *relaycommon.RelayInfo, preCons
 
 		session := &BillingSession{
 			relayInfo: relayInfo,
-			funding:   &WalletFunding{userId: relayInfo.UserId},
+			funding:   &WalletFunding{userId: relayInfo.UserId, requestId: relayInfo.RequestId},
 		}
 		if apiErr := session.preConsume(c, preConsumedQuota); apiErr != nil {
 			return nil, apiErr
@@ -384,10 +451,11 @@ func NewBillingSession(c *gin.Context, relayInfo *relaycommon.RelayInfo, preCons
 		session := &BillingSession{
 			relayInfo: relayInfo,
 			funding: &SubscriptionFunding{
-				requestId: relayInfo.RequestId,
-				userId:    relayInfo.UserId,
-				modelName: relayInfo.OriginModelName,
-				amount:    subConsume,
+				requestId:       relayInfo.RequestId,
+				userId:          relayInfo.UserId,
+				modelName:       relayInfo.OriginModelName,
+				amount:          subConsume,
+				estimatedTokens: int64(relayInfo.GetEstimatePromptTokens()),
 			},
 		}
 		// 必须传 subConsume 而非 preConsumedQuota，保证 SubscriptionFunding.amount、
@@ -440,3 +508,61 @@ func NewBillingSession(c *gin.Context, relayInfo *relaycommon.RelayInfo, preCons
 		return session, nil
 	}
 }
+
+// newOrganizationBillingSession 先从组织订阅扣费，组织没有生效订阅或订阅额度不足（且允许回退）时使用组织钱包。
+// 两条路径都计入成员已用额度，成员超过消费上限时钱包路径同样会拒绝。
+func newOrganizationBillingSession(c *gin.Context, relayInfo *relaycommon.RelayInfo, preConsumedQuota int) (*BillingSession, *types.NewAPIError) {
+	orgId := relayInfo.OrganizationId
+	tryWallet := func() (*BillingSession, *types.NewAPIError) {
+		session := &BillingSession{
+			relayInfo: relayInfo,
+			funding: &OrganizationFunding{
+				organizationId: orgId,
+				userId:         relayInfo.UserId,
+				requestId:      relayInfo.RequestId,
+			},
+		}
+		if apiErr := session.preConsume(c, preConsumedQuota); apiErr != nil {
+			return nil, apiErr
+		}
+		return session, nil
+	}
+
+	hasSub, err := model.HasActiveOrganizationSubscription(orgId)
+	if err != nil {
+		return nil, types.NewError(err, types.ErrorCodeQueryDataError, types.ErrOptionWithSkipRetry())
+	}
+	if !hasSub {
+		return tryWallet()
+	}
+	subConsume := int64(preConsumedQuota)
+	if subConsume <= 0 {
+		subConsume = 1
+	}
+	session := &BillingSession{
+		relayInfo: relayInfo,
+		funding: &SubscriptionFunding{
+			requestId:       relayInfo.RequestId,
+			userId:          relayInfo.UserId,
+			organizationId:  orgId,
+			modelName:       relayInfo.OriginModelName,
+			amount:          subConsume,
+			estimatedTokens: int64(relayInfo.GetEstimatePromptTokens()),
+		},
+	}
+	apiErr := session.preConsume(c, int(subConsume))
+	if apiErr == nil {
+		return session, nil
+	}
+	if apiErr.GetErrorCode() != types.ErrorCodeInsufficientUserQuota {
+		return nil, apiErr
+	}
+	allowOverflow, err := model.OrganizationActiveSubscriptionsAllowWalletOverflow(orgId)
+	if err != nil {
+		return nil, types.NewError(err, types.ErrorCodeQueryDataError, types.ErrOptionWithSkipRetry())
+	}
+	if !allowOverflow {
+		return nil, apiErr
+	}
+	return tryWallet()
+}
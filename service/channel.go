@@ -35,6 +35,12 @@ func DisableChannel(channelError types.ChannelError, reason string) {
 		return
 	}
 
+	// 密钥轮换宽限期内，新旧密钥均视为有效，失败不触发自动禁用
+	if model.IsChannelKeyInRotationGrace(channelError.ChannelId, channelError.UsingKey) {
+		common.SysLog(fmt.Sprintf("通道「%s」（#%d）处于密钥轮换宽限期，跳过禁用操作", channelError.ChannelName, channelError.ChannelId))
+		return
+	}
+
 	success := model.UpdateChannelStatus(channelError.ChannelId, channelError.UsingKey, common.ChannelStatusAutoDisabled, reason)
 	if success {
 		subject := fmt.Sprintf("通道「%s」（#%d）已被禁用", channelError.ChannelName, channelError.ChannelId)
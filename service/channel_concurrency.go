@@ -0,0 +1,392 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/monitor"
+)
+
+const (
+	// channelConcurrencyLeaseTTL bounds how long a Redis slot survives a node
+	// that died without releasing it. It must outlast the longest stream.
+	channelConcurrencyLeaseTTL = 30 * time.Minute
+	// channelConcurrencyQueuePoll is how often a queued waiter re-checks the
+	// shared (Redis) counter, since releases on other nodes can't wake it.
+	channelConcurrencyQueuePoll        = 200 * time.Millisecond
+	channelConcurrencyDefaultQueueWait = 10 * time.Second
+	channelConcurrencyRedisPrefix      = "channel_concurrency:"
+)
+
+var (
+	ErrChannelConcurrencySaturated = errors.New("channel concurrency limit reached")
+	ErrChannelQueueFull            = errors.New("channel concurrency queue is full")
+	ErrChannelQueueTimeout         = errors.New("timed out waiting for a channel concurrency slot")
+)
+
+// ChannelConcurrencyLimits is the effective cap configuration of a channel.
+type ChannelConcurrencyLimits struct {
+	MaxConcurrency       int
+	MaxConcurrencyPerKey int
+	QueueSize            int
+	QueueTimeout         time.Duration
+}
+
+func (l ChannelConcurrencyLimits) Enabled() bool {
+	return l.MaxConcurrency > 0 || l.MaxConcurrencyPerKey > 0
+}
+
+func GetChannelConcurrencyLimits(channel *model.Channel) ChannelConcurrencyLimits {
+	if channel == nil {
+		return ChannelConcurrencyLimits{}
+	}
+	setting := channel.GetSetting()
+	limits := ChannelConcurrencyLimits{
+		MaxConcurrency: setting.MaxConcurrency,
+		QueueSize:      setting.ConcurrencyQueueSize,
+		QueueTimeout:   time.Duration(setting.ConcurrencyQueueTimeoutMs) * time.Millisecond,
+	}
+	if channel.ChannelInfo.IsMultiKey {
+		limits.MaxConcurrencyPerKey = setting.MaxConcurrencyPerKey
+	}
+	if limits.QueueTimeout <= 0 {
+		limits.QueueTimeout = channelConcurrencyDefaultQueueWait
+	}
+	return limits
+}
+
+// ChannelConcurrencyLease is one granted slot. Release is idempotent and
+// nil-safe so callers can defer it unconditionally.
+type ChannelConcurrencyLease struct {
+	channelID int
+	keys      []string
+	member    string
+	released  bool
+	mu        sync.Mutex
+}
+
+func (l *ChannelConcurrencyLease) Release() {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	if l.released {
+		l.mu.Unlock()
+		return
+	}
+	l.released = true
+	l.mu.Unlock()
+
+	channelConcurrencyBackend().release(l.keys, l.member)
+	monitor.ChannelConcurrencyReleased(l.channelID)
+	notifyChannelQueue(l.channelID)
+}
+
+func channelConcurrencySlotKeys(channelID int, keyIndex int, limits ChannelConcurrencyLimits) ([]string, []int) {
+	keys := make([]string, 0, 2)
+	caps := make([]int, 0, 2)
+	if limits.MaxConcurrency > 0 {
+		keys = append(keys, channelConcurrencyRedisPrefix+strconv.Itoa(channelID))
+		caps = append(caps, limits.MaxConcurrency)
+	}
+	if limits.MaxConcurrencyPerKey > 0 {
+		keys = append(keys, fmt.Sprintf("%s%d:key:%d", channelConcurrencyRedisPrefix, channelID, keyIndex))
+		caps = append(caps, limits.MaxConcurrencyPerKey)
+	}
+	return keys, caps
+}
+
+// TryAcquireChannelConcurrency grabs a slot without waiting. It returns a nil
+// lease and nil error when the channel has no cap configured.
+func TryAcquireChannelConcurrency(channel *model.Channel, keyIndex int) (*ChannelConcurrencyLease, error) {
+	limits := GetChannelConcurrencyLimits(channel)
+	if !limits.Enabled() {
+		return nil, nil
+	}
+	return tryAcquireChannelConcurrency(channel.Id, keyIndex, limits)
+}
+
+func tryAcquireChannelConcurrency(channelID int, keyIndex int, limits ChannelConcurrencyLimits) (*ChannelConcurrencyLease, error) {
+	keys, caps := channelConcurrencySlotKeys(channelID, keyIndex, limits)
+	member := common.GetUUID()
+	ok, err := channelConcurrencyBackend().tryAcquire(keys, caps, member)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrChannelConcurrencySaturated
+	}
+	monitor.ChannelConcurrencyAcquired(channelID, limits.MaxConcurrency)
+	return &ChannelConcurrencyLease{channelID: channelID, keys: keys, member: member}, nil
+}
+
+// WaitChannelConcurrency waits in the FIFO queue of the channel, or of the
+// key when a per-key cap is set, until a slot frees up, the queue deadline
+// passes or ctx is done. Ordering is FIFO among the waiters of this node;
+// across nodes the shared counter is polled.
+func WaitChannelConcurrency(ctx context.Context, channel *model.Channel, keyIndex int) (*ChannelConcurrencyLease, error) {
+	limits := GetChannelConcurrencyLimits(channel)
+	if !limits.Enabled() {
+		return nil, nil
+	}
+	if limits.QueueSize <= 0 {
+		return nil, ErrChannelConcurrencySaturated
+	}
+
+	queue := getChannelQueue(channelQueueKeyFor(channel.Id, keyIndex, limits))
+	waiter, ok := queue.enqueue(limits.QueueSize)
+	if !ok {
+		return nil, ErrChannelQueueFull
+	}
+	start := time.Now()
+	monitor.ChannelQueueEnter(channel.Id)
+	acquired := false
+	defer func() {
+		queue.remove(waiter)
+		monitor.ChannelQueueLeave(channel.Id, time.Since(start), acquired)
+	}()
+
+	deadline := time.NewTimer(limits.QueueTimeout)
+	defer deadline.Stop()
+	poll := time.NewTicker(channelConcurrencyQueuePoll)
+	defer poll.Stop()
+
+	for {
+		if queue.isHead(waiter) {
+			lease, err := tryAcquireChannelConcurrency(channel.Id, keyIndex, limits)
+			if err == nil {
+				acquired = true
+				return lease, nil
+			}
+			if !errors.Is(err, ErrChannelConcurrencySaturated) {
+				return nil, err
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-deadline.C:
+			return nil, ErrChannelQueueTimeout
+		case <-waiter.wake:
+		case <-poll.C:
+		}
+	}
+}
+
+// --- FIFO queue (node-local) ---
+
+// channelQueueKey scopes a wait queue the same way as the cap it waits on:
+// per key when the channel has a per-key cap, otherwise per channel, so a
+// waiter for a full key never blocks waiters for free keys.
+type channelQueueKey struct {
+	channelID int
+	keyIndex  int
+}
+
+func channelQueueKeyFor(channelID int, keyIndex int, limits ChannelConcurrencyLimits) channelQueueKey {
+	if limits.MaxConcurrencyPerKey <= 0 {
+		keyIndex = 0
+	}
+	return channelQueueKey{channelID: channelID, keyIndex: keyIndex}
+}
+
+type channelQueueWaiter struct {
+	wake chan struct{}
+}
+
+type channelQueue struct {
+	mu      sync.Mutex
+	waiters []*channelQueueWaiter
+}
+
+var channelQueues sync.Map // map[channelQueueKey]*channelQueue
+
+func getChannelQueue(key channelQueueKey) *channelQueue {
+	if q, ok := channelQueues.Load(key); ok {
+		return q.(*channelQueue)
+	}
+	q, _ := channelQueues.LoadOrStore(key, &channelQueue{})
+	return q.(*channelQueue)
+}
+
+func (q *channelQueue) enqueue(limit int) (*channelQueueWaiter, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.waiters) >= limit {
+		return nil, false
+	}
+	waiter := &channelQueueWaiter{wake: make(chan struct{}, 1)}
+	q.waiters = append(q.waiters, waiter)
+	return waiter, true
+}
+
+func (q *channelQueue) isHead(waiter *channelQueueWaiter) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.waiters) > 0 && q.waiters[0] == waiter
+}
+
+func (q *channelQueue) remove(waiter *channelQueueWaiter) {
+	q.mu.Lock()
+	for i, w := range q.waiters {
+		if w == waiter {
+			q.waiters = append(q.waiters[:i], q.waiters[i+1:]...)
+			break
+		}
+	}
+	var head *channelQueueWaiter
+	if len(q.waiters) > 0 {
+		head = q.waiters[0]
+	}
+	q.mu.Unlock()
+	if head != nil {
+		head.signal()
+	}
+}
+
+func (q *channelQueue) wakeHead() {
+	q.mu.Lock()
+	var head *channelQueueWaiter
+	if len(q.waiters) > 0 {
+		head = q.waiters[0]
+	}
+	q.mu.Unlock()
+	if head != nil {
+		head.signal()
+	}
+}
+
+func (w *channelQueueWaiter) signal() {
+	select {
+	case w.wake <- struct{}{}:
+	default:
+	}
+}
+
+// notifyChannelQueue wakes every queue of the channel: a release frees the
+// channel-wide slot as well as the key's, so waiters on other keys may fit.
+func notifyChannelQueue(channelID int) {
+	channelQueues.Range(func(key, q any) bool {
+		if key.(channelQueueKey).channelID == channelID {
+			q.(*channelQueue).wakeHead()
+		}
+		return true
+	})
+}
+
+// --- slot counters ---
+
+type channelConcurrencyStore interface {
+	tryAcquire(keys []string, caps []int, member string) (bool, error)
+	release(keys []string, member string)
+	count(key string) (int, error)
+}
+
+func channelConcurrencyBackend() channelConcurrencyStore {
+	if common.RedisEnabled && common.RDB != nil {
+		return redisChannelConcurrencyStore{}
+	}
+	return localChannelConcurrency
+}
+
+type memoryChannelConcurrencyStore struct {
+	mu     sync.Mutex
+	counts map[string]int
+}
+
+var localChannelConcurrency = &memoryChannelConcurrencyStore{counts: make(map[string]int)}
+
+func (s *memoryChannelConcurrencyStore) tryAcquire(keys []string, caps []int, _ string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, key := range keys {
+		if s.counts[key] >= caps[i] {
+			return false, nil
+		}
+	}
+	for _, key := range keys {
+		s.counts[key]++
+	}
+	return true, nil
+}
+
+func (s *memoryChannelConcurrencyStore) release(keys []string, _ string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, key := range keys {
+		if s.counts[key] <= 1 {
+			delete(s.counts, key)
+			continue
+		}
+		s.counts[key]--
+	}
+}
+
+func (s *memoryChannelConcurrencyStore) count(key string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.counts[key], nil
+}
+
+// Each slot key is a sorted set of lease members scored by expiry, so slots
+// held by a crashed node age out after channelConcurrencyLeaseTTL.
+const redisChannelConcurrencyAcquireScript = `
+local now = tonumber(ARGV[1])
+local expireAt = tonumber(ARGV[2])
+local ttlMs = tonumber(ARGV[3])
+local member = ARGV[4]
+for i, key in ipairs(KEYS) do
+	redis.call("ZREMRANGEBYSCORE", key, "-inf", now)
+	if redis.call("ZCARD", key) >= tonumber(ARGV[4 + i]) then
+		return 0
+	end
+end
+for _, key in ipairs(KEYS) do
+	redis.call("ZADD", key, expireAt, member)
+	redis.call("PEXPIRE", key, ttlMs)
+end
+return 1
+`
+
+type redisChannelConcurrencyStore struct{}
+
+func (redisChannelConcurrencyStore) tryAcquire(keys []string, caps []int, member string) (bool, error) {
+	now := time.Now()
+	args := []interface{}{
+		now.UnixMilli(),
+		now.Add(channelConcurrencyLeaseTTL).UnixMilli(),
+		channelConcurrencyLeaseTTL.Milliseconds(),
+		member,
+	}
+	for _, c := range caps {
+		args = append(args, c)
+	}
+	result, err := common.RDB.Eval(context.Background(), redisChannelConcurrencyAcquireScript, keys, args...).Int()
+	if err != nil {
+		return false, err
+	}
+	return result == 1, nil
+}
+
+func (redisChannelConcurrencyStore) release(keys []string, member string) {
+	for _, key := range keys {
+		if err := common.RDB.ZRem(context.Background(), key, member).Err(); err != nil {
+			common.SysError(fmt.Sprintf("release channel concurrency slot failed: key=%s, err=%v", key, err))
+		}
+	}
+}
+
+func (redisChannelConcurrencyStore) count(key string) (int, error) {
+	ctx := context.Background()
+	if err := common.RDB.ZRemRangeByScore(ctx, key, "-inf", strconv.FormatInt(time.Now().UnixMilli(), 10)).Err(); err != nil {
+		return 0, err
+	}
+	n, err := common.RDB.ZCard(ctx, key).Result()
+	return int(n), err
+}
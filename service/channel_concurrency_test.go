@@ -0,0 +1,157 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/require"
+)
+
+func newConcurrencyTestChannel(id int, settingJSON string) *model.Channel {
+	return &model.Channel{Id: id, Setting: common.GetPointer(settingJSON)}
+}
+
+func TestTryAcquireChannelConcurrencyUncapped(t *testing.T) {
+	lease, err := TryAcquireChannelConcurrency(newConcurrencyTestChannel(9101, `{}`), 0)
+	require.NoError(t, err)
+	require.Nil(t, lease)
+	lease.Release()
+}
+
+func TestTryAcquireChannelConcurrencyMemory(t *testing.T) {
+	channel := newConcurrencyTestChannel(9102, `{"max_concurrency":2}`)
+
+	first, err := TryAcquireChannelConcurrency(channel, 0)
+	require.NoError(t, err)
+	second, err := TryAcquireChannelConcurrency(channel, 0)
+	require.NoError(t, err)
+	_, err = TryAcquireChannelConcurrency(channel, 0)
+	require.ErrorIs(t, err, ErrChannelConcurrencySaturated)
+
+	first.Release()
+	first.Release() // idempotent
+	third, err := TryAcquireChannelConcurrency(channel, 0)
+	require.NoError(t, err)
+	second.Release()
+	third.Release()
+}
+
+func TestWaitChannelConcurrencyQueue(t *testing.T) {
+	channel := newConcurrencyTestChannel(9103, `{"max_concurrency":1,"concurrency_queue_size":1,"concurrency_queue_timeout_ms":2000}`)
+	holder, err := TryAcquireChannelConcurrency(channel, 0)
+	require.NoError(t, err)
+
+	result := make(chan error, 1)
+	go func() {
+		lease, waitErr := WaitChannelConcurrency(context.Background(), channel, 0)
+		if waitErr == nil {
+			lease.Release()
+		}
+		result <- waitErr
+	}()
+
+	require.Eventually(t, func() bool {
+		queue := getChannelQueue(channelQueueKey{channelID: channel.Id})
+		queue.mu.Lock()
+		defer queue.mu.Unlock()
+		return len(queue.waiters) == 1
+	}, time.Second, 5*time.Millisecond)
+
+	// The single queue slot is taken, so a second waiter is rejected.
+	_, err = WaitChannelConcurrency(context.Background(), channel, 0)
+	require.ErrorIs(t, err, ErrChannelQueueFull)
+
+	holder.Release()
+	select {
+	case err := <-result:
+		require.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("queued waiter was not woken by release")
+	}
+}
+
+func TestWaitChannelConcurrencyTimeout(t *testing.T) {
+	channel := newConcurrencyTestChannel(9104, `{"max_concurrency":1,"concurrency_queue_size":4,"concurrency_queue_timeout_ms":50}`)
+	holder, err := TryAcquireChannelConcurrency(channel, 0)
+	require.NoError(t, err)
+	defer holder.Release()
+
+	_, err = WaitChannelConcurrency(context.Background(), channel, 0)
+	require.ErrorIs(t, err, ErrChannelQueueTimeout)
+}
+
+func TestWaitChannelConcurrencyQueuesPerKey(t *testing.T) {
+	channel := newConcurrencyTestChannel(9106, `{"max_concurrency_per_key":1,"concurrency_queue_size":1,"concurrency_queue_timeout_ms":2000}`)
+	channel.ChannelInfo.IsMultiKey = true
+	holder, err := TryAcquireChannelConcurrency(channel, 0)
+	require.NoError(t, err)
+	defer holder.Release()
+
+	waiting := make(chan struct{})
+	go func() {
+		defer close(waiting)
+		lease, waitErr := WaitChannelConcurrency(context.Background(), channel, 0)
+		if waitErr == nil {
+			lease.Release()
+		}
+	}()
+	require.Eventually(t, func() bool {
+		queue := getChannelQueue(channelQueueKey{channelID: channel.Id, keyIndex: 0})
+		queue.mu.Lock()
+		defer queue.mu.Unlock()
+		return len(queue.waiters) == 1
+	}, time.Second, 5*time.Millisecond)
+
+	// A waiter for a full key must not hold up a free key of the same channel.
+	lease, err := WaitChannelConcurrency(context.Background(), channel, 1)
+	require.NoError(t, err)
+	require.NotNil(t, lease)
+	lease.Release()
+
+	holder.Release()
+	select {
+	case <-waiting:
+	case <-time.After(time.Second):
+		t.Fatal("queued waiter was not woken by release")
+	}
+}
+
+func TestTryAcquireChannelConcurrencyRedisPerKey(t *testing.T) {
+	server := miniredis.RunT(t)
+	oldRDB := common.RDB
+	oldRedisEnabled := common.RedisEnabled
+	common.RDB = redis.NewClient(&redis.Options{Addr: server.Addr()})
+	common.RedisEnabled = true
+	t.Cleanup(func() {
+		_ = common.RDB.Close()
+		common.RDB = oldRDB
+		common.RedisEnabled = oldRedisEnabled
+	})
+
+	channel := newConcurrencyTestChannel(9105, `{"max_concurrency":3,"max_concurrency_per_key":1}`)
+	channel.ChannelInfo.IsMultiKey = true
+
+	keyZero, err := TryAcquireChannelConcurrency(channel, 0)
+	require.NoError(t, err)
+	_, err = TryAcquireChannelConcurrency(channel, 0)
+	require.ErrorIs(t, err, ErrChannelConcurrencySaturated)
+
+	keyOne, err := TryAcquireChannelConcurrency(channel, 1)
+	require.NoError(t, err)
+
+	count, err := redisChannelConcurrencyStore{}.count(fmt.Sprintf("%s%d", channelConcurrencyRedisPrefix, channel.Id))
+	require.NoError(t, err)
+	require.Equal(t, 2, count)
+
+	keyZero.Release()
+	again, err := TryAcquireChannelConcurrency(channel, 0)
+	require.NoError(t, err)
+	again.Release()
+	keyOne.Release()
+}
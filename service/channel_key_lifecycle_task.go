@@ -0,0 +1,133 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+
+	"github.com/bytedance/gopkg/util/gopool"
+)
+
+const (
+	channelKeyLifecycleTickInterval = 10 * time.Minute
+	channelKeyGraceClaimBatchSize   = 100
+)
+
+var (
+	channelKeyLifecycleOnce    sync.Once
+	channelKeyLifecycleRunning atomic.Bool
+)
+
+// StartChannelKeyLifecycleTask warns about channel keys approaching their
+// expiry and closes the grace period of finished key rotations.
+func StartChannelKeyLifecycleTask() {
+	channelKeyLifecycleOnce.Do(func() {
+		if !common.IsMasterNode {
+			return
+		}
+		gopool.Go(func() {
+			logger.LogInfo(context.Background(), fmt.Sprintf("channel key lifecycle task started: tick=%s", channelKeyLifecycleTickInterval))
+			ticker := time.NewTicker(channelKeyLifecycleTickInterval)
+			defer ticker.Stop()
+
+			runChannelKeyLifecycleOnce()
+			for range ticker.C {
+				runChannelKeyLifecycleOnce()
+			}
+		})
+	})
+}
+
+func runChannelKeyLifecycleOnce() {
+	if !channelKeyLifecycleRunning.CompareAndSwap(false, true) {
+		return
+	}
+	defer channelKeyLifecycleRunning.Store(false)
+
+	now := time.Now()
+	warnChannelKeyExpiry(now)
+	finishChannelKeyRotationGraces(now)
+}
+
+// ChannelKeyExpiryDue returns the key indexes whose expiry falls inside the
+// warning window and has not been announced yet, in ascending order.
+func ChannelKeyExpiryDue(info model.ChannelInfo, now time.Time, warnBefore time.Duration) []int {
+	var due []int
+	for keyIndex, expiresAt := range info.KeyExpiresAt {
+		if expiresAt <= 0 || info.KeyExpiryWarnedAt[keyIndex] == expiresAt {
+			continue
+		}
+		if time.Unix(expiresAt, 0).Sub(now) <= warnBefore {
+			due = append(due, keyIndex)
+		}
+	}
+	sort.Ints(due)
+	return due
+}
+
+func warnChannelKeyExpiry(now time.Time) {
+	setting := operation_setting.GetChannelKeyRotationSetting()
+	if !setting.ExpiryWarningEnabled {
+		return
+	}
+	ctx := context.Background()
+	channels, err := model.GetChannelsWithKeyExpiry()
+	if err != nil {
+		logger.LogWarn(ctx, fmt.Sprintf("channel key expiry check: query channels failed: %v", err))
+		return
+	}
+	warnBefore := time.Duration(setting.ExpiryWarningDays) * 24 * time.Hour
+	for _, channel := range channels {
+		for _, keyIndex := range ChannelKeyExpiryDue(channel.ChannelInfo, now, warnBefore) {
+			expiresAt := channel.ChannelInfo.KeyExpiresAt[keyIndex]
+			marked, err := model.MarkChannelKeyExpiryWarned(channel.Id, keyIndex, expiresAt)
+			if err != nil {
+				logger.LogWarn(ctx, fmt.Sprintf("channel key expiry check: channel_id=%d key_index=%d mark failed: %v", channel.Id, keyIndex, err))
+				continue
+			}
+			if !marked {
+				continue
+			}
+			expiry := time.Unix(expiresAt, 0).Format(time.DateTime)
+			subject := fmt.Sprintf("通道「%s」（#%d）密钥即将到期", channel.Name, channel.Id)
+			if expiresAt <= now.Unix() {
+				subject = fmt.Sprintf("通道「%s」（#%d）密钥已到期", channel.Name, channel.Id)
+			}
+			content := fmt.Sprintf("通道「%s」（#%d）的密钥（索引 %d）到期时间为 %s", channel.Name, channel.Id, keyIndex, expiry)
+			if owner := channel.ChannelInfo.KeyRotationOwner[keyIndex]; owner != "" {
+				content += fmt.Sprintf("，轮换负责人：%s", owner)
+			}
+			content += "，请及时轮换。"
+			NotifyRootUser(dto.NotifyTypeChannelUpdate, subject, content)
+		}
+	}
+}
+
+func finishChannelKeyRotationGraces(now time.Time) {
+	ctx := context.Background()
+	rotations, err := model.ClaimEndedChannelKeyRotationGraces(now.Unix(), channelKeyGraceClaimBatchSize)
+	if err != nil {
+		logger.LogWarn(ctx, fmt.Sprintf("channel key rotation grace check failed: %v", err))
+	}
+	for _, rotation := range rotations {
+		params := map[string]interface{}{
+			"id":          rotation.ChannelId,
+			"key_index":   rotation.KeyIndex,
+			"rotation_id": rotation.Id,
+		}
+		content := fmt.Sprintf("Grace period of key rotation #%d on channel (ID: %d) ended; the old key is no longer treated as valid", rotation.Id, rotation.ChannelId)
+		model.RecordOperationAuditLog(rotation.OperatorId, content, "", "channel.key_rotation_grace_end", params, nil, nil)
+		NotifyRootUser(dto.NotifyTypeChannelUpdate,
+			fmt.Sprintf("通道 #%d 密钥轮换宽限期已结束", rotation.ChannelId),
+			fmt.Sprintf("通道 #%d 的密钥（索引 %d）轮换宽限期已结束，旧密钥 %s 可以在上游吊销。", rotation.ChannelId, rotation.KeyIndex, rotation.OldKeyPreview))
+	}
+}
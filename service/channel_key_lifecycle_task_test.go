@@ -0,0 +1,24 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/QuantumNous/new-api/model"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChannelKeyExpiryDue(t *testing.T) {
+	now := time.Unix(1_800_000_000, 0)
+	day := int64(24 * 3600)
+	info := model.ChannelInfo{
+		KeyExpiresAt: map[int]int64{
+			0: now.Unix() + 3*day,  // inside the window
+			1: now.Unix() + 30*day, // too far away
+			2: now.Unix() - day,    // already expired
+			3: now.Unix() + day,    // inside, but already announced
+		},
+		KeyExpiryWarnedAt: map[int]int64{3: now.Unix() + day},
+	}
+	require.Equal(t, []int{0, 2}, ChannelKeyExpiryDue(info, now, 7*24*time.Hour))
+}
@@ -0,0 +1,124 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/model"
+
+	"github.com/bytedance/gopkg/util/gopool"
+)
+
+// Window boundaries are minute-aligned, so a sub-minute tick logs each
+// transition within the minute it happens.
+const channelScheduleWatchTickInterval = 30 * time.Second
+
+var (
+	channelScheduleWatchOnce    sync.Once
+	channelScheduleWatchRunning atomic.Bool
+
+	channelScheduleWatchMu   sync.Mutex
+	channelScheduleLastState map[int]channelScheduleObserved
+)
+
+type channelScheduleObserved struct {
+	eligible bool
+	windows  map[string]bool
+}
+
+// StartChannelScheduleWatcherTask logs channel schedule windows opening and
+// closing. Selection evaluates schedules on its own; this task only records
+// the transitions, so it runs on the master node to avoid duplicate logs.
+func StartChannelScheduleWatcherTask() {
+	channelScheduleWatchOnce.Do(func() {
+		if !common.IsMasterNode {
+			return
+		}
+		gopool.Go(func() {
+			logger.LogInfo(context.Background(), fmt.Sprintf("channel schedule watcher task started: tick=%s", channelScheduleWatchTickInterval))
+			ticker := time.NewTicker(channelScheduleWatchTickInterval)
+			defer ticker.Stop()
+
+			runChannelScheduleWatchOnce(time.Now())
+			for range ticker.C {
+				runChannelScheduleWatchOnce(time.Now())
+			}
+		})
+	})
+}
+
+func runChannelScheduleWatchOnce(now time.Time) {
+	if !channelScheduleWatchRunning.CompareAndSwap(false, true) {
+		return
+	}
+	defer channelScheduleWatchRunning.Store(false)
+
+	snapshots, err := model.GetChannelScheduleSnapshots(now)
+	if err != nil {
+		logger.LogWarn(context.Background(), fmt.Sprintf("channel schedule watch failed: %v", err))
+		return
+	}
+	for _, message := range diffChannelScheduleStates(snapshots) {
+		common.SysLog(message)
+		model.RecordLog(0, model.LogTypeSystem, message)
+	}
+}
+
+// diffChannelScheduleStates updates the last observed states and returns one
+// message per window that opened or closed. The first observation of a
+// channel only sets the baseline.
+func diffChannelScheduleStates(snapshots []model.ChannelScheduleSnapshot) []string {
+	channelScheduleWatchMu.Lock()
+	defer channelScheduleWatchMu.Unlock()
+
+	first := channelScheduleLastState == nil
+	next := make(map[int]channelScheduleObserved, len(snapshots))
+	var messages []string
+	for _, snapshot := range snapshots {
+		observed := channelScheduleObserved{
+			eligible: snapshot.State.Eligible,
+			windows:  make(map[string]bool, len(snapshot.State.ActiveWindows)),
+		}
+		for _, name := range snapshot.State.ActiveWindows {
+			observed.windows[name] = true
+		}
+		next[snapshot.ChannelId] = observed
+
+		previous, ok := channelScheduleLastState[snapshot.ChannelId]
+		if first || !ok {
+			continue
+		}
+		var opened, closed []string
+		for _, name := range snapshot.State.ActiveWindows {
+			if !previous.windows[name] {
+				opened = append(opened, name)
+			}
+		}
+		for name := range previous.windows {
+			if !observed.windows[name] {
+				closed = append(closed, name)
+			}
+		}
+		sort.Strings(closed)
+		label := fmt.Sprintf("渠道「%s」（#%d）", snapshot.ChannelName, snapshot.ChannelId)
+		status := fmt.Sprintf("当前参与选择，权重倍率 %.2f", snapshot.State.WeightMultiplier)
+		if !observed.eligible {
+			status = "当前不参与选择"
+		}
+		if len(opened) > 0 {
+			messages = append(messages, fmt.Sprintf("%s调度窗口开启：%s，%s", label, strings.Join(opened, "、"), status))
+		}
+		if len(closed) > 0 {
+			messages = append(messages, fmt.Sprintf("%s调度窗口关闭：%s，%s", label, strings.Join(closed, "、"), status))
+		}
+	}
+	channelScheduleLastState = next
+	return messages
+}
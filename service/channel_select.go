@@ -186,6 +186,13 @@ func CacheGetRandomSatisfiedChannel(param *RetryParam) (*model.Channel, string,
 			exclude[id] = true
 		}
 	}
+	// Channels whose concurrency cap was full when picked earlier in this
+	// request are skipped so the request can land on the next candidate.
+	if saturated, ok := common.GetContextKeyType[[]int](param.Ctx, constant.ContextKeyConcurrencySaturatedChannels); ok {
+		for _, id := range saturated {
+			exclude[id] = true
+		}
+	}
 	mergeDynamicSuppressed := func(group string) error {
 		suppressed, err := GetDynamicSuppressedChannelIDs(group, param.ModelName, param.RequestPath)
 		if err != nil {
@@ -0,0 +1,64 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+)
+
+const (
+	creditExpiryTaskInterval = 10 * time.Minute
+	creditExpiryBatchSize    = 300
+)
+
+// creditExpiryHandler 定期扣除已过期批次中未用完的额度
+type creditExpiryHandler struct{}
+
+func init() {
+	RegisterSystemTaskHandler(creditExpiryHandler{})
+}
+
+func (creditExpiryHandler) Type() string { return model.SystemTaskTypeCreditExpiry }
+
+func (creditExpiryHandler) Enabled() bool {
+	return operation_setting.GetCreditExpirySetting().Enabled
+}
+
+func (creditExpiryHandler) Interval() time.Duration { return creditExpiryTaskInterval }
+
+func (creditExpiryHandler) NewPayload() any { return nil }
+
+type CreditExpiryResult struct {
+	Expired int `json:"expired"`
+}
+
+func (creditExpiryHandler) Run(ctx context.Context, task *model.SystemTask, runnerID string) {
+	result, err := RunCreditExpirySweep(ctx)
+	if err != nil {
+		failSystemTask(task, runnerID, err)
+		return
+	}
+	if err := model.FinishSystemTask(task.TaskID, runnerID, model.SystemTaskStatusSucceeded, result, ""); err != nil {
+		logSystemTaskLockError(ctx, task, err)
+	}
+}
+
+// RunCreditExpirySweep 分批处理所有已到期的额度批次
+func RunCreditExpirySweep(ctx context.Context) (*CreditExpiryResult, error) {
+	result := &CreditExpiryResult{}
+	for {
+		if ctx.Err() != nil {
+			return result, ctx.Err()
+		}
+		n, err := model.ExpireDueQuotaLots(creditExpiryBatchSize)
+		if err != nil {
+			return result, err
+		}
+		result.Expired += n
+		if n < creditExpiryBatchSize {
+			return result, nil
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/QuantumNous/new-api/model"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+func getQuotaLotRemaining(t *testing.T, userId int) int64 {
+	t.Helper()
+	var remaining int64
+	require.NoError(t, model.DB.Model(&model.QuotaLot{}).Where("user_id = ?", userId).
+		Select("COALESCE(sum(remaining), 0)").Scan(&remaining).Error)
+	return remaining
+}
+
+func TestWalletSettlementConsumesQuotaLotsForFullAmount(t *testing.T) {
+	truncate(t)
+	setting := operation_setting.GetCreditExpirySetting()
+	saved := *setting
+	setting.Enabled = true
+	t.Cleanup(func() { *setting = saved })
+
+	seedUser(t, 1, 1000)
+	require.NoError(t, model.GrantQuotaLotTx(model.DB, 1, model.QuotaLotSourceRedemption, "lot-1", 500, 30))
+
+	// 预扣与实际一致（差额为 0）时批次也按全额同步扣减
+	relayInfo := &relaycommon.RelayInfo{UserId: 1, IsPlayground: true, RequestId: "req-lot-1"}
+	c, _ := gin.CreateTestContext(nil)
+	session, apiErr := NewBillingSession(c, relayInfo, 300)
+	require.Nil(t, apiErr)
+	require.NoError(t, session.Settle(300))
+	require.EqualValues(t, 200, getQuotaLotRemaining(t, 1))
+	require.Equal(t, 700, getUserQuota(t, 1))
+
+	// 无 BillingSession 的旧路径：预扣部分同样计入批次消耗
+	relayInfo = &relaycommon.RelayInfo{UserId: 1, IsPlayground: true, RequestId: "req-lot-2", FinalPreConsumedQuota: 100}
+	require.NoError(t, PostConsumeQuota(relayInfo, 50, relayInfo.FinalPreConsumedQuota, false))
+	require.EqualValues(t, 50, getQuotaLotRemaining(t, 1))
+	require.Equal(t, 650, getUserQuota(t, 1))
+}
+
+func TestWalletSettlementPostsLedgerWithBalanceChange(t *testing.T) {
+	truncate(t)
+	seedUser(t, 1, 1000)
+
+	relayInfo := &relaycommon.RelayInfo{UserId: 1, IsPlayground: true, RequestId: "req-ledger-1"}
+	c, _ := gin.CreateTestContext(nil)
+	session, apiErr := NewBillingSession(c, relayInfo, 300)
+	require.Nil(t, apiErr)
+	require.NoError(t, session.Settle(250))
+	require.Equal(t, 750, getUserQuota(t, 1))
+
+	// 预扣与结算差额都随余额变更在同一事务内记账，账本余额与钱包一致
+	var posted int64
+	require.NoError(t, model.DB.Model(&model.QuotaLedgerEntry{}).
+		Where("account = ? AND ref_id = ?", model.QuotaLedgerUserAccount(1), "req-ledger-1").
+		Select("COALESCE(sum(amount), 0)").Scan(&posted).Error)
+	require.EqualValues(t, -250, posted)
+	account, err := model.GetQuotaLedgerAccount(model.QuotaLedgerUserAccount(1))
+	require.NoError(t, err)
+	require.EqualValues(t, 750, account.Balance)
+}
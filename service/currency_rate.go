@@ -0,0 +1,117 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+)
+
+const currencyRateFetchTimeout = 15 * time.Second
+
+// currencyRateHandler 按配置的间隔从汇率接口刷新非手动设置的货币汇率
+type currencyRateHandler struct{}
+
+func init() {
+	RegisterSystemTaskHandler(currencyRateHandler{})
+}
+
+func (currencyRateHandler) Type() string { return model.SystemTaskTypeCurrencyRate }
+
+func (currencyRateHandler) Enabled() bool {
+	currencySetting := operation_setting.GetCurrencySetting()
+	return currencySetting.Enabled && currencySetting.RateProviderURL != "" && currencySetting.RefreshIntervalMinutes > 0
+}
+
+func (currencyRateHandler) Interval() time.Duration {
+	return time.Duration(max(operation_setting.GetCurrencySetting().RefreshIntervalMinutes, 1)) * time.Minute
+}
+
+func (currencyRateHandler) NewPayload() any { return nil }
+
+type CurrencyRateRefreshResult struct {
+	Updated    int                              `json:"updated"`
+	Currencies []operation_setting.CurrencyRate `json:"currencies"`
+}
+
+func (currencyRateHandler) Run(ctx context.Context, task *model.SystemTask, runnerID string) {
+	result, err := RefreshCurrencyRates(ctx)
+	if err != nil {
+		failSystemTask(task, runnerID, err)
+		return
+	}
+	if err := model.FinishSystemTask(task.TaskID, runnerID, model.SystemTaskStatusSucceeded, result, ""); err != nil {
+		logSystemTaskLockError(ctx, task, err)
+	}
+}
+
+type currencyRateResponse struct {
+	Rates map[string]float64 `json:"rates"`
+}
+
+// RefreshCurrencyRates 从汇率接口拉取以 USD 为基准的汇率，更新非手动设置的货币并保存到配置
+func RefreshCurrencyRates(ctx context.Context) (*CurrencyRateRefreshResult, error) {
+	providerURL := strings.TrimSpace(operation_setting.GetCurrencySetting().RateProviderURL)
+	if providerURL == "" {
+		return nil, errors.New("未配置汇率接口地址")
+	}
+	rates, err := fetchCurrencyRates(ctx, providerURL)
+	if err != nil {
+		return nil, err
+	}
+	currencies, updated := operation_setting.MergeCurrencyRates(rates, common.GetTimestamp())
+	if updated > 0 {
+		data, err := common.Marshal(currencies)
+		if err != nil {
+			return nil, err
+		}
+		if err := model.UpdateOption("currency_setting.currencies", string(data)); err != nil {
+			return nil, err
+		}
+	}
+	return &CurrencyRateRefreshResult{Updated: updated, Currencies: operation_setting.GetEnabledCurrencies()}, nil
+}
+
+func fetchCurrencyRates(ctx context.Context, providerURL string) (map[string]float64, error) {
+	ctx, cancel := context.WithTimeout(ctx, currencyRateFetchTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, providerURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	client := GetSSRFProtectedHTTPClient()
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("汇率接口返回状态码 %d", resp.StatusCode)
+	}
+	var parsed currencyRateResponse
+	if err := common.Unmarshal(body, &parsed); err != nil {
+		return nil, fmt.Errorf("解析汇率接口响应失败: %w", err)
+	}
+	if len(parsed.Rates) == 0 {
+		return nil, errors.New("汇率接口未返回汇率")
+	}
+	rates := make(map[string]float64, len(parsed.Rates))
+	for code, rate := range parsed.Rates {
+		rates[strings.ToUpper(code)] = rate
+	}
+	return rates, nil
+}
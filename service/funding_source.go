@@ -7,12 +7,12 @@ import (
 )
 
 // ---------------------------------------------------------------------------
-// FundingSource — 资金来源接口（钱包 or 订阅）
+// FundingSource — 资金来源接口（钱包 / 订阅 / 组织钱包）
 // ---------------------------------------------------------------------------
 
 // FundingSource 抽象了预扣费的资金来源。
 type FundingSource interface {
-	// Source 返回资金来源标识："wallet" 或 "subscription"
+	// Source 返回资金来源标识："wallet"、"subscription" 或 "organization"
 	Source() string
 	// PreConsume 从该资金来源预扣 amount 额度
 	PreConsume(amount int) error
@@ -27,40 +27,37 @@ type FundingSource interface {
 // ---------------------------------------------------------------------------
 
 type WalletFunding struct {
-	userId   int
-	consumed int // 实际预扣的用户额度
+	userId    int
+	requestId string
+	consumed  int // 实际预扣的用户额度
 }
 
 func (w *WalletFunding) Source() string { return BillingSourceWallet }
 
+// PreConsume 预扣钱包额度，余额变更与账本分录在同一事务内写入；预扣不消耗额度批次，批次在结算时按全额消耗。
 func (w *WalletFunding) PreConsume(amount int) error {
 	if amount <= 0 {
 		return nil
 	}
-	if err := model.DecreaseUserQuota(w.userId, amount, false); err != nil {
+	if err := model.SettleUserWalletQuota(w.userId, amount, 0, model.QuotaLedgerRefRequestId, w.requestId); err != nil {
 		return err
 	}
 	w.consumed = amount
 	return nil
 }
 
+// Settle 调整差额，并在同一事务内按实际结算的全额（预扣 + 差额）消耗额度批次、记录账本。
 func (w *WalletFunding) Settle(delta int) error {
-	if delta == 0 {
-		return nil
-	}
-	if delta > 0 {
-		return model.DecreaseUserQuota(w.userId, delta, false)
-	}
-	return model.IncreaseUserQuota(w.userId, -delta, false)
+	return model.SettleUserWalletQuota(w.userId, delta, w.consumed+delta, model.QuotaLedgerRefRequestId, w.requestId)
 }
 
 func (w *WalletFunding) Refund() error {
 	if w.consumed <= 0 {
 		return nil
 	}
-	// IncreaseUserQuota 是 quota += N 的非幂等操作，不能重试，否则会多退额度。
+	// 退还是 quota += N 的非幂等操作，不能重试，否则会多退额度。
 	// 订阅的 RefundSubscriptionPreConsume 有 requestId 幂等保护所以可以重试。
-	return model.IncreaseUserQuota(w.userId, w.consumed, false)
+	return model.SettleUserWalletQuota(w.userId, -w.consumed, 0, model.QuotaLedgerRefRequestId, w.requestId)
 }
 
 // ---------------------------------------------------------------------------
@@ -68,29 +65,46 @@ func (w *WalletFunding) Refund() error {
 // ---------------------------------------------------------------------------
 
 type SubscriptionFunding struct {
-	requestId      string
-	userId         int
-	modelName      string
-	amount         int64 // 预扣的订阅额度（subConsume）
-	subscriptionId int
-	preConsumed    int64
+	requestId       string
+	userId          int
+	organizationId  int // 大于 0 时从组织订阅扣费，并计入成员已用额度
+	modelName       string
+	amount          int64 // 预扣的订阅额度（subConsume）
+	estimatedTokens int64 // 按 token 计量的额度桶预扣的估算 token 数
+	subscriptionId  int
+	preConsumed     int64
+	// 命中套餐额度桶时的桶 ID 与计量单位，tokensSettled 防止重复结算 token 用量
+	bucketId      int
+	bucketUnit    string
+	tokensSettled bool
 	// 以下字段在 PreConsume 成功后填充，供 RelayInfo 同步使用
 	AmountTotal     int64
 	AmountUsedAfter int64
 	PlanId          int
 	PlanTitle       string
+	BucketName      string
 }
 
 func (s *SubscriptionFunding) Source() string { return BillingSourceSubscription }
 
 func (s *SubscriptionFunding) PreConsume(_ int) error {
 	// amount 参数被忽略，使用内部 s.amount（已在构造时根据 preConsumedQuota 计算）
-	res, err := model.PreConsumeUserSubscription(s.requestId, s.userId, s.modelName, 0, s.amount)
+	res, err := model.PreConsumeUserSubscription(model.SubscriptionPreConsumeParams{
+		RequestId:       s.requestId,
+		UserId:          s.userId,
+		OrganizationId:  s.organizationId,
+		ModelName:       s.modelName,
+		Amount:          s.amount,
+		EstimatedTokens: s.estimatedTokens,
+	})
 	if err != nil {
 		return err
 	}
 	s.subscriptionId = res.UserSubscriptionId
 	s.preConsumed = res.PreConsumed
+	s.bucketId = res.BucketId
+	s.bucketUnit = res.BucketUnit
+	s.BucketName = res.BucketName
 	s.AmountTotal = res.AmountTotal
 	s.AmountUsedAfter = res.AmountUsedAfter
 	// 获取订阅计划信息
@@ -105,7 +119,31 @@ func (s *SubscriptionFunding) Settle(delta int) error {
 	if delta == 0 {
 		return nil
 	}
-	return model.PostConsumeUserSubscriptionDelta(s.subscriptionId, int64(delta))
+	if err := model.PostConsumeUserSubscriptionQuotaDelta(s.subscriptionId, s.bucketId, int64(delta), model.QuotaLedgerRefRequestId, s.requestId); err != nil {
+		return err
+	}
+	return s.settleMemberSpend(delta)
+}
+
+// settleMemberSpend 按差额调整组织订阅对应的成员已用额度，个人订阅不做处理。
+func (s *SubscriptionFunding) settleMemberSpend(delta int) error {
+	if s.organizationId <= 0 {
+		return nil
+	}
+	return model.SettleOrganizationMemberSpend(s.organizationId, s.userId, delta)
+}
+
+// SettleTokens 按实际 token 用量结算按 token 计量的额度桶，其他资金来源不做处理。
+// totalTokens 为 0 时上游没有返回用量，保留预扣的估算值。
+func (s *SubscriptionFunding) SettleTokens(totalTokens int) error {
+	if s.bucketId <= 0 || s.bucketUnit != model.SubscriptionBucketUnitTokens || s.tokensSettled || totalTokens <= 0 {
+		return nil
+	}
+	if err := model.PostConsumeUserSubscriptionBucketDelta(s.bucketId, model.SubscriptionBucketUnitTokens, int64(totalTokens)-s.preConsumed); err != nil {
+		return err
+	}
+	s.tokensSettled = true
+	return nil
 }
 
 func (s *SubscriptionFunding) Refund() error {
@@ -117,6 +155,42 @@ func (s *SubscriptionFunding) Refund() error {
 	})
 }
 
+// ---------------------------------------------------------------------------
+// OrganizationFunding — 组织钱包资金来源实现
+// ---------------------------------------------------------------------------
+
+// OrganizationFunding 从组织共享钱包扣费，同时计入成员的已用额度以执行成员消费上限。
+type OrganizationFunding struct {
+	organizationId int
+	userId         int
+	requestId      string
+	consumed       int // 实际预扣的组织额度
+}
+
+func (o *OrganizationFunding) Source() string { return BillingSourceOrganization }
+
+func (o *OrganizationFunding) PreConsume(amount int) error {
+	if err := model.PreConsumeOrganizationQuota(o.organizationId, o.userId, amount, model.QuotaLedgerRefRequestId, o.requestId); err != nil {
+		return err
+	}
+	o.consumed = amount
+	return nil
+}
+
+func (o *OrganizationFunding) Settle(delta int) error {
+	return model.SettleOrganizationQuota(o.organizationId, o.userId, delta, model.QuotaLedgerRefRequestId, o.requestId)
+}
+
+func (o *OrganizationFunding) Refund() error {
+	if o.consumed <= 0 {
+		return nil
+	}
+	// SettleOrganizationQuota 在事务中执行，失败时不会部分生效，可以重试。
+	return refundWithRetry(func() error {
+		return model.SettleOrganizationQuota(o.organizationId, o.userId, -o.consumed, model.QuotaLedgerRefRequestId, o.requestId)
+	})
+}
+
 // refundWithRetry 尝试多次执行退款操作以提高成功率，只能用于基于事务的退款函数！！！！！！
 // try to refund with retries, only for refund functions based on transactions!!!
 func refundWithRetry(fn func() error) error {
@@ -0,0 +1,243 @@
+package service
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"html/template"
+	"strings"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+
+	"github.com/bytedance/gopkg/util/gopool"
+)
+
+// IssueTopUpInvoice 为已完成的充值订单开具发票；发票功能关闭时不做任何事。
+func IssueTopUpInvoice(tradeNo string) (*model.Invoice, error) {
+	if !operation_setting.GetInvoiceSetting().Enabled {
+		return nil, nil
+	}
+	topUp := model.GetTopUpByTradeNo(tradeNo)
+	if topUp == nil {
+		return nil, errors.New("充值订单不存在")
+	}
+	if topUp.Status != common.TopUpStatusSuccess {
+		return nil, errors.New("充值订单未完成")
+	}
+	amount, currency := topUp.Money, topUp.Currency
+	// 按其他币种结账的订单按实收金额开票
+	if topUp.PayCurrency != "" {
+		amount, currency = topUp.PayMoney, topUp.PayCurrency
+	}
+	return issueInvoice(model.InvoiceIssueParams{
+		UserId:        topUp.UserId,
+		SourceType:    model.InvoiceSourceTopUp,
+		SourceId:      topUp.TradeNo,
+		Description:   fmt.Sprintf("Account top-up (%d)", topUp.Amount),
+		Amount:        amount,
+		PaymentMethod: topUp.PaymentMethod,
+		Currency:      currency,
+	})
+}
+
+// IssueSubscriptionOrderInvoice 为已完成的订阅订单开具发票。使用余额兑换的订阅不开票，
+// 对应的款项在充值时已经开过发票。
+func IssueSubscriptionOrderInvoice(tradeNo string) (*model.Invoice, error) {
+	if !operation_setting.GetInvoiceSetting().Enabled {
+		return nil, nil
+	}
+	order := model.GetSubscriptionOrderByTradeNo(tradeNo)
+	if order == nil {
+		return nil, errors.New("订阅订单不存在")
+	}
+	if order.Status != common.TopUpStatusSuccess {
+		return nil, errors.New("订阅订单未完成")
+	}
+	if order.PaymentMethod == model.PaymentMethodBalance {
+		return nil, nil
+	}
+	description := fmt.Sprintf("Subscription plan #%d", order.PlanId)
+	if plan, err := model.GetSubscriptionPlanById(order.PlanId); err == nil && plan != nil {
+		description = "Subscription: " + plan.Title
+	}
+	return issueInvoice(model.InvoiceIssueParams{
+		UserId:        order.UserId,
+		SourceType:    model.InvoiceSourceSubscriptionOrder,
+		SourceId:      order.TradeNo,
+		Description:   description,
+		Amount:        order.Money,
+		PaymentMethod: order.PaymentMethod,
+	})
+}
+
+// IssueTopUpInvoiceAsync 在后台开具充值发票，供支付回调在入账成功后调用。
+func IssueTopUpInvoiceAsync(tradeNo string) {
+	gopool.Go(func() {
+		if _, err := IssueTopUpInvoice(tradeNo); err != nil {
+			common.SysError(fmt.Sprintf("failed to issue invoice for top-up %s: %s", tradeNo, err.Error()))
+		}
+	})
+}
+
+// IssueSubscriptionOrderInvoiceAsync 在后台开具订阅订单发票。
+func IssueSubscriptionOrderInvoiceAsync(tradeNo string) {
+	gopool.Go(func() {
+		if _, err := IssueSubscriptionOrderInvoice(tradeNo); err != nil {
+			common.SysError(fmt.Sprintf("failed to issue invoice for subscription order %s: %s", tradeNo, err.Error()))
+		}
+	})
+}
+
+func issueInvoice(params model.InvoiceIssueParams) (*model.Invoice, error) {
+	invoice, created, err := model.IssueInvoice(params)
+	if err != nil {
+		return nil, err
+	}
+	if created && operation_setting.GetInvoiceSetting().EmailEnabled {
+		if err := SendInvoiceEmail(invoice); err != nil {
+			common.SysError(fmt.Sprintf("failed to email invoice %s: %s", invoice.InvoiceNo, err.Error()))
+		}
+	}
+	return invoice, nil
+}
+
+// SendInvoiceEmail 把发票 HTML 作为邮件正文发送到开票邮箱。
+func SendInvoiceEmail(invoice *model.Invoice) error {
+	if invoice.BillingEmail == "" {
+		return errors.New("no billing email")
+	}
+	content, err := RenderInvoiceHTML(invoice)
+	if err != nil {
+		return err
+	}
+	subject := fmt.Sprintf("%s 发票 %s", common.SystemName, invoice.InvoiceNo)
+	if err := common.SendEmail(subject, invoice.BillingEmail, content); err != nil {
+		return err
+	}
+	return model.MarkInvoiceEmailed(invoice.Id)
+}
+
+var invoiceHTMLTemplate = template.Must(template.New("invoice").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Invoice {{.InvoiceNo}}</title>
+<style>
+body { font-family: Helvetica, Arial, sans-serif; color: #222; max-width: 720px; margin: 24px auto; }
+h1 { font-size: 24px; margin-bottom: 4px; }
+table { width: 100%; border-collapse: collapse; margin-top: 24px; }
+th, td { text-align: left; padding: 8px; border-bottom: 1px solid #ddd; }
+.right { text-align: right; }
+.parties { display: flex; justify-content: space-between; margin-top: 24px; }
+.muted { color: #666; font-size: 12px; }
+pre { font-family: inherit; margin: 0; white-space: pre-wrap; }
+</style>
+</head>
+<body>
+<h1>Invoice</h1>
+<div class="muted">No. {{.InvoiceNo}} &middot; Issued {{.IssuedDate}}</div>
+<div class="parties">
+<div>
+<strong>From</strong><br>
+{{.SellerName}}<br>
+<pre>{{.SellerAddress}}</pre>
+{{if .SellerTaxId}}Tax ID: {{.SellerTaxId}}{{end}}
+</div>
+<div>
+<strong>Bill to</strong><br>
+{{.BillingName}}<br>
+<pre>{{.BillingAddress}}</pre>
+{{if .BillingTaxId}}Tax ID: {{.BillingTaxId}}<br>{{end}}
+{{.BillingEmail}}
+</div>
+</div>
+<table>
+<tr><th>Description</th>{{if .Period}}<th>Period</th>{{end}}<th class="right">Amount</th></tr>
+<tr><td>{{.Description}}</td>{{if .Period}}<td>{{.Period}}</td>{{end}}<td class="right">{{.AmountText}}</td></tr>
+<tr><td><strong>Total</strong></td>{{if .Period}}<td></td>{{end}}<td class="right"><strong>{{.AmountText}}</strong></td></tr>
+</table>
+<p class="muted">Reference: {{.SourceId}}{{if .PaymentMethod}} &middot; Paid via {{.PaymentMethod}}{{end}}</p>
+{{if .Footer}}<p class="muted"><pre>{{.Footer}}</pre></p>{{end}}
+</body>
+</html>
+`))
+
+type invoiceView struct {
+	*model.Invoice
+	IssuedDate string
+	Period     string
+	AmountText string
+}
+
+func newInvoiceView(invoice *model.Invoice) invoiceView {
+	view := invoiceView{
+		Invoice:    invoice,
+		IssuedDate: time.Unix(invoice.IssuedAt, 0).Format(time.DateOnly),
+		AmountText: invoiceAmountText(invoice.Amount, invoice.Currency),
+	}
+	if invoice.PeriodStart > 0 && invoice.PeriodEnd > 0 {
+		view.Period = time.Unix(invoice.PeriodStart, 0).Format(time.DateOnly) + " - " + time.Unix(invoice.PeriodEnd, 0).Format(time.DateOnly)
+	}
+	return view
+}
+
+// invoiceAmountText 按币种的小数位格式化金额，未配置的币种保留两位小数
+func invoiceAmountText(amount float64, currency string) string {
+	decimals := 2
+	if rate, ok := operation_setting.GetCurrencyRate(currency); ok {
+		decimals = rate.Decimals
+	}
+	return strings.TrimSpace(fmt.Sprintf("%.*f %s", decimals, amount, currency))
+}
+
+func RenderInvoiceHTML(invoice *model.Invoice) (string, error) {
+	var buf bytes.Buffer
+	if err := invoiceHTMLTemplate.Execute(&buf, newInvoiceView(invoice)); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// RenderInvoicePDF 生成单页 PDF。PDF 使用内置的 Helvetica 字体，只能显示 Latin-1 字符，
+// 其余字符会被替换为 "?"；需要完整字符集时请使用 HTML 版本。
+func RenderInvoicePDF(invoice *model.Invoice) []byte {
+	view := newInvoiceView(invoice)
+	doc := newSimplePDF()
+	doc.text(18, "Invoice")
+	doc.text(10, fmt.Sprintf("No. %s    Issued %s", invoice.InvoiceNo, view.IssuedDate))
+	doc.gap()
+	doc.text(11, "From")
+	doc.lines(10, invoice.SellerName, invoice.SellerAddress)
+	if invoice.SellerTaxId != "" {
+		doc.text(10, "Tax ID: "+invoice.SellerTaxId)
+	}
+	doc.gap()
+	doc.text(11, "Bill to")
+	doc.lines(10, invoice.BillingName, invoice.BillingAddress)
+	if invoice.BillingTaxId != "" {
+		doc.text(10, "Tax ID: "+invoice.BillingTaxId)
+	}
+	if invoice.BillingEmail != "" {
+		doc.text(10, invoice.BillingEmail)
+	}
+	doc.gap()
+	doc.text(11, "Description: "+invoice.Description)
+	if view.Period != "" {
+		doc.text(10, "Period: "+view.Period)
+	}
+	doc.text(12, "Total: "+view.AmountText)
+	doc.gap()
+	reference := "Reference: " + invoice.SourceId
+	if invoice.PaymentMethod != "" {
+		reference += "    Paid via " + invoice.PaymentMethod
+	}
+	doc.text(9, reference)
+	if invoice.Footer != "" {
+		doc.gap()
+		doc.lines(9, invoice.Footer)
+	}
+	return doc.bytes()
+}
@@ -0,0 +1,94 @@
+package service
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// simplePDF 是一个只支持单页纯文本的最小 PDF 生成器，用于发票下载，避免引入额外依赖。
+type simplePDF struct {
+	content bytes.Buffer
+	y       float64
+}
+
+const (
+	simplePDFPageWidth  = 595.0 // A4, 单位 pt
+	simplePDFPageHeight = 842.0
+	simplePDFMargin     = 56.0
+)
+
+func newSimplePDF() *simplePDF {
+	return &simplePDF{y: simplePDFPageHeight - simplePDFMargin}
+}
+
+func (p *simplePDF) text(size float64, s string) {
+	if p.y < simplePDFMargin {
+		return
+	}
+	p.y -= size * 1.4
+	fmt.Fprintf(&p.content, "BT /F1 %.0f Tf %.0f %.1f Td (%s) Tj ET\n", size, simplePDFMargin, p.y, escapePDFText(s))
+}
+
+// lines 逐行输出多段文本，段内换行会被拆成多行，空段落被跳过。
+func (p *simplePDF) lines(size float64, parts ...string) {
+	for _, part := range parts {
+		for _, line := range strings.Split(part, "\n") {
+			line = strings.TrimSpace(line)
+			if line != "" {
+				p.text(size, line)
+			}
+		}
+	}
+}
+
+func (p *simplePDF) gap() {
+	p.y -= 10
+}
+
+func (p *simplePDF) bytes() []byte {
+	stream := p.content.String()
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.0f %.0f] /Resources << /Font << /F1 4 0 R >> >> /Contents 5 0 R >>", simplePDFPageWidth, simplePDFPageHeight),
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>",
+		fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", len(stream), stream),
+	}
+	var out bytes.Buffer
+	out.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, obj := range objects {
+		offsets[i] = out.Len()
+		fmt.Fprintf(&out, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+	return out.Bytes()
+}
+
+// escapePDFText 转义 PDF 字符串中的特殊字符，并把 Latin-1 以外的字符替换为 "?"。
+func escapePDFText(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r == '\t':
+			b.WriteByte(' ')
+		case r < 0x20:
+		case r < 0x80:
+			b.WriteRune(r)
+		case r <= 0xFF:
+			fmt.Fprintf(&b, "\\%03o", r)
+		default:
+			b.WriteByte('?')
+		}
+	}
+	return b.String()
+}
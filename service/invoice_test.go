@@ -0,0 +1,51 @@
+package service
+
+import (
+	"bytes"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/QuantumNous/new-api/model"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenderInvoicePDFHasValidXref(t *testing.T) {
+	pdf := RenderInvoicePDF(&model.Invoice{
+		InvoiceNo:   "INV-2026-000001",
+		Description: "Account top-up (10)",
+		Amount:      10,
+		Currency:    "USD",
+		BillingName: "Acme (China) 有限公司",
+		SourceId:    "trade-1",
+	})
+	require.True(t, bytes.HasPrefix(pdf, []byte("%PDF-1.4")))
+	require.Contains(t, string(pdf), `(Acme \(China\) ????) Tj`)
+
+	startxref := regexp.MustCompile(`startxref\n(\d+)\n`).FindSubmatch(pdf)
+	require.NotNil(t, startxref)
+	offset, err := strconv.Atoi(string(startxref[1]))
+	require.NoError(t, err)
+	require.True(t, bytes.HasPrefix(pdf[offset:], []byte("xref\n")))
+
+	entries := regexp.MustCompile(`(\d{10}) 00000 n `).FindAllSubmatch(pdf, -1)
+	require.Len(t, entries, 5)
+	for i, entry := range entries {
+		objOffset, err := strconv.Atoi(string(entry[1]))
+		require.NoError(t, err)
+		require.True(t, bytes.HasPrefix(pdf[objOffset:], []byte(strconv.Itoa(i+1)+" 0 obj")))
+	}
+}
+
+func TestRenderInvoiceHTMLEscapesProfileFields(t *testing.T) {
+	html, err := RenderInvoiceHTML(&model.Invoice{
+		InvoiceNo:   "INV-2026-000002",
+		BillingName: "<script>alert(1)</script>",
+		Amount:      12.5,
+		Currency:    "USD",
+	})
+	require.NoError(t, err)
+	require.False(t, strings.Contains(html, "<script>"))
+	require.Contains(t, html, "12.50 USD")
+}
@@ -9,6 +9,7 @@ import (
 	"github.com/QuantumNous/new-api/constant"
 	"github.com/QuantumNous/new-api/dto"
 	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/model"
 	"github.com/QuantumNous/new-api/pkg/billingexpr"
 	relaycommon "github.com/QuantumNous/new-api/relay/common"
 	"github.com/QuantumNous/new-api/types"
@@ -179,6 +180,11 @@ func appendBillingInfo(relayInfo *relaycommon.RelayInfo, other map[string]interf
 		if relayInfo.SubscriptionPlanTitle != "" {
 			other["subscription_plan_title"] = relayInfo.SubscriptionPlanTitle
 		}
+		if relayInfo.SubscriptionBucketId != 0 {
+			other["subscription_bucket_id"] = relayInfo.SubscriptionBucketId
+			other["subscription_bucket_name"] = relayInfo.SubscriptionBucketName
+			other["subscription_bucket_unit"] = relayInfo.SubscriptionBucketUnit
+		}
 		// Compute "this request" subscription consumed + remaining
 		consumed := relayInfo.SubscriptionPreConsumed + relayInfo.SubscriptionPostDelta
 		usedFinal := relayInfo.SubscriptionAmountUsedAfterPreConsume + relayInfo.SubscriptionPostDelta
@@ -197,7 +203,8 @@ func appendBillingInfo(relayInfo *relaycommon.RelayInfo, other map[string]interf
 			other["subscription_used"] = usedFinal
 			other["subscription_remain"] = remain
 		}
-		if consumed > 0 {
+		// 按次或按 token 计量的额度桶不以额度计算本次消耗
+		if consumed > 0 && (relayInfo.SubscriptionBucketId == 0 || relayInfo.SubscriptionBucketUnit == model.SubscriptionBucketUnitQuota) {
 			other["subscription_consumed"] = consumed
 		}
 		// Wallet quota is not deducted when billed from subscription.
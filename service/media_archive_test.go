@@ -0,0 +1,179 @@
+package service
+
+import (
+	"context"
+	"encoding/base64"
+	"io"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func useLocalMediaArchive(t *testing.T) *operation_setting.MediaArchiveSetting {
+	t.Helper()
+	setting := operation_setting.GetMediaArchiveSetting()
+	saved := *setting
+	t.Cleanup(func() { *setting = saved })
+	setting.Enabled = true
+	setting.Backend = operation_setting.MediaArchiveBackendLocal
+	setting.LocalDir = t.TempDir()
+	setting.RetentionDays = 30
+	setting.PricePerGBDay = 0
+	return setting
+}
+
+func TestArchiveTaskMediaServesSignedURL(t *testing.T) {
+	truncate(t)
+	useLocalMediaArchive(t)
+
+	payload := []byte("fake mp4 bytes")
+	task := &model.Task{TaskID: "task_archive_1", UserId: 1, Status: model.TaskStatusSuccess}
+	task.PrivateData.ResultURL = "data:video/mp4;base64," + base64.StdEncoding.EncodeToString(payload)
+
+	obj, err := ArchiveTaskMedia(context.Background(), task)
+	require.NoError(t, err)
+	require.NotNil(t, obj)
+	assert.Equal(t, "video/mp4", obj.ContentType)
+	assert.EqualValues(t, len(payload), obj.Size)
+
+	// 重复归档复用已有记录
+	again, err := ArchiveTaskMedia(context.Background(), task)
+	require.NoError(t, err)
+	assert.Equal(t, obj.Id, again.Id)
+
+	signed := TaskResultURL(task)
+	require.Contains(t, signed, "/api/media/")
+	query := signed[strings.Index(signed, "?")+1:]
+	var expires int64
+	var signature string
+	for _, kv := range strings.Split(query, "&") {
+		k, v, _ := strings.Cut(kv, "=")
+		switch k {
+		case "expires":
+			expires, _ = strconv.ParseInt(v, 10, 64)
+		case "signature":
+			signature = v
+		}
+	}
+	assert.True(t, VerifyMediaSignature(obj.Id, expires, signature))
+	assert.False(t, VerifyMediaSignature(obj.Id+1, expires, signature))
+	assert.False(t, VerifyMediaSignature(obj.Id, expires+1, signature))
+}
+
+func TestMediaRetentionSweepBillsAndDeletes(t *testing.T) {
+	truncate(t)
+	setting := useLocalMediaArchive(t)
+	setting.PricePerGBDay = 1
+	seedUser(t, 1, int(100*common.QuotaPerUnit))
+
+	task := &model.Task{TaskID: "task_archive_2", UserId: 1, Status: model.TaskStatusSuccess}
+	task.PrivateData.ResultURL = "data:image/png;base64," + base64.StdEncoding.EncodeToString([]byte("png"))
+	obj, err := ArchiveTaskMedia(context.Background(), task)
+	require.NoError(t, err)
+
+	// 模拟 1 GB 的对象已存放两天且已过保留期
+	now := common.GetTimestamp()
+	require.NoError(t, model.DB.Model(&model.MediaObject{}).Where("id = ?", obj.Id).Updates(map[string]any{
+		"size":       int64(1 << 30),
+		"billed_at":  now - 2*24*3600 - 60,
+		"expires_at": now - 1,
+	}).Error)
+
+	result, err := RunMediaRetentionSweep(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1, result.Billed)
+	assert.EqualValues(t, 2*common.QuotaPerUnit, result.Charged)
+	assert.Equal(t, 1, result.Deleted)
+
+	quota, err := model.GetUserQuota(1, true)
+	require.NoError(t, err)
+	assert.Equal(t, int(98*common.QuotaPerUnit), quota)
+	assert.Nil(t, GetArchivedMedia(model.MediaSourceTask, task.TaskID))
+}
+
+func TestArchiveMediaConflictKeepsWinnerObject(t *testing.T) {
+	truncate(t)
+	useLocalMediaArchive(t)
+
+	task := &model.Task{TaskID: "task_archive_3", UserId: 1, Status: model.TaskStatusSuccess}
+	task.PrivateData.ResultURL = "data:video/mp4;base64," + base64.StdEncoding.EncodeToString([]byte("winner"))
+	winner, err := ArchiveTaskMedia(context.Background(), task)
+	require.NoError(t, err)
+	require.NotNil(t, winner)
+
+	// 模拟并发归档的失败方：同一来源上传了自己的对象后写入记录冲突
+	store, backend, err := getMediaStore()
+	require.NoError(t, err)
+	loserKey := "task/loser.mp4"
+	require.NoError(t, store.Put(context.Background(), loserKey, strings.NewReader("loser"), 5, "video/mp4"))
+	loser := &model.MediaObject{
+		Source:     model.MediaSourceTask,
+		SourceId:   task.TaskID,
+		UserId:     1,
+		Backend:    backend,
+		StorageKey: loserKey,
+		CreatedAt:  common.GetTimestamp(),
+	}
+	assert.NotEqual(t, winner.StorageKey, loserKey)
+
+	got, err := saveArchivedMedia(context.Background(), store, loser)
+	require.NoError(t, err)
+	assert.Equal(t, winner.Id, got.Id)
+
+	reader, err := store.Get(context.Background(), winner.StorageKey)
+	require.NoError(t, err)
+	body, err := io.ReadAll(reader)
+	reader.Close()
+	require.NoError(t, err)
+	assert.Equal(t, "winner", string(body))
+	_, err = store.Get(context.Background(), loserKey)
+	assert.Error(t, err)
+}
+
+func TestSafeMediaContentType(t *testing.T) {
+	assert.Equal(t, "video/mp4", safeMediaContentType("video/mp4"))
+	assert.Equal(t, "image/png", safeMediaContentType("image/png"))
+	assert.Equal(t, "audio/mpeg", safeMediaContentType("audio/mpeg"))
+	assert.Equal(t, "application/octet-stream", safeMediaContentType("text/html; charset=utf-8"))
+	assert.Equal(t, "application/octet-stream", safeMediaContentType("image/svg+xml"))
+	assert.Equal(t, "application/octet-stream", safeMediaContentType(""))
+}
+
+func TestMediaRetentionSweepSkipsOtherBackendObjects(t *testing.T) {
+	truncate(t)
+	useLocalMediaArchive(t)
+
+	// 切换后端前留下的一整批过期记录不应挡住当前后端的清理
+	now := common.GetTimestamp()
+	for i := 0; i < mediaRetentionBatchSize; i++ {
+		require.NoError(t, model.DB.Create(&model.MediaObject{
+			Source:     model.MediaSourceTask,
+			SourceId:   "old_s3_" + strconv.Itoa(i),
+			Backend:    operation_setting.MediaArchiveBackendS3,
+			StorageKey: "task/old_" + strconv.Itoa(i),
+			CreatedAt:  now - 100,
+			ExpiresAt:  now - 100,
+			BilledAt:   now,
+		}).Error)
+	}
+	task := &model.Task{TaskID: "task_archive_4", UserId: 1, Status: model.TaskStatusSuccess}
+	task.PrivateData.ResultURL = "data:image/png;base64," + base64.StdEncoding.EncodeToString([]byte("png"))
+	obj, err := ArchiveTaskMedia(context.Background(), task)
+	require.NoError(t, err)
+	require.NoError(t, model.DB.Model(&model.MediaObject{}).Where("id = ?", obj.Id).Update("expires_at", now-1).Error)
+
+	result, err := RunMediaRetentionSweep(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1, result.Deleted)
+	assert.Nil(t, GetArchivedMedia(model.MediaSourceTask, task.TaskID))
+
+	var remaining int64
+	require.NoError(t, model.DB.Model(&model.MediaObject{}).Count(&remaining).Error)
+	assert.EqualValues(t, mediaRetentionBatchSize, remaining)
+}
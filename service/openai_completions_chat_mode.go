@@ -0,0 +1,14 @@
+package service
+
+import (
+	"github.com/QuantumNous/new-api/service/relayconvert"
+	"github.com/QuantumNous/new-api/setting/model_setting"
+)
+
+func ShouldCompletionsUseChatPolicy(policy model_setting.CompletionsToChatPolicy, channelID int, channelType int, apiType int, model string) bool {
+	return relayconvert.ShouldCompletionsUseChatPolicy(policy, channelID, channelType, apiType, model)
+}
+
+func ShouldCompletionsUseChatGlobal(channelID int, channelType int, apiType int, model string) bool {
+	return relayconvert.ShouldCompletionsUseChatGlobal(channelID, channelType, apiType, model)
+}
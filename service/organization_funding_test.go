@@ -0,0 +1,128 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/QuantumNous/new-api/model"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/types"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+func seedOrganization(t *testing.T, ownerId int, quota int, spendLimit int) *model.Organization {
+	t.Helper()
+	require.NoError(t, model.DB.AutoMigrate(&model.Organization{}, &model.OrganizationMember{}))
+	t.Cleanup(func() {
+		model.DB.Exec("DELETE FROM organizations")
+		model.DB.Exec("DELETE FROM organization_members")
+		model.DB.Exec("DELETE FROM quota_ledger_entries")
+		model.DB.Exec("DELETE FROM quota_ledger_accounts")
+	})
+	org, err := model.CreateOrganization("acme", ownerId)
+	require.NoError(t, err)
+	require.NoError(t, model.AdjustOrganizationQuota(org.Id, quota, 0))
+	require.NoError(t, model.UpdateOrganizationMember(org.Id, ownerId, "", &spendLimit, false))
+	return org
+}
+
+func TestBillingSessionDrawsFromOrganizationWallet(t *testing.T) {
+	truncate(t)
+	seedUser(t, 1, 0)
+	org := seedOrganization(t, 1, 1000, 0)
+
+	relayInfo := &relaycommon.RelayInfo{
+		UserId:         1,
+		IsPlayground:   true,
+		OrganizationId: org.Id,
+		RequestId:      "req-org",
+	}
+	c, _ := gin.CreateTestContext(nil)
+	session, apiErr := NewBillingSession(c, relayInfo, 300)
+	require.Nil(t, apiErr)
+	require.Equal(t, BillingSourceOrganization, relayInfo.BillingSource)
+
+	require.NoError(t, session.Settle(200))
+	stored, err := model.GetOrganizationById(org.Id)
+	require.NoError(t, err)
+	require.Equal(t, 800, stored.Quota)
+	require.Equal(t, 200, stored.UsedQuota)
+	// 个人钱包不受影响
+	require.Equal(t, 0, getUserQuota(t, 1))
+}
+
+func TestBillingSessionRejectsOrganizationMemberOverLimit(t *testing.T) {
+	truncate(t)
+	seedUser(t, 1, 0)
+	org := seedOrganization(t, 1, 1000, 100)
+
+	relayInfo := &relaycommon.RelayInfo{
+		UserId:         1,
+		IsPlayground:   true,
+		OrganizationId: org.Id,
+		RequestId:      "req-org-limit",
+	}
+	c, _ := gin.CreateTestContext(nil)
+	_, apiErr := NewBillingSession(c, relayInfo, 300)
+	require.NotNil(t, apiErr)
+	require.Equal(t, types.ErrorCodeInsufficientUserQuota, apiErr.GetErrorCode())
+	stored, err := model.GetOrganizationById(org.Id)
+	require.NoError(t, err)
+	require.Equal(t, 1000, stored.Quota)
+}
+
+func TestRefundTaskQuota_Organization(t *testing.T) {
+	truncate(t)
+	seedUser(t, 1, 0)
+	org := seedOrganization(t, 1, 1000, 0)
+	require.NoError(t, model.PreConsumeOrganizationQuota(org.Id, 1, 400, "", ""))
+
+	task := makeTask(1, 1, 400, 0, BillingSourceOrganization, 0)
+	task.PrivateData.OrganizationId = org.Id
+	require.NoError(t, model.DB.Create(task).Error)
+
+	require.True(t, RefundTaskQuota(t.Context(), task, "failed"))
+	stored, err := model.GetOrganizationById(org.Id)
+	require.NoError(t, err)
+	require.Equal(t, 1000, stored.Quota)
+	require.Equal(t, 0, getUserQuota(t, 1))
+}
+
+func TestBillingSessionDrawsFromOrganizationSubscriptionBeforeWallet(t *testing.T) {
+	truncate(t)
+	seedUser(t, 1, 0)
+	org := seedOrganization(t, 1, 1000, 0)
+	plan := &model.SubscriptionPlan{Id: 601, Title: "Team", DurationUnit: model.SubscriptionDurationMonth, DurationValue: 1, TotalAmount: 500}
+	require.NoError(t, model.DB.Create(plan).Error)
+	model.InvalidateSubscriptionPlanCache(plan.Id)
+	t.Cleanup(func() { model.InvalidateSubscriptionPlanCache(plan.Id) })
+	sub := &model.UserSubscription{UserId: 1, OrganizationId: org.Id, PlanId: plan.Id, AmountTotal: 500, StartTime: time.Now().Unix(), EndTime: time.Now().Add(time.Hour).Unix(), Status: "active", AllowWalletOverflow: true}
+	require.NoError(t, model.DB.Create(sub).Error)
+
+	c, _ := gin.CreateTestContext(nil)
+	relayInfo := &relaycommon.RelayInfo{UserId: 1, IsPlayground: true, OrganizationId: org.Id, RequestId: "req-org-sub"}
+	session, apiErr := NewBillingSession(c, relayInfo, 300)
+	require.Nil(t, apiErr)
+	require.Equal(t, BillingSourceSubscription, relayInfo.BillingSource)
+	require.Equal(t, sub.Id, relayInfo.SubscriptionId)
+	require.NoError(t, session.Settle(400))
+
+	require.NoError(t, model.DB.First(sub, sub.Id).Error)
+	require.EqualValues(t, 400, sub.AmountUsed)
+	member, err := model.GetOrganizationMember(org.Id, 1)
+	require.NoError(t, err)
+	require.Equal(t, 400, member.SpendUsed)
+	stored, err := model.GetOrganizationById(org.Id)
+	require.NoError(t, err)
+	require.Equal(t, 1000, stored.Quota)
+
+	// 订阅剩余额度不足时回退到组织钱包
+	relayInfo = &relaycommon.RelayInfo{UserId: 1, IsPlayground: true, OrganizationId: org.Id, RequestId: "req-org-sub-overflow"}
+	_, apiErr = NewBillingSession(c, relayInfo, 300)
+	require.Nil(t, apiErr)
+	require.Equal(t, BillingSourceOrganization, relayInfo.BillingSource)
+	stored, err = model.GetOrganizationById(org.Id)
+	require.NoError(t, err)
+	require.Equal(t, 700, stored.Quota)
+}
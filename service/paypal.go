@@ -0,0 +1,348 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/setting"
+)
+
+const (
+	payPalLiveAPIBase    = "https://api-m.paypal.com"
+	payPalSandboxAPIBase = "https://api-m.sandbox.paypal.com"
+)
+
+var payPalHTTPClient = &http.Client{Timeout: 30 * time.Second}
+
+// payPalResourceIdPattern 匹配 PayPal 订单号和扣款号，拼接 API 路径前必须校验
+var payPalResourceIdPattern = regexp.MustCompile(`^[A-Z0-9]+$`)
+
+// IsValidPayPalResourceId 判断订单号或扣款号是否为 PayPal 生成的格式
+func IsValidPayPalResourceId(id string) bool {
+	return payPalResourceIdPattern.MatchString(id)
+}
+
+// payPalToken 缓存 client_credentials 换取的访问令牌，凭据或环境变化后重新获取
+var payPalToken struct {
+	sync.Mutex
+	cacheKey  string
+	value     string
+	expiresAt time.Time
+}
+
+// PayPalMoney 为 PayPal API 的金额结构，Value 为十进制字符串
+type PayPalMoney struct {
+	CurrencyCode string `json:"currency_code"`
+	Value        string `json:"value"`
+}
+
+type PayPalLink struct {
+	Href   string `json:"href"`
+	Rel    string `json:"rel"`
+	Method string `json:"method"`
+}
+
+// PayPalCapture 对应 /v2/payments/captures 资源，CustomId 为创建订单时写入的本地 trade_no
+type PayPalCapture struct {
+	Id       string       `json:"id"`
+	Status   string       `json:"status"`
+	Amount   PayPalMoney  `json:"amount"`
+	CustomId string       `json:"custom_id"`
+	Links    []PayPalLink `json:"links"`
+}
+
+// PayPalRefund 对应 /v2/payments/refunds 资源
+type PayPalRefund struct {
+	Id       string       `json:"id"`
+	Status   string       `json:"status"`
+	Amount   PayPalMoney  `json:"amount"`
+	CustomId string       `json:"custom_id"`
+	Links    []PayPalLink `json:"links"`
+}
+
+type PayPalPurchaseUnit struct {
+	ReferenceId string `json:"reference_id,omitempty"`
+	CustomId    string `json:"custom_id,omitempty"`
+	Payments    struct {
+		Captures []PayPalCapture `json:"captures"`
+	} `json:"payments"`
+}
+
+// PayPalOrder 对应 /v2/checkout/orders 资源
+type PayPalOrder struct {
+	Id            string               `json:"id"`
+	Status        string               `json:"status"`
+	PurchaseUnits []PayPalPurchaseUnit `json:"purchase_units"`
+	Links         []PayPalLink         `json:"links"`
+}
+
+// ApproveURL 返回买家确认支付的跳转地址
+func (o *PayPalOrder) ApproveURL() string {
+	for _, link := range o.Links {
+		if link.Rel == "approve" || link.Rel == "payer-action" {
+			return link.Href
+		}
+	}
+	return ""
+}
+
+// CustomId 返回订单第一个购买单元的 custom_id
+func (o *PayPalOrder) CustomId() string {
+	if len(o.PurchaseUnits) == 0 {
+		return ""
+	}
+	return o.PurchaseUnits[0].CustomId
+}
+
+// CompletedCapture 返回订单中第一笔已完成的扣款
+func (o *PayPalOrder) CompletedCapture() *PayPalCapture {
+	for _, unit := range o.PurchaseUnits {
+		for i := range unit.Payments.Captures {
+			if unit.Payments.Captures[i].Status == "COMPLETED" {
+				return &unit.Payments.Captures[i]
+			}
+		}
+	}
+	return nil
+}
+
+// PayPalCreateOrderParams 为创建一次性扣款订单的参数，TradeNo 同时作为 custom_id 回传到扣款和退款资源
+type PayPalCreateOrderParams struct {
+	TradeNo     string
+	Description string
+	Amount      string
+	Currency    string
+	ReturnURL   string
+	CancelURL   string
+}
+
+// PayPalWebhookEvent 为 PayPal webhook 事件，Resource 的结构取决于 EventType
+type PayPalWebhookEvent struct {
+	Id           string          `json:"id"`
+	EventType    string          `json:"event_type"`
+	ResourceType string          `json:"resource_type"`
+	Resource     json.RawMessage `json:"resource"`
+}
+
+// PayPalWebhookHeaders 为 PayPal webhook 验签所需的请求头
+type PayPalWebhookHeaders struct {
+	AuthAlgo         string
+	CertURL          string
+	TransmissionId   string
+	TransmissionSig  string
+	TransmissionTime string
+}
+
+func PayPalAPIBase() string {
+	if setting.PayPalSandbox {
+		return payPalSandboxAPIBase
+	}
+	return payPalLiveAPIBase
+}
+
+func getPayPalAccessToken(ctx context.Context) (string, error) {
+	if setting.PayPalClientId == "" || setting.PayPalClientSecret == "" {
+		return "", errors.New("未配置 PayPal Client ID 或 Secret")
+	}
+	cacheKey := PayPalAPIBase() + "|" + setting.PayPalClientId + "|" + common.Sha1([]byte(setting.PayPalClientSecret))
+
+	payPalToken.Lock()
+	defer payPalToken.Unlock()
+	if payPalToken.cacheKey == cacheKey && payPalToken.value != "" && time.Now().Before(payPalToken.expiresAt) {
+		return payPalToken.value, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, PayPalAPIBase()+"/v1/oauth2/token", strings.NewReader("grant_type=client_credentials"))
+	if err != nil {
+		return "", err
+	}
+	req.SetBasicAuth(setting.PayPalClientId, setting.PayPalClientSecret)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	var resp struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int64  `json:"expires_in"`
+	}
+	if err := doPayPalRequest(req, &resp); err != nil {
+		return "", err
+	}
+	if resp.AccessToken == "" {
+		return "", errors.New("PayPal 未返回访问令牌")
+	}
+	payPalToken.cacheKey = cacheKey
+	payPalToken.value = resp.AccessToken
+	// 提前一分钟过期，避免请求途中令牌失效
+	payPalToken.expiresAt = time.Now().Add(time.Duration(resp.ExpiresIn)*time.Second - time.Minute)
+	return payPalToken.value, nil
+}
+
+func doPayPalRequest(req *http.Request, out any) error {
+	resp, err := payPalHTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("PayPal 请求失败: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("读取 PayPal 响应失败: %w", err)
+	}
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("PayPal API http status %d: %s", resp.StatusCode, string(body))
+	}
+	if out == nil || len(body) == 0 {
+		return nil
+	}
+	return common.Unmarshal(body, out)
+}
+
+func callPayPalAPI(ctx context.Context, method string, path string, payload any, out any) error {
+	token, err := getPayPalAccessToken(ctx)
+	if err != nil {
+		return err
+	}
+	var body io.Reader
+	if payload != nil {
+		data, err := common.Marshal(payload)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, PayPalAPIBase()+path, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+	return doPayPalRequest(req, out)
+}
+
+// CreatePayPalOrder 创建 intent=CAPTURE 的 PayPal 订单，买家确认后需调用 CapturePayPalOrder 完成扣款
+func CreatePayPalOrder(ctx context.Context, params PayPalCreateOrderParams) (*PayPalOrder, error) {
+	payload := map[string]any{
+		"intent": "CAPTURE",
+		"purchase_units": []map[string]any{
+			{
+				"reference_id": params.TradeNo,
+				"custom_id":    params.TradeNo,
+				"description":  params.Description,
+				"amount": PayPalMoney{
+					CurrencyCode: strings.ToUpper(params.Currency),
+					Value:        params.Amount,
+				},
+			},
+		},
+		"payment_source": map[string]any{
+			"paypal": map[string]any{
+				"experience_context": map[string]any{
+					"user_action":         "PAY_NOW",
+					"shipping_preference": "NO_SHIPPING",
+					"return_url":          params.ReturnURL,
+					"cancel_url":          params.CancelURL,
+				},
+			},
+		},
+	}
+	var order PayPalOrder
+	if err := callPayPalAPI(ctx, http.MethodPost, "/v2/checkout/orders", payload, &order); err != nil {
+		return nil, err
+	}
+	return &order, nil
+}
+
+// CapturePayPalOrder 对买家已确认的订单扣款，订单已扣款时返回订单当前状态
+func CapturePayPalOrder(ctx context.Context, orderId string) (*PayPalOrder, error) {
+	if !IsValidPayPalResourceId(orderId) {
+		return nil, fmt.Errorf("无效的 PayPal 订单号 %q", orderId)
+	}
+	var order PayPalOrder
+	err := callPayPalAPI(ctx, http.MethodPost, "/v2/checkout/orders/"+orderId+"/capture", map[string]any{}, &order)
+	if err != nil && strings.Contains(err.Error(), "ORDER_ALREADY_CAPTURED") {
+		return GetPayPalOrder(ctx, orderId)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &order, nil
+}
+
+func GetPayPalOrder(ctx context.Context, orderId string) (*PayPalOrder, error) {
+	if !IsValidPayPalResourceId(orderId) {
+		return nil, fmt.Errorf("无效的 PayPal 订单号 %q", orderId)
+	}
+	var order PayPalOrder
+	if err := callPayPalAPI(ctx, http.MethodGet, "/v2/checkout/orders/"+orderId, nil, &order); err != nil {
+		return nil, err
+	}
+	return &order, nil
+}
+
+func GetPayPalCapture(ctx context.Context, captureId string) (*PayPalCapture, error) {
+	if !IsValidPayPalResourceId(captureId) {
+		return nil, fmt.Errorf("无效的 PayPal 扣款号 %q", captureId)
+	}
+	var capture PayPalCapture
+	if err := callPayPalAPI(ctx, http.MethodGet, "/v2/payments/captures/"+captureId, nil, &capture); err != nil {
+		return nil, err
+	}
+	return &capture, nil
+}
+
+// PayPalRefundTradeNo 返回退款对应的本地 trade_no，退款资源未带 custom_id 时回查原扣款
+func PayPalRefundTradeNo(ctx context.Context, refund *PayPalRefund) (string, error) {
+	if refund.CustomId != "" {
+		return refund.CustomId, nil
+	}
+	for _, link := range refund.Links {
+		if link.Rel != "up" {
+			continue
+		}
+		idx := strings.LastIndex(link.Href, "/captures/")
+		if idx < 0 {
+			continue
+		}
+		capture, err := GetPayPalCapture(ctx, link.Href[idx+len("/captures/"):])
+		if err != nil {
+			return "", err
+		}
+		return capture.CustomId, nil
+	}
+	return "", errors.New("无法确定退款对应的扣款")
+}
+
+// VerifyPayPalWebhookSignature 通过 PayPal 的 verify-webhook-signature 接口校验事件签名，
+// 需要配置 PayPalWebhookId；body 必须为原始请求体
+func VerifyPayPalWebhookSignature(ctx context.Context, headers PayPalWebhookHeaders, body []byte) (bool, error) {
+	if setting.PayPalWebhookId == "" {
+		return false, errors.New("未配置 PayPal Webhook ID")
+	}
+	if headers.TransmissionId == "" || headers.TransmissionSig == "" || headers.CertURL == "" {
+		return false, nil
+	}
+	payload := map[string]any{
+		"auth_algo":         headers.AuthAlgo,
+		"cert_url":          headers.CertURL,
+		"transmission_id":   headers.TransmissionId,
+		"transmission_sig":  headers.TransmissionSig,
+		"transmission_time": headers.TransmissionTime,
+		"webhook_id":        setting.PayPalWebhookId,
+		"webhook_event":     json.RawMessage(body),
+	}
+	var resp struct {
+		VerificationStatus string `json:"verification_status"`
+	}
+	if err := callPayPalAPI(ctx, http.MethodPost, "/v1/notifications/verify-webhook-signature", payload, &resp); err != nil {
+		return false, err
+	}
+	return resp.VerificationStatus == "SUCCESS", nil
+}
@@ -0,0 +1,171 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+)
+
+const postpaidStatementTaskInterval = time.Hour
+
+// postpaidStatementHandler runs the monthly postpaid billing cycle: it issues
+// statements for the previous calendar month (UTC) and suspends accounts whose
+// statements are past due. Runs are idempotent, so the hourly schedule only
+// does real work once per month and whenever a statement becomes overdue.
+type postpaidStatementHandler struct{}
+
+func init() {
+	RegisterSystemTaskHandler(postpaidStatementHandler{})
+}
+
+func (postpaidStatementHandler) Type() string { return model.SystemTaskTypePostpaidStatement }
+
+func (postpaidStatementHandler) Enabled() bool {
+	return operation_setting.GetPostpaidSetting().Enabled
+}
+
+func (postpaidStatementHandler) Interval() time.Duration { return postpaidStatementTaskInterval }
+
+func (postpaidStatementHandler) NewPayload() any { return nil }
+
+// PostpaidStatementPayload controls one postpaid_statement run. A zero
+// PeriodStart bills the previous calendar month; a manual trigger may pass any
+// timestamp inside the month to (re)bill.
+type PostpaidStatementPayload struct {
+	PeriodStart int64 `json:"period_start,omitempty"`
+}
+
+type PostpaidStatementResult struct {
+	PeriodStart int64 `json:"period_start"`
+	PeriodEnd   int64 `json:"period_end"`
+	Accounts    int   `json:"accounts"`
+	Generated   int   `json:"generated"`
+	Invoiced    int   `json:"invoiced"`
+	Overdue     int   `json:"overdue"`
+	Suspended   int   `json:"suspended"`
+}
+
+func (postpaidStatementHandler) Run(ctx context.Context, task *model.SystemTask, runnerID string) {
+	payload := PostpaidStatementPayload{}
+	if err := task.DecodePayload(&payload); err != nil {
+		failSystemTask(task, runnerID, err)
+		return
+	}
+	ref := time.Now().UTC().AddDate(0, -1, 0)
+	if payload.PeriodStart > 0 {
+		ref = time.Unix(payload.PeriodStart, 0).UTC()
+	}
+	result, err := RunPostpaidBillingCycle(ctx, ref, time.Now())
+	if err != nil {
+		failSystemTask(task, runnerID, err)
+		return
+	}
+	if err := model.FinishSystemTask(task.TaskID, runnerID, model.SystemTaskStatusSucceeded, result, ""); err != nil {
+		logSystemTaskLockError(ctx, task, err)
+	}
+}
+
+// postpaidStatementPeriod returns the UTC calendar month containing ref.
+func postpaidStatementPeriod(ref time.Time) (int64, int64) {
+	ref = ref.UTC()
+	start := time.Date(ref.Year(), ref.Month(), 1, 0, 0, 0, 0, time.UTC)
+	return start.Unix(), start.AddDate(0, 1, 0).Unix()
+}
+
+// RunPostpaidBillingCycle 为 periodRef 所在自然月生成账单并开具发票，然后处理逾期账单。
+// 账期尚未结束时不生成账单，只处理逾期。
+func RunPostpaidBillingCycle(ctx context.Context, periodRef time.Time, now time.Time) (*PostpaidStatementResult, error) {
+	periodStart, periodEnd := postpaidStatementPeriod(periodRef)
+	result := &PostpaidStatementResult{PeriodStart: periodStart, PeriodEnd: periodEnd}
+	postpaidSetting := operation_setting.GetPostpaidSetting()
+
+	if periodEnd <= now.Unix() {
+		accounts, err := model.GetAllPostpaidAccounts()
+		if err != nil {
+			return result, err
+		}
+		for _, account := range accounts {
+			if ctx.Err() != nil {
+				return result, ctx.Err()
+			}
+			if account.CreatedAt >= periodEnd {
+				continue
+			}
+			result.Accounts++
+			statement, created, err := model.GeneratePostpaidStatement(account, periodStart, periodEnd, postpaidSetting.GraceDays)
+			if err != nil {
+				return result, fmt.Errorf("generate statement for postpaid account %d: %w", account.Id, err)
+			}
+			if created {
+				result.Generated++
+			}
+			if statement.InvoiceId == 0 && statement.Amount > 0 {
+				invoice, err := IssuePostpaidStatementInvoice(statement)
+				if err != nil {
+					common.SysError(fmt.Sprintf("failed to issue invoice for postpaid statement %d: %s", statement.Id, err.Error()))
+				} else if invoice != nil {
+					result.Invoiced++
+				}
+			}
+		}
+	}
+
+	accountIds, err := model.MarkOverduePostpaidStatements(now.Unix())
+	if err != nil {
+		return result, err
+	}
+	result.Overdue = len(accountIds)
+	if postpaidSetting.SuspendOverdue {
+		for _, accountId := range accountIds {
+			suspended, err := model.SuspendPostpaidAccount(accountId)
+			if err != nil {
+				return result, fmt.Errorf("suspend postpaid account %d: %w", accountId, err)
+			}
+			if suspended {
+				result.Suspended++
+				logger.LogInfo(ctx, fmt.Sprintf("postpaid account %d suspended for overdue statements", accountId))
+			}
+		}
+	}
+	return result, nil
+}
+
+// IssuePostpaidStatementInvoice 为月度账单开具发票；组织账单开给组织所有者并附带组织开票信息。
+func IssuePostpaidStatementInvoice(statement *model.PostpaidStatement) (*model.Invoice, error) {
+	if !operation_setting.GetInvoiceSetting().Enabled {
+		return nil, nil
+	}
+	params := model.InvoiceIssueParams{
+		UserId:        statement.OwnerId,
+		SourceType:    model.InvoiceSourcePostpaidStatement,
+		SourceId:      strconv.Itoa(statement.Id),
+		Description:   "Postpaid usage " + time.Unix(statement.PeriodStart, 0).UTC().Format("2006-01"),
+		Amount:        statement.Amount,
+		PaymentMethod: "postpaid",
+		PeriodStart:   statement.PeriodStart,
+		PeriodEnd:     statement.PeriodEnd,
+	}
+	if statement.OwnerType == model.BillingProfileOwnerOrganization {
+		org, err := model.GetOrganizationById(statement.OwnerId)
+		if err != nil {
+			return nil, err
+		}
+		params.UserId = org.OwnerId
+		params.OrganizationId = org.Id
+	}
+	invoice, err := issueInvoice(params)
+	if err != nil {
+		return nil, err
+	}
+	if err := model.SetPostpaidStatementInvoice(statement.Id, invoice.Id); err != nil {
+		return invoice, err
+	}
+	statement.InvoiceId = invoice.Id
+	return invoice, nil
+}
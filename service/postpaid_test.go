@@ -0,0 +1,260 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/model"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+	"github.com/QuantumNous/new-api/types"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+func enablePostpaid(t *testing.T) {
+	t.Helper()
+	postpaidSetting := operation_setting.GetPostpaidSetting()
+	saved := *postpaidSetting
+	postpaidSetting.Enabled = true
+	postpaidSetting.GraceDays = 10
+	postpaidSetting.SuspendOverdue = true
+	t.Cleanup(func() { *postpaidSetting = saved })
+}
+
+func TestBillingSessionAllowsWalletCreditLine(t *testing.T) {
+	truncate(t)
+	enablePostpaid(t)
+	seedUser(t, 1, 0)
+	require.NoError(t, model.UpsertPostpaidAccount(&model.PostpaidAccount{
+		OwnerType:   model.BillingProfileOwnerUser,
+		OwnerId:     1,
+		CreditLimit: 1000,
+	}))
+
+	relayInfo := &relaycommon.RelayInfo{UserId: 1, IsPlayground: true, RequestId: "req-credit-1"}
+	c, _ := gin.CreateTestContext(nil)
+	session, apiErr := NewBillingSession(c, relayInfo, 300)
+	require.Nil(t, apiErr)
+	require.Equal(t, BillingSourceWallet, relayInfo.BillingSource)
+	require.NoError(t, session.Settle(500))
+	require.Equal(t, -500, getUserQuota(t, 1))
+
+	// 透支不能超过信用额度
+	relayInfo = &relaycommon.RelayInfo{UserId: 1, IsPlayground: true, RequestId: "req-credit-2"}
+	_, apiErr = NewBillingSession(c, relayInfo, 600)
+	require.NotNil(t, apiErr)
+	require.Equal(t, types.ErrorCodeInsufficientUserQuota, apiErr.GetErrorCode())
+	require.Equal(t, -500, getUserQuota(t, 1))
+}
+
+func TestBillingSessionOrganizationCreditLine(t *testing.T) {
+	truncate(t)
+	enablePostpaid(t)
+	seedUser(t, 1, 0)
+	org := seedOrganization(t, 1, 0, 0)
+	require.NoError(t, model.UpsertPostpaidAccount(&model.PostpaidAccount{
+		OwnerType:   model.BillingProfileOwnerOrganization,
+		OwnerId:     org.Id,
+		CreditLimit: 500,
+	}))
+
+	relayInfo := &relaycommon.RelayInfo{UserId: 1, IsPlayground: true, OrganizationId: org.Id, RequestId: "req-org-credit"}
+	c, _ := gin.CreateTestContext(nil)
+	session, apiErr := NewBillingSession(c, relayInfo, 300)
+	require.Nil(t, apiErr)
+	require.NoError(t, session.Settle(300))
+	stored, err := model.GetOrganizationById(org.Id)
+	require.NoError(t, err)
+	require.Equal(t, -300, stored.Quota)
+
+	relayInfo = &relaycommon.RelayInfo{UserId: 1, IsPlayground: true, OrganizationId: org.Id, RequestId: "req-org-credit-2"}
+	_, apiErr = NewBillingSession(c, relayInfo, 300)
+	require.NotNil(t, apiErr)
+	require.Equal(t, types.ErrorCodeInsufficientUserQuota, apiErr.GetErrorCode())
+}
+
+func TestPostpaidBillingCycleSuspendsOverdueAndPaymentRestores(t *testing.T) {
+	truncate(t)
+	enablePostpaid(t)
+	seedUser(t, 1, -2*int(common.QuotaPerUnit))
+	seedToken(t, 1, 1, "postpaid-key-1", 0)
+	seedToken(t, 2, 1, "postpaid-key-2", 0)
+	require.NoError(t, model.DB.Model(&model.Token{}).Where("id = ?", 2).Update("name", "batch").Error)
+
+	periodStart := time.Date(2026, time.March, 1, 0, 0, 0, 0, time.UTC)
+	account := &model.PostpaidAccount{OwnerType: model.BillingProfileOwnerUser, OwnerId: 1, CreditLimit: 10 * int(common.QuotaPerUnit)}
+	require.NoError(t, model.UpsertPostpaidAccount(account))
+	require.NoError(t, model.DB.Model(account).Update("created_at", periodStart.Unix()-86400).Error)
+
+	inPeriod := periodStart.Unix() + 3600
+	logs := []*model.Log{
+		{UserId: 1, Type: model.LogTypeConsume, ModelName: "gpt-4o", TokenId: 1, TokenName: "test_token", Quota: int(common.QuotaPerUnit), CreatedAt: inPeriod},
+		{UserId: 1, Type: model.LogTypeConsume, ModelName: "gpt-4o", TokenId: 1, TokenName: "test_token", Quota: int(common.QuotaPerUnit) / 2, CreatedAt: inPeriod + 60},
+		{UserId: 1, Type: model.LogTypeConsume, ModelName: "claude-3-haiku", TokenId: 2, TokenName: "batch", Quota: int(common.QuotaPerUnit) / 2, CreatedAt: inPeriod + 120},
+		// 不属于本账期或非消费日志不计入账单
+		{UserId: 1, Type: model.LogTypeConsume, ModelName: "gpt-4o", TokenId: 1, Quota: 999, CreatedAt: periodStart.Unix() - 1},
+		{UserId: 1, Type: model.LogTypeTopup, Quota: 999, CreatedAt: inPeriod},
+	}
+	require.NoError(t, model.LOG_DB.Create(&logs).Error)
+
+	ctx := context.Background()
+	afterPeriod := periodStart.AddDate(0, 1, 1)
+	result, err := RunPostpaidBillingCycle(ctx, periodStart, afterPeriod)
+	require.NoError(t, err)
+	require.Equal(t, 1, result.Generated)
+	require.Zero(t, result.Suspended)
+
+	// 重复执行不会生成重复账单
+	result, err = RunPostpaidBillingCycle(ctx, periodStart, afterPeriod)
+	require.NoError(t, err)
+	require.Zero(t, result.Generated)
+
+	statements, total, err := model.GetPostpaidStatements(account.Id, "", 0, "", 0, 10)
+	require.NoError(t, err)
+	require.EqualValues(t, 1, total)
+	statement := statements[0]
+	require.EqualValues(t, 3, statement.Requests)
+	require.EqualValues(t, 2*int64(common.QuotaPerUnit), statement.UsageQuota)
+	require.InDelta(t, 2.0, statement.Amount, 0.001)
+	require.Equal(t, model.PostpaidStatementStatusOpen, statement.Status)
+	require.Equal(t, periodStart.AddDate(0, 1, 10).Unix(), statement.DueAt)
+
+	items, err := model.GetPostpaidStatementItems(statement.Id)
+	require.NoError(t, err)
+	require.Len(t, items, 2)
+	require.Equal(t, "gpt-4o", items[0].ModelName)
+	require.EqualValues(t, 2, items[0].Requests)
+	require.Equal(t, "claude-3-haiku", items[1].ModelName)
+	require.Equal(t, 2, items[1].TokenId)
+
+	// 超过付款期限后暂停令牌
+	result, err = RunPostpaidBillingCycle(ctx, periodStart, periodStart.AddDate(0, 1, 11))
+	require.NoError(t, err)
+	require.Equal(t, 1, result.Suspended)
+	var tokens []model.Token
+	require.NoError(t, model.DB.Order("id").Find(&tokens).Error)
+	for _, token := range tokens {
+		require.Equal(t, common.TokenStatusDisabled, token.Status)
+	}
+	require.Zero(t, model.GetUserPostpaidCreditLimit(1))
+
+	// 部分付款不恢复
+	_, err = model.RecordPostpaidPayment(statement.Id, &model.PostpaidPayment{Amount: 0.5, Reference: "wire-1"})
+	require.NoError(t, err)
+	reloaded, err := model.GetPostpaidAccountById(account.Id)
+	require.NoError(t, err)
+	require.Equal(t, model.PostpaidAccountStatusSuspended, reloaded.Status)
+
+	paid, err := model.RecordPostpaidPayment(statement.Id, &model.PostpaidPayment{Amount: 1.5, Reference: "wire-2"})
+	require.NoError(t, err)
+	require.Equal(t, model.PostpaidStatementStatusPaid, paid.Status)
+	require.Zero(t, getUserQuota(t, 1))
+
+	reloaded, err = model.GetPostpaidAccountById(account.Id)
+	require.NoError(t, err)
+	require.Equal(t, model.PostpaidAccountStatusActive, reloaded.Status)
+	require.NoError(t, model.DB.Order("id").Find(&tokens).Error)
+	for _, token := range tokens {
+		require.Equal(t, common.TokenStatusEnabled, token.Status)
+	}
+
+	_, err = model.RecordPostpaidPayment(statement.Id, &model.PostpaidPayment{Amount: 1})
+	require.ErrorIs(t, err, model.ErrPostpaidStatementPaid)
+}
+
+func TestPostpaidStatementBillsOnlyCreditLineUsage(t *testing.T) {
+	truncate(t)
+	enablePostpaid(t)
+	unit := int(common.QuotaPerUnit)
+	// 期初预付 1，订阅支付 3，钱包支付 1.5，账期结束后又用了 0.25：钱包余额 -0.75
+	seedUser(t, 1, -unit*3/4)
+	seedToken(t, 1, 1, "postpaid-mixed-key", 0)
+
+	periodStart := time.Date(2026, time.March, 1, 0, 0, 0, 0, time.UTC)
+	periodEnd := periodStart.AddDate(0, 1, 0)
+	account := &model.PostpaidAccount{OwnerType: model.BillingProfileOwnerUser, OwnerId: 1, CreditLimit: 10 * unit}
+	require.NoError(t, model.UpsertPostpaidAccount(account))
+	require.NoError(t, model.DB.Model(account).Update("created_at", periodStart.Unix()-86400).Error)
+
+	inPeriod := periodStart.Unix() + 3600
+	subscriptionOther := common.MapToJsonStr(map[string]interface{}{"billing_source": BillingSourceSubscription, "subscription_id": 1})
+	walletOther := common.MapToJsonStr(map[string]interface{}{"billing_source": BillingSourceWallet})
+	logs := []*model.Log{
+		{UserId: 1, Type: model.LogTypeConsume, ModelName: "gpt-4o", TokenId: 1, Quota: 3 * unit, Other: subscriptionOther, CreatedAt: inPeriod},
+		{UserId: 1, Type: model.LogTypeConsume, ModelName: "gpt-4o", TokenId: 1, Quota: unit, Other: walletOther, CreatedAt: inPeriod + 60},
+		{UserId: 1, Type: model.LogTypeConsume, ModelName: "gpt-4o", TokenId: 1, Quota: unit / 2, CreatedAt: inPeriod + 120},
+		{UserId: 1, Type: model.LogTypeConsume, ModelName: "gpt-4o", TokenId: 1, Quota: unit / 4, Other: walletOther, CreatedAt: periodEnd.Unix() + 60},
+	}
+	require.NoError(t, model.LOG_DB.Create(&logs).Error)
+
+	ctx := context.Background()
+	result, err := RunPostpaidBillingCycle(ctx, periodStart, periodEnd.Add(time.Hour))
+	require.NoError(t, err)
+	require.Equal(t, 1, result.Generated)
+
+	statements, _, err := model.GetPostpaidStatements(account.Id, "", 0, "", 0, 10)
+	require.NoError(t, err)
+	require.Len(t, statements, 1)
+	statement := statements[0]
+	// 订阅支付的用量不计入，预付余额覆盖的 1 不计费
+	require.EqualValues(t, 2, statement.Requests)
+	require.EqualValues(t, unit*3/2, statement.UsageQuota)
+	require.EqualValues(t, unit/2, statement.BilledQuota)
+	require.InDelta(t, 0.5, statement.Amount, 0.001)
+	require.Equal(t, model.PostpaidStatementStatusOpen, statement.Status)
+
+	// 下一账期：上期未付清的欠款不重复计费
+	result, err = RunPostpaidBillingCycle(ctx, periodEnd, periodEnd.AddDate(0, 1, 1))
+	require.NoError(t, err)
+	require.Equal(t, 1, result.Generated)
+	statements, _, err = model.GetPostpaidStatements(account.Id, "", 0, "", 0, 10)
+	require.NoError(t, err)
+	require.Len(t, statements, 2)
+	next := statements[0]
+	if next.PeriodStart != periodEnd.Unix() {
+		next = statements[1]
+	}
+	require.Equal(t, periodEnd.Unix(), next.PeriodStart)
+	require.EqualValues(t, unit/4, next.UsageQuota)
+	require.EqualValues(t, unit/4, next.BilledQuota)
+	require.InDelta(t, 0.25, next.Amount, 0.001)
+}
+
+func TestPostpaidStatementBillsDrawnCreditWithoutConsumeLogs(t *testing.T) {
+	truncate(t)
+	enablePostpaid(t)
+	oldLogConsumeEnabled := common.LogConsumeEnabled
+	common.LogConsumeEnabled = false
+	t.Cleanup(func() { common.LogConsumeEnabled = oldLogConsumeEnabled })
+	unit := int(common.QuotaPerUnit)
+	seedUser(t, 1, -unit)
+	seedToken(t, 1, 1, "postpaid-nolog-key", 0)
+
+	periodStart := time.Date(2026, time.March, 1, 0, 0, 0, 0, time.UTC)
+	periodEnd := periodStart.AddDate(0, 1, 0)
+	account := &model.PostpaidAccount{OwnerType: model.BillingProfileOwnerUser, OwnerId: 1, CreditLimit: 10 * unit}
+	require.NoError(t, model.UpsertPostpaidAccount(account))
+	require.NoError(t, model.DB.Model(account).Update("created_at", periodStart.Unix()-86400).Error)
+
+	ctx := context.Background()
+	result, err := RunPostpaidBillingCycle(ctx, periodStart, periodEnd.Add(time.Hour))
+	require.NoError(t, err)
+	require.Equal(t, 1, result.Generated)
+
+	statements, _, err := model.GetPostpaidStatements(account.Id, "", 0, "", 0, 10)
+	require.NoError(t, err)
+	require.Len(t, statements, 1)
+	statement := statements[0]
+	// 没有消费日志也要按透支的额度出账，否则账单直接结清、逾期也不会暂停
+	require.Zero(t, statement.UsageQuota)
+	require.EqualValues(t, unit, statement.BilledQuota)
+	require.InDelta(t, 1.0, statement.Amount, 0.001)
+	require.Equal(t, model.PostpaidStatementStatusOpen, statement.Status)
+
+	result, err = RunPostpaidBillingCycle(ctx, periodStart, periodEnd.AddDate(0, 0, 11))
+	require.NoError(t, err)
+	require.Equal(t, 1, result.Suspended)
+}
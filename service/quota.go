@@ -139,7 +139,8 @@ func PreWssConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, usag
 	quota, clamp := calculateAudioQuota(quotaInfo)
 	noteQuotaClamp(relayInfo, clamp)
 
-	if userQuota < quota {
+	// 开通后付费的用户可以透支到 -CreditLimit，与 NewBillingSession 保持一致
+	if userQuota < quota && userQuota+model.GetUserPostpaidCreditLimit(relayInfo.UserId) < quota {
 		return fmt.Errorf("user quota is not enough, user quota: %s, need quota: %s", logger.FormatQuota(userQuota), logger.FormatQuota(quota))
 	}
 
@@ -228,6 +229,7 @@ func PostWssConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, mod
 		model.UpdateChannelUsedQuota(relayInfo.ChannelId, quota)
 	}
 
+	relayInfo.UsageTotalTokens = totalTokens
 	if err := SettleBilling(ctx, relayInfo, quota); err != nil {
 		logger.LogError(ctx, "error settling billing: "+err.Error())
 	}
@@ -242,6 +244,13 @@ func PostWssConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, mod
 		InjectTieredBillingInfo(other, relayInfo, tieredResult)
 	}
 	attachQuotaSaturation(ctx, relayInfo, other)
+	upstreamCost, _ := ComputeUpstreamCost(ctx, relayInfo, func(map[string]bool) billingexpr.TokenParams {
+		return billingexpr.TokenParams{
+			P:   float64(usage.InputTokens),
+			C:   float64(usage.OutputTokens),
+			Len: float64(usage.InputTokens),
+		}
+	})
 	model.RecordConsumeLog(ctx, relayInfo.UserId, model.RecordConsumeLogParams{
 		ChannelId:        relayInfo.ChannelId,
 		PromptTokens:     usage.InputTokens,
@@ -249,6 +258,7 @@ func PostWssConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, mod
 		ModelName:        logModel,
 		TokenName:        tokenName,
 		Quota:            quota,
+		UpstreamCost:     upstreamCost,
 		Content:          logContent,
 		TokenId:          relayInfo.TokenId,
 		UseTimeSeconds:   int(useTimeSeconds),
@@ -351,6 +361,7 @@ func PostAudioConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, u
 		model.UpdateChannelUsedQuota(relayInfo.ChannelId, quota)
 	}
 
+	relayInfo.UsageTotalTokens = totalTokens
 	if err := SettleBilling(ctx, relayInfo, quota); err != nil {
 		logger.LogError(ctx, "error settling billing: "+err.Error())
 	}
@@ -372,6 +383,7 @@ func PostAudioConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, u
 		ModelName:        logModel,
 		TokenName:        tokenName,
 		Quota:            quota,
+		UpstreamCost:     ComputeUsageUpstreamCost(ctx, relayInfo, usage, false),
 		Content:          logContent,
 		TokenId:          relayInfo.TokenId,
 		UseTimeSeconds:   int(useTimeSeconds),
@@ -417,24 +429,26 @@ func PostConsumeQuota(relayInfo *relaycommon.RelayInfo, quota int, preConsumedQu
 		}
 		delta := int64(quota)
 		if delta != 0 {
-			if err := model.PostConsumeUserSubscriptionDelta(relayInfo.SubscriptionId, delta); err != nil {
+			if err := model.PostConsumeUserSubscriptionQuotaDelta(relayInfo.SubscriptionId, relayInfo.SubscriptionBucketId, delta, model.QuotaLedgerRefRequestId, relayInfo.RequestId); err != nil {
 				return err
 			}
 			relayInfo.SubscriptionPostDelta += delta
 		}
-	} else {
-		// Wallet
-		if quota > 0 {
-			err = model.DecreaseUserQuota(relayInfo.UserId, quota, false)
-		} else {
-			err = model.IncreaseUserQuota(relayInfo.UserId, -quota, false)
+	} else if relayInfo != nil && relayInfo.BillingSource == BillingSourceOrganization {
+		if err := model.SettleOrganizationQuota(relayInfo.OrganizationId, relayInfo.UserId, quota, model.QuotaLedgerRefRequestId, relayInfo.RequestId); err != nil {
+			return err
 		}
-		if err != nil {
+	} else {
+		// Wallet：额度批次按实际结算的全额（预扣 + 差额）消耗
+		if err = model.SettleUserWalletQuota(relayInfo.UserId, quota, quota+preConsumedQuota, model.QuotaLedgerRefRequestId, relayInfo.RequestId); err != nil {
 			return err
 		}
+		if quota > 0 {
+			TriggerAutoRecharge(relayInfo.UserId, relayInfo.UserQuota-(quota+preConsumedQuota), relayInfo.RequestId)
+		}
 	}
 
-	if !relayInfo.IsPlayground {
+	if !relayInfo.IsPlayground && quota != 0 {
 		if quota > 0 {
 			err = model.DecreaseTokenQuota(relayInfo.TokenId, relayInfo.TokenKey, quota)
 		} else {
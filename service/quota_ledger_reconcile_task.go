@@ -0,0 +1,113 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+
+	"github.com/bytedance/gopkg/util/gopool"
+)
+
+const (
+	quotaLedgerReconcileTickInterval = time.Minute
+	quotaLedgerReconcileBatchSize    = 500
+	quotaLedgerDriftNotifyMaxLines   = 20
+)
+
+var (
+	quotaLedgerReconcileOnce    sync.Once
+	quotaLedgerReconcileRunning atomic.Bool
+	quotaLedgerReconcileLastRun atomic.Int64
+)
+
+// StartQuotaLedgerReconcileTask periodically compares users.quota with the
+// quota ledger balances and notifies the root user about persistent drift.
+func StartQuotaLedgerReconcileTask() {
+	quotaLedgerReconcileOnce.Do(func() {
+		if !common.IsMasterNode {
+			return
+		}
+		gopool.Go(func() {
+			logger.LogInfo(context.Background(), fmt.Sprintf("quota ledger reconcile task started: tick=%s", quotaLedgerReconcileTickInterval))
+			ticker := time.NewTicker(quotaLedgerReconcileTickInterval)
+			defer ticker.Stop()
+
+			for range ticker.C {
+				setting := operation_setting.GetQuotaLedgerSetting()
+				if !setting.Enabled || !setting.ReconcileEnabled || common.BatchUpdateEnabled {
+					continue
+				}
+				interval := int64(setting.ReconcileIntervalMinutes) * 60
+				if interval <= 0 || time.Now().Unix()-quotaLedgerReconcileLastRun.Load() < interval {
+					continue
+				}
+				if _, err := RunQuotaLedgerReconcile(); err != nil {
+					logger.LogWarn(context.Background(), fmt.Sprintf("quota ledger reconcile failed: %v", err))
+				}
+			}
+		})
+	})
+}
+
+// RunQuotaLedgerReconcile reconciles every user once. It returns an error
+// without doing anything when the ledger is disabled, when another run is in
+// progress, or when batch updates are enabled: users.quota then lags behind
+// the ledger by deltas buffered on every node, which one node cannot see.
+func RunQuotaLedgerReconcile() (*model.QuotaLedgerReconcileResult, error) {
+	if !operation_setting.GetQuotaLedgerSetting().Enabled {
+		return nil, fmt.Errorf("quota ledger reconcile is unavailable while the quota ledger is disabled")
+	}
+	if common.BatchUpdateEnabled {
+		return nil, fmt.Errorf("quota ledger reconcile is unavailable while batch update is enabled")
+	}
+	if !quotaLedgerReconcileRunning.CompareAndSwap(false, true) {
+		return nil, fmt.Errorf("quota ledger reconcile is already running")
+	}
+	defer quotaLedgerReconcileRunning.Store(false)
+	quotaLedgerReconcileLastRun.Store(time.Now().Unix())
+
+	tolerance := int64(operation_setting.GetQuotaLedgerSetting().DriftTolerance)
+	result := &model.QuotaLedgerReconcileResult{}
+	afterId := 0
+	for {
+		nextId, err := model.ReconcileQuotaLedgerBatch(afterId, quotaLedgerReconcileBatchSize, tolerance, result)
+		if err != nil {
+			return result, err
+		}
+		if nextId == 0 {
+			break
+		}
+		afterId = nextId
+	}
+	logger.LogInfo(context.Background(), fmt.Sprintf("quota ledger reconcile finished: checked=%d opened=%d drifted=%d resolved=%d",
+		result.Checked, result.Opened, result.Drifted, result.Resolved))
+	notifyQuotaLedgerDrift(result.ToNotify)
+	return result, nil
+}
+
+func notifyQuotaLedgerDrift(drifts []*model.QuotaLedgerDrift) {
+	if len(drifts) == 0 {
+		return
+	}
+	lines := make([]string, 0, len(drifts))
+	for i, drift := range drifts {
+		if i >= quotaLedgerDriftNotifyMaxLines {
+			lines = append(lines, fmt.Sprintf("……另有 %d 个用户", len(drifts)-i))
+			break
+		}
+		lines = append(lines, fmt.Sprintf("用户 #%d：余额 %d，账本 %d，差额 %d",
+			drift.UserId, drift.UserQuota, drift.LedgerBalance, drift.Drift))
+	}
+	NotifyRootUser(dto.NotifyTypeQuotaLedger,
+		fmt.Sprintf("额度账本对账发现 %d 个用户余额不一致", len(drifts)),
+		"以下用户的余额与账本连续多次对账不一致，请核查：\n"+strings.Join(lines, "\n"))
+}
@@ -0,0 +1,59 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/QuantumNous/new-api/model"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWalletBillingSessionWritesQuotaLedger(t *testing.T) {
+	truncate(t)
+	seedUser(t, 1, 1000)
+
+	relayInfo := &relaycommon.RelayInfo{
+		UserId:       1,
+		IsPlayground: true,
+		RequestId:    "req-ledger",
+	}
+	c, _ := gin.CreateTestContext(nil)
+	session, apiErr := NewBillingSession(c, relayInfo, 300)
+	require.Nil(t, apiErr)
+	require.Equal(t, BillingSourceWallet, relayInfo.BillingSource)
+	require.NoError(t, session.Settle(200))
+
+	entries, _, err := model.GetQuotaLedgerEntries(model.QuotaLedgerQuery{
+		Account: model.QuotaLedgerUserAccount(1),
+		RefId:   "req-ledger",
+	}, 0, 10)
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	require.Equal(t, model.QuotaLedgerReasonRefund, entries[0].Reason)
+	require.EqualValues(t, 100, entries[0].Amount)
+	require.Equal(t, model.QuotaLedgerReasonConsume, entries[1].Reason)
+	require.EqualValues(t, -300, entries[1].Amount)
+
+	account, err := model.GetQuotaLedgerAccount(model.QuotaLedgerUserAccount(1))
+	require.NoError(t, err)
+	require.EqualValues(t, getUserQuota(t, 1), account.Balance)
+	require.EqualValues(t, 800, account.Balance)
+}
+
+func TestRunQuotaLedgerReconcileRefusesWhenLedgerDisabled(t *testing.T) {
+	truncate(t)
+	seedUser(t, 1, 1000)
+	ledgerSetting := operation_setting.GetQuotaLedgerSetting()
+	ledgerSetting.Enabled = false
+	t.Cleanup(func() { ledgerSetting.Enabled = true })
+
+	// 账本关闭时手动对账不能开户或写入差额记录
+	result, err := RunQuotaLedgerReconcile()
+	require.Error(t, err)
+	require.Nil(t, result)
+	var accounts int64
+	require.NoError(t, model.DB.Model(&model.QuotaLedgerAccount{}).Count(&accounts).Error)
+	require.Zero(t, accounts)
+}
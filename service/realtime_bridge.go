@@ -0,0 +1,27 @@
+package service
+
+import (
+	"strings"
+
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+)
+
+// MatchRealtimeBridgeRule 返回实时模型对应的桥接规则，未开启或未配置对话模型时返回 nil，按原生 Realtime 渠道转发
+func MatchRealtimeBridgeRule(modelName string) *operation_setting.RealtimeBridgeRule {
+	setting := operation_setting.GetRealtimeBridgeSetting()
+	if setting == nil || !setting.Enabled {
+		return nil
+	}
+	modelName = strings.TrimSpace(modelName)
+	if modelName == "" {
+		return nil
+	}
+	for i := range setting.Rules {
+		rule := &setting.Rules[i]
+		if strings.TrimSpace(rule.Model) != modelName || strings.TrimSpace(rule.ChatModel) == "" {
+			continue
+		}
+		return rule
+	}
+	return nil
+}
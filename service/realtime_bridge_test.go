@@ -0,0 +1,45 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+	"github.com/stretchr/testify/require"
+)
+
+func withRealtimeBridgeSetting(t *testing.T, setting operation_setting.RealtimeBridgeSetting) {
+	t.Helper()
+	current := operation_setting.GetRealtimeBridgeSetting()
+	saved := *current
+	*current = setting
+	t.Cleanup(func() {
+		*current = saved
+	})
+}
+
+func TestMatchRealtimeBridgeRule(t *testing.T) {
+	withRealtimeBridgeSetting(t, operation_setting.RealtimeBridgeSetting{
+		Enabled: true,
+		Rules: []operation_setting.RealtimeBridgeRule{
+			{Model: "no-chat-realtime", TranscriptionModel: "whisper-1"},
+			{Model: "gpt-4o-mini-realtime", ChatModel: "gpt-4o-mini", TranscriptionModel: "whisper-1", SpeechModel: "tts-1"},
+		},
+	})
+
+	rule := MatchRealtimeBridgeRule("gpt-4o-mini-realtime")
+	require.NotNil(t, rule)
+	require.Equal(t, "gpt-4o-mini", rule.ChatModel)
+
+	// 没有对话模型的规则无法组成管线
+	require.Nil(t, MatchRealtimeBridgeRule("no-chat-realtime"))
+	require.Nil(t, MatchRealtimeBridgeRule("gpt-4o-realtime-preview"))
+	require.Nil(t, MatchRealtimeBridgeRule(""))
+
+	withRealtimeBridgeSetting(t, operation_setting.RealtimeBridgeSetting{
+		Enabled: false,
+		Rules: []operation_setting.RealtimeBridgeRule{
+			{Model: "gpt-4o-mini-realtime", ChatModel: "gpt-4o-mini"},
+		},
+	})
+	require.Nil(t, MatchRealtimeBridgeRule("gpt-4o-mini-realtime"))
+}
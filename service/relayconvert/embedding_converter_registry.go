@@ -0,0 +1,147 @@
+package relayconvert
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/QuantumNous/new-api/types"
+)
+
+// EmbeddingConverterSpec 描述一对向量格式之间的请求与响应转换。与 TextConverterSpec 一样以同一 ID
+// 同时注册请求转换（From 请求 -> To 请求）与响应转换（From 响应 -> To 响应），向量接口没有流式响应
+type EmbeddingConverterSpec struct {
+	ID      string
+	From    types.RelayFormat
+	To      types.RelayFormat
+	Quality TextConverterQuality
+	Req     RequestConverterFunc
+	Resp    ResponseConverterFunc
+}
+
+var (
+	embeddingConverterMu sync.RWMutex
+	embeddingConverters  = make(map[string]EmbeddingConverterSpec)
+)
+
+// builtinEmbeddingConverters 以 OpenAI Embeddings 为中心，Gemini 与 Cohere 之间直接转换以保留任务类型。
+// 转换到 OpenAI 时 taskType/input_type 只能经 EmbeddingRequest.TaskType 隐式保留、title 丢失，因此标为 fair
+var builtinEmbeddingConverters = []EmbeddingConverterSpec{
+	{
+		ID:      ConverterOpenAIEmbedToGeminiEmbed,
+		From:    types.RelayFormatEmbedding,
+		To:      types.RelayFormatGeminiEmbedding,
+		Quality: TextConverterQualityGood,
+		Req:     convertOpenAIEmbeddingRequestToGemini,
+		Resp:    convertOAIEmbeddingResponseToGeminiEmbed,
+	},
+	{
+		ID:      ConverterGeminiEmbedToOpenAIEmbed,
+		From:    types.RelayFormatGeminiEmbedding,
+		To:      types.RelayFormatEmbedding,
+		Quality: TextConverterQualityFair,
+		Req:     convertGeminiEmbeddingRequestToOpenAI,
+		Resp:    convertGeminiEmbedResponseToOAIEmbedding,
+	},
+	{
+		ID:      ConverterOpenAIEmbedToCohereEmbed,
+		From:    types.RelayFormatEmbedding,
+		To:      types.RelayFormatCohereEmbedding,
+		Quality: TextConverterQualityGood,
+		Req:     convertOpenAIEmbeddingRequestToCohere,
+		Resp:    convertOAIEmbeddingResponseToCohereEmbed,
+	},
+	{
+		ID:      ConverterCohereEmbedToOpenAIEmbed,
+		From:    types.RelayFormatCohereEmbedding,
+		To:      types.RelayFormatEmbedding,
+		Quality: TextConverterQualityFair,
+		Req:     convertCohereEmbedRequestToOpenAI,
+		Resp:    convertCohereEmbedResponseToOAIEmbedding,
+	},
+	{
+		ID:      ConverterGeminiEmbedToCohereEmbed,
+		From:    types.RelayFormatGeminiEmbedding,
+		To:      types.RelayFormatCohereEmbedding,
+		Quality: TextConverterQualityGood,
+		Req:     convertGeminiEmbeddingRequestToCohere,
+		Resp:    convertGeminiEmbedResponseToCohereEmbed,
+	},
+	{
+		ID:      ConverterCohereEmbedToGeminiEmbed,
+		From:    types.RelayFormatCohereEmbedding,
+		To:      types.RelayFormatGeminiEmbedding,
+		Quality: TextConverterQualityGood,
+		Req:     convertCohereEmbedRequestToGemini,
+		Resp:    convertCohereEmbedResponseToGeminiEmbed,
+	},
+}
+
+func registerBuiltinEmbeddingConverters() {
+	for _, spec := range builtinEmbeddingConverters {
+		registerBuiltinEmbeddingConverter(spec)
+	}
+	registerBuiltinOllamaEmbeddingConverters()
+}
+
+// registerBuiltinOllamaEmbeddingConverters 注册 Ollama /api/embed 的请求与响应转换。
+// Ollama 只作为入站格式，没有对称的反向转换，因此不走 EmbeddingConverterSpec，分别注册请求与响应两侧
+func registerBuiltinOllamaEmbeddingConverters() {
+	registerBuiltinRequestConverter(RequestConverterSpec{
+		ID:      ConverterOllamaEmbedToOpenAIEmbed,
+		From:    types.RelayFormatOllama,
+		To:      types.RelayFormatEmbedding,
+		Quality: RequestConverterQualityGood,
+		Convert: convertOllamaEmbedRequestToOpenAI,
+	})
+	registerBuiltinResponseConverter(ResponseConverterSpec{
+		ID:      ResponseConverterOAIEmbedToOllamaEmbed,
+		From:    types.RelayFormatEmbedding,
+		To:      types.RelayFormatOllama,
+		Quality: ResponseConverterQualityGood,
+		Convert: convertOAIEmbeddingResponseToOllamaEmbed,
+	})
+}
+
+func LookupEmbeddingConverter(converter string) (EmbeddingConverterSpec, bool) {
+	embeddingConverterMu.RLock()
+	defer embeddingConverterMu.RUnlock()
+
+	spec, ok := embeddingConverters[strings.TrimSpace(converter)]
+	return spec, ok
+}
+
+func registerBuiltinEmbeddingConverter(spec EmbeddingConverterSpec) {
+	spec.ID = strings.TrimSpace(spec.ID)
+	if spec.ID == "" {
+		panic("embedding converter ID is required")
+	}
+	if spec.From == "" || spec.To == "" {
+		panic(fmt.Sprintf("embedding converter %q must declare from and to formats", spec.ID))
+	}
+	if spec.Quality == "" {
+		panic(fmt.Sprintf("embedding converter %q must declare quality", spec.ID))
+	}
+	if spec.Req == nil || spec.Resp == nil {
+		panic(fmt.Sprintf("embedding converter %q must declare request and response conversion", spec.ID))
+	}
+	if _, exists := embeddingConverters[spec.ID]; exists {
+		panic(fmt.Sprintf("embedding converter %q is already registered", spec.ID))
+	}
+
+	registerBuiltinRequestConverter(RequestConverterSpec{
+		ID:      spec.ID,
+		From:    spec.From,
+		To:      spec.To,
+		Quality: RequestConverterQuality(spec.Quality),
+		Convert: spec.Req,
+	})
+	registerBuiltinResponseConverter(ResponseConverterSpec{
+		ID:      spec.ID,
+		From:    spec.From,
+		To:      spec.To,
+		Quality: ResponseConverterQuality(spec.Quality),
+		Convert: spec.Resp,
+	})
+	embeddingConverters[spec.ID] = spec
+}
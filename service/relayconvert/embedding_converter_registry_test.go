@@ -0,0 +1,199 @@
+package relayconvert
+
+import (
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLookupBuiltinEmbeddingConverters(t *testing.T) {
+	for _, expected := range builtinEmbeddingConverters {
+		spec, ok := LookupEmbeddingConverter(expected.ID)
+		require.True(t, ok, expected.ID)
+		assert.Equal(t, expected.From, spec.From)
+		assert.Equal(t, expected.To, spec.To)
+
+		requestSpec, ok := LookupRequestConverter(expected.ID)
+		require.True(t, ok, expected.ID)
+		assert.Equal(t, RequestConverterQuality(expected.Quality), requestSpec.Quality)
+
+		responseSpec, ok := LookupResponseConverter(expected.ID)
+		require.True(t, ok, expected.ID)
+		assert.Equal(t, ResponseConverterQuality(expected.Quality), responseSpec.Quality)
+	}
+
+	_, ok := LookupEmbeddingConverter(ConverterOllamaEmbedToOpenAIEmbed)
+	assert.False(t, ok)
+}
+
+func TestConvertEmbeddingRequestGeminiToOpenAIAndCohere(t *testing.T) {
+	request := &dto.GeminiBatchEmbeddingRequest{
+		Requests: []*dto.GeminiEmbeddingRequest{
+			{
+				Model:                "models/gemini-embedding-001",
+				Content:              dto.GeminiChatContent{Parts: []dto.GeminiPart{{Text: "hello"}, {Text: "world"}}},
+				TaskType:             "retrieval_query",
+				OutputDimensionality: 256,
+			},
+			{
+				Model:   "models/gemini-embedding-001",
+				Content: dto.GeminiChatContent{Parts: []dto.GeminiPart{{Text: "second"}}},
+			},
+		},
+	}
+
+	result, err := ConvertRequest(nil, nil, types.RelayFormatEmbedding, request)
+	require.NoError(t, err)
+	embeddingRequest := result.Value.(*dto.EmbeddingRequest)
+	assert.Equal(t, "gemini-embedding-001", embeddingRequest.Model)
+	assert.Equal(t, []string{"hello\nworld", "second"}, embeddingRequest.ParseInput())
+	assert.Equal(t, "RETRIEVAL_QUERY", embeddingRequest.TaskType)
+	require.NotNil(t, embeddingRequest.Dimensions)
+	assert.Equal(t, 256, *embeddingRequest.Dimensions)
+
+	result, err = ConvertRequest(nil, nil, types.RelayFormatCohereEmbedding, request)
+	require.NoError(t, err)
+	cohereRequest := result.Value.(*dto.CohereEmbedRequest)
+	assert.Equal(t, []string{"hello\nworld", "second"}, cohereRequest.Texts)
+	assert.Equal(t, "search_query", cohereRequest.InputType)
+	assert.Equal(t, []string{"float"}, cohereRequest.EmbeddingTypes)
+	require.NotNil(t, cohereRequest.OutputDimension)
+	assert.Equal(t, 256, *cohereRequest.OutputDimension)
+}
+
+func TestConvertEmbeddingRequestRejectsGeminiInlineData(t *testing.T) {
+	_, err := ConvertRequest(nil, nil, types.RelayFormatEmbedding, &dto.GeminiEmbeddingRequest{
+		Content: dto.GeminiChatContent{Parts: []dto.GeminiPart{{InlineData: &dto.GeminiInlineData{MimeType: "image/png", Data: "AA=="}}}},
+	})
+	require.Error(t, err)
+}
+
+func TestConvertEmbeddingRequestOpenAIToCohereAndGemini(t *testing.T) {
+	request := &dto.EmbeddingRequest{
+		Model:      "embed-v4.0",
+		Input:      []any{"a", "b"},
+		Dimensions: common.GetPointer(512),
+		TaskType:   "CLUSTERING",
+	}
+
+	result, err := ConvertRequest(nil, nil, types.RelayFormatCohereEmbedding, request)
+	require.NoError(t, err)
+	cohereRequest := result.Value.(*dto.CohereEmbedRequest)
+	assert.Equal(t, "embed-v4.0", cohereRequest.Model)
+	assert.Equal(t, []string{"a", "b"}, cohereRequest.Texts)
+	assert.Equal(t, "clustering", cohereRequest.InputType)
+	require.NotNil(t, cohereRequest.OutputDimension)
+	assert.Equal(t, 512, *cohereRequest.OutputDimension)
+
+	// 未指定任务类型时 Cohere 按文档入库处理，v3 及以上模型要求必须带 input_type
+	request.TaskType = ""
+	result, err = ConvertRequest(nil, nil, types.RelayFormatCohereEmbedding, request)
+	require.NoError(t, err)
+	assert.Equal(t, "search_document", result.Value.(*dto.CohereEmbedRequest).InputType)
+
+	request.TaskType = "CLUSTERING"
+	result, err = ConvertRequest(nil, nil, types.RelayFormatGeminiEmbedding, request)
+	require.NoError(t, err)
+	batch := result.Value.(*dto.GeminiBatchEmbeddingRequest)
+	require.Len(t, batch.Requests, 2)
+	assert.Equal(t, "models/embed-v4.0", batch.Requests[0].Model)
+	assert.Equal(t, "b", batch.Requests[1].Content.Parts[0].Text)
+	assert.Equal(t, "CLUSTERING", batch.Requests[1].TaskType)
+	assert.Equal(t, 512, batch.Requests[1].OutputDimensionality)
+}
+
+func TestConvertEmbeddingRequestCohereRejectsUnsupportedInput(t *testing.T) {
+	_, err := ConvertRequest(nil, nil, types.RelayFormatEmbedding, &dto.CohereEmbedRequest{
+		Model:  "embed-v4.0",
+		Images: []string{"data:image/png;base64,AA=="},
+	})
+	require.Error(t, err)
+
+	_, err = ConvertRequest(nil, nil, types.RelayFormatEmbedding, &dto.CohereEmbedRequest{
+		Model:          "embed-v4.0",
+		Texts:          []string{"a"},
+		EmbeddingTypes: []string{"int8"},
+	})
+	require.Error(t, err)
+
+	result, err := ConvertRequest(nil, nil, types.RelayFormatEmbedding, &dto.CohereEmbedRequest{
+		Model:     "embed-v4.0",
+		Texts:     []string{"a"},
+		InputType: "search_query",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "RETRIEVAL_QUERY", result.Value.(*dto.EmbeddingRequest).TaskType)
+}
+
+func TestConvertEmbeddingResponseOpenAIToGemini(t *testing.T) {
+	response := &dto.EmbeddingResponse{
+		Model: "text-embedding-3-small",
+		Data: []dto.EmbeddingResponseItem{
+			{Index: 1, Embedding: []float64{2}},
+			{Index: 0, Embedding: []float64{1}},
+		},
+		Usage: dto.Usage{PromptTokens: 3, TotalTokens: 3},
+	}
+
+	result, err := ConvertResponse(nil, nil, types.RelayFormatGeminiEmbedding, response)
+	require.NoError(t, err)
+	batch := result.Value.(*dto.GeminiBatchEmbeddingResponse)
+	require.Len(t, batch.Embeddings, 2)
+	assert.Equal(t, []float64{1}, batch.Embeddings[0].Values)
+	assert.Equal(t, []float64{2}, batch.Embeddings[1].Values)
+	assert.Equal(t, 3, result.Usage.PromptTokens)
+
+	info := &relaycommon.RelayInfo{RelayFormat: types.RelayFormatGemini}
+	result, err = ConvertResponse(nil, info, types.RelayFormatGeminiEmbedding, &dto.EmbeddingResponse{
+		Data: []dto.EmbeddingResponseItem{{Embedding: []float64{0.5}}},
+	})
+	require.NoError(t, err)
+	single := result.Value.(*dto.GeminiEmbeddingResponse)
+	assert.Equal(t, []float64{0.5}, single.Embedding.Values)
+}
+
+func TestConvertEmbeddingResponseCohereUsesBilledUnits(t *testing.T) {
+	response := &dto.CohereEmbedResponse{
+		Id:   "embed-1",
+		Meta: &dto.CohereEmbedMeta{BilledUnits: &dto.CohereEmbedBilledUnits{InputTokens: 7}},
+	}
+	require.NoError(t, response.SetFloatEmbeddings([][]float64{{1, 2}, {3, 4}}, true))
+
+	result, err := ConvertResponse(nil, nil, types.RelayFormatEmbedding, response)
+	require.NoError(t, err)
+	embeddingResponse := result.Value.(*dto.EmbeddingResponse)
+	require.Len(t, embeddingResponse.Data, 2)
+	assert.Equal(t, 1, embeddingResponse.Data[1].Index)
+	assert.Equal(t, []float64{3, 4}, embeddingResponse.Data[1].Embedding)
+	assert.Equal(t, 7, embeddingResponse.Usage.PromptTokens)
+	assert.Equal(t, 7, embeddingResponse.Usage.TotalTokens)
+	require.NotNil(t, result.Usage)
+	assert.Equal(t, 7, result.Usage.PromptTokens)
+
+	floats := &dto.CohereEmbedResponse{ResponseType: dto.CohereEmbedResponseTypeFloats}
+	require.NoError(t, common.Unmarshal([]byte(`[[0.1],[0.2]]`), &floats.Embeddings))
+	result, err = ConvertResponse(nil, nil, types.RelayFormatGeminiEmbedding, floats)
+	require.NoError(t, err)
+	batch := result.Value.(*dto.GeminiBatchEmbeddingResponse)
+	require.Len(t, batch.Embeddings, 2)
+	assert.Equal(t, []float64{0.2}, batch.Embeddings[1].Values)
+}
+
+func TestConvertEmbeddingResponseOpenAIToCohere(t *testing.T) {
+	result, err := ConvertResponse(nil, nil, types.RelayFormatCohereEmbedding, &dto.EmbeddingResponse{
+		Data:  []dto.EmbeddingResponseItem{{Embedding: []float64{1, 2}}},
+		Usage: dto.Usage{PromptTokens: 4, TotalTokens: 4},
+	})
+	require.NoError(t, err)
+	cohereResponse := result.Value.(*dto.CohereEmbedResponse)
+	assert.Equal(t, dto.CohereEmbedResponseTypeByType, cohereResponse.ResponseType)
+	assert.Equal(t, 4, cohereResponse.InputTokens())
+	vectors, err := cohereResponse.FloatEmbeddings()
+	require.NoError(t, err)
+	assert.Equal(t, [][]float64{{1, 2}}, vectors)
+}
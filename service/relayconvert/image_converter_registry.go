@@ -0,0 +1,110 @@
+package relayconvert
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/QuantumNous/new-api/types"
+)
+
+// ImageConverterSpec 描述 OpenAI Images 与 Gemini 图像接口之间的请求与响应转换，注册方式与 EmbeddingConverterSpec 相同
+type ImageConverterSpec struct {
+	ID      string
+	From    types.RelayFormat
+	To      types.RelayFormat
+	Quality TextConverterQuality
+	Req     RequestConverterFunc
+	Resp    ResponseConverterFunc
+}
+
+var (
+	imageConverterMu sync.RWMutex
+	imageConverters  = make(map[string]ImageConverterSpec)
+)
+
+// builtinImageConverters 以 OpenAI Images 为中心，对接 Gemini 图像模型的 generateContent 与 Imagen 的 predict。
+// generateContent 转 OpenAI Images 时只保留最后一条用户消息的文本，多轮上下文与参考图丢失，因此标为 fair
+var builtinImageConverters = []ImageConverterSpec{
+	{
+		ID:      ConverterOpenAIImageToGeminiImage,
+		From:    types.RelayFormatOpenAIImage,
+		To:      types.RelayFormatGemini,
+		Quality: TextConverterQualityGood,
+		Req:     convertOpenAIImageRequestToGemini,
+		Resp:    convertOAIImageResponseToGeminiImage,
+	},
+	{
+		ID:      ConverterGeminiImageToOpenAIImage,
+		From:    types.RelayFormatGemini,
+		To:      types.RelayFormatOpenAIImage,
+		Quality: TextConverterQualityFair,
+		Req:     convertGeminiImageRequestToOpenAI,
+		Resp:    convertGeminiImageResponseToOAIImage,
+	},
+	{
+		ID:      ConverterOpenAIImageToImagen,
+		From:    types.RelayFormatOpenAIImage,
+		To:      types.RelayFormatGeminiImagen,
+		Quality: TextConverterQualityGood,
+		Req:     convertOpenAIImageRequestToImagen,
+		Resp:    convertOAIImageResponseToImagen,
+	},
+	{
+		ID:      ConverterImagenToOpenAIImage,
+		From:    types.RelayFormatGeminiImagen,
+		To:      types.RelayFormatOpenAIImage,
+		Quality: TextConverterQualityGood,
+		Req:     convertImagenRequestToOpenAI,
+		Resp:    convertImagenResponseToOAIImage,
+	},
+}
+
+func registerBuiltinImageConverters() {
+	for _, spec := range builtinImageConverters {
+		registerBuiltinImageConverter(spec)
+	}
+}
+
+func LookupImageConverter(converter string) (ImageConverterSpec, bool) {
+	imageConverterMu.RLock()
+	defer imageConverterMu.RUnlock()
+
+	spec, ok := imageConverters[strings.TrimSpace(converter)]
+	return spec, ok
+}
+
+func registerBuiltinImageConverter(spec ImageConverterSpec) {
+	spec.ID = strings.TrimSpace(spec.ID)
+	if spec.ID == "" {
+		panic("image converter ID is required")
+	}
+	if spec.From == "" || spec.To == "" {
+		panic(fmt.Sprintf("image converter %q must declare from and to formats", spec.ID))
+	}
+	if spec.Quality == "" {
+		panic(fmt.Sprintf("image converter %q must declare quality", spec.ID))
+	}
+	if spec.Req == nil || spec.Resp == nil {
+		panic(fmt.Sprintf("image converter %q must declare request and response conversion", spec.ID))
+	}
+	if _, exists := imageConverters[spec.ID]; exists {
+		panic(fmt.Sprintf("image converter %q is already registered", spec.ID))
+	}
+
+	registerBuiltinRequestConverter(RequestConverterSpec{
+		ID:      spec.ID,
+		From:    spec.From,
+		To:      spec.To,
+		Quality: RequestConverterQuality(spec.Quality),
+		Convert: spec.Req,
+	})
+	registerBuiltinResponseConverter(ResponseConverterSpec{
+		ID:      spec.ID,
+		From:    spec.From,
+		To:      spec.To,
+		Quality: ResponseConverterQuality(spec.Quality),
+		Convert: spec.Resp,
+	})
+	imageConverters[spec.ID] = spec
+}
@@ -0,0 +1,198 @@
+package relayconvert
+
+import (
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// pngBase64 PNG 文件头的 base64，用于识别 MIME 类型
+const pngBase64 = "iVBORw0KGgoAAAANSUhEUgAAAAEAAAAB"
+
+func TestLookupBuiltinImageConverters(t *testing.T) {
+	for _, expected := range builtinImageConverters {
+		spec, ok := LookupImageConverter(expected.ID)
+		require.True(t, ok, expected.ID)
+		assert.Equal(t, expected.From, spec.From)
+		assert.Equal(t, expected.To, spec.To)
+
+		requestSpec, ok := LookupRequestConverter(expected.ID)
+		require.True(t, ok, expected.ID)
+		assert.Equal(t, RequestConverterQuality(expected.Quality), requestSpec.Quality)
+
+		responseSpec, ok := LookupResponseConverter(expected.ID)
+		require.True(t, ok, expected.ID)
+		assert.Equal(t, ResponseConverterQuality(expected.Quality), responseSpec.Quality)
+	}
+
+	_, ok := LookupImageConverter(ConverterOpenAIEmbedToGeminiEmbed)
+	assert.False(t, ok)
+}
+
+func TestConvertImageRequestOpenAIToGemini(t *testing.T) {
+	result, err := ConvertRequest(nil, nil, types.RelayFormatGemini, &dto.ImageRequest{
+		Model:   "gemini-2.5-flash-image",
+		Prompt:  "a cat",
+		Size:    "1536x1024",
+		Quality: "hd",
+		N:       common.GetPointer(uint(2)),
+	})
+	require.NoError(t, err)
+	geminiRequest := result.Value.(*dto.GeminiChatRequest)
+	require.Len(t, geminiRequest.Contents, 1)
+	assert.Equal(t, "user", geminiRequest.Contents[0].Role)
+	assert.Equal(t, "a cat", geminiRequest.Contents[0].Parts[0].Text)
+	assert.Equal(t, []string{"TEXT", "IMAGE"}, geminiRequest.GenerationConfig.ResponseModalities)
+	assert.JSONEq(t, `{"aspectRatio":"3:2","imageSize":"2K"}`, string(geminiRequest.GenerationConfig.ImageConfig))
+	require.NotNil(t, geminiRequest.GenerationConfig.CandidateCount)
+	assert.Equal(t, 2, *geminiRequest.GenerationConfig.CandidateCount)
+
+	result, err = ConvertRequest(nil, nil, types.RelayFormatGemini, &dto.ImageRequest{Prompt: "a cat"})
+	require.NoError(t, err)
+	geminiRequest = result.Value.(*dto.GeminiChatRequest)
+	assert.Empty(t, geminiRequest.GenerationConfig.ImageConfig)
+	assert.Nil(t, geminiRequest.GenerationConfig.CandidateCount)
+
+	_, err = ConvertRequest(nil, nil, types.RelayFormatGemini, &dto.ImageRequest{Prompt: " "})
+	require.Error(t, err)
+}
+
+func TestConvertImageRequestOpenAIToImagen(t *testing.T) {
+	result, err := ConvertRequest(nil, nil, types.RelayFormatGeminiImagen, &dto.ImageRequest{Prompt: "a cat"})
+	require.NoError(t, err)
+	imagenRequest := result.Value.(*dto.GeminiImageRequest)
+	assert.Equal(t, "a cat", imagenRequest.Instances[0].Prompt)
+	assert.Equal(t, 1, imagenRequest.Parameters.SampleCount)
+	assert.Equal(t, "1:1", imagenRequest.Parameters.AspectRatio)
+	assert.Equal(t, "allow_adult", imagenRequest.Parameters.PersonGeneration)
+	assert.Empty(t, imagenRequest.Parameters.ImageSize)
+
+	result, err = ConvertRequest(nil, nil, types.RelayFormatGeminiImagen, &dto.ImageRequest{
+		Prompt:  "a cat",
+		Size:    "1024x1792",
+		Quality: "low",
+		N:       common.GetPointer(uint(3)),
+	})
+	require.NoError(t, err)
+	imagenRequest = result.Value.(*dto.GeminiImageRequest)
+	assert.Equal(t, 3, imagenRequest.Parameters.SampleCount)
+	assert.Equal(t, "9:16", imagenRequest.Parameters.AspectRatio)
+	assert.Equal(t, "1K", imagenRequest.Parameters.ImageSize)
+}
+
+func TestConvertImageRequestGeminiToOpenAI(t *testing.T) {
+	request := &dto.GeminiChatRequest{
+		Contents: []dto.GeminiChatContent{
+			{Role: "user", Parts: []dto.GeminiPart{{Text: "draw a cat"}}},
+			{Role: "model", Parts: []dto.GeminiPart{{Text: "here it is"}}},
+			{Role: "user", Parts: []dto.GeminiPart{{Text: "make it blue"}}},
+		},
+	}
+	request.GenerationConfig.ImageConfig = []byte(`{"aspectRatio":"9:16","imageSize":"2K"}`)
+	request.GenerationConfig.CandidateCount = common.GetPointer(2)
+
+	info := &relaycommon.RelayInfo{ChannelMeta: &relaycommon.ChannelMeta{UpstreamModelName: "dall-e-3"}}
+	result, err := ConvertRequest(nil, info, types.RelayFormatOpenAIImage, request)
+	require.NoError(t, err)
+	imageRequest := result.Value.(*dto.ImageRequest)
+	assert.Equal(t, "dall-e-3", imageRequest.Model)
+	assert.Equal(t, "make it blue", imageRequest.Prompt)
+	assert.Equal(t, "1024x1792", imageRequest.Size)
+	assert.Equal(t, "hd", imageRequest.Quality)
+	assert.Equal(t, "b64_json", imageRequest.ResponseFormat)
+	require.NotNil(t, imageRequest.N)
+	assert.Equal(t, uint(2), *imageRequest.N)
+
+	info.UpstreamModelName = "gpt-image-1"
+	result, err = ConvertRequest(nil, info, types.RelayFormatOpenAIImage, request)
+	require.NoError(t, err)
+	imageRequest = result.Value.(*dto.ImageRequest)
+	assert.Equal(t, "1024x1536", imageRequest.Size)
+	assert.Equal(t, "high", imageRequest.Quality)
+	assert.Empty(t, imageRequest.ResponseFormat)
+}
+
+func TestConvertImageResponseGeminiToOpenAI(t *testing.T) {
+	response := &dto.GeminiChatResponse{
+		Candidates: []dto.GeminiChatCandidate{
+			{
+				Content: dto.GeminiChatContent{
+					Role: "model",
+					Parts: []dto.GeminiPart{
+						{Thought: true, InlineData: &dto.GeminiInlineData{MimeType: "image/png", Data: "draft"}},
+						{Text: "A blue cat"},
+						{InlineData: &dto.GeminiInlineData{MimeType: "image/png", Data: pngBase64}},
+					},
+				},
+			},
+		},
+		UsageMetadata: dto.GeminiUsageMetadata{PromptTokenCount: 5, CandidatesTokenCount: 1290, TotalTokenCount: 1295},
+	}
+
+	result, err := ConvertResponse(nil, nil, types.RelayFormatOpenAIImage, response)
+	require.NoError(t, err)
+	imageResponse := result.Value.(*dto.ImageResponse)
+	require.Len(t, imageResponse.Data, 1)
+	assert.Equal(t, pngBase64, imageResponse.Data[0].B64Json)
+	assert.Equal(t, "A blue cat", imageResponse.Data[0].RevisedPrompt)
+	require.NotNil(t, result.Usage)
+	assert.Equal(t, 5, result.Usage.PromptTokens)
+	assert.Equal(t, 1290, result.Usage.CompletionTokens)
+
+	info := &relaycommon.RelayInfo{Request: &dto.ImageRequest{ResponseFormat: "url"}}
+	result, err = ConvertResponse(nil, info, types.RelayFormatOpenAIImage, response)
+	require.NoError(t, err)
+	imageResponse = result.Value.(*dto.ImageResponse)
+	require.Len(t, imageResponse.Data, 1)
+	assert.Equal(t, "data:image/png;base64,"+pngBase64, imageResponse.Data[0].Url)
+	assert.Empty(t, imageResponse.Data[0].B64Json)
+}
+
+func TestConvertImageResponseOpenAIToGemini(t *testing.T) {
+	result, err := ConvertResponse(nil, nil, types.RelayFormatGemini, &dto.ImageResponse{
+		Data: []dto.ImageData{
+			{B64Json: pngBase64, RevisedPrompt: "a cat"},
+			{Url: "https://example.com/cat.png"},
+		},
+	})
+	require.NoError(t, err)
+	geminiResponse := result.Value.(*dto.GeminiChatResponse)
+	require.Len(t, geminiResponse.Candidates, 2)
+
+	first := geminiResponse.Candidates[0].Content.Parts
+	require.Len(t, first, 2)
+	assert.Equal(t, "a cat", first[0].Text)
+	require.NotNil(t, first[1].InlineData)
+	assert.Equal(t, "image/png", first[1].InlineData.MimeType)
+	assert.Equal(t, pngBase64, first[1].InlineData.Data)
+
+	second := geminiResponse.Candidates[1]
+	assert.Equal(t, int64(1), second.Index)
+	require.NotNil(t, second.Content.Parts[0].FileData)
+	assert.Equal(t, "https://example.com/cat.png", second.Content.Parts[0].FileData.FileUri)
+}
+
+func TestConvertImageResponseImagenToOpenAI(t *testing.T) {
+	response := &dto.GeminiImageResponse{
+		Predictions: []dto.GeminiImagePrediction{
+			{MimeType: "image/png", BytesBase64Encoded: pngBase64},
+			{RaiFilteredReason: "filtered"},
+		},
+	}
+
+	result, err := ConvertResponse(nil, nil, types.RelayFormatOpenAIImage, response)
+	require.NoError(t, err)
+	imageResponse := result.Value.(*dto.ImageResponse)
+	require.Len(t, imageResponse.Data, 1)
+	assert.Equal(t, pngBase64, imageResponse.Data[0].B64Json)
+
+	info := &relaycommon.RelayInfo{Request: &dto.ImageRequest{ResponseFormat: "url"}}
+	result, err = ConvertResponse(nil, info, types.RelayFormatOpenAIImage, response)
+	require.NoError(t, err)
+	assert.Equal(t, "data:image/png;base64,"+pngBase64, result.Value.(*dto.ImageResponse).Data[0].Url)
+}
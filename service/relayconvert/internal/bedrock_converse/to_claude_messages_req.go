@@ -0,0 +1,214 @@
+package bedrockconverse
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+)
+
+// bedrockAdditionalFields additionalModelRequestFields 中透传给 Claude 的字段
+type bedrockAdditionalFields struct {
+	TopK     *int          `json:"top_k,omitempty"`
+	Thinking *dto.Thinking `json:"thinking,omitempty"`
+}
+
+// BedrockConverseRequestToClaudeMessages 将 Bedrock Converse 请求转换为 Claude Messages 请求
+func BedrockConverseRequestToClaudeMessages(req *dto.BedrockConverseRequest) (*dto.ClaudeRequest, error) {
+	if req == nil {
+		return nil, errors.New("bedrock converse request is nil")
+	}
+	claudeRequest := &dto.ClaudeRequest{
+		Model:    req.ModelId,
+		Stream:   common.GetPointer(req.Stream),
+		Messages: make([]dto.ClaudeMessage, 0, len(req.Messages)),
+	}
+
+	if len(req.System) > 0 {
+		system := make([]dto.ClaudeMediaMessage, 0, len(req.System))
+		for _, block := range req.System {
+			if block.Text == nil {
+				continue
+			}
+			part := dto.ClaudeMediaMessage{Type: dto.ContentTypeText}
+			part.SetText(*block.Text)
+			system = append(system, part)
+		}
+		if len(system) > 0 {
+			claudeRequest.System = system
+		}
+	}
+
+	for i, message := range req.Messages {
+		content := make([]dto.ClaudeMediaMessage, 0, len(message.Content))
+		for _, block := range message.Content {
+			part, ok, err := bedrockContentBlockToClaude(block)
+			if err != nil {
+				return nil, fmt.Errorf("messages[%d]: %w", i, err)
+			}
+			if ok {
+				content = append(content, part)
+			}
+		}
+		claudeRequest.Messages = append(claudeRequest.Messages, dto.ClaudeMessage{
+			Role:    message.Role,
+			Content: content,
+		})
+	}
+
+	if cfg := req.InferenceConfig; cfg != nil {
+		if cfg.MaxTokens != nil && *cfg.MaxTokens > 0 {
+			claudeRequest.MaxTokens = common.GetPointer(uint(*cfg.MaxTokens))
+		}
+		claudeRequest.Temperature = cfg.Temperature
+		claudeRequest.TopP = cfg.TopP
+		claudeRequest.StopSequences = cfg.StopSequences
+	}
+
+	if cfg := req.ToolConfig; cfg != nil {
+		tools := make([]any, 0, len(cfg.Tools))
+		for _, tool := range cfg.Tools {
+			if tool.ToolSpec == nil {
+				continue
+			}
+			schema := map[string]any{}
+			if len(tool.ToolSpec.InputSchema.Json) > 0 {
+				if err := common.Unmarshal(tool.ToolSpec.InputSchema.Json, &schema); err != nil {
+					return nil, fmt.Errorf("invalid input schema for tool %s: %w", tool.ToolSpec.Name, err)
+				}
+			}
+			tools = append(tools, &dto.Tool{
+				Name:        tool.ToolSpec.Name,
+				Description: tool.ToolSpec.Description,
+				InputSchema: schema,
+			})
+		}
+		if len(tools) > 0 {
+			claudeRequest.Tools = tools
+		}
+		if choice := cfg.ToolChoice; choice != nil {
+			switch {
+			case choice.Tool != nil:
+				claudeRequest.ToolChoice = &dto.ClaudeToolChoice{Type: "tool", Name: choice.Tool.Name}
+			case choice.Any != nil:
+				claudeRequest.ToolChoice = &dto.ClaudeToolChoice{Type: "any"}
+			case choice.Auto != nil:
+				claudeRequest.ToolChoice = &dto.ClaudeToolChoice{Type: "auto"}
+			}
+		}
+	}
+
+	if len(req.AdditionalModelRequestFields) > 0 {
+		var fields bedrockAdditionalFields
+		if err := common.Unmarshal(req.AdditionalModelRequestFields, &fields); err != nil {
+			return nil, fmt.Errorf("invalid additionalModelRequestFields: %w", err)
+		}
+		claudeRequest.TopK = fields.TopK
+		claudeRequest.Thinking = fields.Thinking
+	}
+	return claudeRequest, nil
+}
+
+func bedrockContentBlockToClaude(block dto.BedrockConverseContentBlock) (dto.ClaudeMediaMessage, bool, error) {
+	switch {
+	case block.Text != nil:
+		part := dto.ClaudeMediaMessage{Type: dto.ContentTypeText}
+		part.SetText(*block.Text)
+		return part, true, nil
+	case block.Image != nil:
+		return bedrockImageToClaude(block.Image), true, nil
+	case block.Document != nil:
+		part, err := bedrockDocumentToClaude(block.Document)
+		return part, err == nil, err
+	case block.ToolUse != nil:
+		var input any = map[string]any{}
+		if len(block.ToolUse.Input) > 0 {
+			if err := common.Unmarshal(block.ToolUse.Input, &input); err != nil {
+				return dto.ClaudeMediaMessage{}, false, fmt.Errorf("invalid toolUse input: %w", err)
+			}
+		}
+		return dto.ClaudeMediaMessage{
+			Type:  "tool_use",
+			Id:    block.ToolUse.ToolUseId,
+			Name:  block.ToolUse.Name,
+			Input: input,
+		}, true, nil
+	case block.ToolResult != nil:
+		// Claude Messages 的 tool_result 没有对应 status 的字段，错误结果只保留内容
+		content := make([]dto.ClaudeMediaMessage, 0, len(block.ToolResult.Content))
+		for _, item := range block.ToolResult.Content {
+			switch {
+			case item.Text != nil:
+				part := dto.ClaudeMediaMessage{Type: dto.ContentTypeText}
+				part.SetText(*item.Text)
+				content = append(content, part)
+			case len(item.Json) > 0:
+				part := dto.ClaudeMediaMessage{Type: dto.ContentTypeText}
+				part.SetText(string(item.Json))
+				content = append(content, part)
+			case item.Image != nil:
+				content = append(content, bedrockImageToClaude(item.Image))
+			}
+		}
+		return dto.ClaudeMediaMessage{
+			Type:      "tool_result",
+			ToolUseId: block.ToolResult.ToolUseId,
+			Content:   content,
+		}, true, nil
+	case block.ReasoningContent != nil && block.ReasoningContent.ReasoningText != nil:
+		reasoning := block.ReasoningContent.ReasoningText
+		return dto.ClaudeMediaMessage{
+			Type:      "thinking",
+			Thinking:  common.GetPointer(reasoning.Text),
+			Signature: reasoning.Signature,
+		}, true, nil
+	}
+	return dto.ClaudeMediaMessage{}, false, nil
+}
+
+func bedrockImageToClaude(image *dto.BedrockConverseImageBlock) dto.ClaudeMediaMessage {
+	format := image.Format
+	if format == "jpg" {
+		format = "jpeg"
+	}
+	return dto.ClaudeMediaMessage{
+		Type: "image",
+		Source: &dto.ClaudeMessageSource{
+			Type:      "base64",
+			MediaType: "image/" + format,
+			Data:      image.Source.Bytes,
+		},
+	}
+}
+
+// bedrockDocumentToClaude PDF 以 base64 透传，文本类文档解码后作为纯文本来源
+func bedrockDocumentToClaude(document *dto.BedrockConverseDocumentBlock) (dto.ClaudeMediaMessage, error) {
+	switch document.Format {
+	case "pdf":
+		return dto.ClaudeMediaMessage{
+			Type: "document",
+			Source: &dto.ClaudeMessageSource{
+				Type:      "base64",
+				MediaType: "application/pdf",
+				Data:      document.Source.Bytes,
+			},
+		}, nil
+	case "txt", "md", "csv", "html":
+		data, err := base64.StdEncoding.DecodeString(document.Source.Bytes)
+		if err != nil {
+			return dto.ClaudeMediaMessage{}, fmt.Errorf("invalid document bytes: %w", err)
+		}
+		return dto.ClaudeMediaMessage{
+			Type: "document",
+			Source: &dto.ClaudeMessageSource{
+				Type:      "text",
+				MediaType: "text/plain",
+				Data:      string(data),
+			},
+		}, nil
+	default:
+		return dto.ClaudeMediaMessage{}, fmt.Errorf("unsupported document format %q", document.Format)
+	}
+}
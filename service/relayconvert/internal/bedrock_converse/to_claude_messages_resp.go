@@ -0,0 +1,54 @@
+package bedrockconverse
+
+import (
+	"fmt"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+)
+
+// BedrockConverseResponseToClaudeMessages 将 Bedrock Converse 非流式响应转换为 Claude Messages 响应
+func BedrockConverseResponseToClaudeMessages(resp *dto.BedrockConverseResponse) *dto.ClaudeResponse {
+	claudeResponse := &dto.ClaudeResponse{
+		Id:         fmt.Sprintf("msg_%s", common.GetUUID()),
+		Type:       "message",
+		Role:       "assistant",
+		Content:    make([]dto.ClaudeMediaMessage, 0),
+		StopReason: StopReasonBedrockToClaude(resp.StopReason),
+		Usage:      ClaudeUsageFromBedrock(&resp.Usage),
+	}
+	if resp.Output.Message == nil {
+		return claudeResponse
+	}
+	for _, block := range resp.Output.Message.Content {
+		if part, ok, err := bedrockContentBlockToClaude(block); err == nil && ok {
+			claudeResponse.Content = append(claudeResponse.Content, part)
+		}
+	}
+	return claudeResponse
+}
+
+// ClaudeUsageFromBedrock Bedrock 的 inputTokens 与 Claude 一致，不包含缓存读写部分
+func ClaudeUsageFromBedrock(usage *dto.BedrockConverseUsage) *dto.ClaudeUsage {
+	if usage == nil {
+		return &dto.ClaudeUsage{}
+	}
+	return &dto.ClaudeUsage{
+		InputTokens:              usage.InputTokens,
+		OutputTokens:             usage.OutputTokens,
+		CacheReadInputTokens:     usage.CacheReadInputTokens,
+		CacheCreationInputTokens: usage.CacheWriteInputTokens,
+	}
+}
+
+func StopReasonBedrockToClaude(reason string) string {
+	switch reason {
+	case "guardrail_intervened", "content_filtered":
+		return "refusal"
+	case "":
+		return "end_turn"
+	default:
+		// end_turn、tool_use、max_tokens、stop_sequence 两侧同名
+		return reason
+	}
+}
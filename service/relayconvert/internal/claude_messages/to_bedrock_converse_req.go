@@ -0,0 +1,182 @@
+package claudemessages
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+)
+
+// ClaudeMessagesRequestToBedrockConverse 将 Claude Messages 请求转换为 Bedrock Converse 请求
+func ClaudeMessagesRequestToBedrockConverse(claudeRequest dto.ClaudeRequest) (*dto.BedrockConverseRequest, error) {
+	converseRequest := &dto.BedrockConverseRequest{
+		ModelId:  claudeRequest.Model,
+		Stream:   claudeRequest.Stream != nil && *claudeRequest.Stream,
+		Messages: make([]dto.BedrockConverseMessage, 0, len(claudeRequest.Messages)),
+	}
+
+	if claudeRequest.System != nil {
+		if claudeRequest.IsStringSystem() {
+			if system := claudeRequest.GetStringSystem(); system != "" {
+				converseRequest.System = []dto.BedrockConverseSystemBlock{{Text: common.GetPointer(system)}}
+			}
+		} else {
+			for _, part := range claudeRequest.ParseSystem() {
+				if part.Type == dto.ContentTypeText && part.Text != nil {
+					converseRequest.System = append(converseRequest.System, dto.BedrockConverseSystemBlock{Text: part.Text})
+				}
+			}
+		}
+	}
+
+	for i, message := range claudeRequest.Messages {
+		converseMessage := dto.BedrockConverseMessage{Role: message.Role}
+		if message.IsStringContent() {
+			converseMessage.Content = []dto.BedrockConverseContentBlock{{Text: common.GetPointer(message.GetStringContent())}}
+			converseRequest.Messages = append(converseRequest.Messages, converseMessage)
+			continue
+		}
+		parts, err := message.ParseContent()
+		if err != nil {
+			return nil, fmt.Errorf("messages[%d]: %w", i, err)
+		}
+		for _, part := range parts {
+			block, ok, err := claudeContentToBedrock(part)
+			if err != nil {
+				return nil, fmt.Errorf("messages[%d]: %w", i, err)
+			}
+			if ok {
+				converseMessage.Content = append(converseMessage.Content, block)
+			}
+		}
+		converseRequest.Messages = append(converseRequest.Messages, converseMessage)
+	}
+
+	inferenceConfig := &dto.BedrockConverseInferenceConfig{
+		Temperature:   claudeRequest.Temperature,
+		TopP:          claudeRequest.TopP,
+		StopSequences: claudeRequest.StopSequences,
+	}
+	if claudeRequest.MaxTokens != nil && *claudeRequest.MaxTokens > 0 {
+		inferenceConfig.MaxTokens = common.GetPointer(int(*claudeRequest.MaxTokens))
+	}
+	converseRequest.InferenceConfig = inferenceConfig
+
+	normalTools, _ := dto.ProcessTools(claudeRequest.GetTools())
+	if len(normalTools) > 0 {
+		toolConfig := &dto.BedrockConverseToolConfig{}
+		for _, tool := range normalTools {
+			schema, err := common.Marshal(tool.InputSchema)
+			if err != nil {
+				return nil, fmt.Errorf("invalid input schema for tool %s: %w", tool.Name, err)
+			}
+			toolConfig.Tools = append(toolConfig.Tools, dto.BedrockConverseTool{
+				ToolSpec: &dto.BedrockConverseToolSpec{
+					Name:        tool.Name,
+					Description: tool.Description,
+					InputSchema: dto.BedrockConverseToolInputSchema{Json: schema},
+				},
+			})
+		}
+		if claudeRequest.ToolChoice != nil {
+			if choice, err := common.Any2Type[dto.ClaudeToolChoice](claudeRequest.ToolChoice); err == nil {
+				switch choice.Type {
+				case "auto":
+					toolConfig.ToolChoice = &dto.BedrockConverseToolChoice{Auto: &struct{}{}}
+				case "any":
+					toolConfig.ToolChoice = &dto.BedrockConverseToolChoice{Any: &struct{}{}}
+				case "tool":
+					toolConfig.ToolChoice = &dto.BedrockConverseToolChoice{Tool: &dto.BedrockConverseToolChoiceName{Name: choice.Name}}
+				}
+			}
+		}
+		converseRequest.ToolConfig = toolConfig
+	}
+
+	additional := map[string]any{}
+	if claudeRequest.TopK != nil {
+		additional["top_k"] = *claudeRequest.TopK
+	}
+	if claudeRequest.Thinking != nil {
+		additional["thinking"] = claudeRequest.Thinking
+	}
+	if len(additional) > 0 {
+		data, err := common.Marshal(additional)
+		if err != nil {
+			return nil, err
+		}
+		converseRequest.AdditionalModelRequestFields = data
+	}
+	return converseRequest, nil
+}
+
+func claudeContentToBedrock(part dto.ClaudeMediaMessage) (dto.BedrockConverseContentBlock, bool, error) {
+	switch part.Type {
+	case dto.ContentTypeText:
+		return dto.BedrockConverseContentBlock{Text: common.GetPointer(part.GetText())}, true, nil
+	case "image":
+		image, err := claudeImageToBedrock(part.Source)
+		if err != nil {
+			return dto.BedrockConverseContentBlock{}, false, err
+		}
+		return dto.BedrockConverseContentBlock{Image: image}, true, nil
+	case "document":
+		if part.Source == nil || part.Source.Type != "base64" || part.Source.MediaType != "application/pdf" {
+			return dto.BedrockConverseContentBlock{}, false, fmt.Errorf("bedrock converse only supports base64 pdf documents")
+		}
+		return dto.BedrockConverseContentBlock{Document: &dto.BedrockConverseDocumentBlock{
+			Format: "pdf",
+			Name:   "document",
+			Source: dto.BedrockConverseMediaSource{Bytes: common.Interface2String(part.Source.Data)},
+		}}, true, nil
+	case "tool_use":
+		input, err := common.Marshal(part.Input)
+		if err != nil || string(input) == "null" {
+			input = json.RawMessage("{}")
+		}
+		return dto.BedrockConverseContentBlock{ToolUse: &dto.BedrockConverseToolUseBlock{
+			ToolUseId: part.Id,
+			Name:      part.Name,
+			Input:     input,
+		}}, true, nil
+	case "tool_result":
+		result := &dto.BedrockConverseToolResultBlock{ToolUseId: part.ToolUseId}
+		if part.IsStringContent() {
+			result.Content = append(result.Content, dto.BedrockConverseToolResultContent{Text: common.GetPointer(part.GetStringContent())})
+		} else {
+			for _, item := range part.ParseMediaContent() {
+				switch item.Type {
+				case dto.ContentTypeText:
+					result.Content = append(result.Content, dto.BedrockConverseToolResultContent{Text: common.GetPointer(item.GetText())})
+				case "image":
+					image, err := claudeImageToBedrock(item.Source)
+					if err != nil {
+						return dto.BedrockConverseContentBlock{}, false, err
+					}
+					result.Content = append(result.Content, dto.BedrockConverseToolResultContent{Image: image})
+				}
+			}
+		}
+		return dto.BedrockConverseContentBlock{ToolResult: result}, true, nil
+	case "thinking":
+		if part.Thinking == nil {
+			return dto.BedrockConverseContentBlock{}, false, nil
+		}
+		return dto.BedrockConverseContentBlock{ReasoningContent: &dto.BedrockConverseReasoningContent{
+			ReasoningText: &dto.BedrockConverseReasoningText{Text: *part.Thinking, Signature: part.Signature},
+		}}, true, nil
+	}
+	return dto.BedrockConverseContentBlock{}, false, nil
+}
+
+func claudeImageToBedrock(source *dto.ClaudeMessageSource) (*dto.BedrockConverseImageBlock, error) {
+	if source == nil || source.Type != "base64" {
+		return nil, fmt.Errorf("bedrock converse only supports base64 image sources")
+	}
+	return &dto.BedrockConverseImageBlock{
+		Format: strings.TrimPrefix(source.MediaType, "image/"),
+		Source: dto.BedrockConverseMediaSource{Bytes: common.Interface2String(source.Data)},
+	}, nil
+}
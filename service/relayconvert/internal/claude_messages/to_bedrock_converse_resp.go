@@ -0,0 +1,175 @@
+package claudemessages
+
+import (
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+)
+
+// ClaudeToBedrockConverseStreamState 记录流式用量与停止原因，messageStop 与 metadata 事件在流结束时统一输出
+type ClaudeToBedrockConverseStreamState struct {
+	Usage *dto.ClaudeUsage
+
+	startedAt  time.Time
+	started    bool
+	stopReason string
+	finalized  bool
+}
+
+func NewClaudeToBedrockConverseStreamState() *ClaudeToBedrockConverseStreamState {
+	return &ClaudeToBedrockConverseStreamState{
+		Usage:     &dto.ClaudeUsage{},
+		startedAt: time.Now(),
+	}
+}
+
+// ResponseClaude2BedrockConverse 将 Claude Messages 非流式响应转换为 Bedrock Converse 响应
+func ResponseClaude2BedrockConverse(claudeResponse *dto.ClaudeResponse) *dto.BedrockConverseResponse {
+	message := &dto.BedrockConverseMessage{Role: "assistant", Content: make([]dto.BedrockConverseContentBlock, 0, len(claudeResponse.Content))}
+	for _, part := range claudeResponse.Content {
+		if block, ok, err := claudeContentToBedrock(part); err == nil && ok {
+			message.Content = append(message.Content, block)
+		}
+	}
+	return &dto.BedrockConverseResponse{
+		Output:     dto.BedrockConverseOutput{Message: message},
+		StopReason: StopReasonClaudeToBedrock(claudeResponse.StopReason),
+		Usage:      BedrockUsageFromClaude(claudeResponse.Usage),
+	}
+}
+
+// StreamResponseClaude2BedrockConverse 将一个 Claude 流式事件转换为零个或多个 ConverseStream 事件
+func StreamResponseClaude2BedrockConverse(claudeResponse *dto.ClaudeResponse, state *ClaudeToBedrockConverseStreamState) []*dto.BedrockConverseStreamEvent {
+	index := common.GetPointer(claudeResponse.GetIndex())
+	switch claudeResponse.Type {
+	case "message_start":
+		state.started = true
+		if claudeResponse.Message != nil && claudeResponse.Message.Usage != nil {
+			mergeClaudeStreamUsage(state.Usage, claudeResponse.Message.Usage)
+		}
+		return []*dto.BedrockConverseStreamEvent{{EventType: dto.BedrockConverseEventMessageStart, Role: "assistant"}}
+	case "content_block_start":
+		block := claudeResponse.ContentBlock
+		if block == nil {
+			return nil
+		}
+		switch block.Type {
+		case "tool_use":
+			return []*dto.BedrockConverseStreamEvent{{
+				EventType:         dto.BedrockConverseEventContentBlockStart,
+				ContentBlockIndex: index,
+				Start: &dto.BedrockConverseContentBlockStart{
+					ToolUse: &dto.BedrockConverseToolUseStart{ToolUseId: block.Id, Name: block.Name},
+				},
+			}}
+		case dto.ContentTypeText:
+			// Bedrock 的文本块没有 start 事件，起始文本直接作为 delta 输出
+			if text := block.GetText(); text != "" {
+				return []*dto.BedrockConverseStreamEvent{textDeltaEvent(index, text)}
+			}
+		}
+		return nil
+	case "content_block_delta":
+		delta := claudeResponse.Delta
+		if delta == nil {
+			return nil
+		}
+		event := &dto.BedrockConverseStreamEvent{EventType: dto.BedrockConverseEventContentBlockDelta, ContentBlockIndex: index}
+		switch delta.Type {
+		case "text_delta":
+			return []*dto.BedrockConverseStreamEvent{textDeltaEvent(index, delta.GetText())}
+		case "input_json_delta":
+			if delta.PartialJson == nil {
+				return nil
+			}
+			event.Delta = &dto.BedrockConverseContentBlockDelta{ToolUse: &dto.BedrockConverseToolUseDelta{Input: *delta.PartialJson}}
+		case "thinking_delta":
+			if delta.Thinking == nil {
+				return nil
+			}
+			event.Delta = &dto.BedrockConverseContentBlockDelta{ReasoningContent: &dto.BedrockConverseReasoningContentDelta{Text: delta.Thinking}}
+		case "signature_delta":
+			event.Delta = &dto.BedrockConverseContentBlockDelta{ReasoningContent: &dto.BedrockConverseReasoningContentDelta{Signature: common.GetPointer(delta.Signature)}}
+		default:
+			return nil
+		}
+		return []*dto.BedrockConverseStreamEvent{event}
+	case "content_block_stop":
+		return []*dto.BedrockConverseStreamEvent{{EventType: dto.BedrockConverseEventContentBlockStop, ContentBlockIndex: index}}
+	case "message_delta":
+		if claudeResponse.Delta != nil && claudeResponse.Delta.StopReason != nil {
+			state.stopReason = *claudeResponse.Delta.StopReason
+		}
+		if claudeResponse.Usage != nil {
+			mergeClaudeStreamUsage(state.Usage, claudeResponse.Usage)
+		}
+	}
+	return nil
+}
+
+// FinalizeClaude2BedrockConverseStream 输出 messageStop 与携带用量的 metadata 事件
+func FinalizeClaude2BedrockConverseStream(state *ClaudeToBedrockConverseStreamState) []*dto.BedrockConverseStreamEvent {
+	if state.finalized || !state.started {
+		return nil
+	}
+	state.finalized = true
+	usage := BedrockUsageFromClaude(state.Usage)
+	return []*dto.BedrockConverseStreamEvent{
+		{EventType: dto.BedrockConverseEventMessageStop, StopReason: StopReasonClaudeToBedrock(state.stopReason)},
+		{
+			EventType: dto.BedrockConverseEventMetadata,
+			Usage:     &usage,
+			Metrics:   &dto.BedrockConverseMetrics{LatencyMs: time.Since(state.startedAt).Milliseconds()},
+		},
+	}
+}
+
+func textDeltaEvent(index *int, text string) *dto.BedrockConverseStreamEvent {
+	return &dto.BedrockConverseStreamEvent{
+		EventType:         dto.BedrockConverseEventContentBlockDelta,
+		ContentBlockIndex: index,
+		Delta:             &dto.BedrockConverseContentBlockDelta{Text: common.GetPointer(text)},
+	}
+}
+
+// mergeClaudeStreamUsage message_start 携带输入用量，message_delta 携带累计输出用量，非零值覆盖
+func mergeClaudeStreamUsage(target *dto.ClaudeUsage, usage *dto.ClaudeUsage) {
+	if usage.InputTokens > 0 {
+		target.InputTokens = usage.InputTokens
+	}
+	if usage.OutputTokens > 0 {
+		target.OutputTokens = usage.OutputTokens
+	}
+	if usage.CacheReadInputTokens > 0 {
+		target.CacheReadInputTokens = usage.CacheReadInputTokens
+	}
+	if usage.CacheCreationInputTokens > 0 {
+		target.CacheCreationInputTokens = usage.CacheCreationInputTokens
+	}
+}
+
+func BedrockUsageFromClaude(usage *dto.ClaudeUsage) dto.BedrockConverseUsage {
+	if usage == nil {
+		return dto.BedrockConverseUsage{}
+	}
+	cacheWrite := usage.GetCacheCreationTotalTokens()
+	return dto.BedrockConverseUsage{
+		InputTokens:           usage.InputTokens,
+		OutputTokens:          usage.OutputTokens,
+		TotalTokens:           usage.InputTokens + usage.CacheReadInputTokens + cacheWrite + usage.OutputTokens,
+		CacheReadInputTokens:  usage.CacheReadInputTokens,
+		CacheWriteInputTokens: cacheWrite,
+	}
+}
+
+func StopReasonClaudeToBedrock(reason string) string {
+	switch reason {
+	case "refusal":
+		return "content_filtered"
+	case "", "pause_turn":
+		return "end_turn"
+	default:
+		return reason
+	}
+}
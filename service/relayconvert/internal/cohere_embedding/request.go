@@ -0,0 +1,27 @@
+package cohereembedding
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/QuantumNous/new-api/dto"
+)
+
+// validateRequest 只支持文本输入与 float 向量，其余 embedding_types 无法由 float 上游结果还原
+func validateRequest(req *dto.CohereEmbedRequest) error {
+	if req == nil {
+		return errors.New("cohere embed request is nil")
+	}
+	if len(req.Images) > 0 {
+		return errors.New("images are not supported for embedding conversion")
+	}
+	if len(req.Texts) == 0 {
+		return errors.New("texts is required")
+	}
+	for _, embeddingType := range req.EmbeddingTypes {
+		if strings.ToLower(strings.TrimSpace(embeddingType)) != "float" {
+			return errors.New("only float embedding type is supported for embedding conversion")
+		}
+	}
+	return nil
+}
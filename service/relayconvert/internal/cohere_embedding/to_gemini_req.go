@@ -0,0 +1,33 @@
+package cohereembedding
+
+import (
+	"strings"
+
+	"github.com/QuantumNous/new-api/dto"
+	embeddingshared "github.com/QuantumNous/new-api/service/relayconvert/internal/shared/embedding"
+)
+
+// CohereEmbedRequestToGeminiBatch 将 Cohere /embed 请求转换为 Gemini batchEmbedContents 请求，input_type 映射为 taskType
+func CohereEmbedRequestToGeminiBatch(req *dto.CohereEmbedRequest) (*dto.GeminiBatchEmbeddingRequest, error) {
+	if err := validateRequest(req); err != nil {
+		return nil, err
+	}
+	model := req.Model
+	if !strings.HasPrefix(model, "models/") {
+		model = "models/" + model
+	}
+	taskType := embeddingshared.CohereInputTypeToGeminiTaskType(req.InputType)
+	batch := &dto.GeminiBatchEmbeddingRequest{Requests: make([]*dto.GeminiEmbeddingRequest, 0, len(req.Texts))}
+	for _, text := range req.Texts {
+		geminiRequest := &dto.GeminiEmbeddingRequest{
+			Model:    model,
+			Content:  dto.GeminiChatContent{Parts: []dto.GeminiPart{{Text: text}}},
+			TaskType: taskType,
+		}
+		if req.OutputDimension != nil && *req.OutputDimension > 0 {
+			geminiRequest.OutputDimensionality = *req.OutputDimension
+		}
+		batch.Requests = append(batch.Requests, geminiRequest)
+	}
+	return batch, nil
+}
@@ -0,0 +1,25 @@
+package cohereembedding
+
+import (
+	"github.com/QuantumNous/new-api/dto"
+)
+
+// CohereEmbedResponseToGemini 将 Cohere /embed 响应转换为 Gemini 响应，batch 为 false 时按 embedContent 单条格式返回
+func CohereEmbedResponseToGemini(resp *dto.CohereEmbedResponse, batch bool) (any, error) {
+	vectors, err := resp.FloatEmbeddings()
+	if err != nil {
+		return nil, err
+	}
+	if !batch {
+		single := &dto.GeminiEmbeddingResponse{}
+		if len(vectors) > 0 {
+			single.Embedding.Values = vectors[0]
+		}
+		return single, nil
+	}
+	batchResponse := &dto.GeminiBatchEmbeddingResponse{Embeddings: make([]*dto.ContentEmbedding, 0, len(vectors))}
+	for _, vector := range vectors {
+		batchResponse.Embeddings = append(batchResponse.Embeddings, &dto.ContentEmbedding{Values: vector})
+	}
+	return batchResponse, nil
+}
@@ -0,0 +1,26 @@
+package cohereembedding
+
+import (
+	"github.com/QuantumNous/new-api/dto"
+	embeddingshared "github.com/QuantumNous/new-api/service/relayconvert/internal/shared/embedding"
+)
+
+// CohereEmbedRequestToOpenAI 将 Cohere /embed 请求转换为 OpenAI Embeddings 请求，input_type 按 Gemini 取值保留在 TaskType 中
+func CohereEmbedRequestToOpenAI(req *dto.CohereEmbedRequest) (*dto.EmbeddingRequest, error) {
+	if err := validateRequest(req); err != nil {
+		return nil, err
+	}
+	inputs := make([]any, 0, len(req.Texts))
+	for _, text := range req.Texts {
+		inputs = append(inputs, text)
+	}
+	embeddingRequest := &dto.EmbeddingRequest{
+		Model:    req.Model,
+		Input:    inputs,
+		TaskType: embeddingshared.CohereInputTypeToGeminiTaskType(req.InputType),
+	}
+	if req.OutputDimension != nil && *req.OutputDimension > 0 {
+		embeddingRequest.Dimensions = req.OutputDimension
+	}
+	return embeddingRequest, nil
+}
@@ -0,0 +1,36 @@
+package cohereembedding
+
+import (
+	"github.com/QuantumNous/new-api/dto"
+)
+
+// CohereEmbedResponseToOpenAI 将 Cohere /embed 响应转换为 OpenAI Embeddings 响应，用量取自 billed_units.input_tokens
+func CohereEmbedResponseToOpenAI(resp *dto.CohereEmbedResponse, model string) (*dto.EmbeddingResponse, error) {
+	vectors, err := resp.FloatEmbeddings()
+	if err != nil {
+		return nil, err
+	}
+	embeddingResponse := &dto.EmbeddingResponse{
+		Object: "list",
+		Data:   make([]dto.EmbeddingResponseItem, 0, len(vectors)),
+		Model:  model,
+		Usage:  *UsageFromCohereEmbed(resp),
+	}
+	for i, vector := range vectors {
+		embeddingResponse.Data = append(embeddingResponse.Data, dto.EmbeddingResponseItem{
+			Object:    "embedding",
+			Index:     i,
+			Embedding: vector,
+		})
+	}
+	return embeddingResponse, nil
+}
+
+// UsageFromCohereEmbed 向量接口只有输入用量
+func UsageFromCohereEmbed(resp *dto.CohereEmbedResponse) *dto.Usage {
+	inputTokens := resp.InputTokens()
+	return &dto.Usage{
+		PromptTokens: inputTokens,
+		TotalTokens:  inputTokens,
+	}
+}
@@ -0,0 +1,73 @@
+package geminiembedding
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/QuantumNous/new-api/dto"
+)
+
+// geminiEmbeddingInput 单条与批量请求统一展开后的输入，任务类型与维度取第一个非空值
+type geminiEmbeddingInput struct {
+	model      string
+	texts      []string
+	taskType   string
+	dimensions int
+}
+
+// requestsOf 把 embedContent 与 batchEmbedContents 请求统一为子请求列表
+func requestsOf(request any) ([]*dto.GeminiEmbeddingRequest, error) {
+	switch req := request.(type) {
+	case *dto.GeminiEmbeddingRequest:
+		return []*dto.GeminiEmbeddingRequest{req}, nil
+	case dto.GeminiEmbeddingRequest:
+		return []*dto.GeminiEmbeddingRequest{&req}, nil
+	case *dto.GeminiBatchEmbeddingRequest:
+		return req.Requests, nil
+	case dto.GeminiBatchEmbeddingRequest:
+		return req.Requests, nil
+	default:
+		return nil, fmt.Errorf("expected Gemini embedding request, got %T", request)
+	}
+}
+
+func collectInput(request any) (*geminiEmbeddingInput, error) {
+	requests, err := requestsOf(request)
+	if err != nil {
+		return nil, err
+	}
+	input := &geminiEmbeddingInput{texts: make([]string, 0, len(requests))}
+	for i, req := range requests {
+		if req == nil {
+			return nil, fmt.Errorf("requests[%d] is nil", i)
+		}
+		// 同一 content 的多个 part 在 Gemini 中合并为一个向量
+		texts := make([]string, 0, len(req.Content.Parts))
+		for _, part := range req.Content.Parts {
+			if part.InlineData != nil || part.FileData != nil {
+				return nil, fmt.Errorf("requests[%d]: only text parts are supported for embedding conversion", i)
+			}
+			if part.Text != "" {
+				texts = append(texts, part.Text)
+			}
+		}
+		if len(texts) == 0 {
+			return nil, fmt.Errorf("requests[%d]: content is empty", i)
+		}
+		input.texts = append(input.texts, strings.Join(texts, "\n"))
+		if input.model == "" {
+			input.model = strings.TrimPrefix(req.Model, "models/")
+		}
+		if input.taskType == "" {
+			input.taskType = req.TaskType
+		}
+		if input.dimensions == 0 {
+			input.dimensions = req.OutputDimensionality
+		}
+	}
+	if len(input.texts) == 0 {
+		return nil, errors.New("requests is empty")
+	}
+	return input, nil
+}
@@ -0,0 +1,35 @@
+package geminiembedding
+
+import (
+	"fmt"
+
+	"github.com/QuantumNous/new-api/dto"
+)
+
+// vectorsOf 把 embedContent 与 batchEmbedContents 响应统一为向量列表
+func vectorsOf(response any) ([][]float64, error) {
+	switch resp := response.(type) {
+	case *dto.GeminiEmbeddingResponse:
+		return [][]float64{resp.Embedding.Values}, nil
+	case dto.GeminiEmbeddingResponse:
+		return [][]float64{resp.Embedding.Values}, nil
+	case *dto.GeminiBatchEmbeddingResponse:
+		return batchVectors(resp), nil
+	case dto.GeminiBatchEmbeddingResponse:
+		return batchVectors(&resp), nil
+	default:
+		return nil, fmt.Errorf("expected Gemini embedding response, got %T", response)
+	}
+}
+
+func batchVectors(resp *dto.GeminiBatchEmbeddingResponse) [][]float64 {
+	vectors := make([][]float64, 0, len(resp.Embeddings))
+	for _, embedding := range resp.Embeddings {
+		if embedding == nil {
+			vectors = append(vectors, nil)
+			continue
+		}
+		vectors = append(vectors, embedding.Values)
+	}
+	return vectors
+}
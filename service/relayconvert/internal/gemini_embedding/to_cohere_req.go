@@ -0,0 +1,25 @@
+package geminiembedding
+
+import (
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	embeddingshared "github.com/QuantumNous/new-api/service/relayconvert/internal/shared/embedding"
+)
+
+// GeminiEmbeddingRequestToCohere 将 Gemini 向量请求转换为 Cohere v2 /embed 请求，taskType 映射为 input_type
+func GeminiEmbeddingRequestToCohere(request any) (*dto.CohereEmbedRequest, error) {
+	input, err := collectInput(request)
+	if err != nil {
+		return nil, err
+	}
+	cohereRequest := &dto.CohereEmbedRequest{
+		Model:          input.model,
+		Texts:          input.texts,
+		InputType:      embeddingshared.GeminiTaskTypeToCohereInputType(input.taskType),
+		EmbeddingTypes: []string{"float"},
+	}
+	if input.dimensions > 0 {
+		cohereRequest.OutputDimension = common.GetPointer(input.dimensions)
+	}
+	return cohereRequest, nil
+}
@@ -0,0 +1,19 @@
+package geminiembedding
+
+import (
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+)
+
+// GeminiEmbeddingResponseToCohere 转换为 Cohere embeddings_by_type 响应，不携带 billed_units
+func GeminiEmbeddingResponseToCohere(response any) (*dto.CohereEmbedResponse, error) {
+	vectors, err := vectorsOf(response)
+	if err != nil {
+		return nil, err
+	}
+	cohereResponse := &dto.CohereEmbedResponse{Id: common.GetUUID()}
+	if err := cohereResponse.SetFloatEmbeddings(vectors, true); err != nil {
+		return nil, err
+	}
+	return cohereResponse, nil
+}
@@ -0,0 +1,29 @@
+package geminiembedding
+
+import (
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	embeddingshared "github.com/QuantumNous/new-api/service/relayconvert/internal/shared/embedding"
+)
+
+// GeminiEmbeddingRequestToOpenAI 将 Gemini embedContent/batchEmbedContents 请求转换为 OpenAI Embeddings 请求，
+// taskType 保留在 TaskType 中供 Gemini/Cohere 上游使用，title 没有对应字段被丢弃
+func GeminiEmbeddingRequestToOpenAI(request any) (*dto.EmbeddingRequest, error) {
+	input, err := collectInput(request)
+	if err != nil {
+		return nil, err
+	}
+	inputs := make([]any, 0, len(input.texts))
+	for _, text := range input.texts {
+		inputs = append(inputs, text)
+	}
+	embeddingRequest := &dto.EmbeddingRequest{
+		Model:    input.model,
+		Input:    inputs,
+		TaskType: embeddingshared.NormalizeGeminiTaskType(input.taskType),
+	}
+	if input.dimensions > 0 {
+		embeddingRequest.Dimensions = common.GetPointer(input.dimensions)
+	}
+	return embeddingRequest, nil
+}
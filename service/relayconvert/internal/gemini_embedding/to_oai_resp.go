@@ -0,0 +1,26 @@
+package geminiembedding
+
+import (
+	"github.com/QuantumNous/new-api/dto"
+)
+
+// GeminiEmbeddingResponseToOpenAI Gemini 向量响应不返回用量，Usage 为空，由调用方按请求估算
+func GeminiEmbeddingResponseToOpenAI(response any, model string) (*dto.EmbeddingResponse, error) {
+	vectors, err := vectorsOf(response)
+	if err != nil {
+		return nil, err
+	}
+	embeddingResponse := &dto.EmbeddingResponse{
+		Object: "list",
+		Data:   make([]dto.EmbeddingResponseItem, 0, len(vectors)),
+		Model:  model,
+	}
+	for i, vector := range vectors {
+		embeddingResponse.Data = append(embeddingResponse.Data, dto.EmbeddingResponseItem{
+			Object:    "embedding",
+			Index:     i,
+			Embedding: vector,
+		})
+	}
+	return embeddingResponse, nil
+}
@@ -0,0 +1,57 @@
+package geminiimage
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	imageshared "github.com/QuantumNous/new-api/service/relayconvert/internal/shared/image"
+)
+
+// GeminiChatRequestToImageRequest 将请求图像输出的 generateContent 请求转换为 OpenAI Images 生成请求。
+// 只取最后一条用户消息的文本作为 prompt，多轮上下文与输入图片无法保留
+func GeminiChatRequestToImageRequest(req *dto.GeminiChatRequest, model string) (*dto.ImageRequest, error) {
+	if req == nil {
+		return nil, errors.New("gemini request is nil")
+	}
+	prompt := lastUserPrompt(req.Contents)
+	if prompt == "" {
+		return nil, errors.New("prompt is required")
+	}
+
+	imageConfig := imageshared.ParseGeminiImageConfig(req.GenerationConfig.ImageConfig)
+	imageRequest := &dto.ImageRequest{
+		Model:   model,
+		Prompt:  prompt,
+		Size:    imageshared.AspectRatioToSize(imageConfig.AspectRatio, model),
+		Quality: imageshared.ImageSizeToQuality(imageConfig.ImageSize, model),
+	}
+	if req.GenerationConfig.CandidateCount != nil && *req.GenerationConfig.CandidateCount > 1 {
+		imageRequest.N = common.GetPointer(uint(*req.GenerationConfig.CandidateCount))
+	}
+	// dall-e 默认返回 URL，generateContent 需要内联图片；gpt-image 始终返回 base64 且不接受该参数
+	if imageshared.IsDallE(model) {
+		imageRequest.ResponseFormat = imageshared.ResponseFormatB64JSON
+	}
+	return imageRequest, nil
+}
+
+func lastUserPrompt(contents []dto.GeminiChatContent) string {
+	for i := len(contents) - 1; i >= 0; i-- {
+		content := contents[i]
+		if content.Role != "" && content.Role != "user" {
+			continue
+		}
+		texts := make([]string, 0, len(content.Parts))
+		for _, part := range content.Parts {
+			if text := strings.TrimSpace(part.Text); text != "" {
+				texts = append(texts, text)
+			}
+		}
+		if len(texts) > 0 {
+			return strings.Join(texts, "\n")
+		}
+	}
+	return ""
+}
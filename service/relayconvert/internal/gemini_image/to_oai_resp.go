@@ -0,0 +1,48 @@
+package geminiimage
+
+import (
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	imageshared "github.com/QuantumNous/new-api/service/relayconvert/internal/shared/image"
+)
+
+// GeminiChatResponseToImageResponse 提取各候选中的内联图片（跳过思考过程中的草稿图），
+// responseFormat 为 url 时以 data URL 返回，候选中的文本作为 revised_prompt
+func GeminiChatResponseToImageResponse(resp *dto.GeminiChatResponse, responseFormat string) *dto.ImageResponse {
+	imageResponse := &dto.ImageResponse{
+		Created: common.GetTimestamp(),
+		Data:    make([]dto.ImageData, 0, len(resp.Candidates)),
+	}
+	for _, candidate := range resp.Candidates {
+		texts := make([]string, 0)
+		images := make([]dto.ImageData, 0, 1)
+		for _, part := range candidate.Content.Parts {
+			if part.Thought {
+				continue
+			}
+			if part.Text != "" {
+				texts = append(texts, part.Text)
+				continue
+			}
+			if part.InlineData == nil || part.InlineData.Data == "" {
+				continue
+			}
+			if part.InlineData.MimeType != "" && !strings.HasPrefix(part.InlineData.MimeType, "image/") {
+				continue
+			}
+			image := dto.ImageData{B64Json: part.InlineData.Data}
+			if responseFormat == imageshared.ResponseFormatURL {
+				image = dto.ImageData{Url: imageshared.DataURL(part.InlineData.MimeType, part.InlineData.Data)}
+			}
+			images = append(images, image)
+		}
+		revisedPrompt := strings.TrimSpace(strings.Join(texts, "\n"))
+		for i := range images {
+			images[i].RevisedPrompt = revisedPrompt
+		}
+		imageResponse.Data = append(imageResponse.Data, images...)
+	}
+	return imageResponse
+}
@@ -0,0 +1,32 @@
+package imagen
+
+import (
+	"errors"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	imageshared "github.com/QuantumNous/new-api/service/relayconvert/internal/shared/image"
+)
+
+// ImagenRequestToImageRequest 将 Imagen predict 请求转换为 OpenAI Images 生成请求，多个 instance 时只取第一个
+func ImagenRequestToImageRequest(req *dto.GeminiImageRequest, model string) (*dto.ImageRequest, error) {
+	if req == nil {
+		return nil, errors.New("imagen request is nil")
+	}
+	if len(req.Instances) == 0 || req.Instances[0].Prompt == "" {
+		return nil, errors.New("prompt is required")
+	}
+	imageRequest := &dto.ImageRequest{
+		Model:   model,
+		Prompt:  req.Instances[0].Prompt,
+		Size:    imageshared.AspectRatioToSize(req.Parameters.AspectRatio, model),
+		Quality: imageshared.ImageSizeToQuality(req.Parameters.ImageSize, model),
+	}
+	if req.Parameters.SampleCount > 0 {
+		imageRequest.N = common.GetPointer(uint(req.Parameters.SampleCount))
+	}
+	if imageshared.IsDallE(model) {
+		imageRequest.ResponseFormat = imageshared.ResponseFormatB64JSON
+	}
+	return imageRequest, nil
+}
@@ -0,0 +1,30 @@
+package imagen
+
+import (
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	imageshared "github.com/QuantumNous/new-api/service/relayconvert/internal/shared/image"
+)
+
+// ImagenResponseToImageResponse 将 Imagen predict 响应转换为 OpenAI Images 响应，被安全策略过滤的图片会被跳过
+func ImagenResponseToImageResponse(resp *dto.GeminiImageResponse, responseFormat string) *dto.ImageResponse {
+	imageResponse := &dto.ImageResponse{
+		Created: common.GetTimestamp(),
+		Data:    make([]dto.ImageData, 0, len(resp.Predictions)),
+	}
+	for _, prediction := range resp.Predictions {
+		if prediction.RaiFilteredReason != "" {
+			continue // skip filtered image
+		}
+		if responseFormat == imageshared.ResponseFormatURL {
+			imageResponse.Data = append(imageResponse.Data, dto.ImageData{
+				Url: imageshared.DataURL(prediction.MimeType, prediction.BytesBase64Encoded),
+			})
+			continue
+		}
+		imageResponse.Data = append(imageResponse.Data, dto.ImageData{
+			B64Json: prediction.BytesBase64Encoded,
+		})
+	}
+	return imageResponse
+}
@@ -0,0 +1,82 @@
+package oaichat
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/QuantumNous/new-api/dto"
+)
+
+// OpenAIChatRequestToCompletions 将 OpenAI Chat Completions 请求压平为旧版 /v1/completions 请求：
+// 只有一条用户消息时直接作为 prompt，多轮对话按 "Role: 内容" 逐段拼接并以 "Assistant:" 结尾引导续写，图片与工具调用无法表达而被丢弃
+func OpenAIChatRequestToCompletions(req *dto.GeneralOpenAIRequest) (*dto.CompletionsRequest, error) {
+	if req == nil {
+		return nil, errors.New("openai chat request is nil")
+	}
+	if len(req.Messages) == 0 {
+		return nil, errors.New("openai chat request has no messages")
+	}
+
+	var prompt string
+	if len(req.Messages) == 1 && req.Messages[0].Role == "user" {
+		prompt = chatMessageText(req.Messages[0])
+	} else {
+		var builder strings.Builder
+		for _, msg := range req.Messages {
+			text := chatMessageText(msg)
+			if text == "" {
+				continue
+			}
+			builder.WriteString(completionsRoleLabel(msg.Role))
+			builder.WriteString(": ")
+			builder.WriteString(text)
+			builder.WriteString("\n\n")
+		}
+		builder.WriteString("Assistant:")
+		prompt = builder.String()
+	}
+
+	maxTokens := req.MaxTokens
+	if maxTokens == nil {
+		maxTokens = req.MaxCompletionTokens
+	}
+	return &dto.CompletionsRequest{
+		Model:            req.Model,
+		Prompt:           prompt,
+		Stream:           req.Stream,
+		StreamOptions:    req.StreamOptions,
+		MaxTokens:        maxTokens,
+		Temperature:      req.Temperature,
+		TopP:             req.TopP,
+		Stop:             req.Stop,
+		N:                req.N,
+		FrequencyPenalty: req.FrequencyPenalty,
+		PresencePenalty:  req.PresencePenalty,
+		Seed:             req.Seed,
+		LogitBias:        req.LogitBias,
+		User:             req.User,
+	}, nil
+}
+
+func chatMessageText(msg dto.Message) string {
+	var builder strings.Builder
+	for _, part := range msg.ParseContent() {
+		if part.Type == dto.ContentTypeText {
+			builder.WriteString(part.Text)
+		}
+	}
+	return builder.String()
+}
+
+func completionsRoleLabel(role string) string {
+	switch role {
+	case "system", "developer":
+		return "System"
+	case "assistant":
+		return "Assistant"
+	case "tool":
+		return "Tool"
+	default:
+		return "User"
+	}
+}
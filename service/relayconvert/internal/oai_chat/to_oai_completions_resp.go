@@ -0,0 +1,122 @@
+package oaichat
+
+import (
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+)
+
+// ResponseOpenAIChat2Completions 将 OpenAI Chat Completions 非流式响应转换为 text_completion 响应，推理内容与工具调用不输出
+func ResponseOpenAIChat2Completions(resp *dto.OpenAITextResponse) *dto.CompletionsResponse {
+	usage := resp.Usage
+	completionsResponse := &dto.CompletionsResponse{
+		Id:      completionsID(resp.Id),
+		Object:  "text_completion",
+		Created: completionsCreated(resp.Created),
+		Model:   resp.Model,
+		Choices: make([]dto.CompletionsChoice, 0, len(resp.Choices)),
+		Usage:   &usage,
+	}
+	for _, choice := range resp.Choices {
+		completionsChoice := dto.CompletionsChoice{
+			Text:         choice.Message.StringContent(),
+			Index:        choice.Index,
+			FinishReason: common.GetPointer(common.GetStringIfEmpty(choice.FinishReason, "stop")),
+		}
+		if logprobs := chatLogprobsToCompletions(choice.Logprobs); logprobs != nil {
+			completionsChoice.Logprobs = logprobs
+		}
+		completionsResponse.Choices = append(completionsResponse.Choices, completionsChoice)
+	}
+	return completionsResponse
+}
+
+// StreamResponseOpenAIChat2Completions 将一个 chat.completion.chunk 转换为 text_completion 分片，
+// 只有角色或推理内容的分片没有对应输出，返回 nil
+func StreamResponseOpenAIChat2Completions(chunk *dto.ChatCompletionsStreamResponse) *dto.CompletionsResponse {
+	completionsChunk := &dto.CompletionsResponse{
+		Id:                completionsID(chunk.Id),
+		Object:            "text_completion",
+		Created:           completionsCreated(chunk.Created),
+		Model:             chunk.Model,
+		SystemFingerprint: chunk.SystemFingerprint,
+		Choices:           make([]dto.CompletionsChoice, 0, len(chunk.Choices)),
+		Usage:             chunk.Usage,
+	}
+	for _, choice := range chunk.Choices {
+		text := choice.Delta.GetContentString()
+		finished := choice.FinishReason != nil && *choice.FinishReason != ""
+		if text == "" && !finished {
+			continue
+		}
+		completionsChoice := dto.CompletionsChoice{Text: text, Index: choice.Index}
+		if choice.Logprobs != nil {
+			if logprobs := chatLogprobsToCompletions(*choice.Logprobs); logprobs != nil {
+				completionsChoice.Logprobs = logprobs
+			}
+		}
+		if finished {
+			completionsChoice.FinishReason = choice.FinishReason
+		}
+		completionsChunk.Choices = append(completionsChunk.Choices, completionsChoice)
+	}
+	if len(completionsChunk.Choices) == 0 && completionsChunk.Usage == nil {
+		return nil
+	}
+	return completionsChunk
+}
+
+// chatLogprobsToCompletions 把 chat 的 logprobs.content 转换为 text_completion 的 logprobs，
+// text_offset 从本段文本开头计算，流式分片由调用方按已输出的文本累加。无法识别时返回 nil
+func chatLogprobsToCompletions(logprobs any) *dto.CompletionsLogprobs {
+	if logprobs == nil {
+		return nil
+	}
+	var chatLogprobs dto.ChatLogprobs
+	data, err := common.Marshal(logprobs)
+	if err != nil || common.Unmarshal(data, &chatLogprobs) != nil || len(chatLogprobs.Content) == 0 {
+		return nil
+	}
+	result := &dto.CompletionsLogprobs{
+		Tokens:        make([]string, 0, len(chatLogprobs.Content)),
+		TokenLogprobs: make([]float64, 0, len(chatLogprobs.Content)),
+		TopLogprobs:   make([]map[string]float64, 0, len(chatLogprobs.Content)),
+		TextOffset:    make([]int, 0, len(chatLogprobs.Content)),
+	}
+	offset := 0
+	for _, token := range chatLogprobs.Content {
+		top := make(map[string]float64, len(token.TopLogprobs))
+		for _, candidate := range token.TopLogprobs {
+			top[candidate.Token] = candidate.Logprob
+		}
+		result.Tokens = append(result.Tokens, token.Token)
+		result.TokenLogprobs = append(result.TokenLogprobs, token.Logprob)
+		result.TopLogprobs = append(result.TopLogprobs, top)
+		result.TextOffset = append(result.TextOffset, offset)
+		offset += utf8.RuneCountInString(token.Token)
+	}
+	return result
+}
+
+func completionsID(id string) string {
+	if id == "" {
+		return "cmpl-" + common.GetUUID()
+	}
+	return "cmpl-" + strings.TrimPrefix(id, "chatcmpl-")
+}
+
+func completionsCreated(created any) int64 {
+	switch value := created.(type) {
+	case int64:
+		return value
+	case int:
+		return int64(value)
+	case float64:
+		return int64(value)
+	default:
+		return time.Now().Unix()
+	}
+}
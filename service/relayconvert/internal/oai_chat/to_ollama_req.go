@@ -0,0 +1,94 @@
+package oaichat
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+)
+
+// OpenAIChatRequestToOllamaChat 将 OpenAI Chat Completions 请求转换为 Ollama /api/chat 请求
+func OpenAIChatRequestToOllamaChat(req *dto.GeneralOpenAIRequest) (*dto.OllamaChatRequest, error) {
+	if req == nil {
+		return nil, errors.New("openai chat request is nil")
+	}
+	ollamaRequest := &dto.OllamaChatRequest{
+		Model:    req.Model,
+		Messages: make([]dto.OllamaMessage, 0, len(req.Messages)),
+		Tools:    req.Tools,
+		Stream:   common.GetPointer(req.IsStream(nil)),
+		Think:    req.Think,
+	}
+
+	toolNames := make(map[string]string)
+	for _, msg := range req.Messages {
+		ollamaMessage := dto.OllamaMessage{Role: msg.Role}
+		if msg.ReasoningContent != nil {
+			ollamaMessage.Thinking = *msg.ReasoningContent
+		}
+		for _, part := range msg.ParseContent() {
+			switch part.Type {
+			case dto.ContentTypeText:
+				ollamaMessage.Content += part.Text
+			case dto.ContentTypeImageURL:
+				if image := part.GetImageMedia(); image != nil && image.Url != "" {
+					ollamaMessage.Images = append(ollamaMessage.Images, stripDataURLPrefix(image.Url))
+				}
+			}
+		}
+		for _, call := range msg.ParseToolCalls() {
+			toolNames[call.ID] = call.Function.Name
+			arguments := json.RawMessage(call.Function.Arguments)
+			if !json.Valid(arguments) {
+				arguments = json.RawMessage("{}")
+			}
+			ollamaMessage.ToolCalls = append(ollamaMessage.ToolCalls, dto.OllamaToolCall{
+				Function: dto.OllamaToolCallFunction{Name: call.Function.Name, Arguments: arguments},
+			})
+		}
+		if msg.Role == "tool" {
+			ollamaMessage.ToolName = toolNames[msg.ToolCallId]
+		}
+		ollamaRequest.Messages = append(ollamaRequest.Messages, ollamaMessage)
+	}
+
+	options := &dto.OllamaOptions{
+		Temperature:      req.Temperature,
+		TopP:             req.TopP,
+		TopK:             req.TopK,
+		Seed:             req.Seed,
+		Stop:             req.Stop,
+		FrequencyPenalty: req.FrequencyPenalty,
+		PresencePenalty:  req.PresencePenalty,
+	}
+	if maxTokens := req.GetMaxTokens(); maxTokens > 0 {
+		options.NumPredict = common.GetPointer(int(maxTokens))
+	}
+	ollamaRequest.Options = options
+
+	if req.ResponseFormat != nil {
+		switch req.ResponseFormat.Type {
+		case "json_object":
+			ollamaRequest.Format = json.RawMessage(`"json"`)
+		case "json_schema":
+			var schema struct {
+				Schema json.RawMessage `json:"schema"`
+			}
+			if err := common.Unmarshal(req.ResponseFormat.JsonSchema, &schema); err == nil && len(schema.Schema) > 0 {
+				ollamaRequest.Format = schema.Schema
+			}
+		}
+	}
+	return ollamaRequest, nil
+}
+
+func stripDataURLPrefix(url string) string {
+	if strings.HasPrefix(url, "data:") {
+		if idx := strings.Index(url, ","); idx >= 0 {
+			return url[idx+1:]
+		}
+	}
+	return url
+}
@@ -0,0 +1,185 @@
+package oaichat
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/QuantumNous/new-api/dto"
+)
+
+// OpenAIChatToOllamaStreamState 累积流式工具调用与用量，Ollama 的工具调用以完整对象下发，需等到结束再输出
+type OpenAIChatToOllamaStreamState struct {
+	Model string
+	Usage *dto.Usage
+
+	doneReason string
+	toolCalls  map[int]*openAIChatToOllamaToolCall
+	finalized  bool
+}
+
+type openAIChatToOllamaToolCall struct {
+	Name      string
+	Arguments strings.Builder
+}
+
+func NewOpenAIChatToOllamaStreamState(model string) *OpenAIChatToOllamaStreamState {
+	return &OpenAIChatToOllamaStreamState{
+		Model:     model,
+		Usage:     &dto.Usage{},
+		toolCalls: make(map[int]*openAIChatToOllamaToolCall),
+	}
+}
+
+// ResponseOpenAIChat2Ollama 将 OpenAI Chat Completions 非流式响应转换为 Ollama /api/chat 响应
+func ResponseOpenAIChat2Ollama(resp *dto.OpenAITextResponse) *dto.OllamaChatResponse {
+	ollamaResponse := &dto.OllamaChatResponse{
+		Model:           resp.Model,
+		CreatedAt:       ollamaTimestamp(),
+		Message:         dto.OllamaMessage{Role: "assistant"},
+		Done:            true,
+		DoneReason:      "stop",
+		PromptEvalCount: resp.Usage.PromptTokens,
+		EvalCount:       resp.Usage.CompletionTokens,
+	}
+	if len(resp.Choices) == 0 {
+		return ollamaResponse
+	}
+	choice := resp.Choices[0]
+	ollamaResponse.Message.Content = choice.Message.StringContent()
+	if choice.Message.ReasoningContent != nil {
+		ollamaResponse.Message.Thinking = *choice.Message.ReasoningContent
+	} else if choice.Message.Reasoning != nil {
+		ollamaResponse.Message.Thinking = *choice.Message.Reasoning
+	}
+	var toolCalls []dto.ToolCallResponse
+	if len(choice.Message.ToolCalls) > 0 {
+		_ = json.Unmarshal(choice.Message.ToolCalls, &toolCalls)
+	}
+	for _, call := range toolCalls {
+		ollamaResponse.Message.ToolCalls = append(ollamaResponse.Message.ToolCalls, ollamaToolCall(call.Function.Name, call.Function.Arguments))
+	}
+	ollamaResponse.DoneReason = ollamaDoneReason(choice.FinishReason)
+	return ollamaResponse
+}
+
+// StreamResponseOpenAIChat2Ollama 将一个 OpenAI 流式分片转换为零个或多个 Ollama NDJSON 分片
+func StreamResponseOpenAIChat2Ollama(chunk *dto.ChatCompletionsStreamResponse, state *OpenAIChatToOllamaStreamState) []*dto.OllamaChatResponse {
+	if state.Model == "" {
+		state.Model = chunk.Model
+	}
+	if chunk.Usage != nil {
+		state.Usage = chunk.Usage
+	}
+
+	var results []*dto.OllamaChatResponse
+	for _, choice := range chunk.Choices {
+		if choice.Index != 0 {
+			continue
+		}
+		delta := choice.Delta
+		thinking := ""
+		if delta.ReasoningContent != nil {
+			thinking = *delta.ReasoningContent
+		} else if delta.Reasoning != nil {
+			thinking = *delta.Reasoning
+		}
+		if content := delta.GetContentString(); content != "" || thinking != "" {
+			results = append(results, state.chunk(dto.OllamaMessage{Role: "assistant", Content: content, Thinking: thinking}))
+		}
+		for i, call := range delta.ToolCalls {
+			index := i
+			if call.Index != nil {
+				index = *call.Index
+			}
+			toolCall, ok := state.toolCalls[index]
+			if !ok {
+				toolCall = &openAIChatToOllamaToolCall{}
+				state.toolCalls[index] = toolCall
+			}
+			if call.Function.Name != "" {
+				toolCall.Name = call.Function.Name
+			}
+			toolCall.Arguments.WriteString(call.Function.Arguments)
+		}
+		if choice.FinishReason != nil && *choice.FinishReason != "" {
+			state.doneReason = ollamaDoneReason(*choice.FinishReason)
+			if toolChunk := state.flushToolCalls(); toolChunk != nil {
+				results = append(results, toolChunk)
+			}
+		}
+	}
+	return results
+}
+
+// FinalizeOpenAIChat2OllamaStream 输出 done=true 的最终分片，携带 done_reason 与 token 计数
+func FinalizeOpenAIChat2OllamaStream(state *OpenAIChatToOllamaStreamState) []*dto.OllamaChatResponse {
+	if state.finalized {
+		return nil
+	}
+	state.finalized = true
+
+	var results []*dto.OllamaChatResponse
+	if toolChunk := state.flushToolCalls(); toolChunk != nil {
+		results = append(results, toolChunk)
+	}
+	final := state.chunk(dto.OllamaMessage{Role: "assistant"})
+	final.Done = true
+	final.DoneReason = state.doneReason
+	if final.DoneReason == "" {
+		final.DoneReason = "stop"
+	}
+	if state.Usage != nil {
+		final.PromptEvalCount = state.Usage.PromptTokens
+		final.EvalCount = state.Usage.CompletionTokens
+	}
+	return append(results, final)
+}
+
+func (s *OpenAIChatToOllamaStreamState) chunk(message dto.OllamaMessage) *dto.OllamaChatResponse {
+	return &dto.OllamaChatResponse{
+		Model:     s.Model,
+		CreatedAt: ollamaTimestamp(),
+		Message:   message,
+	}
+}
+
+func (s *OpenAIChatToOllamaStreamState) flushToolCalls() *dto.OllamaChatResponse {
+	if len(s.toolCalls) == 0 {
+		return nil
+	}
+	indexes := make([]int, 0, len(s.toolCalls))
+	for index := range s.toolCalls {
+		indexes = append(indexes, index)
+	}
+	sort.Ints(indexes)
+
+	message := dto.OllamaMessage{Role: "assistant"}
+	for _, index := range indexes {
+		call := s.toolCalls[index]
+		message.ToolCalls = append(message.ToolCalls, ollamaToolCall(call.Name, call.Arguments.String()))
+	}
+	s.toolCalls = make(map[int]*openAIChatToOllamaToolCall)
+	return s.chunk(message)
+}
+
+func ollamaToolCall(name string, arguments string) dto.OllamaToolCall {
+	raw := json.RawMessage(arguments)
+	if strings.TrimSpace(arguments) == "" || !json.Valid(raw) {
+		raw = json.RawMessage("{}")
+	}
+	return dto.OllamaToolCall{Function: dto.OllamaToolCallFunction{Name: name, Arguments: raw}}
+}
+
+// ollamaDoneReason Ollama 只区分 stop 与 length，工具调用同样以 stop 结束
+func ollamaDoneReason(finishReason string) string {
+	if finishReason == "length" {
+		return "length"
+	}
+	return "stop"
+}
+
+func ollamaTimestamp() string {
+	return time.Now().UTC().Format(time.RFC3339Nano)
+}
@@ -0,0 +1,38 @@
+package oaicompletions
+
+import (
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/service/relayconvert/internal/matcher"
+	"github.com/QuantumNous/new-api/setting/model_setting"
+)
+
+// chatOnlyAPITypes 上游没有 /v1/completions 接口的渠道类型，补全请求总是转换为 Chat Completions
+var chatOnlyAPITypes = map[int]struct{}{
+	constant.APITypeAnthropic:  {},
+	constant.APITypeGemini:     {},
+	constant.APITypeVertexAi:   {},
+	constant.APITypeAws:        {},
+	constant.APITypeCohere:     {},
+	constant.APITypePerplexity: {},
+	constant.APITypeCodex:      {},
+}
+
+func ShouldCompletionsUseChatPolicy(policy model_setting.CompletionsToChatPolicy, channelID int, channelType int, apiType int, model string) bool {
+	if _, ok := chatOnlyAPITypes[apiType]; ok {
+		return true
+	}
+	if !policy.IsChannelEnabled(channelID, channelType) {
+		return false
+	}
+	return len(policy.ModelPatterns) == 0 || matcher.MatchAnyRegex(policy.ModelPatterns, model)
+}
+
+func ShouldCompletionsUseChatGlobal(channelID int, channelType int, apiType int, model string) bool {
+	return ShouldCompletionsUseChatPolicy(
+		model_setting.GetGlobalSettings().CompletionsToChatPolicy,
+		channelID,
+		channelType,
+		apiType,
+		model,
+	)
+}
@@ -0,0 +1,86 @@
+package oaicompletions
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/service/relayconvert/internal/matcher"
+	"github.com/QuantumNous/new-api/setting/model_setting"
+)
+
+// 没有匹配到模型专用模板时，用指令引导对话模型只输出 prefix 与 suffix 之间缺失的内容
+const (
+	defaultFIMSystemPrompt = "You are a code completion engine. Reply with only the text that belongs between <prefix> and <suffix>, " +
+		"without explanations, markdown code fences or repeating any of the surrounding text."
+	defaultFIMTemplate = "<prefix>{prefix}</prefix>\n<suffix>{suffix}</suffix>"
+)
+
+// CompletionsRequestToOpenAIChat 将旧版 /v1/completions 请求转换为单轮 Chat Completions 请求：
+// prompt 作为用户消息，带 suffix 时按模型匹配的 FIM 模板拼接。logprobs 对应 chat 的 logprobs/top_logprobs，
+// 响应侧再转回 text_completion 格式；best_of 大于 1 无法对应，直接拒绝。echo 由响应侧处理
+func CompletionsRequestToOpenAIChat(req *dto.CompletionsRequest, templates []model_setting.CompletionsFIMTemplate) (*dto.GeneralOpenAIRequest, error) {
+	if req == nil {
+		return nil, errors.New("completions request is nil")
+	}
+	prompt, ok := req.PromptText()
+	if !ok {
+		return nil, errors.New("only a single text prompt can be converted to chat completions")
+	}
+	if req.BestOf != nil && *req.BestOf > 1 {
+		return nil, errors.New("best_of is not supported when completions are served by a chat completions upstream")
+	}
+
+	messages := make([]dto.Message, 0, 2)
+	if req.Suffix == "" {
+		messages = append(messages, dto.Message{Role: "user", Content: prompt})
+	} else {
+		template := matchFIMTemplate(templates, req.Model)
+		if template.System != "" {
+			messages = append(messages, dto.Message{Role: "system", Content: template.System})
+		}
+		content := strings.NewReplacer("{prefix}", prompt, "{suffix}", req.Suffix).Replace(template.Template)
+		messages = append(messages, dto.Message{Role: "user", Content: content})
+	}
+
+	chatRequest := &dto.GeneralOpenAIRequest{
+		Model:            req.Model,
+		Messages:         messages,
+		Stream:           req.Stream,
+		StreamOptions:    req.StreamOptions,
+		MaxTokens:        req.MaxTokens,
+		Temperature:      req.Temperature,
+		TopP:             req.TopP,
+		Stop:             req.Stop,
+		N:                req.N,
+		FrequencyPenalty: req.FrequencyPenalty,
+		PresencePenalty:  req.PresencePenalty,
+		Seed:             req.Seed,
+		LogitBias:        req.LogitBias,
+		User:             req.User,
+	}
+	// 旧版 logprobs=N 表示返回每个 token 的概率及前 N 个候选，0 只返回 token 自身的概率
+	if req.LogProbs != nil {
+		chatRequest.LogProbs = json.RawMessage("true")
+		if *req.LogProbs > 0 {
+			chatRequest.TopLogProbs = req.LogProbs
+		}
+	}
+	return chatRequest, nil
+}
+
+func matchFIMTemplate(templates []model_setting.CompletionsFIMTemplate, model string) model_setting.CompletionsFIMTemplate {
+	for _, template := range templates {
+		if template.Template == "" {
+			continue
+		}
+		if matcher.MatchAnyRegex([]string{template.ModelPattern}, model) {
+			return template
+		}
+	}
+	return model_setting.CompletionsFIMTemplate{
+		System:   defaultFIMSystemPrompt,
+		Template: defaultFIMTemplate,
+	}
+}
@@ -0,0 +1,64 @@
+package oaicompletions
+
+import (
+	"strings"
+
+	"github.com/QuantumNous/new-api/dto"
+)
+
+// CompletionsResponseToOpenAIChat 将 text_completion 非流式响应转换为 OpenAI Chat Completions 响应
+func CompletionsResponseToOpenAIChat(resp *dto.CompletionsResponse) *dto.OpenAITextResponse {
+	chatResponse := &dto.OpenAITextResponse{
+		Id:      chatCompletionID(resp.Id),
+		Model:   resp.Model,
+		Object:  "chat.completion",
+		Created: resp.Created,
+		Choices: make([]dto.OpenAITextResponseChoice, 0, len(resp.Choices)),
+	}
+	for _, choice := range resp.Choices {
+		finishReason := "stop"
+		if choice.FinishReason != nil && *choice.FinishReason != "" {
+			finishReason = *choice.FinishReason
+		}
+		chatResponse.Choices = append(chatResponse.Choices, dto.OpenAITextResponseChoice{
+			Index:        choice.Index,
+			Message:      dto.Message{Role: "assistant", Content: choice.Text},
+			FinishReason: finishReason,
+		})
+	}
+	if resp.Usage != nil {
+		chatResponse.Usage = *resp.Usage
+	}
+	return chatResponse
+}
+
+// StreamResponseCompletionsToOpenAIChat 将一个 text_completion 流式分片转换为 chat.completion.chunk
+func StreamResponseCompletionsToOpenAIChat(chunk *dto.CompletionsResponse) *dto.ChatCompletionsStreamResponse {
+	chatChunk := &dto.ChatCompletionsStreamResponse{
+		Id:                chatCompletionID(chunk.Id),
+		Object:            "chat.completion.chunk",
+		Created:           chunk.Created,
+		Model:             chunk.Model,
+		SystemFingerprint: chunk.SystemFingerprint,
+		Choices:           make([]dto.ChatCompletionsStreamResponseChoice, 0, len(chunk.Choices)),
+		Usage:             chunk.Usage,
+	}
+	for _, choice := range chunk.Choices {
+		streamChoice := dto.ChatCompletionsStreamResponseChoice{
+			Index:        choice.Index,
+			FinishReason: choice.FinishReason,
+		}
+		if choice.Text != "" {
+			streamChoice.Delta.SetContentString(choice.Text)
+		}
+		chatChunk.Choices = append(chatChunk.Choices, streamChoice)
+	}
+	return chatChunk
+}
+
+func chatCompletionID(id string) string {
+	if id == "" || strings.HasPrefix(id, "chatcmpl-") {
+		return id
+	}
+	return "chatcmpl-" + strings.TrimPrefix(id, "cmpl-")
+}
@@ -0,0 +1,29 @@
+package oaiembedding
+
+import (
+	"errors"
+
+	"github.com/QuantumNous/new-api/dto"
+	embeddingshared "github.com/QuantumNous/new-api/service/relayconvert/internal/shared/embedding"
+)
+
+// EmbeddingRequestToCohere 将 OpenAI Embeddings 请求转换为 Cohere v2 /embed 请求，只请求 float 向量
+func EmbeddingRequestToCohere(req *dto.EmbeddingRequest) (*dto.CohereEmbedRequest, error) {
+	if req == nil {
+		return nil, errors.New("embedding request is nil")
+	}
+	inputs := req.ParseInput()
+	if len(inputs) == 0 {
+		return nil, errors.New("input is empty")
+	}
+	cohereRequest := &dto.CohereEmbedRequest{
+		Model:          req.Model,
+		Texts:          inputs,
+		InputType:      embeddingshared.GeminiTaskTypeToCohereInputType(req.TaskType),
+		EmbeddingTypes: []string{"float"},
+	}
+	if req.Dimensions != nil && *req.Dimensions > 0 {
+		cohereRequest.OutputDimension = req.Dimensions
+	}
+	return cohereRequest, nil
+}
@@ -0,0 +1,20 @@
+package oaiembedding
+
+import (
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+)
+
+// ResponseOpenAIEmbedding2Cohere 将 OpenAI Embeddings 响应转换为 Cohere embeddings_by_type 响应，输入用量写入 billed_units
+func ResponseOpenAIEmbedding2Cohere(resp *dto.EmbeddingResponse) (*dto.CohereEmbedResponse, error) {
+	cohereResponse := &dto.CohereEmbedResponse{
+		Id: common.GetUUID(),
+		Meta: &dto.CohereEmbedMeta{
+			BilledUnits: &dto.CohereEmbedBilledUnits{InputTokens: resp.Usage.PromptTokens},
+		},
+	}
+	if err := cohereResponse.SetFloatEmbeddings(sortedVectors(resp), true); err != nil {
+		return nil, err
+	}
+	return cohereResponse, nil
+}
@@ -0,0 +1,38 @@
+package oaiembedding
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/QuantumNous/new-api/dto"
+	embeddingshared "github.com/QuantumNous/new-api/service/relayconvert/internal/shared/embedding"
+)
+
+// EmbeddingRequestToGeminiBatch 将 OpenAI Embeddings 请求转换为 Gemini batchEmbedContents 请求，每条输入对应一个子请求
+func EmbeddingRequestToGeminiBatch(req *dto.EmbeddingRequest) (*dto.GeminiBatchEmbeddingRequest, error) {
+	if req == nil {
+		return nil, errors.New("embedding request is nil")
+	}
+	inputs := req.ParseInput()
+	if len(inputs) == 0 {
+		return nil, errors.New("input is empty")
+	}
+	model := req.Model
+	if !strings.HasPrefix(model, "models/") {
+		model = "models/" + model
+	}
+	taskType := embeddingshared.NormalizeGeminiTaskType(req.TaskType)
+	batch := &dto.GeminiBatchEmbeddingRequest{Requests: make([]*dto.GeminiEmbeddingRequest, 0, len(inputs))}
+	for _, input := range inputs {
+		geminiRequest := &dto.GeminiEmbeddingRequest{
+			Model:    model,
+			Content:  dto.GeminiChatContent{Parts: []dto.GeminiPart{{Text: input}}},
+			TaskType: taskType,
+		}
+		if req.Dimensions != nil && *req.Dimensions > 0 {
+			geminiRequest.OutputDimensionality = *req.Dimensions
+		}
+		batch.Requests = append(batch.Requests, geminiRequest)
+	}
+	return batch, nil
+}
@@ -0,0 +1,35 @@
+package oaiembedding
+
+import (
+	"sort"
+
+	"github.com/QuantumNous/new-api/dto"
+)
+
+// ResponseOpenAIEmbedding2Gemini 将 OpenAI Embeddings 响应转换为 Gemini 响应，batch 为 false 时按 embedContent 单条格式返回
+func ResponseOpenAIEmbedding2Gemini(resp *dto.EmbeddingResponse, batch bool) any {
+	vectors := sortedVectors(resp)
+	if !batch {
+		single := &dto.GeminiEmbeddingResponse{}
+		if len(vectors) > 0 {
+			single.Embedding.Values = vectors[0]
+		}
+		return single
+	}
+	batchResponse := &dto.GeminiBatchEmbeddingResponse{Embeddings: make([]*dto.ContentEmbedding, 0, len(vectors))}
+	for _, vector := range vectors {
+		batchResponse.Embeddings = append(batchResponse.Embeddings, &dto.ContentEmbedding{Values: vector})
+	}
+	return batchResponse
+}
+
+func sortedVectors(resp *dto.EmbeddingResponse) [][]float64 {
+	items := append([]dto.EmbeddingResponseItem(nil), resp.Data...)
+	sort.SliceStable(items, func(i, j int) bool { return items[i].Index < items[j].Index })
+
+	vectors := make([][]float64, 0, len(items))
+	for _, item := range items {
+		vectors = append(vectors, item.Embedding)
+	}
+	return vectors
+}
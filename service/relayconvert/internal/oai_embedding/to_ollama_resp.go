@@ -0,0 +1,23 @@
+package oaiembedding
+
+import (
+	"sort"
+
+	"github.com/QuantumNous/new-api/dto"
+)
+
+// ResponseOpenAIEmbedding2Ollama 将 OpenAI Embeddings 响应转换为 Ollama /api/embed 响应，向量按 index 排序
+func ResponseOpenAIEmbedding2Ollama(resp *dto.EmbeddingResponse) *dto.OllamaEmbedResponse {
+	items := append([]dto.EmbeddingResponseItem(nil), resp.Data...)
+	sort.SliceStable(items, func(i, j int) bool { return items[i].Index < items[j].Index })
+
+	embeddings := make([][]float64, 0, len(items))
+	for _, item := range items {
+		embeddings = append(embeddings, item.Embedding)
+	}
+	return &dto.OllamaEmbedResponse{
+		Model:           resp.Model,
+		Embeddings:      embeddings,
+		PromptEvalCount: resp.Usage.PromptTokens,
+	}
+}
@@ -0,0 +1,50 @@
+package oaiimage
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	imageshared "github.com/QuantumNous/new-api/service/relayconvert/internal/shared/image"
+
+	"github.com/samber/lo"
+)
+
+// ImageRequestToGeminiChat 将 OpenAI Images 生成请求转换为 Gemini 图像模型的 generateContent 请求，
+// size/quality 写入 imageConfig，n 映射为 candidateCount
+func ImageRequestToGeminiChat(req *dto.ImageRequest) (*dto.GeminiChatRequest, error) {
+	if req == nil {
+		return nil, errors.New("image request is nil")
+	}
+	if strings.TrimSpace(req.Prompt) == "" {
+		return nil, errors.New("prompt is required")
+	}
+
+	geminiRequest := &dto.GeminiChatRequest{
+		Contents: []dto.GeminiChatContent{
+			{
+				Role:  "user",
+				Parts: []dto.GeminiPart{{Text: req.Prompt}},
+			},
+		},
+	}
+	geminiRequest.GenerationConfig.ResponseModalities = []string{"TEXT", "IMAGE"}
+
+	imageConfig := imageshared.GeminiImageConfig{
+		AspectRatio: imageshared.SizeToAspectRatio(req.Size, imageshared.GeminiAspectRatios),
+		ImageSize:   imageshared.QualityToImageSize(req.Quality),
+	}
+	if imageConfig != (imageshared.GeminiImageConfig{}) {
+		data, err := common.Marshal(imageConfig)
+		if err != nil {
+			return nil, err
+		}
+		geminiRequest.GenerationConfig.ImageConfig = data
+	}
+
+	if n := lo.FromPtrOr(req.N, uint(1)); n > 1 {
+		geminiRequest.GenerationConfig.CandidateCount = common.GetPointer(int(n))
+	}
+	return geminiRequest, nil
+}
@@ -0,0 +1,42 @@
+package oaiimage
+
+import (
+	"github.com/QuantumNous/new-api/dto"
+	imageshared "github.com/QuantumNous/new-api/service/relayconvert/internal/shared/image"
+)
+
+// ResponseOpenAIImage2GeminiChat 将 OpenAI Images 响应转换为 generateContent 响应，每张图片对应一个候选，
+// base64 图片写入 inlineData，只有 URL 的图片写入 fileData
+func ResponseOpenAIImage2GeminiChat(resp *dto.ImageResponse) *dto.GeminiChatResponse {
+	finishReason := "STOP"
+	geminiResponse := &dto.GeminiChatResponse{
+		Candidates: make([]dto.GeminiChatCandidate, 0, len(resp.Data)),
+	}
+	for _, image := range resp.Data {
+		var part dto.GeminiPart
+		switch {
+		case image.B64Json != "":
+			part.InlineData = &dto.GeminiInlineData{
+				MimeType: imageshared.DetectMimeType(image.B64Json),
+				Data:     image.B64Json,
+			}
+		case image.Url != "":
+			part.FileData = &dto.GeminiFileData{FileUri: image.Url}
+		default:
+			continue
+		}
+		parts := []dto.GeminiPart{part}
+		if image.RevisedPrompt != "" {
+			parts = append([]dto.GeminiPart{{Text: image.RevisedPrompt}}, parts...)
+		}
+		geminiResponse.Candidates = append(geminiResponse.Candidates, dto.GeminiChatCandidate{
+			Content: dto.GeminiChatContent{
+				Role:  "model",
+				Parts: parts,
+			},
+			FinishReason: &finishReason,
+			Index:        int64(len(geminiResponse.Candidates)),
+		})
+	}
+	return geminiResponse
+}
@@ -0,0 +1,36 @@
+package oaiimage
+
+import (
+	"errors"
+
+	"github.com/QuantumNous/new-api/dto"
+	imageshared "github.com/QuantumNous/new-api/service/relayconvert/internal/shared/image"
+
+	"github.com/samber/lo"
+)
+
+// ImageRequestToImagen 将 OpenAI Images 生成请求转换为 Imagen predict 请求
+// https://ai.google.dev/gemini-api/docs/imagen
+func ImageRequestToImagen(req *dto.ImageRequest) (*dto.GeminiImageRequest, error) {
+	if req == nil {
+		return nil, errors.New("image request is nil")
+	}
+	aspectRatio := imageshared.SizeToAspectRatio(req.Size, imageshared.ImagenAspectRatios)
+	if aspectRatio == "" {
+		aspectRatio = "1:1"
+	}
+	return &dto.GeminiImageRequest{
+		Instances: []dto.GeminiImageInstance{
+			{
+				Prompt: req.Prompt,
+			},
+		},
+		Parameters: dto.GeminiImageParameters{
+			SampleCount:      int(lo.FromPtrOr(req.N, uint(1))),
+			AspectRatio:      aspectRatio,
+			PersonGeneration: "allow_adult", // default allow adult
+			// imageSize 仅 Standard 与 Ultra 模型支持
+			ImageSize: imageshared.QualityToImageSize(req.Quality),
+		},
+	}, nil
+}
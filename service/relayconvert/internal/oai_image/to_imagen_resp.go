@@ -0,0 +1,23 @@
+package oaiimage
+
+import (
+	"github.com/QuantumNous/new-api/dto"
+	imageshared "github.com/QuantumNous/new-api/service/relayconvert/internal/shared/image"
+)
+
+// ResponseOpenAIImage2Imagen 将 OpenAI Images 响应转换为 Imagen predict 响应，predict 只能返回内联图片，只有 URL 的图片会被丢弃
+func ResponseOpenAIImage2Imagen(resp *dto.ImageResponse) *dto.GeminiImageResponse {
+	imagenResponse := &dto.GeminiImageResponse{
+		Predictions: make([]dto.GeminiImagePrediction, 0, len(resp.Data)),
+	}
+	for _, image := range resp.Data {
+		if image.B64Json == "" {
+			continue
+		}
+		imagenResponse.Predictions = append(imagenResponse.Predictions, dto.GeminiImagePrediction{
+			MimeType:           imageshared.DetectMimeType(image.B64Json),
+			BytesBase64Encoded: image.B64Json,
+		})
+	}
+	return imagenResponse
+}
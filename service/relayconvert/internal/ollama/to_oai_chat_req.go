@@ -0,0 +1,208 @@
+package ollama
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+)
+
+// OllamaChatRequestToOpenAIChat 将 Ollama /api/chat 请求转换为 OpenAI Chat Completions 请求
+func OllamaChatRequestToOpenAIChat(req *dto.OllamaChatRequest) (*dto.GeneralOpenAIRequest, error) {
+	if req == nil {
+		return nil, errors.New("ollama chat request is nil")
+	}
+	messages, err := ollamaMessagesToOpenAI(req.Messages)
+	if err != nil {
+		return nil, err
+	}
+	openAIRequest := &dto.GeneralOpenAIRequest{
+		Model:    req.Model,
+		Messages: messages,
+		Tools:    req.Tools,
+	}
+	if err := applyOllamaGenerationParams(openAIRequest, req.Options, req.Format, req.Think, req.IsStream()); err != nil {
+		return nil, err
+	}
+	return openAIRequest, nil
+}
+
+// OllamaGenerateRequestToOpenAIChat 将 Ollama /api/generate 请求转换为单轮对话：system 为系统消息，prompt 与 images 为用户消息
+func OllamaGenerateRequestToOpenAIChat(req *dto.OllamaGenerateRequest) (*dto.GeneralOpenAIRequest, error) {
+	if req == nil {
+		return nil, errors.New("ollama generate request is nil")
+	}
+	messages := make([]dto.Message, 0, 2)
+	if req.System != "" {
+		messages = append(messages, dto.Message{Role: "system", Content: req.System})
+	}
+	messages = append(messages, ollamaUserMessage(req.Prompt, req.Images))
+
+	openAIRequest := &dto.GeneralOpenAIRequest{
+		Model:    req.Model,
+		Messages: messages,
+	}
+	if err := applyOllamaGenerationParams(openAIRequest, req.Options, req.Format, req.Think, req.IsStream()); err != nil {
+		return nil, err
+	}
+	return openAIRequest, nil
+}
+
+func ollamaMessagesToOpenAI(messages []dto.OllamaMessage) ([]dto.Message, error) {
+	result := make([]dto.Message, 0, len(messages))
+	// Ollama 的工具结果只带 tool_name，按调用顺序回填 OpenAI 需要的 tool_call_id
+	pendingCalls := make(map[string][]string)
+	callSeq := 0
+	for _, msg := range messages {
+		switch msg.Role {
+		case "assistant":
+			openAIMessage := dto.Message{Role: "assistant", Content: msg.Content}
+			if msg.Thinking != "" {
+				openAIMessage.ReasoningContent = common.GetPointer(msg.Thinking)
+			}
+			if len(msg.ToolCalls) > 0 {
+				toolCalls := make([]dto.ToolCallResponse, 0, len(msg.ToolCalls))
+				for _, call := range msg.ToolCalls {
+					callSeq++
+					id := fmt.Sprintf("call_%d", callSeq)
+					pendingCalls[call.Function.Name] = append(pendingCalls[call.Function.Name], id)
+					toolCalls = append(toolCalls, dto.ToolCallResponse{
+						ID:   id,
+						Type: "function",
+						Function: dto.FunctionResponse{
+							Name:      call.Function.Name,
+							Arguments: ollamaToolArgumentsString(call.Function.Arguments),
+						},
+					})
+				}
+				openAIMessage.SetToolCalls(toolCalls)
+			}
+			result = append(result, openAIMessage)
+		case "tool":
+			openAIMessage := dto.Message{Role: "tool", Content: msg.Content}
+			if ids := pendingCalls[msg.ToolName]; len(ids) > 0 {
+				openAIMessage.ToolCallId = ids[0]
+				pendingCalls[msg.ToolName] = ids[1:]
+			}
+			if msg.ToolName != "" {
+				openAIMessage.Name = common.GetPointer(msg.ToolName)
+			}
+			result = append(result, openAIMessage)
+		case "user":
+			result = append(result, ollamaUserMessage(msg.Content, msg.Images))
+		case "system", "developer":
+			result = append(result, dto.Message{Role: msg.Role, Content: msg.Content})
+		default:
+			return nil, fmt.Errorf("unsupported ollama message role %q", msg.Role)
+		}
+	}
+	return result, nil
+}
+
+func ollamaUserMessage(text string, images []string) dto.Message {
+	message := dto.Message{Role: "user"}
+	if len(images) == 0 {
+		message.SetStringContent(text)
+		return message
+	}
+	content := make([]dto.MediaContent, 0, len(images)+1)
+	if text != "" {
+		content = append(content, dto.MediaContent{Type: dto.ContentTypeText, Text: text})
+	}
+	for _, image := range images {
+		content = append(content, dto.MediaContent{
+			Type:     dto.ContentTypeImageURL,
+			ImageUrl: &dto.MessageImageUrl{Url: ollamaImageURL(image)},
+		})
+	}
+	message.SetMediaContent(content)
+	return message
+}
+
+// ollamaImageURL Ollama 图片为不带前缀的 base64，按文件头推断类型补全为 data URL
+func ollamaImageURL(image string) string {
+	image = strings.TrimSpace(image)
+	if strings.HasPrefix(image, "data:") || strings.HasPrefix(image, "http://") || strings.HasPrefix(image, "https://") {
+		return image
+	}
+	mimeType := "image/png"
+	switch {
+	case strings.HasPrefix(image, "/9j/"):
+		mimeType = "image/jpeg"
+	case strings.HasPrefix(image, "R0lGOD"):
+		mimeType = "image/gif"
+	case strings.HasPrefix(image, "UklGR"):
+		mimeType = "image/webp"
+	}
+	return "data:" + mimeType + ";base64," + image
+}
+
+func ollamaToolArgumentsString(arguments json.RawMessage) string {
+	switch common.GetJsonType(arguments) {
+	case "unknown", "null":
+		return "{}"
+	case "string":
+		var text string
+		if err := common.Unmarshal(arguments, &text); err == nil {
+			return text
+		}
+	}
+	return string(arguments)
+}
+
+func applyOllamaGenerationParams(req *dto.GeneralOpenAIRequest, options *dto.OllamaOptions, format json.RawMessage, think json.RawMessage, stream bool) error {
+	req.Stream = common.GetPointer(stream)
+	if stream {
+		req.StreamOptions = &dto.StreamOptions{IncludeUsage: true}
+	}
+	if options != nil {
+		req.Temperature = options.Temperature
+		req.TopP = options.TopP
+		req.TopK = options.TopK
+		req.Seed = options.Seed
+		req.Stop = options.Stop
+		req.FrequencyPenalty = options.FrequencyPenalty
+		req.PresencePenalty = options.PresencePenalty
+		// num_predict 为 -1/-2 表示不限制或填满上下文，此时不传 max_tokens
+		if options.NumPredict != nil && *options.NumPredict > 0 {
+			req.MaxTokens = common.GetPointer(uint(*options.NumPredict))
+		}
+	}
+
+	switch common.GetJsonType(format) {
+	case "unknown", "null":
+	case "string":
+		var value string
+		if err := common.Unmarshal(format, &value); err != nil {
+			return fmt.Errorf("invalid format: %w", err)
+		}
+		if value == "json" {
+			req.ResponseFormat = &dto.ResponseFormat{Type: "json_object"}
+		} else if value != "" {
+			return fmt.Errorf("unsupported format %q", value)
+		}
+	case "object":
+		schema, err := common.Marshal(map[string]any{
+			"name":   "response",
+			"schema": format,
+		})
+		if err != nil {
+			return err
+		}
+		req.ResponseFormat = &dto.ResponseFormat{Type: "json_schema", JsonSchema: schema}
+	default:
+		return errors.New("format must be \"json\" or a JSON schema object")
+	}
+
+	// think 为 high/medium/low 时映射为 reasoning_effort，布尔值交由上游默认行为处理
+	if common.GetJsonType(think) == "string" {
+		var effort string
+		if err := common.Unmarshal(think, &effort); err == nil {
+			req.ReasoningEffort = effort
+		}
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package ollama
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+)
+
+// OllamaChatResponseToOpenAIChat 将 Ollama /api/chat 非流式响应转换为 OpenAI Chat Completions 响应
+func OllamaChatResponseToOpenAIChat(resp *dto.OllamaChatResponse) *dto.OpenAITextResponse {
+	message := dto.Message{Role: "assistant", Content: resp.Message.Content}
+	if resp.Message.Thinking != "" {
+		message.ReasoningContent = common.GetPointer(resp.Message.Thinking)
+	}
+	finishReason := "stop"
+	if resp.DoneReason == "length" {
+		finishReason = "length"
+	}
+	if len(resp.Message.ToolCalls) > 0 {
+		toolCalls := make([]dto.ToolCallResponse, 0, len(resp.Message.ToolCalls))
+		for i, call := range resp.Message.ToolCalls {
+			toolCalls = append(toolCalls, dto.ToolCallResponse{
+				ID:   fmt.Sprintf("call_%d", i+1),
+				Type: "function",
+				Function: dto.FunctionResponse{
+					Name:      call.Function.Name,
+					Arguments: ollamaToolArgumentsString(call.Function.Arguments),
+				},
+			})
+		}
+		message.SetToolCalls(toolCalls)
+		finishReason = "tool_calls"
+	}
+
+	created := time.Now().Unix()
+	if t, err := time.Parse(time.RFC3339Nano, resp.CreatedAt); err == nil {
+		created = t.Unix()
+	}
+	return &dto.OpenAITextResponse{
+		Id:      fmt.Sprintf("chatcmpl-%s", common.GetUUID()),
+		Model:   resp.Model,
+		Object:  "chat.completion",
+		Created: created,
+		Choices: []dto.OpenAITextResponseChoice{{
+			Index:        0,
+			Message:      message,
+			FinishReason: finishReason,
+		}},
+		Usage: *UsageFromOllamaCounts(resp.PromptEvalCount, resp.EvalCount),
+	}
+}
+
+func UsageFromOllamaCounts(promptTokens int, completionTokens int) *dto.Usage {
+	return &dto.Usage{
+		PromptTokens:     promptTokens,
+		CompletionTokens: completionTokens,
+		TotalTokens:      promptTokens + completionTokens,
+	}
+}
@@ -0,0 +1,27 @@
+package ollama
+
+import (
+	"errors"
+
+	"github.com/QuantumNous/new-api/dto"
+)
+
+// OllamaEmbedRequestToOpenAIEmbedding 将 Ollama /api/embed 请求转换为 OpenAI Embeddings 请求
+func OllamaEmbedRequestToOpenAIEmbedding(req *dto.OllamaEmbedRequest) (*dto.EmbeddingRequest, error) {
+	if req == nil {
+		return nil, errors.New("ollama embed request is nil")
+	}
+	embeddingRequest := &dto.EmbeddingRequest{
+		Model:      req.Model,
+		Input:      req.Input,
+		Dimensions: req.Dimensions,
+	}
+	if req.Options != nil {
+		embeddingRequest.Seed = req.Options.Seed
+		embeddingRequest.Temperature = req.Options.Temperature
+		embeddingRequest.TopP = req.Options.TopP
+		embeddingRequest.FrequencyPenalty = req.Options.FrequencyPenalty
+		embeddingRequest.PresencePenalty = req.Options.PresencePenalty
+	}
+	return embeddingRequest, nil
+}
@@ -0,0 +1,62 @@
+package embedding
+
+import "strings"
+
+// Gemini taskType 取值，EmbeddingRequest.TaskType 统一使用这一套取值
+const (
+	GeminiTaskRetrievalQuery     = "RETRIEVAL_QUERY"
+	GeminiTaskRetrievalDocument  = "RETRIEVAL_DOCUMENT"
+	GeminiTaskSemanticSimilarity = "SEMANTIC_SIMILARITY"
+	GeminiTaskClassification     = "CLASSIFICATION"
+	GeminiTaskClustering         = "CLUSTERING"
+	GeminiTaskQuestionAnswering  = "QUESTION_ANSWERING"
+	GeminiTaskFactVerification   = "FACT_VERIFICATION"
+	GeminiTaskCodeRetrievalQuery = "CODE_RETRIEVAL_QUERY"
+)
+
+// Cohere input_type 取值，v3 及以上模型必须指定
+const (
+	CohereInputSearchDocument = "search_document"
+	CohereInputSearchQuery    = "search_query"
+	CohereInputClassification = "classification"
+	CohereInputClustering     = "clustering"
+)
+
+// GeminiTaskTypeToCohereInputType 查询类任务映射为 search_query，其余无对应取值的任务按文档入库处理
+func GeminiTaskTypeToCohereInputType(taskType string) string {
+	switch strings.ToUpper(strings.TrimSpace(taskType)) {
+	case GeminiTaskRetrievalQuery, GeminiTaskQuestionAnswering, GeminiTaskFactVerification, GeminiTaskCodeRetrievalQuery:
+		return CohereInputSearchQuery
+	case GeminiTaskClassification:
+		return CohereInputClassification
+	case GeminiTaskClustering:
+		return CohereInputClustering
+	default:
+		return CohereInputSearchDocument
+	}
+}
+
+// CohereInputTypeToGeminiTaskType 无法识别的 input_type 返回空串，由 Gemini 使用默认任务类型
+func CohereInputTypeToGeminiTaskType(inputType string) string {
+	switch strings.ToLower(strings.TrimSpace(inputType)) {
+	case CohereInputSearchQuery:
+		return GeminiTaskRetrievalQuery
+	case CohereInputSearchDocument:
+		return GeminiTaskRetrievalDocument
+	case CohereInputClassification:
+		return GeminiTaskClassification
+	case CohereInputClustering:
+		return GeminiTaskClustering
+	default:
+		return ""
+	}
+}
+
+// NormalizeGeminiTaskType 统一为大写并去掉 TASK_TYPE_UNSPECIFIED
+func NormalizeGeminiTaskType(taskType string) string {
+	taskType = strings.ToUpper(strings.TrimSpace(taskType))
+	if taskType == "TASK_TYPE_UNSPECIFIED" {
+		return ""
+	}
+	return taskType
+}
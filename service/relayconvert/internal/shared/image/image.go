@@ -0,0 +1,168 @@
+package image
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+)
+
+const (
+	ResponseFormatURL     = "url"
+	ResponseFormatB64JSON = "b64_json"
+
+	defaultMimeType = "image/png"
+)
+
+// GeminiAspectRatios generateContent 图像模型支持的宽高比
+var GeminiAspectRatios = []string{"1:1", "2:3", "3:2", "3:4", "4:3", "4:5", "5:4", "9:16", "16:9", "21:9"}
+
+// ImagenAspectRatios Imagen predict 支持的宽高比
+var ImagenAspectRatios = []string{"1:1", "3:4", "4:3", "9:16", "16:9"}
+
+// GeminiImageConfig generationConfig.imageConfig 中与 OpenAI Images 参数对应的字段
+type GeminiImageConfig struct {
+	AspectRatio string `json:"aspectRatio,omitempty"`
+	ImageSize   string `json:"imageSize,omitempty"`
+}
+
+func ParseGeminiImageConfig(raw json.RawMessage) GeminiImageConfig {
+	var config GeminiImageConfig
+	if len(raw) > 0 {
+		_ = common.Unmarshal(raw, &config)
+	}
+	return config
+}
+
+func IsDallE(model string) bool {
+	return strings.HasPrefix(model, "dall-e")
+}
+
+// SizeToAspectRatio 把 OpenAI size（如 1536x1024）换算为 supported 中最接近的宽高比，
+// 已是宽高比格式时原样保留，auto 或无法解析时返回空串由上游使用默认值
+func SizeToAspectRatio(size string, supported []string) string {
+	size = strings.TrimSpace(size)
+	if size == "" || size == "auto" {
+		return ""
+	}
+	if strings.Contains(size, ":") {
+		return size
+	}
+	width, height, ok := parseDimensions(size, "x")
+	if !ok {
+		return ""
+	}
+	target := float64(width) / float64(height)
+	best := ""
+	bestDiff := math.MaxFloat64
+	for _, ratio := range supported {
+		w, h, ok := parseDimensions(ratio, ":")
+		if !ok {
+			continue
+		}
+		if diff := math.Abs(float64(w)/float64(h) - target); diff < bestDiff {
+			best = ratio
+			bestDiff = diff
+		}
+	}
+	return best
+}
+
+// AspectRatioToSize 把宽高比换算为目标 OpenAI 模型支持的 size，dall-e-2 只支持正方形
+func AspectRatioToSize(aspectRatio string, model string) string {
+	width, height, ok := parseDimensions(strings.TrimSpace(aspectRatio), ":")
+	if !ok {
+		return ""
+	}
+	if IsDallE(model) && !strings.HasPrefix(model, "dall-e-3") {
+		return "1024x1024"
+	}
+	landscape, portrait := "1536x1024", "1024x1536"
+	if strings.HasPrefix(model, "dall-e-3") {
+		landscape, portrait = "1792x1024", "1024x1792"
+	}
+	ratio := float64(width) / float64(height)
+	switch {
+	case ratio > 1.1:
+		return landscape
+	case ratio < 0.9:
+		return portrait
+	default:
+		return "1024x1024"
+	}
+}
+
+// QualityToImageSize 把 OpenAI quality（dall-e-3 的 hd/standard，gpt-image 的 high/medium/low/auto）映射为 Gemini imageSize
+func QualityToImageSize(quality string) string {
+	switch strings.TrimSpace(quality) {
+	case "":
+		return ""
+	case "hd", "high", "2K":
+		return "2K"
+	case "4K":
+		return "4K"
+	default:
+		return "1K"
+	}
+}
+
+// ImageSizeToQuality 把 Gemini imageSize 映射为目标 OpenAI 模型的 quality，1K 使用模型默认值
+func ImageSizeToQuality(imageSize string, model string) string {
+	switch strings.ToUpper(strings.TrimSpace(imageSize)) {
+	case "2K", "4K":
+		if IsDallE(model) {
+			return "hd"
+		}
+		return "high"
+	default:
+		return ""
+	}
+}
+
+func DataURL(mimeType string, data string) string {
+	if mimeType == "" {
+		mimeType = DetectMimeType(data)
+	}
+	return fmt.Sprintf("data:%s;base64,%s", mimeType, data)
+}
+
+// DetectMimeType 只解码 base64 开头的少量字节识别图片格式，无法识别时按 PNG 处理
+func DetectMimeType(data string) string {
+	prefix := data
+	if len(prefix) > 24 {
+		prefix = prefix[:24]
+	}
+	raw, err := base64.StdEncoding.DecodeString(prefix)
+	if err != nil || len(raw) == 0 {
+		return defaultMimeType
+	}
+	mimeType := http.DetectContentType(raw)
+	if !strings.HasPrefix(mimeType, "image/") {
+		return defaultMimeType
+	}
+	return mimeType
+}
+
+func parseDimensions(value string, sep string) (int, int, bool) {
+	parts := strings.Split(value, sep)
+	if len(parts) != 2 {
+		return 0, 0, false
+	}
+	width, err := strconv.Atoi(strings.TrimSpace(parts[0]))
+	if err != nil {
+		return 0, 0, false
+	}
+	height, err := strconv.Atoi(strings.TrimSpace(parts[1]))
+	if err != nil {
+		return 0, 0, false
+	}
+	if width <= 0 || height <= 0 {
+		return 0, 0, false
+	}
+	return width, height, true
+}
@@ -6,6 +6,7 @@ import (
 	claudemessages "github.com/QuantumNous/new-api/service/relayconvert/internal/claude_messages"
 	geminichat "github.com/QuantumNous/new-api/service/relayconvert/internal/gemini_chat"
 	oaichat "github.com/QuantumNous/new-api/service/relayconvert/internal/oai_chat"
+	oaicompletions "github.com/QuantumNous/new-api/service/relayconvert/internal/oai_completions"
 	oairesponses "github.com/QuantumNous/new-api/service/relayconvert/internal/oai_responses"
 	sharedgemini "github.com/QuantumNous/new-api/service/relayconvert/internal/shared/gemini"
 	"github.com/QuantumNous/new-api/setting/model_setting"
@@ -55,3 +56,11 @@ func ShouldChatCompletionsUseResponsesPolicy(policy model_setting.ChatCompletion
 func ShouldChatCompletionsUseResponsesGlobal(channelID int, channelType int, model string) bool {
 	return oaichat.ShouldChatCompletionsUseResponsesGlobal(channelID, channelType, model)
 }
+
+func ShouldCompletionsUseChatPolicy(policy model_setting.CompletionsToChatPolicy, channelID int, channelType int, apiType int, model string) bool {
+	return oaicompletions.ShouldCompletionsUseChatPolicy(policy, channelID, channelType, apiType, model)
+}
+
+func ShouldCompletionsUseChatGlobal(channelID int, channelType int, apiType int, model string) bool {
+	return oaicompletions.ShouldCompletionsUseChatGlobal(channelID, channelType, apiType, model)
+}
@@ -9,10 +9,21 @@ import (
 
 	"github.com/QuantumNous/new-api/dto"
 	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	bedrockconverse "github.com/QuantumNous/new-api/service/relayconvert/internal/bedrock_converse"
 	claudemessages "github.com/QuantumNous/new-api/service/relayconvert/internal/claude_messages"
+	cohereembedding "github.com/QuantumNous/new-api/service/relayconvert/internal/cohere_embedding"
 	geminichat "github.com/QuantumNous/new-api/service/relayconvert/internal/gemini_chat"
+	geminiembedding "github.com/QuantumNous/new-api/service/relayconvert/internal/gemini_embedding"
+	geminiimage "github.com/QuantumNous/new-api/service/relayconvert/internal/gemini_image"
+	"github.com/QuantumNous/new-api/service/relayconvert/internal/imagen"
+	relaymeta "github.com/QuantumNous/new-api/service/relayconvert/internal/meta"
 	oaichat "github.com/QuantumNous/new-api/service/relayconvert/internal/oai_chat"
+	oaicompletions "github.com/QuantumNous/new-api/service/relayconvert/internal/oai_completions"
+	oaiembedding "github.com/QuantumNous/new-api/service/relayconvert/internal/oai_embedding"
+	oaiimage "github.com/QuantumNous/new-api/service/relayconvert/internal/oai_image"
 	oairesponses "github.com/QuantumNous/new-api/service/relayconvert/internal/oai_responses"
+	"github.com/QuantumNous/new-api/service/relayconvert/internal/ollama"
+	"github.com/QuantumNous/new-api/setting/model_setting"
 	"github.com/QuantumNous/new-api/types"
 	"github.com/gin-gonic/gin"
 )
@@ -72,14 +83,31 @@ const (
 )
 
 const (
-	ConverterNone                        = "none"
-	ConverterClaudeMessagesToOpenAIChat  = "anthropic_messages_to_openai_chat_completions"
-	ConverterOpenAIChatToClaudeMessages  = "openai_chat_completions_to_anthropic_messages"
-	ConverterOpenAIChatToOpenAIResponses = "openai_chat_completions_to_openai_responses"
-	ConverterOpenAIResponsesToOpenAIChat = "openai_responses_to_openai_chat_completions"
-	ConverterOpenAIResponsesToGemini     = "openai_responses_to_gemini_generate_content"
-	ConverterGeminiContentToOpenAIChat   = "gemini_generate_content_to_openai_chat_completions"
-	ConverterOpenAIChatToGeminiContent   = "openai_chat_completions_to_gemini_generate_content"
+	ConverterNone                          = "none"
+	ConverterClaudeMessagesToOpenAIChat    = "anthropic_messages_to_openai_chat_completions"
+	ConverterOpenAIChatToClaudeMessages    = "openai_chat_completions_to_anthropic_messages"
+	ConverterOpenAIChatToOpenAIResponses   = "openai_chat_completions_to_openai_responses"
+	ConverterOpenAIResponsesToOpenAIChat   = "openai_responses_to_openai_chat_completions"
+	ConverterOpenAIResponsesToGemini       = "openai_responses_to_gemini_generate_content"
+	ConverterGeminiContentToOpenAIChat     = "gemini_generate_content_to_openai_chat_completions"
+	ConverterOpenAIChatToGeminiContent     = "openai_chat_completions_to_gemini_generate_content"
+	ConverterOpenAICompletionsToOpenAIChat = "openai_completions_to_openai_chat_completions"
+	ConverterOpenAIChatToOpenAICompletions = "openai_chat_completions_to_openai_completions"
+	ConverterOllamaChatToOpenAIChat        = "ollama_chat_to_openai_chat_completions"
+	ConverterOpenAIChatToOllamaChat        = "openai_chat_completions_to_ollama_chat"
+	ConverterOllamaEmbedToOpenAIEmbed      = "ollama_embed_to_openai_embeddings"
+	ConverterOpenAIEmbedToGeminiEmbed      = "openai_embeddings_to_gemini_embed_content"
+	ConverterGeminiEmbedToOpenAIEmbed      = "gemini_embed_content_to_openai_embeddings"
+	ConverterOpenAIEmbedToCohereEmbed      = "openai_embeddings_to_cohere_embed"
+	ConverterCohereEmbedToOpenAIEmbed      = "cohere_embed_to_openai_embeddings"
+	ConverterGeminiEmbedToCohereEmbed      = "gemini_embed_content_to_cohere_embed"
+	ConverterCohereEmbedToGeminiEmbed      = "cohere_embed_to_gemini_embed_content"
+	ConverterBedrockConverseToClaude       = "bedrock_converse_to_anthropic_messages"
+	ConverterClaudeToBedrockConverse       = "anthropic_messages_to_bedrock_converse"
+	ConverterOpenAIImageToGeminiImage      = "openai_images_to_gemini_generate_content_image"
+	ConverterGeminiImageToOpenAIImage      = "gemini_generate_content_image_to_openai_images"
+	ConverterOpenAIImageToImagen           = "openai_images_to_gemini_imagen_predict"
+	ConverterImagenToOpenAIImage           = "gemini_imagen_predict_to_openai_images"
 )
 
 func registerBuiltinRequestConverter(spec RequestConverterSpec) {
@@ -497,3 +525,211 @@ func convertResponsesRequestToChat(_ *gin.Context, _ *relaycommon.RelayInfo, req
 	}
 	return oairesponses.ResponsesRequestToChatCompletionsRequest(responsesRequest)
 }
+
+func convertCompletionsRequestToOpenAI(_ *gin.Context, _ *relaycommon.RelayInfo, request any) (any, error) {
+	completionsRequest, ok := request.(*dto.CompletionsRequest)
+	if !ok {
+		if value, ok := request.(dto.CompletionsRequest); ok {
+			completionsRequest = &value
+		}
+	}
+	if completionsRequest == nil {
+		return nil, fmt.Errorf("expected OpenAI completions request, got %T", request)
+	}
+	return oaicompletions.CompletionsRequestToOpenAIChat(completionsRequest, model_setting.GetGlobalSettings().CompletionsToChatPolicy.FIMTemplates)
+}
+
+func convertOpenAIRequestToCompletions(_ *gin.Context, _ *relaycommon.RelayInfo, request any) (any, error) {
+	openAIRequest, ok := request.(*dto.GeneralOpenAIRequest)
+	if !ok {
+		if value, ok := request.(dto.GeneralOpenAIRequest); ok {
+			openAIRequest = &value
+		}
+	}
+	if openAIRequest == nil {
+		return nil, fmt.Errorf("expected OpenAI chat completions request, got %T", request)
+	}
+	return oaichat.OpenAIChatRequestToCompletions(openAIRequest)
+}
+
+func convertOllamaRequestToOpenAI(_ *gin.Context, _ *relaycommon.RelayInfo, request any) (any, error) {
+	switch req := request.(type) {
+	case *dto.OllamaChatRequest:
+		return ollama.OllamaChatRequestToOpenAIChat(req)
+	case dto.OllamaChatRequest:
+		return ollama.OllamaChatRequestToOpenAIChat(&req)
+	case *dto.OllamaGenerateRequest:
+		return ollama.OllamaGenerateRequestToOpenAIChat(req)
+	case dto.OllamaGenerateRequest:
+		return ollama.OllamaGenerateRequestToOpenAIChat(&req)
+	default:
+		return nil, fmt.Errorf("expected Ollama chat or generate request, got %T", request)
+	}
+}
+
+func convertOpenAIRequestToOllama(_ *gin.Context, _ *relaycommon.RelayInfo, request any) (any, error) {
+	openAIRequest, ok := request.(*dto.GeneralOpenAIRequest)
+	if !ok {
+		if value, ok := request.(dto.GeneralOpenAIRequest); ok {
+			openAIRequest = &value
+		}
+	}
+	if openAIRequest == nil {
+		return nil, fmt.Errorf("expected OpenAI chat completions request, got %T", request)
+	}
+	return oaichat.OpenAIChatRequestToOllamaChat(openAIRequest)
+}
+
+func convertOllamaEmbedRequestToOpenAI(_ *gin.Context, _ *relaycommon.RelayInfo, request any) (any, error) {
+	embedRequest, ok := request.(*dto.OllamaEmbedRequest)
+	if !ok {
+		if value, ok := request.(dto.OllamaEmbedRequest); ok {
+			embedRequest = &value
+		}
+	}
+	if embedRequest == nil {
+		return nil, fmt.Errorf("expected Ollama embed request, got %T", request)
+	}
+	return ollama.OllamaEmbedRequestToOpenAIEmbedding(embedRequest)
+}
+
+func convertBedrockConverseRequestToClaude(_ *gin.Context, _ *relaycommon.RelayInfo, request any) (any, error) {
+	converseRequest, ok := request.(*dto.BedrockConverseRequest)
+	if !ok {
+		if value, ok := request.(dto.BedrockConverseRequest); ok {
+			converseRequest = &value
+		}
+	}
+	if converseRequest == nil {
+		return nil, fmt.Errorf("expected Bedrock Converse request, got %T", request)
+	}
+	return bedrockconverse.BedrockConverseRequestToClaudeMessages(converseRequest)
+}
+
+func convertClaudeRequestToBedrockConverse(_ *gin.Context, _ *relaycommon.RelayInfo, request any) (any, error) {
+	claudeRequest, ok := request.(*dto.ClaudeRequest)
+	if !ok {
+		if value, ok := request.(dto.ClaudeRequest); ok {
+			claudeRequest = &value
+		}
+	}
+	if claudeRequest == nil {
+		return nil, fmt.Errorf("expected Anthropic Messages request, got %T", request)
+	}
+	return claudemessages.ClaudeMessagesRequestToBedrockConverse(*claudeRequest)
+}
+
+func convertOpenAIEmbeddingRequestToGemini(_ *gin.Context, _ *relaycommon.RelayInfo, request any) (any, error) {
+	embeddingRequest, err := asEmbeddingRequest(request)
+	if err != nil {
+		return nil, err
+	}
+	return oaiembedding.EmbeddingRequestToGeminiBatch(embeddingRequest)
+}
+
+func convertOpenAIEmbeddingRequestToCohere(_ *gin.Context, _ *relaycommon.RelayInfo, request any) (any, error) {
+	embeddingRequest, err := asEmbeddingRequest(request)
+	if err != nil {
+		return nil, err
+	}
+	return oaiembedding.EmbeddingRequestToCohere(embeddingRequest)
+}
+
+func convertGeminiEmbeddingRequestToOpenAI(_ *gin.Context, _ *relaycommon.RelayInfo, request any) (any, error) {
+	return geminiembedding.GeminiEmbeddingRequestToOpenAI(request)
+}
+
+func convertGeminiEmbeddingRequestToCohere(_ *gin.Context, _ *relaycommon.RelayInfo, request any) (any, error) {
+	return geminiembedding.GeminiEmbeddingRequestToCohere(request)
+}
+
+func convertCohereEmbedRequestToOpenAI(_ *gin.Context, _ *relaycommon.RelayInfo, request any) (any, error) {
+	cohereRequest, err := asCohereEmbedRequest(request)
+	if err != nil {
+		return nil, err
+	}
+	return cohereembedding.CohereEmbedRequestToOpenAI(cohereRequest)
+}
+
+func convertCohereEmbedRequestToGemini(_ *gin.Context, _ *relaycommon.RelayInfo, request any) (any, error) {
+	cohereRequest, err := asCohereEmbedRequest(request)
+	if err != nil {
+		return nil, err
+	}
+	return cohereembedding.CohereEmbedRequestToGeminiBatch(cohereRequest)
+}
+
+func asEmbeddingRequest(request any) (*dto.EmbeddingRequest, error) {
+	switch req := request.(type) {
+	case *dto.EmbeddingRequest:
+		return req, nil
+	case dto.EmbeddingRequest:
+		return &req, nil
+	default:
+		return nil, fmt.Errorf("expected OpenAI embeddings request, got %T", request)
+	}
+}
+
+func asCohereEmbedRequest(request any) (*dto.CohereEmbedRequest, error) {
+	switch req := request.(type) {
+	case *dto.CohereEmbedRequest:
+		return req, nil
+	case dto.CohereEmbedRequest:
+		return &req, nil
+	default:
+		return nil, fmt.Errorf("expected Cohere embed request, got %T", request)
+	}
+}
+
+func convertOpenAIImageRequestToGemini(_ *gin.Context, _ *relaycommon.RelayInfo, request any) (any, error) {
+	imageRequest, err := asImageRequest(request)
+	if err != nil {
+		return nil, err
+	}
+	return oaiimage.ImageRequestToGeminiChat(imageRequest)
+}
+
+func convertOpenAIImageRequestToImagen(_ *gin.Context, _ *relaycommon.RelayInfo, request any) (any, error) {
+	imageRequest, err := asImageRequest(request)
+	if err != nil {
+		return nil, err
+	}
+	return oaiimage.ImageRequestToImagen(imageRequest)
+}
+
+func convertGeminiImageRequestToOpenAI(_ *gin.Context, info *relaycommon.RelayInfo, request any) (any, error) {
+	geminiRequest, ok := request.(*dto.GeminiChatRequest)
+	if !ok {
+		if value, ok := request.(dto.GeminiChatRequest); ok {
+			geminiRequest = &value
+		}
+	}
+	if geminiRequest == nil {
+		return nil, fmt.Errorf("expected Gemini generateContent request, got %T", request)
+	}
+	return geminiimage.GeminiChatRequestToImageRequest(geminiRequest, relaymeta.RelayInfoUpstreamModelName(info))
+}
+
+func convertImagenRequestToOpenAI(_ *gin.Context, info *relaycommon.RelayInfo, request any) (any, error) {
+	imagenRequest, ok := request.(*dto.GeminiImageRequest)
+	if !ok {
+		if value, ok := request.(dto.GeminiImageRequest); ok {
+			imagenRequest = &value
+		}
+	}
+	if imagenRequest == nil {
+		return nil, fmt.Errorf("expected Imagen predict request, got %T", request)
+	}
+	return imagen.ImagenRequestToImageRequest(imagenRequest, relaymeta.RelayInfoUpstreamModelName(info))
+}
+
+func asImageRequest(request any) (*dto.ImageRequest, error) {
+	switch req := request.(type) {
+	case *dto.ImageRequest:
+		return req, nil
+	case dto.ImageRequest:
+		return &req, nil
+	default:
+		return nil, fmt.Errorf("expected OpenAI images request, got %T", request)
+	}
+}
@@ -29,6 +29,23 @@ func TestRequestConverterRegistryListsSupportedTextConverters(t *testing.T) {
 		{converter: ConverterOpenAIChatToGeminiContent, from: types.RelayFormatOpenAI, to: types.RelayFormatGemini, quality: RequestConverterQualityFair, advancedCustom: true},
 		{converter: ConverterOpenAIChatToOpenAIResponses, from: types.RelayFormatOpenAI, to: types.RelayFormatOpenAIResponses, quality: RequestConverterQualityGood, advancedCustom: true},
 		{converter: ConverterOpenAIResponsesToOpenAIChat, from: types.RelayFormatOpenAIResponses, to: types.RelayFormatOpenAI, quality: RequestConverterQualityGood, advancedCustom: true},
+		{converter: ConverterOpenAICompletionsToOpenAIChat, from: types.RelayFormatOpenAICompletions, to: types.RelayFormatOpenAI, quality: RequestConverterQualityFair},
+		{converter: ConverterOpenAIChatToOpenAICompletions, from: types.RelayFormatOpenAI, to: types.RelayFormatOpenAICompletions, quality: RequestConverterQualityDiscouraged},
+		{converter: ConverterOllamaChatToOpenAIChat, from: types.RelayFormatOllama, to: types.RelayFormatOpenAI, quality: RequestConverterQualityGood},
+		{converter: ConverterOpenAIChatToOllamaChat, from: types.RelayFormatOpenAI, to: types.RelayFormatOllama, quality: RequestConverterQualityGood},
+		{converter: ConverterOllamaEmbedToOpenAIEmbed, from: types.RelayFormatOllama, to: types.RelayFormatEmbedding, quality: RequestConverterQualityGood},
+		{converter: ConverterBedrockConverseToClaude, from: types.RelayFormatBedrockConverse, to: types.RelayFormatClaude, quality: RequestConverterQualityGood},
+		{converter: ConverterClaudeToBedrockConverse, from: types.RelayFormatClaude, to: types.RelayFormatBedrockConverse, quality: RequestConverterQualityGood},
+		{converter: ConverterOpenAIEmbedToGeminiEmbed, from: types.RelayFormatEmbedding, to: types.RelayFormatGeminiEmbedding, quality: RequestConverterQualityGood},
+		{converter: ConverterGeminiEmbedToOpenAIEmbed, from: types.RelayFormatGeminiEmbedding, to: types.RelayFormatEmbedding, quality: RequestConverterQualityFair},
+		{converter: ConverterOpenAIEmbedToCohereEmbed, from: types.RelayFormatEmbedding, to: types.RelayFormatCohereEmbedding, quality: RequestConverterQualityGood},
+		{converter: ConverterCohereEmbedToOpenAIEmbed, from: types.RelayFormatCohereEmbedding, to: types.RelayFormatEmbedding, quality: RequestConverterQualityFair},
+		{converter: ConverterGeminiEmbedToCohereEmbed, from: types.RelayFormatGeminiEmbedding, to: types.RelayFormatCohereEmbedding, quality: RequestConverterQualityGood},
+		{converter: ConverterCohereEmbedToGeminiEmbed, from: types.RelayFormatCohereEmbedding, to: types.RelayFormatGeminiEmbedding, quality: RequestConverterQualityGood},
+		{converter: ConverterOpenAIImageToGeminiImage, from: types.RelayFormatOpenAIImage, to: types.RelayFormatGemini, quality: RequestConverterQualityGood},
+		{converter: ConverterGeminiImageToOpenAIImage, from: types.RelayFormatGemini, to: types.RelayFormatOpenAIImage, quality: RequestConverterQualityFair},
+		{converter: ConverterOpenAIImageToImagen, from: types.RelayFormatOpenAIImage, to: types.RelayFormatGeminiImagen, quality: RequestConverterQualityGood},
+		{converter: ConverterImagenToOpenAIImage, from: types.RelayFormatGeminiImagen, to: types.RelayFormatOpenAIImage, quality: RequestConverterQualityGood},
 		{
 			converter: requestConverterClaudeToGemini,
 			from:      types.RelayFormatClaude,
@@ -136,6 +153,247 @@ func TestConvertRequestToTargetRecordsConversionChain(t *testing.T) {
 	assert.Equal(t, []types.RelayFormat{types.RelayFormatOpenAI, types.RelayFormatOpenAIResponses}, info.RequestConversionChain)
 }
 
+func TestConvertRequestOllamaToOpenAI(t *testing.T) {
+	info := &relaycommon.RelayInfo{
+		RelayFormat:            types.RelayFormatOllama,
+		RequestConversionChain: []types.RelayFormat{types.RelayFormatOllama},
+	}
+	req := &dto.OllamaChatRequest{
+		Model: "llama3",
+		Messages: []dto.OllamaMessage{
+			{Role: "system", Content: "be brief"},
+			{Role: "user", Content: "what is this?", Images: []string{"/9j/4AAQ"}},
+			{Role: "assistant", ToolCalls: []dto.OllamaToolCall{{Function: dto.OllamaToolCallFunction{Name: "lookup", Arguments: []byte(`{"q":"cat"}`)}}}},
+			{Role: "tool", ToolName: "lookup", Content: "a cat"},
+		},
+		Format:  []byte(`"json"`),
+		Options: &dto.OllamaOptions{Temperature: common.GetPointer(0.2), NumPredict: common.GetPointer(128)},
+	}
+
+	result, err := ConvertRequest(nil, info, types.RelayFormatOpenAI, req)
+
+	require.NoError(t, err)
+	assert.Equal(t, ConverterOllamaChatToOpenAIChat, result.Converter)
+	openAIRequest, ok := result.Value.(*dto.GeneralOpenAIRequest)
+	require.True(t, ok)
+	assert.Equal(t, "llama3", openAIRequest.Model)
+	// Ollama 未指定 stream 时默认流式，并要求上游返回用量
+	require.NotNil(t, openAIRequest.Stream)
+	assert.True(t, *openAIRequest.Stream)
+	require.NotNil(t, openAIRequest.StreamOptions)
+	assert.True(t, openAIRequest.StreamOptions.IncludeUsage)
+	assert.Equal(t, uint(128), *openAIRequest.MaxTokens)
+	assert.Equal(t, 0.2, *openAIRequest.Temperature)
+	assert.Equal(t, "json_object", openAIRequest.ResponseFormat.Type)
+
+	require.Len(t, openAIRequest.Messages, 4)
+	parts := openAIRequest.Messages[1].ParseContent()
+	require.Len(t, parts, 2)
+	assert.Equal(t, "data:image/jpeg;base64,/9j/4AAQ", parts[1].GetImageMedia().Url)
+	toolCalls := openAIRequest.Messages[2].ParseToolCalls()
+	require.Len(t, toolCalls, 1)
+	assert.Equal(t, `{"q":"cat"}`, toolCalls[0].Function.Arguments)
+	assert.Equal(t, toolCalls[0].ID, openAIRequest.Messages[3].ToolCallId)
+	assert.Equal(t, []types.RelayFormat{types.RelayFormatOllama, types.RelayFormatOpenAI}, info.RequestConversionChain)
+
+	generate, err := ConvertRequest(nil, nil, types.RelayFormatOpenAI, &dto.OllamaGenerateRequest{
+		Model:  "llama3",
+		System: "sys",
+		Prompt: "hi",
+		Stream: common.GetPointer(false),
+	})
+	require.NoError(t, err)
+	generateRequest := generate.Value.(*dto.GeneralOpenAIRequest)
+	require.Len(t, generateRequest.Messages, 2)
+	assert.Equal(t, "hi", generateRequest.Messages[1].StringContent())
+	assert.False(t, *generateRequest.Stream)
+	assert.Nil(t, generateRequest.StreamOptions)
+
+	embed, err := ConvertRequest(nil, nil, types.RelayFormatEmbedding, &dto.OllamaEmbedRequest{
+		Model: "nomic-embed-text",
+		Input: []any{"a", "b"},
+	})
+	require.NoError(t, err)
+	embeddingRequest := embed.Value.(*dto.EmbeddingRequest)
+	assert.Equal(t, []string{"a", "b"}, embeddingRequest.ParseInput())
+}
+
+func TestConvertRequestCompletionsToOpenAIChat(t *testing.T) {
+	info := &relaycommon.RelayInfo{
+		RelayFormat:            types.RelayFormatOpenAI,
+		RequestConversionChain: []types.RelayFormat{types.RelayFormatOpenAICompletions},
+	}
+	req := &dto.CompletionsRequest{
+		Model:     "claude-sonnet",
+		Prompt:    []any{"Say hi"},
+		Echo:      true,
+		LogProbs:  common.GetPointer(3),
+		MaxTokens: common.GetPointer(uint(16)),
+		Stop:      "\n",
+		Stream:    common.GetPointer(true),
+	}
+
+	result, err := ConvertRequest(nil, info, types.RelayFormatOpenAI, req)
+
+	require.NoError(t, err)
+	assert.Equal(t, ConverterOpenAICompletionsToOpenAIChat, result.Converter)
+	chatRequest := result.Value.(*dto.GeneralOpenAIRequest)
+	require.Len(t, chatRequest.Messages, 1)
+	assert.Equal(t, "user", chatRequest.Messages[0].Role)
+	assert.Equal(t, "Say hi", chatRequest.Messages[0].StringContent())
+	assert.Equal(t, uint(16), *chatRequest.MaxTokens)
+	assert.Equal(t, "\n", chatRequest.Stop)
+	assert.True(t, *chatRequest.Stream)
+	// logprobs=N 对应 chat 的 logprobs 与 top_logprobs
+	assert.JSONEq(t, "true", string(chatRequest.LogProbs))
+	assert.Equal(t, 3, *chatRequest.TopLogProbs)
+	assert.Equal(t, []types.RelayFormat{types.RelayFormatOpenAICompletions, types.RelayFormatOpenAI}, info.RequestConversionChain)
+
+	_, err = ConvertRequest(nil, nil, types.RelayFormatOpenAI, &dto.CompletionsRequest{Model: "m", Prompt: []any{"a", "b"}})
+	assert.ErrorContains(t, err, "single text prompt")
+
+	_, err = ConvertRequest(nil, nil, types.RelayFormatOpenAI, &dto.CompletionsRequest{Model: "m", Prompt: "a", BestOf: common.GetPointer(2)})
+	assert.ErrorContains(t, err, "best_of")
+}
+
+func TestConvertRequestCompletionsSuffixUsesFIMTemplate(t *testing.T) {
+	original := model_setting.GetGlobalSettings().CompletionsToChatPolicy.FIMTemplates
+	t.Cleanup(func() {
+		model_setting.GetGlobalSettings().CompletionsToChatPolicy.FIMTemplates = original
+	})
+	model_setting.GetGlobalSettings().CompletionsToChatPolicy.FIMTemplates = []model_setting.CompletionsFIMTemplate{
+		{ModelPattern: `^qwen.*coder`, Template: "<|fim_prefix|>{prefix}<|fim_suffix|>{suffix}<|fim_middle|>"},
+	}
+
+	result, err := ConvertRequest(nil, nil, types.RelayFormatOpenAI, &dto.CompletionsRequest{
+		Model:  "qwen2.5-coder-32b",
+		Prompt: "def add(a, b):\n    ",
+		Suffix: "\n\nprint(add(1, 2))",
+	})
+	require.NoError(t, err)
+	chatRequest := result.Value.(*dto.GeneralOpenAIRequest)
+	require.Len(t, chatRequest.Messages, 1)
+	assert.Equal(t, "<|fim_prefix|>def add(a, b):\n    <|fim_suffix|>\n\nprint(add(1, 2))<|fim_middle|>", chatRequest.Messages[0].StringContent())
+
+	// 未匹配模板的模型使用带系统指令的默认模板，prompt 中的占位符不会被二次替换
+	result, err = ConvertRequest(nil, nil, types.RelayFormatOpenAI, &dto.CompletionsRequest{
+		Model:  "claude-sonnet",
+		Prompt: "x = '{suffix}'",
+		Suffix: "y = 1",
+	})
+	require.NoError(t, err)
+	chatRequest = result.Value.(*dto.GeneralOpenAIRequest)
+	require.Len(t, chatRequest.Messages, 2)
+	assert.Equal(t, "system", chatRequest.Messages[0].Role)
+	assert.Equal(t, "<prefix>x = '{suffix}'</prefix>\n<suffix>y = 1</suffix>", chatRequest.Messages[1].StringContent())
+}
+
+func TestConvertRequestOpenAIChatToCompletions(t *testing.T) {
+	result, err := ConvertRequest(nil, nil, types.RelayFormatOpenAICompletions, &dto.GeneralOpenAIRequest{
+		Model: "gpt-3.5-turbo-instruct",
+		Messages: []dto.Message{
+			{Role: "system", Content: "be brief"},
+			{Role: "user", Content: "hi"},
+		},
+		MaxCompletionTokens: common.GetPointer(uint(8)),
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, ConverterOpenAIChatToOpenAICompletions, result.Converter)
+	completionsRequest := result.Value.(*dto.CompletionsRequest)
+	assert.Equal(t, "System: be brief\n\nUser: hi\n\nAssistant:", completionsRequest.Prompt)
+	assert.Equal(t, uint(8), *completionsRequest.MaxTokens)
+}
+
+func TestShouldCompletionsUseChatPolicy(t *testing.T) {
+	disabled := model_setting.CompletionsToChatPolicy{}
+	// 没有 completions 接口的渠道类型不受开关影响
+	assert.True(t, ShouldCompletionsUseChatPolicy(disabled, 1, constant.ChannelTypeAnthropic, constant.APITypeAnthropic, "claude-sonnet"))
+	assert.False(t, ShouldCompletionsUseChatPolicy(disabled, 1, constant.ChannelTypeOpenAI, constant.APITypeOpenAI, "gpt-4o"))
+
+	policy := model_setting.CompletionsToChatPolicy{Enabled: true, ChannelIDs: []int{7}}
+	assert.True(t, ShouldCompletionsUseChatPolicy(policy, 7, constant.ChannelTypeOpenAI, constant.APITypeOpenAI, "gpt-4o"))
+	assert.False(t, ShouldCompletionsUseChatPolicy(policy, 8, constant.ChannelTypeOpenAI, constant.APITypeOpenAI, "gpt-4o"))
+
+	policy.ModelPatterns = []string{`^gpt-4`}
+	assert.True(t, ShouldCompletionsUseChatPolicy(policy, 7, constant.ChannelTypeOpenAI, constant.APITypeOpenAI, "gpt-4o"))
+	assert.False(t, ShouldCompletionsUseChatPolicy(policy, 7, constant.ChannelTypeOpenAI, constant.APITypeOpenAI, "gpt-3.5-turbo-instruct"))
+}
+
+func TestConvertRequestBedrockConverseToClaude(t *testing.T) {
+	info := &relaycommon.RelayInfo{
+		RelayFormat:            types.RelayFormatBedrockConverse,
+		RequestConversionChain: []types.RelayFormat{types.RelayFormatBedrockConverse},
+	}
+	req := &dto.BedrockConverseRequest{
+		ModelId: "claude-sonnet",
+		Stream:  true,
+		System:  []dto.BedrockConverseSystemBlock{{Text: respPtr("be brief")}},
+		Messages: []dto.BedrockConverseMessage{
+			{Role: "user", Content: []dto.BedrockConverseContentBlock{
+				{Text: respPtr("what is this?")},
+				{Image: &dto.BedrockConverseImageBlock{Format: "jpg", Source: dto.BedrockConverseMediaSource{Bytes: "/9j/4AAQ"}}},
+			}},
+			{Role: "assistant", Content: []dto.BedrockConverseContentBlock{
+				{ToolUse: &dto.BedrockConverseToolUseBlock{ToolUseId: "tooluse_1", Name: "lookup", Input: []byte(`{"q":"cat"}`)}},
+			}},
+			{Role: "user", Content: []dto.BedrockConverseContentBlock{
+				{ToolResult: &dto.BedrockConverseToolResultBlock{ToolUseId: "tooluse_1", Content: []dto.BedrockConverseToolResultContent{{Json: []byte(`{"animal":"cat"}`)}}}},
+			}},
+		},
+		InferenceConfig: &dto.BedrockConverseInferenceConfig{MaxTokens: common.GetPointer(256), StopSequences: []string{"END"}},
+		ToolConfig: &dto.BedrockConverseToolConfig{
+			Tools: []dto.BedrockConverseTool{{ToolSpec: &dto.BedrockConverseToolSpec{
+				Name:        "lookup",
+				InputSchema: dto.BedrockConverseToolInputSchema{Json: []byte(`{"type":"object"}`)},
+			}}},
+			ToolChoice: &dto.BedrockConverseToolChoice{Tool: &dto.BedrockConverseToolChoiceName{Name: "lookup"}},
+		},
+		AdditionalModelRequestFields: []byte(`{"top_k":50,"thinking":{"type":"enabled","budget_tokens":1024}}`),
+	}
+
+	result, err := ConvertRequest(nil, info, types.RelayFormatClaude, req)
+
+	require.NoError(t, err)
+	assert.Equal(t, ConverterBedrockConverseToClaude, result.Converter)
+	claudeRequest, ok := result.Value.(*dto.ClaudeRequest)
+	require.True(t, ok)
+	assert.Equal(t, "claude-sonnet", claudeRequest.Model)
+	assert.True(t, claudeRequest.IsStream(nil))
+	assert.Equal(t, uint(256), *claudeRequest.MaxTokens)
+	assert.Equal(t, []string{"END"}, claudeRequest.StopSequences)
+	assert.Equal(t, 50, *claudeRequest.TopK)
+	assert.Equal(t, 1024, claudeRequest.Thinking.GetBudgetTokens())
+	assert.Equal(t, "be brief", claudeRequest.ParseSystem()[0].GetText())
+	assert.Equal(t, &dto.ClaudeToolChoice{Type: "tool", Name: "lookup"}, claudeRequest.ToolChoice)
+	tools, _ := dto.ProcessTools(claudeRequest.GetTools())
+	require.Len(t, tools, 1)
+	assert.Equal(t, "object", tools[0].InputSchema["type"])
+
+	require.Len(t, claudeRequest.Messages, 3)
+	userParts, err := claudeRequest.Messages[0].ParseContent()
+	require.NoError(t, err)
+	require.Len(t, userParts, 2)
+	assert.Equal(t, "image/jpeg", userParts[1].Source.MediaType)
+	toolUse, err := claudeRequest.Messages[1].ParseContent()
+	require.NoError(t, err)
+	assert.Equal(t, "tooluse_1", toolUse[0].Id)
+	assert.Equal(t, map[string]any{"q": "cat"}, toolUse[0].Input)
+	toolResult, err := claudeRequest.Messages[2].ParseContent()
+	require.NoError(t, err)
+	assert.Equal(t, "tool_result", toolResult[0].Type)
+	assert.Equal(t, `{"animal":"cat"}`, toolResult[0].ParseMediaContent()[0].GetText())
+	assert.Equal(t, []types.RelayFormat{types.RelayFormatBedrockConverse, types.RelayFormatClaude}, info.RequestConversionChain)
+
+	_, err = ConvertRequest(nil, nil, types.RelayFormatClaude, &dto.BedrockConverseRequest{
+		ModelId: "claude-sonnet",
+		Messages: []dto.BedrockConverseMessage{{Role: "user", Content: []dto.BedrockConverseContentBlock{
+			{Document: &dto.BedrockConverseDocumentBlock{Format: "docx", Name: "a", Source: dto.BedrockConverseMediaSource{Bytes: "AA=="}}},
+		}}},
+	})
+	assert.ErrorContains(t, err, "unsupported document format")
+}
+
 func TestConvertRequestPlansMultiHopPath(t *testing.T) {
 	info := &relaycommon.RelayInfo{
 		RelayFormat:            types.RelayFormatClaude,
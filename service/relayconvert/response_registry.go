@@ -10,6 +10,18 @@ import (
 	"github.com/QuantumNous/new-api/common"
 	"github.com/QuantumNous/new-api/dto"
 	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	bedrockconverse "github.com/QuantumNous/new-api/service/relayconvert/internal/bedrock_converse"
+	claudemessages "github.com/QuantumNous/new-api/service/relayconvert/internal/claude_messages"
+	cohereembedding "github.com/QuantumNous/new-api/service/relayconvert/internal/cohere_embedding"
+	geminiembedding "github.com/QuantumNous/new-api/service/relayconvert/internal/gemini_embedding"
+	geminiimage "github.com/QuantumNous/new-api/service/relayconvert/internal/gemini_image"
+	"github.com/QuantumNous/new-api/service/relayconvert/internal/imagen"
+	relaymeta "github.com/QuantumNous/new-api/service/relayconvert/internal/meta"
+	oaichat "github.com/QuantumNous/new-api/service/relayconvert/internal/oai_chat"
+	oaicompletions "github.com/QuantumNous/new-api/service/relayconvert/internal/oai_completions"
+	oaiembedding "github.com/QuantumNous/new-api/service/relayconvert/internal/oai_embedding"
+	oaiimage "github.com/QuantumNous/new-api/service/relayconvert/internal/oai_image"
+	"github.com/QuantumNous/new-api/service/relayconvert/internal/ollama"
 	"github.com/QuantumNous/new-api/types"
 	"github.com/gin-gonic/gin"
 )
@@ -93,6 +105,13 @@ const (
 	ResponseConverterOAIChatToGeminiChat     = "oai_chat_to_gemini_chat_resp"
 	ResponseConverterClaudeMessagesToOAIChat = "claude_messages_to_oai_chat_resp"
 	ResponseConverterGeminiChatToOAIChat     = "gemini_chat_to_oai_chat_resp"
+	ResponseConverterCompletionsToOAIChat    = "oai_completions_to_oai_chat_resp"
+	ResponseConverterOAIChatToCompletions    = "oai_chat_to_oai_completions_resp"
+	ResponseConverterOllamaChatToOAIChat     = "ollama_chat_to_oai_chat_resp"
+	ResponseConverterOAIChatToOllamaChat     = "oai_chat_to_ollama_chat_resp"
+	ResponseConverterOAIEmbedToOllamaEmbed   = "oai_embedding_to_ollama_embed_resp"
+	ResponseConverterBedrockToClaude         = "bedrock_converse_to_claude_messages_resp"
+	ResponseConverterClaudeToBedrock         = "claude_messages_to_bedrock_converse_resp"
 
 	responseConverterClaudeToGemini    = "claude_messages_to_gemini_chat_resp"
 	responseConverterClaudeToResponses = "claude_messages_to_oai_responses_resp"
@@ -706,12 +725,28 @@ func inferResponseRelayFormat(response any) (types.RelayFormat, error) {
 	switch response.(type) {
 	case *dto.OpenAITextResponse, dto.OpenAITextResponse, *dto.ChatCompletionsStreamResponse, dto.ChatCompletionsStreamResponse:
 		return types.RelayFormatOpenAI, nil
+	case *dto.CompletionsResponse, dto.CompletionsResponse:
+		return types.RelayFormatOpenAICompletions, nil
 	case *dto.OpenAIResponsesResponse, dto.OpenAIResponsesResponse, *dto.ResponsesStreamResponse, dto.ResponsesStreamResponse:
 		return types.RelayFormatOpenAIResponses, nil
 	case *dto.ClaudeResponse, dto.ClaudeResponse:
 		return types.RelayFormatClaude, nil
 	case *dto.GeminiChatResponse, dto.GeminiChatResponse:
 		return types.RelayFormatGemini, nil
+	case *dto.OllamaChatResponse, dto.OllamaChatResponse, *dto.OllamaEmbedResponse, dto.OllamaEmbedResponse:
+		return types.RelayFormatOllama, nil
+	case *dto.EmbeddingResponse, dto.EmbeddingResponse:
+		return types.RelayFormatEmbedding, nil
+	case *dto.GeminiEmbeddingResponse, dto.GeminiEmbeddingResponse, *dto.GeminiBatchEmbeddingResponse, dto.GeminiBatchEmbeddingResponse:
+		return types.RelayFormatGeminiEmbedding, nil
+	case *dto.CohereEmbedResponse, dto.CohereEmbedResponse:
+		return types.RelayFormatCohereEmbedding, nil
+	case *dto.ImageResponse, dto.ImageResponse:
+		return types.RelayFormatOpenAIImage, nil
+	case *dto.GeminiImageResponse, dto.GeminiImageResponse:
+		return types.RelayFormatGeminiImagen, nil
+	case *dto.BedrockConverseResponse, dto.BedrockConverseResponse, *dto.BedrockConverseStreamEvent, dto.BedrockConverseStreamEvent:
+		return types.RelayFormatBedrockConverse, nil
 	default:
 		return "", fmt.Errorf("unsupported response type %T", response)
 	}
@@ -746,6 +781,16 @@ func canonicalUsageFromResponse(response any) *dto.Usage {
 			return nil
 		}
 		return UsageFromChatUsage(resp.Usage)
+	case *dto.CompletionsResponse:
+		if resp.Usage == nil {
+			return nil
+		}
+		return UsageFromChatUsage(resp.Usage)
+	case dto.CompletionsResponse:
+		if resp.Usage == nil {
+			return nil
+		}
+		return UsageFromChatUsage(resp.Usage)
 	case *dto.OpenAIResponsesResponse:
 		return UsageFromResponsesUsage(resp.Usage)
 	case dto.OpenAIResponsesResponse:
@@ -768,6 +813,22 @@ func canonicalUsageFromResponse(response any) *dto.Usage {
 		return UsageFromGeminiMetadata(resp.GetUsageMetadata(), 0)
 	case dto.GeminiChatResponse:
 		return UsageFromGeminiMetadata(resp.GetUsageMetadata(), 0)
+	case *dto.OllamaChatResponse:
+		return ollama.UsageFromOllamaCounts(resp.PromptEvalCount, resp.EvalCount)
+	case dto.OllamaChatResponse:
+		return ollama.UsageFromOllamaCounts(resp.PromptEvalCount, resp.EvalCount)
+	case *dto.EmbeddingResponse:
+		return UsageFromChatUsage(&resp.Usage)
+	case dto.EmbeddingResponse:
+		return UsageFromChatUsage(&resp.Usage)
+	case *dto.CohereEmbedResponse:
+		return cohereembedding.UsageFromCohereEmbed(resp)
+	case dto.CohereEmbedResponse:
+		return cohereembedding.UsageFromCohereEmbed(&resp)
+	case *dto.BedrockConverseResponse:
+		return UsageFromClaudeAPIUsage(bedrockconverse.ClaudeUsageFromBedrock(&resp.Usage))
+	case dto.BedrockConverseResponse:
+		return UsageFromClaudeAPIUsage(bedrockconverse.ClaudeUsageFromBedrock(&resp.Usage))
 	default:
 		return nil
 	}
@@ -973,6 +1034,236 @@ func convertGeminiChatStreamResponseToOAIChat(_ *gin.Context, info *relaycommon.
 	return openAIResponse, usage, nil
 }
 
+func convertCompletionsResponseToOAIChat(_ *gin.Context, _ *relaycommon.RelayInfo, response any) (any, *dto.Usage, error) {
+	completionsResponse, err := asCompletionsResponse(response)
+	if err != nil {
+		return nil, nil, err
+	}
+	chatResponse := oaicompletions.CompletionsResponseToOpenAIChat(completionsResponse)
+	return chatResponse, UsageFromChatUsage(&chatResponse.Usage), nil
+}
+
+func convertCompletionsStreamResponseToOAIChat(_ *gin.Context, _ *relaycommon.RelayInfo, response any) (any, *dto.Usage, error) {
+	completionsResponse, err := asCompletionsResponse(response)
+	if err != nil {
+		return nil, nil, err
+	}
+	var usage *dto.Usage
+	if completionsResponse.Usage != nil {
+		usage = UsageFromChatUsage(completionsResponse.Usage)
+	}
+	return oaicompletions.StreamResponseCompletionsToOpenAIChat(completionsResponse), usage, nil
+}
+
+func convertOAIChatResponseToCompletions(_ *gin.Context, _ *relaycommon.RelayInfo, response any) (any, *dto.Usage, error) {
+	chatResponse, err := asOAIChatResponse(response)
+	if err != nil {
+		return nil, nil, err
+	}
+	return oaichat.ResponseOpenAIChat2Completions(chatResponse), UsageFromChatUsage(&chatResponse.Usage), nil
+}
+
+func convertOAIChatStreamResponseToCompletions(_ *gin.Context, _ *relaycommon.RelayInfo, response any) (any, *dto.Usage, error) {
+	chatResponse, err := asOAIChatStreamResponse(response)
+	if err != nil {
+		return nil, nil, err
+	}
+	var usage *dto.Usage
+	if chatResponse.Usage != nil {
+		usage = UsageFromChatUsage(chatResponse.Usage)
+	}
+	return oaichat.StreamResponseOpenAIChat2Completions(chatResponse), usage, nil
+}
+
+func convertOllamaChatResponseToOAIChat(_ *gin.Context, _ *relaycommon.RelayInfo, response any) (any, *dto.Usage, error) {
+	ollamaResponse, err := asOllamaChatResponse(response)
+	if err != nil {
+		return nil, nil, err
+	}
+	chatResponse := ollama.OllamaChatResponseToOpenAIChat(ollamaResponse)
+	return chatResponse, UsageFromChatUsage(&chatResponse.Usage), nil
+}
+
+func convertOAIChatResponseToOllamaChat(_ *gin.Context, _ *relaycommon.RelayInfo, response any) (any, *dto.Usage, error) {
+	chatResponse, err := asOAIChatResponse(response)
+	if err != nil {
+		return nil, nil, err
+	}
+	return oaichat.ResponseOpenAIChat2Ollama(chatResponse), UsageFromChatUsage(&chatResponse.Usage), nil
+}
+
+func newOAIChatToOllamaChatStreamState(options ResponseStreamOptions) any {
+	return oaichat.NewOpenAIChatToOllamaStreamState(strings.TrimSpace(options.Model))
+}
+
+func convertOAIChatStreamResponseToOllamaChat(_ *gin.Context, _ *relaycommon.RelayInfo, response any, state any) ([]any, *dto.Usage, error) {
+	chatResponse, err := asOAIChatStreamResponse(response)
+	if err != nil {
+		return nil, nil, err
+	}
+	streamState, ok := state.(*oaichat.OpenAIChatToOllamaStreamState)
+	if !ok || streamState == nil {
+		return nil, nil, errors.New("OAI chat to Ollama chat stream state is required")
+	}
+	chunks := oaichat.StreamResponseOpenAIChat2Ollama(chatResponse, streamState)
+	return streamValuesFromAny(chunks), UsageFromChatUsage(streamState.Usage), nil
+}
+
+func finalizeOAIChatStreamResponseToOllamaChat(_ *gin.Context, _ *relaycommon.RelayInfo, state any) ([]any, *dto.Usage, error) {
+	streamState, ok := state.(*oaichat.OpenAIChatToOllamaStreamState)
+	if !ok || streamState == nil {
+		return nil, nil, errors.New("OAI chat to Ollama chat stream state is required")
+	}
+	chunks := oaichat.FinalizeOpenAIChat2OllamaStream(streamState)
+	return streamValuesFromAny(chunks), UsageFromChatUsage(streamState.Usage), nil
+}
+
+func convertOAIEmbeddingResponseToOllamaEmbed(_ *gin.Context, _ *relaycommon.RelayInfo, response any) (any, *dto.Usage, error) {
+	embeddingResponse, err := asEmbeddingResponse(response)
+	if err != nil {
+		return nil, nil, err
+	}
+	return oaiembedding.ResponseOpenAIEmbedding2Ollama(embeddingResponse), UsageFromChatUsage(&embeddingResponse.Usage), nil
+}
+
+func convertOAIEmbeddingResponseToGeminiEmbed(_ *gin.Context, info *relaycommon.RelayInfo, response any) (any, *dto.Usage, error) {
+	embeddingResponse, err := asEmbeddingResponse(response)
+	if err != nil {
+		return nil, nil, err
+	}
+	return oaiembedding.ResponseOpenAIEmbedding2Gemini(embeddingResponse, geminiEmbeddingResponseIsBatch(info)), UsageFromChatUsage(&embeddingResponse.Usage), nil
+}
+
+func convertOAIEmbeddingResponseToCohereEmbed(_ *gin.Context, _ *relaycommon.RelayInfo, response any) (any, *dto.Usage, error) {
+	embeddingResponse, err := asEmbeddingResponse(response)
+	if err != nil {
+		return nil, nil, err
+	}
+	cohereResponse, err := oaiembedding.ResponseOpenAIEmbedding2Cohere(embeddingResponse)
+	if err != nil {
+		return nil, nil, err
+	}
+	return cohereResponse, UsageFromChatUsage(&embeddingResponse.Usage), nil
+}
+
+func convertGeminiEmbedResponseToOAIEmbedding(_ *gin.Context, info *relaycommon.RelayInfo, response any) (any, *dto.Usage, error) {
+	embeddingResponse, err := geminiembedding.GeminiEmbeddingResponseToOpenAI(response, relaymeta.RelayInfoUpstreamModelName(info))
+	if err != nil {
+		return nil, nil, err
+	}
+	return embeddingResponse, nil, nil
+}
+
+func convertGeminiEmbedResponseToCohereEmbed(_ *gin.Context, _ *relaycommon.RelayInfo, response any) (any, *dto.Usage, error) {
+	cohereResponse, err := geminiembedding.GeminiEmbeddingResponseToCohere(response)
+	if err != nil {
+		return nil, nil, err
+	}
+	return cohereResponse, nil, nil
+}
+
+func convertCohereEmbedResponseToOAIEmbedding(_ *gin.Context, info *relaycommon.RelayInfo, response any) (any, *dto.Usage, error) {
+	cohereResponse, err := asCohereEmbedResponse(response)
+	if err != nil {
+		return nil, nil, err
+	}
+	embeddingResponse, err := cohereembedding.CohereEmbedResponseToOpenAI(cohereResponse, relaymeta.RelayInfoUpstreamModelName(info))
+	if err != nil {
+		return nil, nil, err
+	}
+	return embeddingResponse, cohereembedding.UsageFromCohereEmbed(cohereResponse), nil
+}
+
+func convertCohereEmbedResponseToGeminiEmbed(_ *gin.Context, info *relaycommon.RelayInfo, response any) (any, *dto.Usage, error) {
+	cohereResponse, err := asCohereEmbedResponse(response)
+	if err != nil {
+		return nil, nil, err
+	}
+	geminiResponse, err := cohereembedding.CohereEmbedResponseToGemini(cohereResponse, geminiEmbeddingResponseIsBatch(info))
+	if err != nil {
+		return nil, nil, err
+	}
+	return geminiResponse, cohereembedding.UsageFromCohereEmbed(cohereResponse), nil
+}
+
+// geminiEmbeddingResponseIsBatch 只有 Gemini embedContent 入站请求按单条格式返回，其余情况按 batchEmbedContents 返回
+func geminiEmbeddingResponseIsBatch(info *relaycommon.RelayInfo) bool {
+	if info == nil {
+		return true
+	}
+	return info.IsGeminiBatchEmbedding
+}
+
+func asEmbeddingResponse(response any) (*dto.EmbeddingResponse, error) {
+	switch resp := response.(type) {
+	case *dto.EmbeddingResponse:
+		return resp, nil
+	case dto.EmbeddingResponse:
+		return &resp, nil
+	default:
+		return nil, fmt.Errorf("expected OpenAI embeddings response, got %T", response)
+	}
+}
+
+func asCohereEmbedResponse(response any) (*dto.CohereEmbedResponse, error) {
+	switch resp := response.(type) {
+	case *dto.CohereEmbedResponse:
+		return resp, nil
+	case dto.CohereEmbedResponse:
+		return &resp, nil
+	default:
+		return nil, fmt.Errorf("expected Cohere embed response, got %T", response)
+	}
+}
+
+func convertBedrockConverseResponseToClaude(_ *gin.Context, _ *relaycommon.RelayInfo, response any) (any, *dto.Usage, error) {
+	var converseResponse *dto.BedrockConverseResponse
+	switch resp := response.(type) {
+	case *dto.BedrockConverseResponse:
+		converseResponse = resp
+	case dto.BedrockConverseResponse:
+		converseResponse = &resp
+	default:
+		return nil, nil, fmt.Errorf("expected Bedrock Converse response, got %T", response)
+	}
+	claudeResponse := bedrockconverse.BedrockConverseResponseToClaudeMessages(converseResponse)
+	return claudeResponse, usageFromClaudeResponse(claudeResponse), nil
+}
+
+func convertClaudeResponseToBedrockConverse(_ *gin.Context, _ *relaycommon.RelayInfo, response any) (any, *dto.Usage, error) {
+	claudeResponse, err := asClaudeResponse(response)
+	if err != nil {
+		return nil, nil, err
+	}
+	return claudemessages.ResponseClaude2BedrockConverse(claudeResponse), usageFromClaudeResponse(claudeResponse), nil
+}
+
+func newClaudeToBedrockConverseStreamState(_ ResponseStreamOptions) any {
+	return claudemessages.NewClaudeToBedrockConverseStreamState()
+}
+
+func convertClaudeStreamResponseToBedrockConverse(_ *gin.Context, _ *relaycommon.RelayInfo, response any, state any) ([]any, *dto.Usage, error) {
+	claudeResponse, err := asClaudeResponse(response)
+	if err != nil {
+		return nil, nil, err
+	}
+	streamState, ok := state.(*claudemessages.ClaudeToBedrockConverseStreamState)
+	if !ok || streamState == nil {
+		return nil, nil, errors.New("Claude to Bedrock Converse stream state is required")
+	}
+	events := claudemessages.StreamResponseClaude2BedrockConverse(claudeResponse, streamState)
+	return streamValuesFromAny(events), UsageFromClaudeAPIUsage(streamState.Usage), nil
+}
+
+func finalizeClaudeStreamResponseToBedrockConverse(_ *gin.Context, _ *relaycommon.RelayInfo, state any) ([]any, *dto.Usage, error) {
+	streamState, ok := state.(*claudemessages.ClaudeToBedrockConverseStreamState)
+	if !ok || streamState == nil {
+		return nil, nil, errors.New("Claude to Bedrock Converse stream state is required")
+	}
+	events := claudemessages.FinalizeClaude2BedrockConverseStream(streamState)
+	return streamValuesFromAny(events), UsageFromClaudeAPIUsage(streamState.Usage), nil
+}
+
 func fallbackPromptTokens(info *relaycommon.RelayInfo) int {
 	if info == nil {
 		return 0
@@ -1045,3 +1336,85 @@ func asGeminiChatResponse(response any) (*dto.GeminiChatResponse, error) {
 		return nil, fmt.Errorf("expected Gemini chat response, got %T", response)
 	}
 }
+
+func asCompletionsResponse(response any) (*dto.CompletionsResponse, error) {
+	switch resp := response.(type) {
+	case *dto.CompletionsResponse:
+		return resp, nil
+	case dto.CompletionsResponse:
+		return &resp, nil
+	default:
+		return nil, fmt.Errorf("expected OAI completions response, got %T", response)
+	}
+}
+
+func asOllamaChatResponse(response any) (*dto.OllamaChatResponse, error) {
+	switch resp := response.(type) {
+	case *dto.OllamaChatResponse:
+		return resp, nil
+	case dto.OllamaChatResponse:
+		return &resp, nil
+	default:
+		return nil, fmt.Errorf("expected Ollama chat response, got %T", response)
+	}
+}
+
+func convertOAIImageResponseToGeminiImage(_ *gin.Context, _ *relaycommon.RelayInfo, response any) (any, *dto.Usage, error) {
+	imageResponse, err := asImageResponse(response)
+	if err != nil {
+		return nil, nil, err
+	}
+	return oaiimage.ResponseOpenAIImage2GeminiChat(imageResponse), nil, nil
+}
+
+func convertOAIImageResponseToImagen(_ *gin.Context, _ *relaycommon.RelayInfo, response any) (any, *dto.Usage, error) {
+	imageResponse, err := asImageResponse(response)
+	if err != nil {
+		return nil, nil, err
+	}
+	return oaiimage.ResponseOpenAIImage2Imagen(imageResponse), nil, nil
+}
+
+func convertGeminiImageResponseToOAIImage(_ *gin.Context, info *relaycommon.RelayInfo, response any) (any, *dto.Usage, error) {
+	geminiResponse, err := asGeminiChatResponse(response)
+	if err != nil {
+		return nil, nil, err
+	}
+	imageResponse := geminiimage.GeminiChatResponseToImageResponse(geminiResponse, imageResponseFormat(info))
+	return imageResponse, UsageFromGeminiMetadata(geminiResponse.GetUsageMetadata(), 0), nil
+}
+
+func convertImagenResponseToOAIImage(_ *gin.Context, info *relaycommon.RelayInfo, response any) (any, *dto.Usage, error) {
+	var imagenResponse *dto.GeminiImageResponse
+	switch resp := response.(type) {
+	case *dto.GeminiImageResponse:
+		imagenResponse = resp
+	case dto.GeminiImageResponse:
+		imagenResponse = &resp
+	default:
+		return nil, nil, fmt.Errorf("expected Imagen predict response, got %T", response)
+	}
+	return imagen.ImagenResponseToImageResponse(imagenResponse, imageResponseFormat(info)), nil, nil
+}
+
+// imageResponseFormat 返回客户端 OpenAI Images 请求期望的 response_format，非 Images 入站请求时为空
+func imageResponseFormat(info *relaycommon.RelayInfo) string {
+	if info == nil {
+		return ""
+	}
+	if imageRequest, ok := info.Request.(*dto.ImageRequest); ok {
+		return imageRequest.ResponseFormat
+	}
+	return ""
+}
+
+func asImageResponse(response any) (*dto.ImageResponse, error) {
+	switch resp := response.(type) {
+	case *dto.ImageResponse:
+		return resp, nil
+	case dto.ImageResponse:
+		return &resp, nil
+	default:
+		return nil, fmt.Errorf("expected OpenAI images response, got %T", response)
+	}
+}
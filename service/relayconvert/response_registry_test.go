@@ -1,8 +1,10 @@
 package relayconvert
 
 import (
+	"encoding/json"
 	"testing"
 
+	"github.com/QuantumNous/new-api/common"
 	"github.com/QuantumNous/new-api/dto"
 	relaycommon "github.com/QuantumNous/new-api/relay/common"
 	"github.com/QuantumNous/new-api/types"
@@ -25,6 +27,23 @@ func TestLookupBuiltinResponseConverters(t *testing.T) {
 		{lookupID: ResponseConverterOAIChatToGeminiChat, id: ConverterOpenAIChatToGeminiContent, from: types.RelayFormatOpenAI, to: types.RelayFormatGemini, quality: ResponseConverterQualityFair},
 		{lookupID: ResponseConverterClaudeMessagesToOAIChat, id: ConverterClaudeMessagesToOpenAIChat, from: types.RelayFormatClaude, to: types.RelayFormatOpenAI, quality: ResponseConverterQualityFair},
 		{lookupID: ResponseConverterGeminiChatToOAIChat, id: ConverterGeminiContentToOpenAIChat, from: types.RelayFormatGemini, to: types.RelayFormatOpenAI, quality: ResponseConverterQualityFair},
+		{lookupID: ResponseConverterCompletionsToOAIChat, id: ConverterOpenAICompletionsToOpenAIChat, from: types.RelayFormatOpenAICompletions, to: types.RelayFormatOpenAI, quality: ResponseConverterQualityFair},
+		{lookupID: ResponseConverterOAIChatToCompletions, id: ConverterOpenAIChatToOpenAICompletions, from: types.RelayFormatOpenAI, to: types.RelayFormatOpenAICompletions, quality: ResponseConverterQualityDiscouraged},
+		{lookupID: ResponseConverterOllamaChatToOAIChat, id: ConverterOllamaChatToOpenAIChat, from: types.RelayFormatOllama, to: types.RelayFormatOpenAI, quality: ResponseConverterQualityGood},
+		{lookupID: ResponseConverterOAIChatToOllamaChat, id: ConverterOpenAIChatToOllamaChat, from: types.RelayFormatOpenAI, to: types.RelayFormatOllama, quality: ResponseConverterQualityGood},
+		{lookupID: ResponseConverterOAIEmbedToOllamaEmbed, id: ResponseConverterOAIEmbedToOllamaEmbed, from: types.RelayFormatEmbedding, to: types.RelayFormatOllama, quality: ResponseConverterQualityGood},
+		{lookupID: ResponseConverterBedrockToClaude, id: ConverterBedrockConverseToClaude, from: types.RelayFormatBedrockConverse, to: types.RelayFormatClaude, quality: ResponseConverterQualityGood},
+		{lookupID: ResponseConverterClaudeToBedrock, id: ConverterClaudeToBedrockConverse, from: types.RelayFormatClaude, to: types.RelayFormatBedrockConverse, quality: ResponseConverterQualityGood},
+		{lookupID: ConverterOpenAIEmbedToGeminiEmbed, id: ConverterOpenAIEmbedToGeminiEmbed, from: types.RelayFormatEmbedding, to: types.RelayFormatGeminiEmbedding, quality: ResponseConverterQualityGood},
+		{lookupID: ConverterGeminiEmbedToOpenAIEmbed, id: ConverterGeminiEmbedToOpenAIEmbed, from: types.RelayFormatGeminiEmbedding, to: types.RelayFormatEmbedding, quality: ResponseConverterQualityFair},
+		{lookupID: ConverterOpenAIEmbedToCohereEmbed, id: ConverterOpenAIEmbedToCohereEmbed, from: types.RelayFormatEmbedding, to: types.RelayFormatCohereEmbedding, quality: ResponseConverterQualityGood},
+		{lookupID: ConverterCohereEmbedToOpenAIEmbed, id: ConverterCohereEmbedToOpenAIEmbed, from: types.RelayFormatCohereEmbedding, to: types.RelayFormatEmbedding, quality: ResponseConverterQualityFair},
+		{lookupID: ConverterGeminiEmbedToCohereEmbed, id: ConverterGeminiEmbedToCohereEmbed, from: types.RelayFormatGeminiEmbedding, to: types.RelayFormatCohereEmbedding, quality: ResponseConverterQualityGood},
+		{lookupID: ConverterCohereEmbedToGeminiEmbed, id: ConverterCohereEmbedToGeminiEmbed, from: types.RelayFormatCohereEmbedding, to: types.RelayFormatGeminiEmbedding, quality: ResponseConverterQualityGood},
+		{lookupID: ConverterOpenAIImageToGeminiImage, id: ConverterOpenAIImageToGeminiImage, from: types.RelayFormatOpenAIImage, to: types.RelayFormatGemini, quality: ResponseConverterQualityGood},
+		{lookupID: ConverterGeminiImageToOpenAIImage, id: ConverterGeminiImageToOpenAIImage, from: types.RelayFormatGemini, to: types.RelayFormatOpenAIImage, quality: ResponseConverterQualityFair},
+		{lookupID: ConverterOpenAIImageToImagen, id: ConverterOpenAIImageToImagen, from: types.RelayFormatOpenAIImage, to: types.RelayFormatGeminiImagen, quality: ResponseConverterQualityGood},
+		{lookupID: ConverterImagenToOpenAIImage, id: ConverterImagenToOpenAIImage, from: types.RelayFormatGeminiImagen, to: types.RelayFormatOpenAIImage, quality: ResponseConverterQualityGood},
 		{
 			lookupID: responseConverterClaudeToGemini,
 			id:       requestConverterClaudeToGemini,
@@ -489,6 +508,234 @@ func TestConvertStreamResponseStatefulDirectConverters(t *testing.T) {
 	require.IsType(t, dto.ChatCompletionsStreamResponse{}, responsesResults[len(responsesResults)-1].Value)
 }
 
+func TestConvertStreamResponseOpenAIChatToOllama(t *testing.T) {
+	state, err := NewResponseStreamState(types.RelayFormatOpenAI, types.RelayFormatOllama, ResponseStreamOptions{Model: "llama3"})
+	require.NoError(t, err)
+
+	results, err := ConvertStreamResponseChunk(nil, nil, state, &dto.ChatCompletionsStreamResponse{
+		Model: "upstream-model",
+		Choices: []dto.ChatCompletionsStreamResponseChoice{
+			{Delta: dto.ChatCompletionsStreamResponseChoiceDelta{Content: respPtr("hel")}},
+		},
+	})
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	chunk := results[0].Value.(*dto.OllamaChatResponse)
+	assert.Equal(t, "llama3", chunk.Model)
+	assert.Equal(t, "hel", chunk.Message.Content)
+	assert.False(t, chunk.Done)
+
+	// 工具调用参数分多个分片到达，在 finish_reason 时合并为一个完整调用
+	index := 0
+	for _, args := range []string{`{"q":`, `"cat"}`} {
+		results, err = ConvertStreamResponseChunk(nil, nil, state, &dto.ChatCompletionsStreamResponse{
+			Choices: []dto.ChatCompletionsStreamResponseChoice{
+				{Delta: dto.ChatCompletionsStreamResponseChoiceDelta{ToolCalls: []dto.ToolCallResponse{{Index: &index, Function: dto.FunctionResponse{Name: "lookup", Arguments: args}}}}},
+			},
+		})
+		require.NoError(t, err)
+		assert.Empty(t, results)
+	}
+	results, err = ConvertStreamResponseChunk(nil, nil, state, &dto.ChatCompletionsStreamResponse{
+		Choices: []dto.ChatCompletionsStreamResponseChoice{{FinishReason: respPtr("tool_calls")}},
+	})
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	toolChunk := results[0].Value.(*dto.OllamaChatResponse)
+	require.Len(t, toolChunk.Message.ToolCalls, 1)
+	assert.Equal(t, "lookup", toolChunk.Message.ToolCalls[0].Function.Name)
+	assert.JSONEq(t, `{"q":"cat"}`, string(toolChunk.Message.ToolCalls[0].Function.Arguments))
+
+	_, err = ConvertStreamResponseChunk(nil, nil, state, &dto.ChatCompletionsStreamResponse{
+		Choices: []dto.ChatCompletionsStreamResponseChoice{},
+		Usage:   &dto.Usage{PromptTokens: 7, CompletionTokens: 3, TotalTokens: 10},
+	})
+	require.NoError(t, err)
+
+	finalResults, err := FinalizeStreamResponse(nil, nil, state)
+	require.NoError(t, err)
+	require.Len(t, finalResults, 1)
+	final := finalResults[0].Value.(*dto.OllamaChatResponse)
+	assert.True(t, final.Done)
+	assert.Equal(t, "stop", final.DoneReason)
+	assert.Equal(t, 7, final.PromptEvalCount)
+	assert.Equal(t, 3, final.EvalCount)
+	assert.Equal(t, 10, state.Usage().TotalTokens)
+}
+
+func TestConvertStreamResponseClaudeToBedrockConverse(t *testing.T) {
+	state, err := NewResponseStreamState(types.RelayFormatClaude, types.RelayFormatBedrockConverse, ResponseStreamOptions{})
+	require.NoError(t, err)
+
+	convert := func(chunk *dto.ClaudeResponse) []*dto.BedrockConverseStreamEvent {
+		results, err := ConvertStreamResponseChunk(nil, nil, state, chunk)
+		require.NoError(t, err)
+		events := make([]*dto.BedrockConverseStreamEvent, 0, len(results))
+		for _, result := range results {
+			events = append(events, result.Value.(*dto.BedrockConverseStreamEvent))
+		}
+		return events
+	}
+
+	events := convert(&dto.ClaudeResponse{Type: "message_start", Message: &dto.ClaudeMediaMessage{Usage: &dto.ClaudeUsage{InputTokens: 12, CacheReadInputTokens: 3}}})
+	require.Len(t, events, 1)
+	assert.Equal(t, dto.BedrockConverseEventMessageStart, events[0].EventType)
+	assert.Equal(t, "assistant", events[0].Role)
+
+	events = convert(&dto.ClaudeResponse{Type: "content_block_start", Index: respPtr(0), ContentBlock: &dto.ClaudeMediaMessage{Type: "text", Text: respPtr("")}})
+	assert.Empty(t, events)
+	events = convert(&dto.ClaudeResponse{Type: "content_block_delta", Index: respPtr(0), Delta: &dto.ClaudeMediaMessage{Type: "text_delta", Text: respPtr("hi")}})
+	require.Len(t, events, 1)
+	assert.Equal(t, "hi", *events[0].Delta.Text)
+	assert.Equal(t, 0, *events[0].ContentBlockIndex)
+
+	events = convert(&dto.ClaudeResponse{Type: "content_block_start", Index: respPtr(1), ContentBlock: &dto.ClaudeMediaMessage{Type: "tool_use", Id: "toolu_1", Name: "lookup"}})
+	require.Len(t, events, 1)
+	assert.Equal(t, dto.BedrockConverseEventContentBlockStart, events[0].EventType)
+	assert.Equal(t, "toolu_1", events[0].Start.ToolUse.ToolUseId)
+	events = convert(&dto.ClaudeResponse{Type: "content_block_delta", Index: respPtr(1), Delta: &dto.ClaudeMediaMessage{Type: "input_json_delta", PartialJson: respPtr(`{"q":`)}})
+	require.Len(t, events, 1)
+	assert.Equal(t, `{"q":`, events[0].Delta.ToolUse.Input)
+	events = convert(&dto.ClaudeResponse{Type: "content_block_stop", Index: respPtr(1)})
+	require.Len(t, events, 1)
+	assert.Equal(t, dto.BedrockConverseEventContentBlockStop, events[0].EventType)
+	assert.Equal(t, 1, *events[0].ContentBlockIndex)
+
+	events = convert(&dto.ClaudeResponse{Type: "message_delta", Delta: &dto.ClaudeMediaMessage{StopReason: respPtr("tool_use")}, Usage: &dto.ClaudeUsage{OutputTokens: 7}})
+	assert.Empty(t, events)
+	assert.Empty(t, convert(&dto.ClaudeResponse{Type: "message_stop"}))
+
+	finalResults, err := FinalizeStreamResponse(nil, nil, state)
+	require.NoError(t, err)
+	require.Len(t, finalResults, 2)
+	stop := finalResults[0].Value.(*dto.BedrockConverseStreamEvent)
+	assert.Equal(t, dto.BedrockConverseEventMessageStop, stop.EventType)
+	assert.Equal(t, "tool_use", stop.StopReason)
+	metadata := finalResults[1].Value.(*dto.BedrockConverseStreamEvent)
+	assert.Equal(t, dto.BedrockConverseEventMetadata, metadata.EventType)
+	assert.Equal(t, dto.BedrockConverseUsage{InputTokens: 12, OutputTokens: 7, TotalTokens: 22, CacheReadInputTokens: 3}, *metadata.Usage)
+
+	payload, err := json.Marshal(stop)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"stopReason":"tool_use"}`, string(payload))
+}
+
+func TestConvertResponseClaudeToBedrockConverse(t *testing.T) {
+	result, err := ConvertResponse(nil, nil, types.RelayFormatBedrockConverse, &dto.ClaudeResponse{
+		Type: "message",
+		Content: []dto.ClaudeMediaMessage{
+			{Type: "thinking", Thinking: respPtr("hmm"), Signature: "sig"},
+			{Type: "text", Text: respPtr("hello")},
+			{Type: "tool_use", Id: "toolu_1", Name: "lookup", Input: map[string]any{"q": "cat"}},
+		},
+		StopReason: "refusal",
+		Usage:      &dto.ClaudeUsage{InputTokens: 4, OutputTokens: 2},
+	})
+	require.NoError(t, err)
+	converse := result.Value.(*dto.BedrockConverseResponse)
+	require.Len(t, converse.Output.Message.Content, 3)
+	assert.Equal(t, "sig", converse.Output.Message.Content[0].ReasoningContent.ReasoningText.Signature)
+	assert.Equal(t, "hello", *converse.Output.Message.Content[1].Text)
+	assert.JSONEq(t, `{"q":"cat"}`, string(converse.Output.Message.Content[2].ToolUse.Input))
+	assert.Equal(t, "content_filtered", converse.StopReason)
+	assert.Equal(t, 6, converse.Usage.TotalTokens)
+	assert.Equal(t, 4, result.Usage.PromptTokens)
+}
+
+func TestConvertResponseOpenAIToOllama(t *testing.T) {
+	result, err := ConvertResponse(nil, nil, types.RelayFormatOllama, &dto.OpenAITextResponse{
+		Model: "gpt-test",
+		Choices: []dto.OpenAITextResponseChoice{
+			{Message: dto.Message{Role: "assistant", Content: "hello"}, FinishReason: "length"},
+		},
+		Usage: dto.Usage{PromptTokens: 4, CompletionTokens: 2, TotalTokens: 6},
+	})
+	require.NoError(t, err)
+	chat := result.Value.(*dto.OllamaChatResponse)
+	assert.Equal(t, "hello", chat.Message.Content)
+	assert.True(t, chat.Done)
+	assert.Equal(t, "length", chat.DoneReason)
+	assert.Equal(t, 4, chat.PromptEvalCount)
+	assert.Equal(t, "hello", chat.ToGenerate().Response)
+
+	result, err = ConvertResponse(nil, nil, types.RelayFormatOllama, &dto.EmbeddingResponse{
+		Model: "text-embedding",
+		Data: []dto.EmbeddingResponseItem{
+			{Index: 1, Embedding: []float64{2}},
+			{Index: 0, Embedding: []float64{1}},
+		},
+		Usage: dto.Usage{PromptTokens: 5},
+	})
+	require.NoError(t, err)
+	embed := result.Value.(*dto.OllamaEmbedResponse)
+	assert.Equal(t, [][]float64{{1}, {2}}, embed.Embeddings)
+	assert.Equal(t, 5, embed.PromptEvalCount)
+	assert.Equal(t, 5, result.Usage.PromptTokens)
+}
+
+func TestConvertResponseOpenAIChatAndCompletions(t *testing.T) {
+	result, err := ConvertResponse(nil, nil, types.RelayFormatOpenAICompletions, &dto.OpenAITextResponse{
+		Id:      "chatcmpl-abc",
+		Model:   "claude-sonnet",
+		Created: float64(1700000000),
+		Choices: []dto.OpenAITextResponseChoice{
+			{Message: dto.Message{Role: "assistant", Content: "hello"}, FinishReason: "length"},
+		},
+		Usage: dto.Usage{PromptTokens: 4, CompletionTokens: 2, TotalTokens: 6},
+	})
+	require.NoError(t, err)
+	completions := result.Value.(*dto.CompletionsResponse)
+	assert.Equal(t, "cmpl-abc", completions.Id)
+	assert.Equal(t, "text_completion", completions.Object)
+	assert.Equal(t, int64(1700000000), completions.Created)
+	require.Len(t, completions.Choices, 1)
+	assert.Equal(t, "hello", completions.Choices[0].Text)
+	assert.Equal(t, "length", *completions.Choices[0].FinishReason)
+	assert.Equal(t, 6, completions.Usage.TotalTokens)
+	assert.Equal(t, 4, result.Usage.PromptTokens)
+
+	result, err = ConvertResponse(nil, nil, types.RelayFormatOpenAI, completions)
+	require.NoError(t, err)
+	chat := result.Value.(*dto.OpenAITextResponse)
+	assert.Equal(t, "chatcmpl-abc", chat.Id)
+	assert.Equal(t, "hello", chat.Choices[0].Message.StringContent())
+	assert.Equal(t, "length", chat.Choices[0].FinishReason)
+}
+
+func TestConvertStreamResponseOpenAIChatToCompletions(t *testing.T) {
+	roleOnly := &dto.ChatCompletionsStreamResponse{
+		Id:      "chatcmpl-abc",
+		Choices: []dto.ChatCompletionsStreamResponseChoice{{Delta: dto.ChatCompletionsStreamResponseChoiceDelta{Role: "assistant"}}},
+	}
+	result, err := ConvertStreamResponse(nil, nil, types.RelayFormatOpenAICompletions, roleOnly)
+	require.NoError(t, err)
+	assert.Nil(t, result.Value)
+
+	content := &dto.ChatCompletionsStreamResponse{
+		Id:      "chatcmpl-abc",
+		Model:   "claude-sonnet",
+		Choices: []dto.ChatCompletionsStreamResponseChoice{{Delta: dto.ChatCompletionsStreamResponseChoiceDelta{Content: common.GetPointer("hel")}}},
+	}
+	result, err = ConvertStreamResponse(nil, nil, types.RelayFormatOpenAICompletions, content)
+	require.NoError(t, err)
+	chunk := result.Value.(*dto.CompletionsResponse)
+	assert.Equal(t, "cmpl-abc", chunk.Id)
+	require.Len(t, chunk.Choices, 1)
+	assert.Equal(t, "hel", chunk.Choices[0].Text)
+	assert.Nil(t, chunk.Choices[0].FinishReason)
+
+	usageOnly := &dto.ChatCompletionsStreamResponse{
+		Id:    "chatcmpl-abc",
+		Usage: &dto.Usage{PromptTokens: 3, CompletionTokens: 1, TotalTokens: 4},
+	}
+	result, err = ConvertStreamResponse(nil, nil, types.RelayFormatOpenAICompletions, usageOnly)
+	require.NoError(t, err)
+	chunk = result.Value.(*dto.CompletionsResponse)
+	assert.Empty(t, chunk.Choices)
+	assert.Equal(t, 4, chunk.Usage.TotalTokens)
+	assert.Equal(t, 4, result.Usage.TotalTokens)
+}
+
 func TestConvertStreamResponseStatefulMultiHopResponsesToClaude(t *testing.T) {
 	info := &relaycommon.RelayInfo{
 		ClaudeConvertInfo: &relaycommon.ClaudeConvertInfo{
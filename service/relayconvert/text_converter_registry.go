@@ -135,6 +135,92 @@ var builtinTextConverters = []TextConverterSpec{
 			Aliases:            []string{ResponseConverterOAIResponsesToOAIChat},
 		},
 	},
+	{
+		ID:      ConverterOpenAICompletionsToOpenAIChat,
+		From:    types.RelayFormatOpenAICompletions,
+		To:      types.RelayFormatOpenAI,
+		Quality: TextConverterQualityFair,
+		Req: TextRequestSide{
+			Convert: convertCompletionsRequestToOpenAI,
+		},
+		Resp: TextResponseSide{
+			Convert:       convertCompletionsResponseToOAIChat,
+			ConvertStream: convertCompletionsStreamResponseToOAIChat,
+			Aliases:       []string{ResponseConverterCompletionsToOAIChat},
+		},
+	},
+	{
+		ID:      ConverterOpenAIChatToOpenAICompletions,
+		From:    types.RelayFormatOpenAI,
+		To:      types.RelayFormatOpenAICompletions,
+		Quality: TextConverterQualityDiscouraged,
+		Req: TextRequestSide{
+			Convert: convertOpenAIRequestToCompletions,
+		},
+		Resp: TextResponseSide{
+			Convert:       convertOAIChatResponseToCompletions,
+			ConvertStream: convertOAIChatStreamResponseToCompletions,
+			Aliases:       []string{ResponseConverterOAIChatToCompletions},
+		},
+	},
+	{
+		ID:      ConverterOllamaChatToOpenAIChat,
+		From:    types.RelayFormatOllama,
+		To:      types.RelayFormatOpenAI,
+		Quality: TextConverterQualityGood,
+		Req: TextRequestSide{
+			Convert: convertOllamaRequestToOpenAI,
+		},
+		Resp: TextResponseSide{
+			Convert: convertOllamaChatResponseToOAIChat,
+			Aliases: []string{ResponseConverterOllamaChatToOAIChat},
+		},
+	},
+	{
+		ID:      ConverterOpenAIChatToOllamaChat,
+		From:    types.RelayFormatOpenAI,
+		To:      types.RelayFormatOllama,
+		Quality: TextConverterQualityGood,
+		Req: TextRequestSide{
+			Convert: convertOpenAIRequestToOllama,
+		},
+		Resp: TextResponseSide{
+			Convert:            convertOAIChatResponseToOllamaChat,
+			NewStreamState:     newOAIChatToOllamaChatStreamState,
+			ConvertStreamChunk: convertOAIChatStreamResponseToOllamaChat,
+			FinalizeStream:     finalizeOAIChatStreamResponseToOllamaChat,
+			Aliases:            []string{ResponseConverterOAIChatToOllamaChat},
+		},
+	},
+	{
+		ID:      ConverterBedrockConverseToClaude,
+		From:    types.RelayFormatBedrockConverse,
+		To:      types.RelayFormatClaude,
+		Quality: TextConverterQualityGood,
+		Req: TextRequestSide{
+			Convert: convertBedrockConverseRequestToClaude,
+		},
+		Resp: TextResponseSide{
+			Convert: convertBedrockConverseResponseToClaude,
+			Aliases: []string{ResponseConverterBedrockToClaude},
+		},
+	},
+	{
+		ID:      ConverterClaudeToBedrockConverse,
+		From:    types.RelayFormatClaude,
+		To:      types.RelayFormatBedrockConverse,
+		Quality: TextConverterQualityGood,
+		Req: TextRequestSide{
+			Convert: convertClaudeRequestToBedrockConverse,
+		},
+		Resp: TextResponseSide{
+			Convert:            convertClaudeResponseToBedrockConverse,
+			NewStreamState:     newClaudeToBedrockConverseStreamState,
+			ConvertStreamChunk: convertClaudeStreamResponseToBedrockConverse,
+			FinalizeStream:     finalizeClaudeStreamResponseToBedrockConverse,
+			Aliases:            []string{ResponseConverterClaudeToBedrock},
+		},
+	},
 	{
 		ID:      requestConverterClaudeToGemini,
 		From:    types.RelayFormatClaude,
@@ -249,6 +335,8 @@ func init() {
 	for _, spec := range builtinTextConverters {
 		registerBuiltinTextConverter(spec)
 	}
+	registerBuiltinEmbeddingConverters()
+	registerBuiltinImageConverters()
 }
 
 func LookupTextConverter(converter string) (TextConverterSpec, bool) {
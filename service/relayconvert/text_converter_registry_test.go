@@ -27,6 +27,12 @@ func TestLookupBuiltinTextConverters(t *testing.T) {
 		{id: ConverterOpenAIChatToGeminiContent, from: types.RelayFormatOpenAI, to: types.RelayFormatGemini, quality: TextConverterQualityFair, reqDirect: true, respDirect: true, respAlias: ResponseConverterOAIChatToGeminiChat},
 		{id: ConverterOpenAIChatToOpenAIResponses, from: types.RelayFormatOpenAI, to: types.RelayFormatOpenAIResponses, quality: TextConverterQualityGood, reqDirect: true, respDirect: true, respAlias: ResponseConverterOAIChatToOAIResponses, streamDirect: true},
 		{id: ConverterOpenAIResponsesToOpenAIChat, from: types.RelayFormatOpenAIResponses, to: types.RelayFormatOpenAI, quality: TextConverterQualityGood, reqDirect: true, respDirect: true, respAlias: ResponseConverterOAIResponsesToOAIChat, streamDirect: true},
+		{id: ConverterOpenAICompletionsToOpenAIChat, from: types.RelayFormatOpenAICompletions, to: types.RelayFormatOpenAI, quality: TextConverterQualityFair, reqDirect: true, respDirect: true, respAlias: ResponseConverterCompletionsToOAIChat},
+		{id: ConverterOpenAIChatToOpenAICompletions, from: types.RelayFormatOpenAI, to: types.RelayFormatOpenAICompletions, quality: TextConverterQualityDiscouraged, reqDirect: true, respDirect: true, respAlias: ResponseConverterOAIChatToCompletions},
+		{id: ConverterOllamaChatToOpenAIChat, from: types.RelayFormatOllama, to: types.RelayFormatOpenAI, quality: TextConverterQualityGood, reqDirect: true, respDirect: true, respAlias: ResponseConverterOllamaChatToOAIChat},
+		{id: ConverterOpenAIChatToOllamaChat, from: types.RelayFormatOpenAI, to: types.RelayFormatOllama, quality: TextConverterQualityGood, reqDirect: true, respDirect: true, respAlias: ResponseConverterOAIChatToOllamaChat, streamDirect: true},
+		{id: ConverterBedrockConverseToClaude, from: types.RelayFormatBedrockConverse, to: types.RelayFormatClaude, quality: TextConverterQualityGood, reqDirect: true, respDirect: true, respAlias: ResponseConverterBedrockToClaude},
+		{id: ConverterClaudeToBedrockConverse, from: types.RelayFormatClaude, to: types.RelayFormatBedrockConverse, quality: TextConverterQualityGood, reqDirect: true, respDirect: true, respAlias: ResponseConverterClaudeToBedrock, streamDirect: true},
 		{
 			id:      requestConverterClaudeToGemini,
 			from:    types.RelayFormatClaude,
@@ -0,0 +1,231 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+
+	"github.com/bytedance/gopkg/util/gopool"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	responsesStoreCleanupTickInterval = 30 * time.Minute
+	responsesStoreCleanupBatchSize    = 1000
+)
+
+var (
+	responsesStoreCleanupOnce    sync.Once
+	responsesStoreCleanupRunning atomic.Bool
+)
+
+// responsesTurn 为当前 /v1/responses 请求展开后的上下文，转换后的响应写回时一并保存
+type responsesTurn struct {
+	InputItems         []any
+	PreviousResponseID string
+}
+
+// PrepareResponsesState 为无状态上游模拟 Responses API 的会话状态：若 previous_response_id 指向网关代存的响应，
+// 则把历史输入与输出展开到 req.Input 并清除 previous_response_id；未命中时保持原样交给上游处理。
+// 同时把本轮完整输入记录到上下文，供 StoreEmulatedResponse 保存
+func PrepareResponsesState(c *gin.Context, req *dto.OpenAIResponsesRequest) error {
+	if !operation_setting.GetResponsesStoreSetting().Enabled || req == nil {
+		return nil
+	}
+	items, err := responsesInputItems(req.Input)
+	if err != nil {
+		return err
+	}
+
+	turn := &responsesTurn{}
+	if previousID := strings.TrimSpace(req.PreviousResponseID); previousID != "" {
+		stored, err := model.GetStoredResponse(c.GetInt("id"), previousID)
+		if err != nil {
+			return fmt.Errorf("query stored response failed: %w", err)
+		}
+		if stored != nil {
+			history, err := storedResponseHistory(stored)
+			if err != nil {
+				return err
+			}
+			items = append(history, items...)
+			input, err := common.Marshal(items)
+			if err != nil {
+				return err
+			}
+			req.Input = input
+			req.PreviousResponseID = ""
+			turn.PreviousResponseID = previousID
+		}
+	}
+
+	if responsesStoreDisabled(req.Store) {
+		return nil
+	}
+	turn.InputItems = items
+	common.SetContextKey(c, constant.ContextKeyResponsesInputItems, turn)
+	return nil
+}
+
+// StoreEmulatedResponse 保存由网关转换生成的 Responses API 响应，返回是否已保存。
+// 只有经过 PrepareResponsesState 且客户端未关闭 store 的请求才会保存
+func StoreEmulatedResponse(c *gin.Context, resp *dto.OpenAIResponsesResponse) bool {
+	setting := operation_setting.GetResponsesStoreSetting()
+	if !setting.Enabled || resp == nil || resp.ID == "" {
+		return false
+	}
+	value, ok := common.GetContextKey(c, constant.ContextKeyResponsesInputItems)
+	if !ok {
+		return false
+	}
+	turn, ok := value.(*responsesTurn)
+	if !ok || turn == nil {
+		return false
+	}
+
+	resp.Store = true
+	if turn.PreviousResponseID != "" {
+		resp.PreviousResponseID, _ = common.Marshal(turn.PreviousResponseID)
+	}
+	inputItems, err := common.Marshal(turn.InputItems)
+	if err != nil {
+		return false
+	}
+	responseBody, err := common.Marshal(resp)
+	if err != nil {
+		return false
+	}
+	size := len(inputItems) + len(responseBody)
+	if setting.MaxSizeKB > 0 && size > setting.MaxSizeKB*1024 {
+		logger.LogWarn(c, fmt.Sprintf("stored response %s skipped: size %d exceeds limit %dKB", resp.ID, size, setting.MaxSizeKB))
+		return false
+	}
+
+	ttlHours := setting.TTLHours
+	if ttlHours <= 0 {
+		ttlHours = 30 * 24
+	}
+	now := common.GetTimestamp()
+	record := &model.StoredResponse{
+		ResponseId:         resp.ID,
+		UserId:             c.GetInt("id"),
+		Model:              resp.Model,
+		PreviousResponseId: turn.PreviousResponseID,
+		InputItems:         string(inputItems),
+		Response:           string(responseBody),
+		Size:               size,
+		CreatedAt:          now,
+		ExpiresAt:          now + int64(ttlHours)*3600,
+	}
+	if err := record.Insert(); err != nil {
+		logger.LogWarn(c, fmt.Sprintf("store response %s failed: %v", resp.ID, err))
+		return false
+	}
+	return true
+}
+
+// responsesInputItems 把 input（字符串或条目数组）统一为条目数组
+func responsesInputItems(input json.RawMessage) ([]any, error) {
+	switch common.GetJsonType(input) {
+	case "", "null":
+		return []any{}, nil
+	case "string":
+		var text string
+		if err := common.Unmarshal(input, &text); err != nil {
+			return nil, fmt.Errorf("invalid input string: %w", err)
+		}
+		return []any{map[string]any{"type": "message", "role": "user", "content": text}}, nil
+	case "array":
+		var items []any
+		if err := common.Unmarshal(input, &items); err != nil {
+			return nil, fmt.Errorf("invalid input array: %w", err)
+		}
+		return items, nil
+	default:
+		return nil, fmt.Errorf("unsupported responses input type %q", common.GetJsonType(input))
+	}
+}
+
+// storedResponseHistory 返回已存响应的完整上下文：历史输入加上该轮的输出条目。
+// 输出中只保留可以作为输入回放的消息与函数调用，推理等条目被丢弃
+func storedResponseHistory(stored *model.StoredResponse) ([]any, error) {
+	var history []any
+	if err := common.UnmarshalJsonStr(stored.InputItems, &history); err != nil {
+		return nil, fmt.Errorf("invalid stored response input: %w", err)
+	}
+	var resp dto.OpenAIResponsesResponse
+	if err := common.UnmarshalJsonStr(stored.Response, &resp); err != nil {
+		return nil, fmt.Errorf("invalid stored response: %w", err)
+	}
+	for _, output := range resp.Output {
+		switch output.Type {
+		case dto.ResponsesOutputTypeMessage:
+			content := make([]any, 0, len(output.Content))
+			for _, part := range output.Content {
+				content = append(content, map[string]any{"type": part.Type, "text": part.Text})
+			}
+			history = append(history, map[string]any{
+				"type":    "message",
+				"role":    "assistant",
+				"content": content,
+			})
+		case dto.ResponsesOutputTypeFunctionCall:
+			history = append(history, map[string]any{
+				"type":      "function_call",
+				"call_id":   output.CallId,
+				"name":      output.Name,
+				"arguments": output.ArgumentsString(),
+			})
+		}
+	}
+	return history, nil
+}
+
+func responsesStoreDisabled(store json.RawMessage) bool {
+	return common.GetJsonType(store) == "boolean" && strings.TrimSpace(string(store)) == "false"
+}
+
+func StartResponsesStoreCleanupTask() {
+	responsesStoreCleanupOnce.Do(func() {
+		if !common.IsMasterNode {
+			return
+		}
+		gopool.Go(func() {
+			logger.LogInfo(context.Background(), fmt.Sprintf("stored responses cleanup task started: tick=%s", responsesStoreCleanupTickInterval))
+			ticker := time.NewTicker(responsesStoreCleanupTickInterval)
+			defer ticker.Stop()
+
+			runResponsesStoreCleanupOnce()
+			for range ticker.C {
+				runResponsesStoreCleanupOnce()
+			}
+		})
+	})
+}
+
+func runResponsesStoreCleanupOnce() {
+	if !responsesStoreCleanupRunning.CompareAndSwap(false, true) {
+		return
+	}
+	defer responsesStoreCleanupRunning.Store(false)
+
+	deleted, err := model.CleanupExpiredStoredResponses(responsesStoreCleanupBatchSize)
+	if err != nil {
+		logger.LogWarn(context.Background(), fmt.Sprintf("stored responses cleanup failed: %v", err))
+		return
+	}
+	if common.DebugEnabled && deleted > 0 {
+		logger.LogDebug(context.Background(), "stored responses cleanup: deleted=%d", deleted)
+	}
+}
@@ -0,0 +1,88 @@
+package service
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func useResponsesStore(t *testing.T) {
+	t.Helper()
+	setting := operation_setting.GetResponsesStoreSetting()
+	saved := *setting
+	t.Cleanup(func() { *setting = saved })
+	setting.Enabled = true
+	setting.TTLHours = 1
+	setting.MaxSizeKB = 64
+}
+
+func newResponsesContext(userId int) *gin.Context {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest("POST", "/v1/responses", nil)
+	c.Set("id", userId)
+	return c
+}
+
+func TestResponsesStoreChainsPreviousResponse(t *testing.T) {
+	truncate(t)
+	useResponsesStore(t)
+
+	// 第一轮：字符串输入，响应被保存
+	c := newResponsesContext(1)
+	first := &dto.OpenAIResponsesRequest{Model: "test-model", Input: []byte(`"hi"`)}
+	require.NoError(t, PrepareResponsesState(c, first))
+	assert.True(t, StoreEmulatedResponse(c, &dto.OpenAIResponsesResponse{
+		ID:    "resp_1",
+		Model: "test-model",
+		Output: []dto.ResponsesOutput{
+			{Type: "reasoning", ID: "rs_1"},
+			{Type: "message", Role: "assistant", Content: []dto.ResponsesOutputContent{{Type: "output_text", Text: "hello"}}},
+		},
+	}))
+
+	// 第二轮：previous_response_id 展开为完整上下文
+	c = newResponsesContext(1)
+	second := &dto.OpenAIResponsesRequest{Model: "test-model", Input: []byte(`"again"`), PreviousResponseID: "resp_1"}
+	require.NoError(t, PrepareResponsesState(c, second))
+	assert.Empty(t, second.PreviousResponseID)
+	var items []map[string]any
+	require.NoError(t, common.Unmarshal(second.Input, &items))
+	require.Len(t, items, 3)
+	assert.Equal(t, "hi", items[0]["content"])
+	assert.Equal(t, "assistant", items[1]["role"])
+	assert.Equal(t, "again", items[2]["content"])
+
+	resp := &dto.OpenAIResponsesResponse{ID: "resp_2", Model: "test-model"}
+	assert.True(t, StoreEmulatedResponse(c, resp))
+	stored, err := model.GetStoredResponse(1, "resp_2")
+	require.NoError(t, err)
+	require.NotNil(t, stored)
+	assert.Equal(t, "resp_1", stored.PreviousResponseId)
+
+	// 其他用户不可见，未命中时原样交给上游
+	c = newResponsesContext(2)
+	other := &dto.OpenAIResponsesRequest{Model: "test-model", Input: []byte(`"x"`), PreviousResponseID: "resp_1"}
+	require.NoError(t, PrepareResponsesState(c, other))
+	assert.Equal(t, "resp_1", other.PreviousResponseID)
+
+	deleted, err := model.DeleteStoredResponse(1, "resp_1")
+	require.NoError(t, err)
+	assert.True(t, deleted)
+}
+
+func TestResponsesStoreSkipsWhenStoreFalse(t *testing.T) {
+	truncate(t)
+	useResponsesStore(t)
+
+	c := newResponsesContext(1)
+	req := &dto.OpenAIResponsesRequest{Model: "test-model", Input: []byte(`"hi"`), Store: []byte(`false`)}
+	require.NoError(t, PrepareResponsesState(c, req))
+	assert.False(t, StoreEmulatedResponse(c, &dto.OpenAIResponsesResponse{ID: "resp_nostore"}))
+}
@@ -0,0 +1,195 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+
+	"github.com/bytedance/gopkg/util/gopool"
+)
+
+const (
+	shadowComparisonCleanupTickInterval = time.Hour
+	shadowComparisonCleanupBatchSize    = 1000
+	// shadowTextCompareMaxRunes bounds the text fed into similarity scoring so a
+	// long completion can't turn a background comparison into a CPU hog.
+	shadowTextCompareMaxRunes = 32 * 1024
+)
+
+var (
+	shadowTrafficInFlight atomic.Int64
+
+	shadowComparisonCleanupOnce    sync.Once
+	shadowComparisonCleanupRunning atomic.Bool
+)
+
+// MatchShadowTrafficRule returns the first enabled rule covering group/model
+// whose dice roll falls inside its percentage. A failed roll moves on to the
+// next matching rule. Rules pointing at the channel that already served the
+// primary request are skipped.
+func MatchShadowTrafficRule(group string, modelName string, primaryChannelId int) *operation_setting.ShadowTrafficRule {
+	setting := operation_setting.GetShadowTrafficSetting()
+	if setting == nil || !setting.Enabled {
+		return nil
+	}
+	for i := range setting.Rules {
+		rule := &setting.Rules[i]
+		if rule.ShadowChannelId <= 0 || rule.ShadowChannelId == primaryChannelId || rule.Percent <= 0 {
+			continue
+		}
+		if len(rule.Groups) > 0 && !containsTrimmed(rule.Groups, group) {
+			continue
+		}
+		if !matchAnyRegexCached(rule.ModelRegex, modelName) {
+			continue
+		}
+		if rule.Percent < 100 && rand.Float64()*100 >= rule.Percent {
+			continue
+		}
+		return rule
+	}
+	return nil
+}
+
+func containsTrimmed(items []string, target string) bool {
+	for _, item := range items {
+		if strings.TrimSpace(item) == target {
+			return true
+		}
+	}
+	return false
+}
+
+// TryAcquireShadowTrafficSlot reserves one background shadow call. Callers
+// must pair a successful acquire with ReleaseShadowTrafficSlot.
+func TryAcquireShadowTrafficSlot() bool {
+	limit := int64(operation_setting.GetShadowTrafficSetting().MaxInFlight)
+	if limit <= 0 {
+		limit = 16
+	}
+	if shadowTrafficInFlight.Add(1) > limit {
+		shadowTrafficInFlight.Add(-1)
+		return false
+	}
+	return true
+}
+
+func ReleaseShadowTrafficSlot() {
+	shadowTrafficInFlight.Add(-1)
+}
+
+func ShadowTrafficTimeout() time.Duration {
+	seconds := operation_setting.GetShadowTrafficSetting().TimeoutSeconds
+	if seconds <= 0 {
+		seconds = 120
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// ShadowTextSimilarity scores two responses with a word-bag Jaccard index in
+// [0, 1]. It is a coarse signal for spotting a shadow channel that answers
+// something entirely different, not a semantic equivalence check.
+func ShadowTextSimilarity(a string, b string) float64 {
+	wordsA := shadowWordSet(a)
+	wordsB := shadowWordSet(b)
+	if len(wordsA) == 0 && len(wordsB) == 0 {
+		return 1
+	}
+	if len(wordsA) == 0 || len(wordsB) == 0 {
+		return 0
+	}
+	intersection := 0
+	for w := range wordsA {
+		if _, ok := wordsB[w]; ok {
+			intersection++
+		}
+	}
+	union := len(wordsA) + len(wordsB) - intersection
+	return float64(intersection) / float64(union)
+}
+
+func shadowWordSet(text string) map[string]struct{} {
+	runes := []rune(text)
+	if len(runes) > shadowTextCompareMaxRunes {
+		runes = runes[:shadowTextCompareMaxRunes]
+	}
+	set := make(map[string]struct{})
+	var current strings.Builder
+	flush := func() {
+		if current.Len() > 0 {
+			set[current.String()] = struct{}{}
+			current.Reset()
+		}
+	}
+	for _, r := range runes {
+		switch {
+		case unicode.Is(unicode.Han, r) || unicode.Is(unicode.Hiragana, r) || unicode.Is(unicode.Katakana, r):
+			// CJK text has no word separators; treat each character as a token.
+			flush()
+			set[string(r)] = struct{}{}
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			current.WriteRune(unicode.ToLower(r))
+		default:
+			flush()
+		}
+	}
+	flush()
+	return set
+}
+
+func RecordShadowComparison(ctx context.Context, comparison *model.ShadowComparison) {
+	if comparison == nil {
+		return
+	}
+	if err := comparison.Insert(); err != nil {
+		logger.LogWarn(ctx, fmt.Sprintf("record shadow comparison failed: shadow_channel_id=%d, err=%v", comparison.ShadowChannelId, err))
+	}
+}
+
+func StartShadowComparisonCleanupTask() {
+	shadowComparisonCleanupOnce.Do(func() {
+		if !common.IsMasterNode {
+			return
+		}
+		gopool.Go(func() {
+			logger.LogInfo(context.Background(), fmt.Sprintf("shadow comparison cleanup task started: tick=%s", shadowComparisonCleanupTickInterval))
+			ticker := time.NewTicker(shadowComparisonCleanupTickInterval)
+			defer ticker.Stop()
+
+			runShadowComparisonCleanupOnce()
+			for range ticker.C {
+				runShadowComparisonCleanupOnce()
+			}
+		})
+	})
+}
+
+func runShadowComparisonCleanupOnce() {
+	if !shadowComparisonCleanupRunning.CompareAndSwap(false, true) {
+		return
+	}
+	defer shadowComparisonCleanupRunning.Store(false)
+
+	retentionDays := operation_setting.GetShadowTrafficSetting().RetentionDays
+	if retentionDays <= 0 {
+		retentionDays = 30
+	}
+	deleted, err := model.CleanupShadowComparisonRecords(int64(retentionDays)*24*3600, shadowComparisonCleanupBatchSize)
+	if err != nil {
+		logger.LogWarn(context.Background(), fmt.Sprintf("shadow comparison cleanup failed: %v", err))
+		return
+	}
+	if common.DebugEnabled && deleted > 0 {
+		logger.LogDebug(context.Background(), "shadow comparison cleanup: deleted=%d", deleted)
+	}
+}
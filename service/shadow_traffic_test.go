@@ -0,0 +1,94 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+	"github.com/stretchr/testify/require"
+)
+
+func withShadowTrafficSetting(t *testing.T, setting operation_setting.ShadowTrafficSetting) {
+	t.Helper()
+	current := operation_setting.GetShadowTrafficSetting()
+	saved := *current
+	*current = setting
+	t.Cleanup(func() {
+		*current = saved
+	})
+}
+
+func TestMatchShadowTrafficRule(t *testing.T) {
+	withShadowTrafficSetting(t, operation_setting.ShadowTrafficSetting{
+		Enabled: true,
+		Rules: []operation_setting.ShadowTrafficRule{
+			{Name: "vip only", Groups: []string{"vip"}, ModelRegex: []string{"^gpt-"}, ShadowChannelId: 7, Percent: 100},
+			{Name: "all groups", ModelRegex: []string{"^claude-"}, ShadowChannelId: 8, Percent: 100},
+		},
+	})
+
+	rule := MatchShadowTrafficRule("vip", "gpt-4o", 1)
+	require.NotNil(t, rule)
+	require.Equal(t, 7, rule.ShadowChannelId)
+
+	require.Nil(t, MatchShadowTrafficRule("default", "gpt-4o", 1))
+
+	rule = MatchShadowTrafficRule("default", "claude-sonnet-4", 1)
+	require.NotNil(t, rule)
+	require.Equal(t, 8, rule.ShadowChannelId)
+
+	// The primary channel is never mirrored onto itself.
+	require.Nil(t, MatchShadowTrafficRule("default", "claude-sonnet-4", 8))
+}
+
+func TestMatchShadowTrafficRuleDisabledOrZeroPercent(t *testing.T) {
+	withShadowTrafficSetting(t, operation_setting.ShadowTrafficSetting{
+		Enabled: false,
+		Rules: []operation_setting.ShadowTrafficRule{
+			{Name: "off", ModelRegex: []string{".*"}, ShadowChannelId: 7, Percent: 100},
+		},
+	})
+	require.Nil(t, MatchShadowTrafficRule("default", "gpt-4o", 1))
+
+	withShadowTrafficSetting(t, operation_setting.ShadowTrafficSetting{
+		Enabled: true,
+		Rules: []operation_setting.ShadowTrafficRule{
+			{Name: "zero", ModelRegex: []string{".*"}, ShadowChannelId: 7, Percent: 0},
+		},
+	})
+	require.Nil(t, MatchShadowTrafficRule("default", "gpt-4o", 1))
+}
+
+func TestMatchShadowTrafficRuleFallsThroughFailedSampling(t *testing.T) {
+	withShadowTrafficSetting(t, operation_setting.ShadowTrafficSetting{
+		Enabled: true,
+		Rules: []operation_setting.ShadowTrafficRule{
+			// The roll for this rule effectively never succeeds.
+			{Name: "rare", ModelRegex: []string{"^gpt-"}, ShadowChannelId: 7, Percent: 1e-9},
+			{Name: "always", ModelRegex: []string{".*"}, ShadowChannelId: 8, Percent: 100},
+		},
+	})
+
+	rule := MatchShadowTrafficRule("default", "gpt-4o", 1)
+	require.NotNil(t, rule)
+	require.Equal(t, 8, rule.ShadowChannelId)
+}
+
+func TestShadowTrafficSlotLimit(t *testing.T) {
+	withShadowTrafficSetting(t, operation_setting.ShadowTrafficSetting{MaxInFlight: 2})
+
+	require.True(t, TryAcquireShadowTrafficSlot())
+	require.True(t, TryAcquireShadowTrafficSlot())
+	require.False(t, TryAcquireShadowTrafficSlot())
+	ReleaseShadowTrafficSlot()
+	require.True(t, TryAcquireShadowTrafficSlot())
+	ReleaseShadowTrafficSlot()
+	ReleaseShadowTrafficSlot()
+}
+
+func TestShadowTextSimilarity(t *testing.T) {
+	require.Equal(t, 1.0, ShadowTextSimilarity("", ""))
+	require.Equal(t, 0.0, ShadowTextSimilarity("hello", ""))
+	require.Equal(t, 1.0, ShadowTextSimilarity("Hello, World!", "world hello"))
+	require.InDelta(t, 1.0/3.0, ShadowTextSimilarity("a b", "b c"), 1e-9)
+	require.InDelta(t, 1.0/3.0, ShadowTextSimilarity("你好", "你们"), 1e-9)
+}
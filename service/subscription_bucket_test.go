@@ -0,0 +1,76 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/model"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBillingSessionSettlesSubscriptionTokenBucket(t *testing.T) {
+	truncate(t)
+	seedUser(t, 1, 0)
+	plan := &model.SubscriptionPlan{
+		Id:            501,
+		Title:         "Token bundle",
+		DurationUnit:  model.SubscriptionDurationMonth,
+		DurationValue: 1,
+		QuotaBuckets: model.SubscriptionQuotaBuckets{
+			{Key: "gpt", Name: "GPT-4o", Models: []string{"gpt-4o*"}, Unit: model.SubscriptionBucketUnitTokens, Amount: 10000},
+		},
+	}
+	require.NoError(t, model.DB.Create(plan).Error)
+	model.InvalidateSubscriptionPlanCache(plan.Id)
+	t.Cleanup(func() { model.InvalidateSubscriptionPlanCache(plan.Id) })
+	sub, err := model.CreateUserSubscriptionFromPlanTx(model.DB, 1, plan, "admin")
+	require.NoError(t, err)
+
+	bucketUsed := func() int64 {
+		var row model.UserSubscriptionBucket
+		require.NoError(t, model.DB.Where("user_subscription_id = ?", sub.Id).First(&row).Error)
+		return row.AmountUsed
+	}
+
+	c, _ := gin.CreateTestContext(nil)
+	relayInfo := &relaycommon.RelayInfo{
+		UserId:          1,
+		IsPlayground:    true,
+		RequestId:       "req-bucket-1",
+		OriginModelName: "gpt-4o-mini",
+		UserSetting:     dto.UserSetting{BillingPreference: "subscription_only"},
+	}
+	relayInfo.SetEstimatePromptTokens(200)
+	session, apiErr := NewBillingSession(c, relayInfo, 500)
+	require.Nil(t, apiErr)
+	require.Equal(t, BillingSourceSubscription, relayInfo.BillingSource)
+	require.NotZero(t, relayInfo.SubscriptionBucketId)
+	require.Equal(t, "GPT-4o", relayInfo.SubscriptionBucketName)
+	require.EqualValues(t, 200, bucketUsed())
+
+	// 按实际 token 结算桶用量，额度差值不计入套餐总额度
+	relayInfo.UsageTotalTokens = 1200
+	require.NoError(t, session.Settle(800))
+	require.EqualValues(t, 1200, bucketUsed())
+
+	var stored model.UserSubscription
+	require.NoError(t, model.DB.First(&stored, sub.Id).Error)
+	require.Zero(t, stored.AmountUsed)
+
+	// 未命中额度桶的模型使用套餐总额度
+	other := &relaycommon.RelayInfo{
+		UserId:          1,
+		IsPlayground:    true,
+		RequestId:       "req-bucket-2",
+		OriginModelName: "o3",
+		UserSetting:     dto.UserSetting{BillingPreference: "subscription_only"},
+	}
+	_, apiErr = NewBillingSession(c, other, 300)
+	require.Nil(t, apiErr)
+	require.Zero(t, other.SubscriptionBucketId)
+	require.NoError(t, model.DB.First(&stored, sub.Id).Error)
+	require.EqualValues(t, 300, stored.AmountUsed)
+	require.EqualValues(t, 1200, bucketUsed())
+}
@@ -17,7 +17,8 @@ import (
 
 // LogTaskConsumption 记录任务消费日志和统计信息（仅记录，不涉及实际扣费）。
 // 实际扣费已由 BillingSession（PreConsumeBilling + SettleBilling）完成。
-func LogTaskConsumption(c *gin.Context, info *relaycommon.RelayInfo) {
+// 返回按渠道成本价计算的提交阶段上游成本，供轮询阶段差额结算时扣除。
+func LogTaskConsumption(c *gin.Context, info *relaycommon.RelayInfo) int {
 	tokenName := c.GetString("token_name")
 	logContent := fmt.Sprintf("操作 %s", info.Action)
 	// 支持任务仅按次计费
@@ -51,19 +52,23 @@ func LogTaskConsumption(c *gin.Context, info *relaycommon.RelayInfo) {
 		other["is_model_mapped"] = true
 		other["upstream_model_name"] = info.UpstreamModelName
 	}
+	appendBillingInfo(info, other)
 	attachQuotaSaturation(c, info, other)
+	upstreamCost := ComputePerCallUpstreamCost(c, info)
 	model.RecordConsumeLog(c, info.UserId, model.RecordConsumeLogParams{
-		ChannelId: info.ChannelId,
-		ModelName: info.OriginModelName,
-		TokenName: tokenName,
-		Quota:     info.PriceData.Quota,
-		Content:   logContent,
-		TokenId:   info.TokenId,
-		Group:     info.UsingGroup,
-		Other:     other,
+		ChannelId:    info.ChannelId,
+		ModelName:    info.OriginModelName,
+		TokenName:    tokenName,
+		Quota:        info.PriceData.Quota,
+		UpstreamCost: upstreamCost,
+		Content:      logContent,
+		TokenId:      info.TokenId,
+		Group:        info.UsingGroup,
+		Other:        other,
 	})
 	model.UpdateUserUsedQuotaAndRequestCount(info.UserId, info.PriceData.Quota)
 	model.UpdateChannelUsedQuota(info.ChannelId, info.PriceData.Quota)
+	return upstreamCost
 }
 
 // ---------------------------------------------------------------------------
@@ -86,15 +91,41 @@ func taskIsSubscription(task *model.Task) bool {
 	return task.PrivateData.BillingSource == BillingSourceSubscription && task.PrivateData.SubscriptionId > 0
 }
 
-// taskAdjustFunding 调整任务的资金来源（钱包或订阅），delta > 0 表示扣费，delta < 0 表示退还。
+// taskIsOrganization 判断任务是否通过组织钱包计费。
+func taskIsOrganization(task *model.Task) bool {
+	return task.PrivateData.BillingSource == BillingSourceOrganization && task.PrivateData.OrganizationId > 0
+}
+
+// taskAdjustFunding 调整任务的资金来源（钱包、订阅或组织钱包），delta > 0 表示扣费，delta < 0 表示退还。
 func taskAdjustFunding(task *model.Task, delta int) error {
 	if taskIsSubscription(task) {
-		return model.PostConsumeUserSubscriptionDelta(task.PrivateData.SubscriptionId, int64(delta))
+		if err := model.PostConsumeUserSubscriptionQuotaDelta(task.PrivateData.SubscriptionId, task.PrivateData.SubscriptionBucketId, int64(delta), model.QuotaLedgerRefTaskId, task.TaskID); err != nil {
+			return err
+		}
+		// 组织订阅同步调整成员已用额度
+		if task.PrivateData.OrganizationId > 0 {
+			return model.SettleOrganizationMemberSpend(task.PrivateData.OrganizationId, task.UserId, delta)
+		}
+		return nil
 	}
-	if delta > 0 {
-		return model.DecreaseUserQuota(task.UserId, delta, false)
+	if taskIsOrganization(task) {
+		return model.SettleOrganizationQuota(task.PrivateData.OrganizationId, task.UserId, delta, model.QuotaLedgerRefTaskId, task.TaskID)
 	}
-	return model.IncreaseUserQuota(task.UserId, -delta, false)
+	// 提交时的全额已在 BillingSession 结算时消耗批次，这里只同步差额
+	return model.SettleUserWalletQuota(task.UserId, delta, delta, model.QuotaLedgerRefTaskId, task.TaskID)
+}
+
+// taskRefundSubscriptionBucket 全额退款时按额度桶自身的计量单位退还提交时的预扣量。
+// 按额度计量的桶随额度差值调整，由 taskAdjustFunding 处理。
+func taskRefundSubscriptionBucket(task *model.Task) error {
+	if !taskIsSubscription(task) || task.PrivateData.SubscriptionBucketId <= 0 || task.PrivateData.SubscriptionBucketConsumed <= 0 {
+		return nil
+	}
+	unit := task.PrivateData.SubscriptionBucketUnit
+	if unit == "" || unit == model.SubscriptionBucketUnitQuota {
+		return nil
+	}
+	return model.PostConsumeUserSubscriptionBucketDelta(task.PrivateData.SubscriptionBucketId, unit, -task.PrivateData.SubscriptionBucketConsumed)
 }
 
 // taskAdjustTokenQuota 调整任务的令牌额度，delta > 0 表示扣费，delta < 0 表示退还。
@@ -133,6 +164,9 @@ func taskBillingOther(task *model.Task) map[string]interface{} {
 			}
 		}
 	}
+	if task.PrivateData.BillingSource != "" {
+		other["billing_source"] = task.PrivateData.BillingSource
+	}
 	props := task.Properties
 	if props.UpstreamModelName != "" && props.UpstreamModelName != props.OriginModelName {
 		other["is_model_mapped"] = true
@@ -169,7 +203,11 @@ func RefundTaskQuota(ctx context.Context, task *model.Task, reason string) bool
 		return true
 	}
 
-	// 1. 退还资金来源（钱包或订阅）
+	// 1. 退还资金来源（钱包或订阅），按次或按 token 计量的额度桶按桶的单位退还
+	if err := taskRefundSubscriptionBucket(task); err != nil {
+		logger.LogWarn(ctx, fmt.Sprintf("退还订阅额度桶失败 task %s: %s", task.TaskID, err.Error()))
+		return false
+	}
 	if err := taskAdjustFunding(task, -quota); err != nil {
 		logger.LogWarn(ctx, fmt.Sprintf("退还资金来源失败 task %s: %s", task.TaskID, err.Error()))
 		return false
@@ -208,6 +246,12 @@ func RefundTaskQuota(ctx context.Context, task *model.Task, reason string) bool
 // reason 用于日志记录（例如 "token重算" 或 "adaptor调整"）。
 // clamps 可选：若计算 actualQuota 时发生额度饱和，将其记入日志 admin_info（仅管理员可见）。
 func RecalculateTaskQuota(ctx context.Context, task *model.Task, actualQuota int, reason string, clamps ...*common.QuotaClamp) {
+	recalculateTaskQuota(ctx, task, actualQuota, 0, reason, clamps...)
+}
+
+// recalculateTaskQuota 在差额结算的基础上记录上游成本差额。
+// actualUpstreamCost 为任务完成后的实际上游成本，0 表示未知，此时只结算额度。
+func recalculateTaskQuota(ctx context.Context, task *model.Task, actualQuota int, actualUpstreamCost int, reason string, clamps ...*common.QuotaClamp) {
 	if actualQuota <= 0 {
 		return
 	}
@@ -244,9 +288,14 @@ func RecalculateTaskQuota(ctx context.Context, task *model.Task, actualQuota int
 
 	var logType int
 	var logQuota int
+	var logUpstreamCost int
 	if quotaDelta > 0 {
 		logType = model.LogTypeConsume
 		logQuota = quotaDelta
+		// 提交时已按次记录的上游成本不再重复计入
+		if actualUpstreamCost > 0 {
+			logUpstreamCost = max(actualUpstreamCost-taskSubmitUpstreamCost(task), 0)
+		}
 		model.UpdateUserUsedQuotaAndRequestCount(task.UserId, quotaDelta)
 		model.UpdateChannelUsedQuota(task.ChannelId, quotaDelta)
 	} else {
@@ -261,19 +310,27 @@ func RecalculateTaskQuota(ctx context.Context, task *model.Task, actualQuota int
 		attachQuotaSaturationToOther(other, clamp)
 	}
 	model.RecordTaskBillingLog(model.RecordTaskBillingLogParams{
-		UserId:    task.UserId,
-		LogType:   logType,
-		Content:   reason,
-		ChannelId: task.ChannelId,
-		ModelName: taskModelName(task),
-		Quota:     logQuota,
-		TokenId:   task.PrivateData.TokenId,
-		Group:     task.Group,
-		Other:     other,
-		NodeName:  task.PrivateData.NodeName,
+		UserId:       task.UserId,
+		LogType:      logType,
+		Content:      reason,
+		ChannelId:    task.ChannelId,
+		ModelName:    taskModelName(task),
+		Quota:        logQuota,
+		UpstreamCost: logUpstreamCost,
+		TokenId:      task.PrivateData.TokenId,
+		Group:        task.Group,
+		Other:        other,
+		NodeName:     task.PrivateData.NodeName,
 	})
 }
 
+func taskSubmitUpstreamCost(task *model.Task) int {
+	if bc := task.PrivateData.BillingContext; bc != nil {
+		return bc.UpstreamCost
+	}
+	return 0
+}
+
 // RecalculateTaskQuotaByTokens 根据实际 token 消耗重新计费（异步差额结算）。
 // 当任务成功且返回了 totalTokens 时，根据模型倍率和分组倍率重新计算实际扣费额度，
 // 与预扣费的差额进行补扣或退还。支持钱包和订阅计费来源。
@@ -323,5 +380,6 @@ func RecalculateTaskQuotaByTokens(ctx context.Context, task *model.Task, totalTo
 	actualQuota, clamp := common.QuotaFromFloatChecked(float64(totalTokens) * modelRatio * finalGroupRatio * otherMultiplier)
 
 	reason := fmt.Sprintf("token重算：tokens=%d, modelRatio=%.2f, groupRatio=%.2f, otherMultiplier=%.4f", totalTokens, modelRatio, finalGroupRatio, otherMultiplier)
-	RecalculateTaskQuota(ctx, task, actualQuota, reason, clamp)
+	actualUpstreamCost, _ := computeTaskUpstreamCost(ctx, task, totalTokens)
+	recalculateTaskQuota(ctx, task, actualQuota, actualUpstreamCost, reason, clamp)
 }
@@ -12,6 +12,7 @@ import (
 	"github.com/QuantumNous/new-api/common"
 	"github.com/QuantumNous/new-api/model"
 	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
 	"github.com/QuantumNous/new-api/types"
 	"github.com/glebarez/sqlite"
 	"github.com/shopspring/decimal"
@@ -38,6 +39,8 @@ func TestMain(m *testing.M) {
 	common.RedisEnabled = false
 	common.BatchUpdateEnabled = false
 	common.LogConsumeEnabled = true
+	// 账本默认关闭，测试中开启以覆盖记账路径
+	operation_setting.GetQuotaLedgerSetting().Enabled = true
 
 	if err := db.AutoMigrate(
 		&model.Task{},
@@ -50,9 +53,27 @@ func TestMain(m *testing.M) {
 		&model.ChannelTestConfig{},
 		&model.BreakerPenaltyTrace{},
 		&model.TopUp{},
+		&model.SubscriptionPlan{},
 		&model.UserSubscription{},
+		&model.SubscriptionPreConsumeRecord{},
+		&model.UserSubscriptionBucket{},
 		&model.SystemTask{},
 		&model.SystemTaskLock{},
+		&model.QuotaLedgerEntry{},
+		&model.QuotaLedgerAccount{},
+		&model.QuotaLedgerDrift{},
+		&model.Coupon{},
+		&model.CouponRedemption{},
+		&model.AutoRechargeConfig{},
+		&model.AutoRechargeAttempt{},
+		&model.PostpaidAccount{},
+		&model.PostpaidStatement{},
+		&model.PostpaidStatementItem{},
+		&model.PostpaidPayment{},
+		&model.MediaObject{},
+		&model.StoredResponse{},
+		&model.QuotaLot{},
+		&model.TaskCallbackDelivery{},
 	); err != nil {
 		panic("failed to migrate: " + err.Error())
 	}
@@ -76,9 +97,24 @@ func truncate(t *testing.T) {
 		model.DB.Exec("DELETE FROM channel_test_configs")
 		model.DB.Exec("DELETE FROM breaker_penalty_traces")
 		model.DB.Exec("DELETE FROM top_ups")
+		model.DB.Exec("DELETE FROM subscription_plans")
 		model.DB.Exec("DELETE FROM user_subscriptions")
+		model.DB.Exec("DELETE FROM subscription_pre_consume_records")
+		model.DB.Exec("DELETE FROM user_subscription_buckets")
 		model.DB.Exec("DELETE FROM system_task_locks")
 		model.DB.Exec("DELETE FROM system_tasks")
+		model.DB.Exec("DELETE FROM quota_ledger_entries")
+		model.DB.Exec("DELETE FROM quota_ledger_accounts")
+		model.DB.Exec("DELETE FROM auto_recharge_configs")
+		model.DB.Exec("DELETE FROM auto_recharge_attempts")
+		model.DB.Exec("DELETE FROM postpaid_accounts")
+		model.DB.Exec("DELETE FROM postpaid_statements")
+		model.DB.Exec("DELETE FROM postpaid_statement_items")
+		model.DB.Exec("DELETE FROM postpaid_payments")
+		model.DB.Exec("DELETE FROM media_objects")
+		model.DB.Exec("DELETE FROM stored_responses")
+		model.DB.Exec("DELETE FROM quota_lots")
+		model.DB.Exec("DELETE FROM task_callback_deliveries")
 	})
 }
 
@@ -370,6 +406,44 @@ func TestRefundTaskQuota_Subscription(t *testing.T) {
 	assert.Zero(t, getTaskQuota(t, task.ID))
 }
 
+func TestRefundTaskQuota_SubscriptionRequestBucket(t *testing.T) {
+	truncate(t)
+	ctx := context.Background()
+
+	const userID, tokenID, channelID, subID = 3, 3, 3, 2
+	const preConsumed = 2000
+	const subTotal, subUsed int64 = 100000, 50000
+
+	seedUser(t, userID, 0)
+	seedToken(t, tokenID, userID, "sk-bucket-key", 8000)
+	seedChannel(t, channelID)
+	seedSubscription(t, subID, userID, subTotal, subUsed)
+	bucket := &model.UserSubscriptionBucket{
+		UserSubscriptionId: subID,
+		UserId:             userID,
+		BucketKey:          "video",
+		Unit:               model.SubscriptionBucketUnitRequests,
+		AmountTotal:        10,
+		AmountUsed:         4,
+	}
+	require.NoError(t, model.DB.Create(bucket).Error)
+
+	task := makeTask(userID, channelID, preConsumed, tokenID, BillingSourceSubscription, subID)
+	task.PrivateData.SubscriptionBucketId = bucket.Id
+	task.PrivateData.SubscriptionBucketUnit = model.SubscriptionBucketUnitRequests
+	task.PrivateData.SubscriptionBucketConsumed = 1
+	require.NoError(t, model.DB.Create(task).Error)
+
+	assert.True(t, RefundTaskQuota(ctx, task, "task failed"))
+
+	// 按次计量的桶退还提交时预扣的 1 次，订阅总额度不受影响
+	var stored model.UserSubscriptionBucket
+	require.NoError(t, model.DB.First(&stored, bucket.Id).Error)
+	assert.EqualValues(t, 3, stored.AmountUsed)
+	assert.Equal(t, subUsed, getSubscriptionUsed(t, subID))
+	assert.Zero(t, getTaskQuota(t, task.ID))
+}
+
 func TestRefundTaskQuota_ZeroQuota(t *testing.T) {
 	truncate(t)
 	ctx := context.Background()
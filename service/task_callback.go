@@ -0,0 +1,289 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+
+	"github.com/bytedance/gopkg/util/gopool"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	taskCallbackTickInterval = 15 * time.Second
+	taskCallbackBatchSize    = 100
+	// taskCallbackClaimLease 为单次投递占用的时长，节点在投递途中退出时到期后由其他节点重试
+	taskCallbackClaimLease = 120
+
+	TaskCallbackEventSucceeded = "task.succeeded"
+	TaskCallbackEventFailed    = "task.failed"
+	TaskCallbackEventCancelled = "task.cancelled"
+)
+
+// TaskCallbackPayload 为推送给客户端的任务终态通知
+type TaskCallbackPayload struct {
+	Event      string `json:"event"`
+	TaskId     string `json:"task_id"`
+	Platform   string `json:"platform"`
+	Action     string `json:"action"`
+	Model      string `json:"model,omitempty"`
+	Status     string `json:"status"`
+	Progress   string `json:"progress"`
+	FailReason string `json:"fail_reason,omitempty"`
+	ResultURL  string `json:"result_url,omitempty"`
+	SubmitTime int64  `json:"submit_time"`
+	FinishTime int64  `json:"finish_time"`
+	Timestamp  int64  `json:"timestamp"`
+}
+
+type taskCallbackRequest struct {
+	CallbackURL string `json:"callback_url"`
+}
+
+// ResolveTaskCallbackURL 返回任务提交请求的回调地址：优先使用请求中的 callback_url（JSON 或表单字段），
+// 其次使用令牌配置的默认回调地址。地址需通过 SSRF 校验
+func ResolveTaskCallbackURL(c *gin.Context, tokenId int) (string, error) {
+	if !operation_setting.GetTaskCallbackSetting().Enabled {
+		return "", nil
+	}
+	callbackURL := ""
+	if strings.HasPrefix(c.ContentType(), "multipart/form-data") || strings.HasPrefix(c.ContentType(), "application/x-www-form-urlencoded") {
+		callbackURL = c.PostForm("callback_url")
+	} else {
+		var req taskCallbackRequest
+		if err := common.UnmarshalBodyReusable(c, &req); err == nil {
+			callbackURL = req.CallbackURL
+		}
+	}
+	callbackURL = strings.TrimSpace(callbackURL)
+	if callbackURL == "" && tokenId > 0 {
+		if token, err := model.GetTokenById(tokenId); err == nil {
+			callbackURL = strings.TrimSpace(token.CallbackUrl)
+		}
+	}
+	if callbackURL == "" {
+		return "", nil
+	}
+	if err := ValidateTaskCallbackURL(callbackURL); err != nil {
+		return "", err
+	}
+	// 回调必须签名，接收方才能校验来源
+	if taskCallbackSecret(c.GetInt("id"), tokenId) == "" {
+		return "", errors.New("callback_url requires a signing secret: set callback_secret on the token or a webhook secret in notification settings")
+	}
+	return callbackURL, nil
+}
+
+// ValidateTaskCallbackURL 校验回调地址的协议、长度和 SSRF 规则
+func ValidateTaskCallbackURL(callbackURL string) error {
+	if len(callbackURL) > 1024 {
+		return errors.New("callback_url is too long")
+	}
+	if !strings.HasPrefix(callbackURL, "http://") && !strings.HasPrefix(callbackURL, "https://") {
+		return errors.New("callback_url must be an http or https url")
+	}
+	if err := ValidateSSRFProtectedFetchURL(callbackURL); err != nil {
+		return fmt.Errorf("callback_url rejected: %v", err)
+	}
+	return nil
+}
+
+// EnqueueTaskCallback 在任务到达终态后记录一条回调投递并立即尝试发送，失败的投递由重试任务按退避时间重发。
+// 调用方需保证只在赢得终态 CAS 后调用一次
+func EnqueueTaskCallback(ctx context.Context, task *model.Task) {
+	if task == nil || task.PrivateData.CallbackURL == "" || !operation_setting.GetTaskCallbackSetting().Enabled {
+		return
+	}
+	event := TaskCallbackEventSucceeded
+	switch task.Status {
+	case model.TaskStatusFailure:
+		event = TaskCallbackEventFailed
+	case model.TaskStatusCancelled:
+		event = TaskCallbackEventCancelled
+	}
+	payload := TaskCallbackPayload{
+		Event:      event,
+		TaskId:     task.TaskID,
+		Platform:   string(task.Platform),
+		Action:     task.Action,
+		Model:      task.Properties.OriginModelName,
+		Status:     string(task.Status),
+		Progress:   task.Progress,
+		FailReason: task.FailReason,
+		SubmitTime: task.SubmitTime,
+		FinishTime: task.FinishTime,
+		Timestamp:  time.Now().Unix(),
+	}
+	if task.Status == model.TaskStatusSuccess {
+		payload.ResultURL = TaskResultURL(task)
+	}
+	payloadBytes, err := common.Marshal(payload)
+	if err != nil {
+		logger.LogError(ctx, fmt.Sprintf("task callback marshal failed: task_id=%s, err=%v", task.TaskID, err))
+		return
+	}
+	delivery := &model.TaskCallbackDelivery{
+		TaskId:  task.TaskID,
+		UserId:  task.UserId,
+		TokenId: task.PrivateData.TokenId,
+		Url:     task.PrivateData.CallbackURL,
+		Event:   event,
+		Payload: string(payloadBytes),
+	}
+	if err := delivery.Insert(); err != nil {
+		logger.LogError(ctx, fmt.Sprintf("task callback enqueue failed: task_id=%s, err=%v", task.TaskID, err))
+		return
+	}
+	gopool.Go(func() {
+		deliverTaskCallback(context.Background(), delivery)
+	})
+}
+
+// taskCallbackRetryHandler 重发上次投递失败且已过退避时间的回调。Enabled 同时检查是否有到期的投递，
+// 空闲时不创建任务记录
+type taskCallbackRetryHandler struct{}
+
+func init() {
+	RegisterSystemTaskHandler(taskCallbackRetryHandler{})
+}
+
+func (taskCallbackRetryHandler) Type() string { return model.SystemTaskTypeTaskCallback }
+
+func (taskCallbackRetryHandler) Enabled() bool {
+	return operation_setting.GetTaskCallbackSetting().Enabled && model.HasDueTaskCallbackDeliveries(common.GetTimestamp())
+}
+
+func (taskCallbackRetryHandler) Interval() time.Duration { return taskCallbackTickInterval }
+
+func (taskCallbackRetryHandler) NewPayload() any { return nil }
+
+type TaskCallbackRetryResult struct {
+	Attempted int `json:"attempted"`
+}
+
+func (taskCallbackRetryHandler) Run(ctx context.Context, task *model.SystemTask, runnerID string) {
+	result := &TaskCallbackRetryResult{Attempted: RunTaskCallbackRetryOnce(ctx)}
+	if err := model.FinishSystemTask(task.TaskID, runnerID, model.SystemTaskStatusSucceeded, result, ""); err != nil {
+		logSystemTaskLockError(ctx, task, err)
+	}
+}
+
+// RunTaskCallbackRetryOnce delivers one batch of due callbacks and returns how
+// many deliveries were attempted.
+func RunTaskCallbackRetryOnce(ctx context.Context) int {
+	deliveries, err := model.GetDueTaskCallbackDeliveries(common.GetTimestamp(), taskCallbackBatchSize)
+	if err != nil {
+		logger.LogWarn(ctx, fmt.Sprintf("task callback retry query failed: %v", err))
+		return 0
+	}
+	attempted := 0
+	for _, delivery := range deliveries {
+		if ctx.Err() != nil {
+			break
+		}
+		if deliverTaskCallback(ctx, delivery) {
+			attempted++
+		}
+	}
+	return attempted
+}
+
+func deliverTaskCallback(ctx context.Context, delivery *model.TaskCallbackDelivery) bool {
+	claimed, err := model.ClaimTaskCallbackDelivery(delivery, taskCallbackClaimLease)
+	if err != nil {
+		logger.LogWarn(ctx, fmt.Sprintf("task callback claim failed: id=%d, err=%v", delivery.Id, err))
+		return false
+	}
+	if !claimed {
+		return false
+	}
+
+	setting := operation_setting.GetTaskCallbackSetting()
+	timeout := time.Duration(setting.TimeoutSeconds) * time.Second
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	sendCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var statusCode int
+	var sendErr error
+	secret := taskCallbackSecret(delivery.UserId, delivery.TokenId)
+	if secret == "" {
+		// 密钥在提交后被清除，不发送未签名的回调
+		sendErr = errors.New("callback signing secret is not configured")
+	} else {
+		timestamp := time.Now().Unix()
+		headers := map[string]string{
+			"X-Webhook-Event":     delivery.Event,
+			"X-Webhook-Delivery":  strconv.FormatInt(delivery.Id, 10),
+			"X-Webhook-Timestamp": strconv.FormatInt(timestamp, 10),
+			"X-Webhook-Signature": signTaskCallback(secret, timestamp, []byte(delivery.Payload)),
+		}
+		statusCode, sendErr = sendSignedWebhook(sendCtx, delivery.Url, "", []byte(delivery.Payload), headers)
+	}
+
+	var nextAttemptAt int64
+	if sendErr != nil && secret != "" && delivery.Attempts+1 < setting.MaxAttempts {
+		nextAttemptAt = common.GetTimestamp() + taskCallbackBackoffSeconds(delivery.Attempts+1, setting)
+	}
+	if err := model.FinishTaskCallbackAttempt(delivery, statusCode, sendErr, nextAttemptAt); err != nil {
+		logger.LogError(ctx, fmt.Sprintf("task callback record failed: id=%d, err=%v", delivery.Id, err))
+	}
+	if sendErr != nil {
+		logger.LogWarn(ctx, fmt.Sprintf("task callback delivery failed: id=%d, task_id=%s, attempts=%d, status=%s, err=%v",
+			delivery.Id, delivery.TaskId, delivery.Attempts, delivery.Status, sendErr))
+	}
+	return true
+}
+
+// taskCallbackSecret 使用令牌配置的回调密钥签名，未配置时回退到用户通知设置中的 webhook 密钥
+func taskCallbackSecret(userId int, tokenId int) string {
+	if tokenId > 0 {
+		if token, err := model.GetTokenById(tokenId); err == nil && token.CallbackSecret != "" {
+			return token.CallbackSecret
+		}
+	}
+	if userSetting, err := model.GetUserSetting(userId, false); err == nil {
+		return userSetting.WebhookSecret
+	}
+	return ""
+}
+
+// signTaskCallback 对 "时间戳.负载" 计算 HMAC-SHA256 签名，时间戳随 X-Webhook-Timestamp 发送，
+// 接收方可据此拒绝过期或重放的请求
+func signTaskCallback(secret string, timestamp int64, payload []byte) string {
+	message := make([]byte, 0, len(payload)+21)
+	message = strconv.AppendInt(message, timestamp, 10)
+	message = append(message, '.')
+	message = append(message, payload...)
+	return generateSignature(secret, message)
+}
+
+// taskCallbackBackoffSeconds 返回第 attempt 次失败后的重试间隔
+func taskCallbackBackoffSeconds(attempt int, setting *operation_setting.TaskCallbackSetting) int64 {
+	base := int64(setting.RetryBaseSeconds)
+	if base <= 0 {
+		base = 30
+	}
+	maxDelay := int64(setting.RetryMaxSeconds)
+	delay := base
+	for i := 1; i < attempt; i++ {
+		delay *= 2
+		if maxDelay > 0 && delay >= maxDelay {
+			return maxDelay
+		}
+	}
+	if maxDelay > 0 && delay > maxDelay {
+		return maxDelay
+	}
+	return delay
+}
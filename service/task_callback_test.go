@@ -0,0 +1,53 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTaskCallbackBackoffSeconds(t *testing.T) {
+	setting := &operation_setting.TaskCallbackSetting{RetryBaseSeconds: 30, RetryMaxSeconds: 200}
+	assert.EqualValues(t, 30, taskCallbackBackoffSeconds(1, setting))
+	assert.EqualValues(t, 60, taskCallbackBackoffSeconds(2, setting))
+	assert.EqualValues(t, 120, taskCallbackBackoffSeconds(3, setting))
+	assert.EqualValues(t, 200, taskCallbackBackoffSeconds(4, setting))
+	assert.EqualValues(t, 200, taskCallbackBackoffSeconds(10, setting))
+}
+
+func TestValidateTaskCallbackURLRejectsNonHTTP(t *testing.T) {
+	assert.Error(t, ValidateTaskCallbackURL("ftp://example.com/hook"))
+	assert.Error(t, ValidateTaskCallbackURL("http://127.0.0.1/hook"))
+}
+
+func TestSignTaskCallbackCoversTimestamp(t *testing.T) {
+	payload := []byte(`{"event":"task.succeeded"}`)
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write([]byte(`1700000000.{"event":"task.succeeded"}`))
+	assert.Equal(t, hex.EncodeToString(mac.Sum(nil)), signTaskCallback("secret", 1700000000, payload))
+	assert.NotEqual(t, signTaskCallback("secret", 1700000000, payload), signTaskCallback("secret", 1700000001, payload))
+}
+
+func TestDeliverTaskCallbackWithoutSecretFailsWithoutSending(t *testing.T) {
+	truncate(t)
+	seedUser(t, 1, 0)
+	delivery := &model.TaskCallbackDelivery{TaskId: "task-unsigned", UserId: 1, Url: "https://example.com/hook", Event: TaskCallbackEventSucceeded, Payload: "{}"}
+	require.NoError(t, delivery.Insert())
+	require.True(t, model.HasDueTaskCallbackDeliveries(common.GetTimestamp()))
+
+	require.True(t, deliverTaskCallback(context.Background(), delivery))
+	var stored model.TaskCallbackDelivery
+	require.NoError(t, model.DB.First(&stored, delivery.Id).Error)
+	assert.Equal(t, model.TaskCallbackStatusFailed, stored.Status)
+	assert.Zero(t, stored.LastStatusCode)
+	assert.Contains(t, stored.LastError, "secret")
+	assert.False(t, model.HasDueTaskCallbackDeliveries(common.GetTimestamp()))
+}
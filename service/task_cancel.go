@@ -0,0 +1,114 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/model"
+)
+
+const taskCancelReason = "任务已被用户取消"
+
+// CancelTask 取消一个进行中的异步任务：先调用上游取消接口，成功后以 CAS 将任务置为 CANCELLED，
+// 并通过 ClaimQuotaForRefund 退还预扣额度。目前只有适配器实现了 relay/channel.TaskCanceler 的平台（豆包、Vidu）支持取消，
+// 其余平台（如 Suno）返回 cancel_not_supported，避免上游继续生成而本地已退款
+func CancelTask(ctx context.Context, task *model.Task) *dto.TaskError {
+	if isTaskTerminal(task.Status) {
+		return TaskErrorWrapperLocal(fmt.Errorf("task is already finished, current status: %s", task.Status), "task_already_finished", http.StatusConflict)
+	}
+	if GetTaskAdaptorFunc == nil {
+		return TaskErrorWrapperLocal(errors.New("task adaptor not ready"), "cancel_not_supported", http.StatusBadRequest)
+	}
+	// service 不能引用 relay/channel，这里按 relay/channel.TaskCanceler 的方法集断言
+	canceler, ok := GetTaskAdaptorFunc(task.Platform).(interface {
+		CancelTask(baseURL string, key string, body map[string]any, proxy string) (*http.Response, error)
+	})
+	if !ok {
+		return TaskErrorWrapperLocal(fmt.Errorf("cancelling tasks is not supported for platform %s", task.Platform), "cancel_not_supported", http.StatusBadRequest)
+	}
+	upstreamID := task.GetUpstreamTaskID()
+	if upstreamID == "" {
+		return TaskErrorWrapperLocal(errors.New("task has no upstream task id"), "cancel_not_supported", http.StatusBadRequest)
+	}
+
+	ch, err := model.CacheGetChannel(task.ChannelId)
+	if err != nil {
+		return TaskErrorWrapperLocal(fmt.Errorf("get channel failed: %w", err), "get_channel_failed", http.StatusInternalServerError)
+	}
+	baseURL := constant.ChannelBaseURLs[ch.Type]
+	if ch.GetBaseURL() != "" {
+		baseURL = ch.GetBaseURL()
+	}
+	key := ch.Key
+	if task.PrivateData.Key != "" {
+		key = task.PrivateData.Key
+	}
+
+	resp, err := canceler.CancelTask(baseURL, key, map[string]any{
+		"task_id": upstreamID,
+		"action":  task.Action,
+	}, ch.GetSetting().Proxy)
+	if err != nil {
+		return TaskErrorWrapper(fmt.Errorf("cancel upstream task failed: %w", err), "cancel_task_failed", http.StatusBadGateway)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		logger.LogWarn(ctx, fmt.Sprintf("cancel task %s upstream status %d: %s", task.TaskID, resp.StatusCode, body))
+		return TaskErrorWrapperLocal(fmt.Errorf("upstream refused to cancel task, status code: %d", resp.StatusCode), "cancel_task_failed", http.StatusBadGateway)
+	}
+
+	oldStatus := task.Status
+	task.Status = model.TaskStatusCancelled
+	task.Progress = "100%"
+	task.FinishTime = time.Now().Unix()
+	task.FailReason = taskCancelReason
+	won, err := task.UpdateWithStatus(oldStatus)
+	if err != nil {
+		return TaskErrorWrapperLocal(fmt.Errorf("update task failed: %w", err), "update_task_failed", http.StatusInternalServerError)
+	}
+	if !won {
+		// 上游已取消，但轮询先一步写入了终态，以其结果为准
+		logger.LogInfo(ctx, fmt.Sprintf("CancelTask: task %s already transitioned, skip refund", task.TaskID))
+		if latest, exist, getErr := model.GetByOnlyTaskId(task.TaskID); getErr == nil && exist {
+			*task = *latest
+		}
+		return nil
+	}
+
+	refundCancelledTask(ctx, task)
+	notifyTaskTerminal(ctx, task)
+	return nil
+}
+
+// refundCancelledTask 通过 ClaimQuotaForRefund 认领退款，退款失败时恢复 quota 标记，交由对账重试
+func refundCancelledTask(ctx context.Context, task *model.Task) {
+	quota := task.Quota
+	if quota == 0 {
+		return
+	}
+	claimed, err := model.ClaimQuotaForRefund(task.ID, quota)
+	if err != nil {
+		logger.LogError(ctx, fmt.Sprintf("CancelTask claim error for task %s: %v", task.TaskID, err))
+		return
+	}
+	if !claimed {
+		return
+	}
+	if RefundTaskQuota(ctx, task, taskCancelReason) {
+		return
+	}
+	restored, restoreErr := model.RestoreQuotaAfterFailedRefund(task.ID, quota)
+	if restoreErr != nil {
+		logger.LogError(ctx, fmt.Sprintf("CancelTask restore quota error for task %s: %v", task.TaskID, restoreErr))
+	} else if !restored {
+		logger.LogError(ctx, fmt.Sprintf("CancelTask could not restore quota marker for task %s", task.TaskID))
+	}
+}
@@ -0,0 +1,92 @@
+package service
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type taskCancelAdaptor struct {
+	mockAdaptor
+	statusCode int
+	cancelled  []string
+}
+
+func (a *taskCancelAdaptor) CancelTask(_ string, _ string, body map[string]any, _ string) (*http.Response, error) {
+	a.cancelled = append(a.cancelled, body["task_id"].(string))
+	return &http.Response{StatusCode: a.statusCode, Body: io.NopCloser(strings.NewReader(`{}`))}, nil
+}
+
+func useTaskAdaptor(t *testing.T, adaptor TaskPollingAdaptor) {
+	t.Helper()
+	previousFactory := GetTaskAdaptorFunc
+	GetTaskAdaptorFunc = func(constant.TaskPlatform) TaskPollingAdaptor { return adaptor }
+	t.Cleanup(func() { GetTaskAdaptorFunc = previousFactory })
+}
+
+func TestCancelTaskRefundsQuota(t *testing.T) {
+	truncate(t)
+	ctx := context.Background()
+
+	const userID, tokenID, channelID = 1, 1, 1
+	const initQuota, preConsumed = 10000, 3000
+	seedUser(t, userID, initQuota)
+	seedToken(t, tokenID, userID, "sk-test-key", 5000)
+	seedChannel(t, channelID)
+
+	task := makeTask(userID, channelID, preConsumed, tokenID, BillingSourceWallet, 0)
+	task.PrivateData.UpstreamTaskID = "upstream_cancel"
+	require.NoError(t, model.DB.Create(task).Error)
+
+	adaptor := &taskCancelAdaptor{statusCode: http.StatusOK}
+	useTaskAdaptor(t, adaptor)
+
+	require.Nil(t, CancelTask(ctx, task))
+	assert.Equal(t, []string{"upstream_cancel"}, adaptor.cancelled)
+	assert.Equal(t, model.TaskStatus(model.TaskStatusCancelled), task.Status)
+	assert.Equal(t, initQuota+preConsumed, getUserQuota(t, userID))
+	assert.Zero(t, getTaskQuota(t, task.ID))
+
+	// 已取消的任务不能再次取消，也不会重复退款
+	taskErr := CancelTask(ctx, task)
+	require.NotNil(t, taskErr)
+	assert.Equal(t, http.StatusConflict, taskErr.StatusCode)
+	assert.Equal(t, initQuota+preConsumed, getUserQuota(t, userID))
+}
+
+func TestCancelTaskRejectedKeepsTaskRunning(t *testing.T) {
+	truncate(t)
+	ctx := context.Background()
+
+	const userID, channelID = 1, 1
+	seedUser(t, userID, 10000)
+	seedChannel(t, channelID)
+
+	task := makeTask(userID, channelID, 3000, 0, BillingSourceWallet, 0)
+	task.PrivateData.UpstreamTaskID = "upstream_running"
+	require.NoError(t, model.DB.Create(task).Error)
+
+	useTaskAdaptor(t, &mockAdaptor{})
+	taskErr := CancelTask(ctx, task)
+	require.NotNil(t, taskErr)
+	assert.Equal(t, http.StatusBadRequest, taskErr.StatusCode)
+	assert.Equal(t, "cancel_not_supported", taskErr.Code)
+
+	useTaskAdaptor(t, &taskCancelAdaptor{statusCode: http.StatusConflict})
+	taskErr = CancelTask(ctx, task)
+	require.NotNil(t, taskErr)
+	assert.Equal(t, http.StatusBadGateway, taskErr.StatusCode)
+
+	var stored model.Task
+	require.NoError(t, model.DB.First(&stored, task.ID).Error)
+	assert.Equal(t, model.TaskStatus(model.TaskStatusInProgress), stored.Status)
+	assert.Equal(t, 3000, stored.Quota)
+	assert.Equal(t, 10000, getUserQuota(t, userID))
+}
@@ -88,6 +88,7 @@ func sweepTimedOutTasks(ctx context.Context) {
 		if !isLegacy && task.Quota != 0 {
 			RefundTaskQuota(ctx, task, reason)
 		}
+		notifyTaskTerminal(ctx, task)
 	}
 
 	if timedOutCount > 0 {
@@ -344,8 +345,13 @@ func updateSunoTasks(ctx context.Context, channelId int, taskIds []string, taskM
 			logger.LogError(ctx, fmt.Sprintf("UpdateSunoTask task %s error: %v", task.TaskID, err))
 		} else if !won {
 			logger.LogWarn(ctx, fmt.Sprintf("Task %s CAS lost or no-op update, skip billing", task.TaskID))
-		} else if isFailure && prevStatus != model.TaskStatusFailure && task.Quota != 0 {
-			RefundTaskQuota(ctx, task, task.FailReason)
+		} else {
+			if isFailure && prevStatus != model.TaskStatusFailure && task.Quota != 0 {
+				RefundTaskQuota(ctx, task, task.FailReason)
+			}
+			if isTaskTerminal(task.Status) && !isTaskTerminal(prevStatus) {
+				notifyTaskTerminal(ctx, task)
+			}
 		}
 	}
 	return nil
@@ -612,8 +618,10 @@ func updateVideoSingleTask(ctx context.Context, adaptor TaskPollingAdaptor, ch *
 	}
 
 	isDone := task.Status == model.TaskStatusSuccess || task.Status == model.TaskStatusFailure
+	terminalWon := false
 	if isDone && snap.Status != task.Status {
 		won, err := task.UpdateWithStatus(snap.Status)
+		terminalWon = err == nil && won
 		if err != nil {
 			logger.LogError(ctx, fmt.Sprintf("UpdateWithStatus failed for task %s: %s", task.TaskID, err.Error()))
 			shouldRefund = false
@@ -638,10 +646,30 @@ func updateVideoSingleTask(ctx context.Context, adaptor TaskPollingAdaptor, ch *
 	if shouldRefund {
 		RefundTaskQuota(ctx, task, task.FailReason)
 	}
+	if terminalWon {
+		notifyTaskTerminal(ctx, task)
+	}
 
 	return nil
 }
 
+// notifyTaskTerminal 在任务赢得终态 CAS 后执行结果归档与客户端回调。
+// 开启归档时先归档再回调，回调中即可返回持久的签名地址
+func notifyTaskTerminal(ctx context.Context, task *model.Task) {
+	if task.Status == model.TaskStatusSuccess && MediaArchiveEnabled() {
+		ArchiveTaskMediaAsync(task, func() {
+			EnqueueTaskCallback(context.Background(), task)
+		})
+		return
+	}
+	EnqueueTaskCallback(ctx, task)
+}
+
+// isTaskTerminal 判断任务是否已到达终态
+func isTaskTerminal(status model.TaskStatus) bool {
+	return status == model.TaskStatusSuccess || status == model.TaskStatusFailure || status == model.TaskStatusCancelled
+}
+
 func redactVideoResponseBody(body []byte) []byte {
 	var m map[string]any
 	if err := common.Unmarshal(body, &m); err != nil {
@@ -0,0 +1,112 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/pkg/billingexpr"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+
+	"github.com/gin-gonic/gin"
+)
+
+// upstreamCostModelName is the model the channel was actually asked for,
+// which is what the upstream prices.
+func upstreamCostModelName(relayInfo *relaycommon.RelayInfo) string {
+	if relayInfo.ChannelMeta != nil && relayInfo.UpstreamModelName != "" {
+		return relayInfo.UpstreamModelName
+	}
+	return relayInfo.OriginModelName
+}
+
+// ComputeUpstreamCost evaluates the channel's upstream cost expression for
+// the request and returns the cost in quota units (no group ratio applied).
+// ok is false when the channel has no cost price for the model or the
+// expression fails; the failure is logged and never affects billing.
+func ComputeUpstreamCost(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, buildParams func(usedVars map[string]bool) billingexpr.TokenParams) (cost int, ok bool) {
+	if relayInfo == nil || relayInfo.ChannelMeta == nil {
+		return 0, false
+	}
+	exprStr := relayInfo.ChannelSetting.UpstreamCostExpr(upstreamCostModelName(relayInfo))
+	if exprStr == "" {
+		return 0, false
+	}
+
+	requestInput := billingexpr.RequestInput{}
+	if relayInfo.BillingRequestInput != nil {
+		requestInput = *relayInfo.BillingRequestInput
+	}
+	cost, err := evalUpstreamCostExpr(exprStr, buildParams(billingexpr.UsedVars(exprStr)), requestInput)
+	if err != nil {
+		logger.LogWarn(ctx, fmt.Sprintf("upstream cost expression failed: channel_id=%d, model=%s, error=%v", relayInfo.ChannelId, upstreamCostModelName(relayInfo), err))
+		return 0, false
+	}
+	return cost, true
+}
+
+func evalUpstreamCostExpr(exprStr string, params billingexpr.TokenParams, requestInput billingexpr.RequestInput) (int, error) {
+	snap := &billingexpr.BillingSnapshot{
+		ExprString:   exprStr,
+		ExprHash:     billingexpr.ExprHashString(exprStr),
+		GroupRatio:   1,
+		QuotaPerUnit: common.QuotaPerUnit,
+		ExprVersion:  billingexpr.ExprVersion(exprStr),
+	}
+	result, err := billingexpr.ComputeTieredQuotaWithRequest(snap, params, requestInput)
+	if err != nil {
+		return 0, err
+	}
+	return result.ActualQuotaAfterGroup, nil
+}
+
+// ComputePerCallUpstreamCost is ComputeUpstreamCost for requests billed per
+// call (Midjourney, async task submits), where no token usage is known.
+func ComputePerCallUpstreamCost(ctx *gin.Context, relayInfo *relaycommon.RelayInfo) int {
+	cost, _ := ComputeUpstreamCost(ctx, relayInfo, func(map[string]bool) billingexpr.TokenParams {
+		return billingexpr.TokenParams{}
+	})
+	return cost
+}
+
+// computeTaskUpstreamCost evaluates the task channel's upstream cost
+// expression once the task reports its token usage. It runs in the polling
+// loop, so the channel settings are read from the channel rather than a
+// relay context.
+func computeTaskUpstreamCost(ctx context.Context, task *model.Task, totalTokens int) (int, bool) {
+	channel, err := model.CacheGetChannel(task.ChannelId)
+	if err != nil {
+		return 0, false
+	}
+	modelName := task.Properties.UpstreamModelName
+	if modelName == "" {
+		modelName = taskModelName(task)
+	}
+	exprStr := channel.GetSetting().UpstreamCostExpr(modelName)
+	if exprStr == "" {
+		return 0, false
+	}
+	cost, err := evalUpstreamCostExpr(exprStr, billingexpr.TokenParams{
+		P:   float64(totalTokens),
+		Len: float64(totalTokens),
+	}, billingexpr.RequestInput{})
+	if err != nil {
+		logger.LogWarn(ctx, fmt.Sprintf("upstream cost expression failed: channel_id=%d, model=%s, error=%v", task.ChannelId, modelName, err))
+		return 0, false
+	}
+	return cost, true
+}
+
+// ComputeUsageUpstreamCost is ComputeUpstreamCost for a dto.Usage.
+func ComputeUsageUpstreamCost(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, usage *dto.Usage, isClaudeUsageSemantic bool) int {
+	if usage == nil {
+		return 0
+	}
+	cost, _ := ComputeUpstreamCost(ctx, relayInfo, func(usedVars map[string]bool) billingexpr.TokenParams {
+		return BuildTieredTokenParams(usage, isClaudeUsageSemantic, usedVars)
+	})
+	return cost
+}
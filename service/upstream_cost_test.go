@@ -0,0 +1,84 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/model"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/stretchr/testify/require"
+)
+
+func TestComputeUsageUpstreamCostUsesUpstreamModelExpr(t *testing.T) {
+	relayInfo := &relaycommon.RelayInfo{
+		OriginModelName: "alias-model",
+		ChannelMeta: &relaycommon.ChannelMeta{
+			UpstreamModelName: "gpt-4o",
+			ChannelSetting: dto.ChannelSettings{
+				UpstreamCostExprs: map[string]string{
+					"gpt-4o": "p * 2 + c * 8",
+					"*":      "p * 100",
+				},
+			},
+		},
+	}
+	usage := &dto.Usage{PromptTokens: 1000, CompletionTokens: 500}
+
+	// (1000*2 + 500*8) / 1e6 * QuotaPerUnit(500000) = 3000
+	require.Equal(t, 3000, ComputeUsageUpstreamCost(nil, relayInfo, usage, false))
+
+	relayInfo.UpstreamModelName = "other-model"
+	require.Equal(t, 50000, ComputeUsageUpstreamCost(nil, relayInfo, usage, false))
+}
+
+func TestComputeUpstreamCostWithoutExprIsZero(t *testing.T) {
+	relayInfo := &relaycommon.RelayInfo{
+		OriginModelName: "gpt-4o",
+		ChannelMeta:     &relaycommon.ChannelMeta{},
+	}
+	require.Zero(t, ComputeUsageUpstreamCost(nil, relayInfo, &dto.Usage{PromptTokens: 10}, false))
+	require.Zero(t, ComputeUsageUpstreamCost(nil, &relaycommon.RelayInfo{}, &dto.Usage{PromptTokens: 10}, false))
+}
+
+func TestComputePerCallUpstreamCost(t *testing.T) {
+	relayInfo := &relaycommon.RelayInfo{
+		OriginModelName: "mj_imagine",
+		ChannelMeta: &relaycommon.ChannelMeta{
+			ChannelSetting: dto.ChannelSettings{
+				UpstreamCostExprs: map[string]string{"*": "20000"},
+			},
+		},
+	}
+	// 20000 / 1e6 * QuotaPerUnit(500000) = 10000
+	require.Equal(t, 10000, ComputePerCallUpstreamCost(nil, relayInfo))
+}
+
+func TestRecalculateTaskQuotaByTokensRecordsUpstreamCostDelta(t *testing.T) {
+	truncate(t)
+	ctx := context.Background()
+
+	const userID, tokenID, channelID = 60, 60, 60
+	seedUser(t, userID, 10000)
+	seedToken(t, tokenID, userID, "sk-upstream-cost", 10000)
+	ch := &model.Channel{Id: channelID, Name: "test_channel", Key: "sk-test", Status: common.ChannelStatusEnabled}
+	ch.SetSetting(dto.ChannelSettings{UpstreamCostExprs: map[string]string{"test-model": "p * 4"}})
+	require.NoError(t, model.DB.Create(ch).Error)
+
+	task := makeTask(userID, channelID, 1000, tokenID, BillingSourceWallet, 0)
+	task.PrivateData.BillingContext.UpstreamCost = 500
+	require.NoError(t, model.DB.Create(task).Error)
+
+	// 1000 tokens * 4 / 1e6 * QuotaPerUnit(500000) = 2000，提交时已记录 500
+	actualUpstreamCost, ok := computeTaskUpstreamCost(ctx, task, 1000)
+	require.True(t, ok)
+	require.Equal(t, 2000, actualUpstreamCost)
+
+	recalculateTaskQuota(ctx, task, 3000, actualUpstreamCost, "token重算")
+	log := getLastLog(t)
+	require.NotNil(t, log)
+	require.Equal(t, model.LogTypeConsume, log.Type)
+	require.Equal(t, 2000, log.Quota)
+	require.Equal(t, 1500, log.UpstreamCost)
+}
@@ -32,10 +32,46 @@ func (p ChatCompletionsToResponsesPolicy) IsChannelEnabled(channelID int, channe
 	return false
 }
 
+// CompletionsFIMTemplate 带 suffix 的补全请求按模型匹配的填充模板，{prefix}、{suffix} 分别替换为 prompt 与 suffix
+type CompletionsFIMTemplate struct {
+	ModelPattern string `json:"model_pattern"`
+	System       string `json:"system,omitempty"`
+	Template     string `json:"template"`
+}
+
+// CompletionsToChatPolicy 控制 /v1/completions 在哪些渠道上转换为 Chat Completions 请求；
+// Claude、Gemini 等不提供 completions 接口的渠道类型始终转换，不受此开关影响。ModelPatterns 为空时匹配全部模型
+type CompletionsToChatPolicy struct {
+	Enabled       bool                     `json:"enabled"`
+	AllChannels   bool                     `json:"all_channels"`
+	ChannelIDs    []int                    `json:"channel_ids,omitempty"`
+	ChannelTypes  []int                    `json:"channel_types,omitempty"`
+	ModelPatterns []string                 `json:"model_patterns,omitempty"`
+	FIMTemplates  []CompletionsFIMTemplate `json:"fim_templates,omitempty"`
+}
+
+func (p CompletionsToChatPolicy) IsChannelEnabled(channelID int, channelType int) bool {
+	if !p.Enabled {
+		return false
+	}
+	if p.AllChannels {
+		return true
+	}
+
+	if channelID > 0 && len(p.ChannelIDs) > 0 && slices.Contains(p.ChannelIDs, channelID) {
+		return true
+	}
+	if channelType > 0 && len(p.ChannelTypes) > 0 && slices.Contains(p.ChannelTypes, channelType) {
+		return true
+	}
+	return false
+}
+
 type GlobalSettings struct {
 	PassThroughRequestEnabled        bool                             `json:"pass_through_request_enabled"`
 	ThinkingModelBlacklist           []string                         `json:"thinking_model_blacklist"`
 	ChatCompletionsToResponsesPolicy ChatCompletionsToResponsesPolicy `json:"chat_completions_to_responses_policy"`
+	CompletionsToChatPolicy          CompletionsToChatPolicy          `json:"completions_to_chat_policy"`
 }
 
 // 默认配置
@@ -49,6 +85,16 @@ var defaultOpenaiSettings = GlobalSettings{
 		Enabled:     false,
 		AllChannels: true,
 	},
+	CompletionsToChatPolicy: CompletionsToChatPolicy{
+		Enabled: false,
+		FIMTemplates: []CompletionsFIMTemplate{
+			{ModelPattern: `(?i)deepseek-coder`, Template: "<｜fim▁begin｜>{prefix}<｜fim▁hole｜>{suffix}<｜fim▁end｜>"},
+			{ModelPattern: `(?i)codellama`, Template: "<PRE> {prefix} <SUF>{suffix} <MID>"},
+			{ModelPattern: `(?i)qwen.*coder`, Template: "<|fim_prefix|>{prefix}<|fim_suffix|>{suffix}<|fim_middle|>"},
+			{ModelPattern: `(?i)(starcoder|santacoder)`, Template: "<fim_prefix>{prefix}<fim_suffix>{suffix}<fim_middle>"},
+			{ModelPattern: `(?i)codestral`, Template: "[SUFFIX]{suffix}[PREFIX]{prefix}"},
+		},
+	},
 }
 
 // 全局实例
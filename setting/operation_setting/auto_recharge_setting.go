@@ -0,0 +1,28 @@
+package operation_setting
+
+import "github.com/QuantumNous/new-api/setting/config"
+
+// AutoRechargeSetting 自动充值配置：钱包余额低于用户设定的阈值时，通过 Stripe 对已保存的支付方式离线扣款
+type AutoRechargeSetting struct {
+	Enabled                bool   `json:"enabled"`
+	Currency               string `json:"currency"`                 // Stripe 扣款币种，需与 StripeUnitPrice 的计价币种一致
+	MaxChargesPerDay       int    `json:"max_charges_per_day"`      // 每个用户每天最多自动充值次数
+	MaxConsecutiveFailures int    `json:"max_consecutive_failures"` // 连续失败达到该次数后自动关闭，0 表示不关闭
+	CooldownSeconds        int64  `json:"cooldown_seconds"`         // 两次自动充值之间的最小间隔
+}
+
+var autoRechargeSetting = AutoRechargeSetting{
+	Enabled:                false,
+	Currency:               "usd",
+	MaxChargesPerDay:       3,
+	MaxConsecutiveFailures: 3,
+	CooldownSeconds:        300,
+}
+
+func init() {
+	config.GlobalConfig.Register("auto_recharge_setting", &autoRechargeSetting)
+}
+
+func GetAutoRechargeSetting() *AutoRechargeSetting {
+	return &autoRechargeSetting
+}
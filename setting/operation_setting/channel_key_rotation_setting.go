@@ -0,0 +1,27 @@
+package operation_setting
+
+import "github.com/QuantumNous/new-api/setting/config"
+
+// ChannelKeyRotationSetting controls key expiry warnings and the grace period
+// applied when a channel key is rotated.
+type ChannelKeyRotationSetting struct {
+	ExpiryWarningEnabled bool  `json:"expiry_warning_enabled"`
+	ExpiryWarningDays    int   `json:"expiry_warning_days"`   // 到期前多少天开始提醒
+	DefaultGraceSeconds  int64 `json:"default_grace_seconds"` // 轮换请求未指定时的宽限期
+	MaxGraceSeconds      int64 `json:"max_grace_seconds"`
+}
+
+var channelKeyRotationSetting = ChannelKeyRotationSetting{
+	ExpiryWarningEnabled: true,
+	ExpiryWarningDays:    7,
+	DefaultGraceSeconds:  600,
+	MaxGraceSeconds:      7 * 24 * 3600,
+}
+
+func init() {
+	config.GlobalConfig.Register("channel_key_rotation_setting", &channelKeyRotationSetting)
+}
+
+func GetChannelKeyRotationSetting() *ChannelKeyRotationSetting {
+	return &channelKeyRotationSetting
+}
@@ -0,0 +1,27 @@
+package operation_setting
+
+import "github.com/QuantumNous/new-api/setting/config"
+
+// CreditExpirySetting 额度有效期配置：开启后按来源为入账额度生成带有效期的额度批次，
+// 结算时优先消耗最早到期的批次，过期未用完的部分由定时任务扣除。有效期为 0 表示永不过期
+type CreditExpirySetting struct {
+	Enabled              bool `json:"enabled"`
+	CheckinValidDays     int  `json:"checkin_valid_days"`      // 签到奖励有效期（天）
+	TopupValidDays       int  `json:"topup_valid_days"`        // 在线充值额度有效期（天）
+	CouponBonusValidDays int  `json:"coupon_bonus_valid_days"` // 优惠码赠送额度有效期（天）
+}
+
+var creditExpirySetting = CreditExpirySetting{
+	Enabled:              false,
+	CheckinValidDays:     30,
+	TopupValidDays:       0,
+	CouponBonusValidDays: 0,
+}
+
+func init() {
+	config.GlobalConfig.Register("credit_expiry_setting", &creditExpirySetting)
+}
+
+func GetCreditExpirySetting() *CreditExpirySetting {
+	return &creditExpirySetting
+}
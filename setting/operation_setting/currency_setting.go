@@ -0,0 +1,141 @@
+package operation_setting
+
+import (
+	"errors"
+	"math"
+	"strings"
+
+	"github.com/QuantumNous/new-api/setting/config"
+)
+
+const CurrencyUSD = "USD"
+
+// CurrencyRate 一种可用于展示和支付的货币，Rate 为 1 USD 可兑换的该货币数量
+type CurrencyRate struct {
+	Code      string  `json:"code"`
+	Symbol    string  `json:"symbol"`
+	Rate      float64 `json:"rate"`
+	Decimals  int     `json:"decimals"`   // 金额保留的小数位，JPY 等零小数位货币为 0
+	Manual    bool    `json:"manual"`     // 手动设置的汇率，自动刷新时不覆盖
+	UpdatedAt int64   `json:"updated_at"` // 汇率最后更新时间
+}
+
+// CurrencySetting 多币种配置：额度始终以 USD 计价（QuotaPerUnit），展示价格和支付金额按汇率换算
+type CurrencySetting struct {
+	Enabled    bool           `json:"enabled"`
+	Currencies []CurrencyRate `json:"currencies"`
+	// 汇率接口地址，返回以 USD 为基准的 {"rates": {"EUR": 0.92, ...}}，为空时只使用手动汇率
+	RateProviderURL string `json:"rate_provider_url"`
+	// 自动刷新汇率的间隔（分钟），0 表示不自动刷新
+	RefreshIntervalMinutes int `json:"refresh_interval_minutes"`
+	// StripePriceId / StripeUnitPrice 对应的币种
+	StripeCurrency string `json:"stripe_currency"`
+	// 易支付网关的结算币种（Price 的计价币种），易支付无法按其他币种收款
+	EpayCurrency string `json:"epay_currency"`
+}
+
+var currencySetting = CurrencySetting{
+	Enabled: false,
+	Currencies: []CurrencyRate{
+		{Code: "USD", Symbol: "$", Rate: 1, Decimals: 2, Manual: true},
+		{Code: "EUR", Symbol: "€", Rate: 0.92, Decimals: 2},
+		{Code: "JPY", Symbol: "¥", Rate: 150, Decimals: 0},
+	},
+	RateProviderURL:        "https://open.er-api.com/v6/latest/USD",
+	RefreshIntervalMinutes: 0,
+	StripeCurrency:         CurrencyUSD,
+	EpayCurrency:           "CNY",
+}
+
+var ErrCurrencyNotSupported = errors.New("不支持的货币")
+
+func init() {
+	config.GlobalConfig.Register("currency_setting", &currencySetting)
+}
+
+func GetCurrencySetting() *CurrencySetting {
+	return &currencySetting
+}
+
+// GetEnabledCurrencies 返回可选的货币列表，USD 始终可用并排在首位
+func GetEnabledCurrencies() []CurrencyRate {
+	usd := CurrencyRate{Code: CurrencyUSD, Symbol: "$", Rate: 1, Decimals: 2, Manual: true}
+	result := []CurrencyRate{usd}
+	if !currencySetting.Enabled {
+		return result
+	}
+	for _, currency := range currencySetting.Currencies {
+		code := strings.ToUpper(strings.TrimSpace(currency.Code))
+		if code == "" || currency.Rate <= 0 {
+			continue
+		}
+		currency.Code = code
+		if code == CurrencyUSD {
+			result[0] = currency
+			result[0].Rate = 1
+			continue
+		}
+		result = append(result, currency)
+	}
+	return result
+}
+
+// GetCurrencyRate 按货币代码查找汇率，未启用多币种时只支持 USD
+func GetCurrencyRate(code string) (CurrencyRate, bool) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if code == "" {
+		code = CurrencyUSD
+	}
+	for _, currency := range GetEnabledCurrencies() {
+		if currency.Code == code {
+			return currency, true
+		}
+	}
+	return CurrencyRate{}, false
+}
+
+// ConvertCurrency 把 from 货币金额按 USD 汇率换算为 to 货币，并按目标货币的小数位取整
+func ConvertCurrency(amount float64, from string, to string) (float64, error) {
+	source, ok := GetCurrencyRate(from)
+	if !ok {
+		return 0, ErrCurrencyNotSupported
+	}
+	target, ok := GetCurrencyRate(to)
+	if !ok {
+		return 0, ErrCurrencyNotSupported
+	}
+	if source.Code == target.Code {
+		return amount, nil
+	}
+	return RoundCurrencyAmount(amount/source.Rate*target.Rate, target.Decimals), nil
+}
+
+// RoundCurrencyAmount 按货币小数位四舍五入
+func RoundCurrencyAmount(amount float64, decimals int) float64 {
+	if decimals < 0 {
+		decimals = 0
+	}
+	pow := math.Pow10(decimals)
+	return math.Round(amount*pow) / pow
+}
+
+// MergeCurrencyRates 用汇率接口返回的数据更新非手动设置的货币，返回新的货币列表和更新数量
+func MergeCurrencyRates(rates map[string]float64, updatedAt int64) ([]CurrencyRate, int) {
+	merged := make([]CurrencyRate, len(currencySetting.Currencies))
+	copy(merged, currencySetting.Currencies)
+	updated := 0
+	for i := range merged {
+		code := strings.ToUpper(strings.TrimSpace(merged[i].Code))
+		if merged[i].Manual || code == CurrencyUSD {
+			continue
+		}
+		rate, ok := rates[code]
+		if !ok || rate <= 0 {
+			continue
+		}
+		merged[i].Rate = rate
+		merged[i].UpdatedAt = updatedAt
+		updated++
+	}
+	return merged, updated
+}
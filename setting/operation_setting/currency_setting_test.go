@@ -0,0 +1,72 @@
+package operation_setting
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConvertCurrencyUsesUsdRatesAndTargetDecimals(t *testing.T) {
+	orig := currencySetting
+	t.Cleanup(func() { currencySetting = orig })
+
+	currencySetting = CurrencySetting{
+		Enabled: true,
+		Currencies: []CurrencyRate{
+			{Code: "eur", Symbol: "€", Rate: 0.9, Decimals: 2},
+			{Code: "JPY", Symbol: "¥", Rate: 150, Decimals: 0},
+			{Code: "GBP", Rate: 0},
+		},
+	}
+
+	amount, err := ConvertCurrency(10, "USD", "EUR")
+	require.NoError(t, err)
+	assert.InDelta(t, 9, amount, 1e-9)
+
+	amount, err = ConvertCurrency(9.99, "EUR", "jpy")
+	require.NoError(t, err)
+	assert.Equal(t, float64(1665), amount)
+
+	// 汇率无效或未配置的货币不可用
+	_, err = ConvertCurrency(1, "USD", "GBP")
+	assert.ErrorIs(t, err, ErrCurrencyNotSupported)
+
+	codes := []string{}
+	for _, currency := range GetEnabledCurrencies() {
+		codes = append(codes, currency.Code)
+	}
+	assert.Equal(t, []string{"USD", "EUR", "JPY"}, codes)
+
+	// 关闭多币种后只保留 USD
+	currencySetting.Enabled = false
+	_, ok := GetCurrencyRate("EUR")
+	assert.False(t, ok)
+	usd, ok := GetCurrencyRate("")
+	require.True(t, ok)
+	assert.Equal(t, float64(1), usd.Rate)
+}
+
+func TestMergeCurrencyRatesSkipsManualRates(t *testing.T) {
+	orig := currencySetting
+	t.Cleanup(func() { currencySetting = orig })
+
+	currencySetting = CurrencySetting{
+		Enabled: true,
+		Currencies: []CurrencyRate{
+			{Code: "USD", Rate: 1, Manual: true},
+			{Code: "EUR", Rate: 0.9},
+			{Code: "JPY", Rate: 140, Manual: true},
+			{Code: "CHF", Rate: 0.8},
+		},
+	}
+
+	merged, updated := MergeCurrencyRates(map[string]float64{"EUR": 0.93, "JPY": 155, "USD": 2}, 1700000000)
+	assert.Equal(t, 1, updated)
+	assert.Equal(t, 0.93, merged[1].Rate)
+	assert.EqualValues(t, 1700000000, merged[1].UpdatedAt)
+	assert.Equal(t, float64(140), merged[2].Rate)
+	assert.Equal(t, 0.8, merged[3].Rate)
+	// 不修改当前配置，由调用方保存
+	assert.Equal(t, 0.9, currencySetting.Currencies[1].Rate)
+}
@@ -0,0 +1,30 @@
+package operation_setting
+
+import "github.com/QuantumNous/new-api/setting/config"
+
+// InvoiceSetting 发票/收据配置，Seller* 为开票方信息
+type InvoiceSetting struct {
+	Enabled       bool   `json:"enabled"`
+	EmailEnabled  bool   `json:"email_enabled"` // 开票后通过邮件发送给用户
+	NumberPrefix  string `json:"number_prefix"` // 发票编号前缀，编号格式为 <前缀>-<年份>-<6 位序号>
+	Currency      string `json:"currency"`
+	SellerName    string `json:"seller_name"`
+	SellerAddress string `json:"seller_address"`
+	SellerTaxId   string `json:"seller_tax_id"`
+	Footer        string `json:"footer"`
+}
+
+var invoiceSetting = InvoiceSetting{
+	Enabled:      false,
+	EmailEnabled: true,
+	NumberPrefix: "INV",
+	Currency:     "USD",
+}
+
+func init() {
+	config.GlobalConfig.Register("invoice_setting", &invoiceSetting)
+}
+
+func GetInvoiceSetting() *InvoiceSetting {
+	return &invoiceSetting
+}
@@ -0,0 +1,48 @@
+package operation_setting
+
+import "github.com/QuantumNous/new-api/setting/config"
+
+const (
+	MediaArchiveBackendLocal = "local"
+	MediaArchiveBackendS3    = "s3"
+)
+
+// MediaArchiveSetting 生成结果归档配置：开启后任务成功时把上游返回的视频/图片下载到存储后端，
+// 之后通过网关签名地址访问，避免上游地址过期。RetentionDays 为 0 表示永久保留；
+// PricePerGBDay 大于 0 时按 GB·天 从用户余额扣除存储费用（单位：美元）
+type MediaArchiveSetting struct {
+	Enabled          bool    `json:"enabled"`
+	Backend          string  `json:"backend"`
+	LocalDir         string  `json:"local_dir"`
+	S3Endpoint       string  `json:"s3_endpoint"`
+	S3Region         string  `json:"s3_region"`
+	S3Bucket         string  `json:"s3_bucket"`
+	S3AccessKeyId    string  `json:"s3_access_key_id"`
+	S3Secret         string  `json:"s3_secret"`
+	S3PathStyle      bool    `json:"s3_path_style"`
+	S3Prefix         string  `json:"s3_prefix"`
+	MaxSizeMB        int     `json:"max_size_mb"`
+	RetentionDays    int     `json:"retention_days"`
+	SignedURLTTLSecs int     `json:"signed_url_ttl_seconds"`
+	PricePerGBDay    float64 `json:"price_per_gb_day"`
+}
+
+var mediaArchiveSetting = MediaArchiveSetting{
+	Enabled:          false,
+	Backend:          MediaArchiveBackendLocal,
+	LocalDir:         "data/media",
+	S3Region:         "us-east-1",
+	S3PathStyle:      true,
+	MaxSizeMB:        512,
+	RetentionDays:    30,
+	SignedURLTTLSecs: 7 * 24 * 3600,
+	PricePerGBDay:    0,
+}
+
+func init() {
+	config.GlobalConfig.Register("media_archive_setting", &mediaArchiveSetting)
+}
+
+func GetMediaArchiveSetting() *MediaArchiveSetting {
+	return &mediaArchiveSetting
+}
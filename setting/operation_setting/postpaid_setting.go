@@ -0,0 +1,25 @@
+package operation_setting
+
+import "github.com/QuantumNous/new-api/setting/config"
+
+// PostpaidSetting 后付费（信用额度）配置：开通信用额度的用户或组织余额可以透支到额度上限，
+// 每月初为上个自然月（UTC）生成账单，逾期未结清时暂停其令牌
+type PostpaidSetting struct {
+	Enabled        bool `json:"enabled"`
+	GraceDays      int  `json:"grace_days"`      // 账单生成后的付款期限（天），账户可单独覆盖
+	SuspendOverdue bool `json:"suspend_overdue"` // 逾期后是否暂停令牌
+}
+
+var postpaidSetting = PostpaidSetting{
+	Enabled:        false,
+	GraceDays:      15,
+	SuspendOverdue: true,
+}
+
+func init() {
+	config.GlobalConfig.Register("postpaid_setting", &postpaidSetting)
+}
+
+func GetPostpaidSetting() *PostpaidSetting {
+	return &postpaidSetting
+}
@@ -0,0 +1,30 @@
+package operation_setting
+
+import "github.com/QuantumNous/new-api/setting/config"
+
+// QuotaLedgerSetting controls the quota ledger and its reconciliation job.
+// The ledger is off by default: every billed request adds its own ledger
+// writes. Reconciliation compares users.quota on the master node and is
+// skipped while batch updates are enabled, since other nodes may still hold
+// unflushed quota deltas.
+type QuotaLedgerSetting struct {
+	Enabled                  bool `json:"enabled"`
+	ReconcileEnabled         bool `json:"reconcile_enabled"`
+	ReconcileIntervalMinutes int  `json:"reconcile_interval_minutes"`
+	DriftTolerance           int  `json:"drift_tolerance"` // 允许的账本与余额差额（额度单位）
+}
+
+var quotaLedgerSetting = QuotaLedgerSetting{
+	Enabled:                  false,
+	ReconcileEnabled:         true,
+	ReconcileIntervalMinutes: 60,
+	DriftTolerance:           0,
+}
+
+func init() {
+	config.GlobalConfig.Register("quota_ledger_setting", &quotaLedgerSetting)
+}
+
+func GetQuotaLedgerSetting() *QuotaLedgerSetting {
+	return &quotaLedgerSetting
+}
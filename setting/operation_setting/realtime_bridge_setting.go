@@ -0,0 +1,32 @@
+package operation_setting
+
+import "github.com/QuantumNous/new-api/setting/config"
+
+// RealtimeBridgeRule maps a realtime model onto an ordinary STT + chat + TTS
+// pipeline. Each hop is relayed as a separate request and billed against its
+// own model, so the realtime model itself needs no channel or price.
+type RealtimeBridgeRule struct {
+	Model              string `json:"model"`
+	ChatModel          string `json:"chat_model"`
+	TranscriptionModel string `json:"transcription_model"`
+	SpeechModel        string `json:"speech_model"`
+	Voice              string `json:"voice,omitempty"` // default voice when the session does not set one
+}
+
+type RealtimeBridgeSetting struct {
+	Enabled bool                 `json:"enabled"`
+	Rules   []RealtimeBridgeRule `json:"rules"`
+}
+
+var realtimeBridgeSetting = RealtimeBridgeSetting{
+	Enabled: false,
+	Rules:   []RealtimeBridgeRule{},
+}
+
+func init() {
+	config.GlobalConfig.Register("realtime_bridge_setting", &realtimeBridgeSetting)
+}
+
+func GetRealtimeBridgeSetting() *RealtimeBridgeSetting {
+	return &realtimeBridgeSetting
+}
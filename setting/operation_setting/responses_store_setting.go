@@ -0,0 +1,26 @@
+package operation_setting
+
+import "github.com/QuantumNous/new-api/setting/config"
+
+// ResponsesStoreSetting Responses API 状态模拟配置：/v1/responses 被转换到 Chat/Claude/Gemini 等无状态上游时，
+// 由网关按用户保存响应条目，使 previous_response_id 与 GET/DELETE /v1/responses/:id 可用。
+// TTLHours 为保存时长，MaxSizeKB 为单条记录（含完整上下文）的大小上限，超出时不保存
+type ResponsesStoreSetting struct {
+	Enabled   bool `json:"enabled"`
+	TTLHours  int  `json:"ttl_hours"`
+	MaxSizeKB int  `json:"max_size_kb"`
+}
+
+var responsesStoreSetting = ResponsesStoreSetting{
+	Enabled:   false,
+	TTLHours:  30 * 24,
+	MaxSizeKB: 1024,
+}
+
+func init() {
+	config.GlobalConfig.Register("responses_store_setting", &responsesStoreSetting)
+}
+
+func GetResponsesStoreSetting() *ResponsesStoreSetting {
+	return &responsesStoreSetting
+}
@@ -0,0 +1,39 @@
+package operation_setting
+
+import "github.com/QuantumNous/new-api/setting/config"
+
+// ShadowTrafficRule mirrors a share of live traffic for matching group/model
+// pairs to a shadow channel. The client only ever receives the primary
+// response; the shadow result is recorded as a comparison row.
+type ShadowTrafficRule struct {
+	Name            string   `json:"name"`
+	Groups          []string `json:"groups,omitempty"` // empty matches every group
+	ModelRegex      []string `json:"model_regex"`
+	ShadowChannelId int      `json:"shadow_channel_id"`
+	Percent         float64  `json:"percent"` // 0-100
+	CompareText     bool     `json:"compare_text"`
+}
+
+type ShadowTrafficSetting struct {
+	Enabled        bool                `json:"enabled"`
+	MaxInFlight    int                 `json:"max_in_flight"`
+	TimeoutSeconds int                 `json:"timeout_seconds"`
+	RetentionDays  int                 `json:"retention_days"`
+	Rules          []ShadowTrafficRule `json:"rules"`
+}
+
+var shadowTrafficSetting = ShadowTrafficSetting{
+	Enabled:        false,
+	MaxInFlight:    16,
+	TimeoutSeconds: 120,
+	RetentionDays:  30,
+	Rules:          []ShadowTrafficRule{},
+}
+
+func init() {
+	config.GlobalConfig.Register("shadow_traffic_setting", &shadowTrafficSetting)
+}
+
+func GetShadowTrafficSetting() *ShadowTrafficSetting {
+	return &shadowTrafficSetting
+}
@@ -0,0 +1,28 @@
+package operation_setting
+
+import "github.com/QuantumNous/new-api/setting/config"
+
+// TaskCallbackSetting controls callback deliveries for finished async tasks.
+type TaskCallbackSetting struct {
+	Enabled          bool `json:"enabled"`
+	MaxAttempts      int  `json:"max_attempts"`
+	RetryBaseSeconds int  `json:"retry_base_seconds"` // 第 n 次重试间隔为 base × 2^(n-1)
+	RetryMaxSeconds  int  `json:"retry_max_seconds"`
+	TimeoutSeconds   int  `json:"timeout_seconds"`
+}
+
+var taskCallbackSetting = TaskCallbackSetting{
+	Enabled:          true,
+	MaxAttempts:      6,
+	RetryBaseSeconds: 30,
+	RetryMaxSeconds:  3600,
+	TimeoutSeconds:   10,
+}
+
+func init() {
+	config.GlobalConfig.Register("task_callback_setting", &taskCallbackSetting)
+}
+
+func GetTaskCallbackSetting() *TaskCallbackSetting {
+	return &taskCallbackSetting
+}
@@ -0,0 +1,9 @@
+package setting
+
+var PayPalClientId = ""
+var PayPalClientSecret = ""
+var PayPalWebhookId = ""
+var PayPalSandbox = false
+var PayPalCurrency = "USD"
+var PayPalUnitPrice = 1.0
+var PayPalMinTopUp = 1
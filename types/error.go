@@ -50,6 +50,7 @@ const (
 	ErrorCodeDoRequestFailed    ErrorCode = "do_request_failed"
 	ErrorCodeGetChannelFailed   ErrorCode = "get_channel_failed"
 	ErrorCodeGenRelayInfoFailed ErrorCode = "gen_relay_info_failed"
+	ErrorCodeConcurrencyLimited ErrorCode = "concurrency_limited"
 
 	// channel error
 	ErrorCodeChannelNoAvailableKey            ErrorCode = "channel:no_available_key"
@@ -68,7 +69,7 @@ const (
 	ErrorCodeAccessDenied          ErrorCode = "access_denied"
 
 	// request error
-	ErrorCodeBadRequestBody ErrorCode = "bad_request_body"
+	ErrorCodeBadRequestBody     ErrorCode = "bad_request_body"
 	ErrorCodeDownstreamCanceled ErrorCode = "downstream_canceled"
 
 	// response error
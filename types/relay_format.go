@@ -4,6 +4,7 @@ type RelayFormat string
 
 const (
 	RelayFormatOpenAI                    RelayFormat = "openai"
+	RelayFormatOpenAICompletions                     = "openai_completions"
 	RelayFormatClaude                                = "claude"
 	RelayFormatGemini                                = "gemini"
 	RelayFormatOpenAIResponses                       = "openai_responses"
@@ -13,6 +14,11 @@ const (
 	RelayFormatOpenAIRealtime                        = "openai_realtime"
 	RelayFormatRerank                                = "rerank"
 	RelayFormatEmbedding                             = "embedding"
+	RelayFormatOllama                                = "ollama"
+	RelayFormatBedrockConverse                       = "bedrock_converse"
+	RelayFormatGeminiEmbedding                       = "gemini_embedding"
+	RelayFormatCohereEmbedding                       = "cohere_embedding"
+	RelayFormatGeminiImagen                          = "gemini_imagen"
 
 	RelayFormatTask    = "task"
 	RelayFormatMjProxy = "mj_proxy"